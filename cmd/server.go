@@ -18,6 +18,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	homedir "github.com/mitchellh/go-homedir"
@@ -27,6 +28,7 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/runatlantis/atlantis/server"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
 	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketcloud"
 	"github.com/runatlantis/atlantis/server/logging"
 )
@@ -50,20 +52,25 @@ const (
 	ADHostnameFlag                   = "azuredevops-hostname"
 	AllowCommandsFlag                = "allow-commands"
 	AllowForkPRsFlag                 = "allow-fork-prs"
+	ApplyQueueMaxSizeFlag            = "apply-queue-max-size"
 	AtlantisURLFlag                  = "atlantis-url"
 	AutoDiscoverModeFlag             = "autodiscover-mode"
 	AutomergeFlag                    = "automerge"
 	ParallelPlanFlag                 = "parallel-plan"
 	ParallelApplyFlag                = "parallel-apply"
+	PlanEncryptionKeyFileFlag        = "plan-encryption-key-file"
+	PlanEncryptionKMSKeyIDFlag       = "plan-encryption-kms-key-id"
 	AutoplanModules                  = "autoplan-modules"
 	AutoplanModulesFromProjects      = "autoplan-modules-from-projects"
 	AutoplanFileListFlag             = "autoplan-file-list"
+	AutoplanDebounceSecondsFlag      = "autoplan-debounce-seconds"
 	BitbucketBaseURLFlag             = "bitbucket-base-url"
 	BitbucketTokenFlag               = "bitbucket-token"
 	BitbucketUserFlag                = "bitbucket-user"
 	BitbucketWebhookSecretFlag       = "bitbucket-webhook-secret"
 	CheckoutDepthFlag                = "checkout-depth"
 	CheckoutStrategyFlag             = "checkout-strategy"
+	CloneURLRewritesFlag             = "clone-url-rewrites"
 	ConfigFlag                       = "config"
 	DataDirFlag                      = "data-dir"
 	DefaultTFVersionFlag             = "default-tf-version"
@@ -109,6 +116,7 @@ const (
 	LockingDBType                    = "locking-db-type"
 	LogLevelFlag                     = "log-level"
 	MarkdownTemplateOverridesDirFlag = "markdown-template-overrides-dir"
+	OTelEndpointFlag                 = "otel-endpoint"
 	ParallelPoolSize                 = "parallel-pool-size"
 	StatsNamespace                   = "stats-namespace"
 	AllowDraftPRs                    = "allow-draft-prs"
@@ -122,6 +130,7 @@ const (
 	RepoConfigFlag                   = "repo-config"
 	RepoConfigJSONFlag               = "repo-config-json"
 	RepoAllowlistFlag                = "repo-allowlist"
+	SecretsDirFlag                   = "secrets-dir"
 	SilenceNoProjectsFlag            = "silence-no-projects"
 	SilenceForkPRErrorsFlag          = "silence-fork-pr-errors"
 	SilenceVCSStatusNoPlans          = "silence-vcs-status-no-plans"
@@ -131,12 +140,22 @@ const (
 	SlackTokenFlag                   = "slack-token"
 	SSLCertFileFlag                  = "ssl-cert-file"
 	SSLKeyFileFlag                   = "ssl-key-file"
+	StepCacheMaxSizeMBFlag           = "step-cache-max-size-mb"
 	RestrictFileList                 = "restrict-file-list"
+	RunIDEnvVarFlag                  = "run-id-env-var"
+	RunIDHeaderFlag                  = "run-id-header"
+	RunStepFixturesFlag              = "run-step-fixtures"
+	RunStepFixturesDirFlag           = "run-step-fixtures-dir"
 	TFDownloadFlag                   = "tf-download"
 	TFDownloadURLFlag                = "tf-download-url"
 	UseTFPluginCache                 = "use-tf-plugin-cache"
 	VarFileAllowlistFlag             = "var-file-allowlist"
+	VCSMaxRetriesFlag                = "vcs-max-retries"
+	VCSRetryBackoffSecondsFlag       = "vcs-retry-backoff-seconds"
 	VCSStatusName                    = "vcs-status-name"
+	VCSStatusContextTemplateFlag     = "vcs-status-context-template"
+	VCSStatusPlanSummaryFlag         = "vcs-status-plan-summary"
+	VCSStatusStrategyFlag            = "vcs-status-strategy"
 	TFEHostnameFlag                  = "tfe-hostname"
 	TFELocalExecutionModeFlag        = "tfe-local-execution-mode"
 	TFETokenFlag                     = "tfe-token"
@@ -153,6 +172,7 @@ const (
 	DefaultAutoDiscoverMode             = "auto"
 	DefaultAutoplanFileList             = "**/*.tf,**/*.tfvars,**/*.tfvars.json,**/terragrunt.hcl,**/.terraform.lock.hcl"
 	DefaultAllowCommands                = "version,plan,apply,unlock,approve_policies"
+	DefaultApplyQueueMaxSize            = 10
 	DefaultCheckoutStrategy             = CheckoutStrategyBranch
 	DefaultCheckoutDepth                = 0
 	DefaultBitbucketBaseURL             = bitbucketcloud.BaseURL
@@ -168,14 +188,18 @@ const (
 	DefaultLogLevel                     = "info"
 	DefaultParallelPoolSize             = 15
 	DefaultStatsNamespace               = "atlantis"
+	DefaultStepCacheMaxSizeMB           = 1024
 	DefaultPort                         = 4141
 	DefaultRedisDB                      = 0
 	DefaultRedisPort                    = 6379
 	DefaultRedisTLSEnabled              = false
 	DefaultRedisInsecureSkipVerify      = false
+	DefaultRunIDEnvVar                  = valid.DefaultRunIDEnvVar
 	DefaultTFDownloadURL                = "https://releases.hashicorp.com"
 	DefaultTFDownload                   = true
 	DefaultTFEHostname                  = "app.terraform.io"
+	DefaultVCSMaxRetries                = 3
+	DefaultVCSRetryBackoffSeconds       = 1
 	DefaultVCSStatusName                = "atlantis"
 	DefaultWebBasicAuth                 = false
 	DefaultWebUsername                  = "atlantis"
@@ -215,7 +239,8 @@ var stringFlags = map[string]stringFlag{
 	AutoDiscoverModeFlag: {
 		description: "Auto discover mode controls whether projects in a repo are discovered by Atlantis. Defaults to 'auto' which " +
 			"means projects will be discovered when no explicit projects are defined in repo config. Also supports 'enabled' (always " +
-			"discover projects) and 'disabled' (never discover projects).",
+			"discover projects), 'disabled' (never discover projects) and 'backend' (always discover projects, treating only " +
+			"directories with a backend or cloud block as projects).",
 		defaultValue: DefaultAutoDiscoverMode,
 	},
 	AutoplanModulesFromProjects: {
@@ -259,6 +284,12 @@ var stringFlags = map[string]stringFlag{
 			" after the pull request is merged.",
 		defaultValue: "branch",
 	},
+	CloneURLRewritesFlag: {
+		description: "Comma separated list of rules rewriting the URLs repos are cloned from, ex. to clone from an internal mirror." +
+			" The format is {regex}={replacement}, e.g. '^https://github\\.com/=https://git-mirror.internal/'." +
+			" The replacement can refer to the regex's capture groups with $1." +
+			" Credentials in the clone URL are kept for the rewritten host. Comments and statuses are still posted to the VCS host.",
+	},
 	ConfigFlag: {
 		description: "Path to yaml config file where flag values can also be set.",
 	},
@@ -372,10 +403,22 @@ var stringFlags = map[string]stringFlag{
 		description:  "Directory for custom overrides to the markdown templates used for comments.",
 		defaultValue: DefaultMarkdownTemplateOverridesDir,
 	},
+	OTelEndpointFlag: {
+		description: "OTLP/HTTP endpoint of an OpenTelemetry collector, ex. http://localhost:4318, to export traces of runs to, with a span for each project and step." +
+			" Traces continue the trace of webhooks with a W3C traceparent header. If not set, runs aren't traced.",
+	},
 	StatsNamespace: {
 		description:  "Namespace for aggregating stats.",
 		defaultValue: DefaultStatsNamespace,
 	},
+	PlanEncryptionKeyFileFlag: {
+		description: "File of the AES-256 keys plans and their JSON are encrypted at rest with, one per line as <id>:<base64 key>." +
+			" The first key encrypts plans, every key decrypts them, so keys are rotated by adding a new first line.",
+	},
+	PlanEncryptionKMSKeyIDFlag: {
+		description: "ID or ARN of the AWS KMS key plans and their JSON are encrypted at rest with, using the aws CLI." +
+			fmt.Sprintf(" Can't be used with --%s.", PlanEncryptionKeyFileFlag),
+	},
 	RedisHost: {
 		description: "The Redis Hostname for when using a Locking DB type of 'redis'.",
 	},
@@ -394,6 +437,25 @@ var stringFlags = map[string]stringFlag{
 			"all repos: '*' (not secure), an entire hostname: 'internalgithub.com/*' or an organization: 'github.com/runatlantis/*'." +
 			" For Bitbucket Server, {owner} is the name of the project (not the key).",
 	},
+	RunIDEnvVarFlag: {
+		description:  "Name of the env var that custom run, env and multienv steps get the ID of the run they're part of in. The ID is also shown at the bottom of pull request comments.",
+		defaultValue: DefaultRunIDEnvVar,
+	},
+	RunIDHeaderFlag: {
+		description: "Webhook request header, ex. X-Request-Id, whose value is used as the ID of the run the webhook starts so runs can be correlated with the system that sent it." +
+			" If not set, or the header isn't in the request, a new ID is generated for each run.",
+	},
+	RunStepFixturesFlag: {
+		description: "Set to 'record' to record the output of each custom run, env and multienv step as a fixture, or 'replay' to comment the recorded outputs instead of running the steps, to test workflows." +
+			" Only meant for developing workflows, not for production.",
+	},
+	RunStepFixturesDirFlag: {
+		description: fmt.Sprintf("Directory run step fixtures are recorded to and replayed from with --%s. Defaults to run-step-fixtures in the data dir.", RunStepFixturesFlag),
+	},
+	SecretsDirFlag: {
+		description: "Directory of secrets that env steps can reference with ${{ secrets.NAME }}, one file per secret named after it, ex. a mounted Kubernetes secret." +
+			" If not set, referencing a secret is an error.",
+	},
 	SlackTokenFlag: {
 		description: "API token for Slack notifications.",
 	},
@@ -428,6 +490,15 @@ var stringFlags = map[string]stringFlag{
 		description:  "Name used to identify Atlantis for pull request statuses.",
 		defaultValue: DefaultVCSStatusName,
 	},
+	VCSStatusContextTemplateFlag: {
+		description: "Go template used to name pull request statuses, ex. 'ci/atlantis/{{ .Command }}{{ if .Project }}/{{ .Project }}{{ end }}'." +
+			" Available fields are .StatusName, .Command, .Project, .ProjectName, .Dir and .Workspace. The project fields are empty for statuses that cover all projects." +
+			" Defaults to '{{ .StatusName }}/{{ .Command }}{{ if .Project }}: {{ .Project }}{{ end }}'.",
+	},
+	VCSStatusStrategyFlag: {
+		description: fmt.Sprintf("Which pull request statuses to set: %q for only the statuses that cover all projects, %q for only one status per project.", valid.StatusStrategyAggregate, valid.StatusStrategyPerProject) +
+			" If not set, both are set. Overridden by a repo's status_strategy in the server-side repo config.",
+	},
 	WebUsernameFlag: {
 		description:  "Username used for Web Basic Authentication on Atlantis HTTP Middleware",
 		defaultValue: DefaultWebUsername,
@@ -542,6 +613,10 @@ var boolFlags = map[string]boolFlag{
 		description:  "Silences the posting of allowlist error comments.",
 		defaultValue: false,
 	},
+	VCSStatusPlanSummaryFlag: {
+		description:  "Include the number of resources to add, change and destroy, summed across projects, in the description of the combined plan commit status.",
+		defaultValue: false,
+	},
 	DisableMarkdownFoldingFlag: {
 		description:  "Toggle off folding in markdown output.",
 		defaultValue: false,
@@ -585,6 +660,18 @@ var boolFlags = map[string]boolFlag{
 	},
 }
 var intFlags = map[string]intFlag{
+	ApplyQueueMaxSizeFlag: {
+		description: "Max number of applies, commented with 'atlantis apply --queue', that can be queued for each locked project." +
+			" Only projects with repo_locks mode on_apply can be queued." +
+			" Queued applies are kept in memory so they're lost when Atlantis restarts.",
+		defaultValue: DefaultApplyQueueMaxSize,
+	},
+	AutoplanDebounceSecondsFlag: {
+		description: "Wait this many seconds after a pull request is opened or updated before autoplanning it." +
+			" If it's updated again in that time only the latest commit is planned, and an autoplan that's already running stops planning further projects." +
+			" Defaults to 0, which autoplans immediately.",
+		defaultValue: 0,
+	},
 	CheckoutDepthFlag: {
 		description: fmt.Sprintf("Used only if --%s=%s.", CheckoutStrategyFlag, CheckoutStrategyMerge) +
 			" How many commits to include in each of base and feature branches when cloning repository." +
@@ -611,6 +698,20 @@ var intFlags = map[string]intFlag{
 		description:  "The Redis Port for when using a Locking DB type of 'redis'.",
 		defaultValue: DefaultRedisPort,
 	},
+	StepCacheMaxSizeMBFlag: {
+		description: "Max size in megabytes of the cache of run steps with cache set, stored in the data dir." +
+			" The least recently used entries are evicted once it's bigger.",
+		defaultValue: DefaultStepCacheMaxSizeMB,
+	},
+	VCSMaxRetriesFlag: {
+		description:  "Max number of times a request to the VCS host is retried when it's rate limited, or has a server error if the request is idempotent.",
+		defaultValue: DefaultVCSMaxRetries,
+	},
+	VCSRetryBackoffSecondsFlag: {
+		description: "Seconds to wait before retrying a request to the VCS host for the first time. The wait doubles with each retry." +
+			" A Retry-After header sent by the VCS host takes precedence.",
+		defaultValue: DefaultVCSRetryBackoffSeconds,
+	},
 }
 
 var int64Flags = map[string]int64Flag{
@@ -623,6 +724,9 @@ var int64Flags = map[string]int64Flag{
 // ValidLogLevels are the valid log levels that can be set
 var ValidLogLevels = []string{"debug", "info", "warn", "error"}
 
+// envVarNameRegex matches valid env var names.
+var envVarNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 type stringFlag struct {
 	description  string
 	defaultValue string
@@ -801,12 +905,13 @@ func (s *ServerCmd) run() error {
 
 	// Config looks good. Start the server.
 	server, err := s.ServerCreator.NewServer(userConfig, server.Config{
-		AllowForkPRsFlag:        AllowForkPRsFlag,
-		AtlantisURLFlag:         AtlantisURLFlag,
-		AtlantisVersion:         s.AtlantisVersion,
-		DefaultTFVersionFlag:    DefaultTFVersionFlag,
-		RepoConfigJSONFlag:      RepoConfigJSONFlag,
-		SilenceForkPRErrorsFlag: SilenceForkPRErrorsFlag,
+		AllowForkPRsFlag:             AllowForkPRsFlag,
+		AtlantisURLFlag:              AtlantisURLFlag,
+		AtlantisVersion:              s.AtlantisVersion,
+		DefaultTFVersionFlag:         DefaultTFVersionFlag,
+		RepoConfigJSONFlag:           RepoConfigJSONFlag,
+		SilenceForkPRErrorsFlag:      SilenceForkPRErrorsFlag,
+		VCSStatusContextTemplateFlag: VCSStatusContextTemplateFlag,
 	})
 
 	if err != nil {
@@ -867,9 +972,22 @@ func (s *ServerCmd) setDefaults(c *server.UserConfig) {
 	if c.ParallelPoolSize == 0 {
 		c.ParallelPoolSize = DefaultParallelPoolSize
 	}
+	// 0 disables the apply queue so it's only defaulted if it isn't set.
+	if !s.Viper.IsSet(ApplyQueueMaxSizeFlag) {
+		c.ApplyQueueMaxSize = DefaultApplyQueueMaxSize
+	}
 	if c.StatsNamespace == "" {
 		c.StatsNamespace = DefaultStatsNamespace
 	}
+	if c.StepCacheMaxSizeMB == 0 {
+		c.StepCacheMaxSizeMB = DefaultStepCacheMaxSizeMB
+	}
+	if c.VCSMaxRetries == 0 {
+		c.VCSMaxRetries = DefaultVCSMaxRetries
+	}
+	if c.VCSRetryBackoffSeconds == 0 {
+		c.VCSRetryBackoffSeconds = DefaultVCSRetryBackoffSeconds
+	}
 	if c.Port == 0 {
 		c.Port = DefaultPort
 	}
@@ -879,6 +997,9 @@ func (s *ServerCmd) setDefaults(c *server.UserConfig) {
 	if c.RedisPort == 0 {
 		c.RedisPort = DefaultRedisPort
 	}
+	if c.RunIDEnvVar == "" {
+		c.RunIDEnvVar = DefaultRunIDEnvVar
+	}
 	if c.TFDownloadURL == "" {
 		c.TFDownloadURL = DefaultTFDownloadURL
 	}
@@ -911,6 +1032,11 @@ func (s *ServerCmd) validate(userConfig server.UserConfig) error {
 			CheckoutStrategyBranch, CheckoutStrategyMerge)
 	}
 
+	if statusStrategy := userConfig.VCSStatusStrategy; statusStrategy != "" && statusStrategy != valid.StatusStrategyAggregate && statusStrategy != valid.StatusStrategyPerProject {
+		return fmt.Errorf("invalid --%s: not one of %s or %s",
+			VCSStatusStrategyFlag, valid.StatusStrategyAggregate, valid.StatusStrategyPerProject)
+	}
+
 	if (userConfig.SSLKeyFile == "") != (userConfig.SSLCertFile == "") {
 		return fmt.Errorf("--%s and --%s are both required for ssl", SSLKeyFileFlag, SSLCertFileFlag)
 	}
@@ -974,6 +1100,10 @@ func (s *ServerCmd) validate(userConfig server.UserConfig) error {
 		return fmt.Errorf("cannot use --%s and --%s at the same time", RepoConfigFlag, RepoConfigJSONFlag)
 	}
 
+	if userConfig.PlanEncryptionKeyFile != "" && userConfig.PlanEncryptionKMSKeyID != "" {
+		return fmt.Errorf("cannot use --%s and --%s at the same time", PlanEncryptionKeyFileFlag, PlanEncryptionKMSKeyIDFlag)
+	}
+
 	// Warn if any tokens have newlines.
 	for name, token := range map[string]string{
 		GHTokenFlag:                userConfig.GithubToken,
@@ -994,6 +1124,40 @@ func (s *ServerCmd) validate(userConfig server.UserConfig) error {
 		return fmt.Errorf("if setting --%s, must set --%s", TFEHostnameFlag, TFETokenFlag)
 	}
 
+	if userConfig.ApplyQueueMaxSize < 0 {
+		return fmt.Errorf("--%s must not be negative", ApplyQueueMaxSizeFlag)
+	}
+
+	if userConfig.AutoplanDebounceSeconds < 0 {
+		return fmt.Errorf("--%s must not be negative", AutoplanDebounceSecondsFlag)
+	}
+
+	if userConfig.OTelEndpoint != "" {
+		if u, err := url.Parse(userConfig.OTelEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid --%s %q, must be an http or https URL, ex. http://localhost:4318", OTelEndpointFlag, userConfig.OTelEndpoint)
+		}
+	}
+
+	if userConfig.RunStepFixtures != "" && userConfig.RunStepFixtures != "record" && userConfig.RunStepFixtures != "replay" {
+		return fmt.Errorf("invalid --%s %q, must be \"record\" or \"replay\"", RunStepFixturesFlag, userConfig.RunStepFixtures)
+	}
+
+	if userConfig.StepCacheMaxSizeMB < 0 {
+		return fmt.Errorf("--%s must not be negative", StepCacheMaxSizeMBFlag)
+	}
+
+	if userConfig.VCSMaxRetries < 0 {
+		return fmt.Errorf("--%s must not be negative", VCSMaxRetriesFlag)
+	}
+
+	if userConfig.VCSRetryBackoffSeconds < 0 {
+		return fmt.Errorf("--%s must not be negative", VCSRetryBackoffSecondsFlag)
+	}
+
+	if !envVarNameRegex.MatchString(userConfig.RunIDEnvVar) {
+		return fmt.Errorf("--%s must be a valid env var name, got %q", RunIDEnvVarFlag, userConfig.RunIDEnvVar)
+	}
+
 	_, patternErr := patternmatcher.New(strings.Split(userConfig.AutoplanFileList, ","))
 	if patternErr != nil {
 		return errors.Wrapf(patternErr, "invalid pattern in --%s, %s", AutoplanFileListFlag, userConfig.AutoplanFileList)
@@ -1003,6 +1167,10 @@ func (s *ServerCmd) validate(userConfig server.UserConfig) error {
 		return errors.Wrapf(err, "invalid --%s", AllowCommandsFlag)
 	}
 
+	if _, err := userConfig.ToCloneURLRewrites(); err != nil {
+		return errors.Wrapf(err, "invalid --%s", CloneURLRewritesFlag)
+	}
+
 	return nil
 }
 
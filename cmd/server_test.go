@@ -60,8 +60,10 @@ var testFlags = map[string]interface{}{
 	AtlantisURLFlag:                  "url",
 	AutoplanModules:                  false,
 	AutoplanModulesFromProjects:      "",
+	AutoplanDebounceSecondsFlag:      10,
 	AllowCommandsFlag:                "version,plan,apply,unlock,import,approve_policies",
 	AllowForkPRsFlag:                 true,
+	ApplyQueueMaxSizeFlag:            20,
 	APISecretFlag:                    "",
 	AutoDiscoverModeFlag:             "auto",
 	AutomergeFlag:                    true,
@@ -72,6 +74,7 @@ var testFlags = map[string]interface{}{
 	BitbucketWebhookSecretFlag:       "bitbucket-secret",
 	CheckoutStrategyFlag:             CheckoutStrategyMerge,
 	CheckoutDepthFlag:                0,
+	CloneURLRewritesFlag:             "^https://github\\.com/=https://git-mirror.internal/",
 	DataDirFlag:                      "/path",
 	DefaultTFVersionFlag:             "v0.11.0",
 	DisableApplyAllFlag:              true,
@@ -108,6 +111,7 @@ var testFlags = map[string]interface{}{
 	LockingDBType:                    "boltdb",
 	LogLevelFlag:                     "debug",
 	MarkdownTemplateOverridesDirFlag: "/path2",
+	OTelEndpointFlag:                 "http://localhost:4318",
 	StatsNamespace:                   "atlantis",
 	AllowDraftPRs:                    true,
 	PortFlag:                         8181,
@@ -115,6 +119,8 @@ var testFlags = map[string]interface{}{
 	ParallelPlanFlag:                 true,
 	ParallelApplyFlag:                true,
 	QuietPolicyChecks:                false,
+	PlanEncryptionKeyFileFlag:        "/run/secrets/plan-keys",
+	PlanEncryptionKMSKeyIDFlag:       "",
 	RedisHost:                        "",
 	RedisInsecureSkipVerify:          false,
 	RedisPassword:                    "",
@@ -124,6 +130,7 @@ var testFlags = map[string]interface{}{
 	RepoAllowlistFlag:                "github.com/runatlantis/atlantis",
 	RepoConfigFlag:                   "",
 	RepoConfigJSONFlag:               "",
+	SecretsDirFlag:                   "/run/secrets",
 	SilenceNoProjectsFlag:            false,
 	SilenceVCSStatusNoProjectsFlag:   false,
 	SilenceForkPRErrorsFlag:          true,
@@ -133,7 +140,12 @@ var testFlags = map[string]interface{}{
 	SlackTokenFlag:                   "slack-token",
 	SSLCertFileFlag:                  "cert-file",
 	SSLKeyFileFlag:                   "key-file",
+	StepCacheMaxSizeMBFlag:           512,
 	RestrictFileList:                 false,
+	RunIDEnvVarFlag:                  "MY_RUN_ID",
+	RunIDHeaderFlag:                  "X-Request-Id",
+	RunStepFixturesFlag:              "record",
+	RunStepFixturesDirFlag:           "/var/atlantis/fixtures",
 	TFDownloadFlag:                   true,
 	TFDownloadURLFlag:                "https://my-hostname.com",
 	TFEHostnameFlag:                  "my-hostname",
@@ -141,7 +153,12 @@ var testFlags = map[string]interface{}{
 	TFETokenFlag:                     "my-token",
 	UseTFPluginCache:                 true,
 	VarFileAllowlistFlag:             "/path",
+	VCSMaxRetriesFlag:                5,
+	VCSRetryBackoffSecondsFlag:       2,
 	VCSStatusName:                    "my-status",
+	VCSStatusContextTemplateFlag:     "ci/{{ .Command }}",
+	VCSStatusPlanSummaryFlag:         true,
+	VCSStatusStrategyFlag:            "per-project",
 	WebBasicAuthFlag:                 false,
 	WebPasswordFlag:                  "atlantis",
 	WebUsernameFlag:                  "atlantis",
@@ -380,6 +397,79 @@ func TestExecute_ValidateCheckoutStrategy(t *testing.T) {
 	ErrEquals(t, "invalid checkout strategy: not one of branch or merge", err)
 }
 
+func TestExecute_ValidateVCSStatusStrategy(t *testing.T) {
+	c := setupWithDefaults(map[string]interface{}{
+		VCSStatusStrategyFlag: "invalid",
+	}, t)
+	err := c.Execute()
+	ErrEquals(t, "invalid --vcs-status-strategy: not one of aggregate or per-project", err)
+}
+
+func TestExecute_ValidateApplyQueueMaxSize(t *testing.T) {
+	c := setupWithDefaults(map[string]interface{}{
+		ApplyQueueMaxSizeFlag: -1,
+	}, t)
+	err := c.Execute()
+	ErrEquals(t, "--apply-queue-max-size must not be negative", err)
+}
+
+func TestExecute_ApplyQueueMaxSizeZero(t *testing.T) {
+	t.Log("0 should disable the apply queue rather than use the default.")
+	c := setupWithDefaults(map[string]interface{}{
+		ApplyQueueMaxSizeFlag: 0,
+	}, t)
+	Ok(t, c.Execute())
+	Equals(t, 0, passedConfig.ApplyQueueMaxSize)
+}
+
+func TestExecute_ValidateCloneURLRewrites(t *testing.T) {
+	c := setupWithDefaults(map[string]interface{}{
+		CloneURLRewritesFlag: "github\\.com",
+	}, t)
+	err := c.Execute()
+	ErrEquals(t, `invalid --clone-url-rewrites: invalid clone URL rewrite "github\\.com", must be of the form {regex}={replacement}`, err)
+}
+
+func TestExecute_ValidateAutoplanDebounce(t *testing.T) {
+	c := setupWithDefaults(map[string]interface{}{
+		AutoplanDebounceSecondsFlag: -1,
+	}, t)
+	err := c.Execute()
+	ErrEquals(t, "--autoplan-debounce-seconds must not be negative", err)
+}
+
+func TestExecute_ValidateVCSRetries(t *testing.T) {
+	c := setupWithDefaults(map[string]interface{}{
+		VCSMaxRetriesFlag: -1,
+	}, t)
+	err := c.Execute()
+	ErrEquals(t, "--vcs-max-retries must not be negative", err)
+}
+
+func TestExecute_ValidateRunIDEnvVar(t *testing.T) {
+	c := setupWithDefaults(map[string]interface{}{
+		RunIDEnvVarFlag: "RUN-ID",
+	}, t)
+	err := c.Execute()
+	ErrEquals(t, `--run-id-env-var must be a valid env var name, got "RUN-ID"`, err)
+}
+
+func TestExecute_ValidateRunStepFixtures(t *testing.T) {
+	c := setupWithDefaults(map[string]interface{}{
+		RunStepFixturesFlag: "rewind",
+	}, t)
+	err := c.Execute()
+	ErrEquals(t, `invalid --run-step-fixtures "rewind", must be "record" or "replay"`, err)
+}
+
+func TestExecute_ValidateOTelEndpoint(t *testing.T) {
+	c := setupWithDefaults(map[string]interface{}{
+		OTelEndpointFlag: "localhost:4318",
+	}, t)
+	err := c.Execute()
+	ErrEquals(t, `invalid --otel-endpoint "localhost:4318", must be an http or https URL, ex. http://localhost:4318`, err)
+}
+
 func TestExecute_ValidateSSLConfig(t *testing.T) {
 	expErr := "--ssl-key-file and --ssl-cert-file are both required for ssl"
 	cases := []struct {
@@ -854,6 +944,19 @@ func TestExecute_RepoCfgFlags(t *testing.T) {
 	ErrEquals(t, "cannot use --repo-config and --repo-config-json at the same time", err)
 }
 
+// Can't use both --plan-encryption-key-file and --plan-encryption-kms-key-id.
+func TestExecute_PlanEncryptionFlags(t *testing.T) {
+	c := setup(map[string]interface{}{
+		GHUserFlag:                 "user",
+		GHTokenFlag:                "token",
+		RepoAllowlistFlag:          "github.com",
+		PlanEncryptionKeyFileFlag:  "plan-keys",
+		PlanEncryptionKMSKeyIDFlag: "alias/atlantis",
+	}, t)
+	err := c.Execute()
+	ErrEquals(t, "cannot use --plan-encryption-key-file and --plan-encryption-kms-key-id at the same time", err)
+}
+
 // Can't use both --tfe-hostname flag without --tfe-token.
 func TestExecute_TFEHostnameOnly(t *testing.T) {
 	c := setup(map[string]interface{}{
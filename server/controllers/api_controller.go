@@ -8,6 +8,8 @@ import (
 	"strings"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
 	"github.com/runatlantis/atlantis/server/core/locking"
 	"github.com/runatlantis/atlantis/server/events"
 	"github.com/runatlantis/atlantis/server/events/command"
@@ -33,6 +35,12 @@ type APIController struct {
 	RepoAllowlistChecker           *events.RepoAllowlistChecker
 	Scope                          tally.Scope
 	VCSClient                      vcs.Client
+	// Renderer renders the results of drift checks that are reported on an
+	// issue.
+	Renderer *events.MarkdownRenderer
+	// GlobalCfg is the server-side repo config. Requests for commands a
+	// repo's allowed_commands doesn't allow are rejected.
+	GlobalCfg valid.GlobalCfg
 }
 
 type APIRequest struct {
@@ -45,6 +53,31 @@ type APIRequest struct {
 		Directory string
 		Workspace string
 	}
+	// Apply, for drift checks, applies the projects if any of them drifted.
+	Apply bool
+	// ReportIssue, for drift checks, is the number of the issue or pull
+	// request the results are commented on when a project drifted or failed.
+	ReportIssue int
+}
+
+// DriftResponse is the response of a drift check.
+type DriftResponse struct {
+	// Drift is true if the plan of any project has changes.
+	Drift bool
+	// DriftedProjects are the projects whose plans have changes.
+	DriftedProjects []DriftedProject
+	// PlanResult is the result of planning the projects.
+	PlanResult *command.Result
+	// ApplyResult is the result of applying the projects if the request set
+	// Apply and a project drifted.
+	ApplyResult *command.Result `json:",omitempty"`
+}
+
+// DriftedProject identifies a project whose plan has changes.
+type DriftedProject struct {
+	ProjectName string
+	Directory   string
+	Workspace   string
 }
 
 func (a *APIRequest) getCommands(ctx *command.Context, cmdBuilder func(*command.Context, *events.CommentCommand) ([]command.ProjectContext, error)) ([]command.ProjectContext, []*events.CommentCommand, error) {
@@ -89,6 +122,10 @@ func (a *APIController) Plan(w http.ResponseWriter, r *http.Request) {
 		a.apiReportError(w, code, err)
 		return
 	}
+	if err := a.checkCommandsAllowed(ctx, command.Plan); err != nil {
+		a.apiReportError(w, http.StatusForbidden, err)
+		return
+	}
 
 	result, err := a.apiPlan(request, ctx)
 	if err != nil {
@@ -117,6 +154,10 @@ func (a *APIController) Apply(w http.ResponseWriter, r *http.Request) {
 		a.apiReportError(w, code, err)
 		return
 	}
+	if err := a.checkCommandsAllowed(ctx, command.Plan, command.Apply); err != nil {
+		a.apiReportError(w, http.StatusForbidden, err)
+		return
+	}
 
 	// We must first make the plan for all projects
 	_, err = a.apiPlan(request, ctx)
@@ -144,6 +185,92 @@ func (a *APIController) Apply(w http.ResponseWriter, r *http.Request) {
 	a.respond(w, logging.Debug, code, string(response))
 }
 
+// Drift plans the projects of the request to check whether their
+// infrastructure drifted from the configuration in Ref, so it can be called on
+// a schedule. If the request sets Apply the projects are applied when any of
+// them drifted. Since there's no pull request to comment on, the results are
+// commented on ReportIssue, if set, when a project drifted or failed.
+func (a *APIController) Drift(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	request, ctx, code, err := a.apiParseAndValidate(r)
+	if err != nil {
+		a.apiReportError(w, code, err)
+		return
+	}
+	cmdNames := []command.Name{command.Plan}
+	if request.Apply {
+		cmdNames = append(cmdNames, command.Apply)
+	}
+	if err := a.checkCommandsAllowed(ctx, cmdNames...); err != nil {
+		a.apiReportError(w, http.StatusForbidden, err)
+		return
+	}
+
+	planResult, err := a.apiPlan(request, ctx)
+	if err != nil {
+		a.apiReportError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer a.Locker.UnlockByPull(ctx.HeadRepo.FullName, 0) // nolint: errcheck
+
+	response := DriftResponse{
+		DriftedProjects: driftedProjects(planResult),
+		PlanResult:      planResult,
+	}
+	response.Drift = len(response.DriftedProjects) > 0
+	hasErrors := planResult.HasErrors()
+	a.reportDrift(ctx, request, planResult, command.Plan, response.Drift || hasErrors)
+
+	if response.Drift && request.Apply && !hasErrors {
+		response.ApplyResult, err = a.apiApply(request, ctx)
+		if err != nil {
+			a.apiReportError(w, http.StatusInternalServerError, err)
+			return
+		}
+		hasErrors = response.ApplyResult.HasErrors()
+		a.reportDrift(ctx, request, response.ApplyResult, command.Apply, true)
+	}
+	if hasErrors {
+		code = http.StatusInternalServerError
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		a.apiReportError(w, http.StatusInternalServerError, err)
+		return
+	}
+	a.respond(w, logging.Debug, code, string(body))
+}
+
+// driftedProjects returns the projects in result whose plans have changes.
+func driftedProjects(result *command.Result) []DriftedProject {
+	drifted := make([]DriftedProject, 0)
+	for _, res := range result.ProjectResults {
+		if res.PlanSuccess != nil && !res.PlanSuccess.NoChanges() {
+			drifted = append(drifted, DriftedProject{
+				ProjectName: res.ProjectName,
+				Directory:   res.RepoRelDir,
+				Workspace:   res.Workspace,
+			})
+		}
+	}
+	return drifted
+}
+
+// reportDrift comments the rendered result of cmdName on the request's
+// ReportIssue if it's set and report is true. Failing to comment is only
+// logged since the response still has the result.
+func (a *APIController) reportDrift(ctx *command.Context, request *APIRequest, result *command.Result, cmdName command.Name, report bool) {
+	if request.ReportIssue == 0 || !report || a.Renderer == nil {
+		return
+	}
+	comment := a.Renderer.Render(ctx, *result, events.CommentCommand{Name: cmdName})
+	if err := a.VCSClient.CreateComment(ctx.Log, ctx.HeadRepo, request.ReportIssue, comment, cmdName.String()); err != nil {
+		ctx.Log.Err("unable to report drift on #%d: %s", request.ReportIssue, err)
+	}
+}
+
 func (a *APIController) apiPlan(request *APIRequest, ctx *command.Context) (*command.Result, error) {
 	cmds, cc, err := request.getCommands(ctx, a.ProjectCommandBuilder.BuildPlanCommands)
 	if err != nil {
@@ -161,6 +288,9 @@ func (a *APIController) apiPlan(request *APIRequest, ctx *command.Context) (*com
 		}
 
 		res := a.ProjectPlanCommandRunner.Plan(cmd)
+		if cmd.ProjectOutcomes != nil {
+			cmd.ProjectOutcomes.Record(res)
+		}
 		projectResults = append(projectResults, res)
 
 		err = a.PostWorkflowHooksCommandRunner.RunPostHooks(ctx, cc[i])
@@ -188,6 +318,9 @@ func (a *APIController) apiApply(request *APIRequest, ctx *command.Context) (*co
 		}
 
 		res := a.ProjectApplyCommandRunner.Apply(cmd)
+		if cmd.ProjectOutcomes != nil {
+			cmd.ProjectOutcomes.Record(res)
+		}
 		projectResults = append(projectResults, res)
 
 		err = a.PostWorkflowHooksCommandRunner.RunPostHooks(ctx, cc[i])
@@ -198,6 +331,17 @@ func (a *APIController) apiApply(request *APIRequest, ctx *command.Context) (*co
 	return &command.Result{ProjectResults: projectResults}, nil
 }
 
+// checkCommandsAllowed returns an error if the repo's allowed_commands doesn't
+// allow any of cmdNames.
+func (a *APIController) checkCommandsAllowed(ctx *command.Context, cmdNames ...command.Name) error {
+	for _, cmdName := range cmdNames {
+		if !a.GlobalCfg.RepoAllowsCommand(ctx.Pull.BaseRepo.ID(), cmdName.String()) {
+			return fmt.Errorf("%s is disabled for this repo", cmdName)
+		}
+	}
+	return nil
+}
+
 func (a *APIController) apiParseAndValidate(r *http.Request) (*APIRequest, *command.Context, int, error) {
 	if len(a.APISecret) == 0 {
 		return nil, nil, http.StatusBadRequest, fmt.Errorf("ignoring request since API is disabled")
@@ -250,9 +394,11 @@ func (a *APIController) apiParseAndValidate(r *http.Request) (*APIRequest, *comm
 			HeadCommit: request.Ref,
 			BaseRepo:   baseRepo,
 		},
-		Scope: a.Scope,
-		Log:   a.Logger,
-		API:   true,
+		Scope:           a.Scope,
+		Log:             a.Logger,
+		API:             true,
+		RunID:           uuid.NewString(),
+		ProjectOutcomes: command.NewProjectOutcomes(),
 	}, http.StatusOK, nil
 }
 
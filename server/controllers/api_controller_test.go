@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"testing"
 
 	. "github.com/petergtz/pegomock/v4"
 	"github.com/runatlantis/atlantis/server/controllers"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
 	. "github.com/runatlantis/atlantis/server/core/locking/mocks"
 	"github.com/runatlantis/atlantis/server/events"
 	"github.com/runatlantis/atlantis/server/events/command"
@@ -58,6 +60,79 @@ func TestAPIController_Apply(t *testing.T) {
 	projectCommandRunner.VerifyWasCalledOnce().Apply(Any[command.ProjectContext]())
 }
 
+func TestAPIController_ApplyNotAllowed(t *testing.T) {
+	ac, projectCommandBuilder, projectCommandRunner := setup(t)
+	ac.GlobalCfg = valid.GlobalCfg{
+		Repos: []valid.Repo{{IDRegex: regexp.MustCompile(".*"), AllowedCommands: []string{"plan"}}},
+	}
+	body, _ := json.Marshal(controllers.APIRequest{
+		Repository: "Repo",
+		Ref:        "main",
+		Type:       "Gitlab",
+		Projects:   []string{"default"},
+	})
+	req, _ := http.NewRequest("POST", "", bytes.NewBuffer(body))
+	req.Header.Set(atlantisTokenHeader, atlantisToken)
+	w := httptest.NewRecorder()
+	ac.Apply(w, req)
+	ResponseContains(t, w, http.StatusForbidden, "apply is disabled for this repo")
+	projectCommandBuilder.VerifyWasCalled(Never()).BuildPlanCommands(Any[*command.Context](), Any[*events.CommentCommand]())
+	projectCommandRunner.VerifyWasCalled(Never()).Apply(Any[command.ProjectContext]())
+}
+
+func TestAPIController_Drift(t *testing.T) {
+	ac, _, projectCommandRunner := setup(t)
+	When(projectCommandRunner.Plan(Any[command.ProjectContext]())).ThenReturn(command.ProjectResult{
+		RepoRelDir: "dir",
+		Workspace:  "default",
+		PlanSuccess: &models.PlanSuccess{
+			TerraformOutput: "Plan: 1 to add, 0 to change, 0 to destroy.",
+		},
+	})
+	body, _ := json.Marshal(controllers.APIRequest{
+		Repository:  "Repo",
+		Ref:         "main",
+		Type:        "Gitlab",
+		Projects:    []string{"default"},
+		Apply:       true,
+		ReportIssue: 5,
+	})
+	req, _ := http.NewRequest("POST", "", bytes.NewBuffer(body))
+	req.Header.Set(atlantisTokenHeader, atlantisToken)
+	w := httptest.NewRecorder()
+	ac.Drift(w, req)
+	ResponseContains(t, w, http.StatusOK, `"Drift":true,"DriftedProjects":[{"ProjectName":"","Directory":"dir","Workspace":"default"}]`)
+	projectCommandRunner.VerifyWasCalledOnce().Plan(Any[command.ProjectContext]())
+	projectCommandRunner.VerifyWasCalledOnce().Apply(Any[command.ProjectContext]())
+	vcsClient := ac.VCSClient.(*MockClient)
+	vcsClient.VerifyWasCalledOnce().CreateComment(Any[logging.SimpleLogging](), Any[models.Repo](), Eq(5), Any[string](), Eq("plan"))
+	vcsClient.VerifyWasCalledOnce().CreateComment(Any[logging.SimpleLogging](), Any[models.Repo](), Eq(5), Any[string](), Eq("apply"))
+}
+
+func TestAPIController_DriftNoChanges(t *testing.T) {
+	ac, _, projectCommandRunner := setup(t)
+	When(projectCommandRunner.Plan(Any[command.ProjectContext]())).ThenReturn(command.ProjectResult{
+		PlanSuccess: &models.PlanSuccess{
+			TerraformOutput: "No changes. Your infrastructure matches the configuration.",
+		},
+	})
+	body, _ := json.Marshal(controllers.APIRequest{
+		Repository:  "Repo",
+		Ref:         "main",
+		Type:        "Gitlab",
+		Projects:    []string{"default"},
+		Apply:       true,
+		ReportIssue: 5,
+	})
+	req, _ := http.NewRequest("POST", "", bytes.NewBuffer(body))
+	req.Header.Set(atlantisTokenHeader, atlantisToken)
+	w := httptest.NewRecorder()
+	ac.Drift(w, req)
+	ResponseContains(t, w, http.StatusOK, `"Drift":false,"DriftedProjects":[]`)
+	projectCommandRunner.VerifyWasCalled(Never()).Apply(Any[command.ProjectContext]())
+	ac.VCSClient.(*MockClient).VerifyWasCalled(Never()).CreateComment(Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]())
+}
+
 func setup(t *testing.T) (controllers.APIController, *MockProjectCommandBuilder, *MockProjectCommandRunner) {
 	RegisterMockTestingT(t)
 	locker := NewMockLocker()
@@ -107,6 +182,7 @@ func setup(t *testing.T) (controllers.APIController, *MockProjectCommandBuilder,
 		PostWorkflowHooksCommandRunner: postWorkflowHooksCommandRunner,
 		VCSClient:                      vcsClient,
 		RepoAllowlistChecker:           repoAllowlistChecker,
+		Renderer:                       events.NewMarkdownRenderer(false, false, false, false, false, false, "", "atlantis", false),
 	}
 	return ac, projectCommandBuilder, projectCommandRunner
 }
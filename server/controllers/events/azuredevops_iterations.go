@@ -0,0 +1,47 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// AzureDevopsIterations tracks the source commit of the latest iteration of
+// each Azure DevOps pull request. Azure DevOps sends a git.pullrequest.updated
+// event for every change to a pull request, ex. its title or reviewers, and
+// can send several for the same push, so only updates that push a new
+// iteration are autoplanned.
+type AzureDevopsIterations struct {
+	mu      sync.Mutex
+	commits map[string]string
+}
+
+// NewAzureDevopsIterations returns an empty AzureDevopsIterations.
+func NewAzureDevopsIterations() *AzureDevopsIterations {
+	return &AzureDevopsIterations{commits: make(map[string]string)}
+}
+
+// IsNew records pull's head commit as its latest iteration and returns true
+// if it's different from the previous one.
+func (a *AzureDevopsIterations) IsNew(pull models.PullRequest) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := a.key(pull)
+	if a.commits[key] == pull.HeadCommit {
+		return false
+	}
+	a.commits[key] = pull.HeadCommit
+	return true
+}
+
+// Forget removes the iterations of pull, ex. once it's closed.
+func (a *AzureDevopsIterations) Forget(pull models.PullRequest) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.commits, a.key(pull))
+}
+
+func (a *AzureDevopsIterations) key(pull models.PullRequest) string {
+	return fmt.Sprintf("%s#%d", pull.BaseRepo.FullName, pull.Num)
+}
@@ -21,6 +21,7 @@ import (
 	"strings"
 
 	"github.com/google/go-github/v59/github"
+	"github.com/google/uuid"
 	"github.com/mcdafydd/go-azuredevops/azuredevops"
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/pkg/errors"
@@ -31,6 +32,7 @@ import (
 	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketserver"
 	"github.com/runatlantis/atlantis/server/events/vcs/gitea"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/tracing"
 	tally "github.com/uber-go/tally/v4"
 	gitlab "github.com/xanzy/go-gitlab"
 )
@@ -50,6 +52,10 @@ const bitbucketCloudRequestIDHeader = "X-Request-UUID"
 const bitbucketServerRequestIDHeader = "X-Request-ID"
 const bitbucketServerSignatureHeader = "X-Hub-Signature"
 
+// traceparentHeader is the W3C trace context header of webhooks sent by
+// systems that trace them.
+const traceparentHeader = "traceparent"
+
 // The URL used for Azure DevOps test webhooks
 const azuredevopsTestURL = "https://fabrikam.visualstudio.com/DefaultCollection/_apis/git/repositories/4bc14d40-c903-45e2-872e-0462c7748079"
 
@@ -98,7 +104,18 @@ type VCSEventsController struct {
 	// Azure DevOps Team Project. If empty, no request validation is done.
 	AzureDevopsWebhookBasicPassword []byte
 	AzureDevopsRequestValidator     AzureDevopsRequestValidator
-	GiteaWebhookSecret              []byte
+	// AzureDevopsIterations, if set, is used to only autoplan Azure DevOps
+	// pull request updates that push a new iteration.
+	AzureDevopsIterations *AzureDevopsIterations
+	GiteaWebhookSecret    []byte
+	// RunIDHeader is the request header whose value, if set, is used as the
+	// ID of the run the webhook starts so it can be correlated with the
+	// system that sent it. If empty, or the header isn't set, a new ID is
+	// generated.
+	RunIDHeader string
+	// Tracer, if set, traces the runs webhooks start, continuing the trace
+	// of webhooks with a W3C traceparent header.
+	Tracer *tracing.Tracer
 }
 
 // Post handles POST webhook requests.
@@ -190,11 +207,11 @@ func (e *VCSEventsController) handleGithubPost(w http.ResponseWriter, r *http.Re
 
 	switch event := event.(type) {
 	case *github.IssueCommentEvent:
-		resp = e.HandleGithubCommentEvent(event, githubReqID, logger)
+		resp = e.HandleGithubCommentEvent(event, githubReqID, logger, e.incomingRunID(r))
 		scope = scope.SubScope(fmt.Sprintf("comment_%s", *event.Action))
 		scope = vcs.SetGitScopeTags(scope, event.GetRepo().GetFullName(), event.GetIssue().GetNumber())
 	case *github.PullRequestEvent:
-		resp = e.HandleGithubPullRequestEvent(logger, event, githubReqID)
+		resp = e.HandleGithubPullRequestEvent(logger, event, githubReqID, e.incomingRunID(r))
 		scope = scope.SubScope(fmt.Sprintf("pr_%s", *event.Action))
 		scope = vcs.SetGitScopeTags(scope, event.GetRepo().GetFullName(), event.GetNumber())
 	default:
@@ -230,11 +247,11 @@ func (e *VCSEventsController) handleBitbucketCloudPost(w http.ResponseWriter, r
 	switch eventType {
 	case bitbucketcloud.PullCreatedHeader, bitbucketcloud.PullUpdatedHeader, bitbucketcloud.PullFulfilledHeader, bitbucketcloud.PullRejectedHeader:
 		e.Logger.Debug("handling as pull request state changed event")
-		e.handleBitbucketCloudPullRequestEvent(w, eventType, body, reqID)
+		e.handleBitbucketCloudPullRequestEvent(w, eventType, body, reqID, e.incomingRunID(r))
 		return
 	case bitbucketcloud.PullCommentCreatedHeader:
 		e.Logger.Debug("handling as comment created event")
-		e.HandleBitbucketCloudCommentEvent(w, body, reqID)
+		e.HandleBitbucketCloudCommentEvent(w, body, reqID, e.incomingRunID(r))
 		return
 	default:
 		e.respond(w, logging.Debug, http.StatusOK, "Ignoring unsupported event type %s %s=%s", eventType, bitbucketCloudRequestIDHeader, reqID)
@@ -266,11 +283,11 @@ func (e *VCSEventsController) handleBitbucketServerPost(w http.ResponseWriter, r
 	switch eventType {
 	case bitbucketserver.PullCreatedHeader, bitbucketserver.PullFromRefUpdatedHeader, bitbucketserver.PullMergedHeader, bitbucketserver.PullDeclinedHeader, bitbucketserver.PullDeletedHeader:
 		e.Logger.Debug("handling as pull request state changed event")
-		e.handleBitbucketServerPullRequestEvent(w, eventType, body, reqID)
+		e.handleBitbucketServerPullRequestEvent(w, eventType, body, reqID, e.incomingRunID(r))
 		return
 	case bitbucketserver.PullCommentCreatedHeader:
 		e.Logger.Debug("handling as comment created event")
-		e.HandleBitbucketServerCommentEvent(w, body, reqID)
+		e.HandleBitbucketServerCommentEvent(w, body, reqID, e.incomingRunID(r))
 		return
 	default:
 		e.respond(w, logging.Debug, http.StatusOK, "Ignoring unsupported event type %s %s=%s", eventType, bitbucketServerRequestIDHeader, reqID)
@@ -295,10 +312,10 @@ func (e *VCSEventsController) handleAzureDevopsPost(w http.ResponseWriter, r *ht
 	switch event.PayloadType {
 	case azuredevops.PullRequestCommentedEvent:
 		e.Logger.Debug("handling as pull request commented event")
-		e.HandleAzureDevopsPullRequestCommentedEvent(w, event, azuredevopsReqID)
+		e.HandleAzureDevopsPullRequestCommentedEvent(w, event, azuredevopsReqID, e.incomingRunID(r))
 	case azuredevops.PullRequestEvent:
 		e.Logger.Debug("handling as pull request event")
-		e.HandleAzureDevopsPullRequestEvent(w, event, azuredevopsReqID)
+		e.HandleAzureDevopsPullRequestEvent(w, event, azuredevopsReqID, e.incomingRunID(r))
 	default:
 		e.respond(w, logging.Debug, http.StatusOK, "Ignoring unsupported event: %v %s", event.PayloadType, azuredevopsReqID)
 	}
@@ -330,17 +347,17 @@ func (e *VCSEventsController) handleGiteaPost(w http.ResponseWriter, r *http.Req
 	// Depending on the event type, handle the event appropriately
 	switch eventType {
 	case "pull_request_comment":
-		e.HandleGiteaPullRequestCommentEvent(w, body, reqID)
+		e.HandleGiteaPullRequestCommentEvent(w, body, reqID, e.incomingRunID(r))
 	case "pull_request":
 		e.Logger.Debug("Handling as pull_request")
-		e.handleGiteaPullRequestEvent(w, body, reqID)
+		e.handleGiteaPullRequestEvent(w, body, reqID, e.incomingRunID(r))
 	// Add other case handlers as necessary
 	default:
 		e.respond(w, logging.Debug, http.StatusOK, "Ignoring unsupported Gitea event type: %s %s=%s", eventType, "X-Gitea-Delivery", reqID)
 	}
 }
 
-func (e *VCSEventsController) handleGiteaPullRequestEvent(w http.ResponseWriter, body []byte, reqID string) {
+func (e *VCSEventsController) handleGiteaPullRequestEvent(w http.ResponseWriter, body []byte, reqID string, runID string) {
 	e.Logger.Debug("Entering handleGiteaPullRequestEvent")
 	// Attempt to unmarshal the incoming body into the Gitea PullRequest struct
 	var payload gitea.GiteaWebhookPayload
@@ -366,13 +383,13 @@ func (e *VCSEventsController) handleGiteaPullRequestEvent(w http.ResponseWriter,
 	logger.Debug("Identified Gitea event as type", "type", pullEventType)
 
 	// Call a generic handler for pull request events
-	response := e.handlePullRequestEvent(logger, baseRepo, headRepo, pull, user, pullEventType)
+	response := e.handlePullRequestEvent(logger, baseRepo, headRepo, pull, user, pullEventType, runID)
 
 	e.respond(w, logging.Debug, http.StatusOK, response.body)
 }
 
 // HandleGiteaPullRequestCommentEvent handles comment events from Gitea where Atlantis commands can come from.
-func (e *VCSEventsController) HandleGiteaPullRequestCommentEvent(w http.ResponseWriter, body []byte, reqID string) {
+func (e *VCSEventsController) HandleGiteaPullRequestCommentEvent(w http.ResponseWriter, body []byte, reqID string, runID string) {
 	var event gitea.GiteaIssueCommentPayload
 	if err := json.Unmarshal(body, &event); err != nil {
 		e.Logger.Err("Failed to unmarshal Gitea comment payload: %v", err)
@@ -384,14 +401,14 @@ func (e *VCSEventsController) HandleGiteaPullRequestCommentEvent(w http.Response
 	baseRepo, user, pullNum, _ := e.Parser.ParseGiteaIssueCommentEvent(event)
 	// Since we're lacking headRepo and maybePull details, we'll pass nil
 	// This follows the same approach as the GitHub client for handling comment events without full PR details
-	response := e.handleCommentEvent(e.Logger, baseRepo, nil, nil, user, pullNum, event.Comment.Body, event.Comment.ID, models.Gitea)
+	response := e.handleCommentEvent(e.Logger, baseRepo, nil, nil, user, pullNum, event.Comment.Body, event.Comment.ID, models.Gitea, runID)
 
 	e.respond(w, logging.Debug, http.StatusOK, response.body)
 }
 
 // HandleGithubCommentEvent handles comment events from GitHub where Atlantis
 // commands can come from. It's exported to make testing easier.
-func (e *VCSEventsController) HandleGithubCommentEvent(event *github.IssueCommentEvent, githubReqID string, logger logging.SimpleLogging) HTTPResponse {
+func (e *VCSEventsController) HandleGithubCommentEvent(event *github.IssueCommentEvent, githubReqID string, logger logging.SimpleLogging, runID string) HTTPResponse {
 	if event.GetAction() != "created" {
 		return HTTPResponse{
 			body: fmt.Sprintf("Ignoring comment event since action was not created %s", githubReqID),
@@ -416,17 +433,17 @@ func (e *VCSEventsController) HandleGithubCommentEvent(event *github.IssueCommen
 
 	// We pass in nil for maybeHeadRepo because the head repo data isn't
 	// available in the GithubIssueComment event.
-	return e.handleCommentEvent(logger, baseRepo, nil, nil, user, pullNum, comment.GetBody(), comment.GetID(), models.Github)
+	return e.handleCommentEvent(logger, baseRepo, nil, nil, user, pullNum, comment.GetBody(), comment.GetID(), models.Github, runID)
 }
 
 // HandleBitbucketCloudCommentEvent handles comment events from Bitbucket.
-func (e *VCSEventsController) HandleBitbucketCloudCommentEvent(w http.ResponseWriter, body []byte, reqID string) {
+func (e *VCSEventsController) HandleBitbucketCloudCommentEvent(w http.ResponseWriter, body []byte, reqID string, runID string) {
 	pull, baseRepo, headRepo, user, comment, err := e.Parser.ParseBitbucketCloudPullCommentEvent(body)
 	if err != nil {
 		e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing pull data: %s %s=%s", err, bitbucketCloudRequestIDHeader, reqID)
 		return
 	}
-	resp := e.handleCommentEvent(e.Logger, baseRepo, &headRepo, &pull, user, pull.Num, comment, -1, models.BitbucketCloud)
+	resp := e.handleCommentEvent(e.Logger, baseRepo, &headRepo, &pull, user, pull.Num, comment, -1, models.BitbucketCloud, runID)
 
 	//TODO: move this to the outer most function similar to github
 	lvl := logging.Debug
@@ -441,13 +458,13 @@ func (e *VCSEventsController) HandleBitbucketCloudCommentEvent(w http.ResponseWr
 }
 
 // HandleBitbucketServerCommentEvent handles comment events from Bitbucket.
-func (e *VCSEventsController) HandleBitbucketServerCommentEvent(w http.ResponseWriter, body []byte, reqID string) {
+func (e *VCSEventsController) HandleBitbucketServerCommentEvent(w http.ResponseWriter, body []byte, reqID string, runID string) {
 	pull, baseRepo, headRepo, user, comment, err := e.Parser.ParseBitbucketServerPullCommentEvent(body)
 	if err != nil {
 		e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing pull data: %s %s=%s", err, bitbucketCloudRequestIDHeader, reqID)
 		return
 	}
-	resp := e.handleCommentEvent(e.Logger, baseRepo, &headRepo, &pull, user, pull.Num, comment, -1, models.BitbucketCloud)
+	resp := e.handleCommentEvent(e.Logger, baseRepo, &headRepo, &pull, user, pull.Num, comment, -1, models.BitbucketCloud, runID)
 
 	//TODO: move this to the outer most function similar to github
 	lvl := logging.Debug
@@ -461,7 +478,7 @@ func (e *VCSEventsController) HandleBitbucketServerCommentEvent(w http.ResponseW
 	e.respond(w, lvl, code, msg)
 }
 
-func (e *VCSEventsController) handleBitbucketCloudPullRequestEvent(w http.ResponseWriter, eventType string, body []byte, reqID string) {
+func (e *VCSEventsController) handleBitbucketCloudPullRequestEvent(w http.ResponseWriter, eventType string, body []byte, reqID string, runID string) {
 	pull, baseRepo, headRepo, user, err := e.Parser.ParseBitbucketCloudPullEvent(body)
 	if err != nil {
 		e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing pull data: %s %s=%s", err, bitbucketCloudRequestIDHeader, reqID)
@@ -470,7 +487,7 @@ func (e *VCSEventsController) handleBitbucketCloudPullRequestEvent(w http.Respon
 	e.Logger.Debug("SHA is %q", pull.HeadCommit)
 	pullEventType := e.Parser.GetBitbucketCloudPullEventType(eventType, pull.HeadCommit, pull.URL)
 	e.Logger.Info("identified event as type %q", pullEventType.String())
-	resp := e.handlePullRequestEvent(e.Logger, baseRepo, headRepo, pull, user, pullEventType)
+	resp := e.handlePullRequestEvent(e.Logger, baseRepo, headRepo, pull, user, pullEventType, runID)
 
 	//TODO: move this to the outer most function similar to github
 	lvl := logging.Debug
@@ -484,7 +501,7 @@ func (e *VCSEventsController) handleBitbucketCloudPullRequestEvent(w http.Respon
 	e.respond(w, lvl, code, msg)
 }
 
-func (e *VCSEventsController) handleBitbucketServerPullRequestEvent(w http.ResponseWriter, eventType string, body []byte, reqID string) {
+func (e *VCSEventsController) handleBitbucketServerPullRequestEvent(w http.ResponseWriter, eventType string, body []byte, reqID string, runID string) {
 	pull, baseRepo, headRepo, user, err := e.Parser.ParseBitbucketServerPullEvent(body)
 	if err != nil {
 		e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing pull data: %s %s=%s", err, bitbucketServerRequestIDHeader, reqID)
@@ -492,7 +509,7 @@ func (e *VCSEventsController) handleBitbucketServerPullRequestEvent(w http.Respo
 	}
 	pullEventType := e.Parser.GetBitbucketServerPullEventType(eventType)
 	e.Logger.Info("identified event as type %q", pullEventType.String())
-	resp := e.handlePullRequestEvent(e.Logger, baseRepo, headRepo, pull, user, pullEventType)
+	resp := e.handlePullRequestEvent(e.Logger, baseRepo, headRepo, pull, user, pullEventType, runID)
 
 	//TODO: move this to the outer most function similar to github
 	lvl := logging.Debug
@@ -509,7 +526,7 @@ func (e *VCSEventsController) handleBitbucketServerPullRequestEvent(w http.Respo
 // HandleGithubPullRequestEvent will delete any locks associated with the pull
 // request if the event is a pull request closed event. It's exported to make
 // testing easier.
-func (e *VCSEventsController) HandleGithubPullRequestEvent(logger logging.SimpleLogging, pullEvent *github.PullRequestEvent, githubReqID string) HTTPResponse {
+func (e *VCSEventsController) HandleGithubPullRequestEvent(logger logging.SimpleLogging, pullEvent *github.PullRequestEvent, githubReqID string, runID string) HTTPResponse {
 	pull, pullEventType, baseRepo, headRepo, user, err := e.Parser.ParseGithubPullEvent(logger, pullEvent)
 	if err != nil {
 		wrapped := errors.Wrapf(err, "Error parsing pull data: %s %s", err, githubReqID)
@@ -523,10 +540,10 @@ func (e *VCSEventsController) HandleGithubPullRequestEvent(logger logging.Simple
 		}
 	}
 	logger.Debug("identified event as type %q", pullEventType.String())
-	return e.handlePullRequestEvent(logger, baseRepo, headRepo, pull, user, pullEventType)
+	return e.handlePullRequestEvent(logger, baseRepo, headRepo, pull, user, pullEventType, runID)
 }
 
-func (e *VCSEventsController) handlePullRequestEvent(logger logging.SimpleLogging, baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User, eventType models.PullRequestEventType) HTTPResponse {
+func (e *VCSEventsController) handlePullRequestEvent(logger logging.SimpleLogging, baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User, eventType models.PullRequestEventType, runID string) HTTPResponse {
 	if !e.RepoAllowlistChecker.IsAllowlisted(baseRepo.FullName, baseRepo.VCSHost.Hostname) {
 		// If the repo isn't allowlisted and we receive an opened pull request
 		// event we comment back on the pull request that the repo isn't
@@ -556,10 +573,10 @@ func (e *VCSEventsController) handlePullRequestEvent(logger logging.SimpleLoggin
 		// We use a goroutine so that this function returns and the connection is
 		// closed.
 		if !e.TestingMode {
-			go e.CommandRunner.RunAutoplanCommand(baseRepo, headRepo, pull, user)
+			go e.CommandRunner.RunAutoplanCommand(baseRepo, headRepo, pull, user, runID)
 		} else {
 			// When testing we want to wait for everything to complete.
-			e.CommandRunner.RunAutoplanCommand(baseRepo, headRepo, pull, user)
+			e.CommandRunner.RunAutoplanCommand(baseRepo, headRepo, pull, user, runID)
 		}
 		return HTTPResponse{
 			body: "Processing...",
@@ -600,10 +617,10 @@ func (e *VCSEventsController) handleGitlabPost(w http.ResponseWriter, r *http.Re
 	switch event := event.(type) {
 	case gitlab.MergeCommentEvent:
 		e.Logger.Debug("handling as comment event")
-		e.HandleGitlabCommentEvent(w, event)
+		e.HandleGitlabCommentEvent(w, event, e.incomingRunID(r))
 	case gitlab.MergeEvent:
 		e.Logger.Debug("handling as pull request event")
-		e.HandleGitlabMergeRequestEvent(w, event)
+		e.HandleGitlabMergeRequestEvent(w, event, e.incomingRunID(r))
 	case gitlab.CommitCommentEvent:
 		e.Logger.Debug("comments on commits are not supported, only comments on merge requests")
 		e.respond(w, logging.Debug, http.StatusOK, "Ignoring comment on commit event")
@@ -615,14 +632,14 @@ func (e *VCSEventsController) handleGitlabPost(w http.ResponseWriter, r *http.Re
 
 // HandleGitlabCommentEvent handles comment events from GitLab where Atlantis
 // commands can come from. It's exported to make testing easier.
-func (e *VCSEventsController) HandleGitlabCommentEvent(w http.ResponseWriter, event gitlab.MergeCommentEvent) {
+func (e *VCSEventsController) HandleGitlabCommentEvent(w http.ResponseWriter, event gitlab.MergeCommentEvent, runID string) {
 	// todo: can gitlab return the pull request here too?
 	baseRepo, headRepo, commentID, user, err := e.Parser.ParseGitlabMergeRequestCommentEvent(event)
 	if err != nil {
 		e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing webhook: %s", err)
 		return
 	}
-	resp := e.handleCommentEvent(e.Logger, baseRepo, &headRepo, nil, user, event.MergeRequest.IID, event.ObjectAttributes.Note, int64(commentID), models.Gitlab)
+	resp := e.handleCommentEvent(e.Logger, baseRepo, &headRepo, nil, user, event.MergeRequest.IID, event.ObjectAttributes.Note, int64(commentID), models.Gitlab, runID)
 
 	//TODO: move this to the outer most function similar to github
 	lvl := logging.Debug
@@ -636,7 +653,7 @@ func (e *VCSEventsController) HandleGitlabCommentEvent(w http.ResponseWriter, ev
 	e.respond(w, lvl, code, msg)
 }
 
-func (e *VCSEventsController) handleCommentEvent(logger logging.SimpleLogging, baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, comment string, commentID int64, vcsHost models.VCSHostType) HTTPResponse {
+func (e *VCSEventsController) handleCommentEvent(logger logging.SimpleLogging, baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, comment string, commentID int64, vcsHost models.VCSHostType, runID string) HTTPResponse {
 	logger = logger.WithHistory(
 		"repo", baseRepo.FullName,
 		"pull", pullNum,
@@ -703,10 +720,10 @@ func (e *VCSEventsController) handleCommentEvent(logger logging.SimpleLogging, b
 		// Respond with success and then actually execute the command asynchronously.
 		// We use a goroutine so that this function returns and the connection is
 		// closed.
-		go e.CommandRunner.RunCommentCommand(baseRepo, maybeHeadRepo, maybePull, user, pullNum, parseResult.Command)
+		go e.CommandRunner.RunCommentCommand(baseRepo, maybeHeadRepo, maybePull, user, pullNum, parseResult.Command, runID)
 	} else {
 		// When testing we want to wait for everything to complete.
-		e.CommandRunner.RunCommentCommand(baseRepo, maybeHeadRepo, maybePull, user, pullNum, parseResult.Command)
+		e.CommandRunner.RunCommentCommand(baseRepo, maybeHeadRepo, maybePull, user, pullNum, parseResult.Command, runID)
 	}
 
 	return HTTPResponse{
@@ -717,14 +734,14 @@ func (e *VCSEventsController) handleCommentEvent(logger logging.SimpleLogging, b
 // HandleGitlabMergeRequestEvent will delete any locks associated with the pull
 // request if the event is a merge request closed event. It's exported to make
 // testing easier.
-func (e *VCSEventsController) HandleGitlabMergeRequestEvent(w http.ResponseWriter, event gitlab.MergeEvent) {
+func (e *VCSEventsController) HandleGitlabMergeRequestEvent(w http.ResponseWriter, event gitlab.MergeEvent, runID string) {
 	pull, pullEventType, baseRepo, headRepo, user, err := e.Parser.ParseGitlabMergeRequestEvent(event)
 	if err != nil {
 		e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing webhook: %s", err)
 		return
 	}
 	e.Logger.Info("identified event as type %q", pullEventType.String())
-	resp := e.handlePullRequestEvent(e.Logger, baseRepo, headRepo, pull, user, pullEventType)
+	resp := e.handlePullRequestEvent(e.Logger, baseRepo, headRepo, pull, user, pullEventType, runID)
 
 	//TODO: move this to the outer most function similar to github
 	lvl := logging.Debug
@@ -742,7 +759,7 @@ func (e *VCSEventsController) HandleGitlabMergeRequestEvent(w http.ResponseWrite
 // commands can come from. It's exported to make testing easier.
 // Sometimes we may want data from the parent azuredevops.Event struct, so we handle type checking here.
 // Requires Resource Version 2.0 of the Pull Request Commented On webhook payload.
-func (e *VCSEventsController) HandleAzureDevopsPullRequestCommentedEvent(w http.ResponseWriter, event *azuredevops.Event, azuredevopsReqID string) {
+func (e *VCSEventsController) HandleAzureDevopsPullRequestCommentedEvent(w http.ResponseWriter, event *azuredevops.Event, azuredevopsReqID string, runID string) {
 	resource, ok := event.Resource.(*azuredevops.GitPullRequestWithComment)
 	if !ok || event.PayloadType != azuredevops.PullRequestCommentedEvent {
 		e.respond(w, logging.Error, http.StatusBadRequest, "Event.Resource is nil or received bad event type %v; %s", event.Resource, azuredevopsReqID)
@@ -778,7 +795,7 @@ func (e *VCSEventsController) HandleAzureDevopsPullRequestCommentedEvent(w http.
 		e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing pull request repository field: %s; %s", err, azuredevopsReqID)
 		return
 	}
-	resp := e.handleCommentEvent(e.Logger, baseRepo, nil, nil, user, resource.PullRequest.GetPullRequestID(), string(strippedComment), -1, models.AzureDevops)
+	resp := e.handleCommentEvent(e.Logger, baseRepo, nil, nil, user, resource.PullRequest.GetPullRequestID(), string(strippedComment), -1, models.AzureDevops, runID)
 
 	//TODO: move this to the outer most function similar to github
 	lvl := logging.Debug
@@ -795,7 +812,7 @@ func (e *VCSEventsController) HandleAzureDevopsPullRequestCommentedEvent(w http.
 // HandleAzureDevopsPullRequestEvent will delete any locks associated with the pull
 // request if the event is a pull request closed event. It's exported to make
 // testing easier.
-func (e *VCSEventsController) HandleAzureDevopsPullRequestEvent(w http.ResponseWriter, event *azuredevops.Event, azuredevopsReqID string) {
+func (e *VCSEventsController) HandleAzureDevopsPullRequestEvent(w http.ResponseWriter, event *azuredevops.Event, azuredevopsReqID string, runID string) {
 	prText := event.Message.GetText()
 	ignoreEvents := []string{
 		"changed the reviewer list",
@@ -829,7 +846,18 @@ func (e *VCSEventsController) HandleAzureDevopsPullRequestEvent(w http.ResponseW
 		return
 	}
 	e.Logger.Info("identified event as type %q", pullEventType.String())
-	resp := e.handlePullRequestEvent(e.Logger, baseRepo, headRepo, pull, user, pullEventType)
+	if e.AzureDevopsIterations != nil {
+		switch pullEventType {
+		case models.OpenedPullEvent, models.UpdatedPullEvent:
+			if !e.AzureDevopsIterations.IsNew(pull) && pullEventType == models.UpdatedPullEvent {
+				e.respond(w, logging.Debug, http.StatusOK, "Ignoring pull request update that isn't a new iteration, commit %s was already autoplanned %s", pull.HeadCommit, azuredevopsReqID)
+				return
+			}
+		case models.ClosedPullEvent:
+			e.AzureDevopsIterations.Forget(pull)
+		}
+	}
+	resp := e.handlePullRequestEvent(e.Logger, baseRepo, headRepo, pull, user, pullEventType, runID)
 
 	//TODO: move this to the outer most function similar to github
 	lvl := logging.Debug
@@ -843,6 +871,30 @@ func (e *VCSEventsController) HandleAzureDevopsPullRequestEvent(w http.ResponseW
 	e.respond(w, lvl, code, msg)
 }
 
+// incomingRunID returns the run ID passed in r's RunIDHeader, if any and
+// valid. If r has a traceparent header, the trace of the run continues it,
+// its ID being generated if it wasn't passed so the trace context can be
+// found by it.
+func (e *VCSEventsController) incomingRunID(r *http.Request) string {
+	var runID string
+	if e.RunIDHeader != "" {
+		runID = r.Header.Get(e.RunIDHeader)
+		if runID != "" && !events.ValidRunID(runID) {
+			e.Logger.Debug("ignoring invalid %s header %q", e.RunIDHeader, runID)
+			runID = ""
+		}
+	}
+	if traceparent := r.Header.Get(traceparentHeader); e.Tracer != nil && traceparent != "" {
+		if runID == "" {
+			runID = uuid.NewString()
+		}
+		if !e.Tracer.SetRemoteParent(runID, traceparent) {
+			e.Logger.Debug("ignoring invalid %s header %q", traceparentHeader, traceparent)
+		}
+	}
+	return runID
+}
+
 // supportsHost returns true if h is in e.SupportedVCSHosts and false otherwise.
 func (e *VCSEventsController) supportsHost(h models.VCSHostType) bool {
 	for _, supported := range e.SupportedVCSHosts {
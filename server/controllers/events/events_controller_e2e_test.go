@@ -1193,7 +1193,7 @@ func TestGitHubWorkflowWithPolicyCheck(t *testing.T) {
 			// Setup test dependencies.
 			w := httptest.NewRecorder()
 			When(vcsClient.PullIsMergeable(
-				Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](), Eq("atlantis-test"))).ThenReturn(true, nil)
+				Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](), Eq("atlantis-test/apply"))).ThenReturn(true, nil)
 			When(vcsClient.PullIsApproved(
 				Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest]())).ThenReturn(models.ApprovalStatus{
 				IsApproved: true,
@@ -1457,6 +1457,7 @@ func setupE2E(t *testing.T, repoDir string, opt setupOption) (events_controllers
 			defaultTFVersion,
 			statusUpdater,
 			asyncTfExec,
+			nil,
 		),
 		ShowStepRunner:        showStepRunner,
 		PolicyCheckStepRunner: policyCheckRunner,
@@ -1503,7 +1504,7 @@ func setupE2E(t *testing.T, repoDir string, opt setupOption) (events_controllers
 		userConfig.QuietPolicyChecks,
 	)
 
-	e2ePullReqStatusFetcher := vcs.NewPullReqStatusFetcher(e2eVCSClient, "atlantis-test")
+	e2ePullReqStatusFetcher := vcs.NewPullReqStatusFetcher(e2eVCSClient, "atlantis-test/apply")
 
 	planCommandRunner := events.NewPlanCommandRunner(
 		false,
@@ -1524,6 +1525,7 @@ func setupE2E(t *testing.T, repoDir string, opt setupOption) (events_controllers
 		lockingClient,
 		discardApprovalOnPlan,
 		e2ePullReqStatusFetcher,
+		false,
 	)
 
 	applyCommandRunner := events.NewApplyCommandRunner(
@@ -1559,6 +1561,7 @@ func setupE2E(t *testing.T, repoDir string, opt setupOption) (events_controllers
 		e2eVCSClient,
 		silenceNoProjects,
 		disableUnlockLabel,
+		globalCfg.PolicySets.Owners,
 	)
 
 	versionCommandRunner := events.NewVersionCommandRunner(
@@ -1775,6 +1778,10 @@ func assertCommentEquals(t *testing.T, expReplies []string, act string, repoDir
 	resourceRegex := regexp.MustCompile(`null_resource\.simple(\[\d])?\d?:.*`)
 	act = resourceRegex.ReplaceAllString(act, "null_resource.simple:")
 
+	// Remove the run ID footer since the ID is different every run.
+	runIDRegex := regexp.MustCompile("\n*<sub>Run ID: `[^`]*`</sub>\n?")
+	act = runIDRegex.ReplaceAllString(act, "")
+
 	// For parallel plans and applies, do a substring match since output may be out of order
 	var replyMatchesExpected func(string, string) bool
 	if parallel {
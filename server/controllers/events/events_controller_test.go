@@ -395,7 +395,7 @@ func TestPost_GitlabCommentSuccess(t *testing.T) {
 	e.Post(w, req)
 	ResponseContains(t, w, http.StatusOK, "Processing...")
 
-	cr.VerifyWasCalledOnce().RunCommentCommand(models.Repo{}, &models.Repo{}, nil, models.User{}, 0, &cmd)
+	cr.VerifyWasCalledOnce().RunCommentCommand(models.Repo{}, &models.Repo{}, nil, models.User{}, 0, &cmd, "")
 }
 
 func TestPost_GithubCommentSuccess(t *testing.T) {
@@ -414,7 +414,49 @@ func TestPost_GithubCommentSuccess(t *testing.T) {
 	e.Post(w, req)
 	ResponseContains(t, w, http.StatusOK, "Processing...")
 
-	cr.VerifyWasCalledOnce().RunCommentCommand(baseRepo, nil, nil, user, 1, &cmd)
+	cr.VerifyWasCalledOnce().RunCommentCommand(baseRepo, nil, nil, user, 1, &cmd, "")
+}
+
+func TestPost_GithubCommentRunIDHeader(t *testing.T) {
+	t.Log("when a run ID header is configured its value is passed to the command handler")
+	e, v, _, _, p, cr, _, _, cp := setup(t)
+	e.RunIDHeader = "X-Request-Id"
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(githubHeader, "issue_comment")
+	req.Header.Set("X-Request-Id", "8c1b2d9e")
+	event := `{"action": "created"}`
+	When(v.Validate(req, secret)).ThenReturn([]byte(event), nil)
+	baseRepo := models.Repo{}
+	user := models.User{}
+	cmd := events.CommentCommand{}
+	When(p.ParseGithubIssueCommentEvent(Any[logging.SimpleLogging](), Any[*github.IssueCommentEvent]())).ThenReturn(baseRepo, user, 1, nil)
+	When(cp.Parse("", models.Github)).ThenReturn(events.CommentParseResult{Command: &cmd})
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	ResponseContains(t, w, http.StatusOK, "Processing...")
+
+	cr.VerifyWasCalledOnce().RunCommentCommand(baseRepo, nil, nil, user, 1, &cmd, "8c1b2d9e")
+}
+
+func TestPost_GithubCommentInvalidRunIDHeader(t *testing.T) {
+	t.Log("when the run ID header's value isn't a valid run ID it's ignored")
+	e, v, _, _, p, cr, _, _, cp := setup(t)
+	e.RunIDHeader = "X-Request-Id"
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(githubHeader, "issue_comment")
+	req.Header.Set("X-Request-Id", "`id`")
+	event := `{"action": "created"}`
+	When(v.Validate(req, secret)).ThenReturn([]byte(event), nil)
+	baseRepo := models.Repo{}
+	user := models.User{}
+	cmd := events.CommentCommand{}
+	When(p.ParseGithubIssueCommentEvent(Any[logging.SimpleLogging](), Any[*github.IssueCommentEvent]())).ThenReturn(baseRepo, user, 1, nil)
+	When(cp.Parse("", models.Github)).ThenReturn(events.CommentParseResult{Command: &cmd})
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	ResponseContains(t, w, http.StatusOK, "Processing...")
+
+	cr.VerifyWasCalledOnce().RunCommentCommand(baseRepo, nil, nil, user, 1, &cmd, "")
 }
 
 func TestPost_GithubCommentReaction(t *testing.T) {
@@ -598,6 +640,35 @@ func TestPost_AzureDevopsPullRequestIgnoreEvent(t *testing.T) {
 	}
 }
 
+func TestPost_AzureDevopsPullRequestIteration(t *testing.T) {
+	t.Log("when an azure devops pull request is updated we only autoplan new iterations")
+	e, _, _, ado, _, cr, _, _, _ := setup(t)
+	e.Parser = &events.EventParser{AzureDevopsUser: "user", AzureDevopsToken: "token"}
+	e.AzureDevopsIterations = events_controllers.NewAzureDevopsIterations()
+	eventJSON, err := os.ReadFile(filepath.Join("testdata", "azureDevopsPullRequestIterationEvent.json"))
+	Ok(t, err)
+
+	cases := []struct {
+		commit  string
+		expResp string
+	}{
+		{"1111111111111111111111111111111111111111", "Processing..."},
+		// A second event for the same iteration, ex. the title was changed.
+		{"1111111111111111111111111111111111111111", "Ignoring pull request update that isn't a new iteration"},
+		{"2222222222222222222222222222222222222222", "Processing..."},
+	}
+	for _, c := range cases {
+		payload := strings.ReplaceAll(string(eventJSON), "SOURCE_COMMIT", c.commit)
+		req, _ := http.NewRequest("GET", "", strings.NewReader(payload))
+		req.Header.Set(azuredevopsHeader, "reqID")
+		When(ado.Validate(req, user, secret)).ThenReturn([]byte(payload), nil)
+		w := httptest.NewRecorder()
+		e.Post(w, req)
+		ResponseContains(t, w, http.StatusOK, c.expResp)
+	}
+	cr.VerifyWasCalled(Times(2)).RunAutoplanCommand(Any[models.Repo](), Any[models.Repo](), Any[models.PullRequest](), Any[models.User](), Any[string]())
+}
+
 func TestPost_AzureDevopsPullRequestDeletedCommentIgnoreEvent(t *testing.T) {
 	t.Log("when the event is an azure devops pull request deleted comment event we ignore it")
 	e, _, _, ado, _, _, _, _, _ := setup(t)
@@ -976,7 +1047,7 @@ func TestPost_PullOpenedOrUpdated(t *testing.T) {
 			w := httptest.NewRecorder()
 			e.Post(w, req)
 			ResponseContains(t, w, http.StatusOK, "Processing...")
-			cr.VerifyWasCalledOnce().RunAutoplanCommand(models.Repo{}, models.Repo{}, models.PullRequest{State: models.ClosedPullState}, models.User{})
+			cr.VerifyWasCalledOnce().RunAutoplanCommand(models.Repo{}, models.Repo{}, models.PullRequest{State: models.ClosedPullState}, models.User{}, "")
 		})
 	}
 }
@@ -330,6 +330,7 @@ func TestDeleteLock_UpdateProjectStatus(t *testing.T) {
 			Workspace:  workspaceName,
 			RepoRelDir: projectPath,
 			Status:     models.DiscardedPlanStatus,
+			PlanStats:  &models.PlanSuccessStats{},
 		},
 	}, status.Projects)
 }
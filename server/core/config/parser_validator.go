@@ -19,7 +19,11 @@ import (
 
 // ParserValidator parses and validates server-side repo config files and
 // repo-level atlantis.yaml files.
-type ParserValidator struct{}
+type ParserValidator struct {
+	// RunIDEnvVar is the env var steps get the ID of their run in, from
+	// --run-id-env-var. If empty, valid.DefaultRunIDEnvVar is used.
+	RunIDEnvVar string
+}
 
 // HasRepoCfg returns true if there is a repo config (atlantis.yaml) file
 // for the repo at absRepoDir.
@@ -71,6 +75,7 @@ func (p *ParserValidator) ParseRepoCfgData(repoCfgData []byte, globalCfg valid.G
 
 	// Set ErrorTag to yaml so it uses the YAML field names in error messages.
 	validation.ErrorTag = "yaml"
+	raw.RunIDEnvVar = p.runIDEnvVar()
 	if err := rawConfig.Validate(); err != nil {
 		return valid.RepoCfg{}, err
 	}
@@ -148,6 +153,7 @@ func (p *ParserValidator) validateRawGlobalCfg(rawCfg raw.GlobalCfg, defaultCfg
 	// Setting ErrorTag means our errors will use the field names defined in
 	// the struct tags for yaml/json.
 	validation.ErrorTag = errTag
+	raw.RunIDEnvVar = p.runIDEnvVar()
 	if err := rawCfg.Validate(); err != nil {
 		return valid.GlobalCfg{}, err
 	}
@@ -156,6 +162,15 @@ func (p *ParserValidator) validateRawGlobalCfg(rawCfg raw.GlobalCfg, defaultCfg
 	return validCfg, nil
 }
 
+// runIDEnvVar returns the env var steps get the ID of their run in, so
+// env_schema checks don't flag references to it.
+func (p *ParserValidator) runIDEnvVar() string {
+	if p.RunIDEnvVar == "" {
+		return valid.DefaultRunIDEnvVar
+	}
+	return p.RunIDEnvVar
+}
+
 func (p *ParserValidator) repoCfgPath(repoDir, cfgFilename string) string {
 	return filepath.Join(repoDir, cfgFilename)
 }
@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-version"
 	"github.com/runatlantis/atlantis/server/core/config"
@@ -241,6 +242,40 @@ projects:
 				Workflows: make(map[string]valid.Workflow),
 			},
 		},
+		{
+			description: "project tf_vars",
+			input: `
+version: 3
+projects:
+- dir: "."
+  tf_vars:
+    region: us-east-1
+    replicas: 3
+    zones: [a, b]
+    tags:
+      team: infra
+`,
+			exp: valid.RepoCfg{
+				Version: 3,
+				Projects: []valid.Project{
+					{
+						Dir:       ".",
+						Workspace: "default",
+						Autoplan: valid.Autoplan{
+							WhenModified: raw.DefaultAutoPlanWhenModified,
+							Enabled:      true,
+						},
+						TFVars: map[string]string{
+							"region":   "us-east-1",
+							"replicas": "3",
+							"zones":    `["a","b"]`,
+							"tags":     `{"team":"infra"}`,
+						},
+					},
+				},
+				Workflows: make(map[string]valid.Workflow),
+			},
+		},
 		{
 			description: "autoplan should be enabled if only when_modified set",
 			input: `
@@ -1054,45 +1089,55 @@ workflows:
 						Plan: valid.Stage{
 							Steps: []valid.Step{
 								{
-									StepName:    "env",
-									EnvVarName:  "env_name",
-									EnvVarValue: "env_value",
+									StepName:      "env",
+									EnvVarName:    "env_name",
+									EnvVarValue:   "env_value",
+									MaskInComment: true,
+									MaskInLog:     true,
 								},
 							},
 						},
 						PolicyCheck: valid.Stage{
 							Steps: []valid.Step{
 								{
-									StepName:    "env",
-									EnvVarName:  "env_name",
-									EnvVarValue: "env_value",
+									StepName:      "env",
+									EnvVarName:    "env_name",
+									EnvVarValue:   "env_value",
+									MaskInComment: true,
+									MaskInLog:     true,
 								},
 							},
 						},
 						Apply: valid.Stage{
 							Steps: []valid.Step{
 								{
-									StepName:   "env",
-									EnvVarName: "env_name",
-									RunCommand: "command and args",
+									StepName:      "env",
+									EnvVarName:    "env_name",
+									RunCommand:    "command and args",
+									MaskInComment: true,
+									MaskInLog:     true,
 								},
 							},
 						},
 						Import: valid.Stage{
 							Steps: []valid.Step{
 								{
-									StepName:    "env",
-									EnvVarName:  "env_name",
-									EnvVarValue: "env_value",
+									StepName:      "env",
+									EnvVarName:    "env_name",
+									EnvVarValue:   "env_value",
+									MaskInComment: true,
+									MaskInLog:     true,
 								},
 							},
 						},
 						StateRm: valid.Stage{
 							Steps: []valid.Step{
 								{
-									StepName:    "env",
-									EnvVarName:  "env_name",
-									EnvVarValue: "env_value",
+									StepName:      "env",
+									EnvVarName:    "env_name",
+									EnvVarValue:   "env_value",
+									MaskInComment: true,
+									MaskInLog:     true,
 								},
 							},
 						},
@@ -1294,6 +1339,12 @@ func TestParseGlobalCfg(t *testing.T) {
   plan_requirements: [invalid]`,
 			expErr: "repos: (0: (plan_requirements: \"invalid\" is not a valid plan_requirement, only \"approved\", \"mergeable\" and \"undiverged\" are supported.).).",
 		},
+		"invalid allowed_plan_requirements": {
+			input: `repos:
+- id: /.*/
+  allowed_plan_requirements: [invalid]`,
+			expErr: "repos: (0: (allowed_plan_requirements: \"invalid\" is not a valid plan_requirement, only \"approved\", \"mergeable\" and \"undiverged\" are supported.).).",
+		},
 		"invalid apply_requirement": {
 			input: `repos:
 - id: /.*/
@@ -1338,6 +1389,278 @@ func TestParseGlobalCfg(t *testing.T) {
 				Workflows: defaultCfg.Workflows,
 			},
 		},
+		"clone depth": {
+			input: `repos:
+- id: /.*/
+  clone_depth: 1`,
+			exp: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					defaultCfg.Repos[0],
+					{
+						IDRegex:    regexp.MustCompile(".*"),
+						CloneDepth: Int(1),
+					},
+				},
+				Workflows: defaultCfg.Workflows,
+			},
+		},
+		"invalid clone depth": {
+			input: `repos:
+- id: /.*/
+  clone_depth: -1`,
+			expErr: "repos: (0: (clone_depth: must be 0 to clone the full history or greater than 0, found -1.).).",
+		},
+		"plan ttl": {
+			input: `repos:
+- id: /.*/
+  plan_ttl: 2h`,
+			exp: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					defaultCfg.Repos[0],
+					{
+						IDRegex: regexp.MustCompile(".*"),
+						PlanTTL: 2 * time.Hour,
+					},
+				},
+				Workflows: defaultCfg.Workflows,
+			},
+		},
+		"invalid plan ttl": {
+			input: `repos:
+- id: /.*/
+  plan_ttl: 2 hours`,
+			expErr: "repos: (0: (plan_ttl: \"2 hours\" is not a valid duration, ex. \"2h\" or \"30m\".).).",
+		},
+		"result export": {
+			input: `repos:
+- id: /.*/
+  result_export:
+    issue: 42
+    url: https://audit.example.com/atlantis`,
+			exp: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					defaultCfg.Repos[0],
+					{
+						IDRegex:      regexp.MustCompile(".*"),
+						ResultExport: &valid.ResultExport{Issue: 42, URL: "https://audit.example.com/atlantis"},
+					},
+				},
+				Workflows: defaultCfg.Workflows,
+			},
+		},
+		"empty result export": {
+			input: `repos:
+- id: /.*/
+  result_export: {}`,
+			expErr: "repos: (0: (result_export: must set issue or url.).).",
+		},
+		"invalid result export url": {
+			input: `repos:
+- id: /.*/
+  result_export:
+    url: ftp://audit.example.com`,
+			expErr: "repos: (0: (result_export: (url: must be an http or https URL.).).).",
+		},
+		"plugin cache dir": {
+			input: `repos:
+- id: /.*/
+  plugin_cache_dir: /var/cache/terraform-plugins/`,
+			exp: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					defaultCfg.Repos[0],
+					{
+						IDRegex:        regexp.MustCompile(".*"),
+						PluginCacheDir: "/var/cache/terraform-plugins",
+					},
+				},
+				Workflows: defaultCfg.Workflows,
+			},
+		},
+		"relative plugin cache dir": {
+			input: `repos:
+- id: /.*/
+  plugin_cache_dir: plugins`,
+			expErr: "repos: (0: (plugin_cache_dir: must be an absolute path, found \"plugins\".).).",
+		},
+		"max projects per pr": {
+			input: `repos:
+- id: /.*/
+  max_projects_per_pr: 20`,
+			exp: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					defaultCfg.Repos[0],
+					{
+						IDRegex:          regexp.MustCompile(".*"),
+						MaxProjectsPerPR: Int(20),
+					},
+				},
+				Workflows: defaultCfg.Workflows,
+			},
+		},
+		"negative max projects per pr": {
+			input: `repos:
+- id: /.*/
+  max_projects_per_pr: -1`,
+			expErr: "repos: (0: (max_projects_per_pr: must be 0 for no limit or greater than 0, found -1.).).",
+		},
+		"require apply reason": {
+			input: `repos:
+- id: /.*/
+  require_apply_reason: true`,
+			exp: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					defaultCfg.Repos[0],
+					{
+						IDRegex:            regexp.MustCompile(".*"),
+						RequireApplyReason: Bool(true),
+					},
+				},
+				Workflows: defaultCfg.Workflows,
+			},
+		},
+		"provider mirror url": {
+			input: `repos:
+- id: /.*/
+  provider_mirror: https://mirror.example.com/providers`,
+			exp: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					defaultCfg.Repos[0],
+					{
+						IDRegex:        regexp.MustCompile(".*"),
+						ProviderMirror: &valid.ProviderMirror{URL: "https://mirror.example.com/providers/"},
+					},
+				},
+				Workflows: defaultCfg.Workflows,
+			},
+		},
+		"provider mirror path": {
+			input: `repos:
+- id: /.*/
+  provider_mirror: /opt/terraform/providers/`,
+			exp: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					defaultCfg.Repos[0],
+					{
+						IDRegex:        regexp.MustCompile(".*"),
+						ProviderMirror: &valid.ProviderMirror{Path: "/opt/terraform/providers"},
+					},
+				},
+				Workflows: defaultCfg.Workflows,
+			},
+		},
+		"invalid provider mirror": {
+			input: `repos:
+- id: /.*/
+  provider_mirror: http://mirror.example.com/`,
+			expErr: "repos: (0: (provider_mirror: network mirror URL \"http://mirror.example.com/\" must be an https URL, ex. \"https://terraform-mirror.example.com/providers/\".).).",
+		},
+		"status strategy": {
+			input: `repos:
+- id: /.*/
+  status_strategy: per-project`,
+			exp: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					defaultCfg.Repos[0],
+					{
+						IDRegex:        regexp.MustCompile(".*"),
+						StatusStrategy: valid.StatusStrategyPerProject,
+					},
+				},
+				Workflows: defaultCfg.Workflows,
+			},
+		},
+		"invalid status strategy": {
+			input: `repos:
+- id: /.*/
+  status_strategy: combined`,
+			expErr: "repos: (0: (status_strategy: \"combined\" is not a valid status_strategy, only \"aggregate\" and \"per-project\" are supported.).).",
+		},
+		"clean workspace": {
+			input: `repos:
+- id: /.*/
+  clean_workspace: on-error`,
+			exp: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					defaultCfg.Repos[0],
+					{
+						IDRegex:        regexp.MustCompile(".*"),
+						CleanWorkspace: valid.CleanWorkspaceOnError,
+					},
+				},
+				Workflows: defaultCfg.Workflows,
+			},
+		},
+		"invalid clean workspace": {
+			input: `repos:
+- id: /.*/
+  clean_workspace: sometimes`,
+			expErr: "repos: (0: (clean_workspace: \"sometimes\" is not a valid clean_workspace, only \"always\", \"never\" and \"on-error\" are supported.).).",
+		},
+		"allowed commands": {
+			input: `repos:
+- id: /.*/
+  allowed_commands: [plan, policy_check]`,
+			exp: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					defaultCfg.Repos[0],
+					{
+						IDRegex:         regexp.MustCompile(".*"),
+						AllowedCommands: []string{"plan", "policy_check"},
+					},
+				},
+				Workflows: defaultCfg.Workflows,
+			},
+		},
+		"invalid allowed commands": {
+			input: `repos:
+- id: /.*/
+  allowed_commands: [plan, destroy]`,
+			expErr: "repos: (0: (allowed_commands: \"destroy\" is not a command, commands are version, plan, apply, unlock, approve_policies, policy_check, import, state, discard-plan, lock-status, list-projects, compare-plan, explain-workflow, approve-step, show-config, pin-plan, unpin-plan.).).",
+		},
+		"run command policy": {
+			input: `repos:
+- id: /.*/
+  allowed_run_commands: [terraform, /opt/tools/]
+  denied_run_commands: [curl]`,
+			exp: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					defaultCfg.Repos[0],
+					{
+						IDRegex:            regexp.MustCompile(".*"),
+						AllowedRunCommands: []string{"terraform", "/opt/tools/"},
+						DeniedRunCommands:  []string{"curl"},
+					},
+				},
+				Workflows: defaultCfg.Workflows,
+			},
+		},
+		"invalid run command": {
+			input: `repos:
+- id: /.*/
+  denied_run_commands: ["curl -d"]`,
+			expErr: "repos: (0: (denied_run_commands: \"curl -d\" is not a valid command, ex. \"terraform\" or \"/usr/local/bin/\".).).",
+		},
+		"allowed plan refs": {
+			input: `repos:
+- id: /.*/
+  allowed_plan_refs: /^(origin\/)?hotfix-.*$/`,
+			exp: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					defaultCfg.Repos[0],
+					{
+						IDRegex:              regexp.MustCompile(".*"),
+						AllowedPlanRefsRegex: regexp.MustCompile(`^(origin\/)?hotfix-.*$`),
+					},
+				},
+				Workflows: defaultCfg.Workflows,
+			},
+		},
+		"invalid allowed plan refs": {
+			input: `repos:
+- id: /.*/
+  allowed_plan_refs: hotfix`,
+			expErr: "repos: (0: (allowed_plan_refs: regex must begin and end with a slash '/'.).).",
+		},
 		"no workflows key": {
 			input: `repos: []`,
 			exp:   defaultCfg,
@@ -1931,6 +2254,10 @@ func String(v string) *string { return &v }
 // to store v and returns a pointer to it.
 func Bool(v bool) *bool { return &v }
 
+// Int is a helper routine that allocates a new int value
+// to store v and returns a pointer to it.
+func Int(v int) *int { return &v }
+
 func defaultWorkflow(name string) valid.Workflow {
 	return valid.Workflow{
 		Name:        name,
@@ -1,14 +1,19 @@
 package raw
 
 import (
+	"errors"
+	"fmt"
+
 	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/moby/patternmatcher"
 	"github.com/runatlantis/atlantis/server/core/config/valid"
 )
 
 var DefaultAutoDiscoverMode = valid.AutoDiscoverAutoMode
 
 type AutoDiscover struct {
-	Mode *valid.AutoDiscoverMode `yaml:"mode,omitempty"`
+	Mode        *valid.AutoDiscoverMode `yaml:"mode,omitempty"`
+	IgnorePaths []string                `yaml:"ignore_paths,omitempty"`
 }
 
 func (a AutoDiscover) ToValid() *valid.AutoDiscover {
@@ -19,14 +24,29 @@ func (a AutoDiscover) ToValid() *valid.AutoDiscover {
 	} else {
 		v.Mode = DefaultAutoDiscoverMode
 	}
+	v.IgnorePaths = a.IgnorePaths
 
 	return &v
 }
 
 func (a AutoDiscover) Validate() error {
+	ignorePathsValid := func(value interface{}) error {
+		paths := value.([]string)
+		for _, p := range paths {
+			if p == "" {
+				return errors.New("paths can't be empty")
+			}
+		}
+		if _, err := patternmatcher.New(paths); err != nil {
+			return fmt.Errorf("invalid pattern: %s", err)
+		}
+		return nil
+	}
+
 	res := validation.ValidateStruct(&a,
 		// If a.Mode is nil, this should still pass validation.
-		validation.Field(&a.Mode, validation.In(valid.AutoDiscoverAutoMode, valid.AutoDiscoverDisabledMode, valid.AutoDiscoverEnabledMode)),
+		validation.Field(&a.Mode, validation.In(valid.AutoDiscoverAutoMode, valid.AutoDiscoverDisabledMode, valid.AutoDiscoverEnabledMode, valid.AutoDiscoverBackendMode)),
+		validation.Field(&a.IgnorePaths, validation.By(ignorePathsValid)),
 	)
 	return res
 }
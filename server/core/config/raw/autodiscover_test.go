@@ -10,6 +10,7 @@ import (
 
 func TestAutoDiscover_UnmarshalYAML(t *testing.T) {
 	autoDiscoverEnabled := valid.AutoDiscoverEnabledMode
+	autoDiscoverBackend := valid.AutoDiscoverBackendMode
 	cases := []struct {
 		description string
 		input       string
@@ -31,6 +32,17 @@ mode: enabled
 				Mode: &autoDiscoverEnabled,
 			},
 		},
+		{
+			description: "backend mode with ignore paths",
+			input: `
+mode: backend
+ignore_paths: [modules, "sandbox/**"]
+`,
+			exp: raw.AutoDiscover{
+				Mode:        &autoDiscoverBackend,
+				IgnorePaths: []string{"modules", "sandbox/**"},
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -47,6 +59,7 @@ func TestAutoDiscover_Validate(t *testing.T) {
 	autoDiscoverAuto := valid.AutoDiscoverAutoMode
 	autoDiscoverEnabled := valid.AutoDiscoverEnabledMode
 	autoDiscoverDisabled := valid.AutoDiscoverDisabledMode
+	autoDiscoverBackend := valid.AutoDiscoverBackendMode
 	randomString := valid.AutoDiscoverMode("random_string")
 	cases := []struct {
 		description string
@@ -79,6 +92,28 @@ func TestAutoDiscover_Validate(t *testing.T) {
 			},
 			errContains: nil,
 		},
+		{
+			description: "mode set to backend",
+			input: raw.AutoDiscover{
+				Mode:        &autoDiscoverBackend,
+				IgnorePaths: []string{"modules", "sandbox/**"},
+			},
+			errContains: nil,
+		},
+		{
+			description: "empty ignore path",
+			input: raw.AutoDiscover{
+				IgnorePaths: []string{""},
+			},
+			errContains: String("IgnorePaths: paths can't be empty"),
+		},
+		{
+			description: "invalid ignore path",
+			input: raw.AutoDiscover{
+				IgnorePaths: []string{"modules/["},
+			},
+			errContains: String("IgnorePaths: invalid pattern"),
+		},
 		{
 			description: "mode set to random string",
 			input: raw.AutoDiscover{
@@ -121,6 +156,17 @@ func TestAutoDiscover_ToValid(t *testing.T) {
 				Mode: valid.AutoDiscoverEnabledMode,
 			},
 		},
+		{
+			description: "ignore paths set",
+			input: raw.AutoDiscover{
+				Mode:        &autoDiscoverEnabled,
+				IgnorePaths: []string{"modules"},
+			},
+			exp: &valid.AutoDiscover{
+				Mode:        valid.AutoDiscoverEnabledMode,
+				IgnorePaths: []string{"modules"},
+			},
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.description, func(t *testing.T) {
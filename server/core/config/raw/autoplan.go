@@ -1,6 +1,9 @@
 package raw
 
 import (
+	"fmt"
+
+	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/runatlantis/atlantis/server/core/config/valid"
 )
 
@@ -15,6 +18,9 @@ var DefaultAutoPlanWhenModified = []string{
 type Autoplan struct {
 	WhenModified []string `yaml:"when_modified,omitempty"`
 	Enabled      *bool    `yaml:"enabled,omitempty"`
+	// Workspaces, if set, limits autoplanning to projects in these
+	// workspaces. Projects in other workspaces must be planned manually.
+	Workspaces []string `yaml:"workspaces,omitempty"`
 }
 
 func (a Autoplan) ToValid() valid.Autoplan {
@@ -30,11 +36,30 @@ func (a Autoplan) ToValid() valid.Autoplan {
 	} else {
 		v.Enabled = *a.Enabled
 	}
+	v.Workspaces = a.Workspaces
 
 	return v
 }
 
 func (a Autoplan) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Workspaces, validation.By(validAutoplanWorkspaces)),
+	)
+}
+
+func validAutoplanWorkspaces(value interface{}) error {
+	workspaces := value.([]string)
+	if workspaces == nil {
+		return nil
+	}
+	if len(workspaces) == 0 {
+		return fmt.Errorf("if set cannot be empty, set enabled to false to disable autoplanning")
+	}
+	for _, w := range workspaces {
+		if !validWorkspace(w) {
+			return fmt.Errorf("invalid workspace: %q", w)
+		}
+	}
 	return nil
 }
 
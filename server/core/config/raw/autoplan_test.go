@@ -3,6 +3,7 @@ package raw_test
 import (
 	"testing"
 
+	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/runatlantis/atlantis/server/core/config/raw"
 	"github.com/runatlantis/atlantis/server/core/config/valid"
 	. "github.com/runatlantis/atlantis/testing"
@@ -44,6 +45,15 @@ when_modified: ["something-else"]
 				WhenModified: []string{"something-else"},
 			},
 		},
+		{
+			description: "workspaces",
+			input: `
+workspaces: [default, staging]
+`,
+			exp: raw.Autoplan{
+				Workspaces: []string{"default", "staging"},
+			},
+		},
 		{
 			description: "modified elem empty",
 			input: `
@@ -89,6 +99,12 @@ func TestAutoplan_Validate(t *testing.T) {
 				Enabled: Bool(false),
 			},
 		},
+		{
+			description: "workspaces",
+			input: raw.Autoplan{
+				Workspaces: []string{"default", "staging"},
+			},
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.description, func(t *testing.T) {
@@ -97,6 +113,49 @@ func TestAutoplan_Validate(t *testing.T) {
 	}
 }
 
+func TestAutoplan_ValidateError(t *testing.T) {
+	cases := []struct {
+		description string
+		input       raw.Autoplan
+		expErr      string
+	}{
+		{
+			description: "workspaces empty",
+			input: raw.Autoplan{
+				Workspaces: []string{},
+			},
+			expErr: "workspaces: if set cannot be empty, set enabled to false to disable autoplanning.",
+		},
+		{
+			description: "workspace empty",
+			input: raw.Autoplan{
+				Workspaces: []string{"default", ""},
+			},
+			expErr: "workspaces: invalid workspace: \"\".",
+		},
+		{
+			description: "workspace with a path",
+			input: raw.Autoplan{
+				Workspaces: []string{"../staging"},
+			},
+			expErr: "workspaces: invalid workspace: \"../staging\".",
+		},
+		{
+			description: "workspace with a space",
+			input: raw.Autoplan{
+				Workspaces: []string{"my workspace"},
+			},
+			expErr: "workspaces: invalid workspace: \"my workspace\".",
+		},
+	}
+	validation.ErrorTag = "yaml"
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			ErrEquals(t, c.expErr, c.input.Validate())
+		})
+	}
+}
+
 func TestAutoplan_ToValid(t *testing.T) {
 	cases := []struct {
 		description string
@@ -141,6 +200,17 @@ func TestAutoplan_ToValid(t *testing.T) {
 				WhenModified: raw.DefaultAutoPlanWhenModified,
 			},
 		},
+		{
+			description: "workspaces",
+			input: raw.Autoplan{
+				Workspaces: []string{"default"},
+			},
+			exp: valid.Autoplan{
+				Enabled:      true,
+				WhenModified: raw.DefaultAutoPlanWhenModified,
+				Workspaces:   []string{"default"},
+			},
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.description, func(t *testing.T) {
@@ -0,0 +1,130 @@
+package raw
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+)
+
+const (
+	EnvSchemaStringType = "string"
+	EnvSchemaNumberType = "number"
+	EnvSchemaBoolType   = "bool"
+)
+
+// envVarNameRegex matches valid environment variable names.
+var envVarNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// envVarRefRegex matches references to environment variables in shell
+// commands, ex. $FOO, ${FOO} or ${FOO:-default}.
+var envVarRefRegex = regexp.MustCompile(`\$(?:\{([A-Za-z_][A-Za-z0-9_]*)|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// shellVarAssignRegex matches variables a shell command assigns itself, ex.
+// FOO=bar, export FOO=bar or for FOO in ...
+var shellVarAssignRegex = regexp.MustCompile(`(?:^|[\s;&|(])(?:(?:export|local|readonly)\s+)?([A-Za-z_][A-Za-z0-9_]*)=|\bfor\s+([A-Za-z_][A-Za-z0-9_]*)\s+in\b`)
+
+// RunIDEnvVar is the name of the env var run and env steps get the ID of
+// their run in, from --run-id-env-var. Like validation.ErrorTag it's set by
+// config.ParserValidator before validating.
+var RunIDEnvVar = valid.DefaultRunIDEnvVar
+
+// validateEnvSchemaTypes returns an error if any name in schema isn't a valid
+// environment variable name or any type isn't supported.
+func validateEnvSchemaTypes(schema map[string]string) error {
+	for _, name := range sortedKeys(schema) {
+		if !envVarNameRegex.MatchString(name) {
+			return fmt.Errorf("%q is not a valid environment variable name", name)
+		}
+		switch schema[name] {
+		case EnvSchemaStringType, EnvSchemaNumberType, EnvSchemaBoolType:
+		default:
+			return fmt.Errorf("%q has type %q, only %q, %q and %q are supported", name, schema[name], EnvSchemaStringType, EnvSchemaNumberType, EnvSchemaBoolType)
+		}
+	}
+	return nil
+}
+
+// validateStageEnvRefs returns an error if a run or env step command in stage
+// references an environment variable that isn't in schema, set by Atlantis
+// or set by an earlier env step in the stage. It also checks that static env
+// step values match their declared type.
+func validateStageEnvRefs(stageName string, stage *Stage, schema map[string]string) error {
+	setBySteps := map[string]bool{}
+	for i, s := range stage.Steps {
+		step := s.ToValid()
+		switch step.StepName {
+		case RunStepName, EnvStepName:
+		default:
+			continue
+		}
+		location := fmt.Sprintf("%s step %d in the %s stage", step.StepName, i+1, stageName)
+		for _, cmd := range []string{step.RunCommand, step.Always} {
+			if refs := undeclaredEnvRefs(cmd, schema, setBySteps); len(refs) > 0 {
+				return fmt.Errorf("%s references undeclared environment variable %q, declare it in env_schema", location, refs[0])
+			}
+		}
+		if step.EnvVarName == "" {
+			continue
+		}
+		if typ := schema[step.EnvVarName]; step.RunCommand == "" && !envSchemaTypeMatches(typ, step.EnvVarValue) {
+			return fmt.Errorf("%s sets %q to %q but it's declared as a %s", location, step.EnvVarName, step.EnvVarValue, typ)
+		}
+		setBySteps[step.EnvVarName] = true
+	}
+	return nil
+}
+
+// undeclaredEnvRefs returns the environment variables cmd references that
+// aren't in schema, in setBySteps, set by Atlantis or assigned by cmd itself.
+func undeclaredEnvRefs(cmd string, schema map[string]string, setBySteps map[string]bool) []string {
+	assigned := map[string]bool{}
+	for _, match := range shellVarAssignRegex.FindAllStringSubmatch(cmd, -1) {
+		assigned[match[1]+match[2]] = true
+	}
+
+	var refs []string
+	for _, idx := range envVarRefRegex.FindAllStringSubmatchIndex(cmd, -1) {
+		// Skip escaped references, ex. \$FOO.
+		if idx[0] > 0 && cmd[idx[0]-1] == '\\' {
+			continue
+		}
+		var name string
+		if idx[2] >= 0 {
+			name = cmd[idx[2]:idx[3]]
+		} else {
+			name = cmd[idx[4]:idx[5]]
+		}
+		if _, ok := schema[name]; ok || setBySteps[name] || slices.Contains(valid.StepEnvVars, name) || name == RunIDEnvVar || assigned[name] {
+			continue
+		}
+		refs = append(refs, name)
+	}
+	return refs
+}
+
+// envSchemaTypeMatches returns true if value is of type typ. Values of
+// undeclared or string types always match.
+func envSchemaTypeMatches(typ string, value string) bool {
+	var err error
+	switch typ {
+	case EnvSchemaNumberType:
+		_, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+	case EnvSchemaBoolType:
+		_, err = strconv.ParseBool(strings.TrimSpace(value))
+	}
+	return err == nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
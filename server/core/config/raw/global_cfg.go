@@ -2,12 +2,15 @@ package raw
 
 import (
 	"fmt"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events/command"
 )
 
 // GlobalCfg is the raw schema for server-side repo config.
@@ -30,6 +33,7 @@ type Repo struct {
 	Workflow                  *string        `yaml:"workflow,omitempty" json:"workflow,omitempty"`
 	PostWorkflowHooks         []WorkflowHook `yaml:"post_workflow_hooks" json:"post_workflow_hooks"`
 	AllowedWorkflows          []string       `yaml:"allowed_workflows,omitempty" json:"allowed_workflows,omitempty"`
+	AllowedPlanRequirements   []string       `yaml:"allowed_plan_requirements,omitempty" json:"allowed_plan_requirements,omitempty"`
 	AllowedOverrides          []string       `yaml:"allowed_overrides" json:"allowed_overrides"`
 	AllowCustomWorkflows      *bool          `yaml:"allow_custom_workflows,omitempty" json:"allow_custom_workflows,omitempty"`
 	DeleteSourceBranchOnMerge *bool          `yaml:"delete_source_branch_on_merge,omitempty" json:"delete_source_branch_on_merge,omitempty"`
@@ -38,6 +42,20 @@ type Repo struct {
 	PolicyCheck               *bool          `yaml:"policy_check,omitempty" json:"policy_check,omitempty"`
 	CustomPolicyCheck         *bool          `yaml:"custom_policy_check,omitempty" json:"custom_policy_check,omitempty"`
 	AutoDiscover              *AutoDiscover  `yaml:"autodiscover,omitempty" json:"autodiscover,omitempty"`
+	CloneDepth                *int           `yaml:"clone_depth,omitempty" json:"clone_depth,omitempty"`
+	AllowedPlanRefs           string         `yaml:"allowed_plan_refs,omitempty" json:"allowed_plan_refs,omitempty"`
+	Quiet                     *bool          `yaml:"quiet,omitempty" json:"quiet,omitempty"`
+	PlanTTL                   *string        `yaml:"plan_ttl,omitempty" json:"plan_ttl,omitempty"`
+	ResultExport              *ResultExport  `yaml:"result_export,omitempty" json:"result_export,omitempty"`
+	PluginCacheDir            *string        `yaml:"plugin_cache_dir,omitempty" json:"plugin_cache_dir,omitempty"`
+	AllowedRunCommands        []string       `yaml:"allowed_run_commands,omitempty" json:"allowed_run_commands,omitempty"`
+	DeniedRunCommands         []string       `yaml:"denied_run_commands,omitempty" json:"denied_run_commands,omitempty"`
+	MaxProjectsPerPR          *int           `yaml:"max_projects_per_pr,omitempty" json:"max_projects_per_pr,omitempty"`
+	RequireApplyReason        *bool          `yaml:"require_apply_reason,omitempty" json:"require_apply_reason,omitempty"`
+	ProviderMirror            *string        `yaml:"provider_mirror,omitempty" json:"provider_mirror,omitempty"`
+	StatusStrategy            *string        `yaml:"status_strategy,omitempty" json:"status_strategy,omitempty"`
+	CleanWorkspace            *string        `yaml:"clean_workspace,omitempty" json:"clean_workspace,omitempty"`
+	AllowedCommands           []string       `yaml:"allowed_commands,omitempty" json:"allowed_commands,omitempty"`
 }
 
 func (g GlobalCfg) Validate() error {
@@ -229,18 +247,98 @@ func (r Repo) Validate() error {
 		return nil
 	}
 
+	cloneDepthValid := func(value interface{}) error {
+		depth := value.(*int)
+		if depth != nil && *depth < 0 {
+			return fmt.Errorf("must be 0 to clone the full history or greater than 0, found %d", *depth)
+		}
+		return nil
+	}
+
+	maxProjectsPerPRValid := func(value interface{}) error {
+		max := value.(*int)
+		if max != nil && *max < 0 {
+			return fmt.Errorf("must be 0 for no limit or greater than 0, found %d", *max)
+		}
+		return nil
+	}
+
+	pluginCacheDirValid := func(value interface{}) error {
+		dir := value.(*string)
+		if dir != nil && !filepath.IsAbs(*dir) {
+			return fmt.Errorf("must be an absolute path, found %q", *dir)
+		}
+		return nil
+	}
+
+	providerMirrorValid := func(value interface{}) error {
+		mirror := value.(*string)
+		if mirror == nil {
+			return nil
+		}
+		_, err := valid.ParseProviderMirror(*mirror)
+		return err
+	}
+
+	statusStrategyValid := func(value interface{}) error {
+		strategy := value.(*string)
+		if strategy == nil || *strategy == valid.StatusStrategyAggregate || *strategy == valid.StatusStrategyPerProject {
+			return nil
+		}
+		return fmt.Errorf("%q is not a valid status_strategy, only %q and %q are supported", *strategy, valid.StatusStrategyAggregate, valid.StatusStrategyPerProject)
+	}
+
+	cleanWorkspaceValid := func(value interface{}) error {
+		policy := value.(*string)
+		if policy == nil || *policy == valid.CleanWorkspaceNever || *policy == valid.CleanWorkspaceAlways || *policy == valid.CleanWorkspaceOnError {
+			return nil
+		}
+		return fmt.Errorf("%q is not a valid clean_workspace, only %q, %q and %q are supported", *policy, valid.CleanWorkspaceAlways, valid.CleanWorkspaceNever, valid.CleanWorkspaceOnError)
+	}
+
+	allowedCommandsValid := func(value interface{}) error {
+		for _, name := range value.([]string) {
+			if _, err := command.ParseCommandName(name); err != nil {
+				return fmt.Errorf("%q is not a command, commands are %s", name, strings.Join(commentCommandNames(), ", "))
+			}
+		}
+		return nil
+	}
+
+	runCommandsValid := func(value interface{}) error {
+		for _, c := range value.([]string) {
+			if c == "" || strings.ContainsAny(c, " \t\n") {
+				return fmt.Errorf("%q is not a valid command, ex. \"terraform\" or \"/usr/local/bin/\"", c)
+			}
+		}
+		return nil
+	}
+
 	return validation.ValidateStruct(&r,
 		validation.Field(&r.ID, validation.Required, validation.By(idValid)),
 		validation.Field(&r.Branch, validation.By(branchValid)),
+		validation.Field(&r.AllowedPlanRefs, validation.By(branchValid)),
 		validation.Field(&r.RepoConfigFile, validation.By(repoConfigFileValid)),
 		validation.Field(&r.AllowedOverrides, validation.By(overridesValid)),
 		validation.Field(&r.PlanRequirements, validation.By(validPlanReq)),
+		validation.Field(&r.AllowedPlanRequirements, validation.By(validPlanReq)),
 		validation.Field(&r.ApplyRequirements, validation.By(validApplyReq)),
 		validation.Field(&r.ImportRequirements, validation.By(validImportReq)),
 		validation.Field(&r.Workflow, validation.By(workflowExists)),
 		validation.Field(&r.DeleteSourceBranchOnMerge, validation.By(deleteSourceBranchOnMergeValid)),
 		validation.Field(&r.AutoDiscover, validation.By(autoDiscoverValid)),
 		validation.Field(&r.RepoLocks, validation.By(repoLocksValid)),
+		validation.Field(&r.CloneDepth, validation.By(cloneDepthValid)),
+		validation.Field(&r.PlanTTL, validation.By(validPlanTTL)),
+		validation.Field(&r.ResultExport),
+		validation.Field(&r.PluginCacheDir, validation.By(pluginCacheDirValid)),
+		validation.Field(&r.AllowedRunCommands, validation.By(runCommandsValid)),
+		validation.Field(&r.DeniedRunCommands, validation.By(runCommandsValid)),
+		validation.Field(&r.MaxProjectsPerPR, validation.By(maxProjectsPerPRValid)),
+		validation.Field(&r.ProviderMirror, validation.By(providerMirrorValid)),
+		validation.Field(&r.StatusStrategy, validation.By(statusStrategyValid)),
+		validation.Field(&r.CleanWorkspace, validation.By(cleanWorkspaceValid)),
+		validation.Field(&r.AllowedCommands, validation.By(allowedCommandsValid)),
 	)
 }
 
@@ -262,6 +360,46 @@ func (r Repo) ToValid(workflows map[string]valid.Workflow, globalPlanReqs []stri
 		branchRegex = regexp.MustCompile(withoutSlashes)
 	}
 
+	var allowedPlanRefsRegex *regexp.Regexp
+	if r.AllowedPlanRefs != "" {
+		withoutSlashes := r.AllowedPlanRefs[1 : len(r.AllowedPlanRefs)-1]
+		// Safe to use MustCompile because we test it in Validate().
+		allowedPlanRefsRegex = regexp.MustCompile(withoutSlashes)
+	}
+
+	var planTTL time.Duration
+	if r.PlanTTL != nil {
+		// Safe to ignore the error because we test it in Validate().
+		planTTL, _ = time.ParseDuration(*r.PlanTTL)
+	}
+
+	var pluginCacheDir string
+	if r.PluginCacheDir != nil {
+		pluginCacheDir = filepath.Clean(*r.PluginCacheDir)
+	}
+
+	var providerMirror *valid.ProviderMirror
+	if r.ProviderMirror != nil {
+		// Safe to ignore the error because we test it in Validate().
+		mirror, _ := valid.ParseProviderMirror(*r.ProviderMirror)
+		providerMirror = &mirror
+	}
+
+	var statusStrategy string
+	if r.StatusStrategy != nil {
+		statusStrategy = *r.StatusStrategy
+	}
+
+	var cleanWorkspace string
+	if r.CleanWorkspace != nil {
+		cleanWorkspace = *r.CleanWorkspace
+	}
+
+	var resultExport *valid.ResultExport
+	if r.ResultExport != nil {
+		resultExport = r.ResultExport.ToValid()
+	}
+
 	var workflow *valid.Workflow
 	if r.Workflow != nil {
 		// This key is guaranteed to exist because we test for it in
@@ -357,6 +495,7 @@ OuterGlobalImportReqs:
 		Workflow:                  workflow,
 		PostWorkflowHooks:         postWorkflowHooks,
 		AllowedWorkflows:          r.AllowedWorkflows,
+		AllowedPlanRequirements:   r.AllowedPlanRequirements,
 		AllowedOverrides:          r.AllowedOverrides,
 		AllowCustomWorkflows:      r.AllowCustomWorkflows,
 		DeleteSourceBranchOnMerge: r.DeleteSourceBranchOnMerge,
@@ -365,5 +504,28 @@ OuterGlobalImportReqs:
 		PolicyCheck:               r.PolicyCheck,
 		CustomPolicyCheck:         r.CustomPolicyCheck,
 		AutoDiscover:              autoDiscover,
+		CloneDepth:                r.CloneDepth,
+		AllowedPlanRefsRegex:      allowedPlanRefsRegex,
+		Quiet:                     r.Quiet,
+		PlanTTL:                   planTTL,
+		ResultExport:              resultExport,
+		PluginCacheDir:            pluginCacheDir,
+		AllowedRunCommands:        r.AllowedRunCommands,
+		DeniedRunCommands:         r.DeniedRunCommands,
+		MaxProjectsPerPR:          r.MaxProjectsPerPR,
+		RequireApplyReason:        r.RequireApplyReason,
+		ProviderMirror:            providerMirror,
+		StatusStrategy:            statusStrategy,
+		CleanWorkspace:            cleanWorkspace,
+		AllowedCommands:           r.AllowedCommands,
+	}
+}
+
+// commentCommandNames returns the names of the commands that can be commented.
+func commentCommandNames() []string {
+	var names []string
+	for _, name := range command.AllCommentCommands {
+		names = append(names, name.String())
 	}
+	return names
 }
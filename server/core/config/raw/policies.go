@@ -1,6 +1,8 @@
 package raw
 
 import (
+	"fmt"
+
 	validation "github.com/go-ozzo/ozzo-validation"
 	version "github.com/hashicorp/go-version"
 	"github.com/runatlantis/atlantis/server/core/config/valid"
@@ -75,6 +77,9 @@ type PolicySet struct {
 	Name         string       `yaml:"name" json:"name"`
 	Owners       PolicyOwners `yaml:"owners,omitempty" json:"owners,omitempty"`
 	ApproveCount int          `yaml:"approve_count,omitempty" json:"approve_count,omitempty"`
+	// ExemptionTemplate is rendered for each failing policy to a snippet
+	// that would exempt the resource from it.
+	ExemptionTemplate string `yaml:"exemption_template,omitempty" json:"exemption_template,omitempty"`
 }
 
 func (p PolicySet) Validate() error {
@@ -84,6 +89,12 @@ func (p PolicySet) Validate() error {
 		validation.Field(&p.ApproveCount),
 		validation.Field(&p.Path, validation.Required.Error("is required")),
 		validation.Field(&p.Source, validation.In(valid.LocalPolicySet, valid.GithubPolicySet).Error("only 'local' and 'github' source types are supported")),
+		validation.Field(&p.ExemptionTemplate, validation.By(func(value interface{}) error {
+			if _, err := valid.ParsePolicyExemptionTemplate(value.(string)); err != nil {
+				return fmt.Errorf("invalid template: %s", err)
+			}
+			return nil
+		})),
 	)
 }
 
@@ -95,6 +106,7 @@ func (p PolicySet) ToValid() valid.PolicySet {
 	policySet.Source = p.Source
 	policySet.ApproveCount = p.ApproveCount
 	policySet.Owners = p.Owners.ToValid()
+	policySet.ExemptionTemplate = p.ExemptionTemplate
 
 	return policySet
 }
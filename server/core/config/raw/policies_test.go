@@ -151,6 +151,20 @@ func TestPolicySets_Validate(t *testing.T) {
 			},
 			expErr: "conftest_version: version \"version123\" could not be parsed: Malformed version: version123.",
 		},
+		{
+			description: "invalid exemption template",
+			input: raw.PolicySets{
+				PolicySets: []raw.PolicySet{
+					{
+						Name:              "policy-name-1",
+						Path:              "rel/path/to/source",
+						Source:            valid.LocalPolicySet,
+						ExemptionTemplate: "resource: {{ .Resourse }}",
+					},
+				},
+			},
+			expErr: "policy_sets: (0: (exemption_template: invalid template: template: exemption_template:1:13: executing \"exemption_template\" at <.Resourse>: can't evaluate field Resourse in type valid.PolicyFailure.).).",
+		},
 	}
 
 	for _, c := range cases {
@@ -221,6 +235,33 @@ func TestPolicySets_ToValid(t *testing.T) {
 				},
 			},
 		},
+		{
+			description: "policy set with exemption template",
+			input: raw.PolicySets{
+				Version: String("v1.0.0"),
+				PolicySets: []raw.PolicySet{
+					{
+						Name:              "good-policy",
+						Path:              "rel/path/to/source",
+						Source:            valid.LocalPolicySet,
+						ExemptionTemplate: "resource: {{ .Resource }}",
+					},
+				},
+			},
+			exp: valid.PolicySets{
+				Version:      version,
+				ApproveCount: 1,
+				PolicySets: []valid.PolicySet{
+					{
+						Name:              "good-policy",
+						Path:              "rel/path/to/source",
+						Source:            "local",
+						ApproveCount:      1,
+						ExemptionTemplate: "resource: {{ .Resource }}",
+					},
+				},
+			},
+		},
 		{
 			description: "valid policies without owners",
 			input: raw.PolicySets{
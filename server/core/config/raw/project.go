@@ -5,7 +5,9 @@ import (
 	"net/url"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation"
 	version "github.com/hashicorp/go-version"
@@ -38,6 +40,14 @@ type Project struct {
 	ExecutionOrderGroup       *int       `yaml:"execution_order_group,omitempty"`
 	PolicyCheck               *bool      `yaml:"policy_check,omitempty"`
 	CustomPolicyCheck         *bool      `yaml:"custom_policy_check,omitempty"`
+	Lock                      *bool      `yaml:"lock,omitempty"`
+	PlanTTL                   *string    `yaml:"plan_ttl,omitempty"`
+	Chdir                     *bool      `yaml:"chdir,omitempty"`
+	CLIConfig                 *string    `yaml:"cli_config,omitempty"`
+	TFVars                    TFVars     `yaml:"tf_vars,omitempty"`
+	// BackendConfigs are the backend config files, by workspace, init uses
+	// for the project's workspace.
+	BackendConfigs map[string]string `yaml:"backend_configs,omitempty"`
 }
 
 func (p Project) Validate() error {
@@ -89,6 +99,11 @@ func (p Project) Validate() error {
 		validation.Field(&p.DependsOn, validation.By(DependsOn)),
 		validation.Field(&p.Name, validation.By(validName)),
 		validation.Field(&p.Branch, validation.By(branchValid)),
+		validation.Field(&p.PlanTTL, validation.By(validPlanTTL)),
+		validation.Field(&p.CLIConfig, validation.By(validCLIConfig)),
+		validation.Field(&p.TFVars, validation.By(validTFVars)),
+		validation.Field(&p.Autoplan),
+		validation.Field(&p.BackendConfigs, validation.By(validBackendConfigs)),
 	)
 }
 
@@ -156,6 +171,38 @@ func (p Project) ToValid() valid.Project {
 		v.CustomPolicyCheck = p.CustomPolicyCheck
 	}
 
+	if p.Lock != nil {
+		v.Lock = p.Lock
+	}
+
+	if p.PlanTTL != nil {
+		// Safe to ignore the error because we test it in Validate().
+		v.PlanTTL, _ = time.ParseDuration(*p.PlanTTL)
+	}
+
+	if p.Chdir != nil {
+		v.Chdir = *p.Chdir
+	}
+
+	if p.CLIConfig != nil {
+		v.CLIConfig = filepath.Clean(*p.CLIConfig)
+	}
+
+	if len(p.BackendConfigs) > 0 {
+		v.BackendConfigs = make(map[string]string, len(p.BackendConfigs))
+		for workspace, file := range p.BackendConfigs {
+			v.BackendConfigs[workspace] = filepath.Clean(file)
+		}
+	}
+
+	if len(p.TFVars) > 0 {
+		v.TFVars = make(map[string]string, len(p.TFVars))
+		for name, value := range p.TFVars {
+			// Safe to ignore the error because we test it in Validate().
+			v.TFVars[name], _ = tfVarString(value)
+		}
+	}
+
 	return v
 }
 
@@ -181,9 +228,13 @@ func validPlanReq(value interface{}) error {
 func validApplyReq(value interface{}) error {
 	reqs := value.([]string)
 	for _, r := range reqs {
-		if r != ApprovedRequirement && r != MergeableRequirement && r != UnDivergedRequirement {
+		req, condition := valid.SplitCommandReq(r)
+		if req != ApprovedRequirement && req != MergeableRequirement && req != UnDivergedRequirement {
 			return fmt.Errorf("%q is not a valid apply_requirement, only %q, %q and %q are supported", r, ApprovedRequirement, MergeableRequirement, UnDivergedRequirement)
 		}
+		if strings.Contains(r, ":") && condition != valid.OnDestroyCommandReqCondition {
+			return fmt.Errorf("%q is not a valid apply_requirement condition, only %q is supported, ex. %q", condition, valid.OnDestroyCommandReqCondition, ApprovedRequirement+":"+valid.OnDestroyCommandReqCondition)
+		}
 	}
 	return nil
 }
@@ -197,3 +248,76 @@ func validImportReq(value interface{}) error {
 	}
 	return nil
 }
+
+// validPlanTTL validates a plan_ttl, which must be a positive duration, ex.
+// "2h".
+func validPlanTTL(value interface{}) error {
+	strPtr := value.(*string)
+	if strPtr == nil {
+		return nil
+	}
+	ttl, err := time.ParseDuration(*strPtr)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid duration, ex. \"2h\" or \"30m\"", *strPtr)
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("%q must be greater than 0", *strPtr)
+	}
+	return nil
+}
+
+// validWorkspace returns true if workspace is a valid workspace name. It's
+// the same validation the -w flag of comments uses: Terraform's, plus '..'
+// isn't allowed since files are named after workspaces.
+func validWorkspace(workspace string) bool {
+	return workspace != "" && workspace == url.PathEscape(workspace) && !strings.Contains(workspace, "..")
+}
+
+// validBackendConfigs validates backend_configs, whose keys must be valid
+// workspace names and whose values must be paths relative to the project's
+// dir. That they're inside the repo is checked when they're used, like
+// cli_config.
+func validBackendConfigs(value interface{}) error {
+	configs := value.(map[string]string)
+	if configs == nil {
+		return nil
+	}
+	if len(configs) == 0 {
+		return errors.New("if set cannot be empty")
+	}
+	workspaces := make([]string, 0, len(configs))
+	for workspace := range configs {
+		workspaces = append(workspaces, workspace)
+	}
+	sort.Strings(workspaces)
+	for _, workspace := range workspaces {
+		if !validWorkspace(workspace) {
+			return fmt.Errorf("invalid workspace: %q", workspace)
+		}
+		file := configs[workspace]
+		if file == "" {
+			return fmt.Errorf("backend config of workspace %q cannot be empty", workspace)
+		}
+		if filepath.IsAbs(file) {
+			return fmt.Errorf("backend config %q of workspace %q must be a path relative to the project's dir", file, workspace)
+		}
+	}
+	return nil
+}
+
+// validCLIConfig validates a cli_config, which must be a path relative to the
+// project's dir. That it's inside the repo is checked when it's used since
+// it could be a symlink.
+func validCLIConfig(value interface{}) error {
+	strPtr := value.(*string)
+	if strPtr == nil {
+		return nil
+	}
+	if *strPtr == "" {
+		return errors.New("if set cannot be empty")
+	}
+	if filepath.IsAbs(*strPtr) {
+		return fmt.Errorf("%q must be a path relative to the project's dir", *strPtr)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package raw
+
+import (
+	"errors"
+	"fmt"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+)
+
+// ProjectOrderAlphabetical is the project_order that sorts every project.
+const ProjectOrderAlphabetical = "alphabetical"
+
+// ProjectOrder is the raw schema for a repo's project_order. It's either
+// "alphabetical" or a list of project names, ex. [network, app, edge].
+type ProjectOrder struct {
+	Alphabetical bool
+	Names        []string
+}
+
+func (p *ProjectOrder) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		if s != ProjectOrderAlphabetical {
+			return fmt.Errorf("project_order must be %q or a list of project names, got %q", ProjectOrderAlphabetical, s)
+		}
+		p.Alphabetical = true
+		return nil
+	}
+	if err := unmarshal(&p.Names); err != nil {
+		return fmt.Errorf("project_order must be %q or a list of project names", ProjectOrderAlphabetical)
+	}
+	return nil
+}
+
+func (p ProjectOrder) Validate() error {
+	namesValid := func(value interface{}) error {
+		seen := make(map[string]bool)
+		for _, n := range value.([]string) {
+			if n == "" {
+				return errors.New("project names can't be empty")
+			}
+			if seen[n] {
+				return fmt.Errorf("%q is listed more than once", n)
+			}
+			seen[n] = true
+		}
+		return nil
+	}
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Names, validation.By(namesValid)),
+	)
+}
+
+func (p ProjectOrder) ToValid() *valid.ProjectOrder {
+	return &valid.ProjectOrder{Names: p.Names}
+}
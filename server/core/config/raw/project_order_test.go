@@ -0,0 +1,95 @@
+package raw_test
+
+import (
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/runatlantis/atlantis/server/core/config/raw"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestProjectOrder_UnmarshalYAML(t *testing.T) {
+	cases := []struct {
+		description string
+		input       string
+		exp         raw.ProjectOrder
+		expErr      string
+	}{
+		{
+			description: "alphabetical",
+			input:       "alphabetical",
+			exp:         raw.ProjectOrder{Alphabetical: true},
+		},
+		{
+			description: "list of names",
+			input:       "[network, app, edge]",
+			exp:         raw.ProjectOrder{Names: []string{"network", "app", "edge"}},
+		},
+		{
+			description: "unknown order",
+			input:       "random",
+			expErr:      `project_order must be "alphabetical" or a list of project names, got "random"`,
+		},
+		{
+			description: "map",
+			input:       "network: 1",
+			expErr:      `project_order must be "alphabetical" or a list of project names`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			var o raw.ProjectOrder
+			err := unmarshalString(c.input, &o)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.exp, o)
+		})
+	}
+}
+
+func TestProjectOrder_Validate(t *testing.T) {
+	cases := []struct {
+		description string
+		input       raw.ProjectOrder
+		expErr      string
+	}{
+		{
+			description: "alphabetical",
+			input:       raw.ProjectOrder{Alphabetical: true},
+		},
+		{
+			description: "names",
+			input:       raw.ProjectOrder{Names: []string{"network", "app"}},
+		},
+		{
+			description: "empty name",
+			input:       raw.ProjectOrder{Names: []string{"network", ""}},
+			expErr:      "Names: project names can't be empty.",
+		},
+		{
+			description: "duplicate name",
+			input:       raw.ProjectOrder{Names: []string{"network", "app", "network"}},
+			expErr:      `Names: "network" is listed more than once.`,
+		},
+	}
+	validation.ErrorTag = "yaml"
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			err := c.input.Validate()
+			if c.expErr == "" {
+				Ok(t, err)
+				return
+			}
+			ErrEquals(t, c.expErr, err)
+		})
+	}
+}
+
+func TestProjectOrder_ToValid(t *testing.T) {
+	Equals(t, &valid.ProjectOrder{}, raw.ProjectOrder{Alphabetical: true}.ToValid())
+	Equals(t, &valid.ProjectOrder{Names: []string{"network", "app"}}, raw.ProjectOrder{Names: []string{"network", "app"}}.ToValid())
+}
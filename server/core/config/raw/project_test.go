@@ -123,6 +123,131 @@ func TestProject_Validate(t *testing.T) {
 			},
 			expErr: "branch: parsing: /(text/: error parsing regexp: missing closing ): `(text`.",
 		},
+		{
+			description: "plan ttl",
+			input: raw.Project{
+				Dir:     String("."),
+				PlanTTL: String("2h"),
+			},
+			expErr: "",
+		},
+		{
+			description: "invalid plan ttl",
+			input: raw.Project{
+				Dir:     String("."),
+				PlanTTL: String("2"),
+			},
+			expErr: "plan_ttl: \"2\" is not a valid duration, ex. \"2h\" or \"30m\".",
+		},
+		{
+			description: "negative plan ttl",
+			input: raw.Project{
+				Dir:     String("."),
+				PlanTTL: String("-1h"),
+			},
+			expErr: "plan_ttl: \"-1h\" must be greater than 0.",
+		},
+		{
+			description: "cli config",
+			input: raw.Project{
+				Dir:       String("."),
+				CLIConfig: String("../custom.tfrc"),
+			},
+			expErr: "",
+		},
+		{
+			description: "empty cli config",
+			input: raw.Project{
+				Dir:       String("."),
+				CLIConfig: String(""),
+			},
+			expErr: "cli_config: if set cannot be empty.",
+		},
+		{
+			description: "absolute cli config",
+			input: raw.Project{
+				Dir:       String("."),
+				CLIConfig: String("/etc/terraformrc"),
+			},
+			expErr: "cli_config: \"/etc/terraformrc\" must be a path relative to the project's dir.",
+		},
+		{
+			description: "tf vars",
+			input: raw.Project{
+				Dir: String("."),
+				TFVars: raw.TFVars{
+					"region":      "us-east-1",
+					"db_password": "${{ secrets.DB_PASSWORD }}",
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "invalid tf var name",
+			input: raw.Project{
+				Dir:    String("."),
+				TFVars: raw.TFVars{"1region": "us-east-1"},
+			},
+			expErr: "tf_vars: \"1region\" is not a valid variable name, it must start with a letter or underscore and contain only letters, digits, underscores and dashes.",
+		},
+		{
+			description: "tf var without value",
+			input: raw.Project{
+				Dir:    String("."),
+				TFVars: raw.TFVars{"region": nil},
+			},
+			expErr: "tf_vars: variable \"region\" must have a value.",
+		},
+		{
+			description: "tf var with invalid expression",
+			input: raw.Project{
+				Dir:    String("."),
+				TFVars: raw.TFVars{"region": "${{ env.REGION }}"},
+			},
+			expErr: "tf_vars: variable \"region\": invalid expression \"${{ env.REGION }}\", only ${{ secrets.NAME }} is supported.",
+		},
+		{
+			description: "backend configs",
+			input: raw.Project{
+				Dir: String("."),
+				BackendConfigs: map[string]string{
+					"staging":    "backends/staging.hcl",
+					"production": "../backends/production.hcl",
+				},
+			},
+		},
+		{
+			description: "backend configs empty",
+			input: raw.Project{
+				Dir:            String("."),
+				BackendConfigs: map[string]string{},
+			},
+			expErr: "backend_configs: if set cannot be empty.",
+		},
+		{
+			description: "backend config of invalid workspace",
+			input: raw.Project{
+				Dir:            String("."),
+				BackendConfigs: map[string]string{"my workspace": "backend.hcl"},
+			},
+			expErr: "backend_configs: invalid workspace: \"my workspace\".",
+		},
+		{
+			description: "backend config empty",
+			input: raw.Project{
+				Dir:            String("."),
+				BackendConfigs: map[string]string{"staging": ""},
+			},
+			expErr: "backend_configs: backend config of workspace \"staging\" cannot be empty.",
+		},
+		{
+			description: "backend config absolute",
+			input: raw.Project{
+				Dir:            String("."),
+				BackendConfigs: map[string]string{"staging": "/etc/backend.hcl"},
+			},
+			expErr: "backend_configs: backend config \"/etc/backend.hcl\" of workspace \"staging\" must be a path relative to the project's dir.",
+		},
 		{
 			description: "plan reqs with unsupported",
 			input: raw.Project{
@@ -179,6 +304,30 @@ func TestProject_Validate(t *testing.T) {
 			},
 			expErr: "",
 		},
+		{
+			description: "apply reqs with conditional approved requirement",
+			input: raw.Project{
+				Dir:               String("."),
+				ApplyRequirements: []string{"mergeable", "approved:on_destroy"},
+			},
+			expErr: "",
+		},
+		{
+			description: "apply reqs with unsupported condition",
+			input: raw.Project{
+				Dir:               String("."),
+				ApplyRequirements: []string{"approved:on_change"},
+			},
+			expErr: "apply_requirements: \"on_change\" is not a valid apply_requirement condition, only \"on_destroy\" is supported, ex. \"approved:on_destroy\".",
+		},
+		{
+			description: "apply reqs with unsupported conditional requirement",
+			input: raw.Project{
+				Dir:               String("."),
+				ApplyRequirements: []string{"unsupported:on_destroy"},
+			},
+			expErr: "apply_requirements: \"unsupported:on_destroy\" is not a valid apply_requirement, only \"approved\", \"mergeable\" and \"undiverged\" are supported.",
+		},
 		{
 			description: "apply reqs with mergeable requirement",
 			input: raw.Project{
@@ -299,6 +448,16 @@ func TestProject_Validate(t *testing.T) {
 			},
 			expErr: "name: \"name with spaces\" is not allowed: must contain only URL safe characters.",
 		},
+		{
+			description: "invalid autoplan workspace",
+			input: raw.Project{
+				Dir: String("."),
+				Autoplan: &raw.Autoplan{
+					Workspaces: []string{"a/b"},
+				},
+			},
+			expErr: "autoplan: (workspaces: invalid workspace: \"a/b\".).",
+		},
 		{
 			description: "project name with +",
 			input: raw.Project{
@@ -407,6 +566,86 @@ func TestProject_ToValid(t *testing.T) {
 				},
 			},
 		},
+		{
+			description: "chdir",
+			input: raw.Project{
+				Dir:   String("envs/prod"),
+				Chdir: Bool(true),
+			},
+			exp: valid.Project{
+				Dir:       "envs/prod",
+				Workspace: "default",
+				Autoplan: valid.Autoplan{
+					WhenModified: raw.DefaultAutoPlanWhenModified,
+					Enabled:      true,
+				},
+				Chdir: true,
+			},
+		},
+		{
+			description: "cli config",
+			input: raw.Project{
+				Dir:       String("envs/prod"),
+				CLIConfig: String("./config/custom.tfrc"),
+			},
+			exp: valid.Project{
+				Dir:       "envs/prod",
+				Workspace: "default",
+				Autoplan: valid.Autoplan{
+					WhenModified: raw.DefaultAutoPlanWhenModified,
+					Enabled:      true,
+				},
+				CLIConfig: "config/custom.tfrc",
+			},
+		},
+		{
+			description: "tf vars",
+			input: raw.Project{
+				Dir: String("."),
+				TFVars: raw.TFVars{
+					"region":   "us-east-1",
+					"replicas": 3,
+					"public":   false,
+					"zones":    []interface{}{"a", "b"},
+					"tags":     map[string]interface{}{"team": "infra"},
+				},
+			},
+			exp: valid.Project{
+				Dir:       ".",
+				Workspace: "default",
+				Autoplan: valid.Autoplan{
+					WhenModified: raw.DefaultAutoPlanWhenModified,
+					Enabled:      true,
+				},
+				TFVars: map[string]string{
+					"region":   "us-east-1",
+					"replicas": "3",
+					"public":   "false",
+					"zones":    `["a","b"]`,
+					"tags":     `{"team":"infra"}`,
+				},
+			},
+		},
+		{
+			description: "backend configs",
+			input: raw.Project{
+				Dir: String("."),
+				BackendConfigs: map[string]string{
+					"staging": "./backends/staging.hcl",
+				},
+			},
+			exp: valid.Project{
+				Dir:       ".",
+				Workspace: "default",
+				Autoplan: valid.Autoplan{
+					WhenModified: raw.DefaultAutoPlanWhenModified,
+					Enabled:      true,
+				},
+				BackendConfigs: map[string]string{
+					"staging": "backends/staging.hcl",
+				},
+			},
+		},
 		// Directories.
 		{
 			description: "dir set to /",
@@ -28,6 +28,7 @@ type RepoCfg struct {
 	AllowedRegexpPrefixes      []string            `yaml:"allowed_regexp_prefixes,omitempty"`
 	AbortOnExcecutionOrderFail *bool               `yaml:"abort_on_execution_order_fail,omitempty"`
 	RepoLocks                  *RepoLocks          `yaml:"repo_locks,omitempty"`
+	ProjectOrder               *ProjectOrder       `yaml:"project_order,omitempty"`
 }
 
 func (r RepoCfg) Validate() error {
@@ -41,10 +42,24 @@ func (r RepoCfg) Validate() error {
 		}
 		return nil
 	}
+	// Projects with lock: false can't be applied so pull requests with them
+	// would never be automerged.
+	automergeCompatible := func(value interface{}) error {
+		if r.Automerge == nil || !*r.Automerge {
+			return nil
+		}
+		for _, p := range value.([]Project) {
+			if p.Lock != nil && !*p.Lock {
+				return errors.New("projects with lock: false can't be applied so they can't be used with automerge")
+			}
+		}
+		return nil
+	}
 	return validation.ValidateStruct(&r,
 		validation.Field(&r.Version, validation.By(equals2)),
-		validation.Field(&r.Projects),
+		validation.Field(&r.Projects, validation.By(automergeCompatible)),
 		validation.Field(&r.Workflows),
+		validation.Field(&r.ProjectOrder),
 	)
 }
 
@@ -82,6 +97,11 @@ func (r RepoCfg) ToValid() valid.RepoCfg {
 	if r.RepoLocks != nil {
 		repoLocks = r.RepoLocks.ToValid()
 	}
+
+	var projectOrder *valid.ProjectOrder
+	if r.ProjectOrder != nil {
+		projectOrder = r.ProjectOrder.ToValid()
+	}
 	return valid.RepoCfg{
 		Version:                    *r.Version,
 		Projects:                   validProjects,
@@ -96,5 +116,6 @@ func (r RepoCfg) ToValid() valid.RepoCfg {
 		EmojiReaction:              emojiReaction,
 		AbortOnExcecutionOrderFail: abortOnExcecutionOrderFail,
 		RepoLocks:                  repoLocks,
+		ProjectOrder:               projectOrder,
 	}
 }
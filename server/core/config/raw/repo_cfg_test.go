@@ -228,6 +228,32 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			expErr: "version: only versions 2 and 3 are supported.",
 		},
+		{
+			description: "lock false with automerge",
+			input: raw.RepoCfg{
+				Version:   Int(3),
+				Automerge: Bool(true),
+				Projects: []raw.Project{
+					{
+						Dir:  String("."),
+						Lock: Bool(false),
+					},
+				},
+			},
+			expErr: "projects: projects with lock: false can't be applied so they can't be used with automerge.",
+		},
+		{
+			description: "lock false without automerge",
+			input: raw.RepoCfg{
+				Version: Int(3),
+				Projects: []raw.Project{
+					{
+						Dir:  String("."),
+						Lock: Bool(false),
+					},
+				},
+			},
+		},
 	}
 	validation.ErrorTag = "yaml"
 	for _, c := range cases {
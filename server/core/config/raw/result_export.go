@@ -0,0 +1,44 @@
+package raw
+
+import (
+	"errors"
+	"net/url"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+)
+
+// ResultExport is the raw schema for where a repo's plan and apply results
+// are exported to, in addition to pull request comments.
+type ResultExport struct {
+	Issue int    `yaml:"issue,omitempty" json:"issue,omitempty"`
+	URL   string `yaml:"url,omitempty" json:"url,omitempty"`
+}
+
+func (r ResultExport) Validate() error {
+	urlValid := func(value interface{}) error {
+		s := value.(string)
+		if s == "" {
+			return nil
+		}
+		u, err := url.Parse(s)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("must be an http or https URL")
+		}
+		return nil
+	}
+	if r.Issue == 0 && r.URL == "" {
+		return errors.New("must set issue or url")
+	}
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Issue, validation.Min(0)),
+		validation.Field(&r.URL, validation.By(urlValid)),
+	)
+}
+
+func (r ResultExport) ToValid() *valid.ResultExport {
+	return &valid.ResultExport{
+		Issue: r.Issue,
+		URL:   r.URL,
+	}
+}
@@ -4,31 +4,118 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/runatlantis/atlantis/server/core/config/valid"
 )
 
 const (
-	ExtraArgsKey        = "extra_args"
-	NameArgKey          = "name"
-	CommandArgKey       = "command"
-	ValueArgKey         = "value"
-	OutputArgKey        = "output"
-	RunStepName         = "run"
-	PlanStepName        = "plan"
-	ShowStepName        = "show"
-	PolicyCheckStepName = "policy_check"
-	ApplyStepName       = "apply"
-	InitStepName        = "init"
-	EnvStepName         = "env"
-	MultiEnvStepName    = "multienv"
-	ImportStepName      = "import"
-	StateRmStepName     = "state_rm"
+	ExtraArgsKey                  = "extra_args"
+	ExtraArgsFileKey              = "extra_args_file"
+	WorkspaceFromFileKey          = "workspace_from_file"
+	CommentArgsPositionKey        = "comment_args_position"
+	ParallelismKey                = "parallelism"
+	NameArgKey                    = "name"
+	CommandArgKey                 = "command"
+	ValueArgKey                   = "value"
+	OutputArgKey                  = "output"
+	StreamArgKey                  = "stream"
+	AlwaysArgKey                  = "always"
+	OnSuccessArgKey               = "on_success"
+	FromSSMPathArgKey             = "from_ssm_path"
+	RequireToolArgKey             = "require_tool"
+	ForEachArgKey                 = "for_each"
+	ParallelArgKey                = "parallel"
+	GoldenArgKey                  = "golden"
+	AssertFormatArgKey            = "assert_format"
+	MetricArgKey                  = "metric"
+	InputArgKey                   = "input"
+	NoNetworkArgKey               = "no_network"
+	RestoreDirArgKey              = "restore_dir"
+	RenderArgKey                  = "render"
+	RateLimitArgKey               = "rate_limit"
+	IfArgKey                      = "if"
+	RequireCleanAfterArgKey       = "require_clean_after"
+	CacheArgKey                   = "cache"
+	CommentModeArgKey             = "comment_mode"
+	MemoryLimitArgKey             = "memory_limit"
+	CPULimitArgKey                = "cpu_limit"
+	VerifyArgKey                  = "verify"
+	RequiresFilesArgKey           = "requires_files"
+	NixShellArgKey                = "nix_shell"
+	RequiresPlanArgKey            = "requires_plan"
+	JUnitArgKey                   = "junit"
+	ExitCodeVarArgKey             = "exit_code_var"
+	AllowedExitCodesArgKey        = "allowed_exit_codes"
+	OnDriftArgKey                 = "on_drift"
+	DebugEnvDiffArgKey            = "debug_env_diff"
+	RequiresProjectsSuccessArgKey = "requires_projects_success"
+	ThreadArgKey                  = "thread"
+	LinePrefixArgKey              = "line_prefix"
+	OutputFilterArgKey            = "output_filter"
+	RequireApprovalArgKey         = "require_approval"
+	ApprovalTimeoutArgKey         = "approval_timeout"
+	DiffAgainstBaseArgKey         = "diff_against_base"
+	MetricLabelArgKey             = "metric_label"
+	FatalArgKey                   = "fatal"
+	ModeArgKey                    = "mode"
+	SeparatorArgKey               = "separator"
+	MaskInArgKey                  = "mask_in"
+	CacheKeyArgKey                = "key"
+	CachePathsArgKey              = "paths"
+	VerifyFileArgKey              = "file"
+	VerifySHA256ArgKey            = "sha256"
+	VerifySHA512ArgKey            = "sha512"
+	VerifySHA256FileArgKey        = "sha256_file"
+	VerifySHA512FileArgKey        = "sha512_file"
+	RunStepName                   = "run"
+	PlanStepName                  = "plan"
+	ShowStepName                  = "show"
+	PolicyCheckStepName           = "policy_check"
+	ApplyStepName                 = "apply"
+	InitStepName                  = "init"
+	EnvStepName                   = "env"
+	MultiEnvStepName              = "multienv"
+	ImportStepName                = "import"
+	StateRmStepName               = "state_rm"
 )
 
+// metricNameRegex matches the names run steps' metrics can be tagged with.
+var metricNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// runStepArgKeys are the keys run steps support, in the order they're listed
+// in errors.
+var runStepArgKeys = []string{
+	CommandArgKey, OutputArgKey, StreamArgKey, AlwaysArgKey, OnSuccessArgKey, RequireToolArgKey,
+	ForEachArgKey, ParallelArgKey, GoldenArgKey, AssertFormatArgKey, MetricArgKey, InputArgKey,
+	NoNetworkArgKey, RestoreDirArgKey, RenderArgKey, RateLimitArgKey, IfArgKey,
+	RequireCleanAfterArgKey, CacheArgKey, CommentModeArgKey, MemoryLimitArgKey, CPULimitArgKey,
+	VerifyArgKey, RequiresFilesArgKey, NixShellArgKey, RequiresPlanArgKey, JUnitArgKey,
+	ExitCodeVarArgKey, AllowedExitCodesArgKey, OnDriftArgKey, DebugEnvDiffArgKey,
+	RequiresProjectsSuccessArgKey, ThreadArgKey, LinePrefixArgKey, OutputFilterArgKey,
+	RequireApprovalArgKey, ApprovalTimeoutArgKey, DiffAgainstBaseArgKey, MetricLabelArgKey,
+	FatalArgKey,
+}
+
+// quotedKeys lists keys quoted, ex. "a", "b" and "c".
+func quotedKeys(keys []string) string {
+	quoted := make([]string, len(keys))
+	for i, k := range keys {
+		quoted[i] = strconv.Quote(k)
+	}
+	if len(quoted) < 2 {
+		return strings.Join(quoted, "")
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " and " + quoted[len(quoted)-1]
+}
+
 // Step represents a single action/command to perform. In YAML, it can be set as
 // 1. A single string for a built-in command:
 //   - init
@@ -40,13 +127,69 @@ const (
 //     name: test
 //     command: echo 312
 //     value: value
+//   - env:
+//     from_ssm_path: /app/prod/
 //   - run:
 //     command: my custom command
 //     output: hide
+//     stream: true
+//     always: my cleanup command
+//     require_tool: [jq>=1.6]
+//   - run:
+//     command: ./lint {}
+//     for_each: find . -name '*.tf'
+//     parallel: 4
+//   - run:
+//     command: ./gen.sh
+//     golden: expected.txt
+//     assert_format: yaml
+//     metric: generate
+//   - run:
+//     command: ./tool
+//     input: "yes\n"
+//     no_network: true
+//     restore_dir: true
+//   - run:
+//     command: ./generate.sh
+//     require_clean_after: true
+//   - run:
+//     command: npm ci
+//     cache: {key: 'npm-{{ .Checksum "package-lock.json" }}', paths: [node_modules]}
+//   - run:
+//     command: ./data.sh
+//     render: table
+//   - run:
+//     command: ./notify.sh
+//     if: num_changes > 10 && workspace == 'prod'
+//   - run:
+//     command: ./build.sh
+//     memory_limit: 512M
+//     cpu_limit: 1.5
+//   - run:
+//     command: ./wipe.sh
+//     require_approval: true
+//     approval_timeout: 30m
+//   - run:
+//     command: ./report.sh
+//     diff_against_base: true
+//   - run:
+//     command: ./coverage.sh
+//     metric_label: coverage
+//   - run:
+//     command: ./check-state.sh
+//     fatal: true
 //
 // 3. A map for a built-in command and extra_args:
 //   - plan:
 //     extra_args: [-var-file=staging.tfvars]
+//   - plan:
+//     extra_args_file: plan-args.txt
+//     workspace_from_file: .atlantis-workspace
+//   - plan:
+//     extra_args: [-var-file=staging.tfvars]
+//     comment_args_position: prepend
+//   - plan:
+//     parallelism: 20
 //
 // 4. A map for a custom run command:
 //   - run: my custom command
@@ -57,8 +200,9 @@ type Step struct {
 	// Key will be set in case #1 and #3 above to the key. In case #2, there
 	// could be multiple keys (since the element is a map) so we don't set Key.
 	Key *string
-	// EnvOrRun will be set in case #2 above.
-	EnvOrRun map[string]map[string]string
+	// CommandMap will be set in case #2 above, and in case #3 when the
+	// built-in step uses keys that aren't lists (ex. extra_args_file).
+	CommandMap map[string]map[string]interface{}
 	// Map will be set in case #3 above.
 	Map map[string]map[string][]string
 	// StringVal will be set in case #4 above.
@@ -146,8 +290,8 @@ func (s Step) Validate() error {
 		return nil
 	}
 
-	envOrRunStep := func(value interface{}) error {
-		elem := value.(map[string]map[string]string)
+	commandMapStep := func(value interface{}) error {
+		elem := value.(map[string]map[string]interface{})
 		var keys []string
 		for k := range elem {
 			keys = append(keys, k)
@@ -175,10 +319,36 @@ func (s Step) Validate() error {
 			// Sort so tests can be deterministic.
 			sort.Strings(argKeys)
 
+			if _, ok := args[FromSSMPathArgKey]; ok {
+				if len(argKeys) != 1 {
+					return fmt.Errorf("env steps with a %q key can't have other keys, found %q", FromSSMPathArgKey, strings.Join(argKeys, ","))
+				}
+				path, ok := stepStringArg(args[FromSSMPathArgKey])
+				if !ok || !strings.HasPrefix(path, "/") {
+					return fmt.Errorf("env step %q option must be a path starting with \"/\"", FromSSMPathArgKey)
+				}
+				break
+			}
+
 			foundNameKey := false
 			for _, k := range argKeys {
-				if k != NameArgKey && k != CommandArgKey && k != ValueArgKey {
-					return fmt.Errorf("env steps only support keys %q, %q and %q, found key %q", NameArgKey, ValueArgKey, CommandArgKey, k)
+				if k != NameArgKey && k != CommandArgKey && k != ValueArgKey && k != MaskInArgKey {
+					return fmt.Errorf("env steps only support keys %q, %q, %q, %q and %q, found key %q", NameArgKey, ValueArgKey, CommandArgKey, MaskInArgKey, FromSSMPathArgKey, k)
+				}
+				if k == MaskInArgKey {
+					if err := validateMaskIn(args[k]); err != nil {
+						return err
+					}
+					continue
+				}
+				v, ok := stepStringArg(args[k])
+				if !ok {
+					return fmt.Errorf("env step %q option must be a string", k)
+				}
+				if k == ValueArgKey {
+					if _, err := valid.ParseSecretRefs(v); err != nil {
+						return fmt.Errorf("env step %q option: %w", k, err)
+					}
 				}
 				if k == NameArgKey {
 					foundNameKey = true
@@ -187,38 +357,388 @@ func (s Step) Validate() error {
 			if !foundNameKey {
 				return fmt.Errorf("env steps must have a %q key set", NameArgKey)
 			}
-			// If we have 3 keys at this point then they've set both command and value.
+			// If we have 3 keys at this point, not counting mask_in, then
+			// they've set both command and value.
+			if _, ok := args[MaskInArgKey]; ok {
+				argKeys = slices.DeleteFunc(argKeys, func(k string) bool { return k == MaskInArgKey })
+			}
 			if len(argKeys) != 2 {
 				return fmt.Errorf("env steps only support one of the %q or %q keys, found both",
 					ValueArgKey, CommandArgKey)
 			}
-		case RunStepName:
-			argsCopy := make(map[string]string)
-			for k, v := range args {
-				argsCopy[k] = v
+		case MultiEnvStepName:
+			if _, ok := args[CommandArgKey]; !ok {
+				return fmt.Errorf("multienv step must have a %q key set", CommandArgKey)
 			}
-			args = argsCopy
+			var argKeys []string
+			for k := range args {
+				argKeys = append(argKeys, k)
+			}
+			// Sort so tests can be deterministic.
+			sort.Strings(argKeys)
+
+			for _, k := range argKeys {
+				switch k {
+				case CommandArgKey:
+					if _, ok := stepStringArg(args[k]); !ok {
+						return fmt.Errorf("multienv step %q option must be a string", k)
+					}
+				case ModeArgKey:
+					v := args[k]
+					if !(v == valid.MultiEnvModeOverwrite || v == valid.MultiEnvModeAppend) {
+						return fmt.Errorf("multienv step %q option must be one of %q or %q", k, valid.MultiEnvModeOverwrite, valid.MultiEnvModeAppend)
+					}
+				case DebugEnvDiffArgKey:
+					if _, ok := stepBoolArg(args[k]); !ok {
+						return fmt.Errorf("multienv step %q option must be a boolean", k)
+					}
+				case SeparatorArgKey:
+					if separator, ok := stepStringArg(args[k]); !ok || separator == "" {
+						return fmt.Errorf("multienv step %q option must be a non-empty string", k)
+					}
+					if args[ModeArgKey] != valid.MultiEnvModeAppend {
+						return fmt.Errorf("multienv step %q option can only be set when %q is %q", k, ModeArgKey, valid.MultiEnvModeAppend)
+					}
+				default:
+					return fmt.Errorf("multienv steps only support keys %q, %q, %q and %q, found key %q", CommandArgKey, ModeArgKey, SeparatorArgKey, DebugEnvDiffArgKey, k)
+				}
+			}
+		case RunStepName:
 			if _, ok := args[CommandArgKey]; !ok {
 				return fmt.Errorf("run step must have a %q key set", CommandArgKey)
 			}
-			delete(args, CommandArgKey)
-			if v, ok := args[OutputArgKey]; ok {
-				if !(v == valid.PostProcessRunOutputShow || v == valid.PostProcessRunOutputHide || v == valid.PostProcessRunOutputStripRefreshing) {
-					return fmt.Errorf("run step %q option must be one of %q, %q, or %q", OutputArgKey, valid.PostProcessRunOutputShow, valid.PostProcessRunOutputHide, valid.PostProcessRunOutputStripRefreshing)
-				}
+			var argKeys []string
+			for k := range args {
+				argKeys = append(argKeys, k)
 			}
-			delete(args, OutputArgKey)
-			if len(args) > 0 {
-				var argKeys []string
-				for k := range args {
-					argKeys = append(argKeys, k)
+			// Sort so tests can be deterministic.
+			sort.Strings(argKeys)
+
+			var extraKeys []string
+			for _, k := range argKeys {
+				if !slices.Contains(runStepArgKeys, k) {
+					extraKeys = append(extraKeys, k)
+					continue
 				}
-				// Sort so tests can be deterministic.
-				sort.Strings(argKeys)
-				return fmt.Errorf("run steps only support keys %q, %q and %q, found extra keys %q", RunStepName, CommandArgKey, OutputArgKey, strings.Join(argKeys, ","))
+				switch k {
+				case CommandArgKey:
+					if _, ok := stepStringArg(args[k]); !ok {
+						return fmt.Errorf("run step %q option must be a string", k)
+					}
+				case OutputArgKey:
+					v := args[k]
+					if !(v == valid.PostProcessRunOutputShow || v == valid.PostProcessRunOutputHide || v == valid.PostProcessRunOutputStripRefreshing) {
+						return fmt.Errorf("run step %q option must be one of %q, %q, or %q", OutputArgKey, valid.PostProcessRunOutputShow, valid.PostProcessRunOutputHide, valid.PostProcessRunOutputStripRefreshing)
+					}
+				case AlwaysArgKey, OnSuccessArgKey:
+					if always, ok := stepStringArg(args[k]); !ok || always == "" {
+						return fmt.Errorf("run step %q option must be a non-empty string", k)
+					}
+				case RequireToolArgKey:
+					reqs, ok := stepStringOrListArg(args[k])
+					if !ok || len(reqs) == 0 {
+						return fmt.Errorf("run step %q option must be a string or a list of strings", k)
+					}
+					for _, req := range reqs {
+						if _, err := valid.ParseToolRequirement(req); err != nil {
+							return fmt.Errorf("run step %q option: %w", k, err)
+						}
+					}
+				case StreamArgKey:
+					stream, ok := stepBoolArg(args[k])
+					if !ok {
+						return fmt.Errorf("run step %q option must be a boolean", k)
+					}
+					if stream && args[OutputArgKey] == valid.PostProcessRunOutputHide {
+						return fmt.Errorf("run step %q option can't be set when %q is %q", k, OutputArgKey, valid.PostProcessRunOutputHide)
+					}
+					if _, ok := args[ForEachArgKey]; stream && ok {
+						return fmt.Errorf("run step %q option can't be set with %q", k, ForEachArgKey)
+					}
+				case ForEachArgKey:
+					if forEach, ok := stepStringArg(args[k]); !ok || forEach == "" {
+						return fmt.Errorf("run step %q option must be a non-empty string", k)
+					}
+					if cmd, _ := stepStringArg(args[CommandArgKey]); !strings.Contains(cmd, valid.ForEachPlaceholder) {
+						return fmt.Errorf("run step %q must contain %q to be replaced by each item of %q", CommandArgKey, valid.ForEachPlaceholder, k)
+					}
+				case ParallelArgKey:
+					if parallel, ok := stepIntArg(args[k]); !ok || parallel < 1 {
+						return fmt.Errorf("run step %q option must be an integer greater than 0", k)
+					}
+					if _, ok := args[ForEachArgKey]; !ok {
+						return fmt.Errorf("run step %q option can only be set with %q", k, ForEachArgKey)
+					}
+				case GoldenArgKey:
+					golden, ok := stepStringArg(args[k])
+					if !ok || golden == "" {
+						return fmt.Errorf("run step %q option must be a non-empty string", k)
+					}
+					if filepath.IsAbs(golden) {
+						return fmt.Errorf("run step %q option must be a path relative to the project directory, found %q", k, golden)
+					}
+				case AssertFormatArgKey:
+					v := args[k]
+					if !(v == valid.AssertFormatJSON || v == valid.AssertFormatYAML) {
+						return fmt.Errorf("run step %q option must be one of %q or %q", k, valid.AssertFormatJSON, valid.AssertFormatYAML)
+					}
+				case MetricArgKey:
+					if metric, ok := stepStringArg(args[k]); !ok || !metricNameRegex.MatchString(metric) {
+						return fmt.Errorf("run step %q option must be a name of letters, digits and underscores that doesn't start with a digit", k)
+					}
+				case InputArgKey:
+					if input, ok := stepStringArg(args[k]); !ok || input == "" {
+						return fmt.Errorf("run step %q option must be a non-empty string", k)
+					}
+				case RenderArgKey:
+					v := args[k]
+					if !(v == valid.RenderRaw || v == valid.RenderTable || v == valid.RenderCode) {
+						return fmt.Errorf("run step %q option must be one of %q, %q or %q", k, valid.RenderRaw, valid.RenderTable, valid.RenderCode)
+					}
+					if args[OutputArgKey] == valid.PostProcessRunOutputHide {
+						return fmt.Errorf("run step %q option can't be set when %q is %q", k, OutputArgKey, valid.PostProcessRunOutputHide)
+					}
+				case RateLimitArgKey:
+					rateLimit, ok := stepStringArg(args[k])
+					if !ok {
+						return fmt.Errorf("run step %q option must be a string", k)
+					}
+					if _, err := valid.ParseRateLimit(rateLimit); err != nil {
+						return fmt.Errorf("run step %q option: %w", k, err)
+					}
+				case IfArgKey:
+					cond, ok := stepStringArg(args[k])
+					if !ok {
+						return fmt.Errorf("run step %q option must be a string", k)
+					}
+					if _, err := valid.ParseStepCondition(cond); err != nil {
+						return fmt.Errorf("run step %q option: %w", k, err)
+					}
+				case CacheArgKey:
+					if _, err := stepCacheArg(args[k]); err != nil {
+						return fmt.Errorf("run step %q option: %w", k, err)
+					}
+				case VerifyArgKey:
+					if _, err := stepVerifyArg(args[k]); err != nil {
+						return fmt.Errorf("run step %q option: %w", k, err)
+					}
+				case RequiresFilesArgKey:
+					files, ok := stepStringListArg(args[k])
+					if !ok || len(files) == 0 {
+						return fmt.Errorf("run step %q option must be a non-empty list of strings", k)
+					}
+					for _, f := range files {
+						if strings.TrimSpace(f) == "" {
+							return fmt.Errorf("run step %q option can't contain an empty path", k)
+						}
+						if filepath.IsAbs(f) {
+							return fmt.Errorf("run step %q option must be paths relative to the project directory, found %q", k, f)
+						}
+					}
+				case RequiresProjectsSuccessArgKey:
+					projects, ok := stepStringListArg(args[k])
+					if !ok || len(projects) == 0 {
+						return fmt.Errorf("run step %q option must be a non-empty list of project names", k)
+					}
+					for _, p := range projects {
+						if strings.TrimSpace(p) == "" {
+							return fmt.Errorf("run step %q option can't contain an empty project name", k)
+						}
+					}
+				case NixShellArgKey:
+					nixShell, ok := stepStringArg(args[k])
+					if !ok || strings.TrimSpace(nixShell) == "" {
+						return fmt.Errorf("run step %q option must be a non-empty string", k)
+					}
+					if filepath.IsAbs(nixShell) {
+						return fmt.Errorf("run step %q option must be a path relative to the project directory, found %q", k, nixShell)
+					}
+				case JUnitArgKey:
+					report, ok := stepStringArg(args[k])
+					if !ok || strings.TrimSpace(report) == "" {
+						return fmt.Errorf("run step %q option must be a non-empty string", k)
+					}
+					if filepath.IsAbs(report) {
+						return fmt.Errorf("run step %q option must be a path relative to the project directory, found %q", k, report)
+					}
+				case ExitCodeVarArgKey:
+					if name, ok := stepStringArg(args[k]); !ok || !envVarNameRegex.MatchString(name) {
+						return fmt.Errorf("run step %q option must be an environment variable name of letters, digits and underscores that doesn't start with a digit", k)
+					}
+				case AllowedExitCodesArgKey:
+					codes, ok := stepIntListArg(args[k])
+					if !ok || len(codes) == 0 {
+						return fmt.Errorf("run step %q option must be a non-empty list of integers", k)
+					}
+					for _, code := range codes {
+						if code < 0 || code > 255 {
+							return fmt.Errorf("run step %q option must be exit codes from 0 to 255, found %d", k, code)
+						}
+					}
+				case CommentModeArgKey:
+					v := args[k]
+					if !(v == valid.CommentModeInline || v == valid.CommentModeSeparate) {
+						return fmt.Errorf("run step %q option must be one of %q or %q", k, valid.CommentModeInline, valid.CommentModeSeparate)
+					}
+					if v == valid.CommentModeSeparate && args[OutputArgKey] == valid.PostProcessRunOutputHide {
+						return fmt.Errorf("run step %q option can't be %q when %q is %q", k, valid.CommentModeSeparate, OutputArgKey, valid.PostProcessRunOutputHide)
+					}
+				case ThreadArgKey:
+					if args[k] != valid.ThreadReview {
+						return fmt.Errorf("run step %q option must be %q", k, valid.ThreadReview)
+					}
+					if args[OutputArgKey] == valid.PostProcessRunOutputHide {
+						return fmt.Errorf("run step %q option can't be set when %q is %q", k, OutputArgKey, valid.PostProcessRunOutputHide)
+					}
+					if args[CommentModeArgKey] == valid.CommentModeSeparate {
+						return fmt.Errorf("run step %q option can't be set when %q is %q", k, CommentModeArgKey, valid.CommentModeSeparate)
+					}
+				case LinePrefixArgKey:
+					prefix, ok := stepStringArg(args[k])
+					if !ok || prefix == "" || strings.Contains(prefix, "\n") {
+						return fmt.Errorf("run step %q option must be a non-empty string without newlines", k)
+					}
+					if render := args[RenderArgKey]; render != nil && render != valid.RenderRaw {
+						return fmt.Errorf("run step %q option can't be set when %q is %q", k, RenderArgKey, render)
+					}
+					if _, ok := args[ThreadArgKey]; ok {
+						return fmt.Errorf("run step %q option can't be set with %q", k, ThreadArgKey)
+					}
+				case OutputFilterArgKey:
+					filter, ok := stepStringArg(args[k])
+					if !ok || strings.TrimSpace(filter) == "" {
+						return fmt.Errorf("run step %q option must be a non-empty string", k)
+					}
+				case MemoryLimitArgKey:
+					limit, ok := stepStringArg(args[k])
+					if !ok {
+						return fmt.Errorf("run step %q option must be a string", k)
+					}
+					if _, err := valid.ParseMemoryLimit(limit); err != nil {
+						return fmt.Errorf("run step %q option: %w", k, err)
+					}
+				case CPULimitArgKey:
+					limit, ok := stepStringArg(args[k])
+					if !ok {
+						return fmt.Errorf("run step %q option must be a number", k)
+					}
+					if _, err := valid.ParseCPULimit(limit); err != nil {
+						return fmt.Errorf("run step %q option: %w", k, err)
+					}
+				case NoNetworkArgKey, RestoreDirArgKey, RequireCleanAfterArgKey, RequiresPlanArgKey, OnDriftArgKey, RequireApprovalArgKey, FatalArgKey:
+					if _, ok := stepBoolArg(args[k]); !ok {
+						return fmt.Errorf("run step %q option must be a boolean", k)
+					}
+				case ApprovalTimeoutArgKey:
+					timeout, ok := stepStringArg(args[k])
+					if !ok {
+						return fmt.Errorf("run step %q option must be a string", k)
+					}
+					if d, err := time.ParseDuration(timeout); err != nil || d <= 0 {
+						return fmt.Errorf("run step %q option must be a positive duration, ex. \"30m\" or \"1h\", found %q", k, timeout)
+					}
+					if requireApproval, _ := stepBoolArg(args[RequireApprovalArgKey]); !requireApproval {
+						return fmt.Errorf("run step %q option can only be set when %q is true", k, RequireApprovalArgKey)
+					}
+				case DebugEnvDiffArgKey:
+					debug, ok := stepBoolArg(args[k])
+					if !ok {
+						return fmt.Errorf("run step %q option must be a boolean", k)
+					}
+					if _, ok := args[ForEachArgKey]; debug && ok {
+						return fmt.Errorf("run step %q option can't be set with %q", k, ForEachArgKey)
+					}
+				case DiffAgainstBaseArgKey:
+					diff, ok := stepBoolArg(args[k])
+					if !ok {
+						return fmt.Errorf("run step %q option must be a boolean", k)
+					}
+					if !diff {
+						break
+					}
+					// The output of these is either not the command's or is
+					// already compared to something.
+					for _, other := range []string{ForEachArgKey, GoldenArgKey, StreamArgKey, DebugEnvDiffArgKey} {
+						if _, ok := args[other]; ok {
+							return fmt.Errorf("run step %q option can't be set with %q", k, other)
+						}
+					}
+				case MetricLabelArgKey:
+					label, ok := stepStringArg(args[k])
+					if !ok || strings.TrimSpace(label) == "" || strings.Contains(label, "\n") {
+						return fmt.Errorf("run step %q option must be a non-empty string without newlines", k)
+					}
+					if args[OutputArgKey] == valid.PostProcessRunOutputHide {
+						return fmt.Errorf("run step %q option can't be set when %q is %q", k, OutputArgKey, valid.PostProcessRunOutputHide)
+					}
+					if render := args[RenderArgKey]; render != nil && render != valid.RenderRaw {
+						return fmt.Errorf("run step %q option can't be set when %q is %q", k, RenderArgKey, render)
+					}
+					if args[CommentModeArgKey] == valid.CommentModeSeparate {
+						return fmt.Errorf("run step %q option can't be set when %q is %q", k, CommentModeArgKey, valid.CommentModeSeparate)
+					}
+					// The output of these isn't a single value.
+					for _, other := range []string{ForEachArgKey, DiffAgainstBaseArgKey, LinePrefixArgKey, ThreadArgKey} {
+						if _, ok := args[other]; ok {
+							return fmt.Errorf("run step %q option can't be set with %q", k, other)
+						}
+					}
+				}
+			}
+			if len(extraKeys) > 0 {
+				return fmt.Errorf("run steps only support keys %s, found extra keys %q", quotedKeys(runStepArgKeys), strings.Join(extraKeys, ","))
 			}
 		default:
-			return fmt.Errorf("%q is not a valid step type", stepName)
+			if !s.validStepName(stepName) {
+				return fmt.Errorf("%q is not a valid step type", stepName)
+			}
+			var argKeys []string
+			for k := range args {
+				argKeys = append(argKeys, k)
+			}
+			// Sort so tests can be deterministic.
+			sort.Strings(argKeys)
+
+			for _, k := range argKeys {
+				switch k {
+				case ExtraArgsKey:
+					if _, ok := stepStringListArg(args[k]); !ok {
+						return fmt.Errorf("built-in step %q option must be a list of strings", k)
+					}
+				case ExtraArgsFileKey, WorkspaceFromFileKey:
+					if k == WorkspaceFromFileKey && stepName != PlanStepName {
+						return fmt.Errorf("built-in step %q option is only supported in %s steps, found in step %s", k, PlanStepName, stepName)
+					}
+					file, ok := stepStringArg(args[k])
+					if !ok || file == "" {
+						return fmt.Errorf("built-in step %q option must be a non-empty string", k)
+					}
+					if filepath.IsAbs(file) {
+						return fmt.Errorf("built-in step %q option must be a path relative to the project directory, found %q", k, file)
+					}
+				case CommentArgsPositionKey:
+					if !stepUsesCommentArgs(stepName) {
+						return fmt.Errorf("built-in step %q option is only supported in %s, %s, %s and %s steps, found in step %s", k, PlanStepName, ApplyStepName, ImportStepName, StateRmStepName, stepName)
+					}
+					v := args[k]
+					if !(v == valid.CommentArgsAppend || v == valid.CommentArgsPrepend) {
+						return fmt.Errorf("built-in step %q option must be one of %q or %q", k, valid.CommentArgsAppend, valid.CommentArgsPrepend)
+					}
+				case ParallelismKey:
+					if stepName != PlanStepName && stepName != ApplyStepName {
+						return fmt.Errorf("built-in step %q option is only supported in %s and %s steps, found in step %s", k, PlanStepName, ApplyStepName, stepName)
+					}
+					if parallelism, ok := stepIntArg(args[k]); !ok || parallelism < 1 || parallelism > valid.MaxParallelism {
+						return fmt.Errorf("built-in step %q option must be an integer from 1 to %d", k, valid.MaxParallelism)
+					}
+					extraArgs, _ := stepStringListArg(args[ExtraArgsKey])
+					if valid.HasParallelismFlag(extraArgs) {
+						return fmt.Errorf("built-in step %q option can't be set with -parallelism in %q", k, ExtraArgsKey)
+					}
+				default:
+					return fmt.Errorf("built-in steps only support keys %q, %q, %q, %q and %q, found %q in step %s", ExtraArgsKey, ExtraArgsFileKey, WorkspaceFromFileKey, CommentArgsPositionKey, ParallelismKey, k, stepName)
+				}
+			}
 		}
 
 		return nil
@@ -251,8 +771,8 @@ func (s Step) Validate() error {
 	if len(s.Map) > 0 {
 		return validation.Validate(s.Map, validation.By(extraArgs))
 	}
-	if len(s.EnvOrRun) > 0 {
-		return validation.Validate(s.EnvOrRun, validation.By(envOrRunStep))
+	if len(s.CommandMap) > 0 {
+		return validation.Validate(s.CommandMap, validation.By(commandMapStep))
 	}
 	if len(s.StringVal) > 0 {
 		return validation.Validate(s.StringVal, validation.By(runStep))
@@ -268,17 +788,97 @@ func (s Step) ToValid() valid.Step {
 		}
 	}
 
-	// This will trigger in case #2 (see Step docs).
-	if len(s.EnvOrRun) > 0 {
+	// This will trigger in case #2 and #3 (see Step docs).
+	if len(s.CommandMap) > 0 {
 		// After validation we assume there's only one key and it's a valid
 		// step name so we just use the first one.
-		for stepName, stepArgs := range s.EnvOrRun {
+		for stepName, stepArgs := range s.CommandMap {
+			extraArgs, _ := stepStringListArg(stepArgs[ExtraArgsKey])
 			step := valid.Step{
-				StepName:    stepName,
-				EnvVarName:  stepArgs[NameArgKey],
-				RunCommand:  stepArgs[CommandArgKey],
-				EnvVarValue: stepArgs[ValueArgKey],
-				Output:      valid.PostProcessRunOutputOption(stepArgs[OutputArgKey]),
+				StepName:            stepName,
+				ExtraArgs:           extraArgs,
+				ExtraArgsFile:       stepStringArgOrEmpty(stepArgs[ExtraArgsFileKey]),
+				WorkspaceFromFile:   stepStringArgOrEmpty(stepArgs[WorkspaceFromFileKey]),
+				CommentArgsPosition: valid.CommentArgsPositionOption(stepStringArgOrEmpty(stepArgs[CommentArgsPositionKey])),
+				EnvVarName:          stepStringArgOrEmpty(stepArgs[NameArgKey]),
+				RunCommand:          stepStringArgOrEmpty(stepArgs[CommandArgKey]),
+				Always:              stepStringArgOrEmpty(stepArgs[AlwaysArgKey]),
+				OnSuccess:           stepStringArgOrEmpty(stepArgs[OnSuccessArgKey]),
+				EnvVarValue:         stepStringArgOrEmpty(stepArgs[ValueArgKey]),
+				SSMPath:             stepStringArgOrEmpty(stepArgs[FromSSMPathArgKey]),
+				ForEach:             stepStringArgOrEmpty(stepArgs[ForEachArgKey]),
+				Golden:              stepStringArgOrEmpty(stepArgs[GoldenArgKey]),
+				NixShell:            stepStringArgOrEmpty(stepArgs[NixShellArgKey]),
+				JUnit:               stepStringArgOrEmpty(stepArgs[JUnitArgKey]),
+				ExitCodeVar:         stepStringArgOrEmpty(stepArgs[ExitCodeVarArgKey]),
+				AssertFormat:        valid.AssertFormatOption(stepStringArgOrEmpty(stepArgs[AssertFormatArgKey])),
+				Render:              valid.RenderOption(stepStringArgOrEmpty(stepArgs[RenderArgKey])),
+				CommentMode:         valid.CommentModeOption(stepStringArgOrEmpty(stepArgs[CommentModeArgKey])),
+				Thread:              valid.ThreadOption(stepStringArgOrEmpty(stepArgs[ThreadArgKey])),
+				LinePrefix:          stepStringArgOrEmpty(stepArgs[LinePrefixArgKey]),
+				OutputFilter:        stepStringArgOrEmpty(stepArgs[OutputFilterArgKey]),
+				Metric:              stepStringArgOrEmpty(stepArgs[MetricArgKey]),
+				MetricLabel:         stepStringArgOrEmpty(stepArgs[MetricLabelArgKey]),
+				Stdin:               stepStringArgOrEmpty(stepArgs[InputArgKey]),
+				Output:              valid.PostProcessRunOutputOption(stepStringArgOrEmpty(stepArgs[OutputArgKey])),
+			}
+			step.Stream, _ = stepBoolArg(stepArgs[StreamArgKey])
+			step.NoNetwork, _ = stepBoolArg(stepArgs[NoNetworkArgKey])
+			step.RestoreDir, _ = stepBoolArg(stepArgs[RestoreDirArgKey])
+			step.RequireCleanAfter, _ = stepBoolArg(stepArgs[RequireCleanAfterArgKey])
+			step.RequiresPlan, _ = stepBoolArg(stepArgs[RequiresPlanArgKey])
+			step.OnDrift, _ = stepBoolArg(stepArgs[OnDriftArgKey])
+			step.DebugEnvDiff, _ = stepBoolArg(stepArgs[DebugEnvDiffArgKey])
+			step.RequireApproval, _ = stepBoolArg(stepArgs[RequireApprovalArgKey])
+			step.Fatal, _ = stepBoolArg(stepArgs[FatalArgKey])
+			step.DiffAgainstBase, _ = stepBoolArg(stepArgs[DiffAgainstBaseArgKey])
+			if timeout := stepStringArgOrEmpty(stepArgs[ApprovalTimeoutArgKey]); timeout != "" {
+				step.ApprovalTimeout, _ = time.ParseDuration(timeout)
+			}
+			step.Parallel, _ = stepIntArg(stepArgs[ParallelArgKey])
+			step.Parallelism, _ = stepIntArg(stepArgs[ParallelismKey])
+			if rateLimit := stepStringArgOrEmpty(stepArgs[RateLimitArgKey]); rateLimit != "" {
+				limit, _ := valid.ParseRateLimit(rateLimit)
+				step.RateLimit = &limit
+			}
+			if cond := stepStringArgOrEmpty(stepArgs[IfArgKey]); cond != "" {
+				step.If, _ = valid.ParseStepCondition(cond)
+			}
+			if _, ok := stepArgs[CacheArgKey]; ok {
+				cache, _ := stepCacheArg(stepArgs[CacheArgKey])
+				step.Cache = &cache
+			}
+			if _, ok := stepArgs[VerifyArgKey]; ok {
+				verify, _ := stepVerifyArg(stepArgs[VerifyArgKey])
+				step.Verify = &verify
+			}
+			if limit := stepStringArgOrEmpty(stepArgs[MemoryLimitArgKey]); limit != "" {
+				step.MemoryLimit, _ = valid.ParseMemoryLimit(limit)
+			}
+			if limit := stepStringArgOrEmpty(stepArgs[CPULimitArgKey]); limit != "" {
+				step.CPULimit, _ = valid.ParseCPULimit(limit)
+			}
+			step.RequiresFiles, _ = stepStringListArg(stepArgs[RequiresFilesArgKey])
+			step.RequiresProjectsSuccess, _ = stepStringListArg(stepArgs[RequiresProjectsSuccessArgKey])
+			step.AllowedExitCodes, _ = stepIntListArg(stepArgs[AllowedExitCodesArgKey])
+			reqs, _ := stepStringOrListArg(stepArgs[RequireToolArgKey])
+			for _, req := range reqs {
+				toolReq, _ := valid.ParseToolRequirement(req)
+				step.RequireTools = append(step.RequireTools, toolReq)
+			}
+			if step.StepName == MultiEnvStepName {
+				step.MultiEnvMode = valid.MultiEnvModeOption(stepStringArgOrEmpty(stepArgs[ModeArgKey]))
+				step.MultiEnvSeparator = stepStringArgOrEmpty(stepArgs[SeparatorArgKey])
+				if step.MultiEnvMode == valid.MultiEnvModeAppend && step.MultiEnvSeparator == "" {
+					step.MultiEnvSeparator = valid.DefaultMultiEnvSeparator
+				}
+			}
+			if step.StepName == EnvStepName && step.SSMPath == "" {
+				step.MaskInComment, step.MaskInLog = true, true
+				if maskIn, ok := stepStringListArg(stepArgs[MaskInArgKey]); ok {
+					step.MaskInComment = slices.Contains(maskIn, valid.MaskInComment)
+					step.MaskInLog = slices.Contains(maskIn, valid.MaskInLog)
+				}
 			}
 			if step.StepName == RunStepName && step.Output == "" {
 				step.Output = valid.PostProcessRunOutputShow
@@ -348,15 +948,20 @@ func (s *Step) unmarshalGeneric(unmarshal func(interface{}) error) error {
 		return nil
 	}
 
-	// This represents an env step, ex:
+	// This represents an env or run step, or a built-in step with options
+	// that aren't lists, ex:
 	//   env:
 	//     name: k
 	//     value: hi //optional
 	//     command: exec
-	var envStep map[string]map[string]string
-	err = unmarshal(&envStep)
+	//   env:
+	//     from_ssm_path: /app/prod/
+	//   plan:
+	//     extra_args_file: plan-args.txt
+	var commandStep map[string]map[string]interface{}
+	err = unmarshal(&commandStep)
 	if err == nil {
-		s.EnvOrRun = envStep
+		s.CommandMap = commandStep
 		return nil
 	}
 
@@ -379,8 +984,8 @@ func (s Step) marshalGeneric() (interface{}, error) {
 		return s.StringVal, nil
 	} else if len(s.Map) != 0 {
 		return s.Map, nil
-	} else if len(s.EnvOrRun) != 0 {
-		return s.EnvOrRun, nil
+	} else if len(s.CommandMap) != 0 {
+		return s.CommandMap, nil
 	} else if s.Key != nil {
 		return s.Key, nil
 	}
@@ -389,3 +994,202 @@ func (s Step) marshalGeneric() (interface{}, error) {
 	// unexpected behavior.
 	return nil, nil
 }
+
+// stepStringArg returns the string form of a scalar step option. YAML and JSON
+// decode scalars such as `true` or `4` into their native types so we accept
+// those too.
+func stepStringArg(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(t), true
+	}
+	return "", false
+}
+
+// stepStringArgOrEmpty is like stepStringArg but returns an empty string if v
+// isn't set or isn't a scalar. It should only be used after validation.
+func stepStringArgOrEmpty(v interface{}) string {
+	str, _ := stepStringArg(v)
+	return str
+}
+
+// stepUsesCommentArgs returns true if the built-in step stepName passes the
+// extra args from the comment to Terraform.
+func stepUsesCommentArgs(stepName string) bool {
+	return stepName == PlanStepName || stepName == ApplyStepName || stepName == ImportStepName || stepName == StateRmStepName
+}
+
+// stepCacheArg returns the cache of a run step from its cache option, a map
+// with a key template and a list of paths.
+func stepCacheArg(v interface{}) (valid.StepCache, error) {
+	args, ok := stepMapArg(v)
+	if !ok {
+		return valid.StepCache{}, fmt.Errorf("must be a map with %q and %q keys", CacheKeyArgKey, CachePathsArgKey)
+	}
+	for k := range args {
+		if k != CacheKeyArgKey && k != CachePathsArgKey {
+			return valid.StepCache{}, fmt.Errorf("only supports keys %q and %q, found %q", CacheKeyArgKey, CachePathsArgKey, k)
+		}
+	}
+	key, ok := stepStringArg(args[CacheKeyArgKey])
+	if !ok {
+		return valid.StepCache{}, fmt.Errorf("%q must be a string", CacheKeyArgKey)
+	}
+	paths, ok := stepStringOrListArg(args[CachePathsArgKey])
+	if !ok {
+		return valid.StepCache{}, fmt.Errorf("%q must be a string or a list of strings", CachePathsArgKey)
+	}
+	return valid.ParseStepCache(key, paths)
+}
+
+// stepVerifyArg returns the checksum a run step verifies from its verify
+// option, a map with the file and either its checksum or a file containing
+// it.
+func stepVerifyArg(v interface{}) (valid.StepVerify, error) {
+	args, ok := stepMapArg(v)
+	if !ok {
+		return valid.StepVerify{}, fmt.Errorf("must be a map with a %q key and one of %q, %q, %q or %q", VerifyFileArgKey, VerifySHA256ArgKey, VerifySHA512ArgKey, VerifySHA256FileArgKey, VerifySHA512FileArgKey)
+	}
+	checksumKeys := map[string]string{
+		VerifySHA256ArgKey:     valid.ChecksumSHA256,
+		VerifySHA512ArgKey:     valid.ChecksumSHA512,
+		VerifySHA256FileArgKey: valid.ChecksumSHA256,
+		VerifySHA512FileArgKey: valid.ChecksumSHA512,
+	}
+	var checksumKey string
+	for k := range args {
+		if _, ok := checksumKeys[k]; ok {
+			if checksumKey != "" {
+				return valid.StepVerify{}, fmt.Errorf("can only have one of %q, %q, %q or %q", VerifySHA256ArgKey, VerifySHA512ArgKey, VerifySHA256FileArgKey, VerifySHA512FileArgKey)
+			}
+			checksumKey = k
+		} else if k != VerifyFileArgKey {
+			return valid.StepVerify{}, fmt.Errorf("only supports keys %q, %q, %q, %q and %q, found %q", VerifyFileArgKey, VerifySHA256ArgKey, VerifySHA512ArgKey, VerifySHA256FileArgKey, VerifySHA512FileArgKey, k)
+		}
+	}
+	file, ok := stepStringArg(args[VerifyFileArgKey])
+	if !ok {
+		return valid.StepVerify{}, fmt.Errorf("%q must be a string", VerifyFileArgKey)
+	}
+	if checksumKey == "" {
+		return valid.StepVerify{}, fmt.Errorf("must have one of %q, %q, %q or %q", VerifySHA256ArgKey, VerifySHA512ArgKey, VerifySHA256FileArgKey, VerifySHA512FileArgKey)
+	}
+	checksum, ok := stepStringArg(args[checksumKey])
+	if !ok {
+		return valid.StepVerify{}, fmt.Errorf("%q must be a string", checksumKey)
+	}
+	if checksumKey == VerifySHA256FileArgKey || checksumKey == VerifySHA512FileArgKey {
+		return valid.ParseStepVerify(file, checksumKeys[checksumKey], "", checksum)
+	}
+	return valid.ParseStepVerify(file, checksumKeys[checksumKey], checksum, "")
+}
+
+// stepMapArg returns the map form of a step option. YAML decodes maps with
+// interface{} keys so they're converted to strings.
+func stepMapArg(v interface{}) (map[string]interface{}, bool) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return t, true
+	case map[interface{}]interface{}:
+		args := make(map[string]interface{})
+		for k, v := range t {
+			args[fmt.Sprint(k)] = v
+		}
+		return args, true
+	}
+	return nil, false
+}
+
+// stepBoolArg returns the boolean form of a step option. Like stepStringArg it
+// also accepts strings such as "true".
+func stepBoolArg(v interface{}) (bool, bool) {
+	switch t := v.(type) {
+	case bool:
+		return t, true
+	case string:
+		b, err := strconv.ParseBool(t)
+		return b, err == nil
+	}
+	return false, false
+}
+
+// stepIntArg returns the integer form of a step option. Like stepStringArg it
+// also accepts strings such as "4".
+func stepIntArg(v interface{}) (int, bool) {
+	switch t := v.(type) {
+	case int:
+		return t, true
+	case int64:
+		return int(t), true
+	case uint64:
+		return int(t), true
+	case float64:
+		return int(t), t == float64(int(t))
+	case string:
+		i, err := strconv.Atoi(t)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// stepStringListArg returns the string form of a list step option.
+func stepStringListArg(v interface{}) ([]string, bool) {
+	switch t := v.(type) {
+	case []string:
+		return t, true
+	case []interface{}:
+		var strs []string
+		for _, e := range t {
+			str, ok := stepStringArg(e)
+			if !ok {
+				return nil, false
+			}
+			strs = append(strs, str)
+		}
+		return strs, true
+	}
+	return nil, false
+}
+
+// stepIntListArg returns the integer form of a list step option.
+func stepIntListArg(v interface{}) ([]int, bool) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	var ints []int
+	for _, e := range list {
+		i, ok := stepIntArg(e)
+		if !ok {
+			return nil, false
+		}
+		ints = append(ints, i)
+	}
+	return ints, true
+}
+
+// validateMaskIn returns an error if v isn't a non-empty list of where the
+// value of an env step is masked.
+func validateMaskIn(v interface{}) error {
+	targets, ok := stepStringListArg(v)
+	if !ok || len(targets) == 0 {
+		return fmt.Errorf("env step %q option must be a non-empty list of %q or %q", MaskInArgKey, valid.MaskInComment, valid.MaskInLog)
+	}
+	for _, target := range targets {
+		if target != valid.MaskInComment && target != valid.MaskInLog {
+			return fmt.Errorf("env step %q option must be a non-empty list of %q or %q, found %q", MaskInArgKey, valid.MaskInComment, valid.MaskInLog, target)
+		}
+	}
+	return nil
+}
+
+// stepStringOrListArg returns a step option that can be either a single string
+// or a list of strings as a list.
+func stepStringOrListArg(v interface{}) ([]string, bool) {
+	if str, ok := stepStringArg(v); ok {
+		return []string{str}, true
+	}
+	return stepStringListArg(v)
+}
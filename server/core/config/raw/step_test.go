@@ -2,7 +2,9 @@ package raw_test
 
 import (
 	"testing"
+	"time"
 
+	"github.com/hashicorp/go-version"
 	"github.com/runatlantis/atlantis/server/core/config/raw"
 	"github.com/runatlantis/atlantis/server/core/config/valid"
 	. "github.com/runatlantis/atlantis/testing"
@@ -81,7 +83,7 @@ env:
   value: direct_value
   name: test`,
 			exp: raw.Step{
-				EnvOrRun: EnvOrRunType{
+				CommandMap: CommandMapType{
 					"env": {
 						"value": "direct_value",
 						"name":  "test",
@@ -96,7 +98,7 @@ env:
   command: echo 123
   name: test`,
 			exp: raw.Step{
-				EnvOrRun: EnvOrRunType{
+				CommandMap: CommandMapType{
 					"env": {
 						"command": "echo 123",
 						"name":    "test",
@@ -134,21 +136,55 @@ key: value`,
 			description: "empty",
 			input:       "",
 			exp: raw.Step{
-				Key:       nil,
-				Map:       nil,
-				StringVal: nil,
-				EnvOrRun:  nil,
+				Key:        nil,
+				Map:        nil,
+				StringVal:  nil,
+				CommandMap: nil,
 			},
 		},
 
-		// Errors
+		// Built-in steps with non-list options
+		{
+			description: "extra_args_file",
+			input: `
+plan:
+  extra_args_file: plan-args.txt`,
+			exp: raw.Step{
+				CommandMap: CommandMapType{
+					"plan": {
+						"extra_args_file": "plan-args.txt",
+					},
+				},
+			},
+		},
+		{
+			description: "extra_args and extra_args_file",
+			input: `
+plan:
+  extra_args: [arg1, arg2]
+  extra_args_file: plan-args.txt`,
+			exp: raw.Step{
+				CommandMap: CommandMapType{
+					"plan": {
+						"extra_args":      []interface{}{"arg1", "arg2"},
+						"extra_args_file": "plan-args.txt",
+					},
+				},
+			},
+		},
 		{
 			description: "extra args style no slice strings",
 			input: `
 key:
   value:
     another: map`,
-			expErr: "yaml: unmarshal errors:\n  line 3: cannot unmarshal !!map into string",
+			exp: raw.Step{
+				CommandMap: CommandMapType{
+					"key": {
+						"value": map[string]interface{}{"another": "map"},
+					},
+				},
+			},
 		},
 	}
 
@@ -227,7 +263,7 @@ func TestStep_Validate(t *testing.T) {
 		{
 			description: "env",
 			input: raw.Step{
-				EnvOrRun: EnvOrRunType{
+				CommandMap: CommandMapType{
 					"env": {
 						"name":    "test",
 						"command": "echo 123",
@@ -283,7 +319,7 @@ func TestStep_Validate(t *testing.T) {
 		{
 			description: "multiple keys in env",
 			input: raw.Step{
-				EnvOrRun: EnvOrRunType{
+				CommandMap: CommandMapType{
 					"key1": nil,
 					"key2": nil,
 				},
@@ -312,7 +348,7 @@ func TestStep_Validate(t *testing.T) {
 		{
 			description: "invalid key in env",
 			input: raw.Step{
-				EnvOrRun: EnvOrRunType{
+				CommandMap: CommandMapType{
 					"invalid": nil,
 				},
 			},
@@ -351,236 +387,2549 @@ func TestStep_Validate(t *testing.T) {
 			expErr: "built-in steps only support a single extra_args key, found 2: invalid,zzzzzzz",
 		},
 		{
-			description: "env step with no name key set",
+			description: "extra_args_file not a string",
 			input: raw.Step{
-				EnvOrRun: EnvOrRunType{
-					"env": {
-						"value": "value",
+				CommandMap: CommandMapType{
+					"plan": {
+						"extra_args_file": []interface{}{"a.txt"},
 					},
 				},
 			},
-			expErr: "env steps must have a \"name\" key set",
+			expErr: "built-in step \"extra_args_file\" option must be a non-empty string",
 		},
 		{
-			description: "env step with invalid key",
+			description: "extra_args_file absolute path",
 			input: raw.Step{
-				EnvOrRun: EnvOrRunType{
-					"env": {
-						"abc":      "",
-						"invalid2": "",
+				CommandMap: CommandMapType{
+					"plan": {
+						"extra_args_file": "/etc/passwd",
 					},
 				},
 			},
-			expErr: "env steps only support keys \"name\", \"value\" and \"command\", found key \"abc\"",
+			expErr: "built-in step \"extra_args_file\" option must be a path relative to the project directory, found \"/etc/passwd\"",
 		},
 		{
-			description: "env step with both command and value set",
+			description: "workspace_from_file",
 			input: raw.Step{
-				EnvOrRun: EnvOrRunType{
-					"env": {
-						"name":    "name",
-						"command": "command",
-						"value":   "value",
+				CommandMap: CommandMapType{
+					"plan": {
+						"workspace_from_file": ".atlantis-workspace",
 					},
 				},
 			},
-			expErr: "env steps only support one of the \"value\" or \"command\" keys, found both",
+			expErr: "",
 		},
 		{
-			// For atlantis.yaml v2, this wouldn't parse, but now there should
-			// be no error.
-			description: "unparseable shell command",
+			description: "workspace_from_file empty",
 			input: raw.Step{
-				StringVal: map[string]string{
-					"run": "my 'c",
+				CommandMap: CommandMapType{
+					"plan": {
+						"workspace_from_file": "",
+					},
 				},
 			},
+			expErr: "built-in step \"workspace_from_file\" option must be a non-empty string",
 		},
-	}
-	for _, c := range cases {
-		t.Run(c.description, func(t *testing.T) {
-			err := c.input.Validate()
-			if c.expErr == "" {
-				Ok(t, err)
-				return
-			}
-			ErrEquals(t, c.expErr, err)
-		})
-	}
-}
-
-func TestStep_ToValid(t *testing.T) {
-	cases := []struct {
-		description string
-		input       raw.Step
-		exp         valid.Step
-	}{
 		{
-			description: "init step",
+			description: "workspace_from_file not in plan step",
 			input: raw.Step{
-				Key: String("init"),
-			},
-			exp: valid.Step{
-				StepName: "init",
+				CommandMap: CommandMapType{
+					"init": {
+						"workspace_from_file": ".atlantis-workspace",
+					},
+				},
 			},
+			expErr: "built-in step \"workspace_from_file\" option is only supported in plan steps, found in step init",
 		},
 		{
-			description: "plan step",
+			description: "comment_args_position",
 			input: raw.Step{
-				Key: String("plan"),
-			},
-			exp: valid.Step{
-				StepName: "plan",
+				CommandMap: CommandMapType{
+					"apply": {
+						"extra_args":            []interface{}{"-lock-timeout=5m"},
+						"comment_args_position": "prepend",
+					},
+				},
 			},
+			expErr: "",
 		},
 		{
-			description: "policy_check step",
+			description: "comment_args_position invalid",
 			input: raw.Step{
-				Key: String("policy_check"),
+				CommandMap: CommandMapType{
+					"plan": {
+						"comment_args_position": "middle",
+					},
+				},
 			},
-			exp: valid.Step{
-				StepName: "policy_check",
+			expErr: "built-in step \"comment_args_position\" option must be one of \"append\" or \"prepend\"",
+		},
+		{
+			description: "comment_args_position not in step using comment args",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"init": {
+						"comment_args_position": "prepend",
+					},
+				},
 			},
+			expErr: "built-in step \"comment_args_position\" option is only supported in plan, apply, import and state_rm steps, found in step init",
 		},
 		{
-			description: "apply step",
+			description: "parallelism",
 			input: raw.Step{
-				Key: String("apply"),
+				CommandMap: CommandMapType{
+					"apply": {
+						"extra_args":  []interface{}{"-lock-timeout=5m"},
+						"parallelism": 20,
+					},
+				},
 			},
-			exp: valid.Step{
-				StepName: "apply",
+			expErr: "",
+		},
+		{
+			description: "parallelism out of range",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"plan": {
+						"parallelism": 0,
+					},
+				},
 			},
+			expErr: "built-in step \"parallelism\" option must be an integer from 1 to 256",
 		},
 		{
-			description: "env step",
+			description: "parallelism not an integer",
 			input: raw.Step{
-				EnvOrRun: EnvOrRunType{
-					"env": {
-						"name":    "test",
-						"command": "echo 123",
+				CommandMap: CommandMapType{
+					"plan": {
+						"parallelism": "lots",
 					},
 				},
 			},
-			exp: valid.Step{
-				StepName:   "env",
-				RunCommand: "echo 123",
-				EnvVarName: "test",
+			expErr: "built-in step \"parallelism\" option must be an integer from 1 to 256",
+		},
+		{
+			description: "parallelism not in plan or apply",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"init": {
+						"parallelism": 20,
+					},
+				},
 			},
+			expErr: "built-in step \"parallelism\" option is only supported in plan and apply steps, found in step init",
 		},
 		{
-			description: "import step",
+			description: "parallelism with -parallelism in extra_args",
 			input: raw.Step{
-				Key: String("import"),
+				CommandMap: CommandMapType{
+					"plan": {
+						"extra_args":  []interface{}{"-parallelism=5"},
+						"parallelism": 20,
+					},
+				},
 			},
-			exp: valid.Step{
-				StepName: "import",
+			expErr: "built-in step \"parallelism\" option can't be set with -parallelism in \"extra_args\"",
+		},
+		{
+			description: "extra_args not a list with extra_args_file",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"plan": {
+						"extra_args":      "arg1",
+						"extra_args_file": "a.txt",
+					},
+				},
 			},
+			expErr: "built-in step \"extra_args\" option must be a list of strings",
 		},
 		{
-			description: "init extra_args",
+			description: "invalid key with extra_args_file",
 			input: raw.Step{
-				Map: MapType{
+				CommandMap: CommandMapType{
 					"init": {
-						"extra_args": []string{"arg1", "arg2"},
+						"extra_args_file": "a.txt",
+						"invalid":         "",
 					},
 				},
 			},
-			exp: valid.Step{
-				StepName:  "init",
-				ExtraArgs: []string{"arg1", "arg2"},
+			expErr: "built-in steps only support keys \"extra_args\", \"extra_args_file\", \"workspace_from_file\", \"comment_args_position\" and \"parallelism\", found \"invalid\" in step init",
+		},
+		{
+			description: "multienv with extra_args_file",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"multienv": {
+						"extra_args_file": "a.txt",
+					},
+				},
 			},
+			expErr: "multienv step must have a \"command\" key set",
 		},
 		{
-			description: "plan extra_args",
+			description: "multienv with append mode",
 			input: raw.Step{
-				Map: MapType{
-					"plan": {
-						"extra_args": []string{"arg1", "arg2"},
+				CommandMap: CommandMapType{
+					"multienv": {
+						"command":   "./paths.sh",
+						"mode":      "append",
+						"separator": " ",
 					},
 				},
 			},
-			exp: valid.Step{
-				StepName:  "plan",
-				ExtraArgs: []string{"arg1", "arg2"},
+			expErr: "",
+		},
+		{
+			description: "multienv with invalid mode",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"multienv": {
+						"command": "./paths.sh",
+						"mode":    "prepend",
+					},
+				},
 			},
+			expErr: "multienv step \"mode\" option must be one of \"overwrite\" or \"append\"",
 		},
 		{
-			description: "policy_check extra_args",
+			description: "multienv with separator without append mode",
 			input: raw.Step{
-				Map: MapType{
-					"policy_check": {
-						"extra_args": []string{"arg1", "arg2"},
+				CommandMap: CommandMapType{
+					"multienv": {
+						"command":   "./paths.sh",
+						"separator": ":",
 					},
 				},
 			},
-			exp: valid.Step{
-				StepName:  "policy_check",
-				ExtraArgs: []string{"arg1", "arg2"},
+			expErr: "multienv step \"separator\" option can only be set when \"mode\" is \"append\"",
+		},
+		{
+			description: "multienv with extra key",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"multienv": {
+						"command": "./paths.sh",
+						"output":  "hide",
+					},
+				},
 			},
+			expErr: "multienv steps only support keys \"command\", \"mode\", \"separator\" and \"debug_env_diff\", found key \"output\"",
 		},
 		{
-			description: "apply extra_args",
+			description: "extra_args_file",
 			input: raw.Step{
-				Map: MapType{
-					"apply": {
-						"extra_args": []string{"arg1", "arg2"},
+				CommandMap: CommandMapType{
+					"plan": {
+						"extra_args":      []interface{}{"arg1"},
+						"extra_args_file": "a.txt",
 					},
 				},
 			},
-			exp: valid.Step{
-				StepName:  "apply",
-				ExtraArgs: []string{"arg1", "arg2"},
+		},
+		{
+			description: "env step with no name key set",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"env": {
+						"value": "value",
+					},
+				},
 			},
+			expErr: "env steps must have a \"name\" key set",
 		},
 		{
-			description: "import extra_args",
+			description: "env step with invalid key",
 			input: raw.Step{
-				Map: MapType{
-					"import": {
-						"extra_args": []string{"arg1", "arg2"},
+				CommandMap: CommandMapType{
+					"env": {
+						"abc":      "",
+						"invalid2": "",
 					},
 				},
 			},
-			exp: valid.Step{
-				StepName:  "import",
-				ExtraArgs: []string{"arg1", "arg2"},
+			expErr: "env steps only support keys \"name\", \"value\", \"command\", \"mask_in\" and \"from_ssm_path\", found key \"abc\"",
+		},
+		{
+			description: "env step with secret expression",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"env": {
+						"name":  "TOKEN",
+						"value": "${{ secrets.API_TOKEN }}",
+					},
+				},
 			},
 		},
 		{
-			description: "run step",
+			description: "env step with invalid expression",
 			input: raw.Step{
-				StringVal: map[string]string{
-					"run": "my 'run command'",
+				CommandMap: CommandMapType{
+					"env": {
+						"name":  "TOKEN",
+						"value": "${{ env.API_TOKEN }}",
+					},
 				},
 			},
-			exp: valid.Step{
-				StepName:   "run",
-				RunCommand: "my 'run command'",
+			expErr: "env step \"value\" option: invalid expression \"${{ env.API_TOKEN }}\", only ${{ secrets.NAME }} is supported",
+		},
+		{
+			description: "env step with both command and value set",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"env": {
+						"name":    "name",
+						"command": "command",
+						"value":   "value",
+					},
+				},
 			},
+			expErr: "env steps only support one of the \"value\" or \"command\" keys, found both",
 		},
 		{
-			description: "run step with output",
+			description: "env step with mask_in",
 			input: raw.Step{
-				EnvOrRun: EnvOrRunType{
-					"run": {
-						"command": "my 'run command'",
-						"output":  "hide",
+				CommandMap: CommandMapType{
+					"env": {
+						"name":    "name",
+						"command": "command",
+						"mask_in": []interface{}{"comment", "log"},
 					},
 				},
 			},
-			exp: valid.Step{
-				StepName:   "run",
-				RunCommand: "my 'run command'",
-				Output:     "hide",
+		},
+		{
+			description: "env step with empty mask_in",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"env": {
+						"name":    "name",
+						"value":   "value",
+						"mask_in": []interface{}{},
+					},
+				},
 			},
+			expErr: "env step \"mask_in\" option must be a non-empty list of \"comment\" or \"log\"",
 		},
-	}
-	for _, c := range cases {
-		t.Run(c.description, func(t *testing.T) {
-			Equals(t, c.exp, c.input.ToValid())
-		})
-	}
-}
-
-type MapType map[string]map[string][]string
-type EnvOrRunType map[string]map[string]string
+		{
+			description: "env step with invalid mask_in",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"env": {
+						"name":    "name",
+						"value":   "value",
+						"mask_in": []interface{}{"comment", "webhook"},
+					},
+				},
+			},
+			expErr: "env step \"mask_in\" option must be a non-empty list of \"comment\" or \"log\", found \"webhook\"",
+		},
+		{
+			description: "env step with from_ssm_path",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"env": {
+						"from_ssm_path": "/app/prod/",
+					},
+				},
+			},
+		},
+		{
+			description: "env step with from_ssm_path and name",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"env": {
+						"from_ssm_path": "/app/prod/",
+						"name":          "name",
+					},
+				},
+			},
+			expErr: "env steps with a \"from_ssm_path\" key can't have other keys, found \"from_ssm_path,name\"",
+		},
+		{
+			description: "env step with relative from_ssm_path",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"env": {
+						"from_ssm_path": "app/prod/",
+					},
+				},
+			},
+			expErr: "env step \"from_ssm_path\" option must be a path starting with \"/\"",
+		},
+		{
+			description: "run step with stream",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "my command",
+						"stream":  true,
+					},
+				},
+			},
+		},
+		{
+			description: "run step with non-boolean stream",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "my command",
+						"stream":  "sometimes",
+					},
+				},
+			},
+			expErr: "run step \"stream\" option must be a boolean",
+		},
+		{
+			description: "run step with stream and hidden output",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "my command",
+						"output":  "hide",
+						"stream":  true,
+					},
+				},
+			},
+			expErr: "run step \"stream\" option can't be set when \"output\" is \"hide\"",
+		},
+		{
+			description: "run step with extra key",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "my command",
+						"invalid": "",
+					},
+				},
+			},
+			expErr: "run steps only support keys \"command\", \"output\", \"stream\", \"always\", \"on_success\", \"require_tool\", \"for_each\", \"parallel\", \"golden\", \"assert_format\", \"metric\", \"input\", \"no_network\", \"restore_dir\", \"render\", \"rate_limit\", \"if\", \"require_clean_after\", \"cache\", \"comment_mode\", \"memory_limit\", \"cpu_limit\", \"verify\", \"requires_files\", \"nix_shell\", \"requires_plan\", \"junit\", \"exit_code_var\", \"allowed_exit_codes\", \"on_drift\", \"debug_env_diff\", \"requires_projects_success\", \"thread\", \"line_prefix\", \"output_filter\", \"require_approval\", \"approval_timeout\", \"diff_against_base\", \"metric_label\" and \"fatal\", found extra keys \"invalid\"",
+		},
+		{
+			description: "run step with require_tool",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "my command",
+						"require_tool": "jq>=1.6",
+					},
+				},
+			},
+		},
+		{
+			description: "run step with require_tool list",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "my command",
+						"require_tool": []interface{}{"jq>=1.6", "yq"},
+					},
+				},
+			},
+		},
+		{
+			description: "run step with invalid require_tool constraint",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "my command",
+						"require_tool": "jq>=one",
+					},
+				},
+			},
+			expErr: "run step \"require_tool\" option: invalid version constraint in \"jq>=one\": Malformed constraint: >=one",
+		},
+		{
+			description: "run step with invalid require_tool name",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "my command",
+						"require_tool": []interface{}{"../jq"},
+					},
+				},
+			},
+			expErr: "run step \"require_tool\" option: invalid tool name \"../jq\" in \"../jq\"",
+		},
+		{
+			description: "run step with for_each and parallel",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":  "tflint {}",
+						"for_each": "ls *.tf",
+						"parallel": 4,
+					},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "run step with for_each missing placeholder",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":  "tflint",
+						"for_each": "ls *.tf",
+					},
+				},
+			},
+			expErr: "run step \"command\" must contain \"{}\" to be replaced by each item of \"for_each\"",
+		},
+		{
+			description: "run step with empty for_each",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":  "tflint {}",
+						"for_each": "",
+					},
+				},
+			},
+			expErr: "run step \"for_each\" option must be a non-empty string",
+		},
+		{
+			description: "run step with parallel without for_each",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":  "tflint",
+						"parallel": 2,
+					},
+				},
+			},
+			expErr: "run step \"parallel\" option can only be set with \"for_each\"",
+		},
+		{
+			description: "run step with parallel 0",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":  "tflint {}",
+						"for_each": "ls *.tf",
+						"parallel": 0,
+					},
+				},
+			},
+			expErr: "run step \"parallel\" option must be an integer greater than 0",
+		},
+		{
+			description: "run step with golden",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./gen.sh",
+						"golden":  "expected.txt",
+					},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "run step with absolute golden",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./gen.sh",
+						"golden":  "/etc/passwd",
+					},
+				},
+			},
+			expErr: "run step \"golden\" option must be a path relative to the project directory, found \"/etc/passwd\"",
+		},
+		{
+			description: "run step with empty golden",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./gen.sh",
+						"golden":  "",
+					},
+				},
+			},
+			expErr: "run step \"golden\" option must be a non-empty string",
+		},
+		{
+			description: "run step with assert_format",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":       "./gen-config.sh",
+						"assert_format": "yaml",
+					},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "run step with invalid assert_format",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":       "./gen-config.sh",
+						"assert_format": "toml",
+					},
+				},
+			},
+			expErr: "run step \"assert_format\" option must be one of \"json\" or \"yaml\"",
+		},
+		{
+			description: "run step with metric",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "make build",
+						"metric":  "build_time",
+					},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "run step with invalid metric",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "make build",
+						"metric":  "build-time",
+					},
+				},
+			},
+			expErr: "run step \"metric\" option must be a name of letters, digits and underscores that doesn't start with a digit",
+		},
+		{
+			description: "run step with input",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./tool",
+						"input":   "yes\nyes\n",
+					},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "run step with empty input",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./tool",
+						"input":   "",
+					},
+				},
+			},
+			expErr: "run step \"input\" option must be a non-empty string",
+		},
+		{
+			description: "run step with no_network",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":    "make build",
+						"no_network": true,
+					},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "run step with resource limits",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "make build",
+						"memory_limit": "512M",
+						"cpu_limit":    1.5,
+					},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "run step with invalid memory_limit",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "make build",
+						"memory_limit": "512T",
+					},
+				},
+			},
+			expErr: "run step \"memory_limit\" option: invalid memory limit \"512T\", must be a number of bytes optionally followed by K, M or G, ex. \"512M\"",
+		},
+		{
+			description: "run step with zero cpu_limit",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":   "make build",
+						"cpu_limit": 0,
+					},
+				},
+			},
+			expErr: "run step \"cpu_limit\" option: invalid cpu limit \"0\", must be a number of CPUs greater than 0, ex. \"0.5\"",
+		},
+		{
+			description: "run step with non-boolean no_network",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":    "make build",
+						"no_network": "yes",
+					},
+				},
+			},
+			expErr: "run step \"no_network\" option must be a boolean",
+		},
+		{
+			description: "run step with non-boolean restore_dir",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":     "./explore.sh",
+						"restore_dir": "yes",
+					},
+				},
+			},
+			expErr: "run step \"restore_dir\" option must be a boolean",
+		},
+		{
+			description: "run step with non-boolean require_clean_after",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":             "./generate.sh",
+						"require_clean_after": "yes",
+					},
+				},
+			},
+			expErr: "run step \"require_clean_after\" option must be a boolean",
+		},
+		{
+			description: "run step with non-map cache",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "npm ci",
+						"cache":   "node_modules",
+					},
+				},
+			},
+			expErr: "run step \"cache\" option: must be a map with \"key\" and \"paths\" keys",
+		},
+		{
+			description: "run step with cache extra key",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "npm ci",
+						"cache":   map[string]interface{}{"key": "npm", "paths": []interface{}{"node_modules"}, "ttl": "1h"},
+					},
+				},
+			},
+			expErr: "run step \"cache\" option: only supports keys \"key\" and \"paths\", found \"ttl\"",
+		},
+		{
+			description: "run step with invalid cache key template",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "npm ci",
+						"cache":   map[string]interface{}{"key": "npm-{{ .Checksum", "paths": []interface{}{"node_modules"}},
+					},
+				},
+			},
+			expErr: "run step \"cache\" option: invalid key \"npm-{{ .Checksum\": template: key:1: unclosed action",
+		},
+		{
+			description: "run step with cache key using env",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "npm ci",
+						"cache":   map[string]interface{}{"key": `npm-{{ env "HOME" }}`, "paths": []interface{}{"node_modules"}},
+					},
+				},
+			},
+			expErr: "run step \"cache\" option: invalid key \"npm-{{ env \\\"HOME\\\" }}\": template: key:1: function \"env\" not defined",
+		},
+		{
+			description: "run step with cache path outside project",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "npm ci",
+						"cache":   map[string]interface{}{"key": "npm", "paths": []interface{}{"../node_modules"}},
+					},
+				},
+			},
+			expErr: "run step \"cache\" option: path \"../node_modules\" must be inside the project directory",
+		},
+		{
+			description: "run step with cache without paths",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "npm ci",
+						"cache":   map[string]interface{}{"key": "npm"},
+					},
+				},
+			},
+			expErr: "run step \"cache\" option: \"paths\" must be a string or a list of strings",
+		},
+		{
+			description: "run step with verify",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./download.sh",
+						"verify":  map[string]interface{}{"file": "tool", "sha512_file": "tool.sha512"},
+					},
+				},
+			},
+		},
+		{
+			description: "run step with non-map verify",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./download.sh",
+						"verify":  "tool",
+					},
+				},
+			},
+			expErr: "run step \"verify\" option: must be a map with a \"file\" key and one of \"sha256\", \"sha512\", \"sha256_file\" or \"sha512_file\"",
+		},
+		{
+			description: "run step with verify without checksum",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./download.sh",
+						"verify":  map[string]interface{}{"file": "tool"},
+					},
+				},
+			},
+			expErr: "run step \"verify\" option: must have one of \"sha256\", \"sha512\", \"sha256_file\" or \"sha512_file\"",
+		},
+		{
+			description: "run step with verify with two checksums",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./download.sh",
+						"verify":  map[string]interface{}{"file": "tool", "sha256": "abababababababababababababababababababababababababababababababab", "sha256_file": "tool.sha256"},
+					},
+				},
+			},
+			expErr: "run step \"verify\" option: can only have one of \"sha256\", \"sha512\", \"sha256_file\" or \"sha512_file\"",
+		},
+		{
+			description: "run step with invalid verify checksum",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./download.sh",
+						"verify":  map[string]interface{}{"file": "tool", "sha256": "abc"},
+					},
+				},
+			},
+			expErr: "run step \"verify\" option: invalid sha256 checksum \"abc\", must be 64 hex characters",
+		},
+		{
+			description: "run step with requires_files",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":        "./use-config.sh",
+						"requires_files": []interface{}{"config.yaml", "secrets.env"},
+					},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "run step with empty requires_files",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":        "./use-config.sh",
+						"requires_files": []interface{}{},
+					},
+				},
+			},
+			expErr: "run step \"requires_files\" option must be a non-empty list of strings",
+		},
+		{
+			description: "run step with requires_files string",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":        "./use-config.sh",
+						"requires_files": "config.yaml",
+					},
+				},
+			},
+			expErr: "run step \"requires_files\" option must be a non-empty list of strings",
+		},
+		{
+			description: "run step with absolute requires_files",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":        "./use-config.sh",
+						"requires_files": []interface{}{"config.yaml", "/etc/passwd"},
+					},
+				},
+			},
+			expErr: "run step \"requires_files\" option must be paths relative to the project directory, found \"/etc/passwd\"",
+		},
+		{
+			description: "run step with requires_plan",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":       "./check-plan.sh $PLANFILE",
+						"requires_plan": true,
+					},
+				},
+			},
+		},
+		{
+			description: "run step with non-boolean requires_plan",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":       "./check-plan.sh $PLANFILE",
+						"requires_plan": "yes",
+					},
+				},
+			},
+			expErr: "run step \"requires_plan\" option must be a boolean",
+		},
+		{
+			description: "run step with nix_shell",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":   "terraform fmt -check",
+						"nix_shell": "../../shell.nix",
+					},
+				},
+			},
+		},
+		{
+			description: "run step with empty nix_shell",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":   "terraform fmt -check",
+						"nix_shell": "",
+					},
+				},
+			},
+			expErr: "run step \"nix_shell\" option must be a non-empty string",
+		},
+		{
+			description: "run step with absolute nix_shell",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":   "terraform fmt -check",
+						"nix_shell": "/etc/shell.nix",
+					},
+				},
+			},
+			expErr: "run step \"nix_shell\" option must be a path relative to the project directory, found \"/etc/shell.nix\"",
+		},
+		{
+			description: "run step with junit",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "go test ./... 2>&1 | go-junit-report > report.xml",
+						"junit":   "report.xml",
+					},
+				},
+			},
+		},
+		{
+			description: "run step with empty junit",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "go test ./...",
+						"junit":   " ",
+					},
+				},
+			},
+			expErr: "run step \"junit\" option must be a non-empty string",
+		},
+		{
+			description: "run step with absolute junit",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "go test ./...",
+						"junit":   "/tmp/report.xml",
+					},
+				},
+			},
+			expErr: "run step \"junit\" option must be a path relative to the project directory, found \"/tmp/report.xml\"",
+		},
+		{
+			description: "run step with exit_code_var and allowed_exit_codes",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":            "./check.sh",
+						"exit_code_var":      "CHECK_RC",
+						"allowed_exit_codes": []interface{}{0, 1, 2},
+					},
+				},
+			},
+		},
+		{
+			description: "run step with invalid on_drift",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":  "./notify-drift.sh",
+						"on_drift": "sometimes",
+					},
+				},
+			},
+			expErr: "run step \"on_drift\" option must be a boolean",
+		},
+		{
+			description: "run step with require_approval",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":          "./wipe.sh",
+						"require_approval": true,
+						"approval_timeout": "30m",
+					},
+				},
+			},
+		},
+		{
+			description: "run step with invalid require_approval",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":          "./wipe.sh",
+						"require_approval": "yes",
+					},
+				},
+			},
+			expErr: "run step \"require_approval\" option must be a boolean",
+		},
+		{
+			description: "run step with invalid approval_timeout",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":          "./wipe.sh",
+						"require_approval": true,
+						"approval_timeout": "soon",
+					},
+				},
+			},
+			expErr: "run step \"approval_timeout\" option must be a positive duration, ex. \"30m\" or \"1h\", found \"soon\"",
+		},
+		{
+			description: "run step with approval_timeout without require_approval",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":          "./wipe.sh",
+						"approval_timeout": "30m",
+					},
+				},
+			},
+			expErr: "run step \"approval_timeout\" option can only be set when \"require_approval\" is true",
+		},
+		{
+			description: "run step with diff_against_base",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":           "./report.sh",
+						"diff_against_base": true,
+					},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "run step with invalid diff_against_base",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":           "./report.sh",
+						"diff_against_base": "yes",
+					},
+				},
+			},
+			expErr: "run step \"diff_against_base\" option must be a boolean",
+		},
+		{
+			description: "run step with diff_against_base and golden",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":           "./report.sh",
+						"diff_against_base": true,
+						"golden":            "expected.txt",
+					},
+				},
+			},
+			expErr: "run step \"diff_against_base\" option can't be set with \"golden\"",
+		},
+		{
+			description: "run step with metric_label",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "./coverage.sh",
+						"metric_label": "coverage",
+					},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "run step with empty metric_label",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "./coverage.sh",
+						"metric_label": " ",
+					},
+				},
+			},
+			expErr: "run step \"metric_label\" option must be a non-empty string without newlines",
+		},
+		{
+			description: "run step with fatal",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./check-state.sh",
+						"fatal":   true,
+					},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "run step with invalid fatal",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./check-state.sh",
+						"fatal":   "yes",
+					},
+				},
+			},
+			expErr: "run step \"fatal\" option must be a boolean",
+		},
+		{
+			description: "run step with metric_label and render table",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "./coverage.sh",
+						"metric_label": "coverage",
+						"render":       "table",
+					},
+				},
+			},
+			expErr: "run step \"metric_label\" option can't be set when \"render\" is \"table\"",
+		},
+		{
+			description: "run step with metric_label and output hide",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "./coverage.sh",
+						"metric_label": "coverage",
+						"output":       "hide",
+					},
+				},
+			},
+			expErr: "run step \"metric_label\" option can't be set when \"output\" is \"hide\"",
+		},
+		{
+			description: "run step with metric_label and for_each",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "./coverage.sh {}",
+						"metric_label": "coverage",
+						"for_each":     "ls",
+					},
+				},
+			},
+			expErr: "run step \"metric_label\" option can't be set with \"for_each\"",
+		},
+		{
+			description: "run step with invalid debug_env_diff",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":        "./setenv.sh",
+						"debug_env_diff": "yes",
+					},
+				},
+			},
+			expErr: "run step \"debug_env_diff\" option must be a boolean",
+		},
+		{
+			description: "run step with debug_env_diff and for_each",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":        "./setenv.sh {item}",
+						"for_each":       "a b",
+						"debug_env_diff": true,
+					},
+				},
+			},
+			expErr: "run step \"debug_env_diff\" option can't be set with \"for_each\"",
+		},
+		{
+			description: "run step with empty requires_projects_success",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":                   "./aggregate.sh",
+						"requires_projects_success": []interface{}{},
+					},
+				},
+			},
+			expErr: "run step \"requires_projects_success\" option must be a non-empty list of project names",
+		},
+		{
+			description: "run step with empty project in requires_projects_success",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":                   "./aggregate.sh",
+						"requires_projects_success": []interface{}{"network", " "},
+					},
+				},
+			},
+			expErr: "run step \"requires_projects_success\" option can't contain an empty project name",
+		},
+		{
+			description: "multienv with invalid debug_env_diff",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"multienv": {
+						"command":        "./setenv.sh",
+						"debug_env_diff": "yes",
+					},
+				},
+			},
+			expErr: "multienv step \"debug_env_diff\" option must be a boolean",
+		},
+		{
+			description: "run step with invalid exit_code_var",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":       "./check.sh",
+						"exit_code_var": "CHECK-RC",
+					},
+				},
+			},
+			expErr: "run step \"exit_code_var\" option must be an environment variable name of letters, digits and underscores that doesn't start with a digit",
+		},
+		{
+			description: "run step with empty allowed_exit_codes",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":            "./check.sh",
+						"allowed_exit_codes": []interface{}{},
+					},
+				},
+			},
+			expErr: "run step \"allowed_exit_codes\" option must be a non-empty list of integers",
+		},
+		{
+			description: "run step with out of range allowed_exit_codes",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":            "./check.sh",
+						"allowed_exit_codes": []interface{}{1, 256},
+					},
+				},
+			},
+			expErr: "run step \"allowed_exit_codes\" option must be exit codes from 0 to 255, found 256",
+		},
+		{
+			description: "run step with verify file outside project",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./download.sh",
+						"verify":  map[string]interface{}{"file": "/usr/bin/tool", "sha256": "abababababababababababababababababababababababababababababababab"},
+					},
+				},
+			},
+			expErr: "run step \"verify\" option: file \"/usr/bin/tool\" must be inside the project directory",
+		},
+		{
+			description: "run step with verify extra key",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./download.sh",
+						"verify":  map[string]interface{}{"file": "tool", "sha256": "abababababababababababababababababababababababababababababababab", "md5": "abc"},
+					},
+				},
+			},
+			expErr: "run step \"verify\" option: only supports keys \"file\", \"sha256\", \"sha512\", \"sha256_file\" and \"sha512_file\", found \"md5\"",
+		},
+		{
+			description: "run step with render",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./data.sh",
+						"render":  "table",
+					},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "run step with invalid render",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./data.sh",
+						"render":  "html",
+					},
+				},
+			},
+			expErr: "run step \"render\" option must be one of \"raw\", \"table\" or \"code\"",
+		},
+		{
+			description: "run step with render and hidden output",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./data.sh",
+						"output":  "hide",
+						"render":  "table",
+					},
+				},
+			},
+			expErr: "run step \"render\" option can't be set when \"output\" is \"hide\"",
+		},
+		{
+			description: "run step with comment_mode",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "./report.sh",
+						"comment_mode": "separate",
+					},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "run step with invalid comment_mode",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "./report.sh",
+						"comment_mode": "thread",
+					},
+				},
+			},
+			expErr: "run step \"comment_mode\" option must be one of \"inline\" or \"separate\"",
+		},
+		{
+			description: "run step with separate comment_mode and hidden output",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "./report.sh",
+						"output":       "hide",
+						"comment_mode": "separate",
+					},
+				},
+			},
+			expErr: "run step \"comment_mode\" option can't be \"separate\" when \"output\" is \"hide\"",
+		},
+		{
+			description: "run step with invalid thread",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./lint.sh",
+						"thread":  "pull",
+					},
+				},
+			},
+			expErr: "run step \"thread\" option must be \"review\"",
+		},
+		{
+			description: "run step with thread and hidden output",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./lint.sh",
+						"output":  "hide",
+						"thread":  "review",
+					},
+				},
+			},
+			expErr: "run step \"thread\" option can't be set when \"output\" is \"hide\"",
+		},
+		{
+			description: "run step with thread and separate comment_mode",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "./lint.sh",
+						"comment_mode": "separate",
+						"thread":       "review",
+					},
+				},
+			},
+			expErr: "run step \"thread\" option can't be set when \"comment_mode\" is \"separate\"",
+		},
+		{
+			description: "run step with empty line_prefix",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":     "./lint.sh",
+						"line_prefix": "",
+					},
+				},
+			},
+			expErr: "run step \"line_prefix\" option must be a non-empty string without newlines",
+		},
+		{
+			description: "run step with line_prefix and render",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":     "./report.sh",
+						"line_prefix": "[report] ",
+						"render":      "table",
+					},
+				},
+			},
+			expErr: "run step \"line_prefix\" option can't be set when \"render\" is \"table\"",
+		},
+		{
+			description: "run step with empty output_filter",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":       "./build.sh",
+						"output_filter": " ",
+					},
+				},
+			},
+			expErr: "run step \"output_filter\" option must be a non-empty string",
+		},
+		{
+			description: "run step with rate_limit",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":    "./call-api.sh",
+						"rate_limit": "cloud-api:5/s",
+					},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "run step with invalid rate_limit",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":    "./call-api.sh",
+						"rate_limit": "5/s",
+					},
+				},
+			},
+			expErr: "run step \"rate_limit\" option: invalid rate limit \"5/s\", must be a name, a count and a period, ex. \"cloud-api:5/s\"",
+		},
+		{
+			description: "run step with rate_limit of 0",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":    "./call-api.sh",
+						"rate_limit": "cloud-api:0/s",
+					},
+				},
+			},
+			expErr: "run step \"rate_limit\" option: invalid rate limit \"cloud-api:0/s\", the count must be greater than 0",
+		},
+		{
+			description: "run step with if",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./notify.sh",
+						"if":      "num_changes > 10 && workspace == 'prod'",
+					},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "run step with if of unknown variable",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./notify.sh",
+						"if":      "changes > 10",
+					},
+				},
+			},
+			expErr: "run step \"if\" option: invalid condition \"changes > 10\": unknown variable \"changes\"",
+		},
+		{
+			description: "run step with if that isn't a boolean",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./notify.sh",
+						"if":      "workspace",
+					},
+				},
+			},
+			expErr: "run step \"if\" option: invalid condition \"workspace\": must be a boolean, found a string",
+		},
+		{
+			description: "run step with stream and for_each",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":  "tflint {}",
+						"for_each": "ls *.tf",
+						"stream":   true,
+					},
+				},
+			},
+			expErr: "run step \"stream\" option can't be set with \"for_each\"",
+		},
+		{
+			description: "run step with always",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./test.sh",
+						"always":  "./cleanup.sh",
+					},
+				},
+			},
+		},
+		{
+			description: "run step with on_success",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":    "./deploy.sh",
+						"on_success": "./notify.sh",
+					},
+				},
+			},
+		},
+		{
+			description: "run step with empty on_success",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":    "./deploy.sh",
+						"on_success": "",
+					},
+				},
+			},
+			expErr: "run step \"on_success\" option must be a non-empty string",
+		},
+		{
+			description: "run step with empty always",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./test.sh",
+						"always":  "",
+					},
+				},
+			},
+			expErr: "run step \"always\" option must be a non-empty string",
+		},
+		{
+			// For atlantis.yaml v2, this wouldn't parse, but now there should
+			// be no error.
+			description: "unparseable shell command",
+			input: raw.Step{
+				StringVal: map[string]string{
+					"run": "my 'c",
+				},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			err := c.input.Validate()
+			if c.expErr == "" {
+				Ok(t, err)
+				return
+			}
+			ErrEquals(t, c.expErr, err)
+		})
+	}
+}
+
+func TestStep_ToValid(t *testing.T) {
+	cases := []struct {
+		description string
+		input       raw.Step
+		exp         valid.Step
+	}{
+		{
+			description: "init step",
+			input: raw.Step{
+				Key: String("init"),
+			},
+			exp: valid.Step{
+				StepName: "init",
+			},
+		},
+		{
+			description: "plan step",
+			input: raw.Step{
+				Key: String("plan"),
+			},
+			exp: valid.Step{
+				StepName: "plan",
+			},
+		},
+		{
+			description: "policy_check step",
+			input: raw.Step{
+				Key: String("policy_check"),
+			},
+			exp: valid.Step{
+				StepName: "policy_check",
+			},
+		},
+		{
+			description: "apply step",
+			input: raw.Step{
+				Key: String("apply"),
+			},
+			exp: valid.Step{
+				StepName: "apply",
+			},
+		},
+		{
+			description: "env step",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"env": {
+						"name":    "test",
+						"command": "echo 123",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:      "env",
+				RunCommand:    "echo 123",
+				EnvVarName:    "test",
+				MaskInComment: true,
+				MaskInLog:     true,
+			},
+		},
+		{
+			description: "env step with mask_in",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"env": {
+						"name":    "test",
+						"value":   "value",
+						"mask_in": []interface{}{"comment"},
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:      "env",
+				EnvVarName:    "test",
+				EnvVarValue:   "value",
+				MaskInComment: true,
+			},
+		},
+		{
+			description: "run step with require_tool",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "my command",
+						"require_tool": []interface{}{"jq>=1.6", "yq"},
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "my command",
+				Output:     "show",
+				RequireTools: []valid.ToolRequirement{
+					{Name: "jq", Constraints: version.MustConstraints(version.NewConstraint(">=1.6"))},
+					{Name: "yq"},
+				},
+			},
+		},
+		{
+			description: "run step with for_each and parallel",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":  "tflint {}",
+						"for_each": "ls *.tf",
+						"parallel": 4,
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "tflint {}",
+				Output:     "show",
+				ForEach:    "ls *.tf",
+				Parallel:   4,
+			},
+		},
+		{
+			description: "run step with golden",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./gen.sh",
+						"golden":  "expected.txt",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "./gen.sh",
+				Output:     "show",
+				Golden:     "expected.txt",
+			},
+		},
+		{
+			description: "run step with assert_format",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":       "./gen-config.sh",
+						"assert_format": "json",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:     "run",
+				RunCommand:   "./gen-config.sh",
+				Output:       "show",
+				AssertFormat: valid.AssertFormatJSON,
+			},
+		},
+		{
+			description: "run step with metric",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "make build",
+						"metric":  "build_time",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "make build",
+				Output:     "show",
+				Metric:     "build_time",
+			},
+		},
+		{
+			description: "run step with input",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./tool",
+						"input":   "yes\n",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "./tool",
+				Output:     "show",
+				Stdin:      "yes\n",
+			},
+		},
+		{
+			description: "run step with no_network",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":    "make build",
+						"no_network": true,
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "make build",
+				Output:     "show",
+				NoNetwork:  true,
+			},
+		},
+		{
+			description: "run step with restore_dir",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":     "./explore.sh",
+						"restore_dir": true,
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "./explore.sh",
+				Output:     "show",
+				RestoreDir: true,
+			},
+		},
+		{
+			description: "run step with require_clean_after",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":             "./generate.sh",
+						"require_clean_after": true,
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:          "run",
+				RunCommand:        "./generate.sh",
+				Output:            "show",
+				RequireCleanAfter: true,
+			},
+		},
+		{
+			description: "run step with resource limits",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "make build",
+						"memory_limit": "512M",
+						"cpu_limit":    1.5,
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:    "run",
+				RunCommand:  "make build",
+				Output:      "show",
+				MemoryLimit: 512 * 1024 * 1024,
+				CPULimit:    1.5,
+			},
+		},
+		{
+			description: "run step with cache",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "npm ci",
+						"cache":   map[string]interface{}{"key": `npm-{{ .Checksum "package-lock.json" }}`, "paths": []interface{}{"node_modules"}},
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "npm ci",
+				Output:     "show",
+				Cache:      &valid.StepCache{Key: `npm-{{ .Checksum "package-lock.json" }}`, Paths: []string{"node_modules"}},
+			},
+		},
+		{
+			description: "run step with verify",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./download.sh",
+						"verify":  map[interface{}]interface{}{"file": "tool", "sha256": "ABABABABABABABABABABABABABABABABABABABABABABABABABABABABABABABAB"},
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "./download.sh",
+				Output:     "show",
+				Verify:     &valid.StepVerify{File: "tool", Algorithm: "sha256", Checksum: "abababababababababababababababababababababababababababababababab"},
+			},
+		},
+		{
+			description: "run step with verify checksum file",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./download.sh",
+						"verify":  map[string]interface{}{"file": "bin/tool", "sha512_file": "SHA512SUMS"},
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "./download.sh",
+				Output:     "show",
+				Verify:     &valid.StepVerify{File: "bin/tool", Algorithm: "sha512", ChecksumFile: "SHA512SUMS"},
+			},
+		},
+		{
+			description: "multienv step with append mode",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"multienv": {
+						"command": "./paths.sh",
+						"mode":    "append",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:          "multienv",
+				RunCommand:        "./paths.sh",
+				MultiEnvMode:      "append",
+				MultiEnvSeparator: ":",
+			},
+		},
+		{
+			description: "multienv step with separator",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"multienv": {
+						"command":   "./flags.sh",
+						"mode":      "append",
+						"separator": " ",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:          "multienv",
+				RunCommand:        "./flags.sh",
+				MultiEnvMode:      "append",
+				MultiEnvSeparator: " ",
+			},
+		},
+		{
+			description: "run step with requires_files",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":        "./use-config.sh",
+						"requires_files": []interface{}{"config.yaml", "secrets.env"},
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:      "run",
+				RunCommand:    "./use-config.sh",
+				Output:        "show",
+				RequiresFiles: []string{"config.yaml", "secrets.env"},
+			},
+		},
+		{
+			description: "run step with requires_plan",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":       "./check-plan.sh $PLANFILE",
+						"requires_plan": true,
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:     "run",
+				RunCommand:   "./check-plan.sh $PLANFILE",
+				Output:       "show",
+				RequiresPlan: true,
+			},
+		},
+		{
+			description: "run step with nix_shell",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":   "terraform fmt -check",
+						"nix_shell": "shell.nix",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "terraform fmt -check",
+				Output:     "show",
+				NixShell:   "shell.nix",
+			},
+		},
+		{
+			description: "run step with junit",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./test.sh",
+						"junit":   "out/report.xml",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "./test.sh",
+				Output:     "show",
+				JUnit:      "out/report.xml",
+			},
+		},
+		{
+			description: "run step with exit_code_var and allowed_exit_codes",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":            "./check.sh",
+						"exit_code_var":      "CHECK_RC",
+						"allowed_exit_codes": []interface{}{1, 2},
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:         "run",
+				RunCommand:       "./check.sh",
+				Output:           "show",
+				ExitCodeVar:      "CHECK_RC",
+				AllowedExitCodes: []int{1, 2},
+			},
+		},
+		{
+			description: "run step with on_drift",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":  "./notify-drift.sh",
+						"on_drift": true,
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "./notify-drift.sh",
+				Output:     "show",
+				OnDrift:    true,
+			},
+		},
+		{
+			description: "run step with require_approval",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":          "./wipe.sh",
+						"require_approval": true,
+						"approval_timeout": "30m",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:        "run",
+				RunCommand:      "./wipe.sh",
+				Output:          "show",
+				RequireApproval: true,
+				ApprovalTimeout: 30 * time.Minute,
+			},
+		},
+		{
+			description: "run step with diff_against_base",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":           "./report.sh",
+						"diff_against_base": true,
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:        "run",
+				RunCommand:      "./report.sh",
+				Output:          "show",
+				DiffAgainstBase: true,
+			},
+		},
+		{
+			description: "run step with metric_label",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "./coverage.sh",
+						"metric_label": "coverage",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:    "run",
+				RunCommand:  "./coverage.sh",
+				Output:      "show",
+				MetricLabel: "coverage",
+			},
+		},
+		{
+			description: "run step with fatal",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./check-state.sh",
+						"fatal":   true,
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "./check-state.sh",
+				Output:     "show",
+				Fatal:      true,
+			},
+		},
+		{
+			description: "run step with debug_env_diff",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":        "./setenv.sh",
+						"debug_env_diff": true,
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:     "run",
+				RunCommand:   "./setenv.sh",
+				Output:       "show",
+				DebugEnvDiff: true,
+			},
+		},
+		{
+			description: "run step with requires_projects_success",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":                   "./aggregate.sh",
+						"requires_projects_success": []interface{}{"network", "dns"},
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:                "run",
+				RunCommand:              "./aggregate.sh",
+				Output:                  "show",
+				RequiresProjectsSuccess: []string{"network", "dns"},
+			},
+		},
+		{
+			description: "multienv step with debug_env_diff",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"multienv": {
+						"command":        "./setenv.sh",
+						"debug_env_diff": true,
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:     "multienv",
+				RunCommand:   "./setenv.sh",
+				DebugEnvDiff: true,
+			},
+		},
+		{
+			description: "run step with render",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./data.sh",
+						"render":  "table",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "./data.sh",
+				Output:     "show",
+				Render:     "table",
+			},
+		},
+		{
+			description: "run step with comment_mode",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":      "./report.sh",
+						"comment_mode": "separate",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:    "run",
+				RunCommand:  "./report.sh",
+				Output:      "show",
+				CommentMode: "separate",
+			},
+		},
+		{
+			description: "run step with thread",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./lint.sh",
+						"thread":  "review",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "./lint.sh",
+				Output:     "show",
+				Thread:     "review",
+			},
+		},
+		{
+			description: "run step with line_prefix",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":     "./lint.sh",
+						"line_prefix": "[lint] ",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "./lint.sh",
+				Output:     "show",
+				LinePrefix: "[lint] ",
+			},
+		},
+		{
+			description: "run step with output_filter",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":       "./build.sh",
+						"output_filter": "grep -v DEBUG",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:     "run",
+				RunCommand:   "./build.sh",
+				Output:       "show",
+				OutputFilter: "grep -v DEBUG",
+			},
+		},
+		{
+			description: "run step with rate_limit",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":    "./call-api.sh",
+						"rate_limit": "cloud-api:100/10m",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "./call-api.sh",
+				Output:     "show",
+				RateLimit:  &valid.RateLimit{Name: "cloud-api", Count: 100, Per: 10 * time.Minute},
+			},
+		},
+		{
+			description: "run step with if",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./notify.sh",
+						"if":      "workspace == 'prod'",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "./notify.sh",
+				Output:     "show",
+				If:         mustParseStepCondition("workspace == 'prod'"),
+			},
+		},
+		{
+			description: "env step from_ssm_path",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"env": {
+						"from_ssm_path": "/app/prod/",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName: "env",
+				SSMPath:  "/app/prod/",
+			},
+		},
+		{
+			description: "import step",
+			input: raw.Step{
+				Key: String("import"),
+			},
+			exp: valid.Step{
+				StepName: "import",
+			},
+		},
+		{
+			description: "plan extra_args_file",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"plan": {
+						"extra_args":      []interface{}{"arg1", "arg2"},
+						"extra_args_file": "plan-args.txt",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:      "plan",
+				ExtraArgs:     []string{"arg1", "arg2"},
+				ExtraArgsFile: "plan-args.txt",
+			},
+		},
+		{
+			description: "plan comment_args_position",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"plan": {
+						"extra_args":            []interface{}{"-var-file=staging.tfvars"},
+						"comment_args_position": "prepend",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:            "plan",
+				ExtraArgs:           []string{"-var-file=staging.tfvars"},
+				CommentArgsPosition: valid.CommentArgsPrepend,
+			},
+		},
+		{
+			description: "plan parallelism",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"plan": {
+						"parallelism": 20,
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:    "plan",
+				Parallelism: 20,
+			},
+		},
+		{
+			description: "plan workspace_from_file",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"plan": {
+						"workspace_from_file": ".atlantis-workspace",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:          "plan",
+				WorkspaceFromFile: ".atlantis-workspace",
+			},
+		},
+		{
+			description: "init extra_args",
+			input: raw.Step{
+				Map: MapType{
+					"init": {
+						"extra_args": []string{"arg1", "arg2"},
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:  "init",
+				ExtraArgs: []string{"arg1", "arg2"},
+			},
+		},
+		{
+			description: "plan extra_args",
+			input: raw.Step{
+				Map: MapType{
+					"plan": {
+						"extra_args": []string{"arg1", "arg2"},
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:  "plan",
+				ExtraArgs: []string{"arg1", "arg2"},
+			},
+		},
+		{
+			description: "policy_check extra_args",
+			input: raw.Step{
+				Map: MapType{
+					"policy_check": {
+						"extra_args": []string{"arg1", "arg2"},
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:  "policy_check",
+				ExtraArgs: []string{"arg1", "arg2"},
+			},
+		},
+		{
+			description: "apply extra_args",
+			input: raw.Step{
+				Map: MapType{
+					"apply": {
+						"extra_args": []string{"arg1", "arg2"},
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:  "apply",
+				ExtraArgs: []string{"arg1", "arg2"},
+			},
+		},
+		{
+			description: "import extra_args",
+			input: raw.Step{
+				Map: MapType{
+					"import": {
+						"extra_args": []string{"arg1", "arg2"},
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:  "import",
+				ExtraArgs: []string{"arg1", "arg2"},
+			},
+		},
+		{
+			description: "run step",
+			input: raw.Step{
+				StringVal: map[string]string{
+					"run": "my 'run command'",
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "my 'run command'",
+			},
+		},
+		{
+			description: "run step with output",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "my 'run command'",
+						"output":  "hide",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "my 'run command'",
+				Output:     "hide",
+			},
+		},
+		{
+			description: "run step with stream",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "my 'run command'",
+						"stream":  true,
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "my 'run command'",
+				Output:     "show",
+				Stream:     true,
+			},
+		},
+		{
+			description: "run step with always",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command": "./test.sh",
+						"always":  "./cleanup.sh",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "./test.sh",
+				Always:     "./cleanup.sh",
+				Output:     "show",
+			},
+		},
+		{
+			description: "run step with on_success",
+			input: raw.Step{
+				CommandMap: CommandMapType{
+					"run": {
+						"command":    "./deploy.sh",
+						"on_success": "./notify.sh",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: "./deploy.sh",
+				OnSuccess:  "./notify.sh",
+				Output:     "show",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			Equals(t, c.exp, c.input.ToValid())
+		})
+	}
+}
+
+type MapType map[string]map[string][]string
+type CommandMapType map[string]map[string]interface{}
+
+func mustParseStepCondition(expr string) *valid.StepCondition {
+	cond, err := valid.ParseStepCondition(expr)
+	if err != nil {
+		panic(err)
+	}
+	return cond
+}
@@ -0,0 +1,60 @@
+package raw
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+)
+
+// TFVars are the Terraform variables a project sets with tf_vars, by name.
+type TFVars map[string]interface{}
+
+// tfVarNameRegex matches the names Terraform allows for variables.
+var tfVarNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// validTFVars validates tf_vars, whose names must be valid Terraform
+// variable names and whose values must be set.
+func validTFVars(value interface{}) error {
+	vars := value.(TFVars)
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !tfVarNameRegex.MatchString(name) {
+			return fmt.Errorf("%q is not a valid variable name, it must start with a letter or underscore and contain only letters, digits, underscores and dashes", name)
+		}
+		s, err := tfVarString(vars[name])
+		if err != nil {
+			return fmt.Errorf("variable %q %s", name, err)
+		}
+		if _, err := valid.ParseSecretRefs(s); err != nil {
+			return fmt.Errorf("variable %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// tfVarString returns value as it's set in a TF_VAR_ environment variable.
+// Strings are used as they are and numbers and bools are formatted, while
+// lists and maps are encoded as JSON, which Terraform parses as HCL.
+func tfVarString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", fmt.Errorf("must have a value")
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("can't be encoded: %s", err)
+		}
+		return string(encoded), nil
+	}
+}
@@ -1,25 +1,96 @@
 package raw
 
 import (
+	"fmt"
+	"path"
+	"time"
+
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/runatlantis/atlantis/server/core/config/valid"
 )
 
 type Workflow struct {
-	Apply       *Stage `yaml:"apply,omitempty" json:"apply,omitempty"`
-	Plan        *Stage `yaml:"plan,omitempty" json:"plan,omitempty"`
-	PolicyCheck *Stage `yaml:"policy_check,omitempty" json:"policy_check,omitempty"`
-	Import      *Stage `yaml:"import,omitempty" json:"import,omitempty"`
-	StateRm     *Stage `yaml:"state_rm,omitempty" json:"state_rm,omitempty"`
+	Engine      *string `yaml:"engine,omitempty" json:"engine,omitempty"`
+	Apply       *Stage  `yaml:"apply,omitempty" json:"apply,omitempty"`
+	Plan        *Stage  `yaml:"plan,omitempty" json:"plan,omitempty"`
+	PolicyCheck *Stage  `yaml:"policy_check,omitempty" json:"policy_check,omitempty"`
+	Import      *Stage  `yaml:"import,omitempty" json:"import,omitempty"`
+	StateRm     *Stage  `yaml:"state_rm,omitempty" json:"state_rm,omitempty"`
+	// OnCancel are steps run when a run of the workflow is cancelled, ex.
+	// because its pull request was closed.
+	OnCancel *Stage `yaml:"on_cancel,omitempty" json:"on_cancel,omitempty"`
+	// EnvSchema maps the environment variables the workflow's steps can
+	// reference to their types. If set, a run or env step referencing an
+	// environment variable that isn't declared is a validation error.
+	EnvSchema map[string]string `yaml:"env_schema,omitempty" json:"env_schema,omitempty"`
+	// EnvPassthrough are glob patterns of the server's environment variables
+	// passed to the workflow's run steps. If nil every variable is passed.
+	EnvPassthrough []string `yaml:"env_passthrough,omitempty" json:"env_passthrough,omitempty"`
+	// Timeout is how long a run of one of the workflow's stages can take,
+	// ex. "30m". If set, commands still running then are killed.
+	Timeout *string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
 }
 
 func (w Workflow) Validate() error {
+	engineValid := func(value interface{}) error {
+		engine := value.(*string)
+		if engine != nil && *engine != string(valid.TerraformEngine) && *engine != string(valid.TerragruntEngine) {
+			return fmt.Errorf("%q is not a valid engine, only %q and %q are supported", *engine, valid.TerraformEngine, valid.TerragruntEngine)
+		}
+		return nil
+	}
+
+	envSchemaValid := func(value interface{}) error {
+		schema := value.(map[string]string)
+		if schema == nil {
+			return nil
+		}
+		if err := validateEnvSchemaTypes(schema); err != nil {
+			return err
+		}
+		stages := []struct {
+			name  string
+			stage *Stage
+		}{
+			{"plan", w.Plan},
+			{"apply", w.Apply},
+			{"policy_check", w.PolicyCheck},
+			{"import", w.Import},
+			{"state_rm", w.StateRm},
+			{"on_cancel", w.OnCancel},
+		}
+		for _, s := range stages {
+			// Invalid stages are reported by their own fields.
+			if s.stage == nil || s.stage.Validate() != nil {
+				continue
+			}
+			if err := validateStageEnvRefs(s.name, s.stage, schema); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	envPassthroughValid := func(value interface{}) error {
+		for _, pattern := range value.([]string) {
+			if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+				return fmt.Errorf("%q is not a valid pattern, ex. \"AWS_*\"", pattern)
+			}
+		}
+		return nil
+	}
+
 	return validation.ValidateStruct(&w,
+		validation.Field(&w.Engine, validation.By(engineValid)),
 		validation.Field(&w.Apply),
 		validation.Field(&w.Plan),
 		validation.Field(&w.PolicyCheck),
 		validation.Field(&w.Import),
 		validation.Field(&w.StateRm),
+		validation.Field(&w.OnCancel),
+		validation.Field(&w.EnvSchema, validation.By(envSchemaValid)),
+		validation.Field(&w.EnvPassthrough, validation.By(envPassthroughValid)),
+		validation.Field(&w.Timeout, validation.By(validWorkflowTimeout)),
 	)
 }
 
@@ -33,7 +104,15 @@ func (w Workflow) toValidStage(stage *Stage, defaultStage valid.Stage) valid.Sta
 
 func (w Workflow) ToValid(name string) valid.Workflow {
 	v := valid.Workflow{
-		Name: name,
+		Name:           name,
+		EnvPassthrough: w.EnvPassthrough,
+	}
+	if w.Engine != nil {
+		v.Engine = valid.Engine(*w.Engine)
+	}
+	if w.Timeout != nil {
+		// Validated by Validate.
+		v.Timeout, _ = time.ParseDuration(*w.Timeout)
 	}
 
 	v.Apply = w.toValidStage(w.Apply, valid.DefaultApplyStage)
@@ -41,6 +120,24 @@ func (w Workflow) ToValid(name string) valid.Workflow {
 	v.PolicyCheck = w.toValidStage(w.PolicyCheck, valid.DefaultPolicyCheckStage)
 	v.Import = w.toValidStage(w.Import, valid.DefaultImportStage)
 	v.StateRm = w.toValidStage(w.StateRm, valid.DefaultStateRmStage)
+	v.OnCancel = w.toValidStage(w.OnCancel, valid.Stage{})
 
 	return v
 }
+
+// validWorkflowTimeout validates a workflow's timeout, which must be a positive
+// duration, ex. "30m".
+func validWorkflowTimeout(value interface{}) error {
+	strPtr := value.(*string)
+	if strPtr == nil {
+		return nil
+	}
+	timeout, err := time.ParseDuration(*strPtr)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid duration, ex. \"30m\" or \"1h\"", *strPtr)
+	}
+	if timeout <= 0 {
+		return fmt.Errorf("%q must be greater than 0", *strPtr)
+	}
+	return nil
+}
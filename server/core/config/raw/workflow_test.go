@@ -2,6 +2,7 @@ package raw_test
 
 import (
 	"testing"
+	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/runatlantis/atlantis/server/core/config/raw"
@@ -132,6 +133,220 @@ func TestWorkflow_Validate(t *testing.T) {
 
 	// Unset keys should validate.
 	Ok(t, (raw.Workflow{}).Validate())
+
+	Ok(t, (raw.Workflow{Engine: String("terragrunt")}).Validate())
+	ErrEquals(t, "engine: \"pulumi\" is not a valid engine, only \"terraform\" and \"terragrunt\" are supported.", (raw.Workflow{Engine: String("pulumi")}).Validate())
+}
+
+func TestWorkflow_ValidateEnvSchema(t *testing.T) {
+	cases := []struct {
+		description string
+		input       string
+		runIDEnvVar string
+		expErr      string
+	}{
+		{
+			description: "no schema",
+			input: `
+plan:
+  steps:
+  - run: echo $UNDECLARED`,
+		},
+		{
+			description: "declared, builtin, env step and shell variables",
+			input: `
+env_schema:
+  REGION: string
+plan:
+  steps:
+  - env:
+      name: STACK
+      command: echo ${REGION}-$WORKSPACE
+  - run: for f in *.tf; do echo "$f $STACK"; done; COUNT=1; echo ${COUNT:-0} \$ESCAPED`,
+		},
+		{
+			description: "run ID and terraform CLI config",
+			input: `
+env_schema:
+  REGION: string
+plan:
+  steps:
+  - run: echo $ATLANTIS_RUN_ID $TF_CLI_CONFIG_FILE`,
+		},
+		{
+			description: "custom run ID env var",
+			input: `
+env_schema:
+  REGION: string
+plan:
+  steps:
+  - run: echo $RUN_ID`,
+			runIDEnvVar: "RUN_ID",
+		},
+		{
+			description: "default run ID env var with a custom one set",
+			input: `
+env_schema:
+  REGION: string
+plan:
+  steps:
+  - run: echo $ATLANTIS_RUN_ID`,
+			runIDEnvVar: "RUN_ID",
+			expErr:      "env_schema: run step 1 in the plan stage references undeclared environment variable \"ATLANTIS_RUN_ID\", declare it in env_schema.",
+		},
+		{
+			description: "undeclared in run step",
+			input: `
+env_schema:
+  REGION: string
+plan:
+  steps:
+  - init
+  - run: echo $REGOIN`,
+			expErr: "env_schema: run step 2 in the plan stage references undeclared environment variable \"REGOIN\", declare it in env_schema.",
+		},
+		{
+			description: "undeclared in always",
+			input: `
+env_schema:
+  REGION: string
+apply:
+  steps:
+  - run:
+      command: echo $REGION
+      always: rm -rf ${TMP_DIR}`,
+			expErr: "env_schema: run step 1 in the apply stage references undeclared environment variable \"TMP_DIR\", declare it in env_schema.",
+		},
+		{
+			description: "env step only sets for later steps",
+			input: `
+env_schema:
+  REGION: string
+plan:
+  steps:
+  - run: echo $STACK
+  - env:
+      name: STACK
+      value: prod`,
+			expErr: "env_schema: run step 1 in the plan stage references undeclared environment variable \"STACK\", declare it in env_schema.",
+		},
+		{
+			description: "env step value doesn't match type",
+			input: `
+env_schema:
+  RETRIES: number
+plan:
+  steps:
+  - env:
+      name: RETRIES
+      value: three`,
+			expErr: "env_schema: env step 1 in the plan stage sets \"RETRIES\" to \"three\" but it's declared as a number.",
+		},
+		{
+			description: "invalid type",
+			input: `
+env_schema:
+  DEBUG: boolean`,
+			expErr: "env_schema: \"DEBUG\" has type \"boolean\", only \"string\", \"number\" and \"bool\" are supported.",
+		},
+		{
+			description: "invalid name",
+			input: `
+env_schema:
+  MY-VAR: string`,
+			expErr: "env_schema: \"MY-VAR\" is not a valid environment variable name.",
+		},
+	}
+	validation.ErrorTag = "yaml"
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			raw.RunIDEnvVar = valid.DefaultRunIDEnvVar
+			if c.runIDEnvVar != "" {
+				raw.RunIDEnvVar = c.runIDEnvVar
+			}
+			t.Cleanup(func() { raw.RunIDEnvVar = valid.DefaultRunIDEnvVar })
+			var w raw.Workflow
+			Ok(t, unmarshalString(c.input, &w))
+			err := w.Validate()
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+		})
+	}
+}
+
+func TestWorkflow_ValidateEnvPassthrough(t *testing.T) {
+	cases := []struct {
+		description string
+		input       string
+		expErr      string
+	}{
+		{
+			description: "patterns",
+			input:       `env_passthrough: ["AWS_*", "TF_VAR_?", HOME]`,
+		},
+		{
+			description: "nothing passed",
+			input:       `env_passthrough: []`,
+		},
+		{
+			description: "invalid pattern",
+			input:       `env_passthrough: ["AWS_[*"]`,
+			expErr:      "env_passthrough: \"AWS_[*\" is not a valid pattern, ex. \"AWS_*\".",
+		},
+		{
+			description: "empty pattern",
+			input:       `env_passthrough: [""]`,
+			expErr:      "env_passthrough: \"\" is not a valid pattern, ex. \"AWS_*\".",
+		},
+	}
+	validation.ErrorTag = "yaml"
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			var w raw.Workflow
+			Ok(t, unmarshalString(c.input, &w))
+			err := w.Validate()
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+		})
+	}
+}
+
+func TestWorkflow_ValidateTimeout(t *testing.T) {
+	cases := []struct {
+		input  string
+		expErr string
+	}{
+		{
+			input: `timeout: 30m`,
+		},
+		{
+			input:  `timeout: 30`,
+			expErr: "timeout: \"30\" is not a valid duration, ex. \"30m\" or \"1h\".",
+		},
+		{
+			input:  `timeout: 0s`,
+			expErr: "timeout: \"0s\" must be greater than 0.",
+		},
+	}
+	validation.ErrorTag = "yaml"
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			var w raw.Workflow
+			Ok(t, unmarshalString(c.input, &w))
+			err := w.Validate()
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+		})
+	}
 }
 
 func TestWorkflow_ToValid(t *testing.T) {
@@ -151,6 +366,78 @@ func TestWorkflow_ToValid(t *testing.T) {
 				StateRm:     valid.DefaultStateRmStage,
 			},
 		},
+		{
+			description: "engine set",
+			input: raw.Workflow{
+				Engine: String("terragrunt"),
+			},
+			exp: valid.Workflow{
+				Engine:      valid.TerragruntEngine,
+				Apply:       valid.DefaultApplyStage,
+				Plan:        valid.DefaultPlanStage,
+				PolicyCheck: valid.DefaultPolicyCheckStage,
+				Import:      valid.DefaultImportStage,
+				StateRm:     valid.DefaultStateRmStage,
+			},
+		},
+		{
+			description: "env passthrough set",
+			input: raw.Workflow{
+				EnvPassthrough: []string{"AWS_*"},
+			},
+			exp: valid.Workflow{
+				Apply:          valid.DefaultApplyStage,
+				Plan:           valid.DefaultPlanStage,
+				PolicyCheck:    valid.DefaultPolicyCheckStage,
+				Import:         valid.DefaultImportStage,
+				StateRm:        valid.DefaultStateRmStage,
+				EnvPassthrough: []string{"AWS_*"},
+			},
+		},
+		{
+			description: "timeout set",
+			input: raw.Workflow{
+				Timeout: String("30m"),
+			},
+			exp: valid.Workflow{
+				Apply:       valid.DefaultApplyStage,
+				Plan:        valid.DefaultPlanStage,
+				PolicyCheck: valid.DefaultPolicyCheckStage,
+				Import:      valid.DefaultImportStage,
+				StateRm:     valid.DefaultStateRmStage,
+				Timeout:     30 * time.Minute,
+			},
+		},
+		{
+			description: "on_cancel set",
+			input: raw.Workflow{
+				OnCancel: &raw.Stage{
+					Steps: []raw.Step{
+						{
+							CommandMap: CommandMapType{
+								"run": {"command": "./release-lease.sh"},
+							},
+						},
+					},
+				},
+			},
+			exp: valid.Workflow{
+				Apply:       valid.DefaultApplyStage,
+				Plan:        valid.DefaultPlanStage,
+				PolicyCheck: valid.DefaultPolicyCheckStage,
+				Import:      valid.DefaultImportStage,
+				StateRm:     valid.DefaultStateRmStage,
+				OnCancel: valid.Stage{
+					Steps: []valid.Step{
+						{
+							StepName:   "run",
+							RunCommand: "./release-lease.sh",
+							Output:     "show",
+						},
+					},
+				},
+			},
+		},
 		{
 			description: "fields set",
 			input: raw.Workflow{
@@ -1,5 +1,11 @@
 package valid
 
+import (
+	"path/filepath"
+
+	"github.com/moby/patternmatcher"
+)
+
 // AutoDiscoverMode enum
 type AutoDiscoverMode string
 
@@ -7,8 +13,31 @@ const (
 	AutoDiscoverEnabledMode  AutoDiscoverMode = "enabled"
 	AutoDiscoverDisabledMode AutoDiscoverMode = "disabled"
 	AutoDiscoverAutoMode     AutoDiscoverMode = "auto"
+	// AutoDiscoverBackendMode discovers projects like AutoDiscoverEnabledMode
+	// but only treats directories with a backend or cloud block as projects.
+	// Changes to directories without one are planned in the closest parent
+	// directory with a backend, or in the projects using them as a module.
+	AutoDiscoverBackendMode AutoDiscoverMode = "backend"
 )
 
 type AutoDiscover struct {
 	Mode AutoDiscoverMode
+	// IgnorePaths are patterns of directories, relative to the repo root,
+	// that are never discovered as projects.
+	IgnorePaths []string
+}
+
+// IsPathIgnored returns true if the repo relative directory dir matches one of
+// the IgnorePaths, or is inside a directory that does.
+func (a AutoDiscover) IsPathIgnored(dir string) bool {
+	if len(a.IgnorePaths) == 0 {
+		return false
+	}
+	// The patterns were validated when the config was parsed.
+	pm, err := patternmatcher.New(a.IgnorePaths)
+	if err != nil {
+		return false
+	}
+	ignored, _ := pm.MatchesOrParentMatches(filepath.ToSlash(filepath.Clean(dir)))
+	return ignored
 }
@@ -0,0 +1,28 @@
+package valid_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestAutoDiscover_IsPathIgnored(t *testing.T) {
+	a := valid.AutoDiscover{IgnorePaths: []string{"modules", "sandbox/**/test"}}
+	cases := map[string]bool{
+		".":                         false,
+		"modules":                   true,
+		"./modules/vpc":             true,
+		"stacks/modules":            false,
+		"sandbox/test":              true,
+		"sandbox/alice/dev/test":    true,
+		"sandbox/alice/dev/test/db": true,
+		"sandbox/alice":             false,
+	}
+	for dir, exp := range cases {
+		t.Run(dir, func(t *testing.T) {
+			Equals(t, exp, a.IsPathIgnored(dir))
+		})
+	}
+	Equals(t, false, valid.AutoDiscover{}.IsPathIgnored("modules"))
+}
@@ -0,0 +1,53 @@
+package valid
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// envExprRegex matches the GitHub Actions style ${{ ... }} expressions in an
+// env step's value.
+var envExprRegex = regexp.MustCompile(`\$\{\{(.*?)\}\}`)
+
+// secretRefRegex matches the only supported expression, a reference to a
+// secret, ex. secrets.DB_PASSWORD. The secret's name is the first submatch.
+var secretRefRegex = regexp.MustCompile(`^\s*secrets\.([A-Za-z_][A-Za-z0-9_]*)\s*$`)
+
+// ParseSecretRefs returns the names of the secrets referenced by value with
+// ${{ secrets.NAME }} expressions, in order. It returns an error if value has
+// an expression that isn't a reference to a secret or isn't closed.
+func ParseSecretRefs(value string) ([]string, error) {
+	var names []string
+	for _, match := range envExprRegex.FindAllStringSubmatch(value, -1) {
+		ref := secretRefRegex.FindStringSubmatch(match[1])
+		if ref == nil {
+			return nil, fmt.Errorf("invalid expression %q, only ${{ secrets.NAME }} is supported", match[0])
+		}
+		names = append(names, ref[1])
+	}
+	if rest := envExprRegex.ReplaceAllString(value, ""); strings.Contains(rest, "${{") {
+		return nil, fmt.Errorf("expression in %q isn't closed with }}", value)
+	}
+	return names, nil
+}
+
+// ExpandSecretRefs returns value with each ${{ secrets.NAME }} expression
+// replaced by the secret lookup returns for NAME. Value must be valid
+// according to ParseSecretRefs.
+func ExpandSecretRefs(value string, lookup func(name string) (string, error)) (string, error) {
+	var lookupErr error
+	expanded := envExprRegex.ReplaceAllStringFunc(value, func(expr string) string {
+		ref := secretRefRegex.FindStringSubmatch(envExprRegex.FindStringSubmatch(expr)[1])
+		if lookupErr != nil || ref == nil {
+			return expr
+		}
+		secret, err := lookup(ref[1])
+		if err != nil {
+			lookupErr = err
+			return expr
+		}
+		return secret
+	})
+	return expanded, lookupErr
+}
@@ -0,0 +1,53 @@
+package valid_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestParseSecretRefs(t *testing.T) {
+	cases := []struct {
+		value    string
+		expNames []string
+		expErr   string
+	}{
+		{value: "plain value"},
+		{value: "$HOME and ${NAME}"},
+		{value: "${{ secrets.DB_PASSWORD }}", expNames: []string{"DB_PASSWORD"}},
+		{value: "${{secrets.user}}:${{ secrets.pass_2 }}@db", expNames: []string{"user", "pass_2"}},
+		{value: "${{ github.token }}", expErr: "invalid expression \"${{ github.token }}\", only ${{ secrets.NAME }} is supported"},
+		{value: "${{ secrets.DB-PASSWORD }}", expErr: "invalid expression \"${{ secrets.DB-PASSWORD }}\", only ${{ secrets.NAME }} is supported"},
+		{value: "${{ secrets.TOKEN", expErr: "expression in \"${{ secrets.TOKEN\" isn't closed with }}"},
+	}
+	for _, c := range cases {
+		t.Run(c.value, func(t *testing.T) {
+			names, err := valid.ParseSecretRefs(c.value)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.expNames, names)
+		})
+	}
+}
+
+func TestExpandSecretRefs(t *testing.T) {
+	secrets := map[string]string{"USER": "app", "PASS": "hunter2"}
+	lookup := func(name string) (string, error) {
+		if secret, ok := secrets[name]; ok {
+			return secret, nil
+		}
+		return "", errors.New("unknown secret " + name)
+	}
+
+	expanded, err := valid.ExpandSecretRefs("${{ secrets.USER }}:${{secrets.PASS}}@db", lookup)
+	Ok(t, err)
+	Equals(t, "app:hunter2@db", expanded)
+
+	_, err = valid.ExpandSecretRefs("${{ secrets.USER }}:${{ secrets.TOKEN }}", lookup)
+	ErrEquals(t, "unknown secret TOKEN", err)
+}
@@ -3,7 +3,10 @@ package valid
 import (
 	"fmt"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
 	version "github.com/hashicorp/go-version"
 	"github.com/runatlantis/atlantis/server/logging"
@@ -14,12 +17,18 @@ const MergeableCommandReq = "mergeable"
 const ApprovedCommandReq = "approved"
 const UnDivergedCommandReq = "undiverged"
 const PoliciesPassedCommandReq = "policies_passed"
+
+// OnDestroyCommandReqCondition makes an apply requirement conditional so it's
+// only required if the plan destroys resources, ex. "approved:on_destroy".
+const OnDestroyCommandReqCondition = "on_destroy"
+
 const PlanRequirementsKey = "plan_requirements"
 const ApplyRequirementsKey = "apply_requirements"
 const ImportRequirementsKey = "import_requirements"
 const WorkflowKey = "workflow"
 const AllowedOverridesKey = "allowed_overrides"
 const AllowCustomWorkflowsKey = "allow_custom_workflows"
+const AllowedPlanRequirementsKey = "allowed_plan_requirements"
 const DefaultWorkflowName = "default"
 const DeleteSourceBranchOnMergeKey = "delete_source_branch_on_merge"
 const RepoLockingKey = "repo_locking"
@@ -27,6 +36,33 @@ const RepoLocksKey = "repo_locks"
 const PolicyCheckKey = "policy_check"
 const CustomPolicyCheckKey = "custom_policy_check"
 const AutoDiscoverKey = "autodiscover"
+const PlanTTLKey = "plan_ttl"
+
+// SplitCommandReq splits a command requirement into the requirement and the
+// condition it's required on, if any, ex. "approved:on_destroy" is split into
+// "approved" and "on_destroy".
+func SplitCommandReq(req string) (string, string) {
+	name, condition, _ := strings.Cut(req, ":")
+	return name, condition
+}
+
+// Status strategies set which commit statuses are set for a pull request.
+// StatusStrategyAggregate only sets one status per command covering every
+// project and StatusStrategyPerProject only sets a status per project.
+const (
+	StatusStrategyAggregate  = "aggregate"
+	StatusStrategyPerProject = "per-project"
+)
+
+// Clean workspace policies set when the working dirs of a pull request are
+// deleted so they're cloned again. CleanWorkspaceNever reuses them,
+// CleanWorkspaceAlways deletes them before every plan and
+// CleanWorkspaceOnError deletes them after a command that errored.
+const (
+	CleanWorkspaceNever   = "never"
+	CleanWorkspaceAlways  = "always"
+	CleanWorkspaceOnError = "on-error"
+)
 
 // DefaultAtlantisFile is the default name of the config file for each repo.
 const DefaultAtlantisFile = "atlantis.yaml"
@@ -77,6 +113,7 @@ type Repo struct {
 	Workflow                  *Workflow
 	PostWorkflowHooks         []*WorkflowHook
 	AllowedWorkflows          []string
+	AllowedPlanRequirements   []string
 	AllowedOverrides          []string
 	AllowCustomWorkflows      *bool
 	DeleteSourceBranchOnMerge *bool
@@ -85,6 +122,45 @@ type Repo struct {
 	PolicyCheck               *bool
 	CustomPolicyCheck         *bool
 	AutoDiscover              *AutoDiscover
+	CloneDepth                *int
+	// AllowedPlanRefsRegex matches the git refs that can be planned with
+	// atlantis plan --ref. If nil no refs can be planned.
+	AllowedPlanRefsRegex *regexp.Regexp
+	// Quiet is true if successful plans shouldn't be commented on, only
+	// failures.
+	Quiet *bool
+	// PlanTTL is how long plans can be applied for. If 0 plans don't expire.
+	PlanTTL time.Duration
+	// ResultExport is where plan and apply results are exported to. If nil
+	// they're only commented on pull requests.
+	ResultExport *ResultExport
+	// PluginCacheDir is the Terraform plugin cache dir the repo's projects
+	// share. If empty the server's plugin cache is used.
+	PluginCacheDir string
+	// ProviderMirror, if set, is where the repo's projects install providers
+	// from instead of their registries.
+	ProviderMirror *ProviderMirror
+	// StatusStrategy is which commit statuses are set for the repo's pull
+	// requests, StatusStrategyAggregate or StatusStrategyPerProject. If empty
+	// the server's strategy is used.
+	StatusStrategy string
+	// AllowedRunCommands are the executables the repo's run steps can run. If
+	// nil any executable that isn't denied can be run.
+	AllowedRunCommands []string
+	// DeniedRunCommands are the executables the repo's run steps can't run.
+	DeniedRunCommands []string
+	// MaxProjectsPerPR is the most projects a command can run on without
+	// targeting specific projects. If nil or 0 there's no limit.
+	MaxProjectsPerPR *int
+	// RequireApplyReason is true if applies commented on pull requests must
+	// give a reason with --reason.
+	RequireApplyReason *bool
+	// AllowedCommands are the names of the commands that can be run on the
+	// repo, ex. plan. If nil any command can be run.
+	AllowedCommands []string
+	// CleanWorkspace is when the working dirs of the repo's pull requests are
+	// deleted, one of the clean workspace policies. If empty they're reused.
+	CleanWorkspace string
 }
 
 type MergedProjectCfg struct {
@@ -107,6 +183,38 @@ type MergedProjectCfg struct {
 	RepoLocks                 RepoLocks
 	PolicyCheck               bool
 	CustomPolicyCheck         bool
+	// PlanOnly is true if the project sets lock: false. Its repo locks are
+	// disabled and it can't be applied.
+	PlanOnly bool
+	// PlanTTL is how long plans can be applied for before they're discarded.
+	// If 0 plans don't expire.
+	PlanTTL time.Duration
+	// PluginCacheDir is the Terraform plugin cache dir set by the repo's
+	// plugin_cache_dir. If empty the server's plugin cache is used.
+	PluginCacheDir string
+	// ProviderMirror is the provider mirror set by the repo's
+	// provider_mirror. If nil providers are installed from their registries.
+	ProviderMirror *ProviderMirror
+	// RunCommandPolicy restricts the executables run steps can run.
+	RunCommandPolicy RunCommandPolicy
+	// Chdir is whether built-in steps run terraform with -chdir set to
+	// RepoRelDir from the repo's root.
+	Chdir bool
+	// CLIConfig is the path, relative to RepoRelDir, of the Terraform CLI
+	// config set by the project's cli_config.
+	CLIConfig string
+	// TFVars are the Terraform variables set by the project's tf_vars.
+	TFVars map[string]string
+	// BackendConfigs are the paths, relative to RepoRelDir, of the backend
+	// config files set by the project's backend_configs, by workspace.
+	BackendConfigs map[string]string
+	// WorkflowTrace is each config that set Workflow, in the order they were
+	// applied, so the last one chose it.
+	WorkflowTrace []string
+	// RepoCfgKeys are the keys of the server-side settings whose value was
+	// overridden by the repo config, ex. apply_requirements. Every other
+	// server-side setting comes from the server-side repo config.
+	RepoCfgKeys []string
 }
 
 // WorkflowHook is a map of custom run commands to run before or after workflows.
@@ -274,6 +382,13 @@ func (r Repo) IDString() string {
 func (g GlobalCfg) MergeProjectCfg(log logging.SimpleLogging, repoID string, proj Project, rCfg RepoCfg) MergedProjectCfg {
 	log.Debug("MergeProjectCfg started")
 	planReqs, applyReqs, importReqs, workflow, allowedOverrides, allowCustomWorkflows, deleteSourceBranchOnMerge, repoLocks, policyCheck, customPolicyCheck, _ := g.getMatchingCfg(log, repoID)
+	workflowTrace := g.workflowTrace(repoID)
+	var repoCfgKeys []string
+	setByRepo := func(key string) {
+		if !slices.Contains(repoCfgKeys, key) {
+			repoCfgKeys = append(repoCfgKeys, key)
+		}
+	}
 	// If repos are allowed to override certain keys then override them.
 	for _, key := range allowedOverrides {
 		switch key {
@@ -281,11 +396,13 @@ func (g GlobalCfg) MergeProjectCfg(log logging.SimpleLogging, repoID string, pro
 			if proj.PlanRequirements != nil {
 				log.Debug("overriding server-defined %s with repo settings: [%s]", PlanRequirementsKey, strings.Join(proj.PlanRequirements, ","))
 				planReqs = proj.PlanRequirements
+				setByRepo(PlanRequirementsKey)
 			}
 		case ApplyRequirementsKey:
 			if proj.ApplyRequirements != nil {
 				log.Debug("overriding server-defined %s with repo settings: [%s]", ApplyRequirementsKey, strings.Join(proj.ApplyRequirements, ","))
 				applyReqs = proj.ApplyRequirements
+				setByRepo(ApplyRequirementsKey)
 
 				// Preserve policies_passed req if policy check is enabled
 				if policyCheck {
@@ -296,6 +413,7 @@ func (g GlobalCfg) MergeProjectCfg(log logging.SimpleLogging, repoID string, pro
 			if proj.ImportRequirements != nil {
 				log.Debug("overriding server-defined %s with repo settings: [%s]", ImportRequirementsKey, strings.Join(proj.ImportRequirements, ","))
 				importReqs = proj.ImportRequirements
+				setByRepo(ImportRequirementsKey)
 			}
 		case WorkflowKey:
 			if proj.WorkflowName != nil {
@@ -305,6 +423,7 @@ func (g GlobalCfg) MergeProjectCfg(log logging.SimpleLogging, repoID string, pro
 				// define its own workflow. We also know that a workflow will
 				// exist with this name due to earlier validation.
 				name := *proj.WorkflowName
+				definedIn := "the server-side repo config"
 				for k, v := range g.Workflows {
 					if k == name {
 						workflow = v
@@ -314,9 +433,16 @@ func (g GlobalCfg) MergeProjectCfg(log logging.SimpleLogging, repoID string, pro
 					for k, v := range rCfg.Workflows {
 						if k == name {
 							workflow = v
+							definedIn = "the repo config"
 						}
 					}
 				}
+				project := fmt.Sprintf("project %q", proj.GetName())
+				if proj.Name == nil {
+					project = fmt.Sprintf("project in dir %q workspace %q", proj.Dir, proj.Workspace)
+				}
+				workflowTrace = append(workflowTrace, fmt.Sprintf("%s in the repo config sets workflow %q, defined in %s", project, name, definedIn))
+				setByRepo(WorkflowKey)
 				log.Debug("overriding server-defined %s with repo-specified workflow: %q", WorkflowKey, workflow.Name)
 			}
 		case DeleteSourceBranchOnMergeKey:
@@ -325,17 +451,20 @@ func (g GlobalCfg) MergeProjectCfg(log logging.SimpleLogging, repoID string, pro
 			if rCfg.DeleteSourceBranchOnMerge != nil && deleteSourceBranchOnMerge != *rCfg.DeleteSourceBranchOnMerge {
 				log.Debug("overriding server-defined %s with repo settings: [%t]", DeleteSourceBranchOnMergeKey, rCfg.DeleteSourceBranchOnMerge)
 				deleteSourceBranchOnMerge = *rCfg.DeleteSourceBranchOnMerge
+				setByRepo(DeleteSourceBranchOnMergeKey)
 			}
 			//Then we check whether the more granular project based config is
 			//different. If it is then we set it.
 			if proj.DeleteSourceBranchOnMerge != nil && deleteSourceBranchOnMerge != *proj.DeleteSourceBranchOnMerge {
 				log.Debug("overriding repo-root-defined %s with repo settings: [%t]", DeleteSourceBranchOnMergeKey, *proj.DeleteSourceBranchOnMerge)
 				deleteSourceBranchOnMerge = *proj.DeleteSourceBranchOnMerge
+				setByRepo(DeleteSourceBranchOnMergeKey)
 			}
 			log.Debug("merged deleteSourceBranchOnMerge: [%t]", deleteSourceBranchOnMerge)
 		case RepoLockingKey:
 			if proj.RepoLocking != nil {
 				log.Debug("overriding server-defined %s with repo settings: [%t]", RepoLockingKey, *proj.RepoLocking)
+				setByRepo(RepoLocksKey)
 				if *proj.RepoLocking && repoLocks.Mode == RepoLocksDisabledMode {
 					repoLocks.Mode = DefaultRepoLocksMode
 				} else if !*proj.RepoLocking {
@@ -348,28 +477,48 @@ func (g GlobalCfg) MergeProjectCfg(log logging.SimpleLogging, repoID string, pro
 			if rCfg.RepoLocks != nil && repoLocks.Mode != rCfg.RepoLocks.Mode {
 				log.Debug("overriding server-defined %s with repo settings: [%#v]", RepoLocksKey, rCfg.RepoLocks)
 				repoLocks = *rCfg.RepoLocks
+				setByRepo(RepoLocksKey)
 			}
 			//Then we check whether the more granular project based config is
 			//different. If it is then we set it.
 			if proj.RepoLocks != nil && repoLocks.Mode != proj.RepoLocks.Mode {
 				log.Debug("overriding repo-root-defined %s with repo settings: [%#v]", RepoLocksKey, *proj.RepoLocks)
 				repoLocks = *proj.RepoLocks
+				setByRepo(RepoLocksKey)
 			}
 			log.Debug("merged repoLocks: [%#v]", repoLocks)
 		case PolicyCheckKey:
 			if proj.PolicyCheck != nil {
 				log.Debug("overriding server-defined %s with repo settings: [%t]", PolicyCheckKey, *proj.PolicyCheck)
 				policyCheck = *proj.PolicyCheck
+				setByRepo(PolicyCheckKey)
 			}
 		case CustomPolicyCheckKey:
 			if proj.CustomPolicyCheck != nil {
 				log.Debug("overriding server-defined %s with repo settings: [%t]", CustomPolicyCheckKey, *proj.CustomPolicyCheck)
 				customPolicyCheck = *proj.CustomPolicyCheck
+				setByRepo(CustomPolicyCheckKey)
 			}
 		}
 		log.Debug("MergeProjectCfg completed")
 	}
 
+	// Projects that don't lock can't be applied so they don't need the
+	// server's permission to disable repo locks.
+	planOnly := proj.Lock != nil && !*proj.Lock
+	if planOnly {
+		log.Debug("project sets lock: false, disabling repo locks and apply")
+		repoLocks.Mode = RepoLocksDisabledMode
+		setByRepo(RepoLocksKey)
+	}
+
+	// Repos can shorten the server's plan TTL but not lengthen it.
+	planTTL := g.RepoPlanTTL(repoID)
+	if proj.PlanTTL > 0 && (planTTL == 0 || proj.PlanTTL < planTTL) {
+		planTTL = proj.PlanTTL
+		setByRepo(PlanTTLKey)
+	}
+
 	log.Debug("final settings: %s: [%s], %s: [%s], %s: [%s], %s: %s",
 		PlanRequirementsKey, strings.Join(planReqs, ","), ApplyRequirementsKey, strings.Join(applyReqs, ","), ImportRequirementsKey, strings.Join(importReqs, ","), WorkflowKey, workflow.Name)
 
@@ -382,7 +531,7 @@ func (g GlobalCfg) MergeProjectCfg(log logging.SimpleLogging, repoID string, pro
 		Workspace:                 proj.Workspace,
 		DependsOn:                 proj.DependsOn,
 		Name:                      proj.GetName(),
-		AutoplanEnabled:           proj.Autoplan.Enabled,
+		AutoplanEnabled:           proj.Autoplan.EnabledForWorkspace(proj.Workspace),
 		TerraformVersion:          proj.TerraformVersion,
 		RepoCfgVersion:            rCfg.Version,
 		PolicySets:                g.PolicySets,
@@ -391,6 +540,17 @@ func (g GlobalCfg) MergeProjectCfg(log logging.SimpleLogging, repoID string, pro
 		RepoLocks:                 repoLocks,
 		PolicyCheck:               policyCheck,
 		CustomPolicyCheck:         customPolicyCheck,
+		PlanOnly:                  planOnly,
+		PlanTTL:                   planTTL,
+		PluginCacheDir:            g.RepoPluginCacheDir(repoID),
+		ProviderMirror:            g.RepoProviderMirror(repoID),
+		RunCommandPolicy:          g.RepoRunCommandPolicy(repoID),
+		Chdir:                     proj.Chdir,
+		CLIConfig:                 proj.CLIConfig,
+		TFVars:                    proj.TFVars,
+		BackendConfigs:            proj.BackendConfigs,
+		WorkflowTrace:             workflowTrace,
+		RepoCfgKeys:               repoCfgKeys,
 	}
 }
 
@@ -414,9 +574,32 @@ func (g GlobalCfg) DefaultProjCfg(log logging.SimpleLogging, repoID string, repo
 		RepoLocks:                 repoLocks,
 		PolicyCheck:               policyCheck,
 		CustomPolicyCheck:         customPolicyCheck,
+		PlanTTL:                   g.RepoPlanTTL(repoID),
+		PluginCacheDir:            g.RepoPluginCacheDir(repoID),
+		ProviderMirror:            g.RepoProviderMirror(repoID),
+		RunCommandPolicy:          g.RepoRunCommandPolicy(repoID),
+		WorkflowTrace:             g.workflowTrace(repoID),
 	}
 }
 
+// workflowTrace returns each server-side config that sets the workflow of
+// repoID's projects, in the order they're applied, as they're described in
+// logs by getMatchingCfg.
+func (g GlobalCfg) workflowTrace(repoID string) []string {
+	var trace []string
+	for i, repo := range g.Repos {
+		if !repo.IDMatches(repoID) || repo.Workflow == nil {
+			continue
+		}
+		from := "default server config"
+		if i > 0 {
+			from = fmt.Sprintf("repos[%d], id: %s in the server-side repo config", i, repo.IDString())
+		}
+		trace = append(trace, fmt.Sprintf("%s sets workflow %q", from, repo.Workflow.Name))
+	}
+	return trace
+}
+
 // RepoAutoDiscoverCfg returns the AutoDiscover config from the global config
 // for the repo with id repoID. If no matching repo is found or there is no
 // AutoDiscover config then this function returns nil.
@@ -428,6 +611,173 @@ func (g GlobalCfg) RepoAutoDiscoverCfg(repoID string) *AutoDiscover {
 	return nil
 }
 
+// RepoCloneDepth returns the clone_depth from the global config for the repo
+// with id repoID. If no matching repo is found or it doesn't set clone_depth
+// then this function returns nil.
+func (g GlobalCfg) RepoCloneDepth(repoID string) *int {
+	repo := g.MatchingRepo(repoID)
+	if repo != nil {
+		return repo.CloneDepth
+	}
+	return nil
+}
+
+// RepoAllowsPlanRef returns true if the global config allows planning the git
+// ref for the repo with id repoID, ex. with atlantis plan --ref.
+func (g GlobalCfg) RepoAllowsPlanRef(repoID string, ref string) bool {
+	repo := g.MatchingRepo(repoID)
+	return repo != nil && repo.AllowedPlanRefsRegex != nil && repo.AllowedPlanRefsRegex.MatchString(ref)
+}
+
+// RepoQuiet returns true if the global config sets quiet for the repo with
+// id repoID so successful plans aren't commented on.
+func (g GlobalCfg) RepoQuiet(repoID string) bool {
+	repo := g.MatchingRepo(repoID)
+	return repo != nil && repo.Quiet != nil && *repo.Quiet
+}
+
+// RepoPlanTTL returns the plan_ttl from the global config for the repo with id
+// repoID. Like other settings, later matching repos override earlier ones. It
+// returns 0 if plans don't expire.
+func (g GlobalCfg) RepoPlanTTL(repoID string) time.Duration {
+	var ttl time.Duration
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) && repo.PlanTTL > 0 {
+			ttl = repo.PlanTTL
+		}
+	}
+	return ttl
+}
+
+// RepoMaxProjectsPerPR returns the max_projects_per_pr from the global config
+// for the repo with id repoID. Like other settings, later matching repos
+// override earlier ones. It returns 0 if there's no limit.
+func (g GlobalCfg) RepoMaxProjectsPerPR(repoID string) int {
+	var max int
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) && repo.MaxProjectsPerPR != nil {
+			max = *repo.MaxProjectsPerPR
+		}
+	}
+	return max
+}
+
+// RepoRequireApplyReason returns true if the global config sets
+// require_apply_reason for the repo with id repoID. Like other settings,
+// later matching repos override earlier ones.
+func (g GlobalCfg) RepoRequireApplyReason(repoID string) bool {
+	var require bool
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) && repo.RequireApplyReason != nil {
+			require = *repo.RequireApplyReason
+		}
+	}
+	return require
+}
+
+// RepoPluginCacheDir returns the plugin_cache_dir from the global config for
+// the repo with id repoID. Like other settings, later matching repos override
+// earlier ones. It returns "" if the server's plugin cache is used.
+func (g GlobalCfg) RepoPluginCacheDir(repoID string) string {
+	var dir string
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) && repo.PluginCacheDir != "" {
+			dir = repo.PluginCacheDir
+		}
+	}
+	return dir
+}
+
+// RepoProviderMirror returns the provider_mirror from the global config for
+// the repo with id repoID. Like other settings, later matching repos override
+// earlier ones. It returns nil if providers are installed from their
+// registries.
+func (g GlobalCfg) RepoProviderMirror(repoID string) *ProviderMirror {
+	var mirror *ProviderMirror
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) && repo.ProviderMirror != nil {
+			mirror = repo.ProviderMirror
+		}
+	}
+	return mirror
+}
+
+// RepoStatusStrategy returns the status_strategy from the global config for
+// the repo with id repoID. Later matching repos override earlier ones. It
+// returns an empty string if it isn't set.
+func (g GlobalCfg) RepoStatusStrategy(repoID string) string {
+	var strategy string
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) && repo.StatusStrategy != "" {
+			strategy = repo.StatusStrategy
+		}
+	}
+	return strategy
+}
+
+// RepoAllowedCommands returns the allowed_commands from the global config for
+// the repo with id repoID. Later matching repos override earlier ones. It
+// returns nil if any command can be run.
+func (g GlobalCfg) RepoAllowedCommands(repoID string) []string {
+	var allowed []string
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) && repo.AllowedCommands != nil {
+			allowed = repo.AllowedCommands
+		}
+	}
+	return allowed
+}
+
+// RepoAllowsCommand returns true if the command named cmdName can be run on
+// the repo with id repoID as set by allowed_commands.
+func (g GlobalCfg) RepoAllowsCommand(repoID string, cmdName string) bool {
+	allowed := g.RepoAllowedCommands(repoID)
+	return allowed == nil || utils.SlicesContains(allowed, cmdName)
+}
+
+// RepoCleanWorkspace returns the clean_workspace from the global config for
+// the repo with id repoID. Later matching repos override earlier ones. It
+// returns CleanWorkspaceNever if it isn't set.
+func (g GlobalCfg) RepoCleanWorkspace(repoID string) string {
+	policy := CleanWorkspaceNever
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) && repo.CleanWorkspace != "" {
+			policy = repo.CleanWorkspace
+		}
+	}
+	return policy
+}
+
+// RepoRunCommandPolicy returns the policy set by allowed_run_commands and
+// denied_run_commands in the global config for the repo with id repoID. Later
+// matching repos override each list set by earlier ones.
+func (g GlobalCfg) RepoRunCommandPolicy(repoID string) RunCommandPolicy {
+	var policy RunCommandPolicy
+	for _, repo := range g.Repos {
+		if !repo.IDMatches(repoID) {
+			continue
+		}
+		if repo.AllowedRunCommands != nil {
+			policy.Allowed = repo.AllowedRunCommands
+		}
+		if repo.DeniedRunCommands != nil {
+			policy.Denied = repo.DeniedRunCommands
+		}
+	}
+	return policy
+}
+
+// RepoResultExport returns the result_export from the global config for the
+// repo with id repoID. If no matching repo is found or it doesn't set
+// result_export then this function returns nil.
+func (g GlobalCfg) RepoResultExport(repoID string) *ResultExport {
+	repo := g.MatchingRepo(repoID)
+	if repo != nil {
+		return repo.ResultExport
+	}
+	return nil
+}
+
 // ValidateRepoCfg validates that rCfg for repo with id repoID is valid based
 // on our global config.
 func (g GlobalCfg) ValidateRepoCfg(rCfg RepoCfg, repoID string) error {
@@ -476,6 +826,26 @@ func (g GlobalCfg) ValidateRepoCfg(rCfg RepoCfg, repoID string) error {
 		}
 	}
 
+	// Check plan requirements are allowed. An empty list allows any
+	// requirement.
+	var allowedPlanReqs []string
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) {
+			if repo.AllowedPlanRequirements != nil {
+				allowedPlanReqs = repo.AllowedPlanRequirements
+			}
+		}
+	}
+	if len(allowedPlanReqs) != 0 {
+		for _, p := range rCfg.Projects {
+			for _, req := range p.PlanRequirements {
+				if !utils.SlicesContains(allowedPlanReqs, req) {
+					return fmt.Errorf("plan requirement '%s' is not allowed for this repo: server-side config '%s' only allows [%s]", req, AllowedPlanRequirementsKey, strings.Join(allowedPlanReqs, ","))
+				}
+			}
+		}
+	}
+
 	// Check custom workflows.
 	var allowCustomWorkflows bool
 	for _, repo := range g.Repos {
@@ -490,6 +860,21 @@ func (g GlobalCfg) ValidateRepoCfg(rCfg RepoCfg, repoID string) error {
 		return fmt.Errorf("repo config not allowed to define custom workflows: server-side config needs '%s: true'", AllowCustomWorkflowsKey)
 	}
 
+	// Check the commands of custom workflows' run steps are allowed. They're
+	// also checked before running in case they're from server-side workflows.
+	if policy := g.RepoRunCommandPolicy(repoID); !policy.IsEmpty() {
+		var names []string
+		for name := range rCfg.Workflows {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := rCfg.Workflows[name].CheckRunCommands(policy); err != nil {
+				return fmt.Errorf("workflow %q: %s", name, err)
+			}
+		}
+	}
+
 	// Check if the repo has set a workflow name that doesn't exist.
 	for _, p := range rCfg.Projects {
 		if p.WorkflowName != nil {
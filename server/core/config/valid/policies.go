@@ -1,7 +1,9 @@
 package valid
 
 import (
+	"io"
 	"strings"
+	"text/template"
 
 	version "github.com/hashicorp/go-version"
 )
@@ -32,6 +34,38 @@ type PolicySet struct {
 	Name         string
 	ApproveCount int
 	Owners       PolicyOwners
+	// ExemptionTemplate is rendered for each failing policy of the set to a
+	// snippet, ex. a conftest exception, that would exempt the resource from
+	// the policy. If empty no snippets are rendered.
+	ExemptionTemplate string
+}
+
+// PolicyFailure is a policy that failed for a resource. It's the data an
+// exemption template is rendered with.
+type PolicyFailure struct {
+	// PolicySet is the name of the policy set of the policy.
+	PolicySet string
+	// Namespace is the Rego package of the policy, ex. main.
+	Namespace string
+	// Resource is the address of the resource the policy failed for, ex.
+	// aws_s3_bucket.logs. It's empty if the failure message doesn't have one.
+	Resource string
+	// Message is the failure message of the policy.
+	Message string
+}
+
+// ParsePolicyExemptionTemplate parses the exemption template of a policy set.
+// It returns an error if the template refers to fields PolicyFailure doesn't
+// have.
+func ParsePolicyExemptionTemplate(tmpl string) (*template.Template, error) {
+	t, err := template.New("exemption_template").Funcs(StepTemplateFuncs()).Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Execute(io.Discard, PolicyFailure{}); err != nil {
+		return nil, err
+	}
+	return t, nil
 }
 
 func (p *PolicySets) HasPolicies() bool {
@@ -0,0 +1,23 @@
+package valid
+
+import "path/filepath"
+
+// ProjectOrder is the order a repo's projects are run and shown in comments
+// in, set by its project_order. Projects in Names come first in that order
+// and the rest follow sorted by name, dir and workspace.
+type ProjectOrder struct {
+	// Names are project names, or dirs for projects without a name. It's
+	// empty if the repo sorts every project.
+	Names []string
+}
+
+// Rank returns the position in Names of the project with name, or of its dir
+// if it has no name. Projects that aren't listed rank after every listed one.
+func (o ProjectOrder) Rank(name string, dir string) int {
+	for i, n := range o.Names {
+		if n == name || (name == "" && filepath.Clean(n) == filepath.Clean(dir)) {
+			return i
+		}
+	}
+	return len(o.Names)
+}
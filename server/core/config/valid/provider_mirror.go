@@ -0,0 +1,44 @@
+package valid
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// ProviderMirror is where Terraform installs providers from instead of their
+// registries, set by a repo's provider_mirror. Exactly one of URL or Path is
+// set.
+type ProviderMirror struct {
+	// URL is the base URL of a network mirror. It always ends in a slash.
+	URL string
+	// Path is the absolute path of a filesystem mirror.
+	Path string
+}
+
+// ParseProviderMirror returns the mirror for mirror, either the https URL of
+// a network mirror or the absolute path of a filesystem mirror.
+func ParseProviderMirror(mirror string) (ProviderMirror, error) {
+	if strings.Contains(mirror, "://") {
+		u, err := url.Parse(mirror)
+		if err != nil {
+			return ProviderMirror{}, fmt.Errorf("invalid URL %q: %s", mirror, err)
+		}
+		if u.Scheme != "https" || u.Host == "" {
+			return ProviderMirror{}, fmt.Errorf("network mirror URL %q must be an https URL, ex. \"https://terraform-mirror.example.com/providers/\"", mirror)
+		}
+		if u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+			return ProviderMirror{}, fmt.Errorf("network mirror URL %q can't have credentials, a query or a fragment", mirror)
+		}
+		// Terraform appends paths to the URL so it must end in a slash.
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+		return ProviderMirror{URL: u.String()}, nil
+	}
+	if !filepath.IsAbs(mirror) {
+		return ProviderMirror{}, fmt.Errorf("must be an https URL or an absolute path, found %q", mirror)
+	}
+	return ProviderMirror{Path: filepath.Clean(mirror)}, nil
+}
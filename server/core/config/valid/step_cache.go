@@ -0,0 +1,40 @@
+package valid
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// StepCache is the cache of a run step. Paths are restored from the server's
+// step cache before the step runs if it has an entry for the rendered Key, and
+// saved to it after the step succeeds if it didn't.
+type StepCache struct {
+	// Key is a template rendered to the key of the cache entry, ex.
+	// npm-{{ .Checksum "package-lock.json" }}.
+	Key string
+	// Paths are the directories and files that are cached, relative to the
+	// project directory.
+	Paths []string
+}
+
+// ParseStepCache returns the cache of a run step with key and paths, or an
+// error if key isn't a valid template or a path is outside the project
+// directory.
+func ParseStepCache(key string, paths []string) (StepCache, error) {
+	if strings.TrimSpace(key) == "" {
+		return StepCache{}, fmt.Errorf("key can't be empty")
+	}
+	if _, err := template.New("key").Funcs(StepTemplateFuncs()).Parse(key); err != nil {
+		return StepCache{}, fmt.Errorf("invalid key %q: %s", key, err)
+	}
+	if len(paths) == 0 {
+		return StepCache{}, fmt.Errorf("paths can't be empty")
+	}
+	for _, p := range paths {
+		if err := CheckProjectPath(p); err != nil {
+			return StepCache{}, fmt.Errorf("path %s", err)
+		}
+	}
+	return StepCache{Key: key, Paths: paths}, nil
+}
@@ -0,0 +1,399 @@
+package valid
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StepConditionVars are the variables a run step's if condition can use, and
+// their types.
+var StepConditionVars = map[string]StepConditionType{
+	"workspace":    StepConditionString,
+	"dir":          StepConditionString,
+	"project_name": StepConditionString,
+	"repo":         StepConditionString,
+	"base_branch":  StepConditionString,
+	"head_branch":  StepConditionString,
+	"pull_num":     StepConditionNumber,
+	"pull_author":  StepConditionString,
+	"user":         StepConditionString,
+	"command":      StepConditionString,
+	"num_changes":  StepConditionNumber,
+}
+
+// StepConditionType is the type of a value in a step condition.
+type StepConditionType string
+
+const (
+	StepConditionString StepConditionType = "string"
+	StepConditionNumber StepConditionType = "number"
+	StepConditionBool   StepConditionType = "bool"
+)
+
+// StepCondition is a parsed if condition of a run step, ex.
+// "num_changes > 10 && workspace == 'prod'". Conditions are made of the
+// variables in StepConditionVars, string, number and boolean literals, the
+// comparisons ==, !=, <, <=, > and >=, and the operators &&, || and !, with
+// parentheses for grouping. Conditions are type checked when they're parsed
+// so evaluating them only fails if a variable is missing.
+type StepCondition struct {
+	// Expr is the condition as it was configured.
+	Expr string
+	root conditionNode
+	vars map[string]bool
+}
+
+// ParseStepCondition parses and type checks a run step's if condition. The
+// condition must be a boolean.
+func ParseStepCondition(expr string) (*StepCondition, error) {
+	tokens, err := tokenizeCondition(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %s", expr, err)
+	}
+	p := &conditionParser{tokens: tokens, vars: make(map[string]bool)}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = fmt.Errorf("unexpected %s", p.peek())
+	}
+	if err == nil && root.typ() != StepConditionBool {
+		err = fmt.Errorf("must be a boolean, found a %s", root.typ())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %s", expr, err)
+	}
+	return &StepCondition{Expr: expr, root: root, vars: p.vars}, nil
+}
+
+// Uses returns true if the condition uses the variable name.
+func (c *StepCondition) Uses(name string) bool {
+	return c.vars[name]
+}
+
+// Eval evaluates the condition with vars, which holds a string for every
+// string variable it uses and a float64 for every number variable.
+func (c *StepCondition) Eval(vars map[string]interface{}) (bool, error) {
+	v, err := c.root.eval(vars)
+	if err != nil {
+		return false, fmt.Errorf("evaluating condition %q: %s", c.Expr, err)
+	}
+	return v.(bool), nil
+}
+
+// conditionNode is a node of a parsed condition. Nodes are type checked when
+// they're built so eval returns a value of typ().
+type conditionNode interface {
+	typ() StepConditionType
+	eval(vars map[string]interface{}) (interface{}, error)
+}
+
+type literalNode struct {
+	t     StepConditionType
+	value interface{}
+}
+
+func (n literalNode) typ() StepConditionType { return n.t }
+
+func (n literalNode) eval(map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+type varNode struct {
+	t    StepConditionType
+	name string
+}
+
+func (n varNode) typ() StepConditionType { return n.t }
+
+func (n varNode) eval(vars map[string]interface{}) (interface{}, error) {
+	v, ok := vars[n.name]
+	if !ok {
+		return nil, fmt.Errorf("variable %q isn't set", n.name)
+	}
+	switch n.t {
+	case StepConditionNumber:
+		if _, ok := v.(float64); ok {
+			return v, nil
+		}
+	case StepConditionString:
+		if _, ok := v.(string); ok {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("variable %q isn't a %s", n.name, n.t)
+}
+
+type notNode struct {
+	operand conditionNode
+}
+
+func (n notNode) typ() StepConditionType { return StepConditionBool }
+
+func (n notNode) eval(vars map[string]interface{}) (interface{}, error) {
+	v, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	return !v.(bool), nil
+}
+
+type binaryNode struct {
+	op          string
+	left, right conditionNode
+}
+
+func (n binaryNode) typ() StepConditionType { return StepConditionBool }
+
+func (n binaryNode) eval(vars map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	// && and || short circuit.
+	switch n.op {
+	case "&&":
+		if !left.(bool) {
+			return false, nil
+		}
+		return n.right.eval(vars)
+	case "||":
+		if left.(bool) {
+			return true, nil
+		}
+		return n.right.eval(vars)
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	}
+	l, r := left.(float64), right.(float64)
+	switch n.op {
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	default:
+		return l >= r, nil
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenOp
+	tokenString
+	tokenNumber
+	tokenIdent
+)
+
+type conditionToken struct {
+	kind tokenKind
+	text string
+}
+
+func (t conditionToken) String() string {
+	if t.kind == tokenEOF {
+		return "end of condition"
+	}
+	return strconv.Quote(t.text)
+}
+
+// conditionOps are the operators of conditions, with two character
+// operators first so they're matched before their prefixes.
+var conditionOps = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"}
+
+// tokenizeCondition splits expr into tokens. String tokens hold the string
+// without its quotes.
+func tokenizeCondition(expr string) ([]conditionToken, error) {
+	var tokens []conditionToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string starting at %d", i+1)
+			}
+			tokens = append(tokens, conditionToken{tokenString, expr[i+1 : i+1+end]})
+			i += end + 2
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(expr) && (expr[j] >= '0' && expr[j] <= '9' || expr[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, conditionToken{tokenNumber, expr[i:j]})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(expr) && (expr[j] == '_' || expr[j] >= 'a' && expr[j] <= 'z' || expr[j] >= 'A' && expr[j] <= 'Z' || expr[j] >= '0' && expr[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, conditionToken{tokenIdent, expr[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, o := range conditionOps {
+				if strings.HasPrefix(expr[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i+1)
+			}
+			tokens = append(tokens, conditionToken{tokenOp, op})
+			i += len(op)
+		}
+	}
+	return append(tokens, conditionToken{kind: tokenEOF}), nil
+}
+
+// conditionParser is a recursive descent parser of condition tokens. From
+// lowest to highest precedence, conditions are ||, &&, ! and comparisons.
+type conditionParser struct {
+	tokens []conditionToken
+	pos    int
+	// vars are the variables the condition uses.
+	vars map[string]bool
+}
+
+func (p *conditionParser) peek() conditionToken {
+	return p.tokens[p.pos]
+}
+
+func (p *conditionParser) next() conditionToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// acceptOp consumes the next token and returns true if it's one of ops.
+func (p *conditionParser) acceptOp(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *conditionParser) parseOr() (conditionNode, error) {
+	return p.parseLogical("||", p.parseAnd)
+}
+
+func (p *conditionParser) parseAnd() (conditionNode, error) {
+	return p.parseLogical("&&", p.parseNot)
+}
+
+// parseLogical parses operands parsed by operand joined by op, which must all
+// be booleans.
+func (p *conditionParser) parseLogical(op string, operand func() (conditionNode, error)) (conditionNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOp(op); !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ() != StepConditionBool || right.typ() != StepConditionBool {
+			return nil, fmt.Errorf("%q needs booleans, found a %s and a %s", op, left.typ(), right.typ())
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *conditionParser) parseNot() (conditionNode, error) {
+	if _, ok := p.acceptOp("!"); !ok {
+		return p.parseComparison()
+	}
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	if operand.typ() != StepConditionBool {
+		return nil, fmt.Errorf("\"!\" needs a boolean, found a %s", operand.typ())
+	}
+	return notNode{operand: operand}, nil
+}
+
+func (p *conditionParser) parseComparison() (conditionNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.acceptOp("==", "!=", "<", "<=", ">", ">=")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if left.typ() != right.typ() {
+		return nil, fmt.Errorf("can't compare a %s to a %s with %q", left.typ(), right.typ(), op)
+	}
+	if op != "==" && op != "!=" && left.typ() != StepConditionNumber {
+		return nil, fmt.Errorf("%q needs numbers, found a %s", op, left.typ())
+	}
+	return binaryNode{op: op, left: left, right: right}, nil
+}
+
+func (p *conditionParser) parsePrimary() (conditionNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return literalNode{t: StepConditionString, value: t.text}, nil
+	case tokenNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		return literalNode{t: StepConditionNumber, value: n}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true", "false":
+			return literalNode{t: StepConditionBool, value: t.text == "true"}, nil
+		}
+		typ, ok := StepConditionVars[t.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown variable %q", t.text)
+		}
+		p.vars[t.text] = true
+		return varNode{t: typ, name: t.text}, nil
+	case tokenOp:
+		if t.text == "(" {
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.acceptOp(")"); !ok {
+				return nil, fmt.Errorf("expected \")\", found %s", p.peek())
+			}
+			return node, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %s", t)
+}
@@ -0,0 +1,83 @@
+package valid_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestParseStepCondition_Errors(t *testing.T) {
+	cases := map[string]string{
+		"":                        "invalid condition \"\": unexpected end of condition",
+		"workspace ==":            "invalid condition \"workspace ==\": unexpected end of condition",
+		"workspace == 'prod":      "invalid condition \"workspace == 'prod\": unterminated string starting at 14",
+		"workspace = 'prod'":      "invalid condition \"workspace = 'prod'\": unexpected character '=' at 11",
+		"env == 'prod'":           "invalid condition \"env == 'prod'\": unknown variable \"env\"",
+		"num_changes":             "invalid condition \"num_changes\": must be a boolean, found a number",
+		"num_changes == 'ten'":    "invalid condition \"num_changes == 'ten'\": can't compare a number to a string with \"==\"",
+		"workspace > 'a'":         "invalid condition \"workspace > 'a'\": \">\" needs numbers, found a string",
+		"num_changes && true":     "invalid condition \"num_changes && true\": \"&&\" needs booleans, found a number and a bool",
+		"!workspace":              "invalid condition \"!workspace\": \"!\" needs a boolean, found a string",
+		"(pull_num > 1":           "invalid condition \"(pull_num > 1\": expected \")\", found end of condition",
+		"pull_num > 1 pull_num":   "invalid condition \"pull_num > 1 pull_num\": unexpected \"pull_num\"",
+		"pull_num > 1.2.3":        "invalid condition \"pull_num > 1.2.3\": invalid number \"1.2.3\"",
+		"true || workspace == 1 ": "invalid condition \"true || workspace == 1 \": can't compare a string to a number with \"==\"",
+	}
+	for expr, expErr := range cases {
+		t.Run(expr, func(t *testing.T) {
+			_, err := valid.ParseStepCondition(expr)
+			ErrEquals(t, expErr, err)
+		})
+	}
+}
+
+func TestStepCondition_Eval(t *testing.T) {
+	vars := map[string]interface{}{
+		"workspace":   "prod",
+		"command":     "plan",
+		"num_changes": float64(12),
+		"pull_num":    float64(3),
+	}
+	cases := map[string]bool{
+		"true":                     true,
+		"false":                    false,
+		"workspace == 'prod'":      true,
+		"workspace == \"staging\"": false,
+		"workspace != 'staging'":   true,
+		"num_changes > 10 && workspace == 'prod'":    true,
+		"num_changes > 20 && workspace == 'prod'":    false,
+		"num_changes > 20 || command == 'plan'":      true,
+		"!(num_changes >= 12)":                       false,
+		"num_changes <= 12 && pull_num < 3.5":        true,
+		"!true || false":                             false,
+		"pull_num == 3 && (false || !(1 > 2))":       true,
+		"command == 'apply' || command == 'plan'":    true,
+		"workspace == 'prod' && num_changes == 12.0": true,
+	}
+	for expr, exp := range cases {
+		t.Run(expr, func(t *testing.T) {
+			cond, err := valid.ParseStepCondition(expr)
+			Ok(t, err)
+			act, err := cond.Eval(vars)
+			Ok(t, err)
+			Equals(t, exp, act)
+		})
+	}
+}
+
+// Test that && and || short circuit so variables that aren't set are only an
+// error if they're needed.
+func TestStepCondition_EvalMissingVar(t *testing.T) {
+	cond, err := valid.ParseStepCondition("workspace == 'prod' && num_changes > 10")
+	Ok(t, err)
+	Assert(t, cond.Uses("num_changes"), "exp num_changes to be used")
+	Assert(t, !cond.Uses("pull_num"), "exp pull_num not to be used")
+
+	act, err := cond.Eval(map[string]interface{}{"workspace": "staging"})
+	Ok(t, err)
+	Equals(t, false, act)
+
+	_, err = cond.Eval(map[string]interface{}{"workspace": "prod"})
+	ErrEquals(t, "evaluating condition \"workspace == 'prod' && num_changes > 10\": variable \"num_changes\" isn't set", err)
+}
@@ -0,0 +1,34 @@
+package valid
+
+// DefaultRunIDEnvVar is the env var run, env and multienv step commands get
+// the ID of their run in, unless --run-id-env-var names another one.
+const DefaultRunIDEnvVar = "ATLANTIS_RUN_ID"
+
+// StepEnvVars are the environment variables Atlantis sets for every run, env
+// and multienv step command, besides the one named by --run-id-env-var. Steps
+// can reference them without declaring them in env_schema.
+// TF_CLI_CONFIG_FILE is only set if the project has a Terraform CLI config.
+var StepEnvVars = []string{
+	"ATLANTIS_TERRAFORM_VERSION",
+	"BASE_BRANCH_NAME",
+	"BASE_REPO_NAME",
+	"BASE_REPO_OWNER",
+	"COMMENT_ARGS",
+	"DIR",
+	"HEAD_BRANCH_NAME",
+	"HEAD_COMMIT",
+	"HEAD_REPO_NAME",
+	"HEAD_REPO_OWNER",
+	"PATH",
+	"PLANFILE",
+	"SHOWFILE",
+	"POLICYCHECKFILE",
+	"PROJECT_NAME",
+	"PULL_AUTHOR",
+	"PULL_NUM",
+	"PULL_URL",
+	"REPO_REL_DIR",
+	"TF_CLI_CONFIG_FILE",
+	"USER_NAME",
+	"WORKSPACE",
+}
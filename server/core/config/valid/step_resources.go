@@ -0,0 +1,40 @@
+package valid
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// memoryLimitRegex matches memory limits like "512M", "1Gi" or "1048576".
+var memoryLimitRegex = regexp.MustCompile(`^(\d+)\s*(?:([KMGkmg])i?[Bb]?)?$`)
+
+// ParseMemoryLimit returns the bytes of a run step's memory limit like "512M"
+// or "1G". Units are K, M and G, optionally followed by i or B, and are
+// powers of 1024. A number without a unit is in bytes.
+func ParseMemoryLimit(s string) (int64, error) {
+	match := memoryLimitRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return 0, fmt.Errorf("invalid memory limit %q, must be a number of bytes optionally followed by K, M or G, ex. \"512M\"", s)
+	}
+	n, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid memory limit %q, must be greater than 0", s)
+	}
+	shift := map[string]uint{"": 0, "k": 10, "m": 20, "g": 30}[strings.ToLower(match[2])]
+	if n > (1<<63-1)>>shift {
+		return 0, fmt.Errorf("invalid memory limit %q, it's too big", s)
+	}
+	return n << shift, nil
+}
+
+// ParseCPULimit returns the number of CPUs of a run step's CPU limit like "1"
+// or "0.5".
+func ParseCPULimit(s string) (float64, error) {
+	cpus, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || cpus <= 0 {
+		return 0, fmt.Errorf("invalid cpu limit %q, must be a number of CPUs greater than 0, ex. \"0.5\"", s)
+	}
+	return cpus, nil
+}
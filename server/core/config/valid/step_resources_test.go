@@ -0,0 +1,46 @@
+package valid_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestParseMemoryLimit(t *testing.T) {
+	cases := []struct {
+		limit  string
+		exp    int64
+		expErr string
+	}{
+		{limit: "1048576", exp: 1048576},
+		{limit: "512K", exp: 512 * 1024},
+		{limit: "512M", exp: 512 * 1024 * 1024},
+		{limit: "512Mi", exp: 512 * 1024 * 1024},
+		{limit: "2GB", exp: 2 * 1024 * 1024 * 1024},
+		{limit: "1g", exp: 1024 * 1024 * 1024},
+		{limit: "0", expErr: "invalid memory limit \"0\", must be greater than 0"},
+		{limit: "1.5G", expErr: "invalid memory limit \"1.5G\", must be a number of bytes optionally followed by K, M or G, ex. \"512M\""},
+		{limit: "99999999999G", expErr: "invalid memory limit \"99999999999G\", it's too big"},
+	}
+	for _, c := range cases {
+		t.Run(c.limit, func(t *testing.T) {
+			act, err := valid.ParseMemoryLimit(c.limit)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.exp, act)
+		})
+	}
+}
+
+func TestParseCPULimit(t *testing.T) {
+	act, err := valid.ParseCPULimit("0.5")
+	Ok(t, err)
+	Equals(t, 0.5, act)
+
+	_, err = valid.ParseCPULimit("-1")
+	ErrEquals(t, "invalid cpu limit \"-1\", must be a number of CPUs greater than 0, ex. \"0.5\"", err)
+}
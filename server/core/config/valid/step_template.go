@@ -0,0 +1,34 @@
+package valid
+
+import (
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+)
+
+// stepTemplateFuncNames are the Sprig functions available in run step
+// templates, in addition to the text/template builtins. Functions that read
+// the server's environment or the network, ex. env and getHostByName, are
+// left out so templates can't leak secrets and only depend on the repo.
+var stepTemplateFuncNames = []string{
+	// Defaults.
+	"default", "empty", "coalesce", "ternary",
+	// Strings.
+	"trim", "trimAll", "trimPrefix", "trimSuffix", "lower", "upper", "title",
+	"replace", "contains", "hasPrefix", "hasSuffix", "substr", "trunc",
+	"nospace", "quote", "squote", "cat", "regexMatch", "regexReplaceAll",
+	// Lists.
+	"list", "join", "split", "splitList", "first", "last",
+	// Conversions and encodings.
+	"toString", "int", "b64enc", "b64dec", "sha1sum", "sha256sum",
+}
+
+// StepTemplateFuncs returns the functions available in run step templates.
+func StepTemplateFuncs() template.FuncMap {
+	all := sprig.TxtFuncMap()
+	funcs := make(template.FuncMap, len(stepTemplateFuncNames))
+	for _, name := range stepTemplateFuncNames {
+		funcs[name] = all[name]
+	}
+	return funcs
+}
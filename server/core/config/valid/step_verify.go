@@ -0,0 +1,71 @@
+package valid
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ChecksumSHA256 = "sha256"
+	ChecksumSHA512 = "sha512"
+)
+
+// StepVerify is a checksum a file must have after a run step's RunCommand
+// succeeds, ex. to check a downloaded tool wasn't tampered with.
+type StepVerify struct {
+	// File is the file that's checked, relative to the project directory.
+	File string
+	// Algorithm is ChecksumSHA256 or ChecksumSHA512.
+	Algorithm string
+	// Checksum is the hex encoded checksum File must have. If empty it's read
+	// from ChecksumFile.
+	Checksum string
+	// ChecksumFile is a file, relative to the project directory, with the
+	// checksum File must have, either on its own or in the format output by
+	// sha256sum and sha512sum.
+	ChecksumFile string
+}
+
+// checksumHexLengths are the lengths of the hex encoded checksums of each
+// algorithm.
+var checksumHexLengths = map[string]int{
+	ChecksumSHA256: 64,
+	ChecksumSHA512: 128,
+}
+
+// ParseStepVerify returns the verify option of a run step that checks file
+// has checksum, or the checksum in checksumFile, using algorithm. It returns
+// an error if the paths are outside the project directory or checksum isn't
+// a valid checksum for algorithm.
+func ParseStepVerify(file string, algorithm string, checksum string, checksumFile string) (StepVerify, error) {
+	if err := CheckProjectPath(file); err != nil {
+		return StepVerify{}, fmt.Errorf("file %s", err)
+	}
+	length, ok := checksumHexLengths[algorithm]
+	if !ok {
+		return StepVerify{}, fmt.Errorf("unsupported checksum algorithm %q, must be %q or %q", algorithm, ChecksumSHA256, ChecksumSHA512)
+	}
+	if checksumFile != "" {
+		if err := CheckProjectPath(checksumFile); err != nil {
+			return StepVerify{}, fmt.Errorf("checksum file %s", err)
+		}
+		return StepVerify{File: file, Algorithm: algorithm, ChecksumFile: checksumFile}, nil
+	}
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	if _, err := hex.DecodeString(checksum); err != nil || len(checksum) != length {
+		return StepVerify{}, fmt.Errorf("invalid %s checksum %q, must be %d hex characters", algorithm, checksum, length)
+	}
+	return StepVerify{File: file, Algorithm: algorithm, Checksum: checksum}, nil
+}
+
+// CheckProjectPath returns an error if p isn't a path inside the project
+// directory.
+func CheckProjectPath(p string) error {
+	clean := filepath.Clean(p)
+	if p == "" || filepath.IsAbs(p) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%q must be inside the project directory", p)
+	}
+	return nil
+}
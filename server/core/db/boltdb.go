@@ -258,13 +258,16 @@ func (b *BoltDB) UnlockByPull(repoFullName string, pullNum int) ([]models.Projec
 	err := b.db.View(func(tx *bolt.Tx) error {
 		c := tx.Bucket(b.locksBucketName).Cursor()
 
-		// we can use the repoFullName as a prefix search since that's the first part of the key
-		for k, v := c.Seek([]byte(repoFullName)); k != nil && bytes.HasPrefix(k, []byte(repoFullName)); k, v = c.Next() {
+		// we can use the repoFullName as a prefix search since that's the first
+		// part of the key, followed by a slash so other repos whose names
+		// start with it, ex. owner/repo-other, aren't included
+		prefix := []byte(repoFullName + "/")
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
 			var lock models.ProjectLock
 			if err := json.Unmarshal(v, &lock); err != nil {
 				return errors.Wrapf(err, "deserializing lock at key %q", string(k))
 			}
-			if lock.Pull.Num == pullNum {
+			if lock.Project.RepoFullName == repoFullName && lock.Pull.Num == pullNum {
 				locks = append(locks, lock)
 			}
 		}
@@ -357,6 +360,10 @@ func (b *BoltDB) UpdatePullWithResults(pull models.PullRequest, newResults []com
 						res.ProjectName == proj.ProjectName {
 
 						proj.Status = res.PlanStatus()
+						if res.Command == command.Plan {
+							proj.PlanStats = res.PlanStats()
+							proj.PlanOnly = res.PlanOnly
+						}
 
 						// Updating only policy sets which are included in results; keeping the rest.
 						if len(proj.PolicyStatus) > 0 {
@@ -502,5 +509,7 @@ func (b *BoltDB) projectResultToProject(p command.ProjectResult) models.ProjectS
 		ProjectName:  p.ProjectName,
 		PolicyStatus: p.PolicyStatus(),
 		Status:       p.PlanStatus(),
+		PlanStats:    p.PlanStats(),
+		PlanOnly:     p.PlanOnly,
 	}
 }
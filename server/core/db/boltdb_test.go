@@ -361,6 +361,21 @@ func TestUnlockByPullOne(t *testing.T) {
 		Ok(t, err)
 		Equals(t, 1, len(ls))
 	}
+	t.Log("...delete nothing when its the same pull but a repo whose name is a prefix of the lock's repo")
+	{
+		other := lock
+		other.Project.RepoFullName = project.RepoFullName + "-other"
+		_, _, err := b.TryLock(other)
+		Ok(t, err)
+		_, err = b.UnlockByPull(project.RepoFullName[:len(project.RepoFullName)-1], pullNum)
+		Ok(t, err)
+		ls, err := b.List()
+		Ok(t, err)
+		Equals(t, 2, len(ls))
+		unlocked, err := b.UnlockByPull(other.Project.RepoFullName, pullNum)
+		Ok(t, err)
+		Equals(t, []models.ProjectLock{other}, unlocked)
+	}
 	t.Log("...delete the lock when its the same repo and pull")
 	{
 		_, err := b.UnlockByPull(project.RepoFullName, pullNum)
@@ -491,6 +506,50 @@ func TestPullStatus_UpdateGet(t *testing.T) {
 	}, status.Projects)
 }
 
+// Test that the plan stats of successful plans are stored.
+func TestPullStatus_UpdatePlanStats(t *testing.T) {
+	b := newTestDB2(t)
+	pull := models.PullRequest{
+		Num:        1,
+		HeadCommit: "sha",
+		BaseRepo: models.Repo{
+			FullName: "runatlantis/atlantis",
+		},
+	}
+	_, err := b.UpdatePullWithResults(
+		pull,
+		[]command.ProjectResult{
+			{
+				Command:    command.Plan,
+				RepoRelDir: ".",
+				Workspace:  "default",
+				PlanSuccess: &models.PlanSuccess{
+					TerraformOutput: "Plan: 3 to add, 1 to change, 0 to destroy.",
+				},
+			},
+		})
+	Ok(t, err)
+
+	// Applying keeps the stats of the last plan.
+	status, err := b.UpdatePullWithResults(
+		pull,
+		[]command.ProjectResult{
+			{
+				Command:      command.Apply,
+				RepoRelDir:   ".",
+				Workspace:    "default",
+				ApplySuccess: "success",
+			},
+		})
+	Ok(t, err)
+	Equals(t, models.AppliedPlanStatus, status.Projects[0].Status)
+	Equals(t, models.PlanSuccessStats{Add: 3, Change: 1, Changes: true}, status.PlanStats())
+
+	maybeStatus, err := b.GetPullStatus(pull)
+	Ok(t, err)
+	Equals(t, status.PlanStats(), maybeStatus.PlanStats()) // nolint: staticcheck
+}
+
 // Test we can create a status, delete it, and then we shouldn't be able to getCommandLock
 // it.
 func TestPullStatus_UpdateDeleteGet(t *testing.T) {
@@ -763,6 +822,7 @@ func TestPullStatus_UpdateMerge_Apply(t *testing.T) {
 				RepoRelDir: "staythesame",
 				Workspace:  "default",
 				Status:     models.PlannedPlanStatus,
+				PlanStats:  &models.PlanSuccessStats{},
 			},
 			{
 				RepoRelDir: "newresult",
@@ -146,6 +146,12 @@ func (c *Client) GetLock(key string) (*models.ProjectLock, error) {
 }
 
 func (c *Client) key(p models.Project, workspace string) string {
+	return Key(p, workspace)
+}
+
+// Key returns the key of the lock of project p in workspace, the key
+// returned by TryLock and used by GetLock and Unlock.
+func Key(p models.Project, workspace string) string {
 	return fmt.Sprintf("%s/%s/%s", p.RepoFullName, p.Path, workspace)
 }
 
@@ -159,7 +159,7 @@ func (r *RedisDB) GetLock(project models.Project, workspace string) (*models.Pro
 func (r *RedisDB) UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error) {
 	var locks []models.ProjectLock
 
-	iter := r.client.Scan(ctx, 0, fmt.Sprintf("pr/%s*", repoFullName), 0).Iterator()
+	iter := r.client.Scan(ctx, 0, fmt.Sprintf("pr/%s/*", repoFullName), 0).Iterator()
 	for iter.Next(ctx) {
 		var lock models.ProjectLock
 		val, err := r.client.Get(ctx, iter.Val()).Result()
@@ -169,7 +169,7 @@ func (r *RedisDB) UnlockByPull(repoFullName string, pullNum int) ([]models.Proje
 		if err := json.Unmarshal([]byte(val), &lock); err != nil {
 			return locks, errors.Wrap(err, fmt.Sprintf("failed to deserialize lock at key '%s'", iter.Val()))
 		}
-		if lock.Pull.Num == pullNum {
+		if lock.Project.RepoFullName == repoFullName && lock.Pull.Num == pullNum {
 			locks = append(locks, lock)
 			if _, err := r.Unlock(lock.Project, lock.Workspace); err != nil {
 				return locks, errors.Wrapf(err, "unlocking repo %s, path %s, workspace %s", lock.Project.RepoFullName, lock.Project.Path, lock.Workspace)
@@ -331,6 +331,10 @@ func (r *RedisDB) UpdatePullWithResults(pull models.PullRequest, newResults []co
 					res.ProjectName == proj.ProjectName {
 
 					proj.Status = res.PlanStatus()
+					if res.Command == command.Plan {
+						proj.PlanStats = res.PlanStats()
+						proj.PlanOnly = res.PlanOnly
+					}
 
 					// Updating only policy sets which are included in results; keeping the rest.
 					if len(proj.PolicyStatus) > 0 {
@@ -419,5 +423,7 @@ func (r *RedisDB) projectResultToProject(p command.ProjectResult) models.Project
 		ProjectName:  p.ProjectName,
 		PolicyStatus: p.PolicyStatus(),
 		Status:       p.PlanStatus(),
+		PlanStats:    p.PlanStats(),
+		PlanOnly:     p.PlanOnly,
 	}
 }
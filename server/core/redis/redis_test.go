@@ -398,6 +398,21 @@ func TestUnlockByPullOne(t *testing.T) {
 		Ok(t, err)
 		Equals(t, 1, len(ls))
 	}
+	t.Log("...delete nothing when its the same pull but a repo whose name is a prefix of the lock's repo")
+	{
+		other := lock
+		other.Project.RepoFullName = project.RepoFullName + "-other"
+		_, _, err := rdb.TryLock(other)
+		Ok(t, err)
+		_, err = rdb.UnlockByPull(project.RepoFullName[:len(project.RepoFullName)-1], pullNum)
+		Ok(t, err)
+		ls, err := rdb.List()
+		Ok(t, err)
+		Equals(t, 2, len(ls))
+		unlocked, err := rdb.UnlockByPull(other.Project.RepoFullName, pullNum)
+		Ok(t, err)
+		Equals(t, []models.ProjectLock{other}, unlocked)
+	}
 	t.Log("...delete the lock when its the same repo and pull")
 	{
 		_, err := rdb.UnlockByPull(project.RepoFullName, pullNum)
@@ -805,6 +820,7 @@ func TestPullStatus_UpdateMerge_Apply(t *testing.T) {
 				RepoRelDir: "staythesame",
 				Workspace:  "default",
 				Status:     models.PlannedPlanStatus,
+				PlanStats:  &models.PlanSuccessStats{},
 			},
 			{
 				RepoRelDir: "newresult",
@@ -1,11 +1,13 @@
 package runtime
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -21,17 +23,23 @@ type ApplyStepRunner struct {
 	DefaultTFVersion    *version.Version
 	CommitStatusUpdater StatusUpdater
 	AsyncTFExec         AsyncTFExec
+	// TFCRunClient, if set, applies saved cloud plans through the Terraform
+	// Cloud/Enterprise API instead of terraform apply.
+	TFCRunClient *TFCRunClient
+	// PullCommentUpdater, if set, is used to stream the log of the runs
+	// applied by TFCRunClient to a pull request comment.
+	PullCommentUpdater PullCommentUpdater
 }
 
 func (a *ApplyStepRunner) Run(ctx command.ProjectContext, extraArgs []string, path string, envs map[string]string) (string, error) {
 	if a.hasTargetFlag(ctx, extraArgs) {
-		return "", errors.New("cannot run apply with -target because we are applying an already generated plan. Instead, run -target with atlantis plan")
+		return "", errors.New("cannot run apply with -target because we are applying an already generated plan. Instead, use atlantis apply --target or run -target with atlantis plan")
 	}
 
 	planPath := filepath.Join(path, GetPlanFilename(ctx.Workspace, ctx.ProjectName))
 	contents, err := os.ReadFile(planPath)
 	if os.IsNotExist(err) {
-		return "", fmt.Errorf("no plan found at path %q and workspace %q–did you run plan?", ctx.RepoRelDir, ctx.Workspace)
+		return "", fmt.Errorf("no plan found at path %q and workspace %q, please run atlantis plan", ctx.RepoRelDir, ctx.Workspace)
 	}
 	if err != nil {
 		return "", errors.Wrap(err, "unable to read planfile")
@@ -41,12 +49,23 @@ func (a *ApplyStepRunner) Run(ctx command.ProjectContext, extraArgs []string, pa
 	var out string
 
 	// TODO: Leverage PlanTypeStepRunnerDelegate here
-	if IsRemotePlan(contents) {
+	if len(ctx.ApplyTargets) > 0 {
+		if IsRemotePlan(contents) {
+			return "", errors.New("cannot run apply with --target on a remote plan")
+		}
+		out, err = a.runTargetedApply(ctx, extraArgs, path, planPath, envs)
+	} else if IsRemotePlan(contents) {
 		args := append(append([]string{"apply", "-input=false", "-no-color"}, extraArgs...), ctx.EscapedCommentArgs...)
-		out, err = a.runRemoteApply(ctx, args, path, planPath, ctx.TerraformVersion, envs)
+		var runURL string
+		out, runURL, err = a.runRemoteApply(ctx, args, path, planPath, ctx.TerraformVersion, envs)
 		if err == nil {
 			out = a.cleanRemoteApplyOutput(out)
+			if runURL != "" {
+				out = fmt.Sprintf("Terraform Cloud run: %s\n\n%s", runURL, out)
+			}
 		}
+	} else if plan, ok := readSavedCloudPlan(planPath); ok && a.TFCRunClient != nil && a.TFCRunClient.Handles(plan) {
+		out, err = a.applyCloudPlan(ctx, plan)
 	} else {
 		// NOTE: we need to quote the plan path because Bitbucket Server can
 		// have spaces in its repo owner names which is part of the path.
@@ -60,10 +79,56 @@ func (a *ApplyStepRunner) Run(ctx command.ProjectContext, extraArgs []string, pa
 		if removeErr := utils.RemoveIgnoreNonExistent(planPath); removeErr != nil {
 			ctx.Log.Warn("failed to delete planfile after successful apply: %s", removeErr)
 		}
+		if unpinErr := UnpinPlan(planPath); unpinErr != nil {
+			ctx.Log.Warn("failed to unpin planfile after successful apply: %s", unpinErr)
+		}
+		if ttlErr := RemovePlanTTL(planPath); ttlErr != nil {
+			ctx.Log.Warn("failed to delete plan_ttl of planfile after successful apply: %s", ttlErr)
+		}
+		a.writeStateVersion(ctx, path, envs)
 	}
 	return out, err
 }
 
+// writeStateVersion writes the serial and lineage of the project's state after
+// an apply to its state version file, so they can be shown with the apply's
+// result. If the backend doesn't support terraform state pull or its state
+// doesn't have them, no file is written.
+func (a *ApplyStepRunner) writeStateVersion(ctx command.ProjectContext, path string, envs map[string]string) {
+	out, err := a.TerraformExecutor.RunCommandWithVersion(ctx, path, []string{"state", "pull"}, envs, ctx.TerraformVersion, ctx.Workspace)
+	if err != nil {
+		ctx.Log.Debug("not showing the state version of the apply, unable to pull the state: %s", err)
+		return
+	}
+	state, ok := parseStateVersion(out)
+	if !ok {
+		ctx.Log.Debug("not showing the state version of the apply, the pulled state has no serial and lineage")
+		return
+	}
+	content, err := json.Marshal(state)
+	if err != nil {
+		ctx.Log.Warn("failed to encode the state version: %s", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(path, ctx.GetStateVersionFileName()), content, 0600); err != nil {
+		ctx.Log.Warn("failed to write the state version: %s", err)
+	}
+}
+
+// parseStateVersion returns the version of the state output by terraform state
+// pull. Anything Terraform output before the state, ex. warnings, is skipped.
+func parseStateVersion(out string) (models.StateVersion, bool) {
+	start := strings.Index(out, "{")
+	if start < 0 {
+		return models.StateVersion{}, false
+	}
+	var state models.StateVersion
+	if err := json.NewDecoder(strings.NewReader(out[start:])).Decode(&state); err != nil || state.Lineage == "" {
+		return models.StateVersion{}, false
+	}
+	return state, true
+}
+
 func (a *ApplyStepRunner) hasTargetFlag(ctx command.ProjectContext, extraArgs []string) bool {
 	isTargetFlag := func(s string) bool {
 		if s == "-target" {
@@ -86,6 +151,87 @@ func (a *ApplyStepRunner) hasTargetFlag(ctx command.ProjectContext, extraArgs []
 	return false
 }
 
+// applyCloudPlan applies the Terraform Cloud/Enterprise run of the saved cloud
+// plan and waits for it, streaming its log to a pull request comment, and
+// returns the log with a link to the run. The run must still be waiting to be
+// applied, which it's not if it was discarded or another run applied first.
+func (a *ApplyStepRunner) applyCloudPlan(ctx command.ProjectContext, plan savedCloudPlan) (string, error) {
+	run, err := a.TFCRunClient.GetRun(plan.RunID)
+	if err != nil {
+		return "", errors.Wrap(err, "reading Terraform Cloud run")
+	}
+	if !run.Confirmable {
+		return "", fmt.Errorf("Terraform Cloud run %s is %s and can't be applied, run atlantis plan again: %s", run.ID, run.Status, run.URL)
+	}
+	comment := fmt.Sprintf("Applied by Atlantis for %s", ctx.Pull.URL)
+	if ctx.User.Username != "" {
+		comment += fmt.Sprintf(" by %s", ctx.User.Username)
+	}
+	if err := a.TFCRunClient.ApplyRun(run.ID, comment); err != nil {
+		return "", errors.Wrap(err, "applying Terraform Cloud run")
+	}
+	runURL := run.URL
+	a.updateStatus(ctx, models.PendingCommitStatus, runURL)
+
+	var stream *pullCommentStream
+	if a.PullCommentUpdater != nil {
+		stream = &pullCommentStream{
+			updater: a.PullCommentUpdater,
+			ctx:     ctx,
+			header:  fmt.Sprintf("Terraform Cloud run [%s](%s) in dir: `%s` workspace: `%s`", run.ID, runURL, ctx.RepoRelDir, ctx.Workspace),
+		}
+	}
+	var log []string
+	readLog := func(run TFCRun) bool {
+		if run.ApplyID == "" {
+			return false
+		}
+		lines, logErr := a.TFCRunClient.ApplyLog(run.ApplyID)
+		if logErr != nil {
+			ctx.Log.Warn("unable to read the log of Terraform Cloud run %s: %s", run.ID, logErr)
+			return false
+		}
+		if len(lines) <= len(log) {
+			return false
+		}
+		log = lines
+		return true
+	}
+	var lastUpdate time.Time
+	run, err = a.TFCRunClient.WaitForRun(ctx, run.ID, func(run TFCRun) {
+		if readLog(run) && stream != nil && time.Since(lastUpdate) >= defaultStreamCommentInterval {
+			stream.update(strings.Join(log, "\n")+"\n", "Applying")
+			lastUpdate = time.Now()
+		}
+	})
+	// Read the log once the run finished in case the last poll missed its end.
+	readLog(run)
+	out := fmt.Sprintf("Terraform Cloud run: %s\n\n%s", runURL, strings.Join(log, "\n"))
+	if err == nil && run.Status != "applied" {
+		err = fmt.Errorf("Terraform Cloud run %s is %s", run.ID, run.Status)
+	}
+	if err != nil {
+		a.updateStatus(ctx, models.FailedCommitStatus, runURL)
+		if stream != nil && stream.commentID != 0 {
+			stream.update(strings.Join(log, "\n")+"\n", "Failed")
+		}
+		return out, errors.Wrap(err, "waiting for Terraform Cloud run")
+	}
+	a.updateStatus(ctx, models.SuccessCommitStatus, runURL)
+	if stream != nil && stream.commentID != 0 {
+		stream.update(strings.Join(log, "\n")+"\n", "Finished")
+	}
+	return out, nil
+}
+
+// updateStatus updates the commit status of the project's apply and logs any
+// error.
+func (a *ApplyStepRunner) updateStatus(ctx command.ProjectContext, status models.CommitStatus, url string) {
+	if err := a.CommitStatusUpdater.UpdateProject(ctx, command.Apply, status, url, nil); err != nil {
+		ctx.Log.Err("unable to update status: %s", err)
+	}
+}
+
 // cleanRemoteApplyOutput removes unneeded output like the refresh and plan
 // phases to make the final comment cleaner.
 func (a *ApplyStepRunner) cleanRemoteApplyOutput(out string) string {
@@ -104,7 +250,7 @@ func (a *ApplyStepRunner) cleanRemoteApplyOutput(out string) string {
 // runRemoteApply handles running the apply and performing actions in real-time
 // as we get the output from the command.
 // Specifically, we set commit statuses with links to Terraform Enterprise's
-// UI to view real-time output.
+// UI to view real-time output, and return the link.
 // We also check if the plan that's about to be applied matches the one we
 // printed to the pull request.
 // We need to do this because remote plan doesn't support -out, so we do a
@@ -116,19 +262,12 @@ func (a *ApplyStepRunner) runRemoteApply(
 	path string,
 	absPlanPath string,
 	tfVersion *version.Version,
-	envs map[string]string) (string, error) {
+	envs map[string]string) (string, string, error) {
 	// The planfile contents are needed to ensure that the plan didn't change
 	// between plan and apply phases.
 	planfileBytes, err := os.ReadFile(absPlanPath)
 	if err != nil {
-		return "", errors.Wrap(err, "reading planfile")
-	}
-
-	// updateStatusF will update the commit status and log any error.
-	updateStatusF := func(status models.CommitStatus, url string) {
-		if err := a.CommitStatusUpdater.UpdateProject(ctx, command.Apply, status, url, nil); err != nil {
-			ctx.Log.Err("unable to update status: %s", err)
-		}
+		return "", "", errors.Wrap(err, "reading planfile")
 	}
 
 	// Start the async command execution.
@@ -153,7 +292,7 @@ func (a *ApplyStepRunner) runRemoteApply(
 		} else if nextLineIsRunURL {
 			runURL = strings.TrimSpace(line.Line)
 			ctx.Log.Debug("remote run url found, updating commit status")
-			updateStatusF(models.PendingCommitStatus, runURL)
+			a.updateStatus(ctx, models.PendingCommitStatus, runURL)
 			nextLineIsRunURL = false
 		}
 
@@ -181,18 +320,18 @@ func (a *ApplyStepRunner) runRemoteApply(
 	ctx.Log.Debug("async tf remote operation complete")
 	output := strings.Join(lines, "\n")
 	if planChangedErr != nil {
-		updateStatusF(models.FailedCommitStatus, runURL)
+		a.updateStatus(ctx, models.FailedCommitStatus, runURL)
 		// The output isn't important if the plans don't match so we just
 		// discard it.
-		return "", planChangedErr
+		return "", runURL, planChangedErr
 	}
 
 	if err != nil {
-		updateStatusF(models.FailedCommitStatus, runURL)
-	} else {
-		updateStatusF(models.SuccessCommitStatus, runURL)
+		a.updateStatus(ctx, models.FailedCommitStatus, runURL)
+		return output, runURL, err
 	}
-	return output, err
+	a.updateStatus(ctx, models.SuccessCommitStatus, runURL)
+	return output, runURL, nil
 }
 
 // remotePlanChanged checks if the plan generated during the plan phase matches
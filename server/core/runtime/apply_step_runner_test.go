@@ -30,7 +30,7 @@ func TestRun_NoDir(t *testing.T) {
 		RepoRelDir: ".",
 		Workspace:  "workspace",
 	}, nil, "/nonexistent/path", map[string]string(nil))
-	ErrEquals(t, "no plan found at path \".\" and workspace \"workspace\"–did you run plan?", err)
+	ErrEquals(t, "no plan found at path \".\" and workspace \"workspace\", please run atlantis plan", err)
 }
 
 func TestRun_NoPlanFile(t *testing.T) {
@@ -42,7 +42,7 @@ func TestRun_NoPlanFile(t *testing.T) {
 		RepoRelDir: ".",
 		Workspace:  "workspace",
 	}, nil, tmpDir, map[string]string(nil))
-	ErrEquals(t, "no plan found at path \".\" and workspace \"workspace\"–did you run plan?", err)
+	ErrEquals(t, "no plan found at path \".\" and workspace \"workspace\", please run atlantis plan", err)
 }
 
 func TestRun_Success(t *testing.T) {
@@ -74,6 +74,143 @@ func TestRun_Success(t *testing.T) {
 	Assert(t, os.IsNotExist(err), "planfile should be deleted")
 }
 
+// Test that saved cloud plans are applied through the Terraform Cloud API,
+// streaming the run's log to a comment.
+func TestRun_ApplyCloudPlan(t *testing.T) {
+	cases := []struct {
+		description    string
+		statuses       []string
+		expOut         string
+		expErr         string
+		expStatus      models.CommitStatus
+		expLastComment string
+	}{
+		{
+			description:    "applied",
+			statuses:       []string{"planned", "applying", "applied"},
+			expOut:         "Terraform Cloud run: $URL\n\nApplying...\nApply complete!",
+			expStatus:      models.SuccessCommitStatus,
+			expLastComment: "**Finished** Terraform Cloud run [run-1]($URL) in dir: `.` workspace: `default`\n```\nApplying...\nApply complete!\n```",
+		},
+		{
+			description:    "errored",
+			statuses:       []string{"planned", "applying", "errored"},
+			expErr:         "waiting for Terraform Cloud run: Terraform Cloud run run-1 is errored",
+			expStatus:      models.FailedCommitStatus,
+			expLastComment: "**Failed** Terraform Cloud run [run-1]($URL) in dir: `.` workspace: `default`\n```\nApplying...\nApply complete!\n```",
+		},
+		{
+			description: "discarded",
+			statuses:    []string{"discarded"},
+			expErr:      "Terraform Cloud run run-1 is discarded and can't be applied, run atlantis plan again: $URL",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			tfc := newFakeTFC(t, c.statuses, []string{"", "Applying...\n", "Applying...\nApply complete!\n"})
+			runURL := tfc.server.URL + "/app/org/workspaces/ws/runs/run-1"
+			terraform := mocks.NewMockClient()
+			commitStatusUpdater := runtimemocks.NewMockStatusUpdater()
+			updater := &fakePullCommentUpdater{}
+			o := runtime.ApplyStepRunner{
+				TerraformExecutor:   terraform,
+				CommitStatusUpdater: commitStatusUpdater,
+				TFCRunClient:        tfc.client("token"),
+				PullCommentUpdater:  updater,
+			}
+			path := t.TempDir()
+			planPath := filepath.Join(path, "default.tfplan")
+			writeSavedPlan(t, tfc, planPath)
+			When(terraform.RunCommandWithVersion(Any[command.ProjectContext](), Any[string](), Any[[]string](), Any[map[string]string](), Any[*version.Version](), Any[string]())).
+				ThenReturn("", errors.New("no state"))
+			ctx := command.ProjectContext{
+				Log:        logging.NewNoopLogger(t),
+				Workspace:  "default",
+				RepoRelDir: ".",
+				Pull:       models.PullRequest{URL: "https://github.com/owner/repo/pull/1"},
+				User:       models.User{Username: "user"},
+			}
+
+			out, err := o.Run(ctx, nil, path, map[string]string(nil))
+			if c.expErr != "" {
+				ErrEquals(t, strings.Replace(c.expErr, "$URL", runURL, 1), err)
+			} else {
+				Ok(t, err)
+				Equals(t, strings.Replace(c.expOut, "$URL", runURL, 1), out)
+				_, err = os.Stat(planPath)
+				Assert(t, os.IsNotExist(err), "planfile should be deleted")
+			}
+			terraform.VerifyWasCalled(Never()).RunCommandWithVersion(Any[command.ProjectContext](), Any[string](), Eq([]string{"apply"}), Any[map[string]string](), Any[*version.Version](), Any[string]())
+			if c.expLastComment == "" {
+				Equals(t, "", tfc.applyComment)
+				return
+			}
+			Equals(t, "Applied by Atlantis for https://github.com/owner/repo/pull/1 by user", tfc.applyComment)
+			commitStatusUpdater.VerifyWasCalledOnce().UpdateProject(ctx, command.Apply, models.PendingCommitStatus, runURL, nil)
+			commitStatusUpdater.VerifyWasCalledOnce().UpdateProject(ctx, command.Apply, c.expStatus, runURL, nil)
+			Equals(t, strings.Replace(c.expLastComment, "$URL", runURL, 1), updater.comments[len(updater.comments)-1])
+		})
+	}
+}
+
+// Test that the version of the state after the apply is written to the state
+// version file, and that no file is written if it can't be read.
+func TestRun_WritesStateVersion(t *testing.T) {
+	cases := []struct {
+		description string
+		stateOut    string
+		stateErr    error
+		exp         string
+	}{
+		{
+			description: "state",
+			stateOut:    "Warning: something\n{\n  \"version\": 4,\n  \"serial\": 12,\n  \"lineage\": \"3d2a4b5c\",\n  \"resources\": []\n}\n",
+			exp:         `{"serial":12,"lineage":"3d2a4b5c"}`,
+		},
+		{
+			description: "no state",
+			stateOut:    "",
+		},
+		{
+			description: "state pull unsupported",
+			stateErr:    errors.New("state pull isn't supported"),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			Ok(t, os.WriteFile(filepath.Join(tmpDir, "workspace.tfplan"), nil, 0600))
+			ctx := command.ProjectContext{
+				Log:        logging.NewNoopLogger(t),
+				Workspace:  "workspace",
+				RepoRelDir: ".",
+			}
+
+			RegisterMockTestingT(t)
+			terraform := mocks.NewMockClient()
+			o := runtime.ApplyStepRunner{
+				TerraformExecutor: terraform,
+			}
+			When(terraform.RunCommandWithVersion(Any[command.ProjectContext](), Any[string](), Any[[]string](), Any[map[string]string](), Any[*version.Version](), Any[string]())).
+				ThenReturn("output", nil)
+			When(terraform.RunCommandWithVersion(Any[command.ProjectContext](), Eq(tmpDir), Eq([]string{"state", "pull"}), Any[map[string]string](), Any[*version.Version](), Eq("workspace"))).
+				ThenReturn(c.stateOut, c.stateErr)
+
+			output, err := o.Run(ctx, nil, tmpDir, map[string]string(nil))
+			Ok(t, err)
+			Equals(t, "output", output)
+			content, err := os.ReadFile(filepath.Join(tmpDir, "workspace-stateversion.json"))
+			if c.exp == "" {
+				Assert(t, os.IsNotExist(err), "exp no state version file")
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.exp, string(content))
+		})
+	}
+}
+
 func TestRun_AppliesCorrectProjectPlan(t *testing.T) {
 	// When running for a project, the planfile has a different name.
 	tmpDir := t.TempDir()
@@ -210,7 +347,7 @@ func TestRun_UsingTarget(t *testing.T) {
 			}, c.extraArgs, tmpDir, map[string]string(nil))
 			Equals(t, "", output)
 			if c.expErr {
-				ErrEquals(t, "cannot run apply with -target because we are applying an already generated plan. Instead, run -target with atlantis plan", err)
+				ErrEquals(t, "cannot run apply with -target because we are applying an already generated plan. Instead, use atlantis apply --target or run -target with atlantis plan", err)
 			} else {
 				Ok(t, err)
 			}
@@ -218,6 +355,125 @@ func TestRun_UsingTarget(t *testing.T) {
 	}
 }
 
+// targetTerraform is a TerraformExec for applies with --target that shows the
+// saved plan as planJSON and the plan of the targets as targetedJSON.
+type targetTerraform struct {
+	planJSON     string
+	targetedJSON string
+	planVars     string
+	calls        [][]string
+}
+
+func (f *targetTerraform) RunCommandWithVersion(_ command.ProjectContext, _ string, args []string, _ map[string]string, _ *version.Version, _ string) (string, error) {
+	f.calls = append(f.calls, args)
+	switch args[0] {
+	case "show":
+		if strings.Contains(args[2], "targeted.tfplan") {
+			return f.targetedJSON, nil
+		}
+		return f.planJSON, nil
+	case "plan":
+		content, err := os.ReadFile(strings.Trim(args[5], `"`))
+		if err != nil {
+			return "", err
+		}
+		f.planVars = string(content)
+		return "planned", nil
+	}
+	return "applied", nil
+}
+
+func (f *targetTerraform) EnsureVersion(logging.SimpleLogging, *version.Version) error {
+	return nil
+}
+
+func TestRun_ApplyTargets(t *testing.T) {
+	planJSON := `{
+  "variables": {"env": {"value": "prod"}},
+  "resource_changes": [
+    {"address": "module.db.aws_db_instance.main", "change": {"actions": ["update"]}},
+    {"address": "aws_instance.web[0]", "change": {"actions": ["create"]}},
+    {"address": "aws_s3_bucket.logs", "change": {"actions": ["no-op"]}}
+  ]
+}`
+	cases := []struct {
+		description  string
+		targets      []string
+		targetedJSON string
+		expOut       string
+		expErr       string
+		expPlanArgs  []string
+	}{
+		{
+			description:  "module target",
+			targets:      []string{"module.db"},
+			targetedJSON: `{"resource_changes": [{"address": "module.db.aws_db_instance.main", "change": {"actions": ["update"]}}]}`,
+			expOut:       "applied\n**Warning**: only the changes of `module.db` were applied. The rest of the plan is stale now and was discarded, run `atlantis plan` again to apply the other changes.\n",
+			expPlanArgs:  []string{"-target=\"module.db\""},
+		},
+		{
+			description:  "several targets",
+			targets:      []string{"module.db", "aws_instance.web"},
+			targetedJSON: `{"resource_changes": [{"address": "module.db.aws_db_instance.main", "change": {"actions": ["update"]}}, {"address": "aws_instance.web[0]", "change": {"actions": ["create"]}}]}`,
+			expOut:       "applied\n**Warning**: only the changes of `module.db`, `aws_instance.web` were applied. The rest of the plan is stale now and was discarded, run `atlantis plan` again to apply the other changes.\n",
+			expPlanArgs:  []string{"-target=\"module.db\"", "-target=\"aws_instance.web\""},
+		},
+		{
+			description: "target not in plan",
+			targets:     []string{"aws_s3_bucket.logs"},
+			expErr:      "target \"aws_s3_bucket.logs\" doesn't match any resource the plan changes, the plan changes aws_instance.web[0], module.db.aws_db_instance.main",
+		},
+		{
+			description: "target prefix of another resource",
+			targets:     []string{"module.d"},
+			expErr:      "target \"module.d\" doesn't match any resource the plan changes",
+		},
+		{
+			description:  "targets changed since plan",
+			targets:      []string{"module.db"},
+			targetedJSON: `{"resource_changes": [{"address": "module.db.aws_db_instance.main", "change": {"actions": ["delete", "create"]}}]}`,
+			expErr:       "not applying, the changes of the targets differ from the plan for module.db.aws_db_instance.main since it was created, run atlantis plan again",
+		},
+		{
+			description:  "targets have no changes anymore",
+			targets:      []string{"module.db"},
+			targetedJSON: `{"resource_changes": []}`,
+			expErr:       "not applying, the targets have no changes anymore since the plan was created, run atlantis plan again",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			planPath := filepath.Join(tmpDir, "workspace.tfplan")
+			Ok(t, os.WriteFile(planPath, nil, 0600))
+			terraform := &targetTerraform{planJSON: planJSON, targetedJSON: c.targetedJSON}
+			step := runtime.ApplyStepRunner{
+				TerraformExecutor: terraform,
+			}
+
+			output, err := step.Run(command.ProjectContext{
+				Log:          logging.NewNoopLogger(t),
+				Workspace:    "workspace",
+				RepoRelDir:   ".",
+				ApplyTargets: c.targets,
+			}, nil, tmpDir, map[string]string(nil))
+			_, statErr := os.Stat(planPath)
+			if c.expErr != "" {
+				ErrContains(t, c.expErr, err)
+				Ok(t, statErr)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.expOut, output)
+			Assert(t, os.IsNotExist(statErr), "exp plan to be discarded")
+			Equals(t, `{"env":"prod"}`, terraform.planVars)
+			Equals(t, c.expPlanArgs, terraform.calls[1][6:])
+			Equals(t, "apply", terraform.calls[3][0])
+			Assert(t, strings.HasSuffix(terraform.calls[3][2], `targeted.tfplan"`), "exp targeted plan to be applied, got %v", terraform.calls[3])
+		})
+	}
+}
+
 // Test that apply works for remote applies.
 func TestRun_RemoteApply_Success(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -241,6 +497,7 @@ Plan: 0 to add, 0 to change, 1 to destroy.`
 	tfExec := &remoteApplyMock{LinesToSend: tfOut, DoneCh: make(chan bool)}
 	updater := runtimemocks.NewMockStatusUpdater()
 	o := runtime.ApplyStepRunner{
+		TerraformExecutor:   mocks.NewMockClient(),
 		AsyncTFExec:         tfExec,
 		CommitStatusUpdater: updater,
 	}
@@ -257,7 +514,7 @@ Plan: 0 to add, 0 to change, 1 to destroy.`
 
 	Ok(t, err)
 	Equals(t, "yes\n", tfExec.PassedInput)
-	Equals(t, `
+	Equals(t, "Terraform Cloud run: https://app.terraform.io/app/lkysow-enterprises/atlantis-tfe-test-dir2/runs/run-PiDsRYKGcerTttV2\n\n"+`
 2019/02/27 21:47:36 [DEBUG] Using modified User-Agent: Terraform/0.11.11 TFE/d161c1b
 null_resource.dir2[1]: Destroying... (ID: 8554368366766418126)
 null_resource.dir2[1]: Destruction complete after 0s
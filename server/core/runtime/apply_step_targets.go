@@ -0,0 +1,171 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/events/command"
+)
+
+// targetedApplyWarning is added to the output of an apply with --target.
+const targetedApplyWarning = "\n**Warning**: only the changes of %s were applied. The rest of the plan is stale now and was discarded, run `atlantis plan` again to apply the other changes.\n"
+
+// targetPlanChange is a resource change in the JSON of a plan.
+type targetPlanChange struct {
+	planChange
+	Address string `json:"address"`
+}
+
+// targetPlanJSON is the JSON of a plan, from terraform show -json, with what's
+// needed to plan its targets again.
+type targetPlanJSON struct {
+	ResourceChanges []targetPlanChange `json:"resource_changes"`
+	Variables       map[string]struct {
+		Value json.RawMessage `json:"value"`
+	} `json:"variables"`
+}
+
+// changes returns the actions of each resource the plan creates, updates or
+// deletes, by address.
+func (p targetPlanJSON) changes() map[string][]string {
+	changes := make(map[string][]string)
+	for _, c := range p.ResourceChanges {
+		if c.changes() {
+			changes[c.Address] = c.Change.Actions
+		}
+	}
+	return changes
+}
+
+// destroys returns true if every change of the plan deletes its resource, as
+// in a plan with -destroy.
+func (p targetPlanJSON) destroys() bool {
+	changes := p.changes()
+	for _, actions := range changes {
+		if !slices.Equal(actions, []string{"delete"}) {
+			return false
+		}
+	}
+	return len(changes) > 0
+}
+
+// matchesTarget returns true if address is target or in it, ex.
+// module.db.aws_db_instance.main is in module.db and aws_instance.web[0] is in
+// aws_instance.web.
+func matchesTarget(address string, target string) bool {
+	return address == target || strings.HasPrefix(address, target+".") || strings.HasPrefix(address, target+"[")
+}
+
+// runTargetedApply applies only the changes of ctx.ApplyTargets in the plan at
+// planPath. Terraform can't apply part of a saved plan so the targets are
+// planned again with the plan's variables, and that plan is only applied if
+// it makes the same changes to them as the saved plan.
+func (a *ApplyStepRunner) runTargetedApply(ctx command.ProjectContext, extraArgs []string, path string, planPath string, envs map[string]string) (string, error) {
+	plan, err := a.showTargetPlan(ctx, path, planPath, envs)
+	if err != nil {
+		return "", err
+	}
+	planChanges := plan.changes()
+	for _, target := range ctx.ApplyTargets {
+		found := false
+		for address := range planChanges {
+			if matchesTarget(address, target) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("target %q doesn't match any resource the plan changes, the plan changes %s", target, formatAddresses(planChanges))
+		}
+	}
+
+	tmpDir, err := os.MkdirTemp("", "atlantis-targeted-apply")
+	if err != nil {
+		return "", fmt.Errorf("creating directory for the targeted plan: %s", err)
+	}
+	defer os.RemoveAll(tmpDir) // nolint: errcheck
+
+	// The variables can be sensitive so they're only kept in tmpDir.
+	vars := make(map[string]json.RawMessage, len(plan.Variables))
+	for name, v := range plan.Variables {
+		vars[name] = v.Value
+	}
+	varsContent, err := json.Marshal(vars)
+	if err != nil {
+		return "", fmt.Errorf("encoding the plan's variables: %s", err)
+	}
+	varFile := filepath.Join(tmpDir, "plan.tfvars.json")
+	if err := os.WriteFile(varFile, varsContent, 0600); err != nil {
+		return "", fmt.Errorf("writing the plan's variables: %s", err)
+	}
+
+	targetedPlanPath := filepath.Join(tmpDir, "targeted.tfplan")
+	planArgs := []string{"plan", "-input=false", "-out", fmt.Sprintf("%q", targetedPlanPath), "-var-file", fmt.Sprintf("%q", varFile)}
+	if plan.destroys() {
+		planArgs = append(planArgs, "-destroy")
+	}
+	for _, target := range ctx.ApplyTargets {
+		planArgs = append(planArgs, fmt.Sprintf("-target=%q", target))
+	}
+	ctx.Log.Info("planning targets %s to apply them", strings.Join(ctx.ApplyTargets, ", "))
+	if out, err := a.TerraformExecutor.RunCommandWithVersion(ctx, path, planArgs, envs, ctx.TerraformVersion, ctx.Workspace); err != nil {
+		return out, fmt.Errorf("planning the targets: %s", err)
+	}
+
+	targetedPlan, err := a.showTargetPlan(ctx, path, targetedPlanPath, envs)
+	if err != nil {
+		return "", err
+	}
+	targetedChanges := targetedPlan.changes()
+	if len(targetedChanges) == 0 {
+		return "", fmt.Errorf("not applying, the targets have no changes anymore since the plan was created, run atlantis plan again")
+	}
+	var differ []string
+	for address, actions := range targetedChanges {
+		if !slices.Equal(planChanges[address], actions) {
+			differ = append(differ, address)
+		}
+	}
+	if len(differ) > 0 {
+		sort.Strings(differ)
+		return "", fmt.Errorf("not applying, the changes of the targets differ from the plan for %s since it was created, run atlantis plan again", strings.Join(differ, ", "))
+	}
+
+	args := append(append(append([]string{"apply", "-input=false"}, extraArgs...), ctx.EscapedCommentArgs...), fmt.Sprintf("%q", targetedPlanPath))
+	out, err := a.TerraformExecutor.RunCommandWithVersion(ctx, path, args, envs, ctx.TerraformVersion, ctx.Workspace)
+	if err != nil {
+		return out, err
+	}
+	return out + fmt.Sprintf(targetedApplyWarning, "`"+strings.Join(ctx.ApplyTargets, "`, `")+"`"), nil
+}
+
+// showTargetPlan returns the JSON of the plan at planPath.
+func (a *ApplyStepRunner) showTargetPlan(ctx command.ProjectContext, path string, planPath string, envs map[string]string) (targetPlanJSON, error) {
+	var plan targetPlanJSON
+	out, err := a.TerraformExecutor.RunCommandWithVersion(ctx, path, []string{"show", "-json", fmt.Sprintf("%q", planPath)}, envs, ctx.TerraformVersion, ctx.Workspace)
+	if err != nil {
+		return plan, fmt.Errorf("running terraform show: %s", err)
+	}
+	if err := json.Unmarshal([]byte(out), &plan); err != nil {
+		return plan, fmt.Errorf("parsing plan JSON: %s", err)
+	}
+	return plan, nil
+}
+
+// formatAddresses returns the sorted addresses of changes, ex. for an error.
+func formatAddresses(changes map[string][]string) string {
+	if len(changes) == 0 {
+		return "nothing"
+	}
+	addresses := make([]string, 0, len(changes))
+	for address := range changes {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return strings.Join(addresses, ", ")
+}
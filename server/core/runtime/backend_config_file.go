@@ -0,0 +1,33 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// ResolveBackendConfigFile returns the absolute path of the backend config
+// file of workspace in configs, the project's backend_configs, whose paths are
+// relative to the project directory path. It returns an error if configs has
+// no backend config for workspace, so the project can't be initialized with
+// the backend of another workspace. The file must be inside repoDir so repos
+// can't point Terraform at arbitrary files on the Atlantis server.
+func ResolveBackendConfigFile(repoDir string, path string, configs map[string]string, workspace string) (string, error) {
+	file, ok := configs[workspace]
+	if !ok {
+		return "", fmt.Errorf("backend_configs has no backend config for workspace %q", workspace)
+	}
+	absFile, err := resolveRepoFile(repoDir, path, file, "backend config")
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(absFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading backend config %q", file)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("backend config %q must be a file", file)
+	}
+	return absFile, nil
+}
@@ -0,0 +1,71 @@
+package runtime_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestResolveBackendConfigFile(t *testing.T) {
+	tmp := t.TempDir()
+	repoDir := filepath.Join(tmp, "repo")
+	projDir := filepath.Join(repoDir, "project")
+	Ok(t, os.MkdirAll(filepath.Join(projDir, "backends"), 0700))
+	Ok(t, os.WriteFile(filepath.Join(projDir, "backends", "staging.hcl"), []byte("bucket = \"staging\"\n"), 0600))
+	Ok(t, os.WriteFile(filepath.Join(tmp, "server.hcl"), nil, 0600))
+	configs := map[string]string{
+		"staging":    "backends/staging.hcl",
+		"production": "backends/production.hcl",
+		"dev":        "../../server.hcl",
+		"test":       "backends",
+	}
+
+	cases := []struct {
+		description string
+		workspace   string
+		expFile     string
+		expErr      string
+	}{
+		{
+			description: "workspace's file",
+			workspace:   "staging",
+			expFile:     filepath.Join(projDir, "backends", "staging.hcl"),
+		},
+		{
+			description: "workspace without a backend config",
+			workspace:   "default",
+			expErr:      "backend_configs has no backend config for workspace \"default\"",
+		},
+		{
+			description: "missing file",
+			workspace:   "production",
+			expErr:      "reading backend config \"backends/production.hcl\"",
+		},
+		{
+			description: "file outside the repo",
+			workspace:   "dev",
+			expErr:      "backend config \"../../server.hcl\" must be inside the repo",
+		},
+		{
+			description: "dir",
+			workspace:   "test",
+			expErr:      "backend config \"backends\" must be a file",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			file, err := runtime.ResolveBackendConfigFile(repoDir, projDir, configs, c.workspace)
+			if c.expErr != "" {
+				ErrContains(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			expFile, err := filepath.EvalSymlinks(c.expFile)
+			Ok(t, err)
+			Equals(t, expFile, file)
+		})
+	}
+}
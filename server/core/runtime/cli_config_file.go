@@ -0,0 +1,27 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// ResolveCLIConfigFile returns the absolute path of the Terraform CLI config
+// file, a path relative to the project directory path set by the project's
+// cli_config. The file must be inside repoDir so repos can't point Terraform
+// at arbitrary files on the Atlantis server.
+func ResolveCLIConfigFile(repoDir string, path string, file string) (string, error) {
+	absFile, err := resolveRepoFile(repoDir, path, file, "cli_config")
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(absFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading cli_config %q", file)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("cli_config %q must be a file", file)
+	}
+	return absFile, nil
+}
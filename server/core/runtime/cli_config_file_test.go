@@ -0,0 +1,72 @@
+package runtime_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestResolveCLIConfigFile(t *testing.T) {
+	tmp := t.TempDir()
+	repoDir := filepath.Join(tmp, "repo")
+	projDir := filepath.Join(repoDir, "project")
+	Ok(t, os.MkdirAll(filepath.Join(projDir, "dir"), 0700))
+	Ok(t, os.WriteFile(filepath.Join(projDir, "custom.tfrc"), []byte("plugin_cache_dir = \"/tmp\"\n"), 0600))
+	Ok(t, os.WriteFile(filepath.Join(repoDir, ".terraformrc"), nil, 0600))
+	Ok(t, os.WriteFile(filepath.Join(tmp, "server.tfrc"), nil, 0600))
+	Ok(t, os.Symlink(filepath.Join(tmp, "server.tfrc"), filepath.Join(projDir, "link.tfrc")))
+
+	cases := []struct {
+		description string
+		file        string
+		expFile     string
+		expErr      string
+	}{
+		{
+			description: "file in the project's dir",
+			file:        "custom.tfrc",
+			expFile:     filepath.Join(projDir, "custom.tfrc"),
+		},
+		{
+			description: "file elsewhere in the repo",
+			file:        "../.terraformrc",
+			expFile:     filepath.Join(repoDir, ".terraformrc"),
+		},
+		{
+			description: "file outside the repo",
+			file:        "../../server.tfrc",
+			expErr:      "cli_config \"../../server.tfrc\" must be inside the repo",
+		},
+		{
+			description: "symlink outside the repo",
+			file:        "link.tfrc",
+			expErr:      "cli_config \"link.tfrc\" must be inside the repo",
+		},
+		{
+			description: "dir",
+			file:        "dir",
+			expErr:      "cli_config \"dir\" must be a file",
+		},
+		{
+			description: "missing file",
+			file:        "missing.tfrc",
+			expErr:      "reading cli_config \"missing.tfrc\"",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			file, err := runtime.ResolveCLIConfigFile(repoDir, projDir, c.file)
+			if c.expErr != "" {
+				ErrContains(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			expFile, err := filepath.EvalSymlinks(c.expFile)
+			Ok(t, err)
+			Equals(t, expFile, file)
+		})
+	}
+}
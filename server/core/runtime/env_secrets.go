@@ -0,0 +1,62 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+)
+
+// SecretStore holds the secrets env steps reference with
+// ${{ secrets.NAME }} expressions.
+type SecretStore interface {
+	// Secret returns the value of the secret called name. It returns an
+	// error if there's no such secret.
+	Secret(name string) (string, error)
+}
+
+// DirSecretStore is a SecretStore where each secret is a file in Dir named
+// after the secret, ex. a mounted Kubernetes secret.
+type DirSecretStore struct {
+	Dir string
+}
+
+// Secret implements SecretStore. A trailing newline is removed from the
+// file's contents.
+func (d *DirSecretStore) Secret(name string) (string, error) {
+	contents, err := os.ReadFile(filepath.Join(d.Dir, filepath.Base(name)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("unknown secret %q, it isn't in the server's secrets", name)
+		}
+		return "", fmt.Errorf("reading secret %q: %w", name, err)
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(contents), "\n"), "\r"), nil
+}
+
+// ResolveEnvSecrets returns value with its ${{ secrets.NAME }} expressions
+// replaced by the secrets in store, and those secrets so they can be masked.
+// If value has no expressions it's returned as is.
+func ResolveEnvSecrets(value string, store SecretStore) (string, []string, error) {
+	names, err := valid.ParseSecretRefs(value)
+	if err != nil || len(names) == 0 {
+		return value, nil, err
+	}
+	if store == nil {
+		return "", nil, fmt.Errorf("can't resolve secret %q, the Atlantis server doesn't have a secret store", names[0])
+	}
+	var secrets []string
+	resolved, err := valid.ExpandSecretRefs(value, func(name string) (string, error) {
+		secret, err := store.Secret(name)
+		if err == nil && secret != "" {
+			secrets = append(secrets, secret)
+		}
+		return secret, err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return resolved, secrets, nil
+}
@@ -0,0 +1,32 @@
+package runtime_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestResolveEnvSecrets(t *testing.T) {
+	dir := t.TempDir()
+	Ok(t, os.WriteFile(filepath.Join(dir, "TOKEN"), []byte("s3cr3t-token\n"), 0600))
+	store := &runtime.DirSecretStore{Dir: dir}
+
+	value, secrets, err := runtime.ResolveEnvSecrets("Bearer ${{ secrets.TOKEN }}", store)
+	Ok(t, err)
+	Equals(t, "Bearer s3cr3t-token", value)
+	Equals(t, []string{"s3cr3t-token"}, secrets)
+
+	value, secrets, err = runtime.ResolveEnvSecrets("plain", nil)
+	Ok(t, err)
+	Equals(t, "plain", value)
+	Equals(t, 0, len(secrets))
+
+	_, _, err = runtime.ResolveEnvSecrets("${{ secrets.MISSING }}", store)
+	ErrEquals(t, "unknown secret \"MISSING\", it isn't in the server's secrets", err)
+
+	_, _, err = runtime.ResolveEnvSecrets("${{ secrets.TOKEN }}", nil)
+	ErrEquals(t, "can't resolve secret \"TOKEN\", the Atlantis server doesn't have a secret store", err)
+}
@@ -0,0 +1,64 @@
+package runtime
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// extraArgsFileComment is the prefix of comment lines in an extra_args_file.
+const extraArgsFileComment = "#"
+
+// ReadExtraArgsFile reads the extra args stored in file, a path relative to the
+// project directory path. Each non-empty line is a single argument and lines
+// beginning with # are comments. The file must be inside repoDir so repos
+// can't read arbitrary files from the Atlantis server.
+func ReadExtraArgsFile(repoDir string, path string, file string) ([]string, error) {
+	absFile, err := resolveRepoFile(repoDir, path, file, "extra_args_file")
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(absFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading extra_args_file %q", file)
+	}
+	defer f.Close() // nolint: errcheck
+
+	var args []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, extraArgsFileComment) {
+			continue
+		}
+		args = append(args, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "reading extra_args_file %q", file)
+	}
+	return args, nil
+}
+
+// resolveRepoFile returns the absolute path of file, a path relative to the
+// project directory path, after resolving symlinks. It returns an error if the
+// file isn't inside repoDir. option is the name of the option file is set by.
+func resolveRepoFile(repoDir string, path string, file string, option string) (string, error) {
+	absFile, err := filepath.EvalSymlinks(filepath.Join(path, file))
+	if err != nil {
+		return "", errors.Wrapf(err, "reading %s %q", option, file)
+	}
+	absRepoDir, err := filepath.EvalSymlinks(repoDir)
+	if err != nil {
+		return "", errors.Wrapf(err, "resolving repo dir %q", repoDir)
+	}
+	rel, err := filepath.Rel(absRepoDir, absFile)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s %q must be inside the repo", option, file)
+	}
+	return absFile, nil
+}
@@ -0,0 +1,70 @@
+package runtime_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestReadExtraArgsFile(t *testing.T) {
+	repoDir := t.TempDir()
+	projDir := filepath.Join(repoDir, "project")
+	Ok(t, os.MkdirAll(projDir, 0700))
+	Ok(t, os.WriteFile(filepath.Join(projDir, "plan-args.txt"), []byte(`# plan args
+-var-file=staging.tfvars
+
+  -lock-timeout=5m
+`), 0600))
+	Ok(t, os.WriteFile(filepath.Join(repoDir, "shared-args.txt"), []byte("-parallelism=5\n"), 0600))
+
+	outsideDir := t.TempDir()
+	Ok(t, os.WriteFile(filepath.Join(outsideDir, "secret.txt"), []byte("secret\n"), 0600))
+	Ok(t, os.Symlink(filepath.Join(outsideDir, "secret.txt"), filepath.Join(projDir, "link.txt")))
+
+	cases := []struct {
+		description string
+		file        string
+		expArgs     []string
+		expErr      string
+	}{
+		{
+			description: "one arg per line with comments and blank lines",
+			file:        "plan-args.txt",
+			expArgs:     []string{"-var-file=staging.tfvars", "-lock-timeout=5m"},
+		},
+		{
+			description: "file elsewhere in the repo",
+			file:        "../shared-args.txt",
+			expArgs:     []string{"-parallelism=5"},
+		},
+		{
+			description: "file outside the repo",
+			file:        "../../" + filepath.Base(outsideDir) + "/secret.txt",
+			expErr:      "must be inside the repo",
+		},
+		{
+			description: "symlink outside the repo",
+			file:        "link.txt",
+			expErr:      "must be inside the repo",
+		},
+		{
+			description: "missing file",
+			file:        "missing.txt",
+			expErr:      "reading extra_args_file \"missing.txt\"",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			args, err := runtime.ReadExtraArgsFile(repoDir, projDir, c.file)
+			if c.expErr != "" {
+				ErrContains(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.expArgs, args)
+		})
+	}
+}
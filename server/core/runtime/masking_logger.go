@@ -0,0 +1,61 @@
+package runtime
+
+import (
+	"fmt"
+
+	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/utils"
+)
+
+// maskingLogger masks secrets in every message it logs.
+type maskingLogger struct {
+	logging.SimpleLogging
+	secrets []string
+}
+
+// NewMaskingLogger returns a logger that logs to logger with every value of
+// secrets masked. If there are no secrets logger is returned.
+func NewMaskingLogger(logger logging.SimpleLogging, secrets []string) logging.SimpleLogging {
+	if len(secrets) == 0 {
+		return logger
+	}
+	return &maskingLogger{SimpleLogging: logger, secrets: secrets}
+}
+
+func (l *maskingLogger) Debug(format string, a ...interface{}) {
+	l.SimpleLogging.Debug("%s", l.mask(format, a))
+}
+
+func (l *maskingLogger) Info(format string, a ...interface{}) {
+	l.SimpleLogging.Info("%s", l.mask(format, a))
+}
+
+func (l *maskingLogger) Warn(format string, a ...interface{}) {
+	l.SimpleLogging.Warn("%s", l.mask(format, a))
+}
+
+func (l *maskingLogger) Err(format string, a ...interface{}) {
+	l.SimpleLogging.Err("%s", l.mask(format, a))
+}
+
+func (l *maskingLogger) Log(level logging.LogLevel, format string, a ...interface{}) {
+	l.SimpleLogging.Log(level, "%s", l.mask(format, a))
+}
+
+func (l *maskingLogger) With(a ...interface{}) logging.SimpleLogging {
+	return &maskingLogger{SimpleLogging: l.SimpleLogging.With(a...), secrets: l.secrets}
+}
+
+func (l *maskingLogger) WithHistory(a ...interface{}) logging.SimpleLogging {
+	return &maskingLogger{SimpleLogging: l.SimpleLogging.WithHistory(a...), secrets: l.secrets}
+}
+
+func (l *maskingLogger) mask(format string, a []interface{}) string {
+	return utils.MaskSecrets(fmt.Sprintf(format, a...), l.secrets)
+}
+
+// MaskableValue returns true if value is long enough to be masked. Shorter
+// values would mask too much unrelated output.
+func MaskableValue(value string) bool {
+	return len(value) >= minMaskedValueLength
+}
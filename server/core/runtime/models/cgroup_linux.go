@@ -0,0 +1,208 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// cgroupRoot is where cgroups v2 is mounted.
+	cgroupRoot = "/sys/fs/cgroup"
+	// cgroupServerLeaf is the cgroup the server moves itself into so its own
+	// cgroup can have children with controllers enabled.
+	cgroupServerLeaf = "atlantis-server"
+	// cgroupCPUPeriod is the period in microseconds the CPU limit of a cgroup
+	// is enforced over.
+	cgroupCPUPeriod = 100000
+)
+
+var (
+	cgroupParentOnce sync.Once
+	cgroupParent     string
+	cgroupParentErr  error
+)
+
+// cgroup is a cgroup a command with resource limits runs in.
+type cgroup struct {
+	dir string
+	fd  *os.File
+}
+
+// checkCgroups returns an error if commands can't run in cgroups with
+// resource limits.
+func checkCgroups() error {
+	_, err := stepCgroupParent()
+	return err
+}
+
+// stepCgroupParent returns the cgroup the cgroups of commands are created in,
+// the server's cgroup. The first time it's called the memory and cpu
+// controllers are enabled for its children. Since cgroups v2 only allows that
+// in cgroups without processes, the server moves itself into a leaf child of
+// its cgroup if needed.
+func stepCgroupParent() (string, error) {
+	cgroupParentOnce.Do(func() {
+		cgroupParent, cgroupParentErr = setupCgroupParent()
+	})
+	return cgroupParent, cgroupParentErr
+}
+
+func setupCgroupParent() (string, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("cgroups v2 isn't mounted at %s", cgroupRoot)
+	}
+	self, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	var rel string
+	for _, line := range strings.Split(string(self), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			rel = strings.TrimPrefix(line, "0::")
+		}
+	}
+	if rel == "" {
+		return "", fmt.Errorf("unable to find the server's cgroup in /proc/self/cgroup")
+	}
+	parent := filepath.Join(cgroupRoot, rel)
+
+	enableControllers := func() error {
+		return writeCgroupFile(filepath.Join(parent, "cgroup.subtree_control"), "+memory +cpu")
+	}
+	err = enableControllers()
+	if errors.Is(err, syscall.EBUSY) {
+		leaf := filepath.Join(parent, cgroupServerLeaf)
+		if err := os.Mkdir(leaf, 0755); err != nil && !os.IsExist(err) {
+			return "", fmt.Errorf("creating cgroup for the server: %w", err)
+		}
+		if err := writeCgroupFile(filepath.Join(leaf, "cgroup.procs"), strconv.Itoa(os.Getpid())); err != nil {
+			return "", fmt.Errorf("moving the server into cgroup %s: %w", leaf, err)
+		}
+		err = enableControllers()
+	}
+	if err != nil {
+		return "", fmt.Errorf("enabling the memory and cpu controllers in cgroup %s, the server needs to be delegated its cgroup: %w", parent, err)
+	}
+	return parent, nil
+}
+
+// newCgroup creates a cgroup limited to memoryBytes of memory, without swap,
+// and cpus CPUs. A limit of 0 isn't set.
+func newCgroup(memoryBytes int64, cpus float64) (*cgroup, error) {
+	parent, err := stepCgroupParent()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(parent, "atlantis-step-")
+	if err != nil {
+		return nil, err
+	}
+	c := &cgroup{dir: dir}
+	if err := c.setLimits(memoryBytes, cpus); err != nil {
+		c.remove()
+		return nil, err
+	}
+	if c.fd, err = os.Open(dir); err != nil {
+		c.remove()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *cgroup) setLimits(memoryBytes int64, cpus float64) error {
+	if memoryBytes > 0 {
+		if err := c.write("memory.max", strconv.FormatInt(memoryBytes, 10)); err != nil {
+			return err
+		}
+		// Without swap the command is killed at the limit rather than slowing
+		// down the host. The file doesn't exist if swap accounting is off.
+		if err := c.write("memory.swap.max", "0"); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if cpus > 0 {
+		quota := int64(cpus * cgroupCPUPeriod)
+		if err := c.write("cpu.max", fmt.Sprintf("%d %d", quota, cgroupCPUPeriod)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attach makes cmd start in the cgroup.
+func (c *cgroup) attach(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(c.fd.Fd())
+}
+
+// release removes the cgroup after its command exited with err, killing any
+// processes the command left behind. If the command failed because it was
+// killed for using more than its memory limit the error wraps
+// ErrMemoryLimitExceeded.
+func (c *cgroup) release(err error) error {
+	if err != nil && c.oomKilled() {
+		err = fmt.Errorf("%w: %s", ErrMemoryLimitExceeded, err)
+	}
+	c.remove()
+	return err
+}
+
+// oomKilled returns true if a process in the cgroup was killed for using more
+// than its memory limit.
+func (c *cgroup) oomKilled() bool {
+	events, err := os.ReadFile(filepath.Join(c.dir, "memory.events"))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(events), "\n") {
+		if count, ok := strings.CutPrefix(line, "oom_kill "); ok {
+			return count != "0"
+		}
+	}
+	return false
+}
+
+// remove kills the processes in the cgroup and removes it. Removing it is
+// retried for a second since killed processes take a moment to exit.
+func (c *cgroup) remove() {
+	if c.fd != nil {
+		c.fd.Close() // nolint: errcheck
+	}
+	c.write("cgroup.kill", "1") // nolint: errcheck
+	for i := 0; i < 100; i++ {
+		if err := os.Remove(c.dir); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (c *cgroup) write(file string, value string) error {
+	return writeCgroupFile(filepath.Join(c.dir, file), value)
+}
+
+// writeCgroupFile writes value to the cgroup interface file path. Unlike
+// os.WriteFile it doesn't try to create it, so it fails with a not exist
+// error if the file isn't supported.
+func writeCgroupFile(path string, value string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(value)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package models
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// cgroup is a cgroup a command with resource limits runs in. They're only
+// supported on Linux.
+type cgroup struct{}
+
+// checkCgroups returns an error since cgroups are only supported on Linux.
+func checkCgroups() error {
+	return fmt.Errorf("limiting the resources of commands is only supported on linux, not %s", runtime.GOOS)
+}
+
+func newCgroup(_ int64, _ float64) (*cgroup, error) {
+	return nil, checkCgroups()
+}
+
+func (c *cgroup) attach(_ *exec.Cmd) {}
+
+func (c *cgroup) release(err error) error {
+	return err
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// ErrDeadlineExceeded is wrapped by the error of a command that was killed
+// because it was still running at its deadline, ex. the end of its
+// workflow's timeout.
+var ErrDeadlineExceeded = errors.New("deadline exceeded")
+
+// ErrCancelled is wrapped by the error of a command that was killed because
+// its run was cancelled, ex. when its pull request was closed.
+var ErrCancelled = errors.New("cancelled")
+
+// DeadlineKillGracePeriod is how long a command is given to exit after it's
+// interrupted at its deadline before it's killed, ex. so Terraform can
+// release its state lock.
+var DeadlineKillGracePeriod = 30 * time.Second
+
+// DeadlineKiller interrupts a command and the processes it started once its
+// deadline passes or its run is cancelled, and kills them if they're still
+// running after DeadlineKillGracePeriod.
+type DeadlineKiller struct {
+	mu            sync.Mutex
+	deadlineTimer *time.Timer
+	killTimer     *time.Timer
+	stop          chan struct{}
+	// reason is ErrDeadlineExceeded or ErrCancelled once the command was
+	// interrupted.
+	reason  error
+	stopped bool
+}
+
+// PrepareDeadline sets cmd up so the processes it starts can be killed with
+// it at deadline or once cancel is closed. It must be called before cmd is
+// started and does nothing if deadline is zero and cancel is nil.
+func PrepareDeadline(cmd *exec.Cmd, deadline time.Time, cancel <-chan struct{}) {
+	if !deadline.IsZero() || cancel != nil {
+		setProcessGroup(cmd)
+	}
+}
+
+// StartDeadline starts the DeadlineKiller of cmd, which must have been
+// started. It returns nil if deadline is zero and cancel is nil.
+func StartDeadline(cmd *exec.Cmd, deadline time.Time, cancel <-chan struct{}) *DeadlineKiller {
+	if deadline.IsZero() && cancel == nil {
+		return nil
+	}
+	k := &DeadlineKiller{stop: make(chan struct{})}
+	var expired <-chan time.Time
+	if !deadline.IsZero() {
+		k.deadlineTimer = time.NewTimer(time.Until(deadline))
+		expired = k.deadlineTimer.C
+	}
+	go func() {
+		select {
+		case <-k.stop:
+		case <-expired:
+			k.interrupt(cmd, ErrDeadlineExceeded)
+		case <-cancel:
+			k.interrupt(cmd, ErrCancelled)
+		}
+	}()
+	return k
+}
+
+// interrupt interrupts cmd because of reason and kills it if it's still
+// running after DeadlineKillGracePeriod, unless k was stopped.
+func (k *DeadlineKiller) interrupt(cmd *exec.Cmd, reason error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.stopped {
+		return
+	}
+	k.reason = reason
+	interruptProcessGroup(cmd)
+	k.killTimer = time.AfterFunc(DeadlineKillGracePeriod, func() {
+		killProcessGroup(cmd)
+	})
+}
+
+// Stop stops k once its command exited with err. It returns err, wrapping
+// ErrDeadlineExceeded or ErrCancelled if the command was interrupted at its
+// deadline or because its run was cancelled. k can be nil.
+func (k *DeadlineKiller) Stop(err error) error {
+	if k == nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.stopped {
+		k.stopped = true
+		close(k.stop)
+	}
+	if k.deadlineTimer != nil {
+		k.deadlineTimer.Stop()
+	}
+	if k.killTimer != nil {
+		k.killTimer.Stop()
+	}
+	if k.reason != nil {
+		if err == nil {
+			return k.reason
+		}
+		return fmt.Errorf("%w: %s", k.reason, err)
+	}
+	return err
+}
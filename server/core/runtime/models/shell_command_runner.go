@@ -33,8 +33,19 @@ type ShellCommandRunner struct {
 	outputHandler jobs.ProjectCommandOutputHandler
 	streamOutput  bool
 	cmd           *exec.Cmd
+	// stdin, if set, is written to the command's stdin instead of what's
+	// sent on the input channel of RunCommandAsync.
+	stdin string
+	// memoryLimit and cpuLimit, if set, are the limits of the cgroup the
+	// command runs in.
+	memoryLimit int64
+	cpuLimit    float64
 }
 
+// ErrMemoryLimitExceeded is wrapped by the error of a command that was killed
+// for using more memory than its limit.
+var ErrMemoryLimitExceeded = errors.New("out of memory")
+
 func NewShellCommandRunner(command string, environ []string, workingDir string, streamOutput bool, outputHandler jobs.ProjectCommandOutputHandler) *ShellCommandRunner {
 	cmd := exec.Command("sh", "-c", command) // #nosec
 	cmd.Env = environ
@@ -49,6 +60,32 @@ func NewShellCommandRunner(command string, environ []string, workingDir string,
 	}
 }
 
+// SetStdin sets the command's stdin to input. The command reads EOF after
+// input so it doesn't wait for more.
+func (s *ShellCommandRunner) SetStdin(input string) {
+	s.stdin = input
+}
+
+// DisableNetwork makes the command run without network access so any attempt
+// to reach the network fails. It returns an error if that isn't supported on
+// this platform.
+func (s *ShellCommandRunner) DisableNetwork() error {
+	return isolateNetwork(s.cmd)
+}
+
+// LimitResources makes the command run in its own cgroup that limits it to
+// memoryBytes of memory and cpus CPUs, with 0 meaning no limit. If it uses
+// more memory it's killed and fails with ErrMemoryLimitExceeded. It returns
+// an error if that isn't supported on this platform.
+func (s *ShellCommandRunner) LimitResources(memoryBytes int64, cpus float64) error {
+	if err := checkCgroups(); err != nil {
+		return err
+	}
+	s.memoryLimit = memoryBytes
+	s.cpuLimit = cpus
+	return nil
+}
+
 func (s *ShellCommandRunner) Run(ctx command.ProjectContext) (string, error) {
 	_, outCh := s.RunCommandAsync(ctx)
 
@@ -90,21 +127,47 @@ func (s *ShellCommandRunner) RunCommandAsync(ctx command.ProjectContext) (chan<-
 
 		stdout, _ := s.cmd.StdoutPipe()
 		stderr, _ := s.cmd.StderrPipe()
-		stdin, _ := s.cmd.StdinPipe()
+		var stdin io.WriteCloser
+		if s.stdin != "" {
+			s.cmd.Stdin = strings.NewReader(s.stdin)
+		} else {
+			stdin, _ = s.cmd.StdinPipe()
+		}
+
+		var cg *cgroup
+		if s.memoryLimit > 0 || s.cpuLimit > 0 {
+			var err error
+			if cg, err = newCgroup(s.memoryLimit, s.cpuLimit); err != nil {
+				err = errors.Wrapf(err, "creating cgroup for %q", s.command)
+				ctx.Log.Err(err.Error())
+				outCh <- Line{Err: err}
+				return
+			}
+			cg.attach(s.cmd)
+		}
 
+		PrepareDeadline(s.cmd, ctx.Deadline, ctx.Cancelled)
 		ctx.Log.Debug("starting %q in %q", s.command, s.workingDir)
 		err := s.cmd.Start()
 		if err != nil {
+			if cg != nil {
+				cg.release(nil) // nolint: errcheck
+			}
 			err = errors.Wrapf(err, "running %q in %q", s.command, s.workingDir)
 			ctx.Log.Err(err.Error())
 			outCh <- Line{Err: err}
 			return
 		}
+		deadline := StartDeadline(s.cmd, ctx.Deadline, ctx.Cancelled)
 
 		// If we get anything on inCh, write it to stdin.
 		// This function will exit when inCh is closed which we do in our defer.
 		go func() {
 			for line := range inCh {
+				if stdin == nil {
+					ctx.Log.Warn("not writing %q to remote command's stdin since it's already set", line)
+					continue
+				}
 				ctx.Log.Debug("writing %q to remote command's stdin", line)
 				_, err := io.WriteString(stdin, line)
 				if err != nil {
@@ -147,7 +210,10 @@ func (s *ShellCommandRunner) RunCommandAsync(ctx command.ProjectContext) (chan<-
 		wg.Wait()
 
 		// Wait for the command to complete.
-		err = s.cmd.Wait()
+		err = deadline.Stop(s.cmd.Wait())
+		if cg != nil {
+			err = cg.release(err)
+		}
 
 		dur := time.Since(start)
 		log := ctx.Log.With("duration", dur)
@@ -0,0 +1,34 @@
+//go:build !linux
+
+package models
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// isolateNetwork returns an error since network namespaces are only supported
+// on Linux.
+func isolateNetwork(_ *exec.Cmd) error {
+	return fmt.Errorf("running commands without network access is only supported on linux, not %s", runtime.GOOS)
+}
+
+// setProcessGroup does nothing since process groups are only used on Linux,
+// so only cmd itself is signalled.
+func setProcessGroup(_ *exec.Cmd) {}
+
+// interruptProcessGroup interrupts cmd.
+func interruptProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Signal(os.Interrupt) // nolint: errcheck
+	}
+}
+
+// killProcessGroup kills cmd.
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill() // nolint: errcheck
+	}
+}
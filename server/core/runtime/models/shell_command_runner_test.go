@@ -1,15 +1,18 @@
 package models_test
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	. "github.com/petergtz/pegomock/v4"
 	"github.com/runatlantis/atlantis/server/core/runtime/models"
 	"github.com/runatlantis/atlantis/server/events/command"
 	"github.com/runatlantis/atlantis/server/jobs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
 	logmocks "github.com/runatlantis/atlantis/server/logging/mocks"
 	. "github.com/runatlantis/atlantis/testing"
 )
@@ -78,3 +81,49 @@ func TestShellCommandRunner_Run(t *testing.T) {
 		})
 	}
 }
+
+// Test that a command still running at its deadline is killed, with the
+// processes it started.
+func TestShellCommandRunner_RunDeadline(t *testing.T) {
+	RegisterMockTestingT(t)
+	gracePeriod := models.DeadlineKillGracePeriod
+	models.DeadlineKillGracePeriod = 100 * time.Millisecond
+	defer func() { models.DeadlineKillGracePeriod = gracePeriod }()
+	ctx := command.ProjectContext{
+		Log:      logging.NewNoopLogger(t),
+		Deadline: time.Now().Add(200 * time.Millisecond),
+	}
+
+	// The background sleep ignores SIGINT so it's only killed after the grace
+	// period, and keeps the output open until then.
+	start := time.Now()
+	runner := models.NewShellCommandRunner("echo started; sleep 30 & sleep 30; echo done", nil, t.TempDir(), false, mocks.NewMockProjectCommandOutputHandler())
+	output, err := runner.Run(ctx)
+	Assert(t, errors.Is(err, models.ErrDeadlineExceeded), "exp deadline exceeded, got %v", err)
+	Equals(t, "started\n", output)
+	Assert(t, time.Since(start) < 10*time.Second, "exp command to be killed, took %s", time.Since(start))
+
+	// Without a deadline it isn't killed.
+	ctx.Deadline = time.Time{}
+	output, err = models.NewShellCommandRunner("sleep 0.3; echo done", nil, t.TempDir(), false, mocks.NewMockProjectCommandOutputHandler()).Run(ctx)
+	Ok(t, err)
+	Equals(t, "done\n", output)
+}
+
+// Test that a command is interrupted once its run is cancelled.
+func TestShellCommandRunner_RunCancelled(t *testing.T) {
+	RegisterMockTestingT(t)
+	cancel := make(chan struct{})
+	ctx := command.ProjectContext{
+		Log:       logging.NewNoopLogger(t),
+		Cancelled: cancel,
+	}
+	time.AfterFunc(200*time.Millisecond, func() { close(cancel) })
+
+	start := time.Now()
+	runner := models.NewShellCommandRunner("echo started; sleep 30; echo done", nil, t.TempDir(), false, mocks.NewMockProjectCommandOutputHandler())
+	output, err := runner.Run(ctx)
+	Assert(t, errors.Is(err, models.ErrCancelled), "exp cancelled, got %v", err)
+	Equals(t, "started\n", output)
+	Assert(t, time.Since(start) < 10*time.Second, "exp command to be interrupted, took %s", time.Since(start))
+}
@@ -3,6 +3,7 @@ package runtime
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/runatlantis/atlantis/server/core/config/valid"
@@ -16,8 +17,9 @@ type MultiEnvStepRunner struct {
 
 // Run runs the multienv step command.
 // The command must return a json string containing the array of name-value pairs that are being added as extra environment variables
-func (r *MultiEnvStepRunner) Run(ctx command.ProjectContext, command string, path string, envs map[string]string) (string, error) {
-	res, err := r.RunStepRunner.Run(ctx, command, path, envs, false, valid.PostProcessRunOutputShow)
+// If the step's mode is append, values are appended to the existing values of the variables instead of replacing them.
+func (r *MultiEnvStepRunner) Run(ctx command.ProjectContext, step valid.Step, path string, envs map[string]string) (string, error) {
+	res, err := r.RunStepRunner.Run(ctx, valid.Step{RunCommand: step.RunCommand, Output: valid.PostProcessRunOutputShow}, path, envs, false)
 	if err != nil {
 		return "", err
 	}
@@ -34,16 +36,52 @@ func (r *MultiEnvStepRunner) Run(ctx command.ProjectContext, command string, pat
 		return "", fmt.Errorf("Invalid environment variable definition: %s (%w)", res, err)
 	}
 
+	// before and after are the values of the variables set by the step
+	// before and after it ran, for debug_env_diff.
+	before := make(map[string]string)
+	after := make(map[string]string)
 	for i := 0; i < len(vars); i += 2 {
 		key := vars[i]
-		envs[key] = vars[i+1]
+		value := vars[i+1]
+		if _, seen := after[key]; step.DebugEnvDiff && !seen {
+			if existing, ok := r.existingEnv(ctx, key, envs); ok {
+				before[key] = existing
+			}
+		}
+		if step.MultiEnvMode == valid.MultiEnvModeAppend {
+			if existing, ok := r.existingEnv(ctx, key, envs); ok && existing != "" {
+				value = existing + step.MultiEnvSeparator + value
+			}
+		}
+		envs[key] = value
+		after[key] = value
 		sb.WriteString(key)
 		sb.WriteRune('\n')
 	}
+	if step.DebugEnvDiff {
+		sb.WriteString("\n" + envDiff(before, after))
+	}
 
 	return sb.String(), nil
 }
 
+// existingEnv returns the value of the environment variable key that commands
+// run by later steps would get if the multienv step didn't set it.
+func (r *MultiEnvStepRunner) existingEnv(ctx command.ProjectContext, key string, envs map[string]string) (string, bool) {
+	if v, ok := envs[key]; ok {
+		return v, true
+	}
+	if key == "PATH" {
+		return r.RunStepRunner.defaultPath(), true
+	}
+	for _, kv := range passthroughEnv(os.Environ(), ctx.EnvPassthrough) {
+		if name, value, _ := strings.Cut(kv, "="); name == key {
+			return value, true
+		}
+	}
+	return "", false
+}
+
 func parseMultienvLine(in string) ([]string, error) {
 	in = strings.TrimSpace(in)
 	if in == "" {
@@ -5,6 +5,7 @@ import (
 
 	version "github.com/hashicorp/go-version"
 	. "github.com/petergtz/pegomock/v4"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
 	"github.com/runatlantis/atlantis/server/core/runtime"
 	"github.com/runatlantis/atlantis/server/core/terraform/mocks"
 	"github.com/runatlantis/atlantis/server/events/command"
@@ -84,7 +85,7 @@ func TestMultiEnvStepRunner_Run(t *testing.T) {
 				ProjectName:      c.ProjectName,
 			}
 			envMap := make(map[string]string)
-			value, err := multiEnvStepRunner.Run(ctx, c.Command, tmpDir, envMap)
+			value, err := multiEnvStepRunner.Run(ctx, valid.Step{RunCommand: c.Command}, tmpDir, envMap)
 			if c.ExpErr != "" {
 				ErrContains(t, c.ExpErr, err)
 				return
@@ -94,3 +95,98 @@ func TestMultiEnvStepRunner_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestMultiEnvStepRunner_RunAppend(t *testing.T) {
+	RegisterMockTestingT(t)
+	tfVersion, err := version.NewVersion("0.12.0")
+	Ok(t, err)
+	runStepRunner := runtime.RunStepRunner{
+		TerraformExecutor:       mocks.NewMockClient(),
+		DefaultTFVersion:        tfVersion,
+		TerraformBinDir:         "/bin/terraform",
+		ProjectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+	}
+	multiEnvStepRunner := runtime.MultiEnvStepRunner{
+		RunStepRunner: &runStepRunner,
+	}
+	ctx := command.ProjectContext{
+		Log:              logging.NewNoopLogger(t),
+		Workspace:        "default",
+		RepoRelDir:       ".",
+		TerraformVersion: tfVersion,
+		EnvPassthrough:   []string{},
+	}
+	t.Setenv("PATH", "/usr/bin")
+	t.Setenv("TF_CLI_ARGS_plan", "-lock=false")
+	tmpDir := t.TempDir()
+	envs := map[string]string{"GOFLAGS": "-mod=mod"}
+
+	// The first step overwrites.
+	_, err = multiEnvStepRunner.Run(ctx, valid.Step{RunCommand: "echo GOFLAGS=-v"}, tmpDir, envs)
+	Ok(t, err)
+	Equals(t, "-v", envs["GOFLAGS"])
+
+	// Later steps append to the values set by earlier steps and to PATH.
+	step := valid.Step{RunCommand: "echo GOFLAGS=-x,NEW=value", MultiEnvMode: valid.MultiEnvModeAppend, MultiEnvSeparator: " "}
+	_, err = multiEnvStepRunner.Run(ctx, step, tmpDir, envs)
+	Ok(t, err)
+	Equals(t, "-v -x", envs["GOFLAGS"])
+	Equals(t, "value", envs["NEW"])
+	for _, dir := range []string{"/opt/tools/bin", "/opt/more/bin"} {
+		step = valid.Step{RunCommand: "echo PATH=" + dir, MultiEnvMode: valid.MultiEnvModeAppend, MultiEnvSeparator: ":"}
+		_, err = multiEnvStepRunner.Run(ctx, step, tmpDir, envs)
+		Ok(t, err)
+	}
+	Equals(t, "/usr/bin:/bin/terraform:/opt/tools/bin:/opt/more/bin", envs["PATH"])
+
+	// Server env vars are only appended to if they're passed through to
+	// commands.
+	step = valid.Step{RunCommand: "echo TF_CLI_ARGS_plan=-parallelism=5", MultiEnvMode: valid.MultiEnvModeAppend, MultiEnvSeparator: " "}
+	_, err = multiEnvStepRunner.Run(ctx, step, tmpDir, envs)
+	Ok(t, err)
+	Equals(t, "-parallelism=5", envs["TF_CLI_ARGS_plan"])
+	ctx.EnvPassthrough = []string{"TF_*"}
+	envs = map[string]string{}
+	_, err = multiEnvStepRunner.Run(ctx, step, tmpDir, envs)
+	Ok(t, err)
+	Equals(t, "-lock=false -parallelism=5", envs["TF_CLI_ARGS_plan"])
+}
+
+func TestMultiEnvStepRunner_RunDebugEnvDiff(t *testing.T) {
+	RegisterMockTestingT(t)
+	tfVersion, err := version.NewVersion("0.12.0")
+	Ok(t, err)
+	runStepRunner := runtime.RunStepRunner{
+		TerraformExecutor:       mocks.NewMockClient(),
+		DefaultTFVersion:        tfVersion,
+		ProjectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+	}
+	multiEnvStepRunner := runtime.MultiEnvStepRunner{
+		RunStepRunner: &runStepRunner,
+	}
+	ctx := command.ProjectContext{
+		Log:              logging.NewNoopLogger(t),
+		Workspace:        "default",
+		RepoRelDir:       ".",
+		TerraformVersion: tfVersion,
+		EnvPassthrough:   []string{},
+	}
+	envs := map[string]string{"GOFLAGS": "-mod=mod", "REGION": "eu-west-1"}
+
+	out, err := multiEnvStepRunner.Run(ctx, valid.Step{
+		RunCommand:   "echo GOFLAGS=-v,REGION=eu-west-1,DB_PASSWORD=hunter2,NEW=value",
+		DebugEnvDiff: true,
+	}, t.TempDir(), envs)
+	Ok(t, err)
+	Equals(t, `Dynamic environment variables added:
+GOFLAGS
+REGION
+DB_PASSWORD
+NEW
+
+Env changes:
++ DB_PASSWORD=***
+~ GOFLAGS="-v" (was "-mod=mod")
++ NEW="value"
+`, out)
+}
@@ -0,0 +1,146 @@
+package runtime
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/utils"
+)
+
+// pinnedPlansDir is the directory under a clone's .git directory holding the
+// markers that pin its planfiles. Each marker has the planfile's path relative
+// to the clone and holds the username of who pinned the plan. Since git can't
+// track files under .git, a pull request can't commit markers to pin plans.
+var pinnedPlansDir = filepath.Join(".git", "atlantis-pinned")
+
+// PinnedPlan is a pinned planfile read by ReadPinnedPlans so it can be
+// restored with RestorePinnedPlans.
+type PinnedPlan struct {
+	// RelPath is the path of the planfile relative to the dir it was read from.
+	RelPath  string
+	Contents []byte
+	PinnedBy string
+}
+
+// pinMarker returns the path of the marker that pins the plan at planFile,
+// which must be in a clone.
+func pinMarker(planFile string) (string, error) {
+	planFile, err := filepath.Abs(planFile)
+	if err != nil {
+		return "", err
+	}
+	for dir := filepath.Dir(planFile); ; dir = filepath.Dir(dir) {
+		if info, err := os.Stat(filepath.Join(dir, ".git")); err == nil && info.IsDir() {
+			relPath, err := filepath.Rel(dir, planFile)
+			if err != nil {
+				return "", err
+			}
+			return filepath.Join(dir, pinnedPlansDir, relPath), nil
+		}
+		if dir == filepath.Dir(dir) {
+			return "", fmt.Errorf("planfile %s isn't in a git clone", planFile)
+		}
+	}
+}
+
+// PinPlan pins the plan at planFile so it isn't discarded by plan_ttl or when
+// new commits are pushed, until it's applied or unpinned. user is who pinned
+// it.
+func PinPlan(planFile string, user string) error {
+	if _, err := os.Stat(planFile); err != nil {
+		return errors.Wrap(err, "checking planfile")
+	}
+	if ref := PlanRef(planFile); ref != "" {
+		return fmt.Errorf("the plan of ref %q can't be pinned since it can't be applied", ref)
+	}
+	marker, err := pinMarker(planFile)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(marker), 0700); err != nil {
+		return errors.Wrap(err, "creating dir of pin marker")
+	}
+	return os.WriteFile(marker, []byte(user), 0600)
+}
+
+// UnpinPlan unpins the plan at planFile. It's a no-op if the plan isn't
+// pinned.
+func UnpinPlan(planFile string) error {
+	marker, err := pinMarker(planFile)
+	if err != nil {
+		return nil
+	}
+	return utils.RemoveIgnoreNonExistent(marker)
+}
+
+// PlanPinnedBy returns who pinned the plan at planFile and true, or false if
+// there's no plan or it isn't pinned.
+func PlanPinnedBy(planFile string) (string, bool) {
+	if _, err := os.Stat(planFile); err != nil {
+		return "", false
+	}
+	marker, err := pinMarker(planFile)
+	if err != nil {
+		return "", false
+	}
+	user, err := os.ReadFile(marker) // nolint: gosec
+	if err != nil {
+		return "", false
+	}
+	return string(user), true
+}
+
+// ReadPinnedPlans reads the pinned plans of the clone at dir, ex. one that's
+// about to be deleted so it can be cloned again.
+func ReadPinnedPlans(dir string) ([]PinnedPlan, error) {
+	var pinned []PinnedPlan
+	markersDir := filepath.Join(dir, pinnedPlansDir)
+	err := filepath.WalkDir(markersDir, func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && path == markersDir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(markersDir, path)
+		if err != nil {
+			return err
+		}
+		planFile := filepath.Join(dir, relPath)
+		user, ok := PlanPinnedBy(planFile)
+		if !ok {
+			return nil
+		}
+		contents, err := os.ReadFile(planFile) // nolint: gosec
+		if err != nil {
+			return errors.Wrapf(err, "reading pinned plan %s", planFile)
+		}
+		pinned = append(pinned, PinnedPlan{RelPath: relPath, Contents: contents, PinnedBy: user})
+		return nil
+	})
+	return pinned, err
+}
+
+// RestorePinnedPlans writes the pinned plans read by ReadPinnedPlans to dir
+// and pins them again.
+func RestorePinnedPlans(dir string, pinned []PinnedPlan) error {
+	for _, plan := range pinned {
+		planFile := filepath.Join(dir, plan.RelPath)
+		if err := os.MkdirAll(filepath.Dir(planFile), 0700); err != nil {
+			return errors.Wrapf(err, "creating dir of pinned plan %s", plan.RelPath)
+		}
+		if err := os.WriteFile(planFile, plan.Contents, 0600); err != nil {
+			return errors.Wrapf(err, "restoring pinned plan %s", plan.RelPath)
+		}
+		if err := PinPlan(planFile, plan.PinnedBy); err != nil {
+			return errors.Wrapf(err, "pinning restored plan %s", plan.RelPath)
+		}
+	}
+	return nil
+}
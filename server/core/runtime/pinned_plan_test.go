@@ -0,0 +1,65 @@
+package runtime_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestPinPlan(t *testing.T) {
+	dir := t.TempDir()
+	planFile := filepath.Join(dir, "default.tfplan")
+	ErrContains(t, "checking planfile", runtime.PinPlan(planFile, "lkysow"))
+
+	Ok(t, os.WriteFile(planFile, nil, 0600))
+	ErrContains(t, "isn't in a git clone", runtime.PinPlan(planFile, "lkysow"))
+	Ok(t, os.Mkdir(filepath.Join(dir, ".git"), 0700))
+	// Markers in the work tree, ex. committed by a pull request, don't pin plans.
+	Ok(t, os.WriteFile(planFile+".pinned", []byte("lkysow"), 0600))
+	_, pinned := runtime.PlanPinnedBy(planFile)
+	Assert(t, !pinned, "exp plan to not be pinned")
+
+	Ok(t, runtime.PinPlan(planFile, "lkysow"))
+	user, pinned := runtime.PlanPinnedBy(planFile)
+	Assert(t, pinned, "exp plan to be pinned")
+	Equals(t, "lkysow", user)
+
+	Ok(t, runtime.UnpinPlan(planFile))
+	_, pinned = runtime.PlanPinnedBy(planFile)
+	Assert(t, !pinned, "exp plan to be unpinned")
+	Ok(t, runtime.UnpinPlan(planFile))
+}
+
+func TestReadPinnedPlans(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "repo")
+	Ok(t, os.MkdirAll(filepath.Join(dir, "staging"), 0700))
+	Ok(t, os.Mkdir(filepath.Join(dir, ".git"), 0700))
+	pinnedPlan := filepath.Join(dir, "staging", "default.tfplan")
+	Ok(t, os.WriteFile(pinnedPlan, []byte("plan"), 0600))
+	Ok(t, runtime.PinPlan(pinnedPlan, "lkysow"))
+	Ok(t, os.WriteFile(filepath.Join(dir, "default.tfplan"), nil, 0600))
+
+	pinned, err := runtime.ReadPinnedPlans(dir)
+	Ok(t, err)
+	Equals(t, []runtime.PinnedPlan{
+		{RelPath: filepath.Join("staging", "default.tfplan"), Contents: []byte("plan"), PinnedBy: "lkysow"},
+	}, pinned)
+
+	Ok(t, os.RemoveAll(dir))
+	none, err := runtime.ReadPinnedPlans(dir)
+	Ok(t, err)
+	Equals(t, 0, len(none))
+
+	// Pinned plans are restored to a new clone.
+	Ok(t, os.MkdirAll(filepath.Join(dir, ".git"), 0700))
+	Ok(t, runtime.RestorePinnedPlans(dir, pinned))
+	contents, err := os.ReadFile(pinnedPlan)
+	Ok(t, err)
+	Equals(t, "plan", string(contents))
+	user, ok := runtime.PlanPinnedBy(pinnedPlan)
+	Assert(t, ok, "exp restored plan to be pinned")
+	Equals(t, "lkysow", user)
+}
@@ -0,0 +1,339 @@
+package runtime
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/pkg/errors"
+)
+
+// encryptedPlanHeader starts plan files encrypted by PlanEncryptor. It's
+// followed by the reference of the key the file is encrypted with, a newline,
+// the nonce and the ciphertext.
+const encryptedPlanHeader = "atlantis-encrypted-plan-v1\n"
+
+// planKeySize is the size of the AES-256 keys plans are encrypted with.
+const planKeySize = 32
+
+const (
+	localPlanKeyRefPrefix = "local:"
+	kmsPlanKeyRefPrefix   = "kms:"
+)
+
+// PlanKeyProvider provides the AES-256 keys plan files are encrypted with.
+type PlanKeyProvider interface {
+	// NewKey returns the key to encrypt a plan file with and a reference to
+	// it, stored in the file, that Key returns the key for.
+	NewKey() (key []byte, ref string, err error)
+	// Key returns the key ref refers to.
+	Key(ref string) ([]byte, error)
+}
+
+// PlanEncryptor encrypts plan files and their JSON at rest. They're decrypted
+// in place while a command uses them and encrypted again afterwards, so
+// callers must hold the project's working dir lock from Decrypt until
+// Encrypt. A nil PlanEncryptor leaves files as they are.
+type PlanEncryptor struct {
+	Keys PlanKeyProvider
+}
+
+// Encrypt encrypts each of files that exists and isn't encrypted yet.
+func (e *PlanEncryptor) Encrypt(files ...string) error {
+	if e == nil {
+		return nil
+	}
+	for _, file := range files {
+		content, info, err := readPlanFile(file)
+		if err != nil {
+			return err
+		}
+		if info == nil || isEncryptedPlan(content) {
+			continue
+		}
+		key, ref, err := e.Keys.NewKey()
+		if err != nil {
+			return errors.Wrapf(err, "getting key to encrypt %q", file)
+		}
+		aead, err := newPlanCipher(key)
+		if err != nil {
+			return errors.Wrapf(err, "encrypting %q", file)
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return errors.Wrapf(err, "encrypting %q", file)
+		}
+		header := encryptedPlanHeader + ref + "\n"
+		encrypted := append([]byte(header), nonce...)
+		encrypted = aead.Seal(encrypted, nonce, content, []byte(header))
+		if err := replacePlanFile(file, encrypted, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decrypt decrypts each of files that exists and is encrypted. Files that
+// aren't encrypted, ex. plans from before encryption was enabled, are left as
+// they are.
+func (e *PlanEncryptor) Decrypt(files ...string) error {
+	if e == nil {
+		return nil
+	}
+	for _, file := range files {
+		content, info, err := readPlanFile(file)
+		if err != nil {
+			return err
+		}
+		if info == nil || !isEncryptedPlan(content) {
+			continue
+		}
+		decrypted, err := e.decrypt(file, content)
+		if err != nil {
+			return err
+		}
+		if err := replacePlanFile(file, decrypted, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadFile returns the content of file, decrypted if it's encrypted, without
+// decrypting it on disk.
+func (e *PlanEncryptor) ReadFile(file string) ([]byte, error) {
+	content, err := os.ReadFile(file) // nolint: gosec
+	if err != nil || e == nil || !isEncryptedPlan(content) {
+		return content, err
+	}
+	return e.decrypt(file, content)
+}
+
+// decrypt returns the decrypted content of encrypted file.
+func (e *PlanEncryptor) decrypt(file string, content []byte) ([]byte, error) {
+	rest := content[len(encryptedPlanHeader):]
+	refEnd := bytes.IndexByte(rest, '\n')
+	if refEnd < 0 {
+		return nil, fmt.Errorf("decrypting %q: missing key reference", file)
+	}
+	key, err := e.Keys.Key(string(rest[:refEnd]))
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting key to decrypt %q", file)
+	}
+	aead, err := newPlanCipher(key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decrypting %q", file)
+	}
+	sealed := rest[refEnd+1:]
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("decrypting %q: file is truncated", file)
+	}
+	header := content[:len(encryptedPlanHeader)+refEnd+1]
+	decrypted, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], header)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decrypting %q", file)
+	}
+	return decrypted, nil
+}
+
+func isEncryptedPlan(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(encryptedPlanHeader))
+}
+
+func newPlanCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readPlanFile returns the content of file and its info, or a nil info if it
+// doesn't exist.
+func readPlanFile(file string) ([]byte, os.FileInfo, error) {
+	info, err := os.Stat(file)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "reading %q", file)
+	}
+	content, err := os.ReadFile(file) // nolint: gosec
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "reading %q", file)
+	}
+	return content, info, nil
+}
+
+// replacePlanFile atomically replaces file with content. The file keeps its
+// permissions and modification time so checks of a plan's age, ex. plan_ttl,
+// aren't affected by encrypting or decrypting it.
+func replacePlanFile(file string, content []byte, info os.FileInfo) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return errors.Wrapf(err, "writing %q", file)
+	}
+	defer os.Remove(tmp.Name()) // nolint: errcheck
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close() // nolint: errcheck
+		return errors.Wrapf(err, "writing %q", file)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "writing %q", file)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return errors.Wrapf(err, "writing %q", file)
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return errors.Wrapf(err, "writing %q", file)
+	}
+	return os.Chtimes(file, info.ModTime(), info.ModTime())
+}
+
+// LocalPlanKeys are AES-256 keys read from a file, one per line as
+// "<id>:<base64 key>". The first key encrypts plans and every key decrypts
+// them, so keys are rotated by adding a new key as the first line and removing
+// the old one once the plans encrypted with it have been applied or planned
+// again. Blank lines and lines starting with # are ignored.
+type LocalPlanKeys struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+// NewLocalPlanKeys reads the keys in keyFile. It returns an error if the file
+// can't be read or has no keys, or a key isn't valid.
+func NewLocalPlanKeys(keyFile string) (*LocalPlanKeys, error) {
+	content, err := os.ReadFile(keyFile) // nolint: gosec
+	if err != nil {
+		return nil, errors.Wrap(err, "reading plan encryption key file")
+	}
+	l := &LocalPlanKeys{keys: make(map[string][]byte)}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(line, ":")
+		if !ok || id == "" || strings.ContainsAny(id, " \t") {
+			return nil, fmt.Errorf("line %d of plan encryption key file %q: expected <id>:<base64 key>", lineNum, keyFile)
+		}
+		if _, ok := l.keys[id]; ok {
+			return nil, fmt.Errorf("plan encryption key file %q has more than one key with id %q", keyFile, id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("plan encryption key %q in %q isn't valid base64: %s", id, keyFile, err)
+		}
+		if len(key) != planKeySize {
+			return nil, fmt.Errorf("plan encryption key %q in %q is %d bytes, it must be %d bytes for AES-256", id, keyFile, len(key), planKeySize)
+		}
+		if l.currentID == "" {
+			l.currentID = id
+		}
+		l.keys[id] = key
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading plan encryption key file")
+	}
+	if l.currentID == "" {
+		return nil, fmt.Errorf("plan encryption key file %q has no keys", keyFile)
+	}
+	return l, nil
+}
+
+// NewKey implements PlanKeyProvider. It returns the first key in the file.
+func (l *LocalPlanKeys) NewKey() ([]byte, string, error) {
+	return l.keys[l.currentID], localPlanKeyRefPrefix + l.currentID, nil
+}
+
+// Key implements PlanKeyProvider.
+func (l *LocalPlanKeys) Key(ref string) ([]byte, error) {
+	id, ok := strings.CutPrefix(ref, localPlanKeyRefPrefix)
+	if !ok {
+		return nil, fmt.Errorf("plan was encrypted with AWS KMS but plans are encrypted with a key file")
+	}
+	key, ok := l.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("plan was encrypted with key %q which isn't in the plan encryption key file", id)
+	}
+	return key, nil
+}
+
+// KMSPlanKeys encrypts each plan with a new data key generated by an AWS KMS
+// key. The data key is stored in the plan encrypted by KMS, so plans encrypted
+// before the KMS key was rotated, or replaced by another key, can be decrypted
+// as long as the server is allowed to decrypt with the key they were encrypted
+// with.
+type KMSPlanKeys struct {
+	KeyID  string
+	client KMSClient
+}
+
+// KMSClient is the part of the AWS KMS API KMSPlanKeys uses. It's
+// implemented by *kms.Client.
+type KMSClient interface {
+	DescribeKey(ctx context.Context, params *kms.DescribeKeyInput, optFns ...func(*kms.Options)) (*kms.DescribeKeyOutput, error)
+	GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+// NewKMSPlanKeys returns KMSPlanKeys for keyID using the server's AWS
+// credentials, after checking the server can use the key so a missing key or
+// missing permissions are reported at startup.
+func NewKMSPlanKeys(keyID string) (*KMSPlanKeys, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, errors.Wrap(err, "loading AWS config")
+	}
+	return NewKMSPlanKeysWithClient(keyID, kms.NewFromConfig(cfg))
+}
+
+// NewKMSPlanKeysWithClient is like NewKMSPlanKeys but uses client for KMS.
+func NewKMSPlanKeysWithClient(keyID string, client KMSClient) (*KMSPlanKeys, error) {
+	if _, err := client.DescribeKey(context.Background(), &kms.DescribeKeyInput{KeyId: aws.String(keyID)}); err != nil {
+		return nil, errors.Wrapf(err, "checking plan encryption KMS key %q", keyID)
+	}
+	return &KMSPlanKeys{KeyID: keyID, client: client}, nil
+}
+
+// NewKey implements PlanKeyProvider.
+func (k *KMSPlanKeys) NewKey() ([]byte, string, error) {
+	out, err := k.client.GenerateDataKey(context.Background(), &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(k.KeyID),
+		KeySpec: kmstypes.DataKeySpecAes256,
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "generating data key")
+	}
+	return out.Plaintext, kmsPlanKeyRefPrefix + base64.StdEncoding.EncodeToString(out.CiphertextBlob), nil
+}
+
+// Key implements PlanKeyProvider. It decrypts the data key in ref with KMS.
+func (k *KMSPlanKeys) Key(ref string) ([]byte, error) {
+	encoded, ok := strings.CutPrefix(ref, kmsPlanKeyRefPrefix)
+	if !ok {
+		return nil, fmt.Errorf("plan was encrypted with a key file but plans are encrypted with AWS KMS")
+	}
+	blob, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing encrypted data key")
+	}
+	out, err := k.client.Decrypt(context.Background(), &kms.DecryptInput{CiphertextBlob: blob})
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypting data key")
+	}
+	return out.Plaintext, nil
+}
@@ -0,0 +1,213 @@
+package runtime_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func planKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func writePlanKeys(t *testing.T, dir string, lines ...string) string {
+	keyFile := filepath.Join(dir, "plan-keys")
+	Ok(t, os.WriteFile(keyFile, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+	return keyFile
+}
+
+func newPlanEncryptor(t *testing.T, keyFile string) *runtime.PlanEncryptor {
+	keys, err := runtime.NewLocalPlanKeys(keyFile)
+	Ok(t, err)
+	return &runtime.PlanEncryptor{Keys: keys}
+}
+
+func TestPlanEncryptor_EncryptDecrypt(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := writePlanKeys(t, dir, "# current key", "a:"+planKey('a'))
+	planFile := filepath.Join(dir, "default.tfplan")
+	showFile := filepath.Join(dir, "default.json")
+	Ok(t, os.WriteFile(planFile, []byte("plan"), 0600))
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	Ok(t, os.Chtimes(planFile, modTime, modTime))
+
+	encryptor := newPlanEncryptor(t, keyFile)
+	Ok(t, encryptor.Encrypt(planFile, showFile))
+	content, err := os.ReadFile(planFile)
+	Ok(t, err)
+	Assert(t, !strings.HasSuffix(string(content), "plan"), "exp plan to be encrypted, got %q", content)
+	info, err := os.Stat(planFile)
+	Ok(t, err)
+	Equals(t, modTime, info.ModTime())
+	Equals(t, os.FileMode(0600), info.Mode().Perm())
+	_, err = os.Stat(showFile)
+	Assert(t, os.IsNotExist(err), "exp missing file to be skipped")
+
+	// Encrypting again is a no-op.
+	Ok(t, encryptor.Encrypt(planFile))
+	read, err := encryptor.ReadFile(planFile)
+	Ok(t, err)
+	Equals(t, "plan", string(read))
+
+	Ok(t, encryptor.Decrypt(planFile, showFile))
+	content, err = os.ReadFile(planFile)
+	Ok(t, err)
+	Equals(t, "plan", string(content))
+	info, err = os.Stat(planFile)
+	Ok(t, err)
+	Equals(t, modTime, info.ModTime())
+
+	// Plans that aren't encrypted, ex. from before encryption was enabled,
+	// are left as they are.
+	Ok(t, encryptor.Decrypt(planFile))
+	content, err = os.ReadFile(planFile)
+	Ok(t, err)
+	Equals(t, "plan", string(content))
+}
+
+// Test that plans are decrypted by a new encryptor with the same keys, as
+// after the server restarted.
+func TestPlanEncryptor_Restart(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := writePlanKeys(t, dir, "a:"+planKey('a'))
+	planFile := filepath.Join(dir, "default.tfplan")
+	Ok(t, os.WriteFile(planFile, []byte("plan"), 0600))
+	Ok(t, newPlanEncryptor(t, keyFile).Encrypt(planFile))
+
+	Ok(t, newPlanEncryptor(t, keyFile).Decrypt(planFile))
+	content, err := os.ReadFile(planFile)
+	Ok(t, err)
+	Equals(t, "plan", string(content))
+}
+
+func TestPlanEncryptor_KeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	planFile := filepath.Join(dir, "default.tfplan")
+	Ok(t, os.WriteFile(planFile, []byte("plan"), 0600))
+	Ok(t, newPlanEncryptor(t, writePlanKeys(t, dir, "a:"+planKey('a'))).Encrypt(planFile))
+
+	// A new key encrypts plans while the old one still decrypts them.
+	rotated := newPlanEncryptor(t, writePlanKeys(t, dir, "b:"+planKey('b'), "a:"+planKey('a')))
+	Ok(t, rotated.Decrypt(planFile))
+	Ok(t, rotated.Encrypt(planFile))
+
+	// Once re-encrypted the old key isn't needed anymore.
+	Ok(t, newPlanEncryptor(t, writePlanKeys(t, dir, "b:"+planKey('b'))).Decrypt(planFile))
+	content, err := os.ReadFile(planFile)
+	Ok(t, err)
+	Equals(t, "plan", string(content))
+
+	Ok(t, rotated.Encrypt(planFile))
+	err = newPlanEncryptor(t, writePlanKeys(t, dir, "c:"+planKey('c'))).Decrypt(planFile)
+	ErrContains(t, "plan was encrypted with key \"b\" which isn't in the plan encryption key file", err)
+}
+
+func TestPlanEncryptor_Tampered(t *testing.T) {
+	dir := t.TempDir()
+	encryptor := newPlanEncryptor(t, writePlanKeys(t, dir, "a:"+planKey('a')))
+	planFile := filepath.Join(dir, "default.tfplan")
+	Ok(t, os.WriteFile(planFile, []byte("plan"), 0600))
+	Ok(t, encryptor.Encrypt(planFile))
+	content, err := os.ReadFile(planFile)
+	Ok(t, err)
+	content[len(content)-1] ^= 0xff
+	Ok(t, os.WriteFile(planFile, content, 0600))
+
+	err = encryptor.Decrypt(planFile)
+	ErrContains(t, fmt.Sprintf("decrypting %q", planFile), err)
+}
+
+func TestNewLocalPlanKeys_Errors(t *testing.T) {
+	cases := []struct {
+		lines  []string
+		expErr string
+	}{
+		{
+			lines:  []string{"# no keys"},
+			expErr: "has no keys",
+		},
+		{
+			lines:  []string{planKey('a')},
+			expErr: "line 1 of plan encryption key file",
+		},
+		{
+			lines:  []string{"a:not base64!"},
+			expErr: "plan encryption key \"a\" in",
+		},
+		{
+			lines:  []string{"a:" + base64.StdEncoding.EncodeToString([]byte("short"))},
+			expErr: "is 5 bytes, it must be 32 bytes for AES-256",
+		},
+		{
+			lines:  []string{"a:" + planKey('a'), "a:" + planKey('b')},
+			expErr: "has more than one key with id \"a\"",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			_, err := runtime.NewLocalPlanKeys(writePlanKeys(t, t.TempDir(), c.lines...))
+			ErrContains(t, c.expErr, err)
+		})
+	}
+
+	_, err := runtime.NewLocalPlanKeys(filepath.Join(t.TempDir(), "missing"))
+	ErrContains(t, "reading plan encryption key file", err)
+}
+
+// fakeKMS is a KMS key that "encrypts" data keys by prefixing them with its
+// ID.
+type fakeKMS struct {
+	keyID string
+}
+
+func (f *fakeKMS) DescribeKey(_ context.Context, params *kms.DescribeKeyInput, _ ...func(*kms.Options)) (*kms.DescribeKeyOutput, error) {
+	if aws.ToString(params.KeyId) != f.keyID {
+		return nil, fmt.Errorf("key %s not found", aws.ToString(params.KeyId))
+	}
+	return &kms.DescribeKeyOutput{}, nil
+}
+
+func (f *fakeKMS) GenerateDataKey(_ context.Context, params *kms.GenerateDataKeyInput, _ ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	key := []byte(strings.Repeat("k", 32))
+	return &kms.GenerateDataKeyOutput{Plaintext: key, CiphertextBlob: append([]byte(aws.ToString(params.KeyId)+":"), key...)}, nil
+}
+
+func (f *fakeKMS) Decrypt(_ context.Context, params *kms.DecryptInput, _ ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	key, ok := bytes.CutPrefix(params.CiphertextBlob, []byte(f.keyID+":"))
+	if !ok {
+		return nil, fmt.Errorf("AccessDeniedException")
+	}
+	return &kms.DecryptOutput{Plaintext: key}, nil
+}
+
+func TestKMSPlanKeys(t *testing.T) {
+	_, err := runtime.NewKMSPlanKeysWithClient("alias/other", &fakeKMS{keyID: "alias/atlantis"})
+	ErrEquals(t, `checking plan encryption KMS key "alias/other": key alias/other not found`, err)
+
+	keys, err := runtime.NewKMSPlanKeysWithClient("alias/atlantis", &fakeKMS{keyID: "alias/atlantis"})
+	Ok(t, err)
+	e := &runtime.PlanEncryptor{Keys: keys}
+	planFile := filepath.Join(t.TempDir(), "default.tfplan")
+	Ok(t, os.WriteFile(planFile, []byte("plan"), 0600))
+	Ok(t, e.Encrypt(planFile))
+	content, err := e.ReadFile(planFile)
+	Ok(t, err)
+	Equals(t, "plan", string(content))
+
+	// Plans of a key the server can't decrypt with can't be read.
+	other, err := runtime.NewKMSPlanKeysWithClient("alias/new", &fakeKMS{keyID: "alias/new"})
+	Ok(t, err)
+	_, err = (&runtime.PlanEncryptor{Keys: other}).ReadFile(planFile)
+	ErrContains(t, "decrypting data key: AccessDeniedException", err)
+}
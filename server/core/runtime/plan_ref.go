@@ -0,0 +1,31 @@
+package runtime
+
+import (
+	"os"
+
+	"github.com/runatlantis/atlantis/server/utils"
+)
+
+// planRefSuffix is appended to a planfile's name for the file recording the
+// git ref planned with plan --ref, so the plan isn't applied as if it were
+// the pull request's.
+const planRefSuffix = ".ref"
+
+// RecordPlanRef records that the plan at planFile was made from ref instead
+// of the pull request's head. If ref is empty any recorded ref is removed.
+func RecordPlanRef(planFile string, ref string) error {
+	if ref == "" {
+		return utils.RemoveIgnoreNonExistent(planFile + planRefSuffix)
+	}
+	return os.WriteFile(planFile+planRefSuffix, []byte(ref), 0600)
+}
+
+// PlanRef returns the ref the plan at planFile was made from, or "" if it
+// was made from the pull request's head.
+func PlanRef(planFile string) string {
+	ref, err := os.ReadFile(planFile + planRefSuffix) // nolint: gosec
+	if err != nil {
+		return ""
+	}
+	return string(ref)
+}
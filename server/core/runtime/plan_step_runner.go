@@ -30,14 +30,20 @@ type planStepRunner struct {
 	DefaultTFVersion    *version.Version
 	CommitStatusUpdater StatusUpdater
 	AsyncTFExec         AsyncTFExec
+	// TFCRunClient, if set, is used to wait for the runs of saved cloud plans
+	// to finish planning, ex. their policy checks, and link them.
+	TFCRunClient *TFCRunClient
 }
 
-func NewPlanStepRunner(terraformExecutor TerraformExec, defaultTfVersion *version.Version, commitStatusUpdater StatusUpdater, asyncTFExec AsyncTFExec) Runner {
+// NewPlanStepRunner returns the runner of plan steps. tfcRunClient can be nil
+// if Atlantis has no Terraform Cloud/Enterprise token.
+func NewPlanStepRunner(terraformExecutor TerraformExec, defaultTfVersion *version.Version, commitStatusUpdater StatusUpdater, asyncTFExec AsyncTFExec, tfcRunClient *TFCRunClient) Runner {
 	runner := &planStepRunner{
 		TerraformExecutor:   terraformExecutor,
 		DefaultTFVersion:    defaultTfVersion,
 		CommitStatusUpdater: commitStatusUpdater,
 		AsyncTFExec:         asyncTFExec,
+		TFCRunClient:        tfcRunClient,
 	}
 	return NewWorkspaceStepRunnerDelegate(terraformExecutor, defaultTfVersion, runner)
 }
@@ -58,16 +64,52 @@ func (p *planStepRunner) Run(ctx command.ProjectContext, extraArgs []string, pat
 	if err != nil {
 		return output, err
 	}
+	if plan, ok := readSavedCloudPlan(planFile); ok && p.TFCRunClient != nil && p.TFCRunClient.Handles(plan) {
+		return p.awaitCloudPlan(ctx, plan, output, tfVersion)
+	}
 	return p.fmtPlanOutput(output, tfVersion), nil
 }
 
+// awaitCloudPlan waits for the Terraform Cloud/Enterprise run of the saved
+// cloud plan to finish planning, ex. its policy checks, and returns output
+// with a link to the run, which the commit status also links to. The plan
+// fails if the run errored or was cancelled or discarded.
+func (p *planStepRunner) awaitCloudPlan(ctx command.ProjectContext, plan savedCloudPlan, output string, tfVersion *version.Version) (string, error) {
+	var runURL string
+	run, err := p.TFCRunClient.WaitForRun(ctx, plan.RunID, func(run TFCRun) {
+		if runURL == "" && run.URL != "" {
+			runURL = run.URL
+			p.updateStatus(ctx, models.PendingCommitStatus, runURL)
+		}
+	})
+	if err != nil {
+		p.updateStatus(ctx, models.FailedCommitStatus, runURL)
+		return output, errors.Wrap(err, "waiting for Terraform Cloud run")
+	}
+	summary := fmt.Sprintf("Terraform Cloud run %s is %s: %s", run.ID, run.Status, run.URL)
+	if tfcRunFailed[run.Status] {
+		p.updateStatus(ctx, models.FailedCommitStatus, runURL)
+		return output, errors.New(summary)
+	}
+	p.updateStatus(ctx, models.SuccessCommitStatus, runURL)
+	return summary + "\n\n" + p.fmtPlanOutput(output, tfVersion), nil
+}
+
+// updateStatus updates the commit status of the project's plan and logs any
+// error.
+func (p *planStepRunner) updateStatus(ctx command.ProjectContext, status models.CommitStatus, url string) {
+	if err := p.CommitStatusUpdater.UpdateProject(ctx, command.Plan, status, url, nil); err != nil {
+		ctx.Log.Err("unable to update status: %s", err)
+	}
+}
+
 // isRemoteOpsErr returns true if there was an error caused due to this
 // project using TFE remote operations.
 func (p *planStepRunner) isRemoteOpsErr(output string, err error) bool {
 	if err == nil {
 		return false
 	}
-	return strings.Contains(output, remoteOpsErr110) || strings.Contains(output, remoteOpsErr01114) || strings.Contains(output, remoteOpsErr012) || strings.Contains(output, remoteOpsErr100)
+	return remoteOpsErrRegex.MatchString(output)
 }
 
 // remotePlan runs a terraform plan command compatible with TFE remote
@@ -79,7 +121,7 @@ func (p *planStepRunner) remotePlan(ctx command.ProjectContext, extraArgs []stri
 		ctx.EscapedCommentArgs,
 	}
 	args := p.flatten(argList)
-	output, err := p.runRemotePlan(ctx, args, path, tfVersion, envs)
+	output, runURL, err := p.runRemotePlan(ctx, args, path, tfVersion, envs)
 	if err != nil {
 		return output, err
 	}
@@ -101,6 +143,9 @@ func (p *planStepRunner) remotePlan(ctx command.ProjectContext, extraArgs []stri
 		return output, errors.Wrap(err, "unable to create planfile for remote ops")
 	}
 
+	if runURL != "" {
+		return fmt.Sprintf("Terraform Cloud run: %s\n\n%s", runURL, p.fmtPlanOutput(output, tfVersion)), nil
+	}
 	return p.fmtPlanOutput(output, tfVersion), nil
 }
 
@@ -184,7 +229,7 @@ func (p *planStepRunner) fmtPlanOutput(output string, tfVersion *version.Version
 
 // runRemotePlan runs a terraform command that utilizes the remote operations
 // backend. It watches the command output for the run url to be printed, and
-// then updates the commit status with a link to the run url.
+// then updates the commit status with a link to the run url, which it returns.
 // The run url is a link to the Terraform Enterprise UI where the output
 // from the in-progress command can be viewed.
 // cmdArgs is the args to terraform to execute.
@@ -194,14 +239,7 @@ func (p *planStepRunner) runRemotePlan(
 	cmdArgs []string,
 	path string,
 	tfVersion *version.Version,
-	envs map[string]string) (string, error) {
-
-	// updateStatusF will update the commit status and log any error.
-	updateStatusF := func(status models.CommitStatus, url string) {
-		if err := p.CommitStatusUpdater.UpdateProject(ctx, command.Plan, status, url, nil); err != nil {
-			ctx.Log.Err("unable to update status: %s", err)
-		}
-	}
+	envs map[string]string) (string, string, error) {
 
 	// Start the async command execution.
 	ctx.Log.Debug("starting async tf remote operation")
@@ -225,7 +263,7 @@ func (p *planStepRunner) runRemotePlan(
 		} else if nextLineIsRunURL {
 			runURL = strings.TrimSpace(line.Line)
 			ctx.Log.Debug("remote run url found, updating commit status")
-			updateStatusF(models.PendingCommitStatus, runURL)
+			p.updateStatus(ctx, models.PendingCommitStatus, runURL)
 			nextLineIsRunURL = false
 		}
 	}
@@ -233,11 +271,11 @@ func (p *planStepRunner) runRemotePlan(
 	ctx.Log.Debug("async tf remote operation complete")
 	output := strings.Join(lines, "\n")
 	if err != nil {
-		updateStatusF(models.FailedCommitStatus, runURL)
+		p.updateStatus(ctx, models.FailedCommitStatus, runURL)
 	} else {
-		updateStatusF(models.SuccessCommitStatus, runURL)
+		p.updateStatus(ctx, models.SuccessCommitStatus, runURL)
 	}
-	return output, err
+	return output, runURL, err
 }
 
 func StripRefreshingFromPlanOutput(output string, tfVersion *version.Version) string {
@@ -265,43 +303,12 @@ func StripRefreshingFromPlanOutput(output string, tfVersion *version.Version) st
 	return output
 }
 
-// remoteOpsErr01114 is the error terraform plan will return if this project is
-// using TFE remote operations in TF 0.11.15.
-var remoteOpsErr01114 = `Error: Saving a generated plan is currently not supported!
-
-The "remote" backend does not support saving the generated execution
-plan locally at this time.
-
-`
-
-// remoteOpsErr012 is the error terraform plan will return if this project is
-// using TFE remote operations in TF 0.12.{0-4}. Later versions haven't been
-// released yet at this time.
-var remoteOpsErr012 = `Error: Saving a generated plan is currently not supported
-
-The "remote" backend does not support saving the generated execution plan
-locally at this time.
-
-`
-
-// remoteOpsErr100 is the error terraform plan will retrun if this project is
-// using TFE remote operations in TF 1.0.{0,1}.
-var remoteOpsErr100 = `Error: Saving a generated plan is currently not supported
-
-The "remote" backend does not support saving the generated execution plan
-locally at this time.
-`
-
-// remoteOpsErr110 is the error terraform plan will return if this project is
-// using Terraform Cloud remote operations in TF 1.1.0 and above
-// note: the trailing whitespace is intentional
-var remoteOpsErr110 = `╷
-│ Error: Saving a generated plan is currently not supported
-│ 
-│ Terraform Cloud does not support saving the generated execution plan
-│ locally at this time.
-╵
-`
+// remoteOpsErrRegex matches the error terraform plan returns if this project
+// is using remote operations, either through the "remote" backend or a cloud
+// block. The wording and formatting changed across versions, ex. in 1.1.0 it
+// was boxed and the cloud block's error names Terraform Cloud, renamed to HCP
+// Terraform in 1.10.0, so only the parts that stayed the same are matched.
+var remoteOpsErrRegex = regexp.MustCompile(`Error: Saving a generated plan is currently not supported!?\s*(?:│\s*)*(?:The "remote" backend|Terraform Cloud|HCP Terraform) does not support saving`)
 
 // remoteOpsHeader is the header we add to the planfile if this plan was
 // generated using TFE remote operations.
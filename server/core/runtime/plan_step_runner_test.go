@@ -39,7 +39,7 @@ func TestRun_AddsEnvVarFile(t *testing.T) {
 	// Using version >= 0.10 here so we don't expect any env commands.
 	tfVersion, _ := version.NewVersion("0.10.0")
 	logger := logging.NewNoopLogger(t)
-	s := runtime.NewPlanStepRunner(terraform, tfVersion, commitStatusUpdater, asyncTfExec)
+	s := runtime.NewPlanStepRunner(terraform, tfVersion, commitStatusUpdater, asyncTfExec, nil)
 
 	expPlanArgs := []string{"plan",
 		"-input=false",
@@ -98,7 +98,7 @@ func TestRun_UsesDiffPathForProject(t *testing.T) {
 	asyncTfExec := runtimemocks.NewMockAsyncTFExec()
 	tfVersion, _ := version.NewVersion("0.10.0")
 	logger := logging.NewNoopLogger(t)
-	s := runtime.NewPlanStepRunner(terraform, tfVersion, commitStatusUpdater, asyncTfExec)
+	s := runtime.NewPlanStepRunner(terraform, tfVersion, commitStatusUpdater, asyncTfExec, nil)
 	ctx := command.ProjectContext{
 		Log:                logger,
 		Workspace:          "default",
@@ -177,7 +177,7 @@ Terraform will perform the following actions:
 	commitStatusUpdater := runtimemocks.NewMockStatusUpdater()
 	asyncTfExec := runtimemocks.NewMockAsyncTFExec()
 	tfVersion, _ := version.NewVersion("0.10.0")
-	s := runtime.NewPlanStepRunner(terraform, tfVersion, commitStatusUpdater, asyncTfExec)
+	s := runtime.NewPlanStepRunner(terraform, tfVersion, commitStatusUpdater, asyncTfExec, nil)
 	When(terraform.RunCommandWithVersion(
 		Any[command.ProjectContext](),
 		Any[string](),
@@ -227,7 +227,7 @@ func TestRun_OutputOnErr(t *testing.T) {
 	commitStatusUpdater := runtimemocks.NewMockStatusUpdater()
 	asyncTfExec := runtimemocks.NewMockAsyncTFExec()
 	tfVersion, _ := version.NewVersion("0.10.0")
-	s := runtime.NewPlanStepRunner(terraform, tfVersion, commitStatusUpdater, asyncTfExec)
+	s := runtime.NewPlanStepRunner(terraform, tfVersion, commitStatusUpdater, asyncTfExec, nil)
 	expOutput := "expected output"
 	expErrMsg := "error!"
 	When(terraform.RunCommandWithVersion(
@@ -299,7 +299,7 @@ func TestRun_NoOptionalVarsIn012(t *testing.T) {
 				Any[string]())).ThenReturn("output", nil)
 
 			tfVersion, _ := version.NewVersion(c.tfVersion)
-			s := runtime.NewPlanStepRunner(terraform, tfVersion, commitStatusUpdater, asyncTfExec)
+			s := runtime.NewPlanStepRunner(terraform, tfVersion, commitStatusUpdater, asyncTfExec, nil)
 			ctx := command.ProjectContext{
 				Workspace:          "default",
 				RepoRelDir:         ".",
@@ -361,6 +361,28 @@ locally at this time.
 │ Terraform Cloud does not support saving the generated execution plan
 │ locally at this time.
 ╵
+`,
+		},
+		{
+			name:      "1.1.0 remote backend error",
+			tfVersion: "1.1.0",
+			remoteOpsErr: `╷
+│ Error: Saving a generated plan is currently not supported
+│ 
+│ The "remote" backend does not support saving the generated execution plan
+│ locally at this time.
+╵
+`,
+		},
+		{
+			name:      "1.10.0 error",
+			tfVersion: "1.10.0",
+			remoteOpsErr: `╷
+│ Error: Saving a generated plan is currently not supported
+│ 
+│ HCP Terraform does not support saving the generated execution plan
+│ locally at this time.
+╵
 `,
 		},
 	}
@@ -389,7 +411,7 @@ locally at this time.
 			commitStatusUpdater := runtimemocks.NewMockStatusUpdater()
 			tfVersion, _ := version.NewVersion(c.tfVersion)
 			asyncTf := &remotePlanMock{}
-			s := runtime.NewPlanStepRunner(terraform, tfVersion, commitStatusUpdater, asyncTf)
+			s := runtime.NewPlanStepRunner(terraform, tfVersion, commitStatusUpdater, asyncTf, nil)
 			absProjectPath := t.TempDir()
 
 			// First, terraform workspace gets run.
@@ -443,6 +465,7 @@ locally at this time.
 
 			output, err := s.Run(ctx, []string{"extra", "args"}, absProjectPath, map[string]string(nil))
 			Ok(t, err)
+			Assert(t, strings.HasPrefix(output, "Terraform Cloud run: https://app.terraform.io/app/lkysow-enterprises/atlantis-tfe-test/runs/run-is4oVvJfrkud1KvE\n\n"), "expect run link, got %q", output)
 			Assert(t, strings.Contains(output, `
 An execution plan has been generated and is shown below.
 Resource actions are indicated with the following symbols:
@@ -471,6 +494,61 @@ Plan: 0 to add, 0 to change, 1 to destroy.`), "expect plan success")
 	}
 }
 
+// Test that plans saved as cloud plans wait for their run to finish planning
+// and link it.
+func TestRun_SavedCloudPlan(t *testing.T) {
+	cases := []struct {
+		description string
+		statuses    []string
+		expOut      string
+		expErr      string
+		expStatus   models.CommitStatus
+	}{
+		{
+			description: "planned",
+			statuses:    []string{"policy_checking", "policy_checked"},
+			expOut:      "Terraform Cloud run run-1 is policy_checked: $URL\n\nPlan: 1 to add, 0 to change, 0 to destroy.",
+			expStatus:   models.SuccessCommitStatus,
+		},
+		{
+			description: "errored",
+			statuses:    []string{"policy_checking", "errored"},
+			expErr:      "Terraform Cloud run run-1 is errored: $URL",
+			expStatus:   models.FailedCommitStatus,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			tfc := newFakeTFC(t, c.statuses, nil)
+			runURL := tfc.server.URL + "/app/org/workspaces/ws/runs/run-1"
+			terraform := mocks.NewMockClient()
+			commitStatusUpdater := runtimemocks.NewMockStatusUpdater()
+			tfVersion, _ := version.NewVersion("1.6.0")
+			s := runtime.NewPlanStepRunner(terraform, tfVersion, commitStatusUpdater, runtimemocks.NewMockAsyncTFExec(), tfc.client("token"))
+			path := t.TempDir()
+			writeSavedPlan(t, tfc, filepath.Join(path, "default.tfplan"))
+			When(terraform.RunCommandWithVersion(Any[command.ProjectContext](), Any[string](), Any[[]string](), Any[map[string]string](), Any[*version.Version](), Any[string]())).
+				ThenReturn("Plan: 1 to add, 0 to change, 0 to destroy.", nil)
+			ctx := command.ProjectContext{
+				Log:        logging.NewNoopLogger(t),
+				Workspace:  "default",
+				RepoRelDir: ".",
+			}
+
+			out, err := s.Run(ctx, nil, path, map[string]string(nil))
+			if c.expErr != "" {
+				ErrEquals(t, strings.Replace(c.expErr, "$URL", runURL, 1), err)
+			} else {
+				Ok(t, err)
+				Equals(t, strings.Replace(c.expOut, "$URL", runURL, 1), out)
+			}
+			commitStatusUpdater.VerifyWasCalledOnce().UpdateProject(ctx, command.Plan, models.PendingCommitStatus, runURL, nil)
+			commitStatusUpdater.VerifyWasCalledOnce().UpdateProject(ctx, command.Plan, c.expStatus, runURL, nil)
+		})
+	}
+}
+
 // Test striping output method
 func TestStripRefreshingFromPlanOutput(t *testing.T) {
 	tfVersion0135, _ := version.NewVersion("0.13.5")
@@ -0,0 +1,50 @@
+package runtime
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/utils"
+)
+
+// planTTLSuffix is appended to a planfile's name for the file recording the
+// project's plan_ttl when it was planned, so expired plans can be found
+// without the project's config.
+const planTTLSuffix = ".ttl"
+
+// RecordPlanTTL records that the plan at planFile can be applied for ttl
+// after it was generated. If ttl is 0 the plan doesn't expire.
+func RecordPlanTTL(planFile string, ttl time.Duration) error {
+	if ttl == 0 {
+		return utils.RemoveIgnoreNonExistent(planFile + planTTLSuffix)
+	}
+	return os.WriteFile(planFile+planTTLSuffix, []byte(ttl.String()), 0600)
+}
+
+// RemovePlanTTL removes the plan_ttl recorded for the plan at planFile.
+func RemovePlanTTL(planFile string) error {
+	return utils.RemoveIgnoreNonExistent(planFile + planTTLSuffix)
+}
+
+// PlanExpired returns true if the plan at planFile is older than the
+// plan_ttl recorded for it. Pinned plans and plans without a recorded
+// plan_ttl never expire.
+func PlanExpired(planFile string) bool {
+	info, err := os.Stat(planFile)
+	if err != nil {
+		return false
+	}
+	contents, err := os.ReadFile(planFile + planTTLSuffix)
+	if err != nil {
+		return false
+	}
+	ttl, err := time.ParseDuration(strings.TrimSpace(string(contents)))
+	if err != nil || ttl <= 0 {
+		return false
+	}
+	if _, pinned := PlanPinnedBy(planFile); pinned {
+		return false
+	}
+	return time.Since(info.ModTime()) > ttl
+}
@@ -0,0 +1,36 @@
+package runtime_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestPlanExpired(t *testing.T) {
+	dir := t.TempDir()
+	Ok(t, os.Mkdir(filepath.Join(dir, ".git"), 0700))
+	planFile := filepath.Join(dir, "default.tfplan")
+	Assert(t, !runtime.PlanExpired(planFile), "exp missing plan to not be expired")
+
+	Ok(t, os.WriteFile(planFile, nil, 0600))
+	old := time.Now().Add(-2 * time.Hour)
+	Ok(t, os.Chtimes(planFile, old, old))
+	Assert(t, !runtime.PlanExpired(planFile), "exp plan without a ttl to not be expired")
+
+	Ok(t, runtime.RecordPlanTTL(planFile, 3*time.Hour))
+	Assert(t, !runtime.PlanExpired(planFile), "exp plan younger than its ttl to not be expired")
+
+	Ok(t, runtime.RecordPlanTTL(planFile, time.Hour))
+	Assert(t, runtime.PlanExpired(planFile), "exp plan older than its ttl to be expired")
+
+	Ok(t, runtime.PinPlan(planFile, "lkysow"))
+	Assert(t, !runtime.PlanExpired(planFile), "exp pinned plan to not be expired")
+	Ok(t, runtime.UnpinPlan(planFile))
+
+	Ok(t, runtime.RecordPlanTTL(planFile, 0))
+	Assert(t, !runtime.PlanExpired(planFile), "exp plan whose ttl was removed to not be expired")
+}
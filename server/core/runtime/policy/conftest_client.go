@@ -209,11 +209,20 @@ func (c *ConfTestExecutorWorkflow) Run(ctx command.ProjectContext, executablePat
 			passed = false
 		}
 
+		var exemptions []string
+		if !passed {
+			var exemptionsErr error
+			if exemptions, exemptionsErr = renderExemptions(policySet, cmdOutput); exemptionsErr != nil {
+				ctx.Log.Warn("not suggesting exemptions for policy set %s: %s", policySet.Name, exemptionsErr)
+			}
+		}
+
 		policySetResults = append(policySetResults, models.PolicySetResult{
 			PolicySetName: policySet.Name,
 			PolicyOutput:  cmdOutput,
 			Passed:        passed,
 			ReqApprovals:  policySet.ApproveCount,
+			Exemptions:    exemptions,
 		})
 	}
 
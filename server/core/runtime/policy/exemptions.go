@@ -0,0 +1,81 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+)
+
+// conftestFailureRegex matches a failure in conftest's default output, ex.
+// "FAIL - plan.json - main - aws_s3_bucket.logs must be encrypted".
+var conftestFailureRegex = regexp.MustCompile(`(?m)^FAIL - .+? - (\S+) - (.+)$`)
+
+// resourceAddressRegex matches the address of a Terraform resource, ex.
+// aws_s3_bucket.logs or module.vpc.aws_subnet.private[0].
+var resourceAddressRegex = regexp.MustCompile(`(?:module\.[A-Za-z0-9_-]+(?:\[[^\]\s]+\])?\.)*(?:data\.)?[A-Za-z0-9-]+_[A-Za-z0-9_-]*\.[A-Za-z_][A-Za-z0-9_-]*(?:\[[^\]\s]+\])?`)
+
+// conftestResult is a file's result in conftest's JSON output.
+type conftestResult struct {
+	Namespace string `json:"namespace"`
+	Failures  []struct {
+		Msg string `json:"msg"`
+	} `json:"failures"`
+}
+
+// policyFailures returns the failures in output, the output of conftest for
+// policySet in its default or JSON format.
+func policyFailures(policySet string, output string) []valid.PolicyFailure {
+	var failures []valid.PolicyFailure
+	var results []conftestResult
+	if err := json.Unmarshal([]byte(output), &results); err == nil {
+		for _, result := range results {
+			for _, f := range result.Failures {
+				failures = append(failures, newPolicyFailure(policySet, result.Namespace, f.Msg))
+			}
+		}
+		return failures
+	}
+	for _, match := range conftestFailureRegex.FindAllStringSubmatch(output, -1) {
+		failures = append(failures, newPolicyFailure(policySet, match[1], strings.TrimSpace(match[2])))
+	}
+	return failures
+}
+
+func newPolicyFailure(policySet string, namespace string, msg string) valid.PolicyFailure {
+	return valid.PolicyFailure{
+		PolicySet: policySet,
+		Namespace: namespace,
+		Resource:  resourceAddressRegex.FindString(msg),
+		Message:   msg,
+	}
+}
+
+// renderExemptions returns policySet's exemption template rendered for each
+// failure in output, the output of conftest for policySet. Identical snippets
+// are only returned once.
+func renderExemptions(policySet valid.PolicySet, output string) ([]string, error) {
+	if policySet.ExemptionTemplate == "" {
+		return nil, nil
+	}
+	tmpl, err := valid.ParsePolicyExemptionTemplate(policySet.ExemptionTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing exemption template: %s", err)
+	}
+	var exemptions []string
+	seen := make(map[string]bool)
+	for _, failure := range policyFailures(policySet.Name, output) {
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, failure); err != nil {
+			return nil, fmt.Errorf("rendering exemption template: %s", err)
+		}
+		exemption := strings.TrimSpace(sb.String())
+		if exemption != "" && !seen[exemption] {
+			seen[exemption] = true
+			exemptions = append(exemptions, exemption)
+		}
+	}
+	return exemptions, nil
+}
@@ -0,0 +1,60 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestRenderExemptions(t *testing.T) {
+	policySet := valid.PolicySet{
+		Name:              "security",
+		ExemptionTemplate: `- policy_set: {{ .PolicySet }}/{{ .Namespace }}{{ "\n" }}  resource: {{ default "<resource>" .Resource }}`,
+	}
+
+	cases := []struct {
+		description string
+		output      string
+		exp         []string
+	}{
+		{
+			"default output",
+			`FAIL - /tmp/plan.json - main - aws_s3_bucket.logs must be encrypted
+FAIL - /tmp/plan.json - main - aws_s3_bucket.logs must be encrypted
+FAIL - /tmp/plan.json - tags - module.vpc.aws_subnet.private[0] is missing the owner tag
+FAIL - /tmp/plan.json - main - Null Resource creation is prohibited.
+
+4 tests, 0 passed, 0 warnings, 4 failures, 0 exceptions`,
+			[]string{
+				"- policy_set: security/main\n  resource: aws_s3_bucket.logs",
+				"- policy_set: security/tags\n  resource: module.vpc.aws_subnet.private[0]",
+				"- policy_set: security/main\n  resource: <resource>",
+			},
+		},
+		{
+			"json output",
+			`[{"filename": "/tmp/plan.json", "namespace": "main", "successes": 1, "failures": [{"msg": "google_storage_bucket.data must not be public"}]}]`,
+			[]string{
+				"- policy_set: security/main\n  resource: google_storage_bucket.data",
+			},
+		},
+		{
+			"no failures",
+			"2 tests, 2 passed, 0 warnings, 0 failures, 0 exceptions",
+			nil,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			exemptions, err := renderExemptions(policySet, c.output)
+			Ok(t, err)
+			Equals(t, c.exp, exemptions)
+		})
+	}
+
+	// Without a template no exemptions are rendered.
+	exemptions, err := renderExemptions(valid.PolicySet{Name: "security"}, "FAIL - /tmp/plan.json - main - aws_s3_bucket.logs must be encrypted")
+	Ok(t, err)
+	Equals(t, []string(nil), exemptions)
+}
@@ -0,0 +1,67 @@
+package runtime
+
+import (
+	"sync"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+)
+
+// RateLimiters is the registry of the rate limits of run steps. Limits are
+// keyed by name so every run step with the same name shares them, across
+// projects and pull requests.
+type RateLimiters struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// tokenBucket is the state of a limit. It holds up to the limit's Count
+// tokens and gets Count tokens back every Per. Tokens go negative when
+// they're reserved by commands waiting for them.
+type tokenBucket struct {
+	tokens float64
+	// last is when tokens was last updated.
+	last time.Time
+}
+
+// NewRateLimiters returns an empty registry.
+func NewRateLimiters() *RateLimiters {
+	return &RateLimiters{buckets: make(map[string]*tokenBucket)}
+}
+
+// Wait blocks until a command can run under limit and returns how long it
+// waited. Run steps sharing a name should use the same rate, otherwise each
+// waits according to its own rate for the tokens they share.
+func (r *RateLimiters) Wait(limit valid.RateLimit) time.Duration {
+	wait := r.reserve(limit)
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return wait
+}
+
+// reserve takes a token from the bucket of limit and returns how long to wait
+// until the token is available.
+func (r *RateLimiters) reserve(limit valid.RateLimit) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	bucket, ok := r.buckets[limit.Name]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Count), last: now}
+		r.buckets[limit.Name] = bucket
+	}
+
+	perToken := limit.Per / time.Duration(limit.Count)
+	bucket.tokens += float64(now.Sub(bucket.last)) / float64(perToken)
+	if bucket.tokens > float64(limit.Count) {
+		bucket.tokens = float64(limit.Count)
+	}
+	bucket.last = now
+
+	bucket.tokens--
+	if bucket.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-bucket.tokens * float64(perToken))
+}
@@ -0,0 +1,228 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// ErrStepApprovalShutdown is the error of steps that were waiting for approval
+// when the server shut down. Approvals aren't kept across restarts so the
+// command has to be run again.
+var ErrStepApprovalShutdown = errors.New("the server shut down while the step was waiting for approval, run the command again to request a new approval")
+
+// StepApprovalCommenter comments on pull requests to ask for approvals.
+type StepApprovalCommenter interface {
+	CreateComment(logger logging.SimpleLogging, repo models.Repo, pullNum int, comment string, command string) error
+}
+
+// StepApprovalCommentBuilder builds the comment that approves the steps of a
+// project.
+type StepApprovalCommentBuilder interface {
+	BuildApproveStepComment(repoRelDir string, workspace string, project string) string
+}
+
+// PendingStepApproval is a run step with require_approval set that's waiting
+// to be approved.
+type PendingStepApproval struct {
+	ProjectName string
+	RepoRelDir  string
+	Workspace   string
+	// Command is the step's command.
+	Command string
+	// RequestedBy is the user who ran the command the step is part of. They
+	// can't approve it themselves.
+	RequestedBy string
+}
+
+// StepApprovals is the registry of the run steps waiting for approval. Steps
+// wait in memory: if the server shuts down they fail, and they're never run
+// by a later server without a new approval.
+type StepApprovals struct {
+	commenter      StepApprovalCommenter
+	commentBuilder StepApprovalCommentBuilder
+
+	mu      sync.Mutex
+	closed  bool
+	waiting map[string][]*waitingStep
+}
+
+// waitingStep is a step blocked in Wait.
+type waitingStep struct {
+	PendingStepApproval
+	// decided gets the outcome of the approval once the step is removed from
+	// the registry by Approve or Close.
+	decided chan error
+}
+
+// NewStepApprovals returns a registry that asks for approvals with commenter.
+func NewStepApprovals(commenter StepApprovalCommenter, commentBuilder StepApprovalCommentBuilder) *StepApprovals {
+	return &StepApprovals{
+		commenter:      commenter,
+		commentBuilder: commentBuilder,
+		waiting:        make(map[string][]*waitingStep),
+	}
+}
+
+// Wait comments on the pull request to ask for approval of step and blocks
+// until it's approved, which returns nil. It returns an error if the step
+// isn't approved within its approval timeout, the run is cancelled or
+// reaches its deadline, or the server shuts down.
+func (a *StepApprovals) Wait(ctx command.ProjectContext, step valid.Step) error {
+	timeout := step.ApprovalTimeout
+	if timeout == 0 {
+		timeout = valid.DefaultApprovalTimeout
+	}
+	key := stepApprovalKey(ctx.BaseRepo.FullName, ctx.Pull.Num)
+	w := &waitingStep{
+		PendingStepApproval: PendingStepApproval{
+			ProjectName: ctx.ProjectName,
+			RepoRelDir:  ctx.RepoRelDir,
+			Workspace:   ctx.Workspace,
+			Command:     step.RunCommand,
+			RequestedBy: ctx.User.Username,
+		},
+		decided: make(chan error, 1),
+	}
+
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return ErrStepApprovalShutdown
+	}
+	a.waiting[key] = append(a.waiting[key], w)
+	a.mu.Unlock()
+
+	if err := a.commenter.CreateComment(ctx.Log, ctx.BaseRepo, ctx.Pull.Num, a.requestComment(w.PendingStepApproval, timeout), ""); err != nil {
+		a.remove(key, w)
+		return fmt.Errorf("asking for approval of %q: %s", step.RunCommand, err)
+	}
+	ctx.Log.Info("waiting up to %s for %q to be approved", timeout, step.RunCommand)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var deadline <-chan time.Time
+	if !ctx.Deadline.IsZero() {
+		deadlineTimer := time.NewTimer(time.Until(ctx.Deadline))
+		defer deadlineTimer.Stop()
+		deadline = deadlineTimer.C
+	}
+
+	var err error
+	for err == nil {
+		select {
+		case decision := <-w.decided:
+			return decision
+		case <-timer.C:
+			err = fmt.Errorf("%q wasn't approved within %s", step.RunCommand, timeout)
+		case <-deadline:
+			err = fmt.Errorf("%q wasn't approved before the workflow's timeout", step.RunCommand)
+		case <-ctx.Cancelled:
+			err = fmt.Errorf("the pull request was closed while %q was waiting for approval", step.RunCommand)
+		}
+	}
+	// The step may have been approved while giving up on it, in which case
+	// the approval wins since the approver was told it was approved.
+	if !a.remove(key, w) {
+		return <-w.decided
+	}
+	return err
+}
+
+// Approve approves the steps of the pull request waiting for approval that
+// matches, on behalf of approver. It returns the steps it approved
+// and the steps that matched but that approver can't approve because they
+// ran the command the step is part of.
+func (a *StepApprovals) Approve(repoFullName string, pullNum int, approver string, matches func(PendingStepApproval) bool) (approved []PendingStepApproval, selfApprovals []PendingStepApproval) {
+	key := stepApprovalKey(repoFullName, pullNum)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var kept []*waitingStep
+	for _, w := range a.waiting[key] {
+		switch {
+		case !matches(w.PendingStepApproval):
+			kept = append(kept, w)
+		case strings.EqualFold(w.RequestedBy, approver):
+			selfApprovals = append(selfApprovals, w.PendingStepApproval)
+			kept = append(kept, w)
+		default:
+			approved = append(approved, w.PendingStepApproval)
+			w.decided <- nil
+		}
+	}
+	a.setWaiting(key, kept)
+	return approved, selfApprovals
+}
+
+// Close fails the steps waiting for approval with ErrStepApprovalShutdown, so
+// the server can shut down without waiting for them, as well as the steps
+// that ask for approval afterwards.
+func (a *StepApprovals) Close() {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+	for key, steps := range a.waiting {
+		for _, w := range steps {
+			w.decided <- ErrStepApprovalShutdown
+		}
+		delete(a.waiting, key)
+	}
+}
+
+// remove removes w from the steps waiting under key. It returns false if w
+// was already removed, by Approve or Close.
+func (a *StepApprovals) remove(key string, w *waitingStep) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var kept []*waitingStep
+	found := false
+	for _, other := range a.waiting[key] {
+		if other == w {
+			found = true
+			continue
+		}
+		kept = append(kept, other)
+	}
+	a.setWaiting(key, kept)
+	return found
+}
+
+// setWaiting sets the steps waiting under key. a.mu must be held.
+func (a *StepApprovals) setWaiting(key string, steps []*waitingStep) {
+	if len(steps) == 0 {
+		delete(a.waiting, key)
+		return
+	}
+	a.waiting[key] = steps
+}
+
+// requestComment returns the comment asking for approval of p.
+func (a *StepApprovals) requestComment(p PendingStepApproval, timeout time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**Approval required** to run `%s` for dir: `%s` workspace: `%s`", p.Command, p.RepoRelDir, p.Workspace)
+	if p.ProjectName != "" {
+		fmt.Fprintf(&b, " project: `%s`", p.ProjectName)
+	}
+	b.WriteString(".\n\n")
+	fmt.Fprintf(&b, "* :heavy_check_mark: To approve it, comment:\n  * `%s`\n", a.commentBuilder.BuildApproveStepComment(p.RepoRelDir, p.Workspace, p.ProjectName))
+	approvers := "Anyone allowed to run `approve-step`"
+	if p.RequestedBy != "" {
+		approvers += fmt.Sprintf(" except @%s, who ran this command,", p.RequestedBy)
+	}
+	fmt.Fprintf(&b, "\n%s can approve it. It fails if it isn't approved within %s or if Atlantis restarts before it's approved.", approvers, timeout)
+	return b.String()
+}
+
+func stepApprovalKey(repoFullName string, pullNum int) string {
+	return fmt.Sprintf("%s#%d", repoFullName, pullNum)
+}
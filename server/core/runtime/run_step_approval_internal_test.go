@@ -0,0 +1,150 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// approvalCommenter sends the comments it creates to comments.
+type approvalCommenter struct {
+	comments chan string
+}
+
+func (c approvalCommenter) CreateComment(_ logging.SimpleLogging, _ models.Repo, _ int, comment string, _ string) error {
+	c.comments <- comment
+	return nil
+}
+
+type approvalCommentBuilder struct{}
+
+func (approvalCommentBuilder) BuildApproveStepComment(repoRelDir string, workspace string, project string) string {
+	return fmt.Sprintf("atlantis approve-step -d %s -w %s", repoRelDir, workspace)
+}
+
+func newTestStepApprovals() (*StepApprovals, chan string) {
+	comments := make(chan string, 10)
+	a := NewStepApprovals(approvalCommenter{comments: comments}, approvalCommentBuilder{})
+	return a, comments
+}
+
+func approvalContext(t *testing.T) command.ProjectContext {
+	return command.ProjectContext{
+		Log:        logging.NewNoopLogger(t),
+		BaseRepo:   models.Repo{FullName: "owner/repo"},
+		Pull:       models.PullRequest{Num: 1},
+		User:       models.User{Username: "alice"},
+		RepoRelDir: "dir",
+		Workspace:  "default",
+	}
+}
+
+// waitAsync starts waiting for approval of step and returns the channel the
+// result of Wait is sent to, once the approval was requested.
+func waitAsync(t *testing.T, a *StepApprovals, comments chan string, ctx command.ProjectContext, step valid.Step) (chan error, string) {
+	errCh := make(chan error, 1)
+	go func() { errCh <- a.Wait(ctx, step) }()
+	select {
+	case comment := <-comments:
+		return errCh, comment
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the approval to be requested")
+		return nil, ""
+	}
+}
+
+func waitResult(t *testing.T, errCh chan error) error {
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for Wait to return")
+		return nil
+	}
+}
+
+func TestStepApprovals_Approve(t *testing.T) {
+	a, comments := newTestStepApprovals()
+	step := valid.Step{StepName: "run", RunCommand: "./wipe.sh", RequireApproval: true}
+	errCh, comment := waitAsync(t, a, comments, approvalContext(t), step)
+	Equals(t, "**Approval required** to run `./wipe.sh` for dir: `dir` workspace: `default`.\n\n"+
+		"* :heavy_check_mark: To approve it, comment:\n  * `atlantis approve-step -d dir -w default`\n\n"+
+		"Anyone allowed to run `approve-step` except @alice, who ran this command, can approve it. It fails if it isn't approved within 1h0m0s or if Atlantis restarts before it's approved.", comment)
+
+	all := func(PendingStepApproval) bool { return true }
+	none := func(PendingStepApproval) bool { return false }
+	pending := PendingStepApproval{RepoRelDir: "dir", Workspace: "default", Command: "./wipe.sh", RequestedBy: "alice"}
+
+	// Steps that don't match aren't approved.
+	approved, selfApprovals := a.Approve("owner/repo", 1, "bob", none)
+	Equals(t, 0, len(approved)+len(selfApprovals))
+	approved, selfApprovals = a.Approve("owner/repo", 2, "bob", all)
+	Equals(t, 0, len(approved)+len(selfApprovals))
+
+	// The user who ran the command can't approve its steps.
+	approved, selfApprovals = a.Approve("owner/repo", 1, "Alice", all)
+	Equals(t, 0, len(approved))
+	Equals(t, []PendingStepApproval{pending}, selfApprovals)
+
+	approved, selfApprovals = a.Approve("owner/repo", 1, "bob", all)
+	Equals(t, []PendingStepApproval{pending}, approved)
+	Equals(t, 0, len(selfApprovals))
+	Ok(t, waitResult(t, errCh))
+
+	// It's no longer waiting.
+	approved, _ = a.Approve("owner/repo", 1, "bob", all)
+	Equals(t, 0, len(approved))
+}
+
+func TestStepApprovals_Timeout(t *testing.T) {
+	a, comments := newTestStepApprovals()
+	step := valid.Step{StepName: "run", RunCommand: "./wipe.sh", RequireApproval: true, ApprovalTimeout: 50 * time.Millisecond}
+	errCh, comment := waitAsync(t, a, comments, approvalContext(t), step)
+	Assert(t, strings.Contains(comment, "within 50ms"), "exp timeout in comment, got %q", comment)
+	ErrEquals(t, `"./wipe.sh" wasn't approved within 50ms`, waitResult(t, errCh))
+
+	approved, _ := a.Approve("owner/repo", 1, "bob", func(PendingStepApproval) bool { return true })
+	Equals(t, 0, len(approved))
+}
+
+func TestStepApprovals_Cancelled(t *testing.T) {
+	a, comments := newTestStepApprovals()
+	cancelled := make(chan struct{})
+	ctx := approvalContext(t)
+	ctx.Cancelled = cancelled
+	step := valid.Step{StepName: "run", RunCommand: "./wipe.sh", RequireApproval: true}
+	errCh, _ := waitAsync(t, a, comments, ctx, step)
+	close(cancelled)
+	ErrEquals(t, `the pull request was closed while "./wipe.sh" was waiting for approval`, waitResult(t, errCh))
+}
+
+func TestStepApprovals_Deadline(t *testing.T) {
+	a, comments := newTestStepApprovals()
+	ctx := approvalContext(t)
+	ctx.Deadline = time.Now().Add(50 * time.Millisecond)
+	step := valid.Step{StepName: "run", RunCommand: "./wipe.sh", RequireApproval: true}
+	errCh, _ := waitAsync(t, a, comments, ctx, step)
+	ErrEquals(t, `"./wipe.sh" wasn't approved before the workflow's timeout`, waitResult(t, errCh))
+}
+
+func TestStepApprovals_Close(t *testing.T) {
+	a, comments := newTestStepApprovals()
+	step := valid.Step{StepName: "run", RunCommand: "./wipe.sh", RequireApproval: true}
+	errCh, _ := waitAsync(t, a, comments, approvalContext(t), step)
+	a.Close()
+	Equals(t, ErrStepApprovalShutdown, waitResult(t, errCh))
+
+	// Steps fail without asking for approval once it's closed.
+	Equals(t, ErrStepApprovalShutdown, a.Wait(approvalContext(t), step))
+	Equals(t, 0, len(comments))
+
+	var nilApprovals *StepApprovals
+	nilApprovals.Close()
+}
@@ -0,0 +1,85 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"gopkg.in/yaml.v3"
+)
+
+// yamlErrLineRegex matches the line number in YAML parse errors, ex.
+// "yaml: line 3: mapping values are not allowed in this context".
+var yamlErrLineRegex = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// assertOutputFormat returns an error if output isn't valid in format. The
+// error includes the line the output is invalid at, if known. Empty output is
+// never valid.
+func assertOutputFormat(output string, format valid.AssertFormatOption) error {
+	if strings.TrimSpace(output) == "" {
+		return fmt.Errorf("output isn't valid %s: output is empty", format)
+	}
+
+	var line int
+	var err error
+	switch format {
+	case valid.AssertFormatJSON:
+		line, err = validateJSON(output)
+	case valid.AssertFormatYAML:
+		line, err = validateYAML(output)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+	if err == nil {
+		return nil
+	}
+	lines := strings.Split(output, "\n")
+	if line < 1 || line > len(lines) {
+		return fmt.Errorf("output isn't valid %s: %s", format, err)
+	}
+	return fmt.Errorf("output isn't valid %s at line %d: %s: %q", format, line, err, lines[line-1])
+}
+
+// validateJSON returns an error if output isn't a single JSON value, and the
+// line the error is at.
+func validateJSON(output string) (int, error) {
+	var v interface{}
+	err := json.Unmarshal([]byte(output), &v)
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		// Offset is the number of bytes read when the error occurred so the
+		// error is in the last byte read.
+		offset := int(syntaxErr.Offset)
+		if offset > 0 {
+			offset--
+		}
+		return strings.Count(output[:offset], "\n") + 1, err
+	}
+	return 0, err
+}
+
+// validateYAML returns an error if any YAML document in output is invalid, and
+// the line the error is at if known.
+func validateYAML(output string) (int, error) {
+	dec := yaml.NewDecoder(strings.NewReader(output))
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			return 0, nil
+		}
+		if err == nil {
+			continue
+		}
+		if match := yamlErrLineRegex.FindStringSubmatch(err.Error()); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			return line, errors.New(match[2])
+		}
+		return 0, errors.New(strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+}
@@ -0,0 +1,348 @@
+package runtime
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events/command"
+)
+
+// stepCacheTmpPrefix is the prefix of the dirs entries are copied to before
+// they're added to the cache.
+const stepCacheTmpPrefix = ".tmp-"
+
+// StepCache stores the paths saved by run steps with cache set so later runs
+// with the same key can restore them instead of downloading them again, ex.
+// node_modules keyed by the checksum of package-lock.json. Entries are dirs
+// named by the hash of their repo, pull request and key. The least recently
+// used entries are evicted once the cache is bigger than its max size.
+type StepCache struct {
+	dir     string
+	maxSize int64
+	// mu is held while entries are restored, added and evicted so an entry
+	// isn't evicted while it's being restored.
+	mu sync.Mutex
+}
+
+// NewStepCache returns a cache storing entries in dir, using at most maxSize
+// bytes.
+func NewStepCache(dir string, maxSize int64) *StepCache {
+	return &StepCache{dir: dir, maxSize: maxSize}
+}
+
+// Restore copies the paths of the entry for key in pull of repo into dir,
+// replacing them. It returns false if there's no entry.
+func (c *StepCache) Restore(repo string, pull int, key string, dir string, paths []string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entryDir := c.entryDir(repo, pull, key)
+	if _, err := os.Stat(entryDir); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	for _, p := range paths {
+		src := filepath.Join(entryDir, p)
+		if _, err := os.Lstat(src); os.IsNotExist(err) {
+			continue
+		}
+		dst := filepath.Join(dir, p)
+		if err := os.RemoveAll(dst); err != nil {
+			return false, err
+		}
+		if err := copyTree(src, dst); err != nil {
+			return false, err
+		}
+	}
+	// The modification time of an entry is when it was last used.
+	now := time.Now()
+	return true, os.Chtimes(entryDir, now, now)
+}
+
+// Save adds the paths in dir to the cache as the entry for key in pull of
+// repo. Paths that don't exist are skipped. If the entry is bigger than the
+// cache it isn't added and an error is returned.
+func (c *StepCache) Save(repo string, pull int, key string, dir string, paths []string) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+	// Copy outside the lock since it can take a while, then add it.
+	tmpDir, err := os.MkdirTemp(c.dir, stepCacheTmpPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir) // nolint: errcheck
+	for _, p := range paths {
+		src := filepath.Join(dir, p)
+		if _, err := os.Lstat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := copyTree(src, filepath.Join(tmpDir, p)); err != nil {
+			return err
+		}
+	}
+	size, err := dirSize(tmpDir)
+	if err != nil {
+		return err
+	}
+	if size > c.maxSize {
+		return fmt.Errorf("not caching %s, it's bigger than the max cache size of %s", formatBytes(size), formatBytes(c.maxSize))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entryDir := c.entryDir(repo, pull, key)
+	if _, err := os.Stat(entryDir); err == nil {
+		// Another run saved the entry first.
+		return nil
+	}
+	if err := os.Rename(tmpDir, entryDir); err != nil {
+		return err
+	}
+	return c.evict(entryDir)
+}
+
+// evict removes the least recently used entries, except keep, until the
+// cache is no bigger than its max size. c.mu must be held.
+func (c *StepCache) evict(keep string) error {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	type entry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var entries []entry
+	var total int64
+	for _, d := range dirEntries {
+		if !d.IsDir() || strings.HasPrefix(d.Name(), stepCacheTmpPrefix) {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(c.dir, d.Name())
+		size, err := dirSize(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{path: path, size: size, modTime: info.ModTime()})
+		total += size
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+	for _, e := range entries {
+		if total <= c.maxSize {
+			break
+		}
+		if e.path == keep {
+			continue
+		}
+		if err := os.RemoveAll(e.path); err != nil {
+			return err
+		}
+		total -= e.size
+	}
+	return nil
+}
+
+// entryDir returns the dir of the entry for key in pull of repo. Keys are
+// scoped to their pull request so an untrusted pull request can't save an
+// entry, ex. a tampered node_modules, that's restored into the runs of
+// another.
+func (c *StepCache) entryDir(repo string, pull int, key string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%s", repo, pull, key)))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// restoreStepCache restores the paths of cache into path. It returns the
+// cache's rendered key and true if there was an entry for it.
+func (r *RunStepRunner) restoreStepCache(ctx command.ProjectContext, cache valid.StepCache, path string) (string, bool, error) {
+	key, err := renderStepCacheKey(ctx, cache, path)
+	if err != nil {
+		return "", false, err
+	}
+	hit, err := r.StepCache.Restore(ctx.BaseRepo.FullName, ctx.Pull.Num, key, path, cache.Paths)
+	if err != nil {
+		return "", false, err
+	}
+	ctx.Log.Debug("cache hit: %t for key %q", hit, key)
+	return key, hit, nil
+}
+
+// stepCacheReport saves the paths of cache if there wasn't an entry for key
+// and returns the line reporting it in the step's output.
+func (r *RunStepRunner) stepCacheReport(ctx command.ProjectContext, cache valid.StepCache, key string, hit bool, path string) string {
+	paths := strings.Join(cache.Paths, ", ")
+	if hit {
+		return fmt.Sprintf("Cache hit for key %q, restored %s.", key, paths)
+	}
+	if err := r.StepCache.Save(ctx.BaseRepo.FullName, ctx.Pull.Num, key, path, cache.Paths); err != nil {
+		ctx.Log.Warn("not saving cache for key %q: %s", key, err)
+		return fmt.Sprintf("Cache miss for key %q, not saved: %s.", key, err)
+	}
+	return fmt.Sprintf("Cache miss for key %q, saved %s.", key, paths)
+}
+
+// stepCacheKeyData is the data the key of a run step's cache is rendered
+// with.
+type stepCacheKeyData struct {
+	ProjectName string
+	RepoRelDir  string
+	Workspace   string
+	dir         string
+}
+
+// Checksum returns the SHA256 checksum of files, relative to the project
+// directory, ex. {{ .Checksum "package-lock.json" }}. Files must be inside
+// the project directory, after resolving symlinks.
+func (d stepCacheKeyData) Checksum(files ...string) (string, error) {
+	if len(files) == 0 {
+		return "", fmt.Errorf("checksum needs at least one file")
+	}
+	h := sha256.New()
+	for _, f := range files {
+		file, err := d.projectFile(f)
+		if err != nil {
+			return "", err
+		}
+		in, err := os.Open(file) // nolint: gosec
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, in)
+		in.Close() // nolint: errcheck
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// projectFile returns the absolute path of file, relative to the project
+// directory, or an error if it isn't inside it.
+func (d stepCacheKeyData) projectFile(file string) (string, error) {
+	if err := valid.CheckProjectPath(file); err != nil {
+		return "", err
+	}
+	absFile, err := filepath.EvalSymlinks(filepath.Join(d.dir, file))
+	if err != nil {
+		return "", err
+	}
+	absDir, err := filepath.EvalSymlinks(d.dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absDir, absFile)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q must be inside the project directory", file)
+	}
+	return absFile, nil
+}
+
+// renderStepCacheKey returns the key of cache for the project in path.
+func renderStepCacheKey(ctx command.ProjectContext, cache valid.StepCache, path string) (string, error) {
+	tmpl, err := template.New("key").Funcs(valid.StepTemplateFuncs()).Option("missingkey=error").Parse(cache.Key)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	data := stepCacheKeyData{
+		ProjectName: ctx.ProjectName,
+		RepoRelDir:  ctx.RepoRelDir,
+		Workspace:   ctx.Workspace,
+		dir:         path,
+	}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(buf.String())
+	if key == "" {
+		return "", fmt.Errorf("key %q rendered to an empty string", cache.Key)
+	}
+	return key, nil
+}
+
+// copyTree copies the file, symlink or directory src to dst.
+func copyTree(src string, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode())
+		}
+		return nil
+	})
+}
+
+// dirSize returns the total size of the files in dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// formatBytes returns n bytes in a human readable form, ex. 1.5MB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGT"[exp])
+}
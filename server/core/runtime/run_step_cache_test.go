@@ -0,0 +1,46 @@
+package runtime_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestStepCache_Evict(t *testing.T) {
+	cache := runtime.NewStepCache(t.TempDir(), 10)
+	save := func(key string, contents string) error {
+		dir := t.TempDir()
+		Ok(t, os.WriteFile(filepath.Join(dir, "file"), []byte(contents), 0600))
+		return cache.Save("owner/repo", 1, key, dir, []string{"file"})
+	}
+	restore := func(key string) bool {
+		hit, err := cache.Restore("owner/repo", 1, key, t.TempDir(), []string{"file"})
+		Ok(t, err)
+		return hit
+	}
+
+	Ok(t, save("a", "aaaa"))
+	// Entries are ordered by when they were last used, which has a
+	// resolution that's too coarse for entries saved right after each other.
+	time.Sleep(10 * time.Millisecond)
+	Ok(t, save("b", "bbbb"))
+	time.Sleep(10 * time.Millisecond)
+	Assert(t, restore("a"), "exp a to be cached")
+	time.Sleep(10 * time.Millisecond)
+
+	// Saving c makes the cache too big so b, the least recently used entry,
+	// is evicted.
+	Ok(t, save("c", "cccc"))
+	Assert(t, restore("a"), "exp a to be cached")
+	Assert(t, !restore("b"), "exp b to be evicted")
+	Assert(t, restore("c"), "exp c to be cached")
+
+	err := save("d", strings.Repeat("d", 11))
+	ErrEquals(t, "not caching 11B, it's bigger than the max cache size of 10B", err)
+	Assert(t, !restore("d"), "exp d to not be cached")
+}
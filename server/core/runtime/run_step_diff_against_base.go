@@ -0,0 +1,96 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	runtimemodels "github.com/runatlantis/atlantis/server/core/runtime/models"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// baseDiffContext is the number of unchanged lines shown around each change
+// in the diff of a step's output against the base branch.
+const baseDiffContext = 3
+
+// BaseCloner clones the base branch of pull requests for run steps with
+// diff_against_base set.
+type BaseCloner interface {
+	// CloneBase clones the base branch of p into a new directory and returns
+	// its path. The caller must delete it.
+	CloneBase(logger logging.SimpleLogging, p models.PullRequest) (string, error)
+}
+
+// diffAgainstBase runs the command of step on a clone of the pull request's
+// base branch and returns a unified diff from its output to output, the
+// output of the command on the pull request. If the command can't be run on
+// the base branch, ex. because cloning it failed or the project doesn't exist
+// there yet, output is returned in full after a note saying why, since the
+// step itself succeeded.
+func (r *RunStepRunner) diffAgainstBase(ctx command.ProjectContext, step valid.Step, envVars []string, input string, nixShell string, output string) string {
+	baseOutput, err := r.runOnBase(ctx, step, envVars, input, nixShell)
+	if err != nil {
+		ctx.Log.Warn("not diffing the output of %q against the base branch: %s", step.RunCommand, err)
+		return fmt.Sprintf("Couldn't diff against the base branch `%s`: %s. Showing the full output.\n\n%s", ctx.Pull.BaseBranch, err, output)
+	}
+	want := normalizeGolden(baseOutput)
+	got := normalizeGolden(output)
+	if want == got {
+		return fmt.Sprintf("No changes in the output compared to the base branch `%s`.\n", ctx.Pull.BaseBranch)
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(want),
+		B:        difflib.SplitLines(got),
+		FromFile: ctx.Pull.BaseBranch,
+		ToFile:   "pull request",
+		Context:  baseDiffContext,
+	})
+	if err != nil {
+		ctx.Log.Warn("not diffing the output of %q against the base branch: %s", step.RunCommand, err)
+		return output
+	}
+	return diff
+}
+
+// runOnBase runs the command of step in the project's directory of a clone of
+// the base branch and returns its output, filtered like the pull request's.
+func (r *RunStepRunner) runOnBase(ctx command.ProjectContext, step valid.Step, envVars []string, input string, nixShell string) (string, error) {
+	if r.BaseCloner == nil {
+		return "", fmt.Errorf("base branches can't be cloned")
+	}
+	baseDir, err := r.BaseCloner.CloneBase(ctx.Log, ctx.Pull)
+	if err != nil {
+		return "", fmt.Errorf("cloning it failed: %w", err)
+	}
+	defer os.RemoveAll(baseDir) // nolint: errcheck
+
+	path := filepath.Join(baseDir, ctx.RepoRelDir)
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("the project's directory %q doesn't exist on it", ctx.RepoRelDir)
+	}
+	// Later values win so the command sees the base clone as its directory
+	// and its plan files, which don't exist unless the command makes them, in
+	// it instead of the pull request's.
+	baseEnvVars := envVars[:len(envVars):len(envVars)]
+	for key, val := range projectPathEnvVars(ctx, path) {
+		baseEnvVars = append(baseEnvVars, fmt.Sprintf("%s=%s", key, val))
+	}
+
+	ctx.Log.Info("running %q on the base branch %q to diff against", step.RunCommand, ctx.Pull.BaseBranch)
+	runner := runtimemodels.NewShellCommandRunner(nixShellCommand(step.RunCommand, nixShell), baseEnvVars, path, false, r.ProjectCmdOutputHandler)
+	runner.SetStdin(input)
+	r.waitRateLimit(ctx, step)
+	output, err := runner.Run(ctx)
+	if err != nil && !slices.Contains(step.AllowedExitCodes, commandExitCode(err)) {
+		return "", fmt.Errorf("`%s` failed on it: %s", step.RunCommand, err)
+	}
+	if step.OutputFilter != "" {
+		output = r.filterOutput(ctx, step.OutputFilter, baseEnvVars, path, output)
+	}
+	return output, nil
+}
@@ -0,0 +1,123 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/utils"
+)
+
+// envDiffIgnored are the env vars shells set themselves, so they'd be in the
+// env diff of every command.
+var envDiffIgnored = map[string]bool{
+	"OLDPWD": true,
+	"PWD":    true,
+	"SHLVL":  true,
+	"_":      true,
+}
+
+// secretEnvNameRegex matches the names of env vars that likely hold secrets.
+// Their values are masked in env diffs.
+var secretEnvNameRegex = regexp.MustCompile(`(?i)(TOKEN|SECRET|PASSWORD|PASSWD|CREDENTIAL|PRIVATE|API_?KEY|ACCESS_?KEY)`)
+
+// envLineRegex matches the start of a variable in the output of env. Lines
+// that don't match continue the value of the previous variable.
+var envLineRegex = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)=`)
+
+// envDiffCommand returns command run so the shell writes its env to file when
+// it exits, even if the command calls exit.
+func envDiffCommand(command string, file string) string {
+	return fmt.Sprintf("trap \"env > %s\" EXIT\n%s", shellQuote(file), command)
+}
+
+// createEnvDiffFile returns the path of an empty file for envDiffCommand to
+// write the env to.
+func createEnvDiffFile() (string, error) {
+	f, err := os.CreateTemp("", "atlantis-env-diff")
+	if err != nil {
+		return "", fmt.Errorf("creating file for debug_env_diff: %s", err)
+	}
+	return f.Name(), f.Close()
+}
+
+// readEnvDiffFile returns the env written to file by envDiffCommand.
+func readEnvDiffFile(file string) (map[string]string, error) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading env for debug_env_diff: %s", err)
+	}
+	if len(contents) == 0 {
+		return nil, errors.New("the shell didn't write its env for debug_env_diff, it may have been killed")
+	}
+	env := make(map[string]string)
+	var last string
+	for _, line := range strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n") {
+		if match := envLineRegex.FindStringSubmatch(line); match != nil {
+			last = match[1]
+			env[last] = line[len(match[0]):]
+		} else if last != "" {
+			env[last] += "\n" + line
+		}
+	}
+	return env, nil
+}
+
+// envMap returns the env vars of envVars, in the KEY=value form of
+// exec.Cmd.Env. Later vars override earlier ones like they do for commands.
+func envMap(envVars []string) map[string]string {
+	env := make(map[string]string)
+	for _, kv := range envVars {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// envDiff returns the env vars added, changed and removed between before and
+// after, one per line sorted by name. Values of vars whose names look like
+// they hold secrets are masked.
+func envDiff(before map[string]string, after map[string]string) string {
+	var names []string
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		if envDiffIgnored[name] {
+			continue
+		}
+		old, hadOld := before[name]
+		value, hasValue := after[name]
+		switch {
+		case !hadOld:
+			fmt.Fprintf(&sb, "+ %s=%s\n", name, envDiffValue(name, value))
+		case !hasValue:
+			fmt.Fprintf(&sb, "- %s\n", name)
+		case old != value:
+			fmt.Fprintf(&sb, "~ %s=%s (was %s)\n", name, envDiffValue(name, value), envDiffValue(name, old))
+		}
+	}
+	if sb.Len() == 0 {
+		return "Env changes: none\n"
+	}
+	return "Env changes:\n" + sb.String()
+}
+
+func envDiffValue(name string, value string) string {
+	if secretEnvNameRegex.MatchString(name) && value != "" {
+		return utils.MaskedValue
+	}
+	return fmt.Sprintf("%q", value)
+}
@@ -0,0 +1,26 @@
+package runtime
+
+import (
+	"path"
+	"strings"
+)
+
+// passthroughEnv returns the variables of environ, in KEY=value form, whose
+// names match any of patterns. If patterns is nil every variable is returned.
+func passthroughEnv(environ []string, patterns []string) []string {
+	if patterns == nil {
+		return environ
+	}
+	var passed []string
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		for _, pattern := range patterns {
+			// Patterns are validated when the config is loaded.
+			if ok, _ := path.Match(pattern, name); ok {
+				passed = append(passed, kv)
+				break
+			}
+		}
+	}
+	return passed
+}
@@ -0,0 +1,143 @@
+package runtime
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events/command"
+)
+
+// RunStepFixturesMode is whether run steps' outputs are recorded or replayed.
+type RunStepFixturesMode string
+
+const (
+	// RunStepFixturesRecord runs run steps and records their outputs.
+	RunStepFixturesRecord RunStepFixturesMode = "record"
+	// RunStepFixturesReplay replays the recorded outputs of run steps
+	// instead of running them.
+	RunStepFixturesReplay RunStepFixturesMode = "replay"
+)
+
+// RunStepFixture is the recorded output of a run step, stored as JSON.
+type RunStepFixture struct {
+	// Repo, Dir, Workspace, ProjectName, Command, Step and Run identify the
+	// step, Step being its index in the steps of the command's stage.
+	Repo        string `json:"repo"`
+	Dir         string `json:"dir"`
+	Workspace   string `json:"workspace"`
+	ProjectName string `json:"project_name,omitempty"`
+	Command     string `json:"command"`
+	Step        int    `json:"step"`
+	Run         string `json:"run"`
+	// Output is the step's output, as commented on the pull request.
+	Output string `json:"output"`
+	// Error is the step's error if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// RunStepFixtures records the outputs of run steps as fixtures in a dir, or
+// replays them without running the steps, so workflows can be tested
+// without running their commands. Env and multienv steps are recorded and
+// replayed as run steps. It isn't meant for production.
+type RunStepFixtures struct {
+	dir  string
+	mode RunStepFixturesMode
+}
+
+// NewRunStepFixtures returns fixtures stored in dir that are recorded or
+// replayed depending on mode.
+func NewRunStepFixtures(dir string, mode RunStepFixturesMode) (*RunStepFixtures, error) {
+	if mode != RunStepFixturesRecord && mode != RunStepFixturesReplay {
+		return nil, fmt.Errorf("%q isn't a run step fixtures mode, it must be %q or %q", mode, RunStepFixturesRecord, RunStepFixturesReplay)
+	}
+	return &RunStepFixtures{dir: dir, mode: mode}, nil
+}
+
+// Replaying returns true if steps should be replayed instead of run.
+func (f *RunStepFixtures) Replaying() bool {
+	return f != nil && f.mode == RunStepFixturesReplay
+}
+
+// CheckReplayable returns an error if f is replaying and any of steps can't
+// be replayed. Only the commands of run, env and multienv steps are recorded
+// so the other steps, ex. init or plan, would run Terraform for real.
+func (f *RunStepFixtures) CheckReplayable(steps []valid.Step) error {
+	if !f.Replaying() {
+		return nil
+	}
+	for i, step := range steps {
+		switch {
+		case step.StepName == "env" && step.SSMPath != "":
+			return fmt.Errorf("the env step with ssm_path (step %d) can't be replayed with --run-step-fixtures=replay, only run, env and multienv steps can", i+1)
+		case step.StepName != "run" && step.StepName != "env" && step.StepName != "multienv":
+			return fmt.Errorf("the %s step (step %d) can't be replayed with --run-step-fixtures=replay, only run, env and multienv steps can", step.StepName, i+1)
+		}
+	}
+	return nil
+}
+
+// Record stores output and err as the fixture of step if f is recording.
+func (f *RunStepFixtures) Record(ctx command.ProjectContext, step valid.Step, output string, err error) error {
+	if f == nil || f.mode != RunStepFixturesRecord {
+		return nil
+	}
+	fixture := newRunStepFixture(ctx, step)
+	fixture.Output = output
+	if err != nil {
+		fixture.Error = err.Error()
+	}
+	content, marshalErr := json.MarshalIndent(fixture, "", "  ")
+	if marshalErr != nil {
+		return marshalErr
+	}
+	if err := os.MkdirAll(f.dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(f.fixtureFile(fixture), append(content, '\n'), 0600)
+}
+
+// Replay returns the output and error recorded for step.
+func (f *RunStepFixtures) Replay(ctx command.ProjectContext, step valid.Step) (string, error) {
+	fixture := newRunStepFixture(ctx, step)
+	content, err := os.ReadFile(f.fixtureFile(fixture))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("no recorded output to replay for run step %d %q of %s in dir %q workspace %q, record it with --run-step-fixtures=record", ctx.StepIndex+1, step.RunCommand, ctx.CommandName, ctx.RepoRelDir, ctx.Workspace)
+	} else if err != nil {
+		return "", err
+	}
+	var recorded RunStepFixture
+	if err := json.Unmarshal(content, &recorded); err != nil {
+		return "", fmt.Errorf("parsing run step fixture: %s", err)
+	}
+	if recorded.Error != "" {
+		return recorded.Output, errors.New(recorded.Error)
+	}
+	return recorded.Output, nil
+}
+
+func newRunStepFixture(ctx command.ProjectContext, step valid.Step) RunStepFixture {
+	return RunStepFixture{
+		Repo:        ctx.BaseRepo.FullName,
+		Dir:         ctx.RepoRelDir,
+		Workspace:   ctx.Workspace,
+		ProjectName: ctx.ProjectName,
+		Command:     ctx.CommandName.String(),
+		Step:        ctx.StepIndex,
+		Run:         step.RunCommand,
+	}
+}
+
+// fixtureFile returns the file of the fixture identified by fixture, named
+// by the hash of its identifying fields.
+func (f *RunStepFixtures) fixtureFile(fixture RunStepFixture) string {
+	key, _ := json.Marshal([]string{fixture.Repo, fixture.Dir, fixture.Workspace, fixture.ProjectName, fixture.Command, strconv.Itoa(fixture.Step), fixture.Run})
+	sum := sha256.Sum256(key)
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:])[:16]+".json")
+}
@@ -0,0 +1,173 @@
+package runtime_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	version "github.com/hashicorp/go-version"
+	. "github.com/petergtz/pegomock/v4"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	"github.com/runatlantis/atlantis/server/core/terraform/mocks"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/models"
+	jobmocks "github.com/runatlantis/atlantis/server/jobs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestRunStepFixtures_RecordReplay(t *testing.T) {
+	RegisterMockTestingT(t)
+	terraform := mocks.NewMockClient()
+	When(terraform.EnsureVersion(Any[logging.SimpleLogging](), Any[*version.Version]())).
+		ThenReturn(nil)
+	defaultVersion, _ := version.NewVersion("1.0")
+	fixturesDir := t.TempDir()
+	newRunner := func(mode runtime.RunStepFixturesMode) runtime.RunStepRunner {
+		fixtures, err := runtime.NewRunStepFixtures(fixturesDir, mode)
+		Ok(t, err)
+		return runtime.RunStepRunner{
+			TerraformExecutor:       terraform,
+			DefaultTFVersion:        defaultVersion,
+			ProjectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+			Fixtures:                fixtures,
+		}
+	}
+	ctx := command.ProjectContext{
+		Log:         logging.NewNoopLogger(t),
+		BaseRepo:    models.Repo{FullName: "owner/repo"},
+		CommandName: command.Plan,
+		RepoRelDir:  "app",
+		Workspace:   "default",
+	}
+	okStep := valid.Step{StepName: "run", RunCommand: "echo recorded > ran && echo recorded", Output: valid.PostProcessRunOutputShow}
+	failStep := valid.Step{StepName: "run", RunCommand: "echo broken && exit 2", Output: valid.PostProcessRunOutputShow}
+
+	recorder := newRunner(runtime.RunStepFixturesRecord)
+	out, err := recorder.Run(ctx, okStep, t.TempDir(), map[string]string{}, false)
+	Ok(t, err)
+	Equals(t, "recorded\n", out)
+	_, recordErr := recorder.Run(ctx, failStep, t.TempDir(), map[string]string{}, false)
+	Assert(t, recordErr != nil, "exp step to fail")
+	files, err := os.ReadDir(fixturesDir)
+	Ok(t, err)
+	Equals(t, 2, len(files))
+
+	// Replaying returns the recorded outputs without running the commands.
+	replayer := newRunner(runtime.RunStepFixturesReplay)
+	dir := t.TempDir()
+	out, err = replayer.Run(ctx, okStep, dir, map[string]string{}, false)
+	Ok(t, err)
+	Equals(t, "recorded\n", out)
+	_, err = os.Stat(filepath.Join(dir, "ran"))
+	Assert(t, os.IsNotExist(err), "exp command not to run")
+	_, err = replayer.Run(ctx, failStep, dir, map[string]string{}, false)
+	ErrEquals(t, recordErr.Error(), err)
+
+	// Steps are recorded per project.
+	otherCtx := ctx
+	otherCtx.RepoRelDir = "other"
+	_, err = replayer.Run(otherCtx, okStep, dir, map[string]string{}, false)
+	ErrEquals(t, `no recorded output to replay for run step 1 "echo recorded > ran && echo recorded" of plan in dir "other" workspace "default", record it with --run-step-fixtures=record`, err)
+}
+
+// Test that a command run by several steps is recorded for each of them.
+func TestRunStepFixtures_RepeatedCommand(t *testing.T) {
+	RegisterMockTestingT(t)
+	terraform := mocks.NewMockClient()
+	When(terraform.EnsureVersion(Any[logging.SimpleLogging](), Any[*version.Version]())).
+		ThenReturn(nil)
+	defaultVersion, _ := version.NewVersion("1.0")
+	fixturesDir := t.TempDir()
+	newRunner := func(mode runtime.RunStepFixturesMode) runtime.RunStepRunner {
+		fixtures, err := runtime.NewRunStepFixtures(fixturesDir, mode)
+		Ok(t, err)
+		return runtime.RunStepRunner{
+			TerraformExecutor:       terraform,
+			DefaultTFVersion:        defaultVersion,
+			ProjectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+			Fixtures:                fixtures,
+		}
+	}
+	ctx := command.ProjectContext{
+		Log:         logging.NewNoopLogger(t),
+		BaseRepo:    models.Repo{FullName: "owner/repo"},
+		CommandName: command.Plan,
+		RepoRelDir:  "app",
+		Workspace:   "default",
+	}
+	// The step counts how many times it ran so its output differs each time.
+	step := valid.Step{StepName: "run", RunCommand: "echo x >> count && wc -l < count", Output: valid.PostProcessRunOutputShow}
+
+	recorder := newRunner(runtime.RunStepFixturesRecord)
+	dir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		ctx.StepIndex = i
+		_, err := recorder.Run(ctx, step, dir, map[string]string{}, false)
+		Ok(t, err)
+	}
+
+	replayer := newRunner(runtime.RunStepFixturesReplay)
+	for i, exp := range []string{"1", "2"} {
+		ctx.StepIndex = i
+		out, err := replayer.Run(ctx, step, t.TempDir(), map[string]string{}, false)
+		Ok(t, err)
+		Equals(t, exp, strings.TrimSpace(out))
+	}
+}
+
+func TestRunStepFixtures_CheckReplayable(t *testing.T) {
+	replayable := []valid.Step{
+		{StepName: "run", RunCommand: "make plan"},
+		{StepName: "env", EnvVarName: "STACK", RunCommand: "echo prod"},
+		{StepName: "env", EnvVarName: "REGION", EnvVarValue: "us-east-1"},
+		{StepName: "multienv", RunCommand: "echo A=1"},
+	}
+	cases := []struct {
+		description string
+		steps       []valid.Step
+		expErr      string
+	}{
+		{
+			description: "run, env and multienv",
+			steps:       replayable,
+		},
+		{
+			description: "built-in step",
+			steps:       append([]valid.Step{{StepName: "init"}}, replayable...),
+			expErr:      "the init step (step 1) can't be replayed with --run-step-fixtures=replay, only run, env and multienv steps can",
+		},
+		{
+			description: "env step with ssm_path",
+			steps:       append(replayable, valid.Step{StepName: "env", SSMPath: "/app/prod"}),
+			expErr:      "the env step with ssm_path (step 5) can't be replayed with --run-step-fixtures=replay, only run, env and multienv steps can",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			replayer, err := runtime.NewRunStepFixtures(t.TempDir(), runtime.RunStepFixturesReplay)
+			Ok(t, err)
+			err = replayer.CheckReplayable(c.steps)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+			} else {
+				Ok(t, err)
+			}
+
+			// Steps run for real while recording.
+			recorder, err := runtime.NewRunStepFixtures(t.TempDir(), runtime.RunStepFixturesRecord)
+			Ok(t, err)
+			Ok(t, recorder.CheckReplayable(c.steps))
+		})
+	}
+
+	var fixtures *runtime.RunStepFixtures
+	Ok(t, fixtures.CheckReplayable([]valid.Step{{StepName: "plan"}}))
+}
+
+func TestNewRunStepFixtures_InvalidMode(t *testing.T) {
+	_, err := runtime.NewRunStepFixtures(t.TempDir(), "rewind")
+	ErrEquals(t, `"rewind" isn't a run step fixtures mode, it must be "record" or "replay"`, err)
+}
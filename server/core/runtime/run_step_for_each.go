@@ -0,0 +1,104 @@
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/core/runtime/models"
+	"github.com/runatlantis/atlantis/server/events/command"
+)
+
+// runForEach runs step's command once for every line output by its for_each
+// command, with at most step.Parallel commands running at once. Each line is
+// quoted for the shell and replaces valid.ForEachPlaceholder in the command.
+// The outputs are joined in the order of the lines and the step fails if the
+// command fails for any of them. Each command is given input as stdin, runs
+// without network access if step.NoNetwork is set, within step.MemoryLimit
+// and step.CPULimit if they're set, in the Nix shell defined by nixShell if
+// it's set and waits for its rate limit if step.RateLimit is set.
+func (r *RunStepRunner) runForEach(ctx command.ProjectContext, step valid.Step, envVars []string, path string, streamOutput bool, input string, nixShell string) (string, error) {
+	items, err := forEachItems(step.ForEach, envVars, path)
+	if err != nil {
+		return "", err
+	}
+	parallel := step.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	ctx.Log.Debug("running %q for %d items with at most %d at once", step.RunCommand, len(items), parallel)
+
+	outputs := make([]string, len(items))
+	errs := make([]error, len(items))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, item string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			cmd := nixShellCommand(strings.ReplaceAll(step.RunCommand, valid.ForEachPlaceholder, shellQuote(item)), nixShell)
+			runner := models.NewShellCommandRunner(cmd, envVars, path, streamOutput, r.ProjectCmdOutputHandler)
+			runner.SetStdin(input)
+			if step.NoNetwork {
+				if errs[i] = runner.DisableNetwork(); errs[i] != nil {
+					return
+				}
+			}
+			if step.MemoryLimit > 0 || step.CPULimit > 0 {
+				if errs[i] = runner.LimitResources(step.MemoryLimit, step.CPULimit); errs[i] != nil {
+					return
+				}
+			}
+			r.waitRateLimit(ctx, step)
+			outputs[i], errs[i] = runner.Run(ctx)
+		}(i, item)
+	}
+	wg.Wait()
+
+	var failed []string
+	var firstErr error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, items[i])
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	output := strings.Join(outputs, "")
+	if firstErr != nil {
+		return output, fmt.Errorf("%s: failed for %d of %d items: %s", firstErr, len(failed), len(items), strings.Join(failed, ", "))
+	}
+	return output, nil
+}
+
+// forEachItems runs the for_each command forEach and returns the non-empty
+// lines of its stdout.
+func forEachItems(forEach string, envVars []string, path string) ([]string, error) {
+	cmd := exec.Command("sh", "-c", forEach) // #nosec
+	cmd.Env = envVars
+	cmd.Dir = path
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: running for_each command %q in %q: %s", err, forEach, path, strings.TrimSpace(stderr.String()))
+	}
+	var items []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			items = append(items, line)
+		}
+	}
+	return items, nil
+}
+
+// shellQuote quotes s so it's passed as a single word to sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
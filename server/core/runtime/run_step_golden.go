@@ -0,0 +1,48 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// goldenDiffContext is the number of unchanged lines shown around each change
+// in a golden file diff.
+const goldenDiffContext = 3
+
+// compareGolden compares output to the contents of golden, a file relative to
+// the project directory path. If they differ it returns a unified diff from
+// the golden file to output and an error. Line endings and trailing newlines
+// are ignored. If the golden file can't be read the diff is output itself so
+// it can be used to create the file.
+func compareGolden(output string, path string, golden string) (string, error) {
+	expected, err := os.ReadFile(filepath.Join(path, golden))
+	if err != nil {
+		return output, fmt.Errorf("reading golden file %q: %w", golden, err)
+	}
+	want := normalizeGolden(string(expected))
+	got := normalizeGolden(output)
+	if want == got {
+		return "", nil
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(want),
+		B:        difflib.SplitLines(got),
+		FromFile: golden,
+		ToFile:   "output",
+		Context:  goldenDiffContext,
+	})
+	if err != nil {
+		return output, fmt.Errorf("diffing output against golden file %q: %w", golden, err)
+	}
+	return diff, fmt.Errorf("output doesn't match golden file %q, update it if the change is expected", golden)
+}
+
+// normalizeGolden converts CRLF line endings to LF and removes trailing
+// newlines.
+func normalizeGolden(s string) string {
+	return strings.TrimRight(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
@@ -0,0 +1,154 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/go-version"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events/command"
+)
+
+// shouldRun returns true if step has no if condition or it's true for the
+// project, and, if step has on_drift set, the project's plan has drift.
+func (r *RunStepRunner) shouldRun(ctx command.ProjectContext, step valid.Step, path string, envs map[string]string, tfVersion *version.Version) (bool, error) {
+	if step.OnDrift {
+		drifted, err := r.countPlanDrift(ctx, path, envs, tfVersion)
+		if err != nil {
+			return false, fmt.Errorf("detecting drift: %s", err)
+		}
+		if drifted == 0 {
+			ctx.Log.Info("skipping %q since the plan has no drift", step.RunCommand)
+			return false, nil
+		}
+	}
+	if step.If == nil {
+		return true, nil
+	}
+	vars := map[string]interface{}{
+		"workspace":    ctx.Workspace,
+		"dir":          ctx.RepoRelDir,
+		"project_name": ctx.ProjectName,
+		"repo":         ctx.BaseRepo.FullName,
+		"base_branch":  ctx.Pull.BaseBranch,
+		"head_branch":  ctx.Pull.HeadBranch,
+		"pull_num":     float64(ctx.Pull.Num),
+		"pull_author":  ctx.Pull.Author,
+		"user":         ctx.User.Username,
+		"command":      ctx.CommandName.String(),
+	}
+	// Counting the changes can mean running terraform show so it's only done
+	// if they're needed.
+	if step.If.Uses("num_changes") {
+		changes, err := r.countPlanChanges(ctx, path, envs, tfVersion)
+		if err != nil {
+			return false, fmt.Errorf("counting changes for condition %q: %s", step.If.Expr, err)
+		}
+		vars["num_changes"] = float64(changes)
+	}
+	run, err := step.If.Eval(vars)
+	if err == nil && !run {
+		ctx.Log.Info("skipping %q since its condition %q is false", step.RunCommand, step.If.Expr)
+	}
+	return run, err
+}
+
+// projectsNotSucceeded returns the projects of projects that haven't
+// succeeded in ctx's command run, ex. because they failed or haven't run yet.
+func projectsNotSucceeded(ctx command.ProjectContext, projects []string) []string {
+	var failed []string
+	for _, project := range projects {
+		if ctx.ProjectOutcomes == nil || !ctx.ProjectOutcomes.Succeeded(project) {
+			failed = append(failed, project)
+		}
+	}
+	return failed
+}
+
+// planChange is a resource change in the JSON of a plan.
+type planChange struct {
+	Change struct {
+		Actions []string `json:"actions"`
+	} `json:"change"`
+}
+
+// changes returns true if c creates, updates or deletes its resource.
+func (c planChange) changes() bool {
+	actions := c.Change.Actions
+	return !(len(actions) == 1 && (actions[0] == "no-op" || actions[0] == "read"))
+}
+
+// planJSON is the JSON of a plan, from terraform show -json.
+type planJSON struct {
+	// ResourceChanges are the changes the plan makes.
+	ResourceChanges []planChange `json:"resource_changes"`
+	// ResourceDrift are the changes made outside of Terraform that the plan's
+	// refresh found.
+	ResourceDrift []planChange `json:"resource_drift"`
+}
+
+// countPlanChanges returns how many resources the project's plan creates,
+// updates or deletes. It's 0 if the project hasn't been planned.
+func (r *RunStepRunner) countPlanChanges(ctx command.ProjectContext, path string, envs map[string]string, tfVersion *version.Version) (int, error) {
+	plan, err := r.readPlanJSON(ctx, path, envs, tfVersion)
+	if err != nil || plan == nil {
+		return 0, err
+	}
+	return countChanges(plan.ResourceChanges), nil
+}
+
+// countPlanDrift returns how many resources were created, updated or deleted
+// outside of Terraform according to the project's plan. It's 0 if the
+// project hasn't been planned.
+func (r *RunStepRunner) countPlanDrift(ctx command.ProjectContext, path string, envs map[string]string, tfVersion *version.Version) (int, error) {
+	plan, err := r.readPlanJSON(ctx, path, envs, tfVersion)
+	if err != nil || plan == nil {
+		return 0, err
+	}
+	return countChanges(plan.ResourceDrift), nil
+}
+
+func countChanges(changes []planChange) int {
+	n := 0
+	for _, c := range changes {
+		if c.changes() {
+			n++
+		}
+	}
+	return n
+}
+
+// readPlanJSON returns the JSON of the project's plan, or nil if the project
+// hasn't been planned. It's read from the project's show file if it's up to
+// date, otherwise it's from terraform show.
+func (r *RunStepRunner) readPlanJSON(ctx command.ProjectContext, path string, envs map[string]string, tfVersion *version.Version) (*planJSON, error) {
+	planFile := filepath.Join(path, GetPlanFilename(ctx.Workspace, ctx.ProjectName))
+	planInfo, err := os.Stat(planFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var out []byte
+	showFile := filepath.Join(path, ctx.GetShowResultFileName())
+	if showInfo, err := os.Stat(showFile); err == nil && !showInfo.ModTime().Before(planInfo.ModTime()) {
+		if out, err = os.ReadFile(showFile); err != nil {
+			return nil, err
+		}
+	} else {
+		show, err := r.TerraformExecutor.RunCommandWithVersion(ctx, path, []string{"show", "-json", filepath.Clean(planFile)}, envs, tfVersion, ctx.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("running terraform show: %s", err)
+		}
+		out = []byte(show)
+	}
+
+	var plan planJSON
+	if err := json.Unmarshal(out, &plan); err != nil {
+		return nil, fmt.Errorf("parsing plan JSON: %s", err)
+	}
+	return &plan, nil
+}
@@ -0,0 +1,26 @@
+package runtime
+
+import (
+	"os"
+	"strings"
+)
+
+// expandStepInput replaces $VAR and ${VAR} in a run step's input with the
+// value of VAR in envVars, the environment the step's command runs with, or
+// with "" if it's not set. $$ is replaced with $ so a literal $ can be
+// written.
+func expandStepInput(input string, envVars []string) string {
+	env := make(map[string]string)
+	// Later values override earlier ones, as they do for the command.
+	for _, kv := range envVars {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return os.Expand(input, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		return env[name]
+	})
+}
@@ -0,0 +1,101 @@
+package runtime
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxJUnitFailedTests is the most failed tests listed by name in a JUnit
+// summary. Any more are only counted.
+const maxJUnitFailedTests = 20
+
+// junitSuite is a <testsuites> or <testsuite> element of a JUnit report.
+// Suites can be nested.
+type junitSuite struct {
+	XMLName xml.Name
+	Suites  []junitSuite `xml:"testsuite"`
+	Cases   []junitCase  `xml:"testcase"`
+}
+
+// junitCase is a <testcase> element of a JUnit report.
+type junitCase struct {
+	Name      string    `xml:"name,attr"`
+	Classname string    `xml:"classname,attr"`
+	Failure   *struct{} `xml:"failure"`
+	Error     *struct{} `xml:"error"`
+	Skipped   *struct{} `xml:"skipped"`
+}
+
+// junitSummary returns a summary of the JUnit report at report, relative to
+// the project directory path: how many tests passed, failed and were skipped
+// and the names of the failed tests. It returns an error if the report is
+// missing, empty or isn't JUnit XML.
+func junitSummary(path string, report string) (string, error) {
+	contents, err := os.ReadFile(filepath.Join(path, report))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("junit report %q not found in %q, the command must write it", report, path)
+		}
+		return "", fmt.Errorf("reading junit report %q: %w", report, err)
+	}
+	if strings.TrimSpace(string(contents)) == "" {
+		return "", fmt.Errorf("junit report %q is empty", report)
+	}
+	var root junitSuite
+	if err := xml.Unmarshal(contents, &root); err != nil {
+		return "", fmt.Errorf("parsing junit report %q: %w", report, err)
+	}
+	if root.XMLName.Local != "testsuites" && root.XMLName.Local != "testsuite" {
+		return "", fmt.Errorf("parsing junit report %q: root element must be <testsuites> or <testsuite>, found <%s>", report, root.XMLName.Local)
+	}
+
+	var total, skipped int
+	var failed []string
+	root.walk(func(c junitCase) {
+		total++
+		switch {
+		case c.Failure != nil || c.Error != nil:
+			failed = append(failed, c.fullName())
+		case c.Skipped != nil:
+			skipped++
+		}
+	})
+	if total == 0 {
+		return "", fmt.Errorf("junit report %q has no tests", report)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Tests: %d passed, %d failed, %d skipped, %d total\n", total-len(failed)-skipped, len(failed), skipped, total)
+	if len(failed) > 0 {
+		b.WriteString("Failed tests:\n")
+		for i, name := range failed {
+			if i == maxJUnitFailedTests {
+				fmt.Fprintf(&b, "- and %d more\n", len(failed)-maxJUnitFailedTests)
+				break
+			}
+			fmt.Fprintf(&b, "- %s\n", name)
+		}
+	}
+	return b.String(), nil
+}
+
+// walk calls fn with every test case in s and its nested suites.
+func (s junitSuite) walk(fn func(junitCase)) {
+	for _, c := range s.Cases {
+		fn(c)
+	}
+	for _, suite := range s.Suites {
+		suite.walk(fn)
+	}
+}
+
+// fullName returns the name of c prefixed with its class name, if it has one.
+func (c junitCase) fullName() string {
+	if c.Classname == "" {
+		return c.Name
+	}
+	return c.Classname + "." + c.Name
+}
@@ -0,0 +1,33 @@
+package runtime
+
+import "strings"
+
+// prefixLines returns output with prefix prepended to each of its lines.
+func prefixLines(output string, prefix string) string {
+	if output == "" {
+		return ""
+	}
+	trailingNewline := strings.HasSuffix(output, "\n")
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = prefix + line
+	}
+	prefixed := strings.Join(lines, "\n")
+	if trailingNewline {
+		prefixed += "\n"
+	}
+	return prefixed
+}
+
+// LinePrefixedSecrets returns secrets and, for secrets spanning multiple
+// lines, how they appear in output prefixed with prefix by line_prefix, so
+// they're still masked.
+func LinePrefixedSecrets(secrets []string, prefix string) []string {
+	all := append([]string(nil), secrets...)
+	for _, secret := range secrets {
+		if strings.Contains(secret, "\n") {
+			all = append(all, strings.ReplaceAll(secret, "\n", "\n"+prefix))
+		}
+	}
+	return all
+}
@@ -0,0 +1,36 @@
+package runtime
+
+import (
+	"time"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/metrics"
+	tally "github.com/uber-go/tally/v4"
+)
+
+// runStepScope is the sub scope run steps emit their metrics under.
+const runStepScope = "run_step"
+
+// stepMetricTag is the tag a run step's metrics are tagged with its metric
+// name under.
+const stepMetricTag = "step"
+
+// emitStepMetrics emits how long a run step took and whether it succeeded to
+// scope, tagged with the step's metric name. scope is expected to already be
+// tagged with the project, see command.ProjectContext.SetProjectScopeTags.
+func emitStepMetrics(scope tally.Scope, step valid.Step, duration time.Duration, err error) {
+	if scope == nil {
+		return
+	}
+	name := step.Metric
+	if name == "" {
+		name = valid.DefaultStepMetric
+	}
+	scope = scope.SubScope(runStepScope).Tagged(map[string]string{stepMetricTag: name})
+	scope.Timer(metrics.ExecutionTimeMetric).Record(duration)
+	if err != nil {
+		scope.Counter(metrics.ExecutionErrorMetric).Inc(1)
+		return
+	}
+	scope.Counter(metrics.ExecutionSuccessMetric).Inc(1)
+}
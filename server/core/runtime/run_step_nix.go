@@ -0,0 +1,42 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// nixShellBin is the Nix command run steps with nix_shell set run in.
+const nixShellBin = "nix-shell"
+
+// nixShellFile returns the absolute path of the nix_shell file of a run step
+// in the project at path, relative to the repo's dir at repoRelDir. It
+// returns an error if the file is outside the repo, doesn't exist or
+// nix-shell isn't in pathEnv.
+func nixShellFile(nixShell string, path string, repoRelDir string, pathEnv string) (string, error) {
+	repoDir := filepath.Clean(strings.TrimSuffix(filepath.Clean(path), filepath.Clean(repoRelDir)))
+	file := filepath.Join(path, nixShell)
+	if rel, err := filepath.Rel(repoDir, file); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("nix_shell %q must be inside the repo", nixShell)
+	}
+	if _, err := os.Stat(file); err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("nix_shell %q not found in %q", nixShell, path)
+		}
+		return "", fmt.Errorf("checking nix_shell %q: %s", nixShell, err)
+	}
+	if lookPathIn(nixShellBin, pathEnv) == "" {
+		return "", fmt.Errorf("can't run in nix_shell %q: %q not found in PATH %q, is Nix installed on the Atlantis server?", nixShell, nixShellBin, pathEnv)
+	}
+	return file, nil
+}
+
+// nixShellCommand returns command run by nix-shell in the shell defined by
+// file. If file is empty command is returned.
+func nixShellCommand(command string, file string) string {
+	if file == "" {
+		return command
+	}
+	return fmt.Sprintf("%s %s --run %s", nixShellBin, shellQuote(file), shellQuote(command))
+}
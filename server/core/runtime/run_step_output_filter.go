@@ -0,0 +1,31 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/core/runtime/models"
+	"github.com/runatlantis/atlantis/server/events/command"
+)
+
+// filterOutput returns output piped through filter, a shell command run with
+// the same environment and in the same directory as the step's command. If
+// filter fails, output is returned unfiltered with a note since the step's
+// success only depends on its command.
+func (r *RunStepRunner) filterOutput(ctx command.ProjectContext, filter string, envVars []string, path string, output string) string {
+	if output == "" {
+		// The filter would wait for input on stdin otherwise.
+		return ""
+	}
+	runner := models.NewShellCommandRunner(filter, envVars, path, false, r.ProjectCmdOutputHandler)
+	runner.SetStdin(output)
+	filtered, err := runner.Run(ctx)
+	if err != nil {
+		ctx.Log.Warn("output_filter failed, not filtering output: %s", err)
+		if output != "" && !strings.HasSuffix(output, "\n") {
+			output += "\n"
+		}
+		return output + fmt.Sprintf("\noutput_filter failed, showing unfiltered output: %s\n%s", err, filtered)
+	}
+	return filtered
+}
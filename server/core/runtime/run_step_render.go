@@ -0,0 +1,93 @@
+package runtime
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+)
+
+// Step outputs are rendered inside the ```diff code block of comments so
+// rendering them differently means closing that block before and reopening
+// it after.
+const (
+	closeOutputBlock  = "```\n\n"
+	reopenOutputBlock = "\n\n```diff"
+)
+
+// renderOutput renders output as render for comments. It returns an error if
+// output can't be rendered that way, ex. it isn't CSV or TSV for a table.
+func renderOutput(output string, render valid.RenderOption) (string, error) {
+	switch render {
+	case valid.RenderTable:
+		table, err := markdownTable(output)
+		if err != nil {
+			return "", err
+		}
+		return closeOutputBlock + table + reopenOutputBlock, nil
+	case valid.RenderCode:
+		return closeOutputBlock + "```\n" + strings.TrimRight(output, "\n") + "\n```" + reopenOutputBlock, nil
+	default:
+		return output, nil
+	}
+}
+
+// renderMetricLabel renders output, a single number optionally followed by a
+// percent sign, ex. a coverage percentage, as a bold line labeled with label
+// outside the comment's code block. It returns an error if output isn't a
+// number.
+func renderMetricLabel(output string, label string) (string, error) {
+	value := strings.TrimSpace(output)
+	number, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return "", fmt.Errorf("output %q isn't a number", value)
+	}
+	return closeOutputBlock + fmt.Sprintf("**%s:** `%s`", label, value) + reopenOutputBlock, nil
+}
+
+// markdownTable returns output, which is CSV or TSV with a header row, as a
+// markdown table. Output is TSV if its first line contains a tab. Every row
+// must have as many fields as the header.
+func markdownTable(output string) (string, error) {
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return "", errors.New("output is empty")
+	}
+	r := csv.NewReader(strings.NewReader(output))
+	format := "CSV"
+	if firstLine, _, _ := strings.Cut(output, "\n"); strings.Contains(firstLine, "\t") {
+		r.Comma = '\t'
+		r.LazyQuotes = true
+		format = "TSV"
+	}
+	rows, err := r.ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("output isn't valid %s: %s", format, err)
+	}
+
+	var b strings.Builder
+	writeRow := func(cells []string) {
+		b.WriteString("|")
+		for _, cell := range cells {
+			b.WriteString(" " + markdownTableCell(cell) + " |")
+		}
+		b.WriteString("\n")
+	}
+	writeRow(rows[0])
+	b.WriteString("|" + strings.Repeat(" --- |", len(rows[0])) + "\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// markdownTableCell escapes cell so it doesn't break a markdown table.
+func markdownTableCell(cell string) string {
+	cell = strings.TrimSpace(cell)
+	cell = strings.ReplaceAll(cell, "|", `\|`)
+	return strings.ReplaceAll(cell, "\n", "<br>")
+}
@@ -0,0 +1,111 @@
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// gitChanges are the files in a repo that aren't committed, from git status,
+// so a run step with require_clean_after can tell which files it changed.
+type gitChanges struct {
+	repoDir string
+	// files are the status and state of each changed file keyed by its path
+	// relative to repoDir.
+	files map[string]gitChange
+}
+
+// gitChange is the state of a file that isn't committed.
+type gitChange struct {
+	// status is the file's two letter status, ex. " M" or "??".
+	status  string
+	size    int64
+	modTime time.Time
+	exists  bool
+}
+
+// readGitChanges returns the files that aren't committed in the repo
+// containing dir. Ignored files aren't included.
+func readGitChanges(dir string) (*gitChanges, error) {
+	repoDir, err := gitOutput(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	repoDir = strings.TrimSpace(repoDir)
+	out, err := gitOutput(repoDir, "status", "--porcelain=v1", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &gitChanges{repoDir: repoDir, files: make(map[string]gitChange)}
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		status, path := entry[:2], entry[3:]
+		// Renames and copies are followed by the path they're from.
+		if status[0] == 'R' || status[0] == 'C' {
+			i++
+		}
+		change := gitChange{status: status}
+		// The file's size and modification time tell if it changed again
+		// after being changed before the step, ex. a lock file updated by
+		// init.
+		if info, err := os.Lstat(filepath.Join(repoDir, path)); err == nil {
+			change.size, change.modTime, change.exists = info.Size(), info.ModTime(), true
+		}
+		changes.files[path] = change
+	}
+	return changes, nil
+}
+
+// changedSince returns the git status lines, ex. " M main.tf", of the files
+// that changed since before, sorted by path.
+func (g *gitChanges) changedSince(before *gitChanges) []string {
+	var paths []string
+	for path, change := range g.files {
+		if prev, ok := before.files[path]; !ok || prev != change {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	lines := make([]string, 0, len(paths))
+	for _, path := range paths {
+		lines = append(lines, fmt.Sprintf("%s %s", g.files[path].status, path))
+	}
+	return lines
+}
+
+// requireClean returns an error listing the files changed in the repo since
+// before, which was read before running command.
+func requireClean(dir string, command string, before *gitChanges) error {
+	after, err := readGitChanges(dir)
+	if err != nil {
+		return fmt.Errorf("checking for uncommitted changes: %s", err)
+	}
+	changed := after.changedSince(before)
+	if len(changed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%q left %d uncommitted change(s), run it and commit the changes:\n%s", command, len(changed), strings.Join(changed, "\n"))
+}
+
+// gitOutput runs git with args in dir and returns its output.
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...) // #nosec
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: running git %s: %s", err, strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,363 @@
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSnapshotCopySize is the size of the largest file that's copied when
+// snapshotting a directory. Larger files, ex. providers in .terraform, are
+// hard linked so snapshotting them doesn't copy their data.
+const maxSnapshotCopySize = 1 << 20
+
+// dirSnapshot is the state of the files in a directory before a run step so
+// they can be restored after it. Only files that changed are restored, which
+// is detected by their mode, size and modification time. Files that are
+// tracked by git and unmodified aren't copied since they can be restored from
+// the git index. Of the other files, those up to maxSnapshotCopySize are
+// copied and larger ones are hard linked, which keeps their data as long as
+// the step replaces or removes them rather than writing to them in place.
+type dirSnapshot struct {
+	dir string
+	// entries is the state of every file, directory and symlink in dir keyed
+	// by their path relative to dir.
+	entries map[string]snapshotEntry
+	// copyDir holds copies of the files that can't be restored from git.
+	copyDir string
+	// linkDir holds the hard links of the files that can't be restored from
+	// git. It's in the git dir of dir's repo, if any, so it's on the same
+	// file system, otherwise it's copyDir.
+	linkDir string
+}
+
+// snapshotEntry is the state of a single file, directory or symlink.
+type snapshotEntry struct {
+	mode    fs.FileMode
+	size    int64
+	modTime time.Time
+	// target is the target of a symlink.
+	target string
+	// fromGit is true if the file is restored from the git index.
+	fromGit bool
+	// linked is true if the file is hard linked in the snapshot instead of
+	// copied.
+	linked bool
+}
+
+// snapshotDir returns a snapshot of the files in dir. The .git directory isn't
+// included. cleanup must be called on the snapshot once it's not needed.
+func snapshotDir(dir string) (*dirSnapshot, error) {
+	copyDir, err := os.MkdirTemp("", "atlantis-restore-dir")
+	if err != nil {
+		return nil, err
+	}
+	snap := &dirSnapshot{
+		dir:     dir,
+		entries: make(map[string]snapshotEntry),
+		copyDir: copyDir,
+		linkDir: copyDir,
+	}
+	if gitDir, err := gitDir(dir); err == nil {
+		if linkDir, err := os.MkdirTemp(gitDir, "atlantis-restore-dir"); err == nil {
+			snap.linkDir = linkDir
+		}
+	}
+	clean := gitCleanFiles(dir)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entry := snapshotEntry{
+			mode:    info.Mode(),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		}
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			if entry.target, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			if clean[filepath.ToSlash(rel)] {
+				entry.fromGit = true
+				break
+			}
+			if info.Size() > maxSnapshotCopySize {
+				// Fall back to copying if the file can't be linked, ex. if
+				// linkDir is on another file system.
+				entry.linked = linkFile(path, filepath.Join(snap.linkDir, rel)) == nil
+			}
+			if !entry.linked {
+				if err := copyFile(path, filepath.Join(copyDir, rel), info.Mode()); err != nil {
+					return err
+				}
+			}
+		}
+		snap.entries[rel] = entry
+		return nil
+	})
+	if err != nil {
+		snap.cleanup()
+		return nil, fmt.Errorf("snapshotting %q: %w", dir, err)
+	}
+	return snap, nil
+}
+
+// restore restores the files in the directory to the snapshot. Files that were
+// created since are removed and files that were changed or removed are
+// restored.
+func (s *dirSnapshot) restore() error {
+	// Remove everything that isn't in the snapshot, or changed type.
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		entry, ok := s.entries[rel]
+		if ok && entry.mode.Type() == d.Type() {
+			return nil
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("restoring %q: %w", s.dir, err)
+	}
+
+	// Sort the paths so directories are created before what's in them.
+	paths := make([]string, 0, len(s.entries))
+	for rel := range s.entries {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	var fromGit []string
+	for _, rel := range paths {
+		entry := s.entries[rel]
+		path := filepath.Join(s.dir, rel)
+		info, err := os.Lstat(path)
+		if err == nil && entry.unchanged(info) {
+			continue
+		}
+		switch {
+		case entry.mode.IsDir():
+			err = os.MkdirAll(path, entry.mode.Perm())
+			if err == nil {
+				err = os.Chmod(path, entry.mode.Perm())
+			}
+		case entry.mode&fs.ModeSymlink != 0:
+			if target, _ := os.Readlink(path); target == entry.target {
+				continue
+			}
+			if err = os.RemoveAll(path); err == nil {
+				err = os.Symlink(entry.target, path)
+			}
+		case entry.fromGit:
+			fromGit = append(fromGit, filepath.ToSlash(rel))
+			continue
+		case entry.linked:
+			err = s.restoreLinked(rel, entry)
+		default:
+			// Remove the file first in case it's read-only.
+			if err = os.RemoveAll(path); err == nil {
+				err = copyFile(filepath.Join(s.copyDir, rel), path, entry.mode)
+			}
+			if err == nil {
+				err = os.Chtimes(path, entry.modTime, entry.modTime)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("restoring %q: %w", path, err)
+		}
+	}
+	if len(fromGit) == 0 {
+		return nil
+	}
+	if err := gitRestoreFiles(s.dir, fromGit); err != nil {
+		return fmt.Errorf("restoring %q: %w", s.dir, err)
+	}
+	for _, rel := range fromGit {
+		entry := s.entries[rel]
+		path := filepath.Join(s.dir, rel)
+		if err := os.Chmod(path, entry.mode.Perm()); err != nil {
+			return fmt.Errorf("restoring %q: %w", path, err)
+		}
+		if err := os.Chtimes(path, entry.modTime, entry.modTime); err != nil {
+			return fmt.Errorf("restoring %q: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// restoreLinked restores the file at rel that's hard linked in the snapshot.
+// If the file is still the linked one but its size or modification time
+// changed, it was written in place and its data is lost.
+func (s *dirSnapshot) restoreLinked(rel string, entry snapshotEntry) error {
+	path := filepath.Join(s.dir, rel)
+	link := filepath.Join(s.linkDir, rel)
+	linkInfo, err := os.Lstat(link)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Lstat(path); err == nil && os.SameFile(info, linkInfo) {
+		if info.Size() != entry.size || !info.ModTime().Equal(entry.modTime) {
+			return fmt.Errorf("it was written to in place but only files up to %d bytes are copied before the step", maxSnapshotCopySize)
+		}
+		return os.Chmod(path, entry.mode.Perm())
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	if err := os.Link(link, path); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, entry.mode.Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(path, entry.modTime, entry.modTime)
+}
+
+// unchanged returns true if info matches the snapshotted state. Directories
+// only need the same mode since what's in them is restored separately.
+func (e snapshotEntry) unchanged(info fs.FileInfo) bool {
+	if info.Mode() != e.mode {
+		return false
+	}
+	if e.mode.IsDir() {
+		return true
+	}
+	return info.Size() == e.size && info.ModTime().Equal(e.modTime)
+}
+
+// cleanup removes the copies and hard links of the snapshotted files.
+func (s *dirSnapshot) cleanup() {
+	os.RemoveAll(s.copyDir) // nolint: errcheck
+	os.RemoveAll(s.linkDir) // nolint: errcheck
+}
+
+// gitCleanFiles returns the files in dir that are tracked by git and match the
+// git index, keyed by their slash separated path relative to dir. It returns
+// an empty map if dir isn't in a git repo.
+func gitCleanFiles(dir string) map[string]bool {
+	clean := make(map[string]bool)
+	tracked, err := gitLsFiles(dir, "--cached")
+	if err != nil {
+		return clean
+	}
+	modified, err := gitLsFiles(dir, "--modified")
+	if err != nil {
+		return clean
+	}
+	for _, path := range tracked {
+		clean[path] = true
+	}
+	for _, path := range modified {
+		delete(clean, path)
+	}
+	return clean
+}
+
+// gitLsFiles returns the files listed by git ls-files with opt, relative to
+// dir.
+func gitLsFiles(dir string, opt string) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "-z", opt) // #nosec
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, path := range strings.Split(string(out), "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// gitDir returns the absolute path of the git dir of the repo dir is in.
+func gitDir(dir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--absolute-git-dir") // #nosec
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitRestoreFiles restores paths, relative to dir, from the git index.
+func gitRestoreFiles(dir string, paths []string) error {
+	cmd := exec.Command("git", "checkout-index", "--force", "-z", "--stdin") // #nosec
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\x00") + "\x00")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: running git checkout-index: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// linkFile hard links the file at src to dst, creating dst's directory if
+// needed.
+func linkFile(src string, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	return os.Link(src, dst)
+}
+
+// copyFile copies the file at src to dst, creating dst's directory if needed.
+func copyFile(src string, dst string, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	in, err := os.Open(src) // nolint: gosec
+	if err != nil {
+		return err
+	}
+	defer in.Close() // nolint: errcheck
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm()) // nolint: gosec
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close() // nolint: errcheck
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(dst, mode.Perm())
+}
@@ -1,18 +1,34 @@
 package runtime
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-version"
 	"github.com/runatlantis/atlantis/server/core/config/valid"
 	"github.com/runatlantis/atlantis/server/core/runtime/models"
 	"github.com/runatlantis/atlantis/server/events/command"
 	"github.com/runatlantis/atlantis/server/jobs"
+	"github.com/runatlantis/atlantis/server/utils"
 )
 
+// shellCommandNotFoundExitCode is the exit code POSIX shells use when they
+// can't find the executable for a command.
+const shellCommandNotFoundExitCode = 127
+
+// commandNotFoundRegex matches the message a shell prints when it can't find
+// an executable, ex. "sh: 1: terrafrom: not found" (dash) or
+// "sh: line 1: terrafrom: command not found" (bash).
+var commandNotFoundRegex = regexp.MustCompile(`(?m)^[^:\n]+: (?:line )?(?:\d+: )?([^:\s]+): (?:command )?not found$`)
+
 // RunStepRunner runs custom commands.
 type RunStepRunner struct {
 	TerraformExecutor TerraformExec
@@ -20,14 +36,64 @@ type RunStepRunner struct {
 	// TerraformBinDir is the directory where Atlantis downloads Terraform binaries.
 	TerraformBinDir         string
 	ProjectCmdOutputHandler jobs.ProjectCommandOutputHandler
+	// PullCommentUpdater is used by steps with stream set to edit a pull
+	// request comment with their output as they run. If nil, output isn't
+	// streamed to the pull request.
+	PullCommentUpdater PullCommentUpdater
+	// StreamCommentInterval is how often streamed output is flushed to the
+	// pull request. Defaults to defaultStreamCommentInterval.
+	StreamCommentInterval time.Duration
+	// RateLimiters are the limits of steps with rate_limit set, shared by
+	// every step run by the server. If nil steps aren't rate limited.
+	RateLimiters *RateLimiters
+	// RunIDEnvVar is the name of the env var commands get the ID of the run
+	// they're part of in. If empty the ID isn't set.
+	RunIDEnvVar string
+	// StepCache stores the paths of steps with cache set between runs. If
+	// nil steps aren't cached.
+	StepCache *StepCache
+	// Fixtures, if set, records the output of each step, or replays the
+	// recorded outputs instead of running the steps, to test workflows.
+	Fixtures *RunStepFixtures
+	// StepApprovals is where steps with require_approval set wait to be
+	// approved. If nil those steps fail since they can't be approved.
+	StepApprovals *StepApprovals
+	// BaseCloner clones the base branch of pull requests for steps with
+	// diff_against_base set. If nil those steps output their full output.
+	BaseCloner BaseCloner
+}
+
+func (r *RunStepRunner) Run(ctx command.ProjectContext, step valid.Step, path string, envs map[string]string, streamOutput bool) (string, error) {
+	if r.Fixtures.Replaying() {
+		ctx.Log.Debug("replaying the recorded output of %q", step.RunCommand)
+		return r.Fixtures.Replay(ctx, step)
+	}
+	start := time.Now()
+	output, err := r.run(ctx, step, path, envs, streamOutput)
+	emitStepMetrics(ctx.Scope, step, time.Since(start), err)
+	if recordErr := r.Fixtures.Record(ctx, step, output, err); recordErr != nil {
+		ctx.Log.Warn("failed to record the output of %q: %s", step.RunCommand, recordErr)
+	}
+	return output, err
 }
 
-func (r *RunStepRunner) Run(ctx command.ProjectContext, command string, path string, envs map[string]string, streamOutput bool, postProcessOutput valid.PostProcessRunOutputOption) (string, error) {
+func (r *RunStepRunner) run(ctx command.ProjectContext, step valid.Step, path string, envs map[string]string, streamOutput bool) (string, error) {
+	command := step.RunCommand
+	postProcessOutput := step.Output
 	tfVersion := r.DefaultTFVersion
 	if ctx.TerraformVersion != nil {
 		tfVersion = ctx.TerraformVersion
 	}
 
+	if err := checkRunCommandPolicy(ctx, step, envs); err != nil {
+		ctx.Log.Debug("error: %s", err)
+		return "", err
+	}
+	if failed := projectsNotSucceeded(ctx, step.RequiresProjectsSuccess); len(failed) > 0 {
+		ctx.Log.Info("skipping %q since projects %s didn't succeed", command, strings.Join(failed, ", "))
+		return fmt.Sprintf("Skipped `%s` since these projects didn't succeed in this run: %s.\n", command, strings.Join(failed, ", ")), nil
+	}
+
 	err := r.TerraformExecutor.EnsureVersion(ctx.Log, tfVersion)
 	if err != nil {
 		err = fmt.Errorf("%s: Downloading terraform Version %s", err, tfVersion.String())
@@ -35,22 +101,28 @@ func (r *RunStepRunner) Run(ctx command.ProjectContext, command string, path str
 		return "", err
 	}
 
-	baseEnvVars := os.Environ()
+	run, err := r.shouldRun(ctx, step, path, envs, tfVersion)
+	if err != nil {
+		ctx.Log.Debug("error: %s", err)
+		return "", err
+	}
+	if !run {
+		return "", nil
+	}
+
+	baseEnvVars := passthroughEnv(os.Environ(), ctx.EnvPassthrough)
+	// Keep in sync with valid.StepEnvVars.
 	customEnvVars := map[string]string{
 		"ATLANTIS_TERRAFORM_VERSION": tfVersion.String(),
 		"BASE_BRANCH_NAME":           ctx.Pull.BaseBranch,
 		"BASE_REPO_NAME":             ctx.BaseRepo.Name,
 		"BASE_REPO_OWNER":            ctx.BaseRepo.Owner,
 		"COMMENT_ARGS":               strings.Join(ctx.EscapedCommentArgs, ","),
-		"DIR":                        path,
 		"HEAD_BRANCH_NAME":           ctx.Pull.HeadBranch,
 		"HEAD_COMMIT":                ctx.Pull.HeadCommit,
 		"HEAD_REPO_NAME":             ctx.HeadRepo.Name,
 		"HEAD_REPO_OWNER":            ctx.HeadRepo.Owner,
-		"PATH":                       fmt.Sprintf("%s:%s", os.Getenv("PATH"), r.TerraformBinDir),
-		"PLANFILE":                   filepath.Join(path, GetPlanFilename(ctx.Workspace, ctx.ProjectName)),
-		"SHOWFILE":                   filepath.Join(path, ctx.GetShowResultFileName()),
-		"POLICYCHECKFILE":            filepath.Join(path, ctx.GetPolicyCheckResultFileName()),
+		"PATH":                       r.defaultPath(),
 		"PROJECT_NAME":               ctx.ProjectName,
 		"PULL_AUTHOR":                ctx.Pull.Author,
 		"PULL_NUM":                   fmt.Sprintf("%d", ctx.Pull.Num),
@@ -59,6 +131,15 @@ func (r *RunStepRunner) Run(ctx command.ProjectContext, command string, path str
 		"USER_NAME":                  ctx.User.Username,
 		"WORKSPACE":                  ctx.Workspace,
 	}
+	for key, val := range projectPathEnvVars(ctx, path) {
+		customEnvVars[key] = val
+	}
+	if r.RunIDEnvVar != "" && ctx.RunID != "" {
+		customEnvVars[r.RunIDEnvVar] = ctx.RunID
+	}
+	if ctx.CLIConfigFile != "" {
+		customEnvVars["TF_CLI_CONFIG_FILE"] = ctx.CLIConfigFile
+	}
 
 	finalEnvVars := baseEnvVars
 	for key, val := range customEnvVars {
@@ -68,13 +149,217 @@ func (r *RunStepRunner) Run(ctx command.ProjectContext, command string, path str
 		finalEnvVars = append(finalEnvVars, fmt.Sprintf("%s=%s", key, val))
 	}
 
-	runner := models.NewShellCommandRunner(command, finalEnvVars, path, streamOutput, r.ProjectCmdOutputHandler)
-	output, err := runner.Run(ctx)
+	pathEnv := customEnvVars["PATH"]
+	if v, ok := envs["PATH"]; ok {
+		pathEnv = v
+	}
+	if err := checkRequiredTools(step.RequireTools, finalEnvVars, pathEnv, path); err != nil {
+		ctx.Log.Debug("error: %s", err)
+		return "", err
+	}
+	if err := checkRequiredFiles(step.RequiresFiles, path); err != nil {
+		ctx.Log.Debug("error: %s", err)
+		return "", err
+	}
+	if step.RequiresPlan {
+		if err := checkPlanExists(ctx, path); err != nil {
+			ctx.Log.Debug("error: %s", err)
+			return "", err
+		}
+	}
+	var nixShell string
+	if step.NixShell != "" {
+		if nixShell, err = nixShellFile(step.NixShell, path, ctx.RepoRelDir, pathEnv); err != nil {
+			ctx.Log.Debug("error: %s", err)
+			return "", err
+		}
+	}
+
+	// Steps are approved once every check passed, so they're not approved
+	// only to fail, and before changing anything.
+	if step.RequireApproval {
+		if r.StepApprovals == nil {
+			err = fmt.Errorf("%q requires approval but the approve-step command isn't allowed, add it to --allow-commands", command)
+			ctx.Log.Debug("error: %s", err)
+			return "", err
+		}
+		if err := r.StepApprovals.Wait(ctx, step); err != nil {
+			ctx.Log.Debug("error: %s", err)
+			return "", err
+		}
+		ctx.Log.Info("%q was approved", command)
+	}
+
+	var input string
+	if step.Stdin != "" {
+		input = expandStepInput(step.Stdin, finalEnvVars)
+		ctx.Log.Debug("writing %q to stdin of %q", utils.MaskSecrets(input, maskedValues(envs)), command)
+	}
+
+	var cacheKey string
+	var cacheHit bool
+	if step.Cache != nil && r.StepCache != nil {
+		if cacheKey, cacheHit, err = r.restoreStepCache(ctx, *step.Cache, path); err != nil {
+			err = fmt.Errorf("restoring cache: %s", err)
+			ctx.Log.Debug("error: %s", err)
+			return "", err
+		}
+	}
+
+	var snapshot *dirSnapshot
+	if step.RestoreDir {
+		if snapshot, err = snapshotDir(path); err != nil {
+			ctx.Log.Debug("error: %s", err)
+			return "", err
+		}
+		defer snapshot.cleanup()
+	}
+
+	var gitBefore *gitChanges
+	if step.RequireCleanAfter {
+		if gitBefore, err = readGitChanges(path); err != nil {
+			err = fmt.Errorf("checking for uncommitted changes: %s", err)
+			ctx.Log.Debug("error: %s", err)
+			return "", err
+		}
+	}
+
+	if step.JUnit != "" {
+		// Remove the report of a previous run so a command that doesn't write
+		// one isn't summarized with stale results.
+		if err := os.Remove(filepath.Join(path, step.JUnit)); err != nil && !os.IsNotExist(err) {
+			err = fmt.Errorf("removing previous junit report %q: %s", step.JUnit, err)
+			ctx.Log.Debug("error: %s", err)
+			return "", err
+		}
+	}
+
+	runCommand := command
+	var envDiffFile string
+	if step.DebugEnvDiff {
+		if envDiffFile, err = createEnvDiffFile(); err != nil {
+			ctx.Log.Debug("error: %s", err)
+			return "", err
+		}
+		defer os.Remove(envDiffFile) // nolint: errcheck
+		runCommand = envDiffCommand(command, envDiffFile)
+	}
+
+	runner := models.NewShellCommandRunner(nixShellCommand(runCommand, nixShell), finalEnvVars, path, streamOutput, r.ProjectCmdOutputHandler)
+	runner.SetStdin(input)
+	if step.NoNetwork {
+		if err := runner.DisableNetwork(); err != nil {
+			err = fmt.Errorf("can't run %q without network access: %s", command, err)
+			ctx.Log.Debug("error: %s", err)
+			return "", err
+		}
+	}
+	if step.MemoryLimit > 0 || step.CPULimit > 0 {
+		if err := runner.LimitResources(step.MemoryLimit, step.CPULimit); err != nil {
+			err = fmt.Errorf("can't limit the resources of %q: %s", command, err)
+			ctx.Log.Debug("error: %s", err)
+			return "", err
+		}
+	}
+	var output string
+	if step.ForEach != "" {
+		output, err = r.runForEach(ctx, step, finalEnvVars, path, streamOutput, input, nixShell)
+	} else if step.Stream && r.PullCommentUpdater != nil {
+		r.waitRateLimit(ctx, step)
+		output, err = r.runStreamed(ctx, runner, step, envs)
+	} else {
+		r.waitRateLimit(ctx, step)
+		output, err = runner.Run(ctx)
+	}
+	exitCode := commandExitCode(err)
+	if err != nil && slices.Contains(step.AllowedExitCodes, exitCode) {
+		ctx.Log.Info("%q exited with %d which is in its allowed_exit_codes", command, exitCode)
+		err = nil
+	}
+	if step.ExitCodeVar != "" && exitCode >= 0 && envs != nil {
+		envs[step.ExitCodeVar] = strconv.Itoa(exitCode)
+	}
+	if err != nil {
+		if notFoundErr := commandNotFoundErr(err, output, pathEnv); notFoundErr != nil {
+			err = fmt.Errorf("%s: %s", notFoundErr, err)
+		} else if errors.Is(err, models.ErrMemoryLimitExceeded) {
+			err = fmt.Errorf("killed for using more than its memory_limit of %s: %s", formatBytes(step.MemoryLimit), err)
+		}
+	}
+	if step.OutputFilter != "" {
+		output = r.filterOutput(ctx, step.OutputFilter, finalEnvVars, path, output)
+	}
+	if err == nil && step.AssertFormat != "" {
+		err = assertOutputFormat(output, step.AssertFormat)
+	}
+	if err == nil && step.Golden != "" {
+		if diff, goldenErr := compareGolden(output, path, step.Golden); goldenErr != nil {
+			output, err = diff, goldenErr
+		}
+	}
+	if err == nil && step.Verify != nil {
+		err = verifyChecksum(path, *step.Verify)
+	}
+	if err == nil && step.DiffAgainstBase {
+		output = r.diffAgainstBase(ctx, step, finalEnvVars, input, nixShell, output)
+	}
+	if step.JUnit != "" {
+		// Failing tests usually fail the command too so the summary is added
+		// either way.
+		if summary, junitErr := junitSummary(path, step.JUnit); junitErr != nil {
+			if err == nil {
+				err = junitErr
+			} else {
+				ctx.Log.Debug("not summarizing junit report: %s", junitErr)
+			}
+		} else {
+			if output != "" && !strings.HasSuffix(output, "\n") {
+				output += "\n"
+			}
+			output += "\n" + summary
+		}
+	}
+	if envDiffFile != "" {
+		if after, diffErr := readEnvDiffFile(envDiffFile); diffErr != nil {
+			ctx.Log.Warn("not diffing env of %q: %s", command, diffErr)
+		} else {
+			if output != "" && !strings.HasSuffix(output, "\n") {
+				output += "\n"
+			}
+			output += "\n" + envDiff(envMap(finalEnvVars), after)
+		}
+	}
+	if err == nil && step.OnSuccess != "" {
+		output, err = r.runOnSuccess(ctx, step.OnSuccess, finalEnvVars, path, streamOutput, output)
+	}
+	// A fatal step that failed skips its cleanup too.
+	if step.Always != "" && (err == nil || !step.Fatal) {
+		output, err = r.runAlways(ctx, step.Always, finalEnvVars, path, streamOutput, output, err)
+	}
+	if err == nil && gitBefore != nil {
+		err = requireClean(path, command, gitBefore)
+	}
+	if err == nil && cacheKey != "" {
+		output = r.stepCacheReport(ctx, *step.Cache, cacheKey, cacheHit, path) + "\n" + output
+	}
+	if snapshot != nil {
+		if restoreErr := snapshot.restore(); restoreErr != nil {
+			ctx.Log.Warn("%s", restoreErr)
+			if err != nil {
+				err = fmt.Errorf("%s (restoring directory also failed: %s)", err, restoreErr)
+			} else {
+				err = restoreErr
+			}
+		}
+	}
 
 	if postProcessOutput == valid.PostProcessRunOutputStripRefreshing {
 		output = StripRefreshingFromPlanOutput(output, tfVersion)
 
 	}
+	if step.LinePrefix != "" {
+		output = prefixLines(output, step.LinePrefix)
+	}
 
 	if err != nil {
 		err = fmt.Errorf("%s: running %q in %q: \n%s", err, command, path, output)
@@ -85,6 +370,22 @@ func (r *RunStepRunner) Run(ctx command.ProjectContext, command string, path str
 		ctx.Log.Debug("Treating custom policy tool error exit code as a policy failure.  Error output: %s", err)
 	}
 
+	if err == nil && step.Render != "" && step.Render != valid.RenderRaw && postProcessOutput != valid.PostProcessRunOutputHide {
+		if rendered, renderErr := renderOutput(output, step.Render); renderErr != nil {
+			ctx.Log.Warn("not rendering output of %q as %s: %s", command, step.Render, renderErr)
+		} else {
+			output = rendered
+		}
+	}
+
+	if err == nil && step.MetricLabel != "" && postProcessOutput != valid.PostProcessRunOutputHide {
+		if rendered, renderErr := renderMetricLabel(output, step.MetricLabel); renderErr != nil {
+			ctx.Log.Warn("not rendering output of %q as metric %q: %s", command, step.MetricLabel, renderErr)
+		} else {
+			output = rendered
+		}
+	}
+
 	switch postProcessOutput {
 	case valid.PostProcessRunOutputHide:
 		return "", nil
@@ -96,3 +397,140 @@ func (r *RunStepRunner) Run(ctx command.ProjectContext, command string, path str
 		return output, nil
 	}
 }
+
+// waitRateLimit blocks until step's command can run under its rate_limit, if
+// it has one.
+func (r *RunStepRunner) waitRateLimit(ctx command.ProjectContext, step valid.Step) {
+	if step.RateLimit == nil || r.RateLimiters == nil {
+		return
+	}
+	if wait := r.RateLimiters.Wait(*step.RateLimit); wait > 0 {
+		ctx.Log.Debug("waited %s to run %q under rate limit %s", wait.Round(time.Millisecond), step.RunCommand, step.RateLimit)
+	}
+}
+
+// defaultPath is the PATH of commands, unless a step sets it. It includes the
+// directory Terraform binaries are downloaded to.
+func (r *RunStepRunner) defaultPath() string {
+	return fmt.Sprintf("%s:%s", os.Getenv("PATH"), r.TerraformBinDir)
+}
+
+// projectPathEnvVars returns the environment variables of commands that are
+// paths in the project's directory path.
+func projectPathEnvVars(ctx command.ProjectContext, path string) map[string]string {
+	return map[string]string{
+		"DIR":             path,
+		"PLANFILE":        filepath.Join(path, GetPlanFilename(ctx.Workspace, ctx.ProjectName)),
+		"SHOWFILE":        filepath.Join(path, ctx.GetShowResultFileName()),
+		"POLICYCHECKFILE": filepath.Join(path, ctx.GetPolicyCheckResultFileName()),
+	}
+}
+
+// checkRunCommandPolicy returns an error if step runs a command the repo's
+// allowed_run_commands or denied_run_commands don't allow, or if envs, set by
+// earlier env or multienv steps, change the PATH the commands are looked up in.
+// Denied commands are logged so attempts to run them can be audited.
+func checkRunCommandPolicy(ctx command.ProjectContext, step valid.Step, envs map[string]string) error {
+	if _, ok := envs["PATH"]; ok && !ctx.RunCommandPolicy.IsEmpty() && len(step.Commands()) > 0 {
+		ctx.Log.Warn("denied run step %q of project at dir %q workspace %q in repo %q for user %q: PATH was set by an earlier step",
+			step.RunCommand, ctx.RepoRelDir, ctx.Workspace, ctx.BaseRepo.FullName, ctx.User.Username)
+		return fmt.Errorf("not running %q: setting PATH is not allowed when server-side config 'allowed_run_commands' or 'denied_run_commands' is set", step.RunCommand)
+	}
+	for _, command := range step.Commands() {
+		if err := ctx.RunCommandPolicy.Check(command); err != nil {
+			ctx.Log.Warn("denied run step command %q of project at dir %q workspace %q in repo %q for user %q: %s",
+				command, ctx.RepoRelDir, ctx.Workspace, ctx.BaseRepo.FullName, ctx.User.Username, err)
+			return fmt.Errorf("not running %q: %s", command, err)
+		}
+	}
+	return nil
+}
+
+// checkRequiredFiles returns an error listing every file in files, relative to
+// dir, that doesn't exist.
+func checkRequiredFiles(files []string, dir string) error {
+	var missing []string
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(dir, f)); os.IsNotExist(err) {
+			missing = append(missing, fmt.Sprintf("%q", f))
+		} else if err != nil {
+			return fmt.Errorf("checking required file %q: %s", f, err)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("required files not found in %q: %s", dir, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkPlanExists returns an error if the project at path doesn't have a plan
+// file.
+func checkPlanExists(ctx command.ProjectContext, path string) error {
+	planFile := GetPlanFilename(ctx.Workspace, ctx.ProjectName)
+	if _, err := os.Stat(filepath.Join(path, planFile)); os.IsNotExist(err) {
+		return fmt.Errorf("plan must run before this step: no plan file %q found for dir %q workspace %q", planFile, ctx.RepoRelDir, ctx.Workspace)
+	} else if err != nil {
+		return fmt.Errorf("checking for plan file %q: %s", planFile, err)
+	}
+	return nil
+}
+
+// runAlways runs a step's always command after its main command finished with
+// output and err, regardless of whether it failed. The always command's output
+// is appended to output. If it fails and the main command succeeded its error
+// is returned, otherwise the main command's error is kept and the failure is
+// added to it so it isn't lost.
+func (r *RunStepRunner) runAlways(ctx command.ProjectContext, always string, envVars []string, path string, streamOutput bool, output string, err error) (string, error) {
+	runner := models.NewShellCommandRunner(always, envVars, path, streamOutput, r.ProjectCmdOutputHandler)
+	alwaysOutput, alwaysErr := runner.Run(ctx)
+	output += alwaysOutput
+	if alwaysErr == nil {
+		return output, err
+	}
+	alwaysErr = fmt.Errorf("%s: running always command %q", alwaysErr, always)
+	ctx.Log.Warn("%s", alwaysErr)
+	if err != nil {
+		return output, fmt.Errorf("%s (always command also failed: %s)", err, alwaysErr)
+	}
+	return output, alwaysErr
+}
+
+// runOnSuccess runs a step's on_success command after its main command
+// succeeded with output. The on_success command's output is appended to
+// output and if it fails the step fails.
+func (r *RunStepRunner) runOnSuccess(ctx command.ProjectContext, onSuccess string, envVars []string, path string, streamOutput bool, output string) (string, error) {
+	runner := models.NewShellCommandRunner(onSuccess, envVars, path, streamOutput, r.ProjectCmdOutputHandler)
+	onSuccessOutput, err := runner.Run(ctx)
+	output += onSuccessOutput
+	if err != nil {
+		return output, fmt.Errorf("%s: running on_success command %q", err, onSuccess)
+	}
+	return output, nil
+}
+
+// commandExitCode returns the exit code of a command that returned err, or -1
+// if it didn't exit, ex. it couldn't be started or was killed.
+func commandExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return -1
+	}
+	return exitErr.ExitCode()
+}
+
+// commandNotFoundErr returns an actionable error if err was caused by the shell
+// not finding the executable for a command, otherwise it returns nil.
+func commandNotFoundErr(err error, output string, pathEnv string) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != shellCommandNotFoundExitCode {
+		return nil
+	}
+	match := commandNotFoundRegex.FindStringSubmatch(output)
+	if match == nil {
+		return nil
+	}
+	return fmt.Errorf("command %q not found in PATH %q", match[1], pathEnv)
+}
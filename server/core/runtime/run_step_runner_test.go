@@ -1,10 +1,21 @@
 package runtime_test
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-version"
 	. "github.com/petergtz/pegomock/v4"
@@ -16,6 +27,7 @@ import (
 	jobmocks "github.com/runatlantis/atlantis/server/jobs/mocks"
 	"github.com/runatlantis/atlantis/server/logging"
 	. "github.com/runatlantis/atlantis/testing"
+	tally "github.com/uber-go/tally/v4"
 )
 
 func TestRunStepRunner_Run(t *testing.T) {
@@ -60,6 +72,14 @@ func TestRunStepRunner_Run(t *testing.T) {
 			Command: "lkjlkj",
 			ExpErr:  "exit status 127: running \"lkjlkj\" in",
 		},
+		{
+			Command: "lkjlkj --version",
+			ExpErr:  fmt.Sprintf("command \"lkjlkj\" not found in PATH %q", fmt.Sprintf("%s:%s", os.Getenv("PATH"), "/bin/dir")),
+		},
+		{
+			Command: "exit 127",
+			ExpErr:  "exit status 127: running \"exit 127\" in",
+		},
 		{
 			Command: "echo workspace=$WORKSPACE version=$ATLANTIS_TERRAFORM_VERSION dir=$DIR planfile=$PLANFILE showfile=$SHOWFILE project=$PROJECT_NAME",
 			ExpOut:  "workspace=myworkspace version=0.11.0 dir=$DIR planfile=$DIR/myworkspace.tfplan showfile=$DIR/myworkspace.json project=\n",
@@ -145,7 +165,7 @@ func TestRunStepRunner_Run(t *testing.T) {
 				ProjectName:        c.ProjectName,
 				EscapedCommentArgs: []string{"-target=resource1", "-target=resource2"},
 			}
-			out, err := r.Run(ctx, c.Command, tmpDir, map[string]string{"test": "var"}, true, valid.PostProcessRunOutputShow)
+			out, err := r.Run(ctx, valid.Step{RunCommand: c.Command, Output: valid.PostProcessRunOutputShow}, tmpDir, map[string]string{"test": "var"}, true)
 			if c.ExpErr != "" {
 				ErrContains(t, c.ExpErr, err)
 				return
@@ -163,3 +183,1933 @@ func TestRunStepRunner_Run(t *testing.T) {
 		})
 	}
 }
+
+// newRunStepRunner returns a RunStepRunner using Terraform 1.0 and the context
+// of a project in the default workspace of the repo root.
+func newRunStepRunner(t *testing.T) (*runtime.RunStepRunner, command.ProjectContext) {
+	RegisterMockTestingT(t)
+	terraform := mocks.NewMockClient()
+	When(terraform.EnsureVersion(Any[logging.SimpleLogging](), Any[*version.Version]())).
+		ThenReturn(nil)
+	defaultVersion, _ := version.NewVersion("1.0")
+	r := &runtime.RunStepRunner{
+		TerraformExecutor:       terraform,
+		DefaultTFVersion:        defaultVersion,
+		ProjectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+	}
+	ctx := command.ProjectContext{
+		Log:        logging.NewNoopLogger(t),
+		Workspace:  "default",
+		RepoRelDir: ".",
+	}
+	return r, ctx
+}
+
+// fakePullCommentUpdater records the comments a streamed run step writes.
+type fakePullCommentUpdater struct {
+	mu       sync.Mutex
+	comments []string
+	err      error
+}
+
+func (f *fakePullCommentUpdater) CreateUpdatableComment(_ logging.SimpleLogging, _ models.Repo, _ int, comment string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.comments = append(f.comments, comment)
+	return 1, f.err
+}
+
+func (f *fakePullCommentUpdater) UpdateComment(_ logging.SimpleLogging, _ models.Repo, _ int, _ int64, comment string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.comments = append(f.comments, comment)
+	return f.err
+}
+
+func TestRunStepRunner_RunStreamed(t *testing.T) {
+	cases := []struct {
+		description  string
+		command      string
+		updaterErr   error
+		expErr       bool
+		expLastStart string
+	}{
+		{
+			description:  "streams output and finishes",
+			command:      "echo first; sleep 0.5; echo secret-token",
+			expLastStart: "**Finished**",
+		},
+		{
+			description:  "streams output and fails",
+			command:      "echo first; sleep 0.5; echo secret-token; exit 1",
+			expErr:       true,
+			expLastStart: "**Failed**",
+		},
+		{
+			description: "disables streaming if the comment can't be created",
+			command:     "echo first; sleep 0.5; echo secret-token",
+			updaterErr:  errors.New("not supported"),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			updater := &fakePullCommentUpdater{err: c.updaterErr}
+			r, ctx := newRunStepRunner(t)
+			r.PullCommentUpdater = updater
+			r.StreamCommentInterval = 100 * time.Millisecond
+			ctx.RepoRelDir = "mydir"
+			step := valid.Step{
+				StepName:   "run",
+				RunCommand: c.command,
+				Output:     valid.PostProcessRunOutputShow,
+				Stream:     true,
+			}
+			out, err := r.Run(ctx, step, t.TempDir(), map[string]string{"TOKEN": "secret-token"}, false)
+			if c.expErr {
+				Assert(t, err != nil, "expected error")
+			} else {
+				Ok(t, err)
+				// The step's own output isn't masked, only the streamed comment.
+				Equals(t, "first\nsecret-token\n", out)
+			}
+
+			updater.mu.Lock()
+			defer updater.mu.Unlock()
+			if c.updaterErr != nil {
+				Equals(t, 1, len(updater.comments))
+			} else {
+				// There may be an extra update if the ticker fires between the
+				// last line of output and the command exiting.
+				Assert(t, len(updater.comments) >= 2, "expected at least 2 comments, got %d", len(updater.comments))
+			}
+			Assert(t, strings.HasPrefix(updater.comments[0], "**Running**"), "first comment should be in progress, got %q", updater.comments[0])
+			Assert(t, strings.Contains(updater.comments[0], "first\n"), "first comment should contain partial output, got %q", updater.comments[0])
+			if c.expLastStart != "" {
+				last := updater.comments[len(updater.comments)-1]
+				Assert(t, strings.HasPrefix(last, c.expLastStart), "last comment should start with %q, got %q", c.expLastStart, last)
+				Assert(t, strings.Contains(last, "first\n***\n"), "last comment should contain masked output, got %q", last)
+				Assert(t, !strings.Contains(last, "secret-token"), "last comment should not contain secret, got %q", last)
+			}
+		})
+	}
+}
+
+func TestRunStepRunner_RunAlways(t *testing.T) {
+	cases := []struct {
+		description string
+		command     string
+		always      string
+		expOut      string
+		expErr      []string
+		expRun      bool
+	}{
+		{
+			description: "runs after success",
+			command:     "echo test",
+			always:      "echo cleanup",
+			expOut:      "test\ncleanup\n",
+		},
+		{
+			description: "runs after failure and keeps the command's error",
+			command:     "echo test; exit 3",
+			always:      "echo cleanup",
+			expErr:      []string{"exit status 3", "test\ncleanup\n"},
+		},
+		{
+			description: "runs after the command is killed",
+			command:     "echo test; kill -TERM $$",
+			always:      "echo cleanup",
+			expErr:      []string{"signal: terminated", "test\ncleanup\n"},
+		},
+		{
+			description: "failing cleanup fails the step",
+			command:     "echo test",
+			always:      "echo cleanup; exit 4",
+			expErr:      []string{"exit status 4: running always command \"echo cleanup; exit 4\""},
+		},
+		{
+			description: "failing cleanup doesn't override the command's error",
+			command:     "echo test; exit 3",
+			always:      "echo cleanup; exit 4",
+			expErr:      []string{"exit status 3 (always command also failed: ", "exit status 4"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r, ctx := newRunStepRunner(t)
+			step := valid.Step{
+				StepName:   "run",
+				RunCommand: c.command,
+				Always:     c.always,
+				Output:     valid.PostProcessRunOutputShow,
+			}
+			out, err := r.Run(ctx, step, t.TempDir(), nil, false)
+			if len(c.expErr) > 0 {
+				for _, expErr := range c.expErr {
+					ErrContains(t, expErr, err)
+				}
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.expOut, out)
+		})
+	}
+}
+
+// Test that a fatal step's always command only runs if the step succeeded.
+func TestRunStepRunner_RunFatal(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+	path := t.TempDir()
+	step := valid.Step{
+		StepName:   "run",
+		RunCommand: "echo test; exit 3",
+		Always:     "touch cleaned",
+		Fatal:      true,
+		Output:     valid.PostProcessRunOutputShow,
+	}
+	_, err := r.Run(ctx, step, path, nil, false)
+	ErrContains(t, "exit status 3", err)
+	_, err = os.Stat(filepath.Join(path, "cleaned"))
+	Assert(t, os.IsNotExist(err), "exp always command not to run after the fatal step failed")
+
+	step.RunCommand = "echo test"
+	out, err := r.Run(ctx, step, path, nil, false)
+	Ok(t, err)
+	Equals(t, "test\n", out)
+	_, err = os.Stat(filepath.Join(path, "cleaned"))
+	Ok(t, err)
+}
+
+func TestRunStepRunner_RunOnSuccess(t *testing.T) {
+	cases := []struct {
+		description string
+		command     string
+		onSuccess   string
+		always      string
+		expOut      string
+		expErr      []string
+		expRun      bool
+	}{
+		{
+			description: "runs after success",
+			command:     "echo deploy",
+			onSuccess:   "echo notify",
+			expOut:      "deploy\nnotify\n",
+		},
+		{
+			description: "doesn't run after failure",
+			command:     "echo deploy; exit 3",
+			onSuccess:   "echo notify",
+			expErr:      []string{"exit status 3", "deploy\n"},
+		},
+		{
+			description: "failing follow-up fails the step",
+			command:     "echo deploy",
+			onSuccess:   "echo notify; exit 4",
+			expErr:      []string{"exit status 4: running on_success command \"echo notify; exit 4\"", "deploy\nnotify\n"},
+			expRun:      true,
+		},
+		{
+			description: "runs before always",
+			command:     "echo deploy",
+			onSuccess:   "echo notify",
+			always:      "echo cleanup",
+			expOut:      "deploy\nnotify\ncleanup\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r, ctx := newRunStepRunner(t)
+			step := valid.Step{
+				StepName:   "run",
+				RunCommand: c.command,
+				OnSuccess:  c.onSuccess,
+				Always:     c.always,
+				Output:     valid.PostProcessRunOutputShow,
+			}
+			out, err := r.Run(ctx, step, t.TempDir(), nil, false)
+			if len(c.expErr) > 0 {
+				for _, expErr := range c.expErr {
+					ErrContains(t, expErr, err)
+				}
+				Assert(t, strings.Contains(err.Error(), "notify") == c.expRun, "expected on_success to run: %t, got %q", c.expRun, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.expOut, out)
+		})
+	}
+}
+
+func TestRunStepRunner_RunVerify(t *testing.T) {
+	sha256Sum := "7c9bbe5ec9b3fb774e8fa0f54247e93c34ddf8e5d16fe3073420de0ae81a262d"
+	sha512Sum := "62b95004b6eb5f43905104a81fd32c28cdcb4e3ffb50105afd03eb1257cf034a4edb6d1f0f9fd15d76fa42a0d08325d54b1de8115a898675cadc79daa2cbab64"
+	wrongSum := strings.Repeat("0", 64)
+
+	cases := []struct {
+		description string
+		command     string
+		verify      valid.StepVerify
+		expErr      string
+	}{
+		{
+			description: "sha256 matches",
+			command:     "printf tool > tool",
+			verify:      valid.StepVerify{File: "tool", Algorithm: valid.ChecksumSHA256, Checksum: sha256Sum},
+		},
+		{
+			description: "sha512 matches",
+			command:     "printf tool > tool",
+			verify:      valid.StepVerify{File: "tool", Algorithm: valid.ChecksumSHA512, Checksum: sha512Sum},
+		},
+		{
+			description: "mismatch",
+			command:     "printf tampered > tool",
+			verify:      valid.StepVerify{File: "tool", Algorithm: valid.ChecksumSHA256, Checksum: wrongSum},
+			expErr:      fmt.Sprintf("checksum mismatch for \"tool\": expected sha256 %s, got ", wrongSum),
+		},
+		{
+			description: "missing file",
+			command:     "true",
+			verify:      valid.StepVerify{File: "tool", Algorithm: valid.ChecksumSHA256, Checksum: sha256Sum},
+			expErr:      "verifying checksum of \"tool\"",
+		},
+		{
+			description: "checksum file with only the checksum",
+			command:     fmt.Sprintf("printf tool > tool; echo %s > tool.sha256", sha256Sum),
+			verify:      valid.StepVerify{File: "tool", Algorithm: valid.ChecksumSHA256, ChecksumFile: "tool.sha256"},
+		},
+		{
+			description: "sha256sum checksum file",
+			command:     fmt.Sprintf("mkdir bin; printf tool > bin/tool; printf '%s  other\\n%s  tool\\n' > SHA256SUMS", wrongSum, sha256Sum),
+			verify:      valid.StepVerify{File: "bin/tool", Algorithm: valid.ChecksumSHA256, ChecksumFile: "SHA256SUMS"},
+		},
+		{
+			description: "checksum file mismatch",
+			command:     fmt.Sprintf("printf tool > tool; printf '%s  tool\\n' > SHA256SUMS", wrongSum),
+			verify:      valid.StepVerify{File: "tool", Algorithm: valid.ChecksumSHA256, ChecksumFile: "SHA256SUMS"},
+			expErr:      "checksum mismatch for \"tool\"",
+		},
+		{
+			description: "checksum file without the file",
+			command:     fmt.Sprintf("printf tool > tool; printf '%s  other\\n%s  another\\n' > SHA256SUMS", sha256Sum, sha256Sum),
+			verify:      valid.StepVerify{File: "tool", Algorithm: valid.ChecksumSHA256, ChecksumFile: "SHA256SUMS"},
+			expErr:      "reading checksum of \"tool\": no checksum for \"tool\" in \"SHA256SUMS\"",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r, ctx := newRunStepRunner(t)
+			step := valid.Step{
+				StepName:   "run",
+				RunCommand: c.command,
+				Verify:     &c.verify,
+				Output:     valid.PostProcessRunOutputShow,
+			}
+			_, err := r.Run(ctx, step, t.TempDir(), nil, false)
+			if c.expErr != "" {
+				ErrContains(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+		})
+	}
+}
+
+func TestRunStepRunner_RunRequiresFiles(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+	dir := t.TempDir()
+	Ok(t, os.WriteFile(filepath.Join(dir, "config.yaml"), nil, 0600))
+	step := valid.Step{
+		StepName:      "run",
+		RunCommand:    "touch ran",
+		RequiresFiles: []string{"config.yaml", "secrets.env", "certs/ca.pem"},
+		Output:        valid.PostProcessRunOutputShow,
+	}
+
+	_, err := r.Run(ctx, step, dir, nil, false)
+	ErrEquals(t, fmt.Sprintf("required files not found in %q: \"secrets.env\", \"certs/ca.pem\"", dir), err)
+	_, err = os.Stat(filepath.Join(dir, "ran"))
+	Assert(t, os.IsNotExist(err), "expected the command not to run")
+
+	Ok(t, os.WriteFile(filepath.Join(dir, "secrets.env"), nil, 0600))
+	Ok(t, os.MkdirAll(filepath.Join(dir, "certs"), 0700))
+	Ok(t, os.WriteFile(filepath.Join(dir, "certs", "ca.pem"), nil, 0600))
+	_, err = r.Run(ctx, step, dir, nil, false)
+	Ok(t, err)
+	_, err = os.Stat(filepath.Join(dir, "ran"))
+	Ok(t, err)
+}
+
+func TestRunStepRunner_RunRequiresPlan(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+	ctx.RepoRelDir = "project"
+	ctx.ProjectName = "app"
+	dir := t.TempDir()
+	step := valid.Step{
+		StepName:     "run",
+		RunCommand:   "echo checked",
+		RequiresPlan: true,
+		Output:       valid.PostProcessRunOutputShow,
+	}
+
+	_, err := r.Run(ctx, step, dir, nil, false)
+	ErrEquals(t, "plan must run before this step: no plan file \"app-default.tfplan\" found for dir \"project\" workspace \"default\"", err)
+
+	Ok(t, os.WriteFile(filepath.Join(dir, "app-default.tfplan"), nil, 0600))
+	out, err := r.Run(ctx, step, dir, nil, false)
+	Ok(t, err)
+	Equals(t, "checked\n", out)
+}
+
+func TestRunStepRunner_RunExitCodeVar(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+
+	cases := []struct {
+		description string
+		command     string
+		allowed     []int
+		expOut      string
+		expErr      string
+		expEnvs     map[string]string
+	}{
+		{
+			description: "success",
+			command:     "echo ok",
+			expOut:      "ok\n",
+			expEnvs:     map[string]string{"CHECK_RC": "0"},
+		},
+		{
+			description: "allowed exit code",
+			command:     "echo drift && exit 2",
+			allowed:     []int{1, 2},
+			expOut:      "drift\n",
+			expEnvs:     map[string]string{"CHECK_RC": "2"},
+		},
+		{
+			description: "exit code not allowed",
+			command:     "echo broken && exit 3",
+			allowed:     []int{1, 2},
+			expErr:      "exit status 3",
+			expEnvs:     map[string]string{"CHECK_RC": "3"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			envs := map[string]string{}
+			out, err := r.Run(ctx, valid.Step{
+				StepName:         "run",
+				RunCommand:       c.command,
+				ExitCodeVar:      "CHECK_RC",
+				AllowedExitCodes: c.allowed,
+				Output:           valid.PostProcessRunOutputShow,
+			}, t.TempDir(), envs, false)
+			if c.expErr != "" {
+				ErrContains(t, c.expErr, err)
+			} else {
+				Ok(t, err)
+				Equals(t, c.expOut, out)
+			}
+			Equals(t, c.expEnvs, envs)
+		})
+	}
+}
+
+func TestRunStepRunner_RunDebugEnvDiff(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+	envs := map[string]string{"CHANGE_ME": "old", "REMOVE_ME": "value"}
+
+	out, err := r.Run(ctx, valid.Step{
+		StepName:     "run",
+		RunCommand:   "export ADDED=value API_TOKEN=hunter2 MULTILINE='a\nb' CHANGE_ME=new && unset REMOVE_ME && cd .. && echo ran",
+		DebugEnvDiff: true,
+		Output:       valid.PostProcessRunOutputShow,
+	}, t.TempDir(), envs, false)
+	Ok(t, err)
+	Equals(t, `ran
+
+Env changes:
++ ADDED="value"
++ API_TOKEN=***
+~ CHANGE_ME="new" (was "old")
++ MULTILINE="a\nb"
+- REMOVE_ME
+`, out)
+
+	// The env is diffed when the command exits early too.
+	_, err = r.Run(ctx, valid.Step{
+		StepName:     "run",
+		RunCommand:   "export ADDED=value && exit 3",
+		DebugEnvDiff: true,
+		Output:       valid.PostProcessRunOutputShow,
+	}, t.TempDir(), envs, false)
+	ErrContains(t, "Env changes:\n+ ADDED=\"value\"\n", err)
+
+	out, err = r.Run(ctx, valid.Step{
+		StepName:     "run",
+		RunCommand:   "echo ran",
+		DebugEnvDiff: true,
+		Output:       valid.PostProcessRunOutputShow,
+	}, t.TempDir(), envs, false)
+	Ok(t, err)
+	Equals(t, "ran\n\nEnv changes: none\n", out)
+}
+
+func TestRunStepRunner_RunNixShell(t *testing.T) {
+	binDir := t.TempDir()
+	// The fake nix-shell prints the shell file and runs the command.
+	Ok(t, os.WriteFile(filepath.Join(binDir, "nix-shell"), []byte("#!/bin/sh\necho \"shell=$1\"\nsh -c \"$3\"\n"), 0700)) // nolint: gosec
+	repoDir := t.TempDir()
+	Ok(t, os.WriteFile(filepath.Join(repoDir, "shell.nix"), nil, 0600))
+	projectDir := filepath.Join(repoDir, "project")
+	Ok(t, os.MkdirAll(projectDir, 0700))
+
+	cases := []struct {
+		description string
+		nixShell    string
+		pathEnv     string
+		expOut      string
+		expErr      string
+	}{
+		{
+			description: "shell file in repo",
+			nixShell:    "../shell.nix",
+			pathEnv:     binDir + ":" + os.Getenv("PATH"),
+			expOut:      fmt.Sprintf("shell=%s\nit's 'quoted'\n", filepath.Join(repoDir, "shell.nix")),
+		},
+		{
+			description: "shell file outside repo",
+			nixShell:    "../../shell.nix",
+			pathEnv:     binDir + ":" + os.Getenv("PATH"),
+			expErr:      "nix_shell \"../../shell.nix\" must be inside the repo",
+		},
+		{
+			description: "shell file not found",
+			nixShell:    "shell.nix",
+			pathEnv:     binDir + ":" + os.Getenv("PATH"),
+			expErr:      fmt.Sprintf("nix_shell \"shell.nix\" not found in %q", projectDir),
+		},
+		{
+			description: "nix isn't installed",
+			nixShell:    "../shell.nix",
+			pathEnv:     "/nonexistent",
+			expErr:      "can't run in nix_shell \"../shell.nix\": \"nix-shell\" not found in PATH \"/nonexistent\", is Nix installed on the Atlantis server?",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r, ctx := newRunStepRunner(t)
+			ctx.RepoRelDir = "project"
+			step := valid.Step{
+				StepName:   "run",
+				RunCommand: "echo \"it's 'quoted'\"",
+				NixShell:   c.nixShell,
+				Output:     valid.PostProcessRunOutputShow,
+			}
+			out, err := r.Run(ctx, step, projectDir, map[string]string{"PATH": c.pathEnv}, false)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.expOut, out)
+		})
+	}
+}
+
+func TestRunStepRunner_RunJUnit(t *testing.T) {
+	report := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="pkg">
+    <testcase classname="pkg" name="TestPass"></testcase>
+    <testcase classname="pkg" name="TestFail"><failure message="expected 1"></failure></testcase>
+    <testcase classname="pkg" name="TestSkip"><skipped></skipped></testcase>
+    <testsuite name="pkg/sub">
+      <testcase name="TestPanic"><error message="panic"></error></testcase>
+    </testsuite>
+  </testsuite>
+</testsuites>`
+	cases := []struct {
+		description string
+		command     string
+		report      string
+		expOut      string
+		expErr      string
+	}{
+		{
+			description: "passing tests",
+			command:     "echo tested",
+			report:      `<testsuite name="pkg"><testcase classname="pkg" name="TestPass"/></testsuite>`,
+			expOut:      "tested\n\nTests: 1 passed, 0 failed, 0 skipped, 1 total\n",
+		},
+		{
+			description: "failing tests",
+			command:     "echo tested; exit 1",
+			report:      report,
+			expErr:      "\ntested\n\nTests: 1 passed, 2 failed, 1 skipped, 4 total\nFailed tests:\n- pkg.TestFail\n- TestPanic\n",
+		},
+		{
+			description: "missing report",
+			command:     "echo tested",
+			expErr:      "junit report \"report.xml\" not found in",
+		},
+		{
+			description: "empty report",
+			command:     "echo tested",
+			report:      "\n",
+			expErr:      "junit report \"report.xml\" is empty",
+		},
+		{
+			description: "no tests",
+			command:     "echo tested",
+			report:      "<testsuites></testsuites>",
+			expErr:      "junit report \"report.xml\" has no tests",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r, ctx := newRunStepRunner(t)
+			dir := t.TempDir()
+			// A report left by a previous run must not be summarized.
+			Ok(t, os.WriteFile(filepath.Join(dir, "report.xml"), []byte(report), 0600))
+			command := c.command
+			if c.report != "" {
+				Ok(t, os.WriteFile(filepath.Join(dir, "new-report.xml"), []byte(c.report), 0600))
+				command = "mv new-report.xml report.xml; " + command
+			}
+			step := valid.Step{
+				StepName:   "run",
+				RunCommand: command,
+				JUnit:      "report.xml",
+				Output:     valid.PostProcessRunOutputShow,
+			}
+			out, err := r.Run(ctx, step, dir, nil, false)
+			if c.expErr != "" {
+				ErrContains(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.expOut, out)
+		})
+	}
+}
+
+func TestRunStepRunner_RunRequireTool(t *testing.T) {
+	binDir := t.TempDir()
+	Ok(t, os.WriteFile(filepath.Join(binDir, "mytool"), []byte("#!/bin/sh\necho \"mytool version v1.6.2\"\n"), 0700)) // nolint: gosec
+	Ok(t, os.WriteFile(filepath.Join(binDir, "noversion"), []byte("#!/bin/sh\necho \"no version here\"\n"), 0700))    // nolint: gosec
+	pathEnv := binDir + ":" + os.Getenv("PATH")
+
+	cases := []struct {
+		description string
+		requires    []string
+		expErr      string
+	}{
+		{
+			description: "satisfied",
+			requires:    []string{"mytool>=1.6", "mytool~>1.6.0"},
+		},
+		{
+			description: "any version",
+			requires:    []string{"mytool"},
+		},
+		{
+			description: "too old",
+			requires:    []string{"mytool>=1.7"},
+			expErr:      fmt.Sprintf("required tool \"mytool\" found at version 1.6.2 in %q, install a version matching \">=1.7\"", filepath.Join(binDir, "mytool")),
+		},
+		{
+			description: "missing",
+			requires:    []string{"missingtool>=1.0"},
+			expErr:      fmt.Sprintf("required tool \"missingtool\" not found in PATH %q", pathEnv),
+		},
+		{
+			description: "unparseable version",
+			requires:    []string{"noversion>=1.0"},
+			expErr:      "unable to find the version of required tool \"noversion>=1.0\" in the output of \"noversion --version\": \"no version here\"",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r, ctx := newRunStepRunner(t)
+			step := valid.Step{
+				StepName:   "run",
+				RunCommand: "echo ran",
+				Output:     valid.PostProcessRunOutputShow,
+			}
+			for _, req := range c.requires {
+				toolReq, err := valid.ParseToolRequirement(req)
+				Ok(t, err)
+				step.RequireTools = append(step.RequireTools, toolReq)
+			}
+			out, err := r.Run(ctx, step, t.TempDir(), map[string]string{"PATH": pathEnv}, false)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, "ran\n", out)
+		})
+	}
+}
+
+func TestRunStepRunner_RunForEach(t *testing.T) {
+	cases := []struct {
+		description string
+		command     string
+		forEach     string
+		expOut      string
+		expErr      string
+	}{
+		{
+			description: "runs for every item in order",
+			command:     "echo linted {}",
+			forEach:     "ls *.tf",
+			expOut:      "linted a.tf\nlinted b c.tf\nlinted it's.tf\n",
+		},
+		{
+			description: "fails if an item fails",
+			command:     "test {} != 'b c.tf' && echo ok",
+			forEach:     "ls *.tf",
+			expErr:      "exit status 1: failed for 1 of 3 items: b c.tf",
+		},
+		{
+			description: "for_each command fails",
+			command:     "echo {}",
+			forEach:     "echo oops >&2; exit 2",
+			expErr:      "exit status 2: running for_each command \"echo oops >&2; exit 2\"",
+		},
+		{
+			description: "no items",
+			command:     "echo {}",
+			forEach:     "true",
+			expOut:      "",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r, ctx := newRunStepRunner(t)
+			path := t.TempDir()
+			for _, f := range []string{"a.tf", "b c.tf", "it's.tf"} {
+				Ok(t, os.WriteFile(filepath.Join(path, f), nil, 0600))
+			}
+			step := valid.Step{
+				StepName:   "run",
+				RunCommand: c.command,
+				ForEach:    c.forEach,
+				Parallel:   2,
+				Output:     valid.PostProcessRunOutputShow,
+			}
+			out, err := r.Run(ctx, step, path, map[string]string{}, false)
+			if c.expErr != "" {
+				ErrContains(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.expOut, out)
+		})
+	}
+}
+
+// Test that at most parallel items of a for_each run at once.
+func TestRunStepRunner_RunForEachParallel(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+	path := t.TempDir()
+	Ok(t, os.Mkdir(filepath.Join(path, "running"), 0700))
+	step := valid.Step{
+		StepName:   "run",
+		RunCommand: "touch running/{} && ls running | wc -l >> counts && sleep 0.2 && rm running/{}",
+		ForEach:    "seq 1 8",
+		Parallel:   3,
+		Output:     valid.PostProcessRunOutputShow,
+	}
+	_, err := r.Run(ctx, step, path, map[string]string{}, false)
+	Ok(t, err)
+
+	counts, err := os.ReadFile(filepath.Join(path, "counts"))
+	Ok(t, err)
+	lines := strings.Fields(string(counts))
+	Equals(t, 8, len(lines))
+	for _, line := range lines {
+		Assert(t, line >= "1" && line <= "3", "exp at most 3 items running at once, got %s", line)
+	}
+}
+
+func TestRunStepRunner_RunGolden(t *testing.T) {
+	cases := []struct {
+		description string
+		command     string
+		golden      string
+		expOut      string
+		expErr      string
+	}{
+		{
+			description: "matches",
+			command:     "printf 'a\\nb\\n'",
+			golden:      "a\r\nb\n\n",
+			expOut:      "a\nb\n",
+		},
+		{
+			description: "differs",
+			command:     "printf 'a\\nc\\n'",
+			golden:      "a\nb\n",
+			expErr: "output doesn't match golden file \"expected.txt\", update it if the change is expected: running \"printf 'a\\\\nc\\\\n'\" in \"PATH\": \n" +
+				"--- expected.txt\n+++ output\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+		},
+		{
+			description: "missing golden file",
+			command:     "echo a",
+			expErr:      "reading golden file \"expected.txt\": open PATH/expected.txt: no such file or directory: running \"echo a\" in \"PATH\": \na\n",
+		},
+		{
+			description: "command fails",
+			command:     "echo a && exit 1",
+			golden:      "b\n",
+			expErr:      "running \"echo a && exit 1\" in \"PATH\": exit status 1: running \"echo a && exit 1\" in \"PATH\": \na\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r, ctx := newRunStepRunner(t)
+			path := t.TempDir()
+			if c.golden != "" {
+				Ok(t, os.WriteFile(filepath.Join(path, "expected.txt"), []byte(c.golden), 0600))
+			}
+			step := valid.Step{
+				StepName:   "run",
+				RunCommand: c.command,
+				Golden:     "expected.txt",
+				Output:     valid.PostProcessRunOutputShow,
+			}
+			out, err := r.Run(ctx, step, path, map[string]string{}, false)
+			if c.expErr != "" {
+				ErrEquals(t, strings.ReplaceAll(c.expErr, "PATH", path), err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.expOut, out)
+		})
+	}
+}
+
+func TestRunStepRunner_RunAssertFormat(t *testing.T) {
+	cases := []struct {
+		description string
+		command     string
+		format      valid.AssertFormatOption
+		expErr      string
+	}{
+		{
+			description: "valid json",
+			command:     `echo '{"a": [1, 2]}'`,
+			format:      valid.AssertFormatJSON,
+		},
+		{
+			description: "invalid json",
+			command:     `printf '{\n  "a": 1,\n}\n'`,
+			format:      valid.AssertFormatJSON,
+			expErr:      "output isn't valid json at line 3: invalid character '}' looking for beginning of object key string: \"}\"",
+		},
+		{
+			description: "truncated json",
+			command:     `printf '{\n  "a": 1\n'`,
+			format:      valid.AssertFormatJSON,
+			expErr:      "output isn't valid json at line 2: unexpected end of JSON input: \"  \\\"a\\\": 1\"",
+		},
+		{
+			description: "json with trailing data",
+			command:     `printf '{}\n{}\n'`,
+			format:      valid.AssertFormatJSON,
+			expErr:      "output isn't valid json at line 2: invalid character '{' after top-level value: \"{}\"",
+		},
+		{
+			description: "valid yaml documents",
+			command:     `printf 'a: 1\n---\nb: [2]\n'`,
+			format:      valid.AssertFormatYAML,
+		},
+		{
+			description: "invalid yaml",
+			command:     `printf 'a: 1\nb: c: d\n'`,
+			format:      valid.AssertFormatYAML,
+			expErr:      "output isn't valid yaml at line 2: mapping values are not allowed in this context: \"b: c: d\"",
+		},
+		{
+			description: "invalid yaml in second document",
+			command:     `printf 'a: 1\n---\nb: c: d\n'`,
+			format:      valid.AssertFormatYAML,
+			expErr:      "output isn't valid yaml at line 3: mapping values are not allowed in this context: \"b: c: d\"",
+		},
+		{
+			description: "empty output",
+			command:     "true",
+			format:      valid.AssertFormatYAML,
+			expErr:      "output isn't valid yaml: output is empty",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r, ctx := newRunStepRunner(t)
+			path := t.TempDir()
+			step := valid.Step{
+				StepName:     "run",
+				RunCommand:   c.command,
+				AssertFormat: c.format,
+				Output:       valid.PostProcessRunOutputShow,
+			}
+			_, err := r.Run(ctx, step, path, map[string]string{}, false)
+			if c.expErr != "" {
+				ErrContains(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+		})
+	}
+}
+
+func TestRunStepRunner_RunMetrics(t *testing.T) {
+	cases := []struct {
+		description string
+		command     string
+		metric      string
+		expKey      string
+		expCounter  string
+	}{
+		{
+			description: "successful step with metric",
+			command:     "echo hi",
+			metric:      "build_time",
+			expKey:      "step=build_time",
+			expCounter:  "execution_success",
+		},
+		{
+			description: "failed step with metric",
+			command:     "exit 1",
+			metric:      "build_time",
+			expKey:      "step=build_time",
+			expCounter:  "execution_error",
+		},
+		{
+			description: "step without metric",
+			command:     "echo hi",
+			expKey:      "step=run",
+			expCounter:  "execution_success",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r, ctx := newRunStepRunner(t)
+			scope := tally.NewTestScope("atlantis", map[string]string{"project": "myproject"})
+			ctx.Scope = scope
+			step := valid.Step{
+				StepName:   "run",
+				RunCommand: c.command,
+				Metric:     c.metric,
+				Output:     valid.PostProcessRunOutputShow,
+			}
+			_, _ = r.Run(ctx, step, t.TempDir(), map[string]string{}, false)
+
+			snapshot := scope.Snapshot()
+			tags := "+project=myproject," + c.expKey
+			counter, ok := snapshot.Counters()["atlantis.run_step."+c.expCounter+tags]
+			Assert(t, ok, "exp %s counter to be emitted, got %v", c.expCounter, snapshot.Counters())
+			Equals(t, int64(1), counter.Value())
+			timer, ok := snapshot.Timers()["atlantis.run_step.execution_time"+tags]
+			Assert(t, ok, "exp execution_time timer to be emitted, got %v", snapshot.Timers())
+			Equals(t, 1, len(timer.Values()))
+		})
+	}
+}
+
+func TestRunStepRunner_RunInput(t *testing.T) {
+	cases := []struct {
+		description string
+		command     string
+		input       string
+		forEach     string
+		envs        map[string]string
+		expOut      string
+	}{
+		{
+			description: "answers prompts",
+			command:     `read a; read b; echo "$a-$b"`,
+			input:       "yes\nno\n",
+			expOut:      "yes-no\n",
+		},
+		{
+			description: "stdin ends after input",
+			command:     "cat",
+			input:       "yes",
+			expOut:      "yes\n",
+		},
+		{
+			description: "expands variables",
+			command:     "cat",
+			input:       "${WORKSPACE} $TOKEN $$HOME $UNSET.\n",
+			envs:        map[string]string{"TOKEN": "secret"},
+			expOut:      "myworkspace secret $HOME .\n",
+		},
+		{
+			description: "for_each",
+			command:     `read a; echo {}: "$a"`,
+			input:       "yes\n",
+			forEach:     "printf 'a\\nb\\n'",
+			expOut:      "a: yes\nb: yes\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r, ctx := newRunStepRunner(t)
+			ctx.Workspace = "myworkspace"
+			step := valid.Step{
+				StepName:   "run",
+				RunCommand: c.command,
+				Stdin:      c.input,
+				ForEach:    c.forEach,
+				Output:     valid.PostProcessRunOutputShow,
+			}
+			envs := c.envs
+			if envs == nil {
+				envs = map[string]string{}
+			}
+			out, err := r.Run(ctx, step, t.TempDir(), envs, false)
+			Ok(t, err)
+			Equals(t, c.expOut, out)
+		})
+	}
+}
+
+// Test that commands of run steps with no_network can't reach the network,
+// even on the loopback interface, while other commands can.
+func TestRunStepRunner_RunNoNetwork(t *testing.T) {
+	if goruntime.GOOS != "linux" {
+		t.Skip("no_network is only supported on linux")
+	}
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl isn't installed")
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "reached")
+	}))
+	defer srv.Close()
+
+	r, ctx := newRunStepRunner(t)
+	run := func(command string, noNetwork bool) (string, error) {
+		step := valid.Step{
+			StepName:   "run",
+			RunCommand: command,
+			NoNetwork:  noNetwork,
+			Output:     valid.PostProcessRunOutputShow,
+		}
+		return r.Run(ctx, step, t.TempDir(), map[string]string{}, false)
+	}
+	if _, err := run("true", true); err != nil {
+		t.Skipf("network namespaces aren't available: %s", err)
+	}
+
+	out, err := run("curl -sS "+srv.URL, false)
+	Ok(t, err)
+	Equals(t, "reached\n", out)
+
+	out, err = run("curl -sS "+srv.URL, true)
+	ErrContains(t, "exit status", err)
+	Assert(t, !strings.Contains(out, "reached"), "exp no_network command not to reach the server, got %q", out)
+}
+
+// Test that commands of run steps with memory_limit are killed when they use
+// more memory and the step fails with a clear error.
+func TestRunStepRunner_RunResourceLimits(t *testing.T) {
+	if goruntime.GOOS != "linux" {
+		t.Skip("memory_limit and cpu_limit are only supported on linux")
+	}
+	r, ctx := newRunStepRunner(t)
+	run := func(command string) (string, error) {
+		step := valid.Step{
+			StepName:    "run",
+			RunCommand:  command,
+			MemoryLimit: 32 * 1024 * 1024,
+			CPULimit:    0.5,
+			Output:      valid.PostProcessRunOutputShow,
+		}
+		return r.Run(ctx, step, t.TempDir(), map[string]string{}, false)
+	}
+	if _, err := run("true"); err != nil {
+		t.Skipf("cgroups aren't available: %s", err)
+	}
+
+	out, err := run("echo within limits")
+	Ok(t, err)
+	Equals(t, "within limits\n", out)
+
+	// The shell keeps the whole output of the substitution in memory.
+	_, err = run("x=$(head -c 256M /dev/zero | tr '\\0' a)")
+	ErrContains(t, "killed for using more than its memory_limit of 32.0MB", err)
+}
+
+// Test that run steps with restore_dir leave the files in the project
+// directory as they were, whether or not they're tracked by git.
+func TestRunStepRunner_RunRestoreDir(t *testing.T) {
+	for _, inGit := range []bool{false, true} {
+		t.Run(fmt.Sprintf("in git %t", inGit), func(t *testing.T) {
+			repoDir := t.TempDir()
+			path := filepath.Join(repoDir, "project")
+			Ok(t, os.MkdirAll(filepath.Join(path, "modules"), 0700))
+			Ok(t, os.WriteFile(filepath.Join(path, "main.tf"), []byte("main"), 0600))
+			Ok(t, os.WriteFile(filepath.Join(path, "modules", "mod.tf"), []byte("mod"), 0600))
+			if inGit {
+				runCmd(t, repoDir, "git", "init")
+				runCmd(t, repoDir, "git", "add", ".")
+				runCmd(t, repoDir, "git", "-c", "user.name=atlantis", "-c", "user.email=atlantis@example.com", "commit", "-m", "initial")
+			}
+			// An untracked file.
+			Ok(t, os.WriteFile(filepath.Join(path, "local.tfvars"), []byte("local"), 0600))
+			mainInfo, err := os.Stat(filepath.Join(path, "main.tf"))
+			Ok(t, err)
+
+			r, ctx := newRunStepRunner(t)
+			ctx.RepoRelDir = "project"
+			step := valid.Step{
+				StepName:   "run",
+				RunCommand: "echo changed > main.tf && echo changed > local.tfvars && rm -r modules && mkdir tmp && touch tmp/out new.txt && echo done",
+				RestoreDir: true,
+				Output:     valid.PostProcessRunOutputShow,
+			}
+			out, err := r.Run(ctx, step, path, map[string]string{}, false)
+			Ok(t, err)
+			Equals(t, "done\n", out)
+
+			var files []string
+			Ok(t, filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+				Ok(t, err)
+				rel, _ := filepath.Rel(path, p)
+				files = append(files, rel)
+				return nil
+			}))
+			Equals(t, []string{".", "local.tfvars", "main.tf", "modules", "modules/mod.tf"}, files)
+			for file, exp := range map[string]string{"main.tf": "main", "local.tfvars": "local", "modules/mod.tf": "mod"} {
+				contents, err := os.ReadFile(filepath.Join(path, file))
+				Ok(t, err)
+				Equals(t, exp, string(contents))
+			}
+			restoredInfo, err := os.Stat(filepath.Join(path, "main.tf"))
+			Ok(t, err)
+			Equals(t, mainInfo.ModTime(), restoredInfo.ModTime())
+		})
+	}
+}
+
+func TestRunStepRunner_RunRestoreDirLargeFiles(t *testing.T) {
+	cases := []struct {
+		description string
+		command     string
+		expErr      string
+	}{
+		{
+			description: "replaced",
+			command:     "rm .terraform/provider && echo changed > .terraform/provider",
+		},
+		{
+			description: "written in place",
+			command:     "echo changed > .terraform/provider",
+			expErr:      "it was written to in place but only files up to 1048576 bytes are copied before the step",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			path := t.TempDir()
+			Ok(t, os.WriteFile(filepath.Join(path, "main.tf"), []byte("main"), 0600))
+			runCmd(t, path, "git", "init")
+			runCmd(t, path, "git", "add", ".")
+			runCmd(t, path, "git", "-c", "user.name=atlantis", "-c", "user.email=atlantis@example.com", "commit", "-m", "initial")
+			provider := bytes.Repeat([]byte("p"), 2<<20)
+			Ok(t, os.MkdirAll(filepath.Join(path, ".terraform"), 0700))
+			Ok(t, os.WriteFile(filepath.Join(path, ".terraform", "provider"), provider, 0700))
+
+			r, ctx := newRunStepRunner(t)
+			step := valid.Step{
+				StepName:   "run",
+				RunCommand: c.command,
+				RestoreDir: true,
+				Output:     valid.PostProcessRunOutputShow,
+			}
+			_, err := r.Run(ctx, step, path, map[string]string{}, false)
+			if c.expErr != "" {
+				ErrContains(t, c.expErr, err)
+			} else {
+				Ok(t, err)
+				contents, err := os.ReadFile(filepath.Join(path, ".terraform", "provider"))
+				Ok(t, err)
+				Assert(t, bytes.Equal(provider, contents), "exp the provider to be restored")
+			}
+			// The hard links are removed from the git dir.
+			links, err := filepath.Glob(filepath.Join(path, ".git", "atlantis-restore-dir*"))
+			Ok(t, err)
+			Equals(t, 0, len(links))
+		})
+	}
+}
+
+func TestRunStepRunner_RunRender(t *testing.T) {
+	cases := []struct {
+		description string
+		command     string
+		render      valid.RenderOption
+		expOut      string
+	}{
+		{
+			description: "raw",
+			command:     `printf 'a,b\n1,2\n'`,
+			render:      valid.RenderRaw,
+			expOut:      "a,b\n1,2\n",
+		},
+		{
+			description: "CSV table",
+			command:     `printf 'name,count\n"a|b",2\n'`,
+			render:      valid.RenderTable,
+			expOut:      "```\n\n| name | count |\n| --- | --- |\n| a\\|b | 2 |\n\n```diff",
+		},
+		{
+			description: "TSV table",
+			command:     `printf 'name\tcount\na, b\t2\n'`,
+			render:      valid.RenderTable,
+			expOut:      "```\n\n| name | count |\n| --- | --- |\n| a, b | 2 |\n\n```diff",
+		},
+		{
+			description: "invalid table falls back to raw",
+			command:     `printf 'a,b\n1,2,3\n'`,
+			render:      valid.RenderTable,
+			expOut:      "a,b\n1,2,3\n",
+		},
+		{
+			description: "code",
+			command:     `echo hello`,
+			render:      valid.RenderCode,
+			expOut:      "```\n\n```\nhello\n```\n\n```diff",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r, ctx := newRunStepRunner(t)
+			step := valid.Step{
+				StepName:   "run",
+				RunCommand: c.command,
+				Render:     c.render,
+				Output:     valid.PostProcessRunOutputShow,
+			}
+			out, err := r.Run(ctx, step, t.TempDir(), map[string]string{}, false)
+			Ok(t, err)
+			Equals(t, c.expOut, out)
+		})
+	}
+}
+
+func TestRunStepRunner_RunMetricLabel(t *testing.T) {
+	cases := []struct {
+		description string
+		command     string
+		expOut      string
+	}{
+		{
+			description: "integer",
+			command:     "echo 42",
+			expOut:      "```\n\n**coverage:** `42`\n\n```diff",
+		},
+		{
+			description: "percentage with whitespace",
+			command:     "printf '  87.5%%\n\n'",
+			expOut:      "```\n\n**coverage:** `87.5%`\n\n```diff",
+		},
+		{
+			description: "negative",
+			command:     "echo -1.5",
+			expOut:      "```\n\n**coverage:** `-1.5`\n\n```diff",
+		},
+		{
+			description: "not a number falls back to raw",
+			command:     "echo 'coverage: 87%'",
+			expOut:      "coverage: 87%\n",
+		},
+		{
+			description: "NaN falls back to raw",
+			command:     "echo NaN",
+			expOut:      "NaN\n",
+		},
+		{
+			description: "empty falls back to raw",
+			command:     "true",
+			expOut:      "",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r, ctx := newRunStepRunner(t)
+			step := valid.Step{
+				StepName:    "run",
+				RunCommand:  c.command,
+				MetricLabel: "coverage",
+				Output:      valid.PostProcessRunOutputShow,
+			}
+			out, err := r.Run(ctx, step, t.TempDir(), map[string]string{}, false)
+			Ok(t, err)
+			Equals(t, c.expOut, out)
+		})
+	}
+}
+
+// Test that run steps sharing a rate limit stay within it when several
+// projects run them at once, and that other limits aren't affected.
+func TestRunStepRunner_RunRateLimit(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+	r.RateLimiters = runtime.NewRateLimiters()
+	runProjects := func(limit string) time.Duration {
+		rateLimit, err := valid.ParseRateLimit(limit)
+		Ok(t, err)
+		step := valid.Step{
+			StepName:   "run",
+			RunCommand: "echo called",
+			Output:     valid.PostProcessRunOutputShow,
+			RateLimit:  &rateLimit,
+		}
+		start := time.Now()
+		var wg sync.WaitGroup
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ctx := ctx
+				ctx.ProjectName = fmt.Sprintf("project%d", i)
+				out, err := r.Run(ctx, step, t.TempDir(), map[string]string{}, false)
+				Ok(t, err)
+				Equals(t, "called\n", out)
+			}(i)
+		}
+		wg.Wait()
+		return time.Since(start)
+	}
+
+	// The first 2 calls run at once and the other 4 wait for a token each,
+	// which come back every 100ms.
+	elapsed := runProjects("cloud-api:2/200ms")
+	Assert(t, elapsed >= 390*time.Millisecond, "exp 6 calls limited to 2 every 200ms to take at least 400ms, took %s", elapsed)
+
+	elapsed = runProjects("other-api:100/s")
+	Assert(t, elapsed < 390*time.Millisecond, "exp calls under another limit not to wait, took %s", elapsed)
+}
+
+func TestRunStepRunner_RunIf(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+	ctx.CommandName = command.Plan
+	terraform := r.TerraformExecutor.(*mocks.MockClient)
+	planJSON := `{"resource_changes": [
+		{"change": {"actions": ["create"]}},
+		{"change": {"actions": ["delete", "create"]}},
+		{"change": {"actions": ["no-op"]}},
+		{"change": {"actions": ["read"]}}
+	]}`
+	run := func(workspace string, cond string, tmpDir string) string {
+		ifCond, err := valid.ParseStepCondition(cond)
+		Ok(t, err)
+		step := valid.Step{
+			StepName:   "run",
+			RunCommand: "echo ran",
+			Output:     valid.PostProcessRunOutputShow,
+			If:         ifCond,
+		}
+		ctx := ctx
+		ctx.Workspace = workspace
+		out, err := r.Run(ctx, step, tmpDir, map[string]string{}, false)
+		Ok(t, err)
+		return out
+	}
+
+	t.Run("workspace", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		Equals(t, "ran\n", run("prod", "workspace == 'prod' && command == 'plan'", tmpDir))
+		Equals(t, "", run("staging", "workspace == 'prod' && command == 'plan'", tmpDir))
+	})
+
+	t.Run("no plan has no changes", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		Equals(t, "ran\n", run("default", "num_changes == 0", tmpDir))
+	})
+
+	t.Run("changes from show file", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		Ok(t, os.WriteFile(filepath.Join(tmpDir, "default.tfplan"), nil, 0600))
+		Ok(t, os.WriteFile(filepath.Join(tmpDir, "default.json"), []byte(planJSON), 0600))
+		Equals(t, "ran\n", run("default", "num_changes == 2", tmpDir))
+		Equals(t, "", run("default", "num_changes > 10", tmpDir))
+	})
+
+	t.Run("changes from terraform show", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		planFile := filepath.Join(tmpDir, "staging.tfplan")
+		Ok(t, os.WriteFile(planFile, nil, 0600))
+		When(terraform.RunCommandWithVersion(Any[command.ProjectContext](), Eq(tmpDir), Eq([]string{"show", "-json", planFile}),
+			Any[map[string]string](), Any[*version.Version](), Eq("staging"))).ThenReturn(planJSON, nil)
+		Equals(t, "ran\n", run("staging", "num_changes == 2", tmpDir))
+	})
+}
+
+func TestRunStepRunner_RunOnDrift(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+	step := valid.Step{
+		StepName:   "run",
+		RunCommand: "echo drifted",
+		Output:     valid.PostProcessRunOutputShow,
+		OnDrift:    true,
+	}
+
+	cases := []struct {
+		description string
+		planJSON    string
+		expOut      string
+	}{
+		{
+			description: "not planned",
+		},
+		{
+			description: "changes without drift",
+			planJSON:    `{"resource_changes": [{"change": {"actions": ["create"]}}]}`,
+		},
+		{
+			description: "drift refreshed without changes",
+			planJSON:    `{"resource_drift": [{"change": {"actions": ["no-op"]}}, {"change": {"actions": ["read"]}}]}`,
+		},
+		{
+			description: "drift",
+			planJSON:    `{"resource_changes": [{"change": {"actions": ["update"]}}], "resource_drift": [{"change": {"actions": ["update"]}}]}`,
+			expOut:      "drifted\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if c.planJSON != "" {
+				Ok(t, os.WriteFile(filepath.Join(tmpDir, "default.tfplan"), nil, 0600))
+				Ok(t, os.WriteFile(filepath.Join(tmpDir, "default.json"), []byte(c.planJSON), 0600))
+			}
+			out, err := r.Run(ctx, step, tmpDir, map[string]string{}, false)
+			Ok(t, err)
+			Equals(t, c.expOut, out)
+		})
+	}
+
+	t.Run("invalid plan JSON", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		Ok(t, os.WriteFile(filepath.Join(tmpDir, "default.tfplan"), nil, 0600))
+		Ok(t, os.WriteFile(filepath.Join(tmpDir, "default.json"), []byte("not json"), 0600))
+		_, err := r.Run(ctx, step, tmpDir, map[string]string{}, false)
+		ErrContains(t, "detecting drift: parsing plan JSON", err)
+	})
+}
+
+func TestRunStepRunner_RunEnvPassthrough(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("SECRET_TOKEN", "secret")
+	step := valid.Step{
+		StepName:   "run",
+		RunCommand: `echo "region=$AWS_REGION token=$SECRET_TOKEN workspace=$WORKSPACE stack=$STACK"`,
+		Output:     valid.PostProcessRunOutputShow,
+	}
+	cases := []struct {
+		description    string
+		envPassthrough []string
+		exp            string
+	}{
+		{
+			description: "everything passed by default",
+			exp:         "region=us-east-1 token=secret workspace=default stack=web\n",
+		},
+		{
+			description:    "only matching variables passed",
+			envPassthrough: []string{"AWS_*"},
+			exp:            "region=us-east-1 token= workspace=default stack=web\n",
+		},
+		{
+			description:    "nothing passed",
+			envPassthrough: []string{},
+			exp:            "region= token= workspace=default stack=web\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			ctx := ctx
+			ctx.EnvPassthrough = c.envPassthrough
+			out, err := r.Run(ctx, step, t.TempDir(), map[string]string{"STACK": "web"}, false)
+			Ok(t, err)
+			Equals(t, c.exp, out)
+		})
+	}
+}
+
+func TestRunStepRunner_RunCommandPolicy(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+	ctx.RunCommandPolicy = valid.RunCommandPolicy{
+		Allowed: []string{"echo", "touch"},
+		Denied:  []string{"rm"},
+	}
+
+	path := t.TempDir()
+	out, err := r.Run(ctx, valid.Step{StepName: "run", RunCommand: "touch ran && echo ok"}, path, nil, false)
+	Ok(t, err)
+	Equals(t, "ok\n", out)
+
+	_, err = r.Run(ctx, valid.Step{StepName: "run", RunCommand: "echo ok; rm ran"}, path, nil, false)
+	ErrEquals(t, `not running "echo ok; rm ran": "rm" is denied by server-side config 'denied_run_commands'`, err)
+	_, err = r.Run(ctx, valid.Step{StepName: "run", RunCommand: "echo ok", Always: "cat ran"}, path, nil, false)
+	ErrEquals(t, `not running "cat ran": "cat" is not allowed: server-side config 'allowed_run_commands' only allows [echo,touch]`, err)
+	_, err = r.Run(ctx, valid.Step{StepName: "run", RunCommand: "echo ok", OutputFilter: "rm ran"}, path, nil, false)
+	ErrEquals(t, `not running "rm ran": "rm" is denied by server-side config 'denied_run_commands'`, err)
+	_, err = r.Run(ctx, valid.Step{StepName: "run", RunCommand: "echo ok"}, path, map[string]string{"PATH": path}, false)
+	ErrEquals(t, `not running "echo ok": setting PATH is not allowed when server-side config 'allowed_run_commands' or 'denied_run_commands' is set`, err)
+	_, err = os.Stat(filepath.Join(path, "ran"))
+	Ok(t, err)
+}
+
+// Test that run steps with require_clean_after fail if they change files in
+// the repo, ignoring files that were already changed before they ran.
+func TestRunStepRunner_RunRequireCleanAfter(t *testing.T) {
+	repoDir := t.TempDir()
+	path := filepath.Join(repoDir, "project")
+	Ok(t, os.MkdirAll(path, 0700))
+	Ok(t, os.WriteFile(filepath.Join(path, "main.tf"), []byte("main\n"), 0600))
+	Ok(t, os.WriteFile(filepath.Join(path, ".terraform.lock.hcl"), []byte("lock\n"), 0600))
+	runCmd(t, repoDir, "git", "init")
+	runCmd(t, repoDir, "git", "add", ".")
+	runCmd(t, repoDir, "git", "-c", "user.name=atlantis", "-c", "user.email=atlantis@example.com", "commit", "-m", "initial")
+	// Files changed before the step, ex. by init and plan.
+	Ok(t, os.WriteFile(filepath.Join(path, "default.tfplan"), []byte("plan"), 0600))
+	Ok(t, os.WriteFile(filepath.Join(path, ".terraform.lock.hcl"), []byte("lock updated\n"), 0600))
+
+	r, ctx := newRunStepRunner(t)
+	ctx.RepoRelDir = "project"
+
+	// Regenerating the same contents leaves the repo clean.
+	step := valid.Step{
+		StepName:          "run",
+		RunCommand:        "echo main > main.tf && echo generated",
+		RequireCleanAfter: true,
+		Output:            valid.PostProcessRunOutputShow,
+	}
+	out, err := r.Run(ctx, step, path, map[string]string{}, false)
+	Ok(t, err)
+	Equals(t, "generated\n", out)
+
+	step.RunCommand = "echo new > main.tf && echo new > ../gen.tf && echo more >> .terraform.lock.hcl && echo generated"
+	_, err = r.Run(ctx, step, path, map[string]string{}, false)
+	ErrContains(t, `"echo new > main.tf && echo new > ../gen.tf && echo more >> .terraform.lock.hcl && echo generated" left 3 uncommitted change(s), run it and commit the changes:
+?? gen.tf
+ M project/.terraform.lock.hcl
+ M project/main.tf`, err)
+	ErrContains(t, "generated", err)
+}
+
+func TestRunStepRunner_RunID(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+	r.RunIDEnvVar = "ATLANTIS_RUN_ID"
+	ctx.RunID = "8c1b2d9e"
+	step := valid.Step{
+		StepName:   "run",
+		RunCommand: "echo run $ATLANTIS_RUN_ID",
+		Output:     valid.PostProcessRunOutputShow,
+	}
+	out, err := r.Run(ctx, step, t.TempDir(), map[string]string{}, false)
+	Ok(t, err)
+	Equals(t, "run 8c1b2d9e\n", out)
+
+	// Without a name the ID isn't set.
+	r.RunIDEnvVar = ""
+	out, err = r.Run(ctx, step, t.TempDir(), map[string]string{}, false)
+	Ok(t, err)
+	Equals(t, "run\n", out)
+}
+
+// Test that every env var valid.StepEnvVars says steps can reference without
+// declaring it in env_schema is set.
+func TestRunStepRunner_RunStepEnvVars(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+	r.RunIDEnvVar = valid.DefaultRunIDEnvVar
+	ctx.RunID = "8c1b2d9e"
+	ctx.CLIConfigFile = "/repo/custom.tfrc"
+	step := valid.Step{
+		StepName:   "run",
+		RunCommand: "env",
+		Output:     valid.PostProcessRunOutputShow,
+	}
+	out, err := r.Run(ctx, step, t.TempDir(), map[string]string{}, false)
+	Ok(t, err)
+	for _, name := range append(valid.StepEnvVars, valid.DefaultRunIDEnvVar) {
+		Assert(t, strings.Contains("\n"+out, "\n"+name+"="), "exp %s to be set, got %q", name, out)
+	}
+}
+
+// approveStepCommenter approves the steps waiting for approval as soon as
+// it's asked to.
+type approveStepCommenter struct {
+	approvals *runtime.StepApprovals
+	approver  string
+}
+
+func (c *approveStepCommenter) CreateComment(_ logging.SimpleLogging, repo models.Repo, pullNum int, _ string, _ string) error {
+	go c.approvals.Approve(repo.FullName, pullNum, c.approver, func(runtime.PendingStepApproval) bool { return true })
+	return nil
+}
+
+func (c *approveStepCommenter) BuildApproveStepComment(string, string, string) string {
+	return "atlantis approve-step"
+}
+
+func TestRunStepRunner_RunRequireApproval(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+	ctx.BaseRepo = models.Repo{FullName: "owner/repo"}
+	ctx.Pull = models.PullRequest{Num: 1}
+	ctx.User = models.User{Username: "alice"}
+	step := valid.Step{
+		StepName:        "run",
+		RunCommand:      "echo wiped",
+		Output:          valid.PostProcessRunOutputShow,
+		RequireApproval: true,
+	}
+
+	// Without approvals the step can't be approved so it fails.
+	_, err := r.Run(ctx, step, t.TempDir(), map[string]string{}, false)
+	ErrEquals(t, `"echo wiped" requires approval but the approve-step command isn't allowed, add it to --allow-commands`, err)
+
+	commenter := &approveStepCommenter{approver: "bob"}
+	r.StepApprovals = runtime.NewStepApprovals(commenter, commenter)
+	commenter.approvals = r.StepApprovals
+	out, err := r.Run(ctx, step, t.TempDir(), map[string]string{}, false)
+	Ok(t, err)
+	Equals(t, "wiped\n", out)
+}
+
+func TestRunStepRunner_RunCLIConfig(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+	t.Setenv("TF_CLI_CONFIG_FILE", "/server.tfrc")
+	ctx.CLIConfigFile = "/repo/custom.tfrc"
+	step := valid.Step{
+		StepName:   "run",
+		RunCommand: "echo $TF_CLI_CONFIG_FILE",
+		Output:     valid.PostProcessRunOutputShow,
+	}
+	out, err := r.Run(ctx, step, t.TempDir(), map[string]string{}, false)
+	Ok(t, err)
+	Equals(t, "/repo/custom.tfrc\n", out)
+
+	// Without a cli_config the server's is used.
+	ctx.CLIConfigFile = ""
+	out, err = r.Run(ctx, step, t.TempDir(), map[string]string{}, false)
+	Ok(t, err)
+	Equals(t, "/server.tfrc\n", out)
+}
+
+func TestRunStepRunner_RunRequiresProjectsSuccess(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+	outcomes := command.NewProjectOutcomes()
+	outcomes.Record(command.ProjectResult{ProjectName: "network", PlanSuccess: &models.PlanSuccess{}})
+	outcomes.Record(command.ProjectResult{ProjectName: "dns", Error: errors.New("err")})
+	ctx.ProjectOutcomes = outcomes
+
+	cases := []struct {
+		description string
+		projects    []string
+		expOut      string
+	}{
+		{
+			description: "required projects succeeded",
+			projects:    []string{"network"},
+			expOut:      "aggregated\n",
+		},
+		{
+			description: "required project failed",
+			projects:    []string{"network", "dns"},
+			expOut:      "Skipped `echo aggregated` since these projects didn't succeed in this run: dns.\n",
+		},
+		{
+			description: "required project didn't run",
+			projects:    []string{"app"},
+			expOut:      "Skipped `echo aggregated` since these projects didn't succeed in this run: app.\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			step := valid.Step{
+				StepName:                "run",
+				RunCommand:              "echo aggregated",
+				Output:                  valid.PostProcessRunOutputShow,
+				RequiresProjectsSuccess: c.projects,
+			}
+			out, err := r.Run(ctx, step, t.TempDir(), map[string]string{}, false)
+			Ok(t, err)
+			Equals(t, c.expOut, out)
+		})
+	}
+}
+
+func TestRunStepRunner_RunLinePrefix(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+
+	out, err := r.Run(ctx, valid.Step{
+		StepName:   "run",
+		RunCommand: "printf 'one\\n\\nthree\\n'",
+		Output:     valid.PostProcessRunOutputShow,
+		LinePrefix: "[lint] ",
+	}, t.TempDir(), map[string]string{}, false)
+	Ok(t, err)
+	Equals(t, "[lint] one\n[lint] \n[lint] three\n", out)
+
+	_, err = r.Run(ctx, valid.Step{
+		StepName:   "run",
+		RunCommand: "echo failed && exit 1",
+		Output:     valid.PostProcessRunOutputShow,
+		LinePrefix: "[lint] ",
+	}, t.TempDir(), map[string]string{}, false)
+	ErrContains(t, "\n[lint] failed\n", err)
+}
+
+func TestRunStepRunner_RunOutputFilter(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+
+	cases := []struct {
+		description string
+		command     string
+		filter      string
+		expOut      string
+		expErr      string
+	}{
+		{
+			description: "filters output",
+			command:     "printf 'DEBUG start\\nbuilt\\nDEBUG end\\n'",
+			filter:      "grep -v DEBUG",
+			expOut:      "built\n",
+		},
+		{
+			description: "runs with the step's env",
+			command:     "echo built",
+			filter:      "sed \"s/^/$WORKSPACE: /\"",
+			expOut:      "default: built\n",
+		},
+		{
+			description: "failing filter shows unfiltered output",
+			command:     "echo built",
+			filter:      "echo bad filter >&2 && exit 3",
+			expOut:      "built\n\noutput_filter failed, showing unfiltered output: running \"echo bad filter >&2 && exit 3\" in \"DIR\": exit status 3\nbad filter\n",
+		},
+		{
+			description: "filter doesn't change the step's failure",
+			command:     "echo DEBUG && echo failed && exit 1",
+			filter:      "grep -v DEBUG",
+			expErr:      "exit status 1: running \"echo DEBUG && echo failed && exit 1\" in",
+		},
+		{
+			description: "filter failing doesn't fail the step",
+			command:     "echo DEBUG",
+			filter:      "grep -v DEBUG",
+			expOut:      "DEBUG\n\noutput_filter failed, showing unfiltered output: running \"grep -v DEBUG\" in \"DIR\": exit status 1\n",
+		},
+		{
+			description: "empty output",
+			command:     "true",
+			filter:      "grep -v DEBUG",
+			expOut:      "",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			dir := t.TempDir()
+			out, err := r.Run(ctx, valid.Step{
+				StepName:     "run",
+				RunCommand:   c.command,
+				Output:       valid.PostProcessRunOutputShow,
+				OutputFilter: c.filter,
+			}, dir, map[string]string{}, false)
+			if c.expErr != "" {
+				ErrContains(t, c.expErr, err)
+				Assert(t, !strings.Contains(err.Error(), "\nDEBUG"), "exp output in error to be filtered, got %q", err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, strings.ReplaceAll(c.expOut, "DIR", dir), out)
+		})
+	}
+}
+
+func TestLinePrefixedSecrets(t *testing.T) {
+	Equals(t, []string{"single", "first\nsecond", "first\n[x] second"}, runtime.LinePrefixedSecrets([]string{"single", "first\nsecond"}, "[x] "))
+}
+
+func TestRunStepRunner_RunCache(t *testing.T) {
+	r, ctx := newRunStepRunner(t)
+	r.StepCache = runtime.NewStepCache(t.TempDir(), 1024*1024)
+	ctx.BaseRepo = models.Repo{FullName: "owner/repo"}
+	step := valid.Step{
+		StepName:   "run",
+		RunCommand: "if [ -f deps/pkg ]; then echo reused; else mkdir deps && echo pkg > deps/pkg && echo downloaded; fi",
+		Output:     valid.PostProcessRunOutputShow,
+		Cache:      &valid.StepCache{Key: `deps-{{ .Checksum "lock.json" }}`, Paths: []string{"deps"}},
+	}
+	newProjectDir := func(lock string) string {
+		dir := t.TempDir()
+		Ok(t, os.WriteFile(filepath.Join(dir, "lock.json"), []byte(lock), 0600))
+		return dir
+	}
+	sum := sha256.Sum256([]byte("v1"))
+	key := "deps-" + hex.EncodeToString(sum[:])
+
+	out, err := r.Run(ctx, step, newProjectDir("v1"), map[string]string{}, false)
+	Ok(t, err)
+	Equals(t, fmt.Sprintf("Cache miss for key %q, saved deps.\ndownloaded\n", key), out)
+
+	// A fresh clone with the same lock file restores the saved deps.
+	path := newProjectDir("v1")
+	out, err = r.Run(ctx, step, path, map[string]string{}, false)
+	Ok(t, err)
+	Equals(t, fmt.Sprintf("Cache hit for key %q, restored deps.\nreused\n", key), out)
+	contents, err := os.ReadFile(filepath.Join(path, "deps", "pkg"))
+	Ok(t, err)
+	Equals(t, "pkg\n", string(contents))
+
+	// A different lock file is a different key.
+	out, err = r.Run(ctx, step, newProjectDir("v2"), map[string]string{}, false)
+	Ok(t, err)
+	Assert(t, strings.HasPrefix(out, "Cache miss for key"), "exp cache miss but got %q", out)
+
+	// Entries aren't shared between pull requests, so one can't poison the
+	// cache of another.
+	ctx.Pull.Num = 2
+	out, err = r.Run(ctx, step, newProjectDir("v1"), map[string]string{}, false)
+	Ok(t, err)
+	Assert(t, strings.HasPrefix(out, "Cache miss for key"), "exp cache miss but got %q", out)
+
+	// Entries aren't shared between repos.
+	ctx.BaseRepo.FullName = "owner/other"
+	out, err = r.Run(ctx, step, newProjectDir("v1"), map[string]string{}, false)
+	Ok(t, err)
+	Assert(t, strings.HasPrefix(out, "Cache miss for key"), "exp cache miss but got %q", out)
+
+	// Keys can use the Sprig functions available to step templates.
+	step.Cache.Key = `{{ .ProjectName | default "deps" }}-{{ .Checksum "lock.json" | trunc 8 }}`
+	out, err = r.Run(ctx, step, newProjectDir("v1"), map[string]string{}, false)
+	Ok(t, err)
+	Equals(t, fmt.Sprintf("Cache miss for key %q, saved deps.\ndownloaded\n", key[:len("deps-")+8]), out)
+
+	// A key that can't be rendered fails the step.
+	step.Cache.Key = `deps-{{ .Checksum "missing.json" }}`
+	_, err = r.Run(ctx, step, newProjectDir("v1"), map[string]string{}, false)
+	ErrContains(t, "restoring cache:", err)
+	ErrContains(t, "missing.json", err)
+
+	// Checksums can't read files outside the project directory.
+	step.Cache.Key = `deps-{{ .Checksum "../secret" }}`
+	_, err = r.Run(ctx, step, newProjectDir("v1"), map[string]string{}, false)
+	ErrContains(t, `"../secret" must be inside the project directory`, err)
+	outside := filepath.Join(t.TempDir(), "secret")
+	Ok(t, os.WriteFile(outside, []byte("secret"), 0600))
+	path = newProjectDir("v1")
+	Ok(t, os.Symlink(outside, filepath.Join(path, "link.json")))
+	step.Cache.Key = `deps-{{ .Checksum "link.json" }}`
+	_, err = r.Run(ctx, step, path, map[string]string{}, false)
+	ErrContains(t, `"link.json" must be inside the project directory`, err)
+}
+
+// fakeBaseCloner "clones" the base branch by writing files to dir.
+type fakeBaseCloner struct {
+	dir   string
+	files map[string]string
+	err   error
+}
+
+func (f *fakeBaseCloner) CloneBase(_ logging.SimpleLogging, _ models.PullRequest) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	for name, content := range f.files {
+		path := filepath.Join(f.dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			return "", err
+		}
+	}
+	return f.dir, nil
+}
+
+func TestRunStepRunner_RunDiffAgainstBase(t *testing.T) {
+	cases := []struct {
+		description string
+		baseFiles   map[string]string
+		cloneErr    error
+		expOut      string
+	}{
+		{
+			description: "output differs",
+			baseFiles:   map[string]string{"proj/out.txt": "a\nb\n"},
+			expOut:      "--- main\n+++ pull request\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+		},
+		{
+			description: "output unchanged",
+			baseFiles:   map[string]string{"proj/out.txt": "a\r\nc\n"},
+			expOut:      "No changes in the output compared to the base branch `main`.\n",
+		},
+		{
+			description: "clone fails",
+			cloneErr:    errors.New("boom"),
+			expOut:      "Couldn't diff against the base branch `main`: cloning it failed: boom. Showing the full output.\n\na\nc\n",
+		},
+		{
+			description: "project doesn't exist on base",
+			baseFiles:   map[string]string{"other/out.txt": "a\n"},
+			expOut:      "Couldn't diff against the base branch `main`: the project's directory \"proj\" doesn't exist on it. Showing the full output.\n\na\nc\n",
+		},
+		{
+			description: "command fails on base",
+			baseFiles:   map[string]string{"proj/other.txt": "a\n"},
+			expOut:      "Couldn't diff against the base branch `main`: `cat out.txt` failed on it: running \"cat out.txt\" in \"BASE/proj\": exit status 1. Showing the full output.\n\na\nc\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			baseDir := filepath.Join(t.TempDir(), "base")
+			r, ctx := newRunStepRunner(t)
+			r.BaseCloner = &fakeBaseCloner{dir: baseDir, files: c.baseFiles, err: c.cloneErr}
+			ctx.RepoRelDir = "proj"
+			ctx.Pull = models.PullRequest{BaseBranch: "main"}
+			path := t.TempDir()
+			Ok(t, os.WriteFile(filepath.Join(path, "out.txt"), []byte("a\nc\n"), 0600))
+			step := valid.Step{
+				StepName:        "run",
+				RunCommand:      "cat out.txt",
+				DiffAgainstBase: true,
+				Output:          valid.PostProcessRunOutputShow,
+			}
+			out, err := r.Run(ctx, step, path, map[string]string{}, false)
+			Ok(t, err)
+			Equals(t, strings.ReplaceAll(c.expOut, "BASE", baseDir), out)
+			_, err = os.Stat(baseDir)
+			Assert(t, os.IsNotExist(err), "expected the base clone to be deleted")
+		})
+	}
+}
+
+// Test that the command run on the base branch sees the plan files of the
+// base clone instead of the pull request's.
+func TestRunStepRunner_RunDiffAgainstBasePlanFile(t *testing.T) {
+	baseDir := filepath.Join(t.TempDir(), "base")
+	r, ctx := newRunStepRunner(t)
+	r.BaseCloner = &fakeBaseCloner{dir: baseDir, files: map[string]string{"proj/default.tfplan": "base\n"}}
+	ctx.RepoRelDir = "proj"
+	ctx.Pull = models.PullRequest{BaseBranch: "main"}
+	path := t.TempDir()
+	Ok(t, os.WriteFile(filepath.Join(path, "default.tfplan"), []byte("pull\n"), 0600))
+	step := valid.Step{
+		StepName:        "run",
+		RunCommand:      "cat $PLANFILE",
+		DiffAgainstBase: true,
+		Output:          valid.PostProcessRunOutputShow,
+	}
+	out, err := r.Run(ctx, step, path, map[string]string{}, false)
+	Ok(t, err)
+	Equals(t, "--- main\n+++ pull request\n@@ -1 +1 @@\n-base\n+pull\n", out)
+}
@@ -0,0 +1,145 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	runtimemodels "github.com/runatlantis/atlantis/server/core/runtime/models"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/terraform/ansi"
+	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/utils"
+)
+
+// defaultStreamCommentInterval is how often streamed output is flushed to the
+// pull request. VCS hosts rate limit comment edits so we batch output rather
+// than editing the comment for every line.
+const defaultStreamCommentInterval = 10 * time.Second
+
+// streamCommentMaxOutput is the maximum length of output in a streamed
+// comment. If the output is longer only its tail is shown.
+const streamCommentMaxOutput = 60000
+
+// minMaskedValueLength is the minimum length of an environment variable value
+// that is masked in streamed output. Shorter values would mask too much
+// unrelated output.
+const minMaskedValueLength = 4
+
+// PullCommentUpdater brings the comment editing methods of vcs.Client into this
+// package without causing circular imports.
+type PullCommentUpdater interface {
+	// CreateUpdatableComment creates a comment and returns its ID.
+	CreateUpdatableComment(logger logging.SimpleLogging, repo models.Repo, pullNum int, comment string) (int64, error)
+	// UpdateComment replaces the body of the comment with commentID.
+	UpdateComment(logger logging.SimpleLogging, repo models.Repo, pullNum int, commentID int64, comment string) error
+}
+
+// runStreamed runs the step's command and edits a pull request comment with the
+// output so far every StreamCommentInterval, and once more when it finishes.
+// Values of envs are masked in the comment since they're often set from
+// secrets by env and multienv steps.
+func (r *RunStepRunner) runStreamed(ctx command.ProjectContext, runner *runtimemodels.ShellCommandRunner, step valid.Step, envs map[string]string) (string, error) {
+	interval := r.StreamCommentInterval
+	if interval == 0 {
+		interval = defaultStreamCommentInterval
+	}
+	stream := &pullCommentStream{
+		updater: r.PullCommentUpdater,
+		ctx:     ctx,
+		header:  fmt.Sprintf("`%s` in dir: `%s` workspace: `%s`", step.RunCommand, ctx.RepoRelDir, ctx.Workspace),
+		masked:  maskedValues(envs),
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	_, outCh := runner.RunCommandAsync(ctx)
+	outbuf := new(strings.Builder)
+	pending := false
+	var err error
+loop:
+	for {
+		select {
+		case line, ok := <-outCh:
+			if !ok {
+				break loop
+			}
+			if line.Err != nil {
+				err = line.Err
+				break loop
+			}
+			outbuf.WriteString(line.Line)
+			outbuf.WriteString("\n")
+			pending = true
+		case <-ticker.C:
+			if pending {
+				stream.update(outbuf.String(), "Running")
+				pending = false
+			}
+		}
+	}
+
+	output := ansi.Strip(outbuf.String())
+	status := "Finished"
+	if err != nil {
+		status = "Failed"
+	}
+	stream.update(output, status)
+	return output, err
+}
+
+// pullCommentStream edits a single pull request comment with a step's output.
+type pullCommentStream struct {
+	updater   PullCommentUpdater
+	ctx       command.ProjectContext
+	header    string
+	masked    []string
+	commentID int64
+	disabled  bool
+}
+
+// update creates the comment on the first call and edits it afterwards. If the
+// VCS host doesn't support editing comments, or a request fails, streaming is
+// disabled for the rest of the step so we don't hammer the API.
+func (s *pullCommentStream) update(output string, status string) {
+	if s.disabled {
+		return
+	}
+	comment := s.render(output, status)
+	var err error
+	if s.commentID == 0 {
+		s.commentID, err = s.updater.CreateUpdatableComment(s.ctx.Log, s.ctx.Pull.BaseRepo, s.ctx.Pull.Num, comment)
+	} else {
+		err = s.updater.UpdateComment(s.ctx.Log, s.ctx.Pull.BaseRepo, s.ctx.Pull.Num, s.commentID, comment)
+	}
+	if err != nil {
+		s.ctx.Log.Warn("unable to stream run step output to pull request, disabling streaming for this step: %s", err)
+		s.disabled = true
+	}
+}
+
+func (s *pullCommentStream) render(output string, status string) string {
+	output = ansi.Strip(output)
+	if len(output) > streamCommentMaxOutput {
+		output = output[len(output)-streamCommentMaxOutput:]
+		if i := strings.Index(output, "\n"); i >= 0 {
+			output = output[i+1:]
+		}
+		output = "...\n" + output
+	}
+	return utils.MaskSecrets(fmt.Sprintf("**%s** %s\n```\n%s```", status, s.header, output), s.masked)
+}
+
+// maskedValues returns the values of envs that should be masked.
+func maskedValues(envs map[string]string) []string {
+	var values []string
+	for _, v := range envs {
+		if MaskableValue(v) {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,75 @@
+package runtime
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+)
+
+// verifyChecksum returns an error if the file of verify, relative to path,
+// doesn't have its checksum.
+func verifyChecksum(path string, verify valid.StepVerify) error {
+	expected := verify.Checksum
+	if verify.ChecksumFile != "" {
+		var err error
+		expected, err = readChecksumFile(filepath.Join(path, verify.ChecksumFile), verify.File)
+		if err != nil {
+			return fmt.Errorf("reading checksum of %q: %w", verify.File, err)
+		}
+	}
+
+	var h hash.Hash
+	switch verify.Algorithm {
+	case valid.ChecksumSHA512:
+		h = sha512.New()
+	default:
+		h = sha256.New()
+	}
+	f, err := os.Open(filepath.Join(path, verify.File)) // nolint: gosec
+	if err != nil {
+		return fmt.Errorf("verifying checksum of %q: %w", verify.File, err)
+	}
+	defer f.Close() // nolint: errcheck
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("verifying checksum of %q: %w", verify.File, err)
+	}
+	actual := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("checksum mismatch for %q: expected %s %s, got %s", verify.File, verify.Algorithm, expected, actual)
+	}
+	return nil
+}
+
+// readChecksumFile returns the checksum of file in checksumFile. It either
+// only contains the checksum or is in the format output by sha256sum, ex.
+// "<checksum>  <file>" on each line, in which case the line for file is used.
+func readChecksumFile(checksumFile string, file string) (string, error) {
+	content, err := os.ReadFile(checksumFile) // nolint: gosec
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) == 1 && len(strings.Fields(lines[0])) == 1 {
+		return strings.TrimSpace(lines[0]), nil
+	}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		// sha256sum prefixes names with * in binary mode.
+		name := strings.TrimPrefix(fields[1], "*")
+		if filepath.Clean(name) == filepath.Clean(file) || name == filepath.Base(file) {
+			return fields[0], nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %q in %q", file, filepath.Base(checksumFile))
+}
@@ -0,0 +1,151 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/command"
+)
+
+// ssmSecureStringType is the type of SSM parameters that are encrypted. Their
+// values are masked in step output.
+const ssmSecureStringType = string(ssmtypes.ParameterTypeSecureString)
+
+// ssmMaxResults is how many parameters are requested per page, the most SSM
+// allows.
+const ssmMaxResults = 10
+
+// invalidEnvNameChars matches characters that can't be used in an environment
+// variable name.
+var invalidEnvNameChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// SSMParameter is a parameter stored in AWS SSM Parameter Store.
+type SSMParameter struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+// SSMParameterGetter gets parameters from AWS SSM Parameter Store.
+type SSMParameterGetter interface {
+	// GetParametersByPath returns the decrypted parameters under path,
+	// including those nested in paths below it.
+	GetParametersByPath(ctx command.ProjectContext, path string) ([]SSMParameter, error)
+}
+
+// AWSSSMParameterGetter gets SSM parameters with the AWS SDK, using the
+// server's AWS credentials.
+type AWSSSMParameterGetter struct {
+	// Client is used for SSM. If it's nil, a client is created from the
+	// server's AWS config the first time parameters are read.
+	Client SSMClient
+
+	clientOnce sync.Once
+	clientErr  error
+}
+
+// SSMClient is the part of the AWS SSM API AWSSSMParameterGetter uses. It's
+// implemented by *ssm.Client.
+type SSMClient interface {
+	GetParametersByPath(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error)
+}
+
+// GetParametersByPath implements SSMParameterGetter. It reads every page of
+// parameters.
+func (a *AWSSSMParameterGetter) GetParametersByPath(ctx command.ProjectContext, path string) ([]SSMParameter, error) {
+	a.clientOnce.Do(func() {
+		if a.Client != nil {
+			return
+		}
+		cfg, err := awsconfig.LoadDefaultConfig(context.Background())
+		if err != nil {
+			a.clientErr = errors.Wrap(err, "loading AWS config")
+			return
+		}
+		a.Client = ssm.NewFromConfig(cfg)
+	})
+	if a.clientErr != nil {
+		return nil, a.clientErr
+	}
+
+	var params []SSMParameter
+	pages := ssm.NewGetParametersByPathPaginator(a.Client, &ssm.GetParametersByPathInput{
+		Path:           aws.String(path),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(true),
+		MaxResults:     aws.Int32(ssmMaxResults),
+	})
+	for pages.HasMorePages() {
+		if len(params) > 0 {
+			ctx.Log.Debug("fetching next page of SSM parameters under %q", path)
+		}
+		page, err := pages.NextPage(context.Background())
+		if err != nil {
+			return nil, errors.Wrap(err, "getting SSM parameters by path")
+		}
+		for _, p := range page.Parameters {
+			params = append(params, SSMParameter{
+				Name:  aws.ToString(p.Name),
+				Type:  string(p.Type),
+				Value: aws.ToString(p.Value),
+			})
+		}
+	}
+	return params, nil
+}
+
+// SSMEnvStepRunner sets environment variables from every parameter under an
+// AWS SSM Parameter Store path.
+type SSMEnvStepRunner struct {
+	ParameterGetter SSMParameterGetter
+}
+
+// Run loads the parameters under ssmPath into envs. Each variable is named
+// after the last segment of the parameter's name, uppercased. It errors if
+// parameters in different paths below ssmPath would set the same variable.
+// It returns the values of SecureString parameters so they can be masked in
+// step output.
+func (r *SSMEnvStepRunner) Run(ctx command.ProjectContext, ssmPath string, envs map[string]string) ([]string, error) {
+	params, err := r.ParameterGetter.GetParametersByPath(ctx, ssmPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading SSM parameters under %q: %w", ssmPath, err)
+	}
+	if len(params) == 0 {
+		return nil, fmt.Errorf("no SSM parameters found under %q", ssmPath)
+	}
+
+	var secrets []string
+	names := make([]string, 0, len(params))
+	paramNames := make(map[string]string, len(params))
+	for _, param := range params {
+		name := ssmEnvName(param.Name)
+		if other, ok := paramNames[name]; ok {
+			return nil, fmt.Errorf("SSM parameters %q and %q under %q both set %s", other, param.Name, ssmPath, name)
+		}
+		paramNames[name] = param.Name
+		envs[name] = param.Value
+		names = append(names, name)
+		if param.Type == ssmSecureStringType && param.Value != "" {
+			secrets = append(secrets, param.Value)
+		}
+	}
+	sort.Strings(names)
+	ctx.Log.Debug("set environment variables %s from SSM parameters under %q", strings.Join(names, ","), ssmPath)
+	return secrets, nil
+}
+
+// ssmEnvName returns the environment variable name for the SSM parameter
+// named paramName, ex. /app/prod/db-password becomes DB_PASSWORD.
+func ssmEnvName(paramName string) string {
+	return invalidEnvNameChars.ReplaceAllString(strings.ToUpper(path.Base(paramName)), "_")
+}
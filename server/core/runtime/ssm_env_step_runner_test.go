@@ -0,0 +1,142 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+type fakeSSMParameterGetter struct {
+	params []runtime.SSMParameter
+	err    error
+}
+
+func (f *fakeSSMParameterGetter) GetParametersByPath(_ command.ProjectContext, _ string) ([]runtime.SSMParameter, error) {
+	return f.params, f.err
+}
+
+func TestSSMEnvStepRunner_Run(t *testing.T) {
+	cases := []struct {
+		description string
+		getter      *fakeSSMParameterGetter
+		expEnvs     map[string]string
+		expSecrets  []string
+		expErr      string
+	}{
+		{
+			description: "sets envs and returns secure values",
+			getter: &fakeSSMParameterGetter{
+				params: []runtime.SSMParameter{
+					{Name: "/app/prod/region", Type: "String", Value: "us-east-1"},
+					{Name: "/app/prod/db-password", Type: "SecureString", Value: "hunter2"},
+				},
+			},
+			expEnvs: map[string]string{
+				"REGION":      "us-east-1",
+				"DB_PASSWORD": "hunter2",
+			},
+			expSecrets: []string{"hunter2"},
+		},
+		{
+			description: "nested parameters",
+			getter: &fakeSSMParameterGetter{
+				params: []runtime.SSMParameter{
+					{Name: "/app/prod/region", Type: "String", Value: "us-east-1"},
+					{Name: "/app/prod/db/host", Type: "String", Value: "db.internal"},
+				},
+			},
+			expEnvs: map[string]string{
+				"REGION": "us-east-1",
+				"HOST":   "db.internal",
+			},
+		},
+		{
+			description: "same name in different paths",
+			getter: &fakeSSMParameterGetter{
+				params: []runtime.SSMParameter{
+					{Name: "/app/prod/db/password", Type: "SecureString", Value: "hunter2"},
+					{Name: "/app/prod/cache/password", Type: "SecureString", Value: "hunter3"},
+				},
+			},
+			expEnvs: map[string]string{
+				"PASSWORD": "hunter2",
+			},
+			expErr: "SSM parameters \"/app/prod/db/password\" and \"/app/prod/cache/password\" under \"/app/prod/\" both set PASSWORD",
+		},
+		{
+			description: "no parameters",
+			getter:      &fakeSSMParameterGetter{},
+			expEnvs:     map[string]string{},
+			expErr:      "no SSM parameters found under \"/app/prod/\"",
+		},
+		{
+			description: "getter error",
+			getter:      &fakeSSMParameterGetter{err: errors.New("access denied")},
+			expEnvs:     map[string]string{},
+			expErr:      "loading SSM parameters under \"/app/prod/\": access denied",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r := runtime.SSMEnvStepRunner{ParameterGetter: c.getter}
+			envs := map[string]string{}
+			secrets, err := r.Run(command.ProjectContext{Log: logging.NewNoopLogger(t)}, "/app/prod/", envs)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+			} else {
+				Ok(t, err)
+			}
+			Equals(t, c.expEnvs, envs)
+			Equals(t, c.expSecrets, secrets)
+		})
+	}
+}
+
+// fakeSSM returns the parameters under "/app/prod/" in two pages.
+type fakeSSM struct {
+	inputs []ssm.GetParametersByPathInput
+}
+
+func (f *fakeSSM) GetParametersByPath(_ context.Context, params *ssm.GetParametersByPathInput, _ ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error) {
+	f.inputs = append(f.inputs, *params)
+	if aws.ToString(params.Path) != "/app/prod/" {
+		return nil, errors.New("ParameterNotFound")
+	}
+	if aws.ToString(params.NextToken) == "page2" {
+		return &ssm.GetParametersByPathOutput{Parameters: []ssmtypes.Parameter{
+			{Name: aws.String("/app/prod/b"), Type: ssmtypes.ParameterTypeSecureString, Value: aws.String("2")},
+		}}, nil
+	}
+	return &ssm.GetParametersByPathOutput{
+		Parameters: []ssmtypes.Parameter{
+			{Name: aws.String("/app/prod/a"), Type: ssmtypes.ParameterTypeString, Value: aws.String("1")},
+		},
+		NextToken: aws.String("page2"),
+	}, nil
+}
+
+func TestAWSSSMParameterGetter_GetParametersByPath(t *testing.T) {
+	client := &fakeSSM{}
+	getter := runtime.AWSSSMParameterGetter{Client: client}
+	ctx := command.ProjectContext{Log: logging.NewNoopLogger(t)}
+	params, err := getter.GetParametersByPath(ctx, "/app/prod/")
+	Ok(t, err)
+	Equals(t, []runtime.SSMParameter{
+		{Name: "/app/prod/a", Type: "String", Value: "1"},
+		{Name: "/app/prod/b", Type: "SecureString", Value: "2"},
+	}, params)
+	Equals(t, 2, len(client.inputs))
+	Assert(t, aws.ToBool(client.inputs[0].Recursive), "exp nested parameters to be read")
+	Assert(t, aws.ToBool(client.inputs[0].WithDecryption), "exp parameters to be decrypted")
+
+	_, err = getter.GetParametersByPath(ctx, "/other/")
+	ErrContains(t, "ParameterNotFound", err)
+}
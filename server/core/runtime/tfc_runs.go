@@ -0,0 +1,283 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/command"
+)
+
+// DefaultTFCPollInterval is how often a Terraform Cloud/Enterprise run is
+// polled while waiting for it.
+const DefaultTFCPollInterval = 5 * time.Second
+
+// DefaultTFCRunTimeout is how long a Terraform Cloud/Enterprise run is waited
+// for if its workflow has no timeout.
+const DefaultTFCRunTimeout = time.Hour
+
+// TFCRunClient reads and applies Terraform Cloud/Enterprise runs through its
+// API, authenticated with the token Atlantis is configured with.
+type TFCRunClient struct {
+	// Address is the URL of the Terraform Cloud/Enterprise host, ex.
+	// https://app.terraform.io.
+	Address string
+	Token   string
+	// HTTPClient, if set, is used for requests instead of
+	// http.DefaultClient.
+	HTTPClient *http.Client
+	// PollInterval, if set, is used instead of DefaultTFCPollInterval.
+	PollInterval time.Duration
+}
+
+// TFCRun is the state of a Terraform Cloud/Enterprise run.
+type TFCRun struct {
+	ID     string
+	Status string
+	// Confirmable is true if the run is waiting to be applied.
+	Confirmable bool
+	// URL is the link to the run in the Terraform Cloud/Enterprise UI.
+	URL     string
+	ApplyID string
+}
+
+// tfcRunInProgress are the statuses of a run that's still planning, ex.
+// running its policy checks, or applying.
+var tfcRunInProgress = map[string]bool{
+	"pending":             true,
+	"fetching":            true,
+	"fetching_completed":  true,
+	"pre_plan_running":    true,
+	"pre_plan_completed":  true,
+	"queuing":             true,
+	"plan_queued":         true,
+	"planning":            true,
+	"cost_estimating":     true,
+	"policy_checking":     true,
+	"post_plan_running":   true,
+	"confirmed":           true,
+	"queuing_apply":       true,
+	"apply_queued":        true,
+	"pre_apply_running":   true,
+	"pre_apply_completed": true,
+	"applying":            true,
+}
+
+// tfcRunFailed are the statuses of a run that won't be applied.
+var tfcRunFailed = map[string]bool{
+	"errored":        true,
+	"canceled":       true,
+	"force_canceled": true,
+	"discarded":      true,
+}
+
+// savedCloudPlan is the plan file Terraform writes when a project with a cloud
+// block is planned with -out. It only references the run that made the plan.
+type savedCloudPlan struct {
+	RemotePlanFormat int    `json:"remote_plan_format"`
+	RunID            string `json:"run_id"`
+	Hostname         string `json:"hostname"`
+}
+
+// readSavedCloudPlan returns the saved cloud plan at planFile. It returns
+// false if planFile isn't one, ex. it's a local plan.
+func readSavedCloudPlan(planFile string) (savedCloudPlan, bool) {
+	contents, err := os.ReadFile(planFile) // nolint: gosec
+	if err != nil {
+		return savedCloudPlan{}, false
+	}
+	var plan savedCloudPlan
+	if err := json.Unmarshal(contents, &plan); err != nil || plan.RemotePlanFormat != 1 || plan.RunID == "" {
+		return savedCloudPlan{}, false
+	}
+	return plan, true
+}
+
+// Handles returns true if plan was made on c's host so c can read its run.
+func (c *TFCRunClient) Handles(plan savedCloudPlan) bool {
+	u, err := url.Parse(c.Address)
+	return err == nil && u.Host == plan.Hostname
+}
+
+// GetRun returns the run with the ID runID.
+func (c *TFCRunClient) GetRun(runID string) (TFCRun, error) {
+	var resp struct {
+		Data struct {
+			ID         string `json:"id"`
+			Attributes struct {
+				Status  string `json:"status"`
+				Actions struct {
+					IsConfirmable bool `json:"is-confirmable"`
+				} `json:"actions"`
+			} `json:"attributes"`
+			Relationships struct {
+				Apply struct {
+					Data *struct {
+						ID string `json:"id"`
+					} `json:"data"`
+				} `json:"apply"`
+			} `json:"relationships"`
+		} `json:"data"`
+		Included []struct {
+			Type       string `json:"type"`
+			Attributes struct {
+				Name string `json:"name"`
+			} `json:"attributes"`
+			Relationships struct {
+				Organization struct {
+					Data struct {
+						ID string `json:"id"`
+					} `json:"data"`
+				} `json:"organization"`
+			} `json:"relationships"`
+		} `json:"included"`
+	}
+	if err := c.do(http.MethodGet, fmt.Sprintf("/api/v2/runs/%s?include=workspace", url.PathEscape(runID)), nil, &resp); err != nil {
+		return TFCRun{}, err
+	}
+	run := TFCRun{
+		ID:          resp.Data.ID,
+		Status:      resp.Data.Attributes.Status,
+		Confirmable: resp.Data.Attributes.Actions.IsConfirmable,
+	}
+	if resp.Data.Relationships.Apply.Data != nil {
+		run.ApplyID = resp.Data.Relationships.Apply.Data.ID
+	}
+	for _, inc := range resp.Included {
+		if inc.Type == "workspaces" {
+			run.URL = fmt.Sprintf("%s/app/%s/workspaces/%s/runs/%s", strings.TrimSuffix(c.Address, "/"),
+				inc.Relationships.Organization.Data.ID, inc.Attributes.Name, run.ID)
+		}
+	}
+	return run, nil
+}
+
+// ApplyRun applies the run with the ID runID, which must be confirmable,
+// with comment.
+func (c *TFCRunClient) ApplyRun(runID string, comment string) error {
+	body, err := json.Marshal(map[string]string{"comment": comment})
+	if err != nil {
+		return err
+	}
+	return c.do(http.MethodPost, fmt.Sprintf("/api/v2/runs/%s/actions/apply", url.PathEscape(runID)), body, nil)
+}
+
+// ApplyLog returns the log of the apply with the ID applyID so far, one line
+// per message.
+func (c *TFCRunClient) ApplyLog(applyID string) ([]string, error) {
+	var resp struct {
+		Data struct {
+			Attributes struct {
+				LogReadURL string `json:"log-read-url"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := c.do(http.MethodGet, fmt.Sprintf("/api/v2/applies/%s", url.PathEscape(applyID)), nil, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Data.Attributes.LogReadURL == "" {
+		return nil, nil
+	}
+	// The log URL is already authorized so the token isn't sent with it.
+	r, err := c.httpClient().Get(resp.Data.Attributes.LogReadURL)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close() // nolint: errcheck
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("reading log of apply %s: %s", applyID, r.Status)
+	}
+	log, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return tfcLogLines(string(log)), nil
+}
+
+// WaitForRun polls the run with the ID runID until it's no longer in
+// progress, or ctx's deadline passes, and returns it. onPoll, if set, is
+// called with the run after every poll.
+func (c *TFCRunClient) WaitForRun(ctx command.ProjectContext, runID string, onPoll func(TFCRun)) (TFCRun, error) {
+	deadline := ctx.Deadline
+	if deadline.IsZero() {
+		deadline = time.Now().Add(DefaultTFCRunTimeout)
+	}
+	interval := c.PollInterval
+	if interval == 0 {
+		interval = DefaultTFCPollInterval
+	}
+	for {
+		run, err := c.GetRun(runID)
+		if err != nil {
+			return TFCRun{}, err
+		}
+		if onPoll != nil {
+			onPoll(run)
+		}
+		if !tfcRunInProgress[run.Status] {
+			return run, nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return run, fmt.Errorf("run %s is still %s after waiting until %s", runID, run.Status, deadline.Format(time.RFC3339))
+		}
+		time.Sleep(interval)
+	}
+}
+
+// do sends a request for path to c's API with body, if set, and decodes the
+// response into out, if set.
+func (c *TFCRunClient) do(method string, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.Address, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Content-Type", "application/vnd.api+json")
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return errors.Wrapf(json.NewDecoder(resp.Body).Decode(out), "decoding response of %s %s", method, path)
+}
+
+func (c *TFCRunClient) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// tfcLogLines returns the lines of a run's log. Terraform writes them as JSON
+// messages, which are replaced by their text, and the log is framed by
+// control characters, which are removed.
+func tfcLogLines(log string) []string {
+	log = strings.NewReplacer("\x02", "", "\x03", "").Replace(log)
+	if log == "" {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(log, "\n"), "\n") {
+		var msg struct {
+			Message *string `json:"@message"`
+		}
+		if strings.HasPrefix(line, "{") && json.Unmarshal([]byte(line), &msg) == nil && msg.Message != nil {
+			line = *msg.Message
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,148 @@
+package runtime_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// fakeTFC is a Terraform Cloud API serving the run run-1 of the workspace
+// ws in the organization org.
+type fakeTFC struct {
+	mu sync.Mutex
+	// statuses are the statuses of the run, the next one is returned by every
+	// read of the run and the last one is repeated.
+	statuses []string
+	// logs are the logs of the run's apply, the next one is returned by every
+	// read of the log and the last one is repeated.
+	logs []string
+	// applyComment is the comment the run was applied with.
+	applyComment string
+	server       *httptest.Server
+}
+
+func newFakeTFC(t *testing.T, statuses []string, logs []string) *fakeTFC {
+	f := &fakeTFC{statuses: statuses, logs: logs}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// client returns a client of f authenticated with token.
+func (f *fakeTFC) client(token string) *runtime.TFCRunClient {
+	return &runtime.TFCRunClient{
+		Address:      f.server.URL,
+		Token:        token,
+		PollInterval: 10 * time.Millisecond,
+	}
+}
+
+// savedPlan returns the saved cloud plan of the run.
+func (f *fakeTFC) savedPlan() string {
+	return fmt.Sprintf(`{"remote_plan_format":1,"run_id":"run-1","hostname":%q}`, strings.TrimPrefix(f.server.URL, "http://"))
+}
+
+func (f *fakeTFC) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/logs/apply-1" {
+		fmt.Fprint(w, next(&f.logs)) // nolint: errcheck
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v2/runs/run-1":
+		status := next(&f.statuses)
+		confirmable := status == "planned" || status == "policy_checked"
+		fmt.Fprintf(w, `{"data":{"id":"run-1","attributes":{"status":%q,"actions":{"is-confirmable":%t}},"relationships":{"apply":{"data":{"id":"apply-1"}}}},`+ // nolint: errcheck
+			`"included":[{"id":"ws-1","type":"workspaces","attributes":{"name":"ws"},"relationships":{"organization":{"data":{"id":"org"}}}}]}`, status, confirmable)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v2/runs/run-1/actions/apply":
+		var body struct {
+			Comment string `json:"comment"`
+		}
+		json.NewDecoder(r.Body).Decode(&body) // nolint: errcheck
+		f.applyComment = body.Comment
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v2/applies/apply-1":
+		fmt.Fprintf(w, `{"data":{"id":"apply-1","attributes":{"log-read-url":%q}}}`, f.server.URL+"/logs/apply-1") // nolint: errcheck
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// next returns the first of values, removing it unless it's the last one.
+func next(values *[]string) string {
+	if len(*values) == 0 {
+		return ""
+	}
+	v := (*values)[0]
+	if len(*values) > 1 {
+		*values = (*values)[1:]
+	}
+	return v
+}
+
+func TestTFCRunClient_WaitForRun(t *testing.T) {
+	tfc := newFakeTFC(t, []string{"pending", "planning", "policy_checking", "policy_checked"}, nil)
+	var polled []string
+	run, err := tfc.client("token").WaitForRun(command.ProjectContext{Log: logging.NewNoopLogger(t)}, "run-1", func(run runtime.TFCRun) {
+		polled = append(polled, run.Status)
+	})
+	Ok(t, err)
+	Equals(t, runtime.TFCRun{
+		ID:          "run-1",
+		Status:      "policy_checked",
+		Confirmable: true,
+		URL:         tfc.server.URL + "/app/org/workspaces/ws/runs/run-1",
+		ApplyID:     "apply-1",
+	}, run)
+	Equals(t, []string{"pending", "planning", "policy_checking", "policy_checked"}, polled)
+}
+
+func TestTFCRunClient_WaitForRunDeadline(t *testing.T) {
+	tfc := newFakeTFC(t, []string{"planning"}, nil)
+	ctx := command.ProjectContext{
+		Log:      logging.NewNoopLogger(t),
+		Deadline: time.Now().Add(100 * time.Millisecond),
+	}
+	_, err := tfc.client("token").WaitForRun(ctx, "run-1", nil)
+	ErrContains(t, "run run-1 is still planning after waiting until", err)
+}
+
+func TestTFCRunClient_Unauthorized(t *testing.T) {
+	tfc := newFakeTFC(t, []string{"planned"}, nil)
+	_, err := tfc.client("wrong").GetRun("run-1")
+	ErrEquals(t, "GET /api/v2/runs/run-1?include=workspace: 401 Unauthorized", err)
+}
+
+func TestTFCRunClient_ApplyLog(t *testing.T) {
+	tfc := newFakeTFC(t, nil, []string{"\x02Terraform v1.6.0\n" +
+		`{"@level":"info","@message":"null_resource.a: Destroying..."}` + "\n" +
+		`{"@level":"info","@message":"Apply complete! Resources: 0 added, 0 changed, 1 destroyed."}` + "\n\x03"})
+	lines, err := tfc.client("token").ApplyLog("apply-1")
+	Ok(t, err)
+	Equals(t, []string{
+		"Terraform v1.6.0",
+		"null_resource.a: Destroying...",
+		"Apply complete! Resources: 0 added, 0 changed, 1 destroyed.",
+	}, lines)
+}
+
+// writeSavedPlan writes the saved cloud plan of tfc's run to planFile.
+func writeSavedPlan(t *testing.T, tfc *fakeTFC, planFile string) {
+	Ok(t, os.WriteFile(planFile, []byte(tfc.savedPlan()), 0600))
+}
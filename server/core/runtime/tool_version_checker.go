@@ -0,0 +1,77 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+)
+
+// toolVersionRegex matches the first version number in the output of
+// `<tool> --version`, ex. 1.6 in "jq-1.6" or 4.40.5 in
+// "yq (https://github.com/mikefarah/yq/) version v4.40.5".
+var toolVersionRegex = regexp.MustCompile(`\d+(?:\.\d+)+`)
+
+// checkRequiredTools returns an actionable error if a tool required by a run
+// step isn't in pathEnv or its version doesn't satisfy the requirement.
+// Versions are checked by running `<tool> --version` in dir with envVars.
+func checkRequiredTools(reqs []valid.ToolRequirement, envVars []string, pathEnv string, dir string) error {
+	for _, req := range reqs {
+		if err := checkRequiredTool(req, envVars, pathEnv, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkRequiredTool(req valid.ToolRequirement, envVars []string, pathEnv string, dir string) error {
+	toolPath := lookPathIn(req.Name, pathEnv)
+	if toolPath == "" {
+		return fmt.Errorf("required tool %q not found in PATH %q", req.Name, pathEnv)
+	}
+	if req.Constraints == nil {
+		return nil
+	}
+
+	cmd := exec.Command(toolPath, "--version") // nolint: gosec
+	cmd.Env = envVars
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("running \"%s --version\" to check required tool %q: %s: %s", req.Name, req.String(), err, strings.TrimSpace(string(out)))
+	}
+	match := toolVersionRegex.Find(out)
+	if match == nil {
+		return fmt.Errorf("unable to find the version of required tool %q in the output of \"%s --version\": %q", req.String(), req.Name, strings.TrimSpace(string(out)))
+	}
+	v, err := version.NewVersion(string(match))
+	if err != nil {
+		return fmt.Errorf("parsing the version of required tool %q: %w", req.String(), err)
+	}
+	if !req.Constraints.Check(v) {
+		return fmt.Errorf("required tool %q found at version %s in %q, install a version matching %q", req.Name, v, toolPath, req.Constraints.String())
+	}
+	return nil
+}
+
+// lookPathIn is like exec.LookPath but searches pathEnv rather than the
+// server's PATH since steps can override it. It returns an empty string if
+// name isn't found.
+func lookPathIn(name string, pathEnv string) string {
+	for _, dir := range filepath.SplitList(pathEnv) {
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+			return path
+		}
+	}
+	return ""
+}
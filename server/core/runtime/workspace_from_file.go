@@ -0,0 +1,35 @@
+package runtime
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ReadWorkspaceFromFile reads the workspace stored in file, a path relative to
+// the project directory path, for a plan step's workspace_from_file. The file
+// must be inside repoDir and contain a valid workspace name, surrounding
+// whitespace is ignored.
+func ReadWorkspaceFromFile(repoDir string, path string, file string) (string, error) {
+	absFile, err := resolveRepoFile(repoDir, path, file, "workspace_from_file")
+	if err != nil {
+		return "", err
+	}
+	contents, err := os.ReadFile(absFile) // nolint: gosec
+	if err != nil {
+		return "", errors.Wrapf(err, "reading workspace_from_file %q", file)
+	}
+	workspace := strings.TrimSpace(string(contents))
+	if workspace == "" {
+		return "", fmt.Errorf("workspace_from_file %q is empty", file)
+	}
+	// Like workspaces in comments, don't allow workspaces that could be used
+	// to escape the workspace's directory.
+	if workspace != url.PathEscape(workspace) || strings.Contains(workspace, "..") {
+		return "", fmt.Errorf("workspace_from_file %q contains invalid workspace %q", file, workspace)
+	}
+	return workspace, nil
+}
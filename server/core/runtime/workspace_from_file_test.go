@@ -0,0 +1,68 @@
+package runtime_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestReadWorkspaceFromFile(t *testing.T) {
+	repoDir := t.TempDir()
+	projDir := filepath.Join(repoDir, "project")
+	Ok(t, os.MkdirAll(projDir, 0700))
+	for file, contents := range map[string]string{
+		"workspace":         "staging\n",
+		"empty":             " \n",
+		"invalid":           "../prod",
+		"../repo-workspace": "prod",
+	} {
+		Ok(t, os.WriteFile(filepath.Join(projDir, file), []byte(contents), 0600))
+	}
+
+	cases := []struct {
+		description  string
+		file         string
+		expWorkspace string
+		expErr       string
+	}{
+		{
+			description:  "workspace with trailing newline",
+			file:         "workspace",
+			expWorkspace: "staging",
+		},
+		{
+			description:  "file elsewhere in the repo",
+			file:         "../repo-workspace",
+			expWorkspace: "prod",
+		},
+		{
+			description: "empty file",
+			file:        "empty",
+			expErr:      "workspace_from_file \"empty\" is empty",
+		},
+		{
+			description: "invalid workspace",
+			file:        "invalid",
+			expErr:      "workspace_from_file \"invalid\" contains invalid workspace \"../prod\"",
+		},
+		{
+			description: "missing file",
+			file:        "missing",
+			expErr:      "reading workspace_from_file \"missing\"",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			workspace, err := runtime.ReadWorkspaceFromFile(repoDir, projDir, c.file)
+			if c.expErr != "" {
+				ErrContains(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.expWorkspace, workspace)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package terraform
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events/command"
+)
+
+// chdirMinVersion is the first Terraform version with the -chdir option.
+var chdirMinVersion = version.Must(version.NewVersion("0.14.0"))
+
+// chdirCmd returns the dir to run terraform in for the project at path and
+// the args to run it with. If the project sets chdir, terraform runs in the
+// repo's root with -chdir set to the project's dir, which Terraform changes to
+// before anything else so paths in args, ex. plan files, are still relative to
+// path. Otherwise it runs in path with args.
+func chdirCmd(ctx command.ProjectContext, v *version.Version, path string, args []string) (string, []string, error) {
+	if !ctx.Chdir || filepath.Clean(ctx.RepoRelDir) == "." {
+		return path, args, nil
+	}
+	if ctx.Engine == valid.TerragruntEngine {
+		return "", nil, fmt.Errorf("chdir can't be used with the %s engine", valid.TerragruntEngine)
+	}
+	if v.LessThan(chdirMinVersion) {
+		return "", nil, fmt.Errorf("chdir needs Terraform %s or later, the project uses %s", chdirMinVersion, v)
+	}
+	path = filepath.Clean(path)
+	relDir := filepath.Clean(ctx.RepoRelDir)
+	if !strings.HasSuffix(path, string(filepath.Separator)+relDir) {
+		return "", nil, fmt.Errorf("chdir: project dir %q isn't in %q", relDir, path)
+	}
+	repoDir := strings.TrimSuffix(path, string(filepath.Separator)+relDir)
+	return repoDir, append([]string{"-chdir=" + relDir}, args...), nil
+}
@@ -0,0 +1,108 @@
+package terraform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+)
+
+// CLIConfigDirName is the name of the dir inside our data dir where the
+// Terraform CLI config files of projects with a provider mirror are written.
+const CLIConfigDirName = "cli-config"
+
+// providerInstallationRegex matches a provider_installation block in a
+// Terraform CLI config.
+var providerInstallationRegex = regexp.MustCompile(`(?m)^\s*provider_installation\s*\{`)
+
+// providerMirrorCLIConfig writes a Terraform CLI config that installs
+// providers from mirror and returns its path, to be set as
+// TF_CLI_CONFIG_FILE. It's the project's CLI config projectFile if set, else
+// the server's own CLI config, ex. with the TFE token and plugin_cache_dir,
+// followed by a provider_installation block so the rest of the config still
+// applies. Files are named by their contents so they're only written once and
+// are shared by every project with the same mirror and config.
+func (c *DefaultClient) providerMirrorCLIConfig(mirror valid.ProviderMirror, projectFile string) (string, error) {
+	var base []byte
+	var err error
+	if projectFile != "" {
+		base, err = os.ReadFile(projectFile) // nolint: gosec
+		if err != nil {
+			return "", fmt.Errorf("reading cli_config: %w", err)
+		}
+		if providerInstallationRegex.Match(base) {
+			return "", errors.New("can't use provider_mirror because the project's cli_config already has a provider_installation block")
+		}
+	} else {
+		var baseFile string
+		base, baseFile, err = serverCLIConfig()
+		if err != nil {
+			return "", err
+		}
+		if providerInstallationRegex.Match(base) {
+			return "", fmt.Errorf("can't use provider_mirror because the server's Terraform CLI config %s already has a provider_installation block", baseFile)
+		}
+	}
+
+	config := string(base)
+	if len(config) > 0 && config[len(config)-1] != '\n' {
+		config += "\n"
+	}
+	if mirror.URL != "" {
+		config += fmt.Sprintf("\nprovider_installation {\n  network_mirror {\n    url = %q\n  }\n}\n", mirror.URL)
+	} else {
+		config += fmt.Sprintf("\nprovider_installation {\n  filesystem_mirror {\n    path = %q\n  }\n}\n", mirror.Path)
+	}
+
+	sum := sha256.Sum256([]byte(config))
+	file := filepath.Join(c.cliConfigDir, fmt.Sprintf("terraformrc-%s", hex.EncodeToString(sum[:8])))
+	if _, err := os.Stat(file); err == nil {
+		return file, nil
+	}
+	if err := os.MkdirAll(c.cliConfigDir, 0700); err != nil {
+		return "", err
+	}
+	// Write to a temp file first so concurrent commands never read a partial
+	// config.
+	tmp, err := os.CreateTemp(c.cliConfigDir, ".tmp-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name()) // nolint: errcheck
+	if _, err := tmp.WriteString(config); err != nil {
+		tmp.Close() // nolint: errcheck
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return file, os.Rename(tmp.Name(), file)
+}
+
+// serverCLIConfig returns the contents and path of the Terraform CLI config
+// the server uses, set by TF_CLI_CONFIG_FILE or ~/.terraformrc. It returns
+// empty contents if there isn't one.
+func serverCLIConfig() ([]byte, string, error) {
+	file := os.Getenv("TF_CLI_CONFIG_FILE")
+	if file == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return nil, "", fmt.Errorf("getting home dir to read ~/.terraformrc file: %w", err)
+		}
+		file = filepath.Join(home, ".terraformrc")
+	}
+	contents, err := os.ReadFile(file) // nolint: gosec
+	if os.IsNotExist(err) {
+		return nil, file, nil
+	}
+	if err != nil {
+		return nil, file, fmt.Errorf("reading Terraform CLI config: %w", err)
+	}
+	return contents, file, nil
+}
@@ -0,0 +1,66 @@
+package terraform
+
+import (
+	"os"
+	"sync"
+
+	"github.com/runatlantis/atlantis/server/events/command"
+)
+
+// pluginCacheLocks serializes terraform init for each plugin cache dir since
+// terraform doesn't support concurrent writes to the cache and parallel
+// inits installing the same provider can corrupt it. Its zero value is ready
+// to use.
+type pluginCacheLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock blocks until no other init is using dir and returns the function that
+// releases it.
+func (p *pluginCacheLocks) lock(dir string) func() {
+	p.mu.Lock()
+	if p.locks == nil {
+		p.locks = make(map[string]*sync.Mutex)
+	}
+	l, ok := p.locks[dir]
+	if !ok {
+		l = &sync.Mutex{}
+		p.locks[dir] = l
+	}
+	p.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// pluginCacheDir returns the plugin cache dir the project's commands use. It's
+// the project's repo's plugin_cache_dir if set, otherwise the dir in our data
+// dir if the plugin cache is enabled. It returns "" if there's no cache.
+func (c *DefaultClient) pluginCacheDir(ctx command.ProjectContext) string {
+	if ctx.PluginCacheDir != "" {
+		return ctx.PluginCacheDir
+	}
+	if c.usePluginCache {
+		return c.terraformPluginCacheDir
+	}
+	return ""
+}
+
+// lockPluginCache makes sure the plugin cache dir exists and, if args is an
+// init, blocks until no other init is using it. It returns the function that
+// releases the cache, which must be called once the command finishes.
+func (c *DefaultClient) lockPluginCache(ctx command.ProjectContext, args []string) (func(), error) {
+	dir := c.pluginCacheDir(ctx)
+	if dir == "" {
+		return func() {}, nil
+	}
+	// Terraform ignores TF_PLUGIN_CACHE_DIR if it doesn't exist.
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || args[0] != "init" {
+		return func() {}, nil
+	}
+	return c.pluginCacheLocks.lock(dir), nil
+}
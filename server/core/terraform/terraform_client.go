@@ -17,6 +17,7 @@
 package terraform
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"net/http"
@@ -37,6 +38,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/warrensbox/terraform-switcher/lib"
 
+	"github.com/runatlantis/atlantis/server/core/config/valid"
 	"github.com/runatlantis/atlantis/server/core/runtime/models"
 	"github.com/runatlantis/atlantis/server/events/command"
 	"github.com/runatlantis/atlantis/server/events/terraform/ansi"
@@ -46,6 +48,10 @@ import (
 
 var LogStreamingValidCmds = [...]string{"init", "plan", "apply"}
 
+// terragruntBinary is the terragrunt executable that's run, from the PATH,
+// for projects whose workflow uses the terragrunt engine.
+const terragruntBinary = "terragrunt"
+
 //go:generate pegomock generate --package mocks -o mocks/mock_terraform_client.go Client
 
 type Client interface {
@@ -89,6 +95,11 @@ type DefaultClient struct {
 
 	// usePluginCache determines whether or not to set the TF_PLUGIN_CACHE_DIR env var
 	usePluginCache bool
+	// pluginCacheLocks serializes inits using the same plugin cache dir.
+	pluginCacheLocks pluginCacheLocks
+	// cliConfigDir is the dir inside our data dir where the CLI configs of
+	// projects with a provider mirror are written.
+	cliConfigDir string
 
 	projectCmdOutputHandler jobs.ProjectCommandOutputHandler
 }
@@ -186,6 +197,8 @@ func NewClientWithDefaultVersion(
 		defaultVersion:          finalDefaultVersion,
 		terraformPluginCacheDir: cacheDir,
 		binDir:                  binDir,
+		// binDir is also inside our data dir.
+		cliConfigDir:            filepath.Join(filepath.Dir(binDir), CLIConfigDirName),
 		downloader:              tfDownloader,
 		downloadBaseURL:         tfDownloadURL,
 		downloadAllowed:         tfDownloadAllowed,
@@ -410,7 +423,12 @@ func (c *DefaultClient) RunCommandWithVersion(ctx command.ProjectContext, path s
 		output = ansi.Strip(output)
 		return fmt.Sprintf("%s\n", output), err
 	}
-	tfCmd, cmd, err := c.prepExecCmd(ctx.Log, v, workspace, path, args)
+	unlock, err := c.lockPluginCache(ctx, args)
+	if err != nil {
+		return "", errors.Wrap(err, "creating plugin cache dir")
+	}
+	defer unlock()
+	tfCmd, cmd, err := c.prepExecCmd(ctx, v, workspace, path, args)
 	if err != nil {
 		return "", err
 	}
@@ -420,7 +438,7 @@ func (c *DefaultClient) RunCommandWithVersion(ctx command.ProjectContext, path s
 	}
 	cmd.Env = envVars
 	start := time.Now()
-	out, err := cmd.CombinedOutput()
+	out, err := combinedOutputWithDeadline(cmd, ctx.Deadline, ctx.Cancelled)
 	dur := time.Since(start)
 	log := ctx.Log.With("duration", dur)
 	if err != nil {
@@ -436,20 +454,22 @@ func (c *DefaultClient) RunCommandWithVersion(ctx command.ProjectContext, path s
 // prepExecCmd builds a ready to execute command based on the version of terraform
 // v, and args. It returns a printable representation of the command that will
 // be run and the actual command.
-func (c *DefaultClient) prepExecCmd(log logging.SimpleLogging, v *version.Version, workspace string, path string, args []string) (string, *exec.Cmd, error) {
-	tfCmd, envVars, err := c.prepCmd(log, v, workspace, path, args)
+func (c *DefaultClient) prepExecCmd(ctx command.ProjectContext, v *version.Version, workspace string, path string, args []string) (string, *exec.Cmd, error) {
+	tfCmd, dir, envVars, err := c.prepCmd(ctx, v, workspace, path, args)
 	if err != nil {
 		return "", nil, err
 	}
 	cmd := exec.Command("sh", "-c", tfCmd)
-	cmd.Dir = path
+	cmd.Dir = dir
 	cmd.Env = envVars
 	return tfCmd, cmd, nil
 }
 
-// prepCmd prepares a shell command (to be interpreted with `sh -c <cmd>`) and set of environment
-// variables for running terraform.
-func (c *DefaultClient) prepCmd(log logging.SimpleLogging, v *version.Version, workspace string, path string, args []string) (string, []string, error) {
+// prepCmd prepares a shell command (to be interpreted with `sh -c <cmd>`), the
+// dir to run it in and set of environment variables for running terraform. If
+// the project's engine is terragrunt then terragrunt is run instead and told
+// to use the terraform binary for version v.
+func (c *DefaultClient) prepCmd(ctx command.ProjectContext, v *version.Version, workspace string, path string, args []string) (string, string, []string, error) {
 	if v == nil {
 		v = c.defaultVersion
 	}
@@ -461,12 +481,16 @@ func (c *DefaultClient) prepCmd(log logging.SimpleLogging, v *version.Version, w
 	} else {
 		var err error
 		c.versionsLock.Lock()
-		binPath, err = ensureVersion(log, c.downloader, c.versions, v, c.binDir, c.downloadBaseURL, c.downloadAllowed)
+		binPath, err = ensureVersion(ctx.Log, c.downloader, c.versions, v, c.binDir, c.downloadBaseURL, c.downloadAllowed)
 		c.versionsLock.Unlock()
 		if err != nil {
-			return "", nil, err
+			return "", "", nil, err
 		}
 	}
+	dir, args, err := chdirCmd(ctx, v, path, args)
+	if err != nil {
+		return "", "", nil, err
+	}
 
 	// We add custom variables so that if `extra_args` is specified with env
 	// vars then they'll be substituted.
@@ -478,14 +502,37 @@ func (c *DefaultClient) prepCmd(log logging.SimpleLogging, v *version.Version, w
 		fmt.Sprintf("ATLANTIS_TERRAFORM_VERSION=%s", v.String()),
 		fmt.Sprintf("DIR=%s", path),
 	}
-	if c.usePluginCache {
-		envVars = append(envVars, fmt.Sprintf("TF_PLUGIN_CACHE_DIR=%s", c.terraformPluginCacheDir))
+	if cacheDir := c.pluginCacheDir(ctx); cacheDir != "" {
+		envVars = append(envVars, fmt.Sprintf("TF_PLUGIN_CACHE_DIR=%s", cacheDir))
+	}
+	if ctx.Engine == valid.TerragruntEngine {
+		envVars = append(envVars,
+			fmt.Sprintf("TERRAGRUNT_TFPATH=%s", binPath),
+			"TERRAGRUNT_NON_INTERACTIVE=true",
+			// Terragrunt logs to stderr which would otherwise be mixed into
+			// output we parse, ex. the JSON from terraform show.
+			"TERRAGRUNT_LOG_LEVEL=error",
+		)
+		binPath = terragruntBinary
 	}
 	// Append current Atlantis process's environment variables, ex.
 	// AWS_ACCESS_KEY.
 	envVars = append(envVars, os.Environ()...)
+	if ctx.ProviderMirror != nil {
+		cliConfig, err := c.providerMirrorCLIConfig(*ctx.ProviderMirror, ctx.CLIConfigFile)
+		if err != nil {
+			return "", "", nil, errors.Wrap(err, "writing Terraform CLI config for provider_mirror")
+		}
+		// After the server's environment so it overrides the server's
+		// TF_CLI_CONFIG_FILE, whose contents it includes.
+		envVars = append(envVars, fmt.Sprintf("TF_CLI_CONFIG_FILE=%s", cliConfig))
+	} else if ctx.CLIConfigFile != "" {
+		// Only set for this command so the server's environment, and so other
+		// projects, keep the server's CLI config.
+		envVars = append(envVars, fmt.Sprintf("TF_CLI_CONFIG_FILE=%s", ctx.CLIConfigFile))
+	}
 	tfCmd := fmt.Sprintf("%s %s", binPath, strings.Join(args, " "))
-	return tfCmd, envVars, nil
+	return tfCmd, dir, envVars, nil
 }
 
 // RunCommandAsync runs terraform with args. It immediately returns an
@@ -495,27 +542,48 @@ func (c *DefaultClient) prepCmd(log logging.SimpleLogging, v *version.Version, w
 // If any error is passed on the out channel, there will be no
 // further output (so callers are free to exit).
 func (c *DefaultClient) RunCommandAsync(ctx command.ProjectContext, path string, args []string, customEnvVars map[string]string, v *version.Version, workspace string) (chan<- string, <-chan models.Line) {
-	cmd, envVars, err := c.prepCmd(ctx.Log, v, workspace, path, args)
+	unlock, err := c.lockPluginCache(ctx, args)
 	if err != nil {
-		// The signature of `RunCommandAsync` doesn't provide for returning an immediate error, only one
-		// once reading the output. Since we won't be spawning a process, simulate that by sending the
-		// errorcustomEnvVars to the output channel.
-		outCh := make(chan models.Line)
-		inCh := make(chan string)
-		go func() {
-			outCh <- models.Line{Err: err}
-			close(outCh)
-			close(inCh)
-		}()
-		return inCh, outCh
+		return asyncErr(errors.Wrap(err, "creating plugin cache dir"))
+	}
+	cmd, dir, envVars, err := c.prepCmd(ctx, v, workspace, path, args)
+	if err != nil {
+		unlock()
+		return asyncErr(err)
 	}
 
 	for key, val := range customEnvVars {
 		envVars = append(envVars, fmt.Sprintf("%s=%s", key, val))
 	}
 
-	runner := models.NewShellCommandRunner(cmd, envVars, path, true, c.projectCmdOutputHandler)
-	inCh, outCh := runner.RunCommandAsync(ctx)
+	runner := models.NewShellCommandRunner(cmd, envVars, dir, true, c.projectCmdOutputHandler)
+	inCh, runnerOutCh := runner.RunCommandAsync(ctx)
+	// Hold the plugin cache until the command finishes, which is when its
+	// output is closed.
+	outCh := make(chan models.Line)
+	go func() {
+		defer close(outCh)
+		defer unlock()
+		for line := range runnerOutCh {
+			outCh <- line
+		}
+	}()
+	return inCh, outCh
+}
+
+// asyncErr returns the channels of RunCommandAsync for a command that failed
+// with err before it started.
+func asyncErr(err error) (chan<- string, <-chan models.Line) {
+	// The signature of `RunCommandAsync` doesn't provide for returning an immediate error, only one
+	// once reading the output. Since we won't be spawning a process, simulate that by sending the
+	// error to the output channel.
+	outCh := make(chan models.Line)
+	inCh := make(chan string)
+	go func() {
+		outCh <- models.Line{Err: err}
+		close(outCh)
+		close(inCh)
+	}()
 	return inCh, outCh
 }
 
@@ -643,3 +711,19 @@ func (d *DefaultDownloader) GetAny(dst, src string) error {
 	_, err := getter.GetAny(context.Background(), dst, src)
 	return err
 }
+
+// combinedOutputWithDeadline runs cmd and returns its combined output. If
+// deadline is set, cmd is killed if it's still running then, and it's
+// killed once cancel is closed.
+func combinedOutputWithDeadline(cmd *exec.Cmd, deadline time.Time, cancel <-chan struct{}) ([]byte, error) {
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	models.PrepareDeadline(cmd, deadline, cancel)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	killer := models.StartDeadline(cmd, deadline, cancel)
+	err := killer.Stop(cmd.Wait())
+	return out.Bytes(), err
+}
@@ -5,10 +5,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	version "github.com/hashicorp/go-version"
 	. "github.com/petergtz/pegomock/v4"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
 	runtimemodels "github.com/runatlantis/atlantis/server/core/runtime/models"
 	"github.com/runatlantis/atlantis/server/events/command"
 	"github.com/runatlantis/atlantis/server/events/models"
@@ -126,6 +128,35 @@ func TestDefaultClient_RunCommandWithVersion_EnvVars(t *testing.T) {
 	Equals(t, exp, out)
 }
 
+// Test that projects using the terragrunt engine run terragrunt with the
+// terraform binary for their version.
+func TestDefaultClient_RunCommandWithVersion_Terragrunt(t *testing.T) {
+	v, err := version.NewVersion("1.5.7")
+	Ok(t, err)
+	tmp := t.TempDir()
+	binDir := filepath.Join(tmp, "bin")
+	Ok(t, os.Mkdir(binDir, 0700))
+	script := "#!/bin/sh\necho \"terragrunt $* TERRAGRUNT_TFPATH=$TERRAGRUNT_TFPATH\"\n"
+	Ok(t, os.WriteFile(filepath.Join(binDir, "terragrunt"), []byte(script), 0700)) // nolint: gosec
+	t.Setenv("PATH", fmt.Sprintf("%s:%s", binDir, os.Getenv("PATH")))
+
+	ctx := command.ProjectContext{
+		Log:        logging.NewNoopLogger(t),
+		Workspace:  "default",
+		RepoRelDir: ".",
+		Engine:     valid.TerragruntEngine,
+	}
+	client := &DefaultClient{
+		defaultVersion:          v,
+		overrideTF:              "/bin/terraform1.5.7",
+		projectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+	}
+
+	out, err := client.RunCommandWithVersion(ctx, tmp, []string{"show", "-json", "plan.tfplan"}, map[string]string{}, nil, "default")
+	Ok(t, err)
+	Equals(t, "terragrunt show -json plan.tfplan TERRAGRUNT_TFPATH=/bin/terraform1.5.7\n", out)
+}
+
 // Test that it returns an error on error.
 func TestDefaultClient_RunCommandWithVersion_Error(t *testing.T) {
 	v, err := version.NewVersion("0.11.11")
@@ -403,3 +434,237 @@ func waitCh(ch <-chan runtimemodels.Line) (string, error) {
 	}
 	return strings.Join(ls, "\n"), nil
 }
+
+// Test that parallel inits using a repo's plugin cache dir download providers
+// once and the other inits reuse them.
+func TestDefaultClient_RunCommandWithVersion_PluginCache(t *testing.T) {
+	v, err := version.NewVersion("1.5.7")
+	Ok(t, err)
+	tmp := t.TempDir()
+	cacheDir := filepath.Join(tmp, "cache")
+	// Pretend to be terraform init downloading a provider to the cache
+	// unless it's already there.
+	tf := filepath.Join(tmp, "terraform")
+	script := `#!/bin/sh
+provider="$TF_PLUGIN_CACHE_DIR/registry.terraform.io/hashicorp/null"
+if [ -e "$provider" ]; then
+  echo "reusing null from $TF_PLUGIN_CACHE_DIR"
+  exit 0
+fi
+echo "downloading null"
+sleep 0.2
+mkdir -p "$(dirname "$provider")"
+echo provider > "$provider"
+`
+	Ok(t, os.WriteFile(tf, []byte(script), 0700)) // nolint: gosec
+
+	client := &DefaultClient{
+		defaultVersion:          v,
+		terraformPluginCacheDir: filepath.Join(tmp, "server-cache"),
+		overrideTF:              tf,
+		usePluginCache:          true,
+		projectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+	}
+	outs := make([]string, 4)
+	var wg sync.WaitGroup
+	for i := range outs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := command.ProjectContext{
+				Log:            logging.NewNoopLogger(t),
+				Workspace:      "default",
+				RepoRelDir:     fmt.Sprintf("project%d", i),
+				PluginCacheDir: cacheDir,
+			}
+			out, err := client.RunCommandWithVersion(ctx, t.TempDir(), []string{"init"}, map[string]string{}, nil, "default")
+			Ok(t, err)
+			outs[i] = out
+		}(i)
+	}
+	wg.Wait()
+
+	downloads := 0
+	for _, out := range outs {
+		if out == "downloading null\n" {
+			downloads++
+		} else {
+			Equals(t, fmt.Sprintf("reusing null from %s\n", cacheDir), out)
+		}
+	}
+	Equals(t, 1, downloads)
+
+	// The server's cache isn't used by the repo.
+	_, err = os.Stat(client.terraformPluginCacheDir)
+	Assert(t, os.IsNotExist(err), "exp server cache not to be created")
+}
+
+// Test that projects setting chdir run terraform from the repo's root with
+// -chdir set to their dir, both for sync and async commands.
+func TestDefaultClient_RunCommandWithVersion_Chdir(t *testing.T) {
+	v, err := version.NewVersion("1.5.7")
+	Ok(t, err)
+	tmp := t.TempDir()
+	tf := filepath.Join(tmp, "terraform")
+	Ok(t, os.WriteFile(tf, []byte("#!/bin/sh\necho \"pwd=$(pwd) args=$* cache=$TF_PLUGIN_CACHE_DIR\"\n"), 0700)) // nolint: gosec
+	repoDir := t.TempDir()
+	projectDir := filepath.Join(repoDir, "envs", "prod")
+	Ok(t, os.MkdirAll(projectDir, 0700))
+	cacheDir := filepath.Join(tmp, "cache")
+
+	client := &DefaultClient{
+		defaultVersion:          v,
+		terraformPluginCacheDir: cacheDir,
+		overrideTF:              tf,
+		usePluginCache:          true,
+		projectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+	}
+	ctx := command.ProjectContext{
+		Log:        logging.NewNoopLogger(t),
+		Workspace:  "default",
+		RepoRelDir: "envs/prod",
+		Chdir:      true,
+	}
+
+	out, err := client.RunCommandWithVersion(ctx, projectDir, []string{"init"}, map[string]string{}, nil, "default")
+	Ok(t, err)
+	Equals(t, fmt.Sprintf("pwd=%s args=-chdir=envs/prod init cache=%s\n", repoDir, cacheDir), out)
+
+	planFile := filepath.Join(projectDir, "default.tfplan")
+	out, err = client.RunCommandWithVersion(ctx, projectDir, []string{"plan", "-out", planFile}, map[string]string{}, nil, "default")
+	Ok(t, err)
+	Equals(t, fmt.Sprintf("pwd=%s args=-chdir=envs/prod plan -out %s cache=%s\n", repoDir, planFile, cacheDir), out)
+
+	// Projects at the repo's root run as usual.
+	rootCtx := ctx
+	rootCtx.RepoRelDir = "."
+	out, err = client.RunCommandWithVersion(rootCtx, repoDir, []string{"init"}, map[string]string{}, nil, "default")
+	Ok(t, err)
+	Equals(t, fmt.Sprintf("pwd=%s args=init cache=%s\n", repoDir, cacheDir), out)
+}
+
+func TestDefaultClient_RunCommandWithVersion_ChdirErrors(t *testing.T) {
+	v, err := version.NewVersion("1.5.7")
+	Ok(t, err)
+	client := &DefaultClient{
+		defaultVersion:          v,
+		overrideTF:              "echo",
+		projectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+	}
+	repoDir := t.TempDir()
+	ctx := command.ProjectContext{
+		Log:        logging.NewNoopLogger(t),
+		Workspace:  "default",
+		RepoRelDir: "prod",
+		Chdir:      true,
+	}
+
+	oldVersion, err := version.NewVersion("0.13.7")
+	Ok(t, err)
+	_, err = client.RunCommandWithVersion(ctx, filepath.Join(repoDir, "prod"), []string{"init"}, map[string]string{}, oldVersion, "default")
+	ErrEquals(t, "chdir needs Terraform 0.14.0 or later, the project uses 0.13.7", err)
+
+	ctx.Engine = valid.TerragruntEngine
+	_, err = client.RunCommandWithVersion(ctx, filepath.Join(repoDir, "prod"), []string{"init"}, map[string]string{}, nil, "default")
+	ErrEquals(t, "chdir can't be used with the terragrunt engine", err)
+}
+
+// Test that projects with a provider mirror run terraform with a CLI config
+// that's the server's config plus a provider_installation block.
+func TestDefaultClient_RunCommandWithVersion_ProviderMirror(t *testing.T) {
+	v, err := version.NewVersion("1.5.7")
+	Ok(t, err)
+	tmp := t.TempDir()
+	serverConfig := filepath.Join(tmp, "server.tfrc")
+	t.Setenv("TF_CLI_CONFIG_FILE", serverConfig)
+
+	client := &DefaultClient{
+		defaultVersion:          v,
+		overrideTF:              "cat",
+		cliConfigDir:            filepath.Join(tmp, CLIConfigDirName),
+		projectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+	}
+	run := func(mirror valid.ProviderMirror) (string, error) {
+		ctx := command.ProjectContext{
+			Log:            logging.NewNoopLogger(t),
+			Workspace:      "default",
+			RepoRelDir:     ".",
+			ProviderMirror: &mirror,
+		}
+		return client.RunCommandWithVersion(ctx, tmp, []string{"$TF_CLI_CONFIG_FILE"}, map[string]string{}, nil, "default")
+	}
+
+	t.Run("network mirror without server config", func(t *testing.T) {
+		out, err := run(valid.ProviderMirror{URL: "https://mirror.example.com/providers/"})
+		Ok(t, err)
+		Equals(t, "\nprovider_installation {\n  network_mirror {\n    url = \"https://mirror.example.com/providers/\"\n  }\n}\n", out)
+	})
+
+	t.Run("filesystem mirror with server config", func(t *testing.T) {
+		Ok(t, os.WriteFile(serverConfig, []byte("plugin_cache_dir = \"/cache\""), 0600))
+		out, err := run(valid.ProviderMirror{Path: "/mirror"})
+		Ok(t, err)
+		Equals(t, "plugin_cache_dir = \"/cache\"\n\nprovider_installation {\n  filesystem_mirror {\n    path = \"/mirror\"\n  }\n}\n", out)
+
+		// The config is reused by later commands.
+		configs, err := os.ReadDir(client.cliConfigDir)
+		Ok(t, err)
+		_, err = run(valid.ProviderMirror{Path: "/mirror"})
+		Ok(t, err)
+		again, err := os.ReadDir(client.cliConfigDir)
+		Ok(t, err)
+		Equals(t, len(configs), len(again))
+	})
+
+	t.Run("server config with provider_installation", func(t *testing.T) {
+		Ok(t, os.WriteFile(serverConfig, []byte("provider_installation {\n  direct {}\n}\n"), 0600))
+		_, err := run(valid.ProviderMirror{Path: "/mirror"})
+		ErrContains(t, fmt.Sprintf("can't use provider_mirror because the server's Terraform CLI config %s already has a provider_installation block", serverConfig), err)
+	})
+}
+
+func TestDefaultClient_RunCommandWithVersion_CLIConfig(t *testing.T) {
+	v, err := version.NewVersion("1.5.7")
+	Ok(t, err)
+	tmp := t.TempDir()
+	t.Setenv("TF_CLI_CONFIG_FILE", filepath.Join(tmp, "server.tfrc"))
+	Ok(t, os.WriteFile(filepath.Join(tmp, "server.tfrc"), []byte("plugin_cache_dir = \"/cache\"\n"), 0600))
+	projectConfig := filepath.Join(tmp, "custom.tfrc")
+	Ok(t, os.WriteFile(projectConfig, []byte("credentials \"app.terraform.io\" {}\n"), 0600))
+
+	client := &DefaultClient{
+		defaultVersion:          v,
+		overrideTF:              "cat",
+		cliConfigDir:            filepath.Join(tmp, CLIConfigDirName),
+		projectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+	}
+	run := func(mirror *valid.ProviderMirror) (string, error) {
+		ctx := command.ProjectContext{
+			Log:            logging.NewNoopLogger(t),
+			Workspace:      "default",
+			RepoRelDir:     ".",
+			CLIConfigFile:  projectConfig,
+			ProviderMirror: mirror,
+		}
+		return client.RunCommandWithVersion(ctx, tmp, []string{"$TF_CLI_CONFIG_FILE"}, map[string]string{}, nil, "default")
+	}
+
+	t.Run("project config", func(t *testing.T) {
+		out, err := run(nil)
+		Ok(t, err)
+		Equals(t, "credentials \"app.terraform.io\" {}\n", out)
+		Equals(t, filepath.Join(tmp, "server.tfrc"), os.Getenv("TF_CLI_CONFIG_FILE"))
+	})
+
+	t.Run("project config with provider mirror", func(t *testing.T) {
+		out, err := run(&valid.ProviderMirror{Path: "/mirror"})
+		Ok(t, err)
+		Equals(t, "credentials \"app.terraform.io\" {}\n\nprovider_installation {\n  filesystem_mirror {\n    path = \"/mirror\"\n  }\n}\n", out)
+	})
+
+	t.Run("project config with provider_installation and provider mirror", func(t *testing.T) {
+		Ok(t, os.WriteFile(projectConfig, []byte("provider_installation {\n  direct {}\n}\n"), 0600))
+		_, err := run(&valid.ProviderMirror{Path: "/mirror"})
+		ErrContains(t, "can't use provider_mirror because the project's cli_config already has a provider_installation block", err)
+	})
+}
@@ -0,0 +1,269 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/runatlantis/atlantis/server/core/locking"
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// QueuedApply is an apply commented with atlantis apply --queue on a project
+// locked by another pull request.
+type QueuedApply struct {
+	Pull models.PullRequest
+	User models.User
+	// Cmd is the apply command run for the project once its lock is
+	// released.
+	Cmd *CommentCommand
+}
+
+// ApplyQueue is a locking.Locker that queues applies of projects locked by
+// other pull requests. When a project's lock is released the first apply
+// queued for it is run, so queued applies run in the order they were queued.
+// Queues are kept in memory so they're lost when Atlantis restarts.
+type ApplyQueue struct {
+	locking.Locker
+	// MaxSize is the most applies that can be queued for each project. If
+	// it's 0 applies can't be queued.
+	MaxSize int
+	// Run runs a queued apply once its project's lock is released. It's
+	// called in a new goroutine.
+	Run func(apply QueuedApply)
+	// Comments, if set, comments the new position of queued applies on their
+	// pull requests as the applies ahead of them run or are cancelled. The
+	// comment is created the first time an apply moves and then updated in
+	// place, so it also says when the apply runs.
+	Comments runtime.PullCommentUpdater
+	// Logger logs the failures to comment. It must be set with Comments.
+	Logger logging.SimpleLogging
+
+	mu     sync.Mutex
+	queues map[string][]QueuedApply
+	// shown is the position of every queued apply last shown on its pull
+	// request, when it was queued or by Comments.
+	shown map[queuedApplyKey]shownPosition
+	// commentMu serializes commenting positions so comments are updated in
+	// the order the queues change.
+	commentMu sync.Mutex
+}
+
+// queuedApplyKey identifies the apply queued by a pull request for the
+// project with a lock key.
+type queuedApplyKey struct {
+	lockKey      string
+	repoFullName string
+	pullNum      int
+}
+
+// shownPosition is the position of a queued apply shown on its pull request
+// and the ID of the comment showing it, if it's moved since it was queued.
+type shownPosition struct {
+	position  int
+	commentID int64
+}
+
+func newQueuedApplyKey(lockKey string, apply QueuedApply) queuedApplyKey {
+	return queuedApplyKey{lockKey: lockKey, repoFullName: apply.Pull.BaseRepo.FullName, pullNum: apply.Pull.Num}
+}
+
+// NewApplyQueue returns an ApplyQueue queueing at most maxSize applies for
+// each project locked by locker.
+func NewApplyQueue(locker locking.Locker, maxSize int) *ApplyQueue {
+	return &ApplyQueue{
+		Locker:  locker,
+		MaxSize: maxSize,
+		queues:  make(map[string][]QueuedApply),
+		shown:   make(map[queuedApplyKey]shownPosition),
+	}
+}
+
+// Enqueue queues apply for the project with the lock key lockKey. It returns
+// the apply's position in the queue, starting at 1, and the queue's length.
+// If the pull request already has an apply queued for the project it's
+// replaced, keeping its position. It returns an error if the queue is full.
+func (q *ApplyQueue) Enqueue(lockKey string, apply QueuedApply) (int, int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	queue := q.queues[lockKey]
+	for i, queued := range queue {
+		if queued.Pull.BaseRepo.FullName == apply.Pull.BaseRepo.FullName && queued.Pull.Num == apply.Pull.Num {
+			queue[i] = apply
+			return i + 1, len(queue), nil
+		}
+	}
+	if q.MaxSize <= 0 {
+		return 0, 0, fmt.Errorf("applies can't be queued on this Atlantis server")
+	}
+	if len(queue) >= q.MaxSize {
+		return 0, 0, fmt.Errorf("the apply queue of this project is full, it can have at most %d applies", q.MaxSize)
+	}
+	q.queues[lockKey] = append(queue, apply)
+	q.shown[newQueuedApplyKey(lockKey, apply)] = shownPosition{position: len(queue) + 1}
+	return len(queue) + 1, len(queue) + 1, nil
+}
+
+// Queued returns the applies queued for the project with the lock key
+// lockKey, in the order they'll run.
+func (q *ApplyQueue) Queued(lockKey string) []QueuedApply {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]QueuedApply(nil), q.queues[lockKey]...)
+}
+
+// Unlock implements locking.Locker. If the lock was released the first apply
+// queued for its project is run.
+func (q *ApplyQueue) Unlock(key string) (*models.ProjectLock, error) {
+	lock, err := q.Locker.Unlock(key)
+	if err != nil {
+		return lock, err
+	}
+	if lock != nil {
+		q.runNext(key)
+	}
+	return lock, nil
+}
+
+// UnlockByPull implements locking.Locker. It's called when the pull request
+// is closed or unlocked, so the applies it queued are cancelled. Then the
+// first apply queued for each project whose lock was released is run.
+func (q *ApplyQueue) UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error) {
+	q.cancel(repoFullName, pullNum)
+	locks, err := q.Locker.UnlockByPull(repoFullName, pullNum)
+	if err != nil {
+		return locks, err
+	}
+	for _, lock := range locks {
+		q.runNext(locking.Key(lock.Project, lock.Workspace))
+	}
+	return locks, nil
+}
+
+// cancel removes the applies queued by the pull request from every queue.
+func (q *ApplyQueue) cancel(repoFullName string, pullNum int) {
+	q.mu.Lock()
+	var moved []string
+	for key, queue := range q.queues {
+		var kept []QueuedApply
+		for _, queued := range queue {
+			if queued.Pull.BaseRepo.FullName != repoFullName || queued.Pull.Num != pullNum {
+				kept = append(kept, queued)
+				continue
+			}
+			delete(q.shown, newQueuedApplyKey(key, queued))
+		}
+		if len(kept) != len(queue) {
+			moved = append(moved, key)
+		}
+		q.setQueue(key, kept)
+	}
+	q.mu.Unlock()
+
+	for _, key := range moved {
+		go q.commentPositions(key)
+	}
+}
+
+// runNext removes the first apply queued for the project with the lock key
+// lockKey and runs it.
+func (q *ApplyQueue) runNext(lockKey string) {
+	q.mu.Lock()
+	queue := q.queues[lockKey]
+	if len(queue) == 0 || q.Run == nil {
+		q.mu.Unlock()
+		return
+	}
+	next := queue[0]
+	q.setQueue(lockKey, queue[1:])
+	nextKey := newQueuedApplyKey(lockKey, next)
+	shown := q.shown[nextKey]
+	delete(q.shown, nextKey)
+	q.mu.Unlock()
+
+	go q.Run(next)
+	go func() {
+		if shown.commentID != 0 {
+			q.commentMu.Lock()
+			q.comment(next, shown.commentID, fmt.Sprintf("The queued apply of %s is running now that the lock was released.", queuedApplyDescription(next)))
+			q.commentMu.Unlock()
+		}
+		q.commentPositions(lockKey)
+	}()
+}
+
+// commentPositions comments the positions of the applies queued for the
+// project with the lock key lockKey that moved since they were last shown.
+func (q *ApplyQueue) commentPositions(lockKey string) {
+	if q.Comments == nil {
+		return
+	}
+	q.commentMu.Lock()
+	defer q.commentMu.Unlock()
+
+	q.mu.Lock()
+	queue := append([]QueuedApply(nil), q.queues[lockKey]...)
+	shown := make([]shownPosition, len(queue))
+	for i, apply := range queue {
+		shown[i] = q.shown[newQueuedApplyKey(lockKey, apply)]
+	}
+	q.mu.Unlock()
+
+	for i, apply := range queue {
+		if shown[i].position == i+1 {
+			continue
+		}
+		comment := fmt.Sprintf("The queued apply of %s is now at position %d of %d and will run when the lock is released.", queuedApplyDescription(apply), i+1, len(queue))
+		commentID := q.comment(apply, shown[i].commentID, comment)
+		if commentID == 0 {
+			continue
+		}
+		q.mu.Lock()
+		key := newQueuedApplyKey(lockKey, apply)
+		// The apply may have run or been cancelled since.
+		if _, ok := q.shown[key]; ok {
+			q.shown[key] = shownPosition{position: i + 1, commentID: commentID}
+		}
+		q.mu.Unlock()
+	}
+}
+
+// comment updates the comment with commentID on the pull request of apply,
+// or creates it if commentID is 0, and returns its ID. It returns 0 if the
+// comment failed.
+func (q *ApplyQueue) comment(apply QueuedApply, commentID int64, comment string) int64 {
+	if commentID == 0 {
+		id, err := q.Comments.CreateUpdatableComment(q.Logger, apply.Pull.BaseRepo, apply.Pull.Num, comment)
+		if err != nil {
+			q.Logger.Warn("failed to comment the position of the queued apply on #%d: %s", apply.Pull.Num, err)
+			return 0
+		}
+		return id
+	}
+	if err := q.Comments.UpdateComment(q.Logger, apply.Pull.BaseRepo, apply.Pull.Num, commentID, comment); err != nil {
+		q.Logger.Warn("failed to update the position of the queued apply on #%d: %s", apply.Pull.Num, err)
+		return 0
+	}
+	return commentID
+}
+
+// queuedApplyDescription returns the project of apply as it's described in
+// comments, ex. dir: `staging` workspace: `default`.
+func queuedApplyDescription(apply QueuedApply) string {
+	if apply.Cmd.ProjectName != "" {
+		return fmt.Sprintf("project: `%s`", apply.Cmd.ProjectName)
+	}
+	return fmt.Sprintf("dir: `%s` workspace: `%s`", apply.Cmd.RepoRelDir, apply.Cmd.Workspace)
+}
+
+// setQueue sets the queue of lockKey, deleting it if it's empty. q.mu must
+// be held.
+func (q *ApplyQueue) setQueue(lockKey string, queue []QueuedApply) {
+	if len(queue) == 0 {
+		delete(q.queues, lockKey)
+		return
+	}
+	q.queues[lockKey] = queue
+}
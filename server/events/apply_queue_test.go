@@ -0,0 +1,224 @@
+package events_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/petergtz/pegomock/v4"
+	lockmocks "github.com/runatlantis/atlantis/server/core/locking/mocks"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+const queueLockKey = "owner/repo/./default"
+
+func queuedApply(pullNum int) events.QueuedApply {
+	return events.QueuedApply{
+		Pull: models.PullRequest{Num: pullNum, BaseRepo: models.Repo{FullName: "owner/repo"}},
+		Cmd:  &events.CommentCommand{RepoRelDir: ".", Workspace: "default", Queue: true},
+	}
+}
+
+// runQueued returns the pull request numbers of the applies run by q.
+func runQueued(q *events.ApplyQueue) chan int {
+	ran := make(chan int, 10)
+	q.Run = func(apply events.QueuedApply) {
+		ran <- apply.Pull.Num
+	}
+	return ran
+}
+
+func expRan(t *testing.T, ran chan int, pullNum int) {
+	t.Helper()
+	select {
+	case num := <-ran:
+		Equals(t, pullNum, num)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("exp the apply of pull %d to run", pullNum)
+	}
+}
+
+func TestApplyQueue_Enqueue(t *testing.T) {
+	RegisterMockTestingT(t)
+	q := events.NewApplyQueue(lockmocks.NewMockLocker(), 2)
+
+	pos, length, err := q.Enqueue(queueLockKey, queuedApply(2))
+	Ok(t, err)
+	Equals(t, 1, pos)
+	Equals(t, 1, length)
+
+	pos, length, err = q.Enqueue(queueLockKey, queuedApply(3))
+	Ok(t, err)
+	Equals(t, 2, pos)
+	Equals(t, 2, length)
+
+	// Queueing again keeps the pull request's position.
+	pos, length, err = q.Enqueue(queueLockKey, queuedApply(2))
+	Ok(t, err)
+	Equals(t, 1, pos)
+	Equals(t, 2, length)
+
+	_, _, err = q.Enqueue(queueLockKey, queuedApply(4))
+	ErrEquals(t, "the apply queue of this project is full, it can have at most 2 applies", err)
+
+	// Each project has its own queue.
+	pos, _, err = q.Enqueue("owner/repo/other/default", queuedApply(4))
+	Ok(t, err)
+	Equals(t, 1, pos)
+	Equals(t, 2, len(q.Queued(queueLockKey)))
+}
+
+func TestApplyQueue_EnqueueDisabled(t *testing.T) {
+	RegisterMockTestingT(t)
+	q := events.NewApplyQueue(lockmocks.NewMockLocker(), 0)
+	_, _, err := q.Enqueue(queueLockKey, queuedApply(2))
+	ErrEquals(t, "applies can't be queued on this Atlantis server", err)
+}
+
+func TestApplyQueue_UnlockRunsInOrder(t *testing.T) {
+	RegisterMockTestingT(t)
+	locker := lockmocks.NewMockLocker()
+	q := events.NewApplyQueue(locker, 10)
+	ran := runQueued(q)
+	When(locker.Unlock(queueLockKey)).ThenReturn(&models.ProjectLock{}, nil)
+
+	for _, num := range []int{2, 3} {
+		_, _, err := q.Enqueue(queueLockKey, queuedApply(num))
+		Ok(t, err)
+	}
+
+	_, err := q.Unlock(queueLockKey)
+	Ok(t, err)
+	expRan(t, ran, 2)
+	Equals(t, 1, len(q.Queued(queueLockKey)))
+
+	_, err = q.Unlock(queueLockKey)
+	Ok(t, err)
+	expRan(t, ran, 3)
+	Equals(t, 0, len(q.Queued(queueLockKey)))
+}
+
+func TestApplyQueue_UnlockWithoutLock(t *testing.T) {
+	RegisterMockTestingT(t)
+	locker := lockmocks.NewMockLocker()
+	q := events.NewApplyQueue(locker, 10)
+	runQueued(q)
+	When(locker.Unlock(queueLockKey)).ThenReturn(nil, nil)
+
+	_, _, err := q.Enqueue(queueLockKey, queuedApply(2))
+	Ok(t, err)
+	_, err = q.Unlock(queueLockKey)
+	Ok(t, err)
+	Equals(t, 1, len(q.Queued(queueLockKey)))
+}
+
+func TestApplyQueue_UnlockByPull(t *testing.T) {
+	RegisterMockTestingT(t)
+	locker := lockmocks.NewMockLocker()
+	q := events.NewApplyQueue(locker, 10)
+	ran := runQueued(q)
+	When(locker.UnlockByPull("owner/repo", 2)).ThenReturn(nil, nil)
+	When(locker.UnlockByPull("owner/repo", 1)).ThenReturn([]models.ProjectLock{
+		{
+			Project:   models.NewProject("owner/repo", ".", ""),
+			Workspace: "default",
+		},
+	}, nil)
+
+	for _, num := range []int{2, 3} {
+		_, _, err := q.Enqueue(queueLockKey, queuedApply(num))
+		Ok(t, err)
+	}
+
+	// Closing a pull request cancels its queued applies.
+	_, err := q.UnlockByPull("owner/repo", 2)
+	Ok(t, err)
+	Equals(t, []events.QueuedApply{queuedApply(3)}, q.Queued(queueLockKey))
+
+	// Closing the pull request holding the lock runs the next apply.
+	_, err = q.UnlockByPull("owner/repo", 1)
+	Ok(t, err)
+	expRan(t, ran, 3)
+	Equals(t, 0, len(q.Queued(queueLockKey)))
+}
+
+// queueComments records the comments of the apply queue by pull request.
+type queueComments struct {
+	mu       sync.Mutex
+	comments map[int64]string
+	pulls    map[int64]int
+}
+
+func (c *queueComments) CreateUpdatableComment(_ logging.SimpleLogging, _ models.Repo, pullNum int, comment string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := int64(len(c.comments) + 1)
+	c.comments[id] = comment
+	c.pulls[id] = pullNum
+	return id, nil
+}
+
+func (c *queueComments) UpdateComment(_ logging.SimpleLogging, _ models.Repo, _ int, commentID int64, comment string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.comments[commentID] = comment
+	return nil
+}
+
+// byPull returns the comments by the number of the pull request they're on.
+func (c *queueComments) byPull() map[int]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	comments := make(map[int]string)
+	for id, comment := range c.comments {
+		comments[c.pulls[id]] = comment
+	}
+	return comments
+}
+
+func expComments(t *testing.T, c *queueComments, exp map[int]string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && !reflect.DeepEqual(exp, c.byPull()) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	Equals(t, exp, c.byPull())
+}
+
+func TestApplyQueue_CommentsPositions(t *testing.T) {
+	RegisterMockTestingT(t)
+	locker := lockmocks.NewMockLocker()
+	q := events.NewApplyQueue(locker, 10)
+	comments := &queueComments{comments: make(map[int64]string), pulls: make(map[int64]int)}
+	q.Comments = comments
+	q.Logger = logging.NewNoopLogger(t)
+	ran := runQueued(q)
+	When(locker.Unlock(queueLockKey)).ThenReturn(&models.ProjectLock{}, nil)
+
+	for _, num := range []int{2, 3, 4} {
+		_, _, err := q.Enqueue(queueLockKey, queuedApply(num))
+		Ok(t, err)
+	}
+
+	// The applies behind the one that runs are commented their new position.
+	_, err := q.Unlock(queueLockKey)
+	Ok(t, err)
+	expRan(t, ran, 2)
+	expComments(t, comments, map[int]string{
+		3: "The queued apply of dir: `.` workspace: `default` is now at position 1 of 2 and will run when the lock is released.",
+		4: "The queued apply of dir: `.` workspace: `default` is now at position 2 of 2 and will run when the lock is released.",
+	})
+
+	// Their comments are updated as the queue drains.
+	_, err = q.Unlock(queueLockKey)
+	Ok(t, err)
+	expRan(t, ran, 3)
+	expComments(t, comments, map[int]string{
+		3: "The queued apply of dir: `.` workspace: `default` is running now that the lock was released.",
+		4: "The queued apply of dir: `.` workspace: `default` is now at position 1 of 1 and will run when the lock is released.",
+	})
+}
@@ -0,0 +1,104 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+func NewApproveStepCommandRunner(
+	vcsClient vcs.Client,
+	stepApprovals *runtime.StepApprovals,
+) *ApproveStepCommandRunner {
+	return &ApproveStepCommandRunner{
+		vcsClient:     vcsClient,
+		stepApprovals: stepApprovals,
+	}
+}
+
+// ApproveStepCommandRunner approves the run steps with require_approval set
+// that are waiting for approval in the pull request, so they run. Without
+// flags every waiting step is approved. Users can't approve the steps of
+// commands they ran themselves.
+type ApproveStepCommandRunner struct {
+	vcsClient     vcs.Client
+	stepApprovals *runtime.StepApprovals
+}
+
+func (a *ApproveStepCommandRunner) Run(ctx *command.Context, cmd *CommentCommand) {
+	baseRepo := ctx.Pull.BaseRepo
+	pullNum := ctx.Pull.Num
+
+	var vcsMessage string
+	if a.stepApprovals == nil {
+		vcsMessage = "No steps are waiting for approval."
+	} else {
+		approved, selfApprovals := a.stepApprovals.Approve(baseRepo.FullName, pullNum, ctx.User.Username, func(p runtime.PendingStepApproval) bool {
+			return approveStepMatches(cmd, p)
+		})
+		for _, p := range approved {
+			ctx.Log.Info("%s approved %q for dir %q workspace %q", ctx.User.Username, p.Command, p.RepoRelDir, p.Workspace)
+		}
+		vcsMessage = approveStepComment(ctx.User.Username, approved, selfApprovals)
+	}
+
+	if commentErr := a.vcsClient.CreateComment(ctx.Log, baseRepo, pullNum, vcsMessage, command.ApproveStep.String()); commentErr != nil {
+		ctx.Log.Err("unable to comment: %s", commentErr)
+	}
+}
+
+// approveStepMatches returns true if the step waiting for approval p is
+// targeted by cmd. Without flags every step is targeted. Otherwise -p selects
+// steps by project name and -d/-w by directory and workspace, defaulting like
+// apply does.
+func approveStepMatches(cmd *CommentCommand, p runtime.PendingStepApproval) bool {
+	if !cmd.IsForSpecificProject() {
+		return true
+	}
+	if cmd.ProjectName != "" {
+		return p.ProjectName == cmd.ProjectName
+	}
+	repoRelDir := DefaultRepoRelDir
+	if cmd.RepoRelDir != "" {
+		repoRelDir = cmd.RepoRelDir
+	}
+	workspace := DefaultWorkspace
+	if cmd.Workspace != "" {
+		workspace = cmd.Workspace
+	}
+	return p.RepoRelDir == repoRelDir && p.Workspace == workspace
+}
+
+// approveStepComment returns the comment replying to an approve-step comment
+// by approver.
+func approveStepComment(approver string, approved []runtime.PendingStepApproval, selfApprovals []runtime.PendingStepApproval) string {
+	if len(approved) == 0 && len(selfApprovals) == 0 {
+		return "No matching steps are waiting for approval. Steps stop waiting once they time out, their pull request is closed or Atlantis restarts, after which the command has to be run again to request a new approval."
+	}
+	var b strings.Builder
+	if len(approved) > 0 {
+		fmt.Fprintf(&b, "Approved %d step(s), they're running now:\n\n", len(approved))
+		writePendingStepApprovals(&b, approved)
+	}
+	if len(selfApprovals) > 0 {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "@%s can't approve these steps since they ran the command the steps are part of, someone else has to approve them:\n\n", approver)
+		writePendingStepApprovals(&b, selfApprovals)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func writePendingStepApprovals(b *strings.Builder, steps []runtime.PendingStepApproval) {
+	for _, p := range steps {
+		fmt.Fprintf(b, "* `%s` for dir: `%s` workspace: `%s`", p.Command, p.RepoRelDir, p.Workspace)
+		if p.ProjectName != "" {
+			fmt.Fprintf(b, " project: `%s`", p.ProjectName)
+		}
+		b.WriteString("\n")
+	}
+}
@@ -0,0 +1,118 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// AutoplanDebouncer coalesces the autoplans of each pull request so that when
+// several pushes arrive within Window only the last one autoplans. An autoplan
+// scheduled while another is running for the same pull request supersedes the
+// running one, which stops before planning any more projects, and starts once
+// it finishes so they never plan the same working directory at once.
+type AutoplanDebouncer struct {
+	// Window is how long to wait after a push for another push before
+	// autoplanning.
+	Window time.Duration
+
+	mu    sync.Mutex
+	pulls map[string]*debouncedAutoplan
+}
+
+// autoplanFunc runs an autoplan. superseded returns true once a newer
+// autoplan has been scheduled for the same pull request.
+type autoplanFunc func(superseded func() bool)
+
+// debouncedAutoplan is the state of the autoplans of a single pull request.
+type debouncedAutoplan struct {
+	// gen is incremented every time an autoplan is scheduled so stale timers
+	// can be ignored.
+	gen int
+	// pending is the latest scheduled autoplan that hasn't started.
+	pending autoplanFunc
+	// ready is true if the window of pending has passed but it's waiting for
+	// the running autoplan to finish.
+	ready bool
+	// running is true while an autoplan runs.
+	running bool
+	// superseded is set when a newer autoplan is scheduled while one runs.
+	superseded bool
+}
+
+// NewAutoplanDebouncer returns a debouncer that waits window after each push.
+func NewAutoplanDebouncer(window time.Duration) *AutoplanDebouncer {
+	return &AutoplanDebouncer{
+		Window: window,
+		pulls:  make(map[string]*debouncedAutoplan),
+	}
+}
+
+// Schedule schedules run to autoplan the pull request identified by key once
+// Window passes without another call to Schedule for key. Any autoplan
+// already scheduled for key is discarded and any running one is superseded.
+func (d *AutoplanDebouncer) Schedule(key string, run autoplanFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state, ok := d.pulls[key]
+	if !ok {
+		state = &debouncedAutoplan{}
+		d.pulls[key] = state
+	}
+	state.gen++
+	state.pending = run
+	state.ready = false
+	if state.running {
+		state.superseded = true
+	}
+	gen := state.gen
+	time.AfterFunc(d.Window, func() { d.fire(key, state, gen) })
+}
+
+// fire starts the pending autoplan for key if no newer one has been
+// scheduled since gen and none is running.
+func (d *AutoplanDebouncer) fire(key string, state *debouncedAutoplan, gen int) {
+	d.mu.Lock()
+	if d.pulls[key] != state || state.gen != gen || state.pending == nil {
+		d.mu.Unlock()
+		return
+	}
+	if state.running {
+		state.ready = true
+		d.mu.Unlock()
+		return
+	}
+	d.mu.Unlock()
+	d.runPending(key, state)
+}
+
+// runPending runs the pending autoplans of state until there are no more
+// ready to run.
+func (d *AutoplanDebouncer) runPending(key string, state *debouncedAutoplan) {
+	d.mu.Lock()
+	for state.pending != nil {
+		run := state.pending
+		state.pending = nil
+		state.ready = false
+		state.running = true
+		state.superseded = false
+		d.mu.Unlock()
+
+		run(func() bool {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			return state.superseded
+		})
+
+		d.mu.Lock()
+		state.running = false
+		if !state.ready {
+			break
+		}
+	}
+	// Forget the pull request unless an autoplan is still waiting for its
+	// window to pass.
+	if state.pending == nil {
+		delete(d.pulls, key)
+	}
+	d.mu.Unlock()
+}
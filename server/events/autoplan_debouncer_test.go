@@ -0,0 +1,88 @@
+package events_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// Test that only the last of several autoplans scheduled within the window
+// runs.
+func TestAutoplanDebouncer_Coalesces(t *testing.T) {
+	d := events.NewAutoplanDebouncer(50 * time.Millisecond)
+	var mu sync.Mutex
+	var ran []int
+	done := make(chan struct{})
+	for i := 1; i <= 3; i++ {
+		i := i
+		d.Schedule("pull", func(_ func() bool) {
+			mu.Lock()
+			ran = append(ran, i)
+			mu.Unlock()
+			close(done)
+		})
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("autoplan never ran")
+	}
+	// Wait past the windows of the earlier autoplans.
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	Equals(t, []int{3}, ran)
+}
+
+// Test that autoplans for different pull requests don't affect each other.
+func TestAutoplanDebouncer_SeparatePulls(t *testing.T) {
+	d := events.NewAutoplanDebouncer(10 * time.Millisecond)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	d.Schedule("pull1", func(_ func() bool) { wg.Done() })
+	d.Schedule("pull2", func(_ func() bool) { wg.Done() })
+	wg.Wait()
+}
+
+// Test that scheduling an autoplan while one runs supersedes the running one
+// and the new one only starts once it finishes.
+func TestAutoplanDebouncer_SupersedesRunning(t *testing.T) {
+	d := events.NewAutoplanDebouncer(10 * time.Millisecond)
+	started := make(chan func() bool)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	firstDone := false
+	d.Schedule("pull", func(superseded func() bool) {
+		started <- superseded
+		<-release
+		mu.Lock()
+		firstDone = true
+		mu.Unlock()
+	})
+	superseded := <-started
+	Assert(t, !superseded(), "exp first autoplan not to be superseded before a newer one is scheduled")
+
+	secondRan := make(chan bool)
+	d.Schedule("pull", func(superseded func() bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		Assert(t, firstDone, "exp second autoplan to start after the first finished")
+		secondRan <- superseded()
+	})
+	Assert(t, superseded(), "exp first autoplan to be superseded")
+
+	// Give the second autoplan's window time to pass while the first runs.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	select {
+	case secondSuperseded := <-secondRan:
+		Assert(t, !secondSuperseded, "exp second autoplan not to be superseded")
+	case <-time.After(2 * time.Second):
+		t.Fatal("second autoplan never ran")
+	}
+}
@@ -0,0 +1,166 @@
+package events
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// CloneURLRewrite rewrites the clone URLs of repos matching Regex, ex. to
+// clone from an internal mirror of the VCS host. Only cloning uses the
+// rewritten URL, comments and statuses still go to the VCS host.
+type CloneURLRewrite struct {
+	Regex       *regexp.Regexp
+	Replacement string
+}
+
+// ParseCloneURLRewrites parses a comma separated list of {regex}={replacement}
+// rules. The replacement can refer to the regex's capture groups with $1 or
+// ${name}. Commas inside braces or brackets, ex. in {1,3} or [,;], and
+// escaped commas don't separate rules. An empty string has no rules.
+func ParseCloneURLRewrites(rules string) ([]CloneURLRewrite, error) {
+	var rewrites []CloneURLRewrite
+	for _, rule := range splitCloneURLRewrites(rules) {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		pattern, replacement, ok := strings.Cut(rule, "=")
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid clone URL rewrite %q, must be of the form {regex}={replacement}", rule)
+		}
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid clone URL rewrite %q: %w", rule, err)
+		}
+		rewrites = append(rewrites, CloneURLRewrite{Regex: regex, Replacement: replacement})
+	}
+	return rewrites, nil
+}
+
+// splitCloneURLRewrites splits rules on the commas that aren't escaped or
+// inside braces or brackets, so regexes like a{1,3} stay whole.
+func splitCloneURLRewrites(rules string) []string {
+	var split []string
+	var depth, start int
+	for i := 0; i < len(rules); i++ {
+		switch rules[i] {
+		case '\\':
+			i++
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				split = append(split, rules[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(split, rules[start:])
+}
+
+// RewriteCloneURL returns cloneURL rewritten by each of rewrites in order.
+// The rules match the URL without its credentials, which are then added back
+// so the VCS token is used for the rewritten host too.
+func RewriteCloneURL(rewrites []CloneURLRewrite, cloneURL string) string {
+	if len(rewrites) == 0 {
+		return cloneURL
+	}
+	scheme, rest, ok := strings.Cut(cloneURL, "://")
+	if !ok {
+		return rewriteURL(rewrites, cloneURL)
+	}
+	host, _, _ := strings.Cut(rest, "/")
+	at := strings.LastIndex(host, "@")
+	if at < 0 {
+		return rewriteURL(rewrites, cloneURL)
+	}
+	userinfo := rest[:at+1]
+	rewritten := rewriteURL(rewrites, scheme+"://"+rest[at+1:])
+	if newScheme, newRest, ok := strings.Cut(rewritten, "://"); ok {
+		return newScheme + "://" + userinfo + newRest
+	}
+	return rewritten
+}
+
+func rewriteURL(rewrites []CloneURLRewrite, s string) string {
+	for _, rewrite := range rewrites {
+		s = rewrite.Regex.ReplaceAllString(s, rewrite.Replacement)
+	}
+	return s
+}
+
+// rewriteRepoCloneURLs returns repo with its clone URLs rewritten by
+// rewrites. The sanitized URL is rewritten too so credentials are still
+// removed from git's output.
+//
+// git looks up the credentials of a URL without a password by its host, so
+// if repo's clone URL has none, ex. with a GitHub App, and it's rewritten to
+// another host, the credentials credsFile has for its host are added to it.
+func rewriteRepoCloneURLs(rewrites []CloneURLRewrite, credsFile string, repo models.Repo) models.Repo {
+	cloneURL := RewriteCloneURL(rewrites, repo.CloneURL)
+	sanitizedCloneURL := RewriteCloneURL(rewrites, repo.SanitizedCloneURL)
+	if user := storedCredentials(credsFile, repo.CloneURL, cloneURL); user != nil {
+		cloneURL = withUserinfo(cloneURL, user.String())
+		sanitizedCloneURL = withUserinfo(sanitizedCloneURL, url.QueryEscape(user.Username())+":<redacted>")
+	}
+	repo.CloneURL = cloneURL
+	repo.SanitizedCloneURL = sanitizedCloneURL
+	return repo
+}
+
+// storedCredentials returns the credentials credsFile, a git credentials
+// file, has for the host of cloneURL if cloneURL has no password and was
+// rewritten to another host. Otherwise, or if credsFile can't be read, it
+// returns nil.
+func storedCredentials(credsFile string, cloneURL string, rewritten string) *url.Userinfo {
+	if credsFile == "" {
+		return nil
+	}
+	orig, err := url.Parse(cloneURL)
+	if err != nil {
+		return nil
+	}
+	if password, _ := orig.User.Password(); password != "" {
+		return nil
+	}
+	if u, err := url.Parse(rewritten); err != nil || u.Host == orig.Host {
+		return nil
+	}
+	content, err := os.ReadFile(credsFile) // nolint: gosec
+	if err != nil {
+		return nil
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		stored, err := url.Parse(strings.TrimSpace(line))
+		if err != nil || stored.User == nil || stored.Host != orig.Host {
+			continue
+		}
+		if orig.User.Username() != "" && orig.User.Username() != stored.User.Username() {
+			continue
+		}
+		return stored.User
+	}
+	return nil
+}
+
+// withUserinfo returns cloneURL with its userinfo replaced by userinfo.
+func withUserinfo(cloneURL string, userinfo string) string {
+	scheme, rest, ok := strings.Cut(cloneURL, "://")
+	if !ok {
+		return cloneURL
+	}
+	host, _, _ := strings.Cut(rest, "/")
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		rest = rest[at+1:]
+	}
+	return scheme + "://" + userinfo + "@" + rest
+}
@@ -3,6 +3,7 @@ package command
 import (
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/tracing"
 	tally "github.com/uber-go/tally/v4"
 )
 
@@ -46,4 +47,40 @@ type Context struct {
 
 	// API is true if plan/apply by API endpoints
 	API bool
+
+	// Superseded, if set, returns true once a newer autoplan has been
+	// scheduled for the pull request, in which case this one should stop.
+	Superseded func() bool
+
+	// RunID identifies this run of the command so it can be correlated across
+	// logs, the steps it runs and its pull request comments.
+	RunID string
+
+	// ApplyReason is the reason an apply was given with --reason. It's shown
+	// in the apply's comment and exported with its result.
+	ApplyReason string
+
+	// ApplyTargets are the resource or module addresses given with apply
+	// --target. Only their changes in the plans are applied.
+	ApplyTargets []string
+
+	// Span is the span tracing the command's run. It's nil if runs aren't
+	// traced.
+	Span *tracing.Span
+
+	// PlanSummary is true for plan --summary, which adds a table of the
+	// resources each plan changes to its comment.
+	PlanSummary bool
+
+	// Cancelled, if set, is closed once the command is cancelled because its
+	// pull request was closed.
+	Cancelled <-chan struct{}
+
+	// QueueApply is true if applies of projects locked by other pull
+	// requests should be queued, set with apply --queue.
+	QueueApply bool
+
+	// ProjectOutcomes, if set, records which of the run's projects succeeded
+	// for steps with requires_projects_success.
+	ProjectOutcomes *ProjectOutcomes
 }
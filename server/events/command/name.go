@@ -30,6 +30,28 @@ const (
 	Import
 	// State is a command to run terraform state rm
 	State
+	// DiscardPlan is a command to delete stored plans without releasing locks.
+	DiscardPlan
+	// LockStatus is a command to show who holds the locks of projects.
+	LockStatus
+	// ListProjects is a command to list the projects of a pull request by index.
+	ListProjects
+	// ComparePlan is a command to compare the plans of a pull request with
+	// another's.
+	ComparePlan
+	// ExplainWorkflow is a command to show which workflow projects use and
+	// why.
+	ExplainWorkflow
+	// ApproveStep is a command to approve run steps with require_approval set
+	// that are waiting to run.
+	ApproveStep
+	// ShowConfig is a command to show the effective config of projects.
+	ShowConfig
+	// PinPlan is a command to pin stored plans so they aren't discarded
+	// automatically.
+	PinPlan
+	// UnpinPlan is a command to unpin stored plans pinned by PinPlan.
+	UnpinPlan
 	// Adding more? Don't forget to update String() below
 )
 
@@ -39,14 +61,26 @@ type ArgCount struct {
 }
 
 // AllCommentCommands are list of commands that can be run from a comment.
+// --allow-commands=all allows every one of them, so commands added here are
+// enabled by all too.
 var AllCommentCommands = []Name{
 	Version,
 	Plan,
 	Apply,
 	Unlock,
 	ApprovePolicies,
+	PolicyCheck,
 	Import,
 	State,
+	DiscardPlan,
+	LockStatus,
+	ListProjects,
+	ComparePlan,
+	ExplainWorkflow,
+	ApproveStep,
+	ShowConfig,
+	PinPlan,
+	UnpinPlan,
 }
 
 // TitleString returns the string representation in title form.
@@ -74,6 +108,24 @@ func (c Name) String() string {
 		return "import"
 	case State:
 		return "state"
+	case DiscardPlan:
+		return "discard-plan"
+	case LockStatus:
+		return "lock-status"
+	case ListProjects:
+		return "list-projects"
+	case ComparePlan:
+		return "compare-plan"
+	case ExplainWorkflow:
+		return "explain-workflow"
+	case ApproveStep:
+		return "approve-step"
+	case ShowConfig:
+		return "show-config"
+	case PinPlan:
+		return "pin-plan"
+	case UnpinPlan:
+		return "unpin-plan"
 	}
 	return ""
 }
@@ -85,6 +137,8 @@ func (c Name) DefaultUsage() string {
 		return "import ADDRESS ID"
 	case State:
 		return "state [rm ADDRESS...]"
+	case ComparePlan:
+		return "compare-plan --against PULL"
 	default:
 		return c.String()
 	}
@@ -149,6 +203,24 @@ func ParseCommandName(name string) (Name, error) {
 		return Import, nil
 	case "state":
 		return State, nil
+	case "discard-plan":
+		return DiscardPlan, nil
+	case "lock-status":
+		return LockStatus, nil
+	case "list-projects":
+		return ListProjects, nil
+	case "compare-plan":
+		return ComparePlan, nil
+	case "explain-workflow":
+		return ExplainWorkflow, nil
+	case "approve-step":
+		return ApproveStep, nil
+	case "show-config":
+		return ShowConfig, nil
+	case "pin-plan":
+		return PinPlan, nil
+	case "unpin-plan":
+		return UnpinPlan, nil
 	}
 	return -1, fmt.Errorf("unknown command name: %s", name)
 }
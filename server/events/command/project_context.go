@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-version"
 	"github.com/runatlantis/atlantis/server/core/config/valid"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/tracing"
 	tally "github.com/uber-go/tally/v4"
 )
 
@@ -93,6 +95,12 @@ type ProjectContext struct {
 	// Steps are the sequence of commands we need to run for this project and this
 	// stage.
 	Steps []valid.Step
+	// Engine is the tool built-in steps run for this project, ex. terragrunt.
+	// If empty, terraform is run.
+	Engine valid.Engine
+	// EnvPassthrough are glob patterns of the server's environment variables
+	// passed to run steps. If nil every variable is passed.
+	EnvPassthrough []string
 	// TerraformVersion is the version of terraform we should use when executing
 	// commands for this project. This can be set to nil in which case we will
 	// use the default Atlantis terraform version.
@@ -119,12 +127,97 @@ type ProjectContext struct {
 	RepoConfigFile string
 	// UUID for atlantis logs
 	JobID string
+	// OutputSecrets are masked in the output streamed to the job, ex. the
+	// values of SSM SecureString parameters loaded by env steps. They're the
+	// secrets masked in comments as well as those masked in logs.
+	OutputSecrets []string
 	// The index of order group. Before planning/applying it will use to sort projects. Default is 0.
 	ExecutionOrderGroup int
 	// If plans/applies should be aborted if any prior plan/apply fails
 	AbortOnExcecutionOrderFail bool
 	// Allows custom policy check tools outside of Conftest to run in checks
 	CustomPolicyCheck bool
+	// PlanRef is the git ref to plan instead of the pull request's head. If
+	// empty the pull request's head is planned.
+	PlanRef string
+	// PlanOnly is true if the project sets lock: false so its plans don't
+	// lock it and it can't be applied.
+	PlanOnly bool
+	// PlanTTL is how long plans can be applied for. Older plans are discarded
+	// when applying. If 0 plans don't expire.
+	PlanTTL time.Duration
+	// Chdir is whether terraform runs from the repo's root with -chdir set to
+	// RepoRelDir instead of from RepoRelDir.
+	Chdir bool
+	// CLIConfig is the path, relative to RepoRelDir, of the Terraform CLI
+	// config set by the project's cli_config.
+	CLIConfig string
+	// CLIConfigFile is the absolute path of CLIConfig, resolved when the
+	// project's steps run. If empty the server's CLI config is used.
+	CLIConfigFile string
+	// TFVars are the Terraform variables set by the project's tf_vars, by
+	// name. They're exported to the project's steps as TF_VAR_ environment
+	// variables.
+	TFVars map[string]string
+	// BackendConfigs are the paths, relative to RepoRelDir, of the backend
+	// config files set by the project's backend_configs, by workspace. If
+	// set, init uses the one of Workspace.
+	BackendConfigs map[string]string
+	// PluginCacheDir is the Terraform plugin cache dir set by the repo's
+	// plugin_cache_dir. If empty the server's plugin cache is used.
+	PluginCacheDir string
+	// ProviderMirror is the provider mirror set by the repo's
+	// provider_mirror. If nil providers are installed from their registries.
+	ProviderMirror *valid.ProviderMirror
+	// RunCommandPolicy restricts the executables run steps can run.
+	RunCommandPolicy valid.RunCommandPolicy
+	// RunID identifies the command run this project is part of. It's shared by
+	// every project of the run.
+	RunID string
+	// Cancelled, if set, is closed once the command is cancelled. Running
+	// commands are interrupted, steps that haven't started are skipped and
+	// OnCancelSteps run.
+	Cancelled <-chan struct{}
+	// ProjectOutcomes, if set, records which projects of the run succeeded.
+	ProjectOutcomes *ProjectOutcomes
+	// OnCancelSteps are the steps of the workflow's on_cancel stage.
+	OnCancelSteps []valid.Step
+	// QueueApply is true if the apply should be queued, with apply --queue,
+	// if the project is locked by another pull request.
+	QueueApply bool
+	// ApplyReason is the reason the apply was given with --reason. It's
+	// kept when the apply is queued.
+	ApplyReason string
+	// ApplyTargets are the resource or module addresses given with apply
+	// --target. Only their changes in the plan are applied.
+	ApplyTargets []string
+	// Span is the span tracing the command, the run's span until the
+	// project's command starts and then its own. It's nil if runs aren't
+	// traced.
+	Span *tracing.Span
+	// PlanSummary is true for plan --summary, which adds a table of the
+	// resources the plan changes to its comment.
+	PlanSummary bool
+	// WorkflowTimeout is how long running the steps of the command can take,
+	// from the timeout of the project's workflow. If 0 there's no limit.
+	WorkflowTimeout time.Duration
+	// Deadline, if set, is when the command's steps must be done. Commands
+	// still running then are killed.
+	Deadline time.Time
+	// StepIndex is the index of the step being run in the steps of the
+	// command's stage, to tell apart steps that run the same command.
+	StepIndex int
+	// WorkflowName is the name of the project's workflow.
+	WorkflowName string
+	// WorkflowTrace is each config that set the project's workflow, in the
+	// order they were applied, as shown by explain-workflow.
+	WorkflowTrace []string
+	// Workflow is the project's workflow, with every stage, as shown by
+	// show-config.
+	Workflow valid.Workflow
+	// RepoCfgKeys are the keys of the server-side settings the repo config
+	// overrode for the project, as shown by show-config.
+	RepoCfgKeys []string
 }
 
 // SetProjectScopeTags adds ProjectContext tags to a new returned scope.
@@ -164,6 +257,16 @@ func (p ProjectContext) GetPolicyCheckResultFileName() string {
 	return fmt.Sprintf("%s-%s-policyout.json", projName, p.Workspace)
 }
 
+// GetStateVersionFileName returns the filename (not the path) to store the
+// version of the state after an apply.
+func (p ProjectContext) GetStateVersionFileName() string {
+	if p.ProjectName == "" {
+		return fmt.Sprintf("%s-stateversion.json", p.Workspace)
+	}
+	projName := strings.Replace(p.ProjectName, "/", planfileSlashReplace, -1)
+	return fmt.Sprintf("%s-%s-stateversion.json", projName, p.Workspace)
+}
+
 // Gets a unique identifier for the current pull request as a single string
 func (p ProjectContext) PullInfo() string {
 	normalizedOwner := strings.ReplaceAll(p.BaseRepo.Owner, "/", "-")
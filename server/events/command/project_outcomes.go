@@ -0,0 +1,69 @@
+package command
+
+import "sync"
+
+// ProjectOutcomes records whether the projects of a command run succeeded, so
+// steps can depend on the results of the run's other projects. It's safe to
+// use from projects running in parallel.
+type ProjectOutcomes struct {
+	mu        sync.Mutex
+	succeeded map[string]bool
+	errored   bool
+	// abortedBy describes the project whose fatal step failed, if any.
+	abortedBy string
+}
+
+// NewProjectOutcomes returns ProjectOutcomes with no projects.
+func NewProjectOutcomes() *ProjectOutcomes {
+	return &ProjectOutcomes{succeeded: make(map[string]bool)}
+}
+
+// Record records result. A project only succeeded if every result recorded
+// for it had no error or failure, ex. both its plan and policy check.
+func (o *ProjectOutcomes) Record(result ProjectResult) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if result.Error != nil {
+		o.errored = true
+	}
+	if result.ProjectName == "" {
+		return
+	}
+	succeeded, ok := o.succeeded[result.ProjectName]
+	o.succeeded[result.ProjectName] = (succeeded || !ok) && result.Error == nil && result.Failure == ""
+}
+
+// Succeeded returns whether the project named projectName succeeded. It
+// returns false if the project hasn't finished in this run.
+func (o *ProjectOutcomes) Succeeded(projectName string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.succeeded[projectName]
+}
+
+// Errored returns whether any project errored in this run, including projects
+// without names.
+func (o *ProjectOutcomes) Errored() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.errored
+}
+
+// Abort records that a fatal step of the project described by project failed,
+// so the run's other projects stop before their next step. Only the first
+// project to abort the run is kept.
+func (o *ProjectOutcomes) Abort(project string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.abortedBy == "" {
+		o.abortedBy = project
+	}
+}
+
+// Aborted returns the description of the project that aborted the run and
+// true if a fatal step of one of the run's projects failed.
+func (o *ProjectOutcomes) Aborted() (string, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.abortedBy, o.abortedBy != ""
+}
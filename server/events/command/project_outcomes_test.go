@@ -0,0 +1,45 @@
+package command_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/models"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestProjectOutcomes(t *testing.T) {
+	o := command.NewProjectOutcomes()
+	o.Record(command.ProjectResult{ProjectName: "network", PlanSuccess: &models.PlanSuccess{}})
+	o.Record(command.ProjectResult{ProjectName: "dns", Error: errors.New("err")})
+	o.Record(command.ProjectResult{ProjectName: "db", PlanSuccess: &models.PlanSuccess{}})
+	o.Record(command.ProjectResult{ProjectName: "db", Failure: "policies failed"})
+	o.Record(command.ProjectResult{PlanSuccess: &models.PlanSuccess{}})
+
+	Assert(t, o.Succeeded("network"), "exp network to have succeeded")
+	Assert(t, !o.Succeeded("dns"), "exp dns not to have succeeded since it errored")
+	Assert(t, !o.Succeeded("db"), "exp db not to have succeeded since its policy check failed")
+	Assert(t, !o.Succeeded("app"), "exp app not to have succeeded since it didn't run")
+	Assert(t, !o.Succeeded(""), "exp projects without names not to be recorded")
+	Assert(t, o.Errored(), "exp run to have errored since dns errored")
+}
+
+func TestProjectOutcomes_Errored(t *testing.T) {
+	o := command.NewProjectOutcomes()
+	o.Record(command.ProjectResult{ProjectName: "db", Failure: "policies failed"})
+	Assert(t, !o.Errored(), "exp failures not to count as errors")
+	o.Record(command.ProjectResult{Error: errors.New("err")})
+	Assert(t, o.Errored(), "exp errors of projects without names to count")
+}
+
+func TestProjectOutcomes_Abort(t *testing.T) {
+	o := command.NewProjectOutcomes()
+	_, aborted := o.Aborted()
+	Assert(t, !aborted, "exp run not to be aborted")
+	o.Abort(`project "db"`)
+	o.Abort(`project "dns"`)
+	abortedBy, aborted := o.Aborted()
+	Assert(t, aborted, "exp run to be aborted")
+	Equals(t, `project "db"`, abortedBy)
+}
@@ -15,10 +15,17 @@ type ProjectResult struct {
 	PlanSuccess        *models.PlanSuccess
 	PolicyCheckResults *models.PolicyCheckResults
 	ApplySuccess       string
-	VersionSuccess     string
-	ImportSuccess      *models.ImportSuccess
-	StateRmSuccess     *models.StateRmSuccess
-	ProjectName        string
+	// ApplyState is the version of the state after a successful apply. It's
+	// nil if it couldn't be read, ex. because the backend doesn't support
+	// terraform state pull.
+	ApplyState     *models.StateVersion
+	VersionSuccess string
+	ImportSuccess  *models.ImportSuccess
+	StateRmSuccess *models.StateRmSuccess
+	ProjectName    string
+	// PlanOnly is true if the project can't be applied because it sets
+	// lock: false.
+	PlanOnly bool
 }
 
 // CommitStatus returns the vcs commit status of this project result.
@@ -80,6 +87,16 @@ func (p ProjectResult) PlanStatus() models.ProjectPlanStatus {
 	panic("PlanStatus() missing a combination")
 }
 
+// PlanStats returns the changes in the plan if this is a successful plan
+// result, otherwise nil.
+func (p ProjectResult) PlanStats() *models.PlanSuccessStats {
+	if p.Command != Plan || p.PlanSuccess == nil {
+		return nil
+	}
+	stats := p.PlanSuccess.Stats()
+	return &stats
+}
+
 // IsSuccessful returns true if this project result had no errors.
 func (p ProjectResult) IsSuccessful() bool {
 	return p.PlanSuccess != nil || (p.PolicyCheckResults != nil && p.Error == nil && p.Failure == "") || p.ApplySuccess != ""
@@ -2,9 +2,12 @@ package events
 
 import (
 	"fmt"
+	"path/filepath"
 
+	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/core/config/raw"
 	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/core/runtime"
 	"github.com/runatlantis/atlantis/server/events/command"
 	"github.com/runatlantis/atlantis/server/events/models"
 )
@@ -19,6 +22,15 @@ type CommandRequirementHandler interface {
 
 type DefaultCommandRequirementHandler struct {
 	WorkingDir WorkingDir
+	// ShowStepRunner writes a project's plan JSON if it's needed to check
+	// conditional requirements and doesn't exist.
+	ShowStepRunner StepRunner
+	// PlanEncryptor decrypts plans encrypted at rest while they're read. If
+	// nil plans aren't encrypted.
+	PlanEncryptor *runtime.PlanEncryptor
+	// WorkingDirLocker, if set, is locked while a plan is shown so it isn't
+	// decrypted while another command uses the project's directory.
+	WorkingDirLocker WorkingDirLocker
 }
 
 func (a *DefaultCommandRequirementHandler) ValidatePlanProject(repoDir string, ctx command.ProjectContext) (failure string, err error) {
@@ -43,11 +55,29 @@ func (a *DefaultCommandRequirementHandler) ValidatePlanProject(repoDir string, c
 }
 
 func (a *DefaultCommandRequirementHandler) ValidateApplyProject(repoDir string, ctx command.ProjectContext) (failure string, err error) {
+	// destroys is only checked if there are conditional requirements since it
+	// might have to run terraform show.
+	var destroys *bool
 	for _, req := range ctx.ApplyRequirements {
+		req, condition := valid.SplitCommandReq(req)
+		reason := ""
+		if condition == valid.OnDestroyCommandReqCondition {
+			if destroys == nil {
+				d, err := a.planDestroys(filepath.Join(repoDir, ctx.RepoRelDir), ctx)
+				if err != nil {
+					return "", errors.Wrapf(err, "checking if plan destroys resources for %q requirement", req+":"+condition)
+				}
+				destroys = &d
+			}
+			if !*destroys {
+				continue
+			}
+			reason = " because the plan destroys resources"
+		}
 		switch req {
 		case raw.ApprovedRequirement:
 			if !ctx.PullReqStatus.ApprovalStatus.IsApproved {
-				return "Pull request must be approved according to the project's approval rules before running apply.", nil
+				return fmt.Sprintf("Pull request must be approved according to the project's approval rules before running apply%s.", reason), nil
 			}
 		// this should come before mergeability check since mergeability is a superset of this check.
 		case valid.PoliciesPassedCommandReq:
@@ -57,11 +87,11 @@ func (a *DefaultCommandRequirementHandler) ValidateApplyProject(repoDir string,
 			}
 		case raw.MergeableRequirement:
 			if !ctx.PullReqStatus.Mergeable {
-				return "Pull request must be mergeable before running apply.", nil
+				return fmt.Sprintf("Pull request must be mergeable before running apply%s.", reason), nil
 			}
 		case raw.UnDivergedRequirement:
 			if a.WorkingDir.HasDiverged(ctx.Log, repoDir) {
-				return "Default branch must be rebased onto pull request before running apply.", nil
+				return fmt.Sprintf("Default branch must be rebased onto pull request before running apply%s.", reason), nil
 			}
 		}
 	}
@@ -69,6 +99,31 @@ func (a *DefaultCommandRequirementHandler) ValidateApplyProject(repoDir string,
 	return "", nil
 }
 
+// planDestroys returns true if the project's plan in projectDir deletes or
+// replaces any resources.
+func (a *DefaultCommandRequirementHandler) planDestroys(projectDir string, ctx command.ProjectContext) (bool, error) {
+	if a.WorkingDirLocker != nil {
+		unlockFn, err := a.WorkingDirLocker.TryLock(ctx.Pull.BaseRepo.FullName, ctx.Pull.Num, ctx.Workspace, ctx.RepoRelDir)
+		if err != nil {
+			return false, err
+		}
+		defer unlockFn()
+	}
+	plan, err := readPlanJSON(a.ShowStepRunner, a.PlanEncryptor, projectDir, ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, rc := range plan.ResourceChanges {
+		for _, action := range rc.Change.Actions {
+			// Replacements are a delete and a create so they destroy too.
+			if action == "delete" {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
 func (a *DefaultCommandRequirementHandler) ValidateProjectDependencies(ctx command.ProjectContext) (failure string, err error) {
 	for _, dependOnProject := range ctx.DependsOn {
 
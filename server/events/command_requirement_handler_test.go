@@ -2,6 +2,8 @@ package events_test
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	. "github.com/petergtz/pegomock/v4"
@@ -208,6 +210,103 @@ func TestAggregateApplyRequirements_ValidateApplyProject(t *testing.T) {
 	}
 }
 
+func TestAggregateApplyRequirements_ValidateApplyProjectOnDestroy(t *testing.T) {
+	destroyPlan := `{"resource_changes":[{"change":{"actions":["create"]}},{"change":{"actions":["delete","create"]}}]}`
+	changesPlan := `{"resource_changes":[{"change":{"actions":["create"]}},{"change":{"actions":["update"]}}]}`
+	tests := []struct {
+		name     string
+		planJSON string
+		showJSON string
+		approved bool
+		// dirLocked is true if another command holds the project's working
+		// dir lock.
+		dirLocked   bool
+		wantFailure string
+		wantErr     string
+	}{
+		{
+			name:     "changes only plan doesn't require approval",
+			planJSON: changesPlan,
+		},
+		{
+			name:        "destroy plan requires approval",
+			planJSON:    destroyPlan,
+			wantFailure: "Pull request must be approved according to the project's approval rules before running apply because the plan destroys resources.",
+		},
+		{
+			name:     "approved destroy plan",
+			planJSON: destroyPlan,
+			approved: true,
+		},
+		{
+			name:        "missing plan JSON is written by show step",
+			showJSON:    destroyPlan,
+			wantFailure: "Pull request must be approved according to the project's approval rules before running apply because the plan destroys resources.",
+		},
+		{
+			name:     "invalid plan JSON",
+			planJSON: "not json",
+			wantErr:  "checking if plan destroys resources for \"approved:on_destroy\" requirement: parsing plan JSON",
+		},
+		{
+			name:      "plan isn't read while another command uses the project's dir",
+			showJSON:  destroyPlan,
+			dirLocked: true,
+			wantErr:   "is currently locked by another command",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			repoDir := t.TempDir()
+			projectDir := filepath.Join(repoDir, "project")
+			assert.NoError(t, os.MkdirAll(projectDir, 0700))
+			ctx := command.ProjectContext{
+				Log:               logging.NewNoopLogger(t),
+				RepoRelDir:        "project",
+				Workspace:         "default",
+				ApplyRequirements: []string{raw.MergeableRequirement, raw.ApprovedRequirement + ":" + valid.OnDestroyCommandReqCondition},
+				PullReqStatus: models.PullReqStatus{
+					ApprovalStatus: models.ApprovalStatus{IsApproved: tt.approved},
+					Mergeable:      true,
+				},
+			}
+			showFile := filepath.Join(projectDir, ctx.GetShowResultFileName())
+			if tt.planJSON != "" {
+				assert.NoError(t, os.WriteFile(showFile, []byte(tt.planJSON), 0600))
+			}
+			showStepRunner := mocks.NewMockStepRunner()
+			When(showStepRunner.Run(Any[command.ProjectContext](), Any[[]string](), Eq(projectDir), Any[map[string]string]())).
+				Then(func(_ []Param) ReturnValues {
+					assert.NoError(t, os.WriteFile(showFile, []byte(tt.showJSON), 0600))
+					return ReturnValues{tt.showJSON, nil}
+				})
+			locker := events.NewDefaultWorkingDirLocker()
+			if tt.dirLocked {
+				unlockFn, err := locker.TryLock(ctx.Pull.BaseRepo.FullName, ctx.Pull.Num, ctx.Workspace, ctx.RepoRelDir)
+				assert.NoError(t, err)
+				defer unlockFn()
+			}
+			a := &events.DefaultCommandRequirementHandler{
+				WorkingDir:       mocks.NewMockWorkingDir(),
+				ShowStepRunner:   showStepRunner,
+				WorkingDirLocker: locker,
+			}
+
+			gotFailure, err := a.ValidateApplyProject(repoDir, ctx)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantFailure, gotFailure)
+			if tt.planJSON != "" {
+				showStepRunner.VerifyWasCalled(Never()).Run(Any[command.ProjectContext](), Any[[]string](), Any[string](), Any[map[string]string]())
+			}
+		})
+	}
+}
+
 func TestRequirements_ValidateProjectDependencies(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -15,9 +15,12 @@ package events
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/google/go-github/v59/github"
+	"github.com/google/uuid"
 	"github.com/mcdafydd/go-azuredevops/azuredevops"
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/core/config/valid"
@@ -28,6 +31,7 @@ import (
 	"github.com/runatlantis/atlantis/server/logging"
 	"github.com/runatlantis/atlantis/server/metrics"
 	"github.com/runatlantis/atlantis/server/recovery"
+	"github.com/runatlantis/atlantis/server/tracing"
 	"github.com/runatlantis/atlantis/server/utils"
 	tally "github.com/uber-go/tally/v4"
 	gitlab "github.com/xanzy/go-gitlab"
@@ -44,8 +48,10 @@ type CommandRunner interface {
 	// RunCommentCommand is the first step after a command request has been parsed.
 	// It handles gathering additional information needed to execute the command
 	// and then calling the appropriate services to finish executing the command.
-	RunCommentCommand(baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, cmd *CommentCommand)
-	RunAutoplanCommand(baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User)
+	// runID identifies the run in logs, to steps and in comments. If it's
+	// empty a new ID is generated.
+	RunCommentCommand(baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, cmd *CommentCommand, runID string)
+	RunAutoplanCommand(baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User, runID string)
 }
 
 //go:generate pegomock generate github.com/runatlantis/atlantis/server/events --package mocks -o mocks/mock_github_pull_getter.go GithubPullGetter
@@ -131,10 +137,38 @@ type DefaultCommandRunner struct {
 	TeamAllowlistChecker           *TeamAllowlistChecker
 	VarFileAllowlistChecker        *VarFileAllowlistChecker
 	CommitStatusUpdater            CommitStatusUpdater
+	// AutoplanDebouncer, if set, coalesces autoplans triggered by pushes in
+	// quick succession.
+	AutoplanDebouncer *AutoplanDebouncer
+	// RunCanceller, if set, cancels the commands running for a pull request
+	// when it's closed.
+	RunCanceller *RunCanceller
+	// WorkingDirCleaner, if set, deletes the working dirs of pull requests as
+	// set by their repo's clean_workspace.
+	WorkingDirCleaner *WorkingDirCleaner
+	// ExpiredPlanDiscarder, if set, discards the pull request's plans that
+	// are older than their plan_ttl before running comment commands.
+	ExpiredPlanDiscarder *ExpiredPlanDiscarder
+	// Tracer, if set, traces runs with a span for each of their projects and
+	// steps.
+	Tracer *tracing.Tracer
 }
 
 // RunAutoplanCommand runs plan and policy_checks when a pull request is opened or updated.
-func (c *DefaultCommandRunner) RunAutoplanCommand(baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User) {
+// If AutoplanDebouncer is set the autoplan is scheduled and runs later unless
+// it's superseded by a newer push.
+func (c *DefaultCommandRunner) RunAutoplanCommand(baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User, runID string) {
+	if c.AutoplanDebouncer != nil {
+		key := fmt.Sprintf("%s/%s#%d", baseRepo.VCSHost.Hostname, baseRepo.FullName, pull.Num)
+		c.AutoplanDebouncer.Schedule(key, func(superseded func() bool) {
+			c.runAutoplanCommand(baseRepo, headRepo, pull, user, runID, superseded)
+		})
+		return
+	}
+	c.runAutoplanCommand(baseRepo, headRepo, pull, user, runID, nil)
+}
+
+func (c *DefaultCommandRunner) runAutoplanCommand(baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User, runID string, superseded func() bool) {
 	if opStarted := c.Drainer.StartOp(); !opStarted {
 		if commentErr := c.VCSClient.CreateComment(c.Logger, baseRepo, pull.Num, ShutdownComment, command.Plan.String()); commentErr != nil {
 			c.Logger.Log(logging.Error, "unable to comment that Atlantis is shutting down: %s", commentErr)
@@ -143,8 +177,11 @@ func (c *DefaultCommandRunner) RunAutoplanCommand(baseRepo models.Repo, headRepo
 	}
 	defer c.Drainer.OpDone()
 
-	log := c.buildLogger(baseRepo.FullName, pull.Num)
+	runID = newRunID(runID)
+	log := c.buildLogger(baseRepo.FullName, pull.Num, runID)
 	defer c.logPanics(baseRepo, pull.Num, log)
+	span := c.startRunSpan(runID, baseRepo, pull.Num, command.Autoplan.String(), "auto")
+	defer span.End(nil)
 	status, err := c.PullStatusFetcher.GetPullStatus(pull)
 
 	if err != nil {
@@ -164,15 +201,28 @@ func (c *DefaultCommandRunner) RunAutoplanCommand(baseRepo models.Repo, headRepo
 	if !ok {
 		return
 	}
+	if !c.GlobalCfg.RepoAllowsCommand(baseRepo.ID(), command.Plan.String()) {
+		log.Info("not autoplanning since %s is disabled for this repo by allowed_commands", command.Plan)
+		return
+	}
 
 	ctx := &command.Context{
-		User:       user,
-		Log:        log,
-		Scope:      scope,
-		Pull:       pull,
-		HeadRepo:   headRepo,
-		PullStatus: status,
-		Trigger:    command.AutoTrigger,
+		User:            user,
+		Log:             log,
+		Scope:           scope,
+		Pull:            pull,
+		HeadRepo:        headRepo,
+		PullStatus:      status,
+		Trigger:         command.AutoTrigger,
+		Superseded:      superseded,
+		RunID:           runID,
+		ProjectOutcomes: command.NewProjectOutcomes(),
+		Span:            span,
+	}
+	if c.RunCanceller != nil {
+		cancelled, done := c.RunCanceller.Start(baseRepo, pull.Num)
+		defer done()
+		ctx.Cancelled = cancelled
 	}
 	if !c.validateCtxAndComment(ctx, command.Autoplan) {
 		return
@@ -192,6 +242,10 @@ func (c *DefaultCommandRunner) RunAutoplanCommand(baseRepo models.Repo, headRepo
 	cmd := &CommentCommand{
 		Name: command.Autoplan,
 	}
+	if c.WorkingDirCleaner != nil {
+		c.WorkingDirCleaner.BeforePlan(ctx.Log, pull)
+		defer func() { c.WorkingDirCleaner.AfterRun(ctx.Log, pull, ctx.ProjectOutcomes.Errored()) }()
+	}
 	err = c.PreWorkflowHooksCommandRunner.RunPreHooks(ctx, cmd)
 
 	if err != nil {
@@ -255,6 +309,21 @@ func (c *DefaultCommandRunner) checkUserPermissions(repo models.Repo, user model
 	return true, nil
 }
 
+// checkCommandAllowed returns false, and comments why, if the repo's
+// allowed_commands doesn't allow the command.
+func (c *DefaultCommandRunner) checkCommandAllowed(log logging.SimpleLogging, baseRepo models.Repo, pullNum int, cmdName command.Name) bool {
+	if c.GlobalCfg.RepoAllowsCommand(baseRepo.ID(), cmdName.String()) {
+		return true
+	}
+	log.Info("%s is disabled for this repo by allowed_commands", cmdName)
+	allowed := c.GlobalCfg.RepoAllowedCommands(baseRepo.ID())
+	comment := fmt.Sprintf("```\nError: %s is disabled for this repo.\nAllowed commands (allowed_commands): %s\n```", cmdName, strings.Join(allowed, ", "))
+	if commentErr := c.VCSClient.CreateComment(log, baseRepo, pullNum, comment, ""); commentErr != nil {
+		log.Err("unable to comment on pull request: %s", commentErr)
+	}
+	return false
+}
+
 // checkVarFilesInPlanCommandAllowlisted checks if paths in a 'plan' command are allowlisted.
 func (c *DefaultCommandRunner) checkVarFilesInPlanCommandAllowlisted(cmd *CommentCommand) error {
 	if cmd == nil || cmd.CommandName() != command.Plan {
@@ -269,7 +338,7 @@ func (c *DefaultCommandRunner) checkVarFilesInPlanCommandAllowlisted(cmd *Commen
 // enough data to construct the Repo model and callers might want to wait until
 // the event is further validated before making an additional (potentially
 // wasteful) call to get the necessary data.
-func (c *DefaultCommandRunner) RunCommentCommand(baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, cmd *CommentCommand) {
+func (c *DefaultCommandRunner) RunCommentCommand(baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, cmd *CommentCommand, runID string) {
 	if opStarted := c.Drainer.StartOp(); !opStarted {
 		if commentErr := c.VCSClient.CreateComment(c.Logger, baseRepo, pullNum, ShutdownComment, ""); commentErr != nil {
 			c.Logger.Log(logging.Error, "unable to comment that Atlantis is shutting down: %s", commentErr)
@@ -278,8 +347,11 @@ func (c *DefaultCommandRunner) RunCommentCommand(baseRepo models.Repo, maybeHead
 	}
 	defer c.Drainer.OpDone()
 
-	log := c.buildLogger(baseRepo.FullName, pullNum)
+	runID = newRunID(runID)
+	log := c.buildLogger(baseRepo.FullName, pullNum, runID)
 	defer c.logPanics(baseRepo, pullNum, log)
+	span := c.startRunSpan(runID, baseRepo, pullNum, cmd.Name.String(), "comment")
+	defer span.End(nil)
 
 	scope := c.StatsScope.SubScope("comment")
 
@@ -299,6 +371,9 @@ func (c *DefaultCommandRunner) RunCommentCommand(baseRepo models.Repo, maybeHead
 		c.commentUserDoesNotHavePermissions(baseRepo, pullNum, user, cmd)
 		return
 	}
+	if !c.checkCommandAllowed(log, baseRepo, pullNum, cmd.Name) {
+		return
+	}
 
 	// Check if the provided var files in a 'plan' command are allowlisted
 	if err := c.checkVarFilesInPlanCommandAllowlisted(cmd); err != nil {
@@ -314,6 +389,10 @@ func (c *DefaultCommandRunner) RunCommentCommand(baseRepo models.Repo, maybeHead
 		return
 	}
 
+	if c.ExpiredPlanDiscarder != nil {
+		c.ExpiredPlanDiscarder.DiscardPull(log, pull)
+	}
+
 	status, err := c.PullStatusFetcher.GetPullStatus(pull)
 
 	if err != nil {
@@ -330,12 +409,39 @@ func (c *DefaultCommandRunner) RunCommentCommand(baseRepo models.Repo, maybeHead
 		Trigger:             command.CommentTrigger,
 		PolicySet:           cmd.PolicySet,
 		ClearPolicyApproval: cmd.ClearPolicyApproval,
+		RunID:               runID,
+		ApplyReason:         cmd.Reason,
+		ApplyTargets:        cmd.Targets,
+		PlanSummary:         cmd.Summary,
+		QueueApply:          cmd.Queue,
+		ProjectOutcomes:     command.NewProjectOutcomes(),
+		Span:                span,
+	}
+	if c.RunCanceller != nil {
+		cancelled, done := c.RunCanceller.Start(baseRepo, pull.Num)
+		defer done()
+		ctx.Cancelled = cancelled
 	}
 
 	if !c.validateCtxAndComment(ctx, cmd.Name) {
 		return
 	}
 
+	// approve-step unblocks a run that's in progress and holds the pull
+	// request's working directory, so it can't run workflow hooks or clean
+	// the directory.
+	if cmd.Name == command.ApproveStep {
+		buildCommentCommandRunner(c, cmd.CommandName()).Run(ctx, cmd)
+		return
+	}
+
+	if c.WorkingDirCleaner != nil {
+		if cmd.Name == command.Plan {
+			c.WorkingDirCleaner.BeforePlan(ctx.Log, pull)
+		}
+		defer func() { c.WorkingDirCleaner.AfterRun(ctx.Log, pull, ctx.ProjectOutcomes.Errored()) }()
+	}
+
 	err = c.PreWorkflowHooksCommandRunner.RunPreHooks(ctx, cmd)
 
 	if err != nil {
@@ -430,14 +536,45 @@ func (c *DefaultCommandRunner) getAzureDevopsData(logger logging.SimpleLogging,
 	return pull, headRepo, nil
 }
 
-func (c *DefaultCommandRunner) buildLogger(repoFullName string, pullNum int) logging.SimpleLogging {
+func (c *DefaultCommandRunner) buildLogger(repoFullName string, pullNum int, runID string) logging.SimpleLogging {
 
 	return c.Logger.WithHistory(
 		"repo", repoFullName,
 		"pull", strconv.Itoa(pullNum),
+		"run-id", runID,
 	)
 }
 
+// startRunSpan starts the span of the run with ID runID of cmdName for the
+// pull request pullNum of baseRepo, triggered by trigger, ex. "comment". It's
+// nil if runs aren't traced.
+func (c *DefaultCommandRunner) startRunSpan(runID string, baseRepo models.Repo, pullNum int, cmdName string, trigger string) *tracing.Span {
+	span := c.Tracer.StartRun(runID, "atlantis "+cmdName)
+	span.SetAttribute("atlantis.repo", baseRepo.FullName)
+	span.SetAttribute("atlantis.pull", pullNum)
+	span.SetAttribute("atlantis.command", cmdName)
+	span.SetAttribute("atlantis.trigger", trigger)
+	return span
+}
+
+// runIDRegex matches the run IDs that can be passed in with a webhook. The ID
+// ends up in comments, logs and step env vars so it's kept to a safe charset.
+var runIDRegex = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// ValidRunID returns true if id can be used as the ID of a run.
+func ValidRunID(id string) bool {
+	return runIDRegex.MatchString(id)
+}
+
+// newRunID returns incoming, the ID of a run passed in with its webhook, or a
+// new ID if it's empty or not a valid run ID.
+func newRunID(incoming string) string {
+	if ValidRunID(incoming) {
+		return incoming
+	}
+	return uuid.NewString()
+}
+
 func (c *DefaultCommandRunner) ensureValidRepoMetadata(
 	baseRepo models.Repo,
 	maybeHeadRepo *models.Repo,
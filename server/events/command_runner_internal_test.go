@@ -1,6 +1,7 @@
 package events
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/runatlantis/atlantis/server/events/command"
@@ -165,6 +166,35 @@ func TestPlanUpdatePlanCommitStatus(t *testing.T) {
 	}
 }
 
+func TestPlanUpdatePlanCommitStatus_PlanSummary(t *testing.T) {
+	pullStatus := models.PullStatus{
+		Projects: []models.ProjectStatus{
+			{
+				Status:    models.PlannedPlanStatus,
+				PlanStats: &models.PlanSuccessStats{Add: 2, Changes: true},
+			},
+			{
+				Status:    models.PlannedPlanStatus,
+				PlanStats: &models.PlanSuccessStats{Change: 1, Destroy: 1, Changes: true},
+			},
+			{
+				Status: models.ErroredPlanStatus,
+			},
+		},
+	}
+	csu := &MockCSU{}
+	cr := &PlanCommandRunner{
+		commitStatusUpdater:  csu,
+		vcsStatusPlanSummary: true,
+	}
+	cr.updateCommitStatus(&command.Context{}, pullStatus, command.Plan)
+	Equals(t, models.FailedCommitStatus, csu.CalledStatus)
+	Equals(t, command.Plan, csu.CalledCommand)
+	Equals(t, 2, csu.CalledNumSuccess)
+	Equals(t, 3, csu.CalledNumTotal)
+	Equals(t, &models.PlanSuccessStats{Add: 2, Change: 1, Destroy: 1, Changes: true}, csu.CalledStats)
+}
+
 func TestPlanUpdateApplyCommitStatus(t *testing.T) {
 	cases := map[string]struct {
 		cmd                  command.Name
@@ -271,6 +301,7 @@ type MockCSU struct {
 	CalledCommand    command.Name
 	CalledNumSuccess int
 	CalledNumTotal   int
+	CalledStats      *models.PlanSuccessStats
 	Called           bool
 }
 
@@ -285,6 +316,18 @@ func (m *MockCSU) UpdateCombinedCount(_ logging.SimpleLogging, repo models.Repo,
 	return nil
 }
 
+func (m *MockCSU) UpdateCombinedPlanSummary(_ logging.SimpleLogging, repo models.Repo, pull models.PullRequest, status models.CommitStatus, numSuccess int, numTotal int, stats models.PlanSuccessStats) error {
+	m.Called = true
+	m.CalledRepo = repo
+	m.CalledPull = pull
+	m.CalledStatus = status
+	m.CalledCommand = command.Plan
+	m.CalledNumSuccess = numSuccess
+	m.CalledNumTotal = numTotal
+	m.CalledStats = &stats
+	return nil
+}
+
 func (m *MockCSU) UpdateCombined(_ logging.SimpleLogging, _ models.Repo, _ models.PullRequest, _ models.CommitStatus, _ command.Name) error {
 	return nil
 }
@@ -300,3 +343,27 @@ func (m *MockCSU) UpdatePreWorkflowHook(_ logging.SimpleLogging, _ models.PullRe
 func (m *MockCSU) UpdatePostWorkflowHook(_ logging.SimpleLogging, _ models.PullRequest, _ models.CommitStatus, _ string, _ string, _ string) error {
 	return nil
 }
+
+func TestNewRunID(t *testing.T) {
+	cases := map[string]bool{
+		"8c1b2d9e":                             true,
+		"3f2c6a1e-8d7b-4c5e-9a0f-1b2c3d4e5f60": true,
+		"build_12.3":                           true,
+		"":                                     false,
+		"`rm -rf /`":                           false,
+		"id with spaces":                       false,
+		"line\nbreak":                          false,
+		strings.Repeat("a", 129):               false,
+	}
+	for incoming, exp := range cases {
+		t.Run(incoming, func(t *testing.T) {
+			runID := newRunID(incoming)
+			if exp {
+				Equals(t, incoming, runID)
+				return
+			}
+			Assert(t, runID != incoming, "exp invalid run ID %q to be replaced", incoming)
+			Assert(t, ValidRunID(runID), "exp generated run ID %q to be valid", runID)
+		})
+	}
+}
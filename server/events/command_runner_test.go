@@ -16,13 +16,19 @@ package events_test
 import (
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/runatlantis/atlantis/server/core/config/valid"
 	"github.com/runatlantis/atlantis/server/core/db"
 	"github.com/runatlantis/atlantis/server/core/locking"
+	"github.com/runatlantis/atlantis/server/core/runtime"
 	"github.com/runatlantis/atlantis/server/events/command"
 	"github.com/runatlantis/atlantis/server/logging"
 	"github.com/runatlantis/atlantis/server/metrics"
@@ -46,6 +52,7 @@ var githubGetter *mocks.MockGithubPullGetter
 var gitlabGetter *mocks.MockGitlabMergeRequestGetter
 var ch events.DefaultCommandRunner
 var workingDir events.WorkingDir
+var workingDirLocker events.WorkingDirLocker
 var pendingPlanFinder *mocks.MockPendingPlanFinder
 var drainer *events.Drainer
 var deleteLockCommand *mocks.MockDeleteLockCommand
@@ -60,11 +67,18 @@ var pullUpdater *events.PullUpdater
 var autoMerger *events.AutoMerger
 var policyCheckCommandRunner *events.PolicyCheckCommandRunner
 var approvePoliciesCommandRunner *events.ApprovePoliciesCommandRunner
+var policyCheckCommentCommandRunner *events.PolicyCheckCommentCommandRunner
 var planCommandRunner *events.PlanCommandRunner
 var applyLockChecker *lockingmocks.MockApplyLockChecker
 var lockingLocker *lockingmocks.MockLocker
 var applyCommandRunner *events.ApplyCommandRunner
 var unlockCommandRunner *events.UnlockCommandRunner
+var discardPlanCommandRunner *events.DiscardPlanCommandRunner
+var lockStatusCommandRunner *events.LockStatusCommandRunner
+var listProjectsCommandRunner *events.ListProjectsCommandRunner
+var explainWorkflowCommandRunner *events.ExplainWorkflowCommandRunner
+var comparePlanCommandRunner *events.ComparePlanCommandRunner
+var stepApprovals *runtime.StepApprovals
 var importCommandRunner *events.ImportCommandRunner
 var preWorkflowHooksCommandRunner events.PreWorkflowHooksCommandRunner
 var postWorkflowHooksCommandRunner events.PostWorkflowHooksCommandRunner
@@ -78,6 +92,7 @@ type TestConfig struct {
 	discardApprovalOnPlan      bool
 	backend                    locking.Backend
 	DisableUnlockLabel         string
+	policyOwners               valid.PolicyOwners
 }
 
 func setup(t *testing.T, options ...func(testConfig *TestConfig)) *vcsmocks.MockClient {
@@ -110,6 +125,7 @@ func setup(t *testing.T, options ...func(testConfig *TestConfig)) *vcsmocks.Mock
 	logger := logging.NewNoopLogger(t)
 	projectCommandRunner = mocks.NewMockProjectCommandRunner()
 	workingDir = mocks.NewMockWorkingDir()
+	workingDirLocker = events.NewDefaultWorkingDirLocker()
 	pendingPlanFinder = mocks.NewMockPendingPlanFinder()
 	commitUpdater = mocks.NewMockCommitStatusUpdater()
 	pullReqStatusFetcher = vcsmocks.NewMockPullReqStatusFetcher()
@@ -163,6 +179,7 @@ func setup(t *testing.T, options ...func(testConfig *TestConfig)) *vcsmocks.Mock
 		lockingLocker,
 		testConfig.discardApprovalOnPlan,
 		pullReqStatusFetcher,
+		false,
 	)
 
 	applyCommandRunner = events.NewApplyCommandRunner(
@@ -193,11 +210,74 @@ func setup(t *testing.T, options ...func(testConfig *TestConfig)) *vcsmocks.Mock
 		vcsClient,
 	)
 
+	policyCheckCommentCommandRunner = events.NewPolicyCheckCommentCommandRunner(
+		commitUpdater,
+		projectCommandBuilder,
+		policyCheckCommandRunner,
+		pullUpdater,
+		true,
+	)
+
 	unlockCommandRunner = events.NewUnlockCommandRunner(
 		deleteLockCommand,
 		vcsClient,
 		testConfig.SilenceNoProjects,
 		testConfig.DisableUnlockLabel,
+		testConfig.policyOwners,
+	)
+
+	discardPlanCommandRunner = events.NewDiscardPlanCommandRunner(
+		vcsClient,
+		pendingPlanFinder,
+		workingDir,
+		workingDirLocker,
+		testConfig.backend,
+		testConfig.policyOwners,
+		testConfig.SilenceNoProjects,
+	)
+
+	pinPlanCommandRunner := events.NewPinPlanCommandRunner(
+		vcsClient,
+		pendingPlanFinder,
+		workingDir,
+		testConfig.policyOwners,
+	)
+
+	lockStatusCommandRunner = events.NewLockStatusCommandRunner(
+		vcsClient,
+		lockingLocker,
+	)
+
+	listProjectsCommandRunner = events.NewListProjectsCommandRunner(
+		vcsClient,
+		projectCommandBuilder,
+		workingDir,
+	)
+
+	explainWorkflowCommandRunner = events.NewExplainWorkflowCommandRunner(
+		vcsClient,
+		projectCommandBuilder,
+	)
+
+	showConfigCommandRunner := events.NewShowConfigCommandRunner(
+		vcsClient,
+		projectCommandBuilder,
+	)
+
+	stepApprovals = runtime.NewStepApprovals(vcsClient, &commentParser)
+	approveStepCommandRunner := events.NewApproveStepCommandRunner(
+		vcsClient,
+		stepApprovals,
+	)
+
+	comparePlanCommandRunner = events.NewComparePlanCommandRunner(
+		vcsClient,
+		pendingPlanFinder,
+		workingDir,
+		workingDirLocker,
+		nil,
+		nil,
+		nil,
 	)
 
 	versionCommandRunner := events.NewVersionCommandRunner(
@@ -220,9 +300,19 @@ func setup(t *testing.T, options ...func(testConfig *TestConfig)) *vcsmocks.Mock
 		command.Plan:            planCommandRunner,
 		command.Apply:           applyCommandRunner,
 		command.ApprovePolicies: approvePoliciesCommandRunner,
+		command.PolicyCheck:     policyCheckCommentCommandRunner,
 		command.Unlock:          unlockCommandRunner,
 		command.Version:         versionCommandRunner,
 		command.Import:          importCommandRunner,
+		command.DiscardPlan:     discardPlanCommandRunner,
+		command.LockStatus:      lockStatusCommandRunner,
+		command.ListProjects:    listProjectsCommandRunner,
+		command.ComparePlan:     comparePlanCommandRunner,
+		command.ExplainWorkflow: explainWorkflowCommandRunner,
+		command.ApproveStep:     approveStepCommandRunner,
+		command.ShowConfig:      showConfigCommandRunner,
+		command.PinPlan:         pinPlanCommandRunner,
+		command.UnpinPlan:       pinPlanCommandRunner,
 	}
 
 	preWorkflowHooksCommandRunner = mocks.NewMockPreWorkflowHooksCommandRunner()
@@ -263,7 +353,7 @@ func TestRunCommentCommand_LogPanics(t *testing.T) {
 	vcsClient := setup(t)
 	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenPanic(
 		"panic test - if you're seeing this in a test failure this isn't the failing test")
-	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, 1, &events.CommentCommand{Name: command.Plan})
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, 1, &events.CommentCommand{Name: command.Plan}, "")
 	_, _, _, comment, _ := vcsClient.VerifyWasCalledOnce().CreateComment(
 		Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]()).GetCapturedArguments()
 	Assert(t, strings.Contains(comment, "Error: goroutine panic"), fmt.Sprintf("comment should be about a goroutine panic but was %q", comment))
@@ -273,7 +363,7 @@ func TestRunCommentCommand_GithubPullErr(t *testing.T) {
 	t.Log("if getting the github pull request fails an error should be logged")
 	vcsClient := setup(t)
 	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(nil, errors.New("err"))
-	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan})
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan}, "")
 	vcsClient.VerifyWasCalledOnce().CreateComment(
 		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num), Eq("`Error: making pull request API call to GitHub: err`"), Eq(""))
 }
@@ -282,7 +372,7 @@ func TestRunCommentCommand_GitlabMergeRequestErr(t *testing.T) {
 	t.Log("if getting the gitlab merge request fails an error should be logged")
 	vcsClient := setup(t)
 	When(gitlabGetter.GetMergeRequest(Any[logging.SimpleLogging](), Eq(testdata.GitlabRepo.FullName), Eq(testdata.Pull.Num))).ThenReturn(nil, errors.New("err"))
-	ch.RunCommentCommand(testdata.GitlabRepo, &testdata.GitlabRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan})
+	ch.RunCommentCommand(testdata.GitlabRepo, &testdata.GitlabRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan}, "")
 	vcsClient.VerifyWasCalledOnce().CreateComment(
 		Any[logging.SimpleLogging](), Eq(testdata.GitlabRepo), Eq(testdata.Pull.Num), Eq("`Error: making merge request API call to GitLab: err`"), Eq(""))
 }
@@ -294,7 +384,7 @@ func TestRunCommentCommand_GithubPullParseErr(t *testing.T) {
 	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(&pull, nil)
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(&pull))).ThenReturn(testdata.Pull, testdata.GithubRepo, testdata.GitlabRepo, errors.New("err"))
 
-	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan})
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan}, "")
 	vcsClient.VerifyWasCalledOnce().CreateComment(
 		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num), Eq("`Error: extracting required fields from comment data: err`"), Eq(""))
 }
@@ -312,10 +402,10 @@ func TestRunCommentCommand_TeamAllowListChecker(t *testing.T) {
 		When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(&pull, nil)
 		When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(&pull))).ThenReturn(modelPull, modelPull.BaseRepo, testdata.GithubRepo, nil)
 
-		ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan})
+		ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan}, "8c1b2d9e")
 		vcsClient.VerifyWasCalled(Never()).GetTeamNamesForUser(testdata.GithubRepo, testdata.User)
 		vcsClient.VerifyWasCalledOnce().CreateComment(
-			Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull.Num), Eq("Ran Plan for 0 projects:"), Eq("plan"))
+			Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull.Num), Eq("Ran Plan for 0 projects:\n\n\n<sub>Run ID: `8c1b2d9e`</sub>"), Eq("plan"))
 	})
 
 	t.Run("no rules", func(t *testing.T) {
@@ -330,10 +420,10 @@ func TestRunCommentCommand_TeamAllowListChecker(t *testing.T) {
 		When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(&pull, nil)
 		When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(&pull))).ThenReturn(modelPull, modelPull.BaseRepo, testdata.GithubRepo, nil)
 
-		ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan})
+		ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan}, "8c1b2d9e")
 		vcsClient.VerifyWasCalled(Never()).GetTeamNamesForUser(testdata.GithubRepo, testdata.User)
 		vcsClient.VerifyWasCalledOnce().CreateComment(
-			Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull.Num), Eq("Ran Plan for 0 projects:"), Eq("plan"))
+			Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull.Num), Eq("Ran Plan for 0 projects:\n\n\n<sub>Run ID: `8c1b2d9e`</sub>"), Eq("plan"))
 	})
 }
 
@@ -356,7 +446,7 @@ func TestRunCommentCommand_ForkPRDisabled(t *testing.T) {
 	headRepo.Owner = "forkrepo"
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(&pull))).ThenReturn(modelPull, modelPull.BaseRepo, headRepo, nil)
 
-	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan})
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan}, "")
 	commentMessage := fmt.Sprintf("Atlantis commands can't be run on fork pull requests. To enable, set --%s  or, to disable this message, set --%s", ch.AllowForkPRsFlag, ch.SilenceForkPRErrorsFlag)
 	vcsClient.VerifyWasCalledOnce().CreateComment(
 		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull.Num), Eq(commentMessage), Eq(""))
@@ -376,7 +466,7 @@ func TestRunCommentCommand_ForkPRDisabled_SilenceEnabled(t *testing.T) {
 	headRepo.Owner = "forkrepo"
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(&pull))).ThenReturn(modelPull, modelPull.BaseRepo, headRepo, nil)
 
-	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan})
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan}, "")
 	vcsClient.VerifyWasCalled(Never()).CreateComment(
 		Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]())
 }
@@ -390,7 +480,7 @@ func TestRunCommentCommandPlan_NoProjects_SilenceEnabled(t *testing.T) {
 	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(&pull, nil)
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(&pull))).ThenReturn(modelPull, modelPull.BaseRepo, testdata.GithubRepo, nil)
 
-	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan})
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan}, "")
 	vcsClient.VerifyWasCalled(Never()).CreateComment(
 		Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]())
 	commitUpdater.VerifyWasCalledOnce().UpdateCombinedCount(
@@ -414,7 +504,7 @@ func TestRunCommentCommandPlan_NoProjectsTarget_SilenceEnabled(t *testing.T) {
 	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(&pull, nil)
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(&pull))).ThenReturn(modelPull, modelPull.BaseRepo, testdata.GithubRepo, nil)
 
-	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan, ProjectName: "meow"})
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan, ProjectName: "meow"}, "")
 	vcsClient.VerifyWasCalled(Never()).CreateComment(
 		Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]())
 	commitUpdater.VerifyWasCalledOnce().UpdateCombinedCount(
@@ -437,7 +527,7 @@ func TestRunCommentCommandApply_NoProjects_SilenceEnabled(t *testing.T) {
 	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(&pull, nil)
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(&pull))).ThenReturn(modelPull, modelPull.BaseRepo, testdata.GithubRepo, nil)
 
-	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Apply})
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Apply}, "")
 	vcsClient.VerifyWasCalled(Never()).CreateComment(
 		Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]())
 	commitUpdater.VerifyWasCalledOnce().UpdateCombinedCount(
@@ -460,7 +550,7 @@ func TestRunCommentCommandApprovePolicy_NoProjects_SilenceEnabled(t *testing.T)
 	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(&pull, nil)
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(&pull))).ThenReturn(modelPull, modelPull.BaseRepo, testdata.GithubRepo, nil)
 
-	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.ApprovePolicies})
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.ApprovePolicies}, "")
 	vcsClient.VerifyWasCalled(Never()).CreateComment(
 		Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]())
 	commitUpdater.VerifyWasCalledOnce().UpdateCombinedCount(
@@ -483,7 +573,7 @@ func TestRunCommentCommandUnlock_NoProjects_SilenceEnabled(t *testing.T) {
 	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(&pull, nil)
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(&pull))).ThenReturn(modelPull, modelPull.BaseRepo, testdata.GithubRepo, nil)
 
-	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Unlock})
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Unlock}, "")
 	vcsClient.VerifyWasCalled(Never()).CreateComment(Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]())
 }
 
@@ -496,7 +586,7 @@ func TestRunCommentCommandImport_NoProjects_SilenceEnabled(t *testing.T) {
 	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(&pull, nil)
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(&pull))).ThenReturn(modelPull, modelPull.BaseRepo, testdata.GithubRepo, nil)
 
-	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Import})
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Import}, "")
 	vcsClient.VerifyWasCalled(Never()).CreateComment(Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]())
 }
 
@@ -512,7 +602,7 @@ func TestRunCommentCommand_DisableApplyAllDisabled(t *testing.T) {
 	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(pull, nil)
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo, testdata.GithubRepo, nil)
 
-	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, modelPull.Num, &events.CommentCommand{Name: command.Apply})
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, modelPull.Num, &events.CommentCommand{Name: command.Apply}, "")
 	vcsClient.VerifyWasCalledOnce().CreateComment(
 		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull.Num),
 		Eq("**Error:** Running `atlantis apply` without flags is disabled. You must specify which project to apply via the `-d <dir>`, `-w <workspace>` or `-p <project name>` flags."), Eq("apply"))
@@ -536,7 +626,7 @@ func TestRunCommentCommand_DisableAutoplan(t *testing.T) {
 			},
 		}, nil)
 
-	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, modelPull, testdata.User)
+	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, modelPull, testdata.User, "")
 	projectCommandBuilder.VerifyWasCalled(Never()).BuildAutoplanCommands(Any[*command.Context]())
 }
 
@@ -560,7 +650,7 @@ func TestRunCommentCommand_DisableAutoplanLabel(t *testing.T) {
 	When(ch.VCSClient.GetPullLabels(
 		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull))).ThenReturn([]string{"disable-auto-plan", "need-help"}, nil)
 
-	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, modelPull, testdata.User)
+	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, modelPull, testdata.User, "")
 	projectCommandBuilder.VerifyWasCalled(Never()).BuildAutoplanCommands(Any[*command.Context]())
 	vcsClient.VerifyWasCalledOnce().GetPullLabels(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull))
 }
@@ -584,7 +674,7 @@ func TestRunCommentCommand_DisableAutoplanLabel_PullNotLabeled(t *testing.T) {
 		}, nil)
 	When(ch.VCSClient.GetPullLabels(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull))).ThenReturn(nil, nil)
 
-	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, modelPull, testdata.User)
+	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, modelPull, testdata.User, "")
 	projectCommandBuilder.VerifyWasCalled(Once()).BuildAutoplanCommands(Any[*command.Context]())
 	vcsClient.VerifyWasCalledOnce().GetPullLabels(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull))
 }
@@ -600,7 +690,7 @@ func TestRunCommentCommand_ClosedPull(t *testing.T) {
 	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(pull, nil)
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo, testdata.GithubRepo, nil)
 
-	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan})
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan}, "")
 	vcsClient.VerifyWasCalledOnce().CreateComment(
 		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull.Num), Eq("Atlantis commands can't be run on closed pull requests"), Eq(""))
 }
@@ -618,9 +708,9 @@ func TestRunCommentCommand_MatchedBranch(t *testing.T) {
 	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(&pull, nil)
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(&pull))).ThenReturn(modelPull, modelPull.BaseRepo, testdata.GithubRepo, nil)
 
-	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan})
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan}, "8c1b2d9e")
 	vcsClient.VerifyWasCalledOnce().CreateComment(
-		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull.Num), Eq("Ran Plan for 0 projects:"), Eq("plan"))
+		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull.Num), Eq("Ran Plan for 0 projects:\n\n\n<sub>Run ID: `8c1b2d9e`</sub>"), Eq("plan"))
 }
 
 func TestRunCommentCommand_UnmatchedBranch(t *testing.T) {
@@ -636,7 +726,40 @@ func TestRunCommentCommand_UnmatchedBranch(t *testing.T) {
 	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(&pull, nil)
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(&pull))).ThenReturn(modelPull, modelPull.BaseRepo, testdata.GithubRepo, nil)
 
-	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan})
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan}, "")
+	vcsClient.VerifyWasCalled(Never()).CreateComment(Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]())
+}
+
+func TestRunCommentCommand_CommandNotAllowed(t *testing.T) {
+	t.Log("if a command isn't in the repo's allowed_commands atlantis should" +
+		" comment saying it's disabled and not run it")
+	vcsClient := setup(t)
+
+	ch.GlobalCfg.Repos = append(ch.GlobalCfg.Repos, valid.Repo{
+		IDRegex:         regexp.MustCompile(".*"),
+		AllowedCommands: []string{"plan", "policy_check"},
+	})
+
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Apply}, "")
+	vcsClient.VerifyWasCalledOnce().CreateComment(
+		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num),
+		Eq("```\nError: apply is disabled for this repo.\nAllowed commands (allowed_commands): plan, policy_check\n```"), Eq(""))
+	githubGetter.VerifyWasCalled(Never()).GetPullRequest(Any[logging.SimpleLogging](), Any[models.Repo](), Any[int]())
+	projectCommandBuilder.VerifyWasCalled(Never()).BuildApplyCommands(Any[*command.Context](), Any[*events.CommentCommand]())
+}
+
+func TestRunAutoplanCommand_PlanNotAllowed(t *testing.T) {
+	t.Log("if plan isn't in the repo's allowed_commands autoplan shouldn't run")
+	vcsClient := setup(t)
+
+	ch.GlobalCfg.Repos = append(ch.GlobalCfg.Repos, valid.Repo{
+		IDRegex:         regexp.MustCompile(".*"),
+		AllowedCommands: []string{"version"},
+	})
+	modelPull := models.PullRequest{BaseRepo: testdata.GithubRepo, BaseBranch: "main", State: models.OpenPullState}
+
+	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, modelPull, testdata.User, "")
+	projectCommandBuilder.VerifyWasCalled(Never()).BuildAutoplanCommands(Any[*command.Context]())
 	vcsClient.VerifyWasCalled(Never()).CreateComment(Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]())
 }
 
@@ -672,7 +795,7 @@ func TestRunUnlockCommand_VCSComment(t *testing.T) {
 				testdata.GithubRepo, nil)
 
 			ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num,
-				&events.CommentCommand{Name: command.Unlock})
+				&events.CommentCommand{Name: command.Unlock}, "")
 
 			deleteLockCommand.VerifyWasCalledOnce().DeleteLocksByPull(Any[logging.SimpleLogging](),
 				Eq(testdata.GithubRepo.FullName), Eq(testdata.Pull.Num))
@@ -697,15 +820,850 @@ func TestRunUnlockCommandFail_VCSComment(t *testing.T) {
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo,
 		testdata.GithubRepo, nil)
 	When(deleteLockCommand.DeleteLocksByPull(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo.FullName),
-		Eq(testdata.Pull.Num))).ThenReturn(0, errors.New("err"))
+		Eq(testdata.Pull.Num))).ThenReturn(nil, errors.New("err"))
 
 	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num,
-		&events.CommentCommand{Name: command.Unlock})
+		&events.CommentCommand{Name: command.Unlock}, "")
 
 	vcsClient.VerifyWasCalledOnce().CreateComment(
 		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num), Eq("Failed to delete PR locks"), Eq("unlock"))
 }
 
+func TestRunUnlockAllCommand_VCSComment(t *testing.T) {
+	locks := []models.ProjectLock{
+		{Project: models.Project{RepoFullName: testdata.GithubRepo.FullName, Path: "."}, Workspace: "default"},
+		{Project: models.Project{RepoFullName: testdata.GithubRepo.FullName, Path: "staging", ProjectName: "staging"}, Workspace: "default"},
+	}
+	cases := []struct {
+		name         string
+		author       string
+		policyOwners valid.PolicyOwners
+		userTeams    []string
+		locks        []models.ProjectLock
+		// plainUnlock runs unlock without --all.
+		plainUnlock bool
+		expUnlocked bool
+		expComment  string
+	}{
+		{
+			name:        "author",
+			author:      testdata.User.Username,
+			locks:       locks,
+			expUnlocked: true,
+			expComment:  "Unlocked 2 lock(s) and discarded their plans:\n\n- dir: `.` workspace: `default`\n- project: `staging` dir: `staging` workspace: `default`",
+		},
+		{
+			name:         "policy owner",
+			author:       "someone-else",
+			policyOwners: valid.PolicyOwners{Users: []string{testdata.User.Username}},
+			locks:        locks[:1],
+			expUnlocked:  true,
+			expComment:   "Unlocked 1 lock(s) and discarded their plans:\n\n- dir: `.` workspace: `default`",
+		},
+		{
+			name:         "policy owner team",
+			author:       "someone-else",
+			policyOwners: valid.PolicyOwners{Teams: []string{"platform"}},
+			userTeams:    []string{"platform"},
+			expUnlocked:  true,
+			expComment:   "No Atlantis locks found for this PR",
+		},
+		{
+			name:         "other user",
+			author:       "someone-else",
+			policyOwners: valid.PolicyOwners{Users: []string{"admin"}, Teams: []string{"platform"}},
+			userTeams:    []string{"developers"},
+			expComment:   "Not unlocking: only the pull request's author or a policy owner can run `unlock --all`",
+		},
+		{
+			name:         "other user without --all",
+			author:       "someone-else",
+			policyOwners: valid.PolicyOwners{Users: []string{"admin"}},
+			locks:        locks,
+			plainUnlock:  true,
+			expUnlocked:  true,
+			expComment:   "All Atlantis locks for this PR have been unlocked and plans discarded",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			vcsClient := setup(t, func(tc *TestConfig) {
+				tc.policyOwners = c.policyOwners
+				tc.DisableUnlockLabel = ""
+			})
+			pull := &github.PullRequest{
+				State: github.String("open"),
+			}
+			modelPull := models.PullRequest{BaseRepo: testdata.GithubRepo, State: models.OpenPullState, Num: testdata.Pull.Num, Author: c.author}
+			When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo),
+				Eq(testdata.Pull.Num))).ThenReturn(pull, nil)
+			When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo,
+				testdata.GithubRepo, nil)
+			When(vcsClient.GetTeamNamesForUser(Any[models.Repo](), Any[models.User]())).ThenReturn(c.userTeams, nil)
+			When(deleteLockCommand.DeleteLocksByPull(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo.FullName),
+				Eq(testdata.Pull.Num))).ThenReturn(c.locks, nil)
+
+			ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num,
+				&events.CommentCommand{Name: command.Unlock, UnlockAll: !c.plainUnlock}, "")
+
+			if c.expUnlocked {
+				deleteLockCommand.VerifyWasCalledOnce().DeleteLocksByPull(Any[logging.SimpleLogging](),
+					Eq(testdata.GithubRepo.FullName), Eq(testdata.Pull.Num))
+			} else {
+				deleteLockCommand.VerifyWasCalled(Never()).DeleteLocksByPull(Any[logging.SimpleLogging](),
+					Any[string](), Any[int]())
+			}
+			vcsClient.VerifyWasCalledOnce().CreateComment(
+				Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num), Eq(c.expComment), Eq("unlock"))
+		})
+	}
+}
+
+func TestRunDiscardPlanCommand_VCSComment(t *testing.T) {
+	cases := []struct {
+		name         string
+		cmd          *events.CommentCommand
+		author       string
+		policyOwners valid.PolicyOwners
+		lockedDir    string
+		expDiscarded []events.PendingPlan
+		expComment   string
+	}{
+		{
+			name: "all plans",
+			cmd:  &events.CommentCommand{Name: command.DiscardPlan},
+			expDiscarded: []events.PendingPlan{
+				{RepoRelDir: ".", Workspace: "default"},
+				{RepoRelDir: "staging", Workspace: "default", ProjectName: "staging"},
+				{RepoRelDir: "staging", Workspace: "prod", ProjectName: "staging"},
+			},
+			expComment: "Discarded 3 plan(s), run `atlantis plan` to plan again:\n\n- dir: `.` workspace: `default`\n- project: `staging` dir: `staging` workspace: `default`\n- project: `staging` dir: `staging` workspace: `prod`",
+		},
+		{
+			name: "dir",
+			cmd:  &events.CommentCommand{Name: command.DiscardPlan, RepoRelDir: "staging"},
+			expDiscarded: []events.PendingPlan{
+				{RepoRelDir: "staging", Workspace: "default", ProjectName: "staging"},
+			},
+			expComment: "Discarded 1 plan(s), run `atlantis plan` to plan again:\n\n- project: `staging` dir: `staging` workspace: `default`",
+		},
+		{
+			name: "workspace",
+			cmd:  &events.CommentCommand{Name: command.DiscardPlan, Workspace: "default"},
+			expDiscarded: []events.PendingPlan{
+				{RepoRelDir: ".", Workspace: "default"},
+			},
+			expComment: "Discarded 1 plan(s), run `atlantis plan` to plan again:\n\n- dir: `.` workspace: `default`",
+		},
+		{
+			name: "project",
+			cmd:  &events.CommentCommand{Name: command.DiscardPlan, ProjectName: "staging"},
+			expDiscarded: []events.PendingPlan{
+				{RepoRelDir: "staging", Workspace: "default", ProjectName: "staging"},
+				{RepoRelDir: "staging", Workspace: "prod", ProjectName: "staging"},
+			},
+			expComment: "Discarded 2 plan(s), run `atlantis plan` to plan again:\n\n- project: `staging` dir: `staging` workspace: `default`\n- project: `staging` dir: `staging` workspace: `prod`",
+		},
+		{
+			name: "project and workspace",
+			cmd:  &events.CommentCommand{Name: command.DiscardPlan, ProjectName: "staging", Workspace: "prod"},
+			expDiscarded: []events.PendingPlan{
+				{RepoRelDir: "staging", Workspace: "prod", ProjectName: "staging"},
+			},
+			expComment: "Discarded 1 plan(s), run `atlantis plan` to plan again:\n\n- project: `staging` dir: `staging` workspace: `prod`",
+		},
+		{
+			name:       "no matching plans",
+			cmd:        &events.CommentCommand{Name: command.DiscardPlan, ProjectName: "production"},
+			expComment: "No plans found to discard",
+		},
+		{
+			name:         "policy owner",
+			cmd:          &events.CommentCommand{Name: command.DiscardPlan, Workspace: "default"},
+			author:       "someone-else",
+			policyOwners: valid.PolicyOwners{Users: []string{testdata.User.Username}},
+			expDiscarded: []events.PendingPlan{
+				{RepoRelDir: ".", Workspace: "default"},
+			},
+			expComment: "Discarded 1 plan(s), run `atlantis plan` to plan again:\n\n- dir: `.` workspace: `default`",
+		},
+		{
+			name:       "other user",
+			cmd:        &events.CommentCommand{Name: command.DiscardPlan},
+			author:     "someone-else",
+			expComment: "Not discarding plans: only the pull request's author or a policy owner can run `discard-plan`",
+		},
+		{
+			name:       "workspace in use",
+			cmd:        &events.CommentCommand{Name: command.DiscardPlan, RepoRelDir: "staging"},
+			lockedDir:  "staging",
+			expComment: "Failed to discard plans: the default workspace at path staging is currently locked by another command that is running for this pull request.\nWait until the previous command is complete and try again",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			vcsClient := setup(t, func(tc *TestConfig) {
+				tc.policyOwners = c.policyOwners
+			})
+			pull := &github.PullRequest{
+				State: github.String("open"),
+			}
+			author := c.author
+			if author == "" {
+				author = testdata.User.Username
+			}
+			modelPull := models.PullRequest{BaseRepo: testdata.GithubRepo, State: models.OpenPullState, Num: testdata.Pull.Num, Author: author}
+			When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo),
+				Eq(testdata.Pull.Num))).ThenReturn(pull, nil)
+			When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo,
+				testdata.GithubRepo, nil)
+			tmp := t.TempDir()
+			When(workingDir.GetPullDir(Any[models.Repo](), Any[models.PullRequest]())).ThenReturn(tmp, nil)
+			When(pendingPlanFinder.Find(tmp)).ThenReturn([]events.PendingPlan{
+				{RepoDir: tmp, RepoRelDir: ".", Workspace: "default"},
+				{RepoDir: tmp, RepoRelDir: "staging", Workspace: "default", ProjectName: "staging"},
+				{RepoDir: tmp, RepoRelDir: "staging", Workspace: "prod", ProjectName: "staging"},
+			}, nil)
+			if c.lockedDir != "" {
+				unlockFn, err := workingDirLocker.TryLock(testdata.GithubRepo.FullName, testdata.Pull.Num, "default", c.lockedDir)
+				Ok(t, err)
+				defer unlockFn()
+			}
+
+			ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, c.cmd, "")
+
+			mockWorkingDir := workingDir.(*mocks.MockWorkingDir)
+			for _, plan := range c.expDiscarded {
+				mockWorkingDir.VerifyWasCalledOnce().DeletePlan(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull),
+					Eq(plan.Workspace), Eq(plan.RepoRelDir), Eq(plan.ProjectName))
+			}
+			mockWorkingDir.VerifyWasCalled(Times(len(c.expDiscarded))).DeletePlan(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+				Any[string](), Any[string](), Any[string]())
+			vcsClient.VerifyWasCalledOnce().CreateComment(
+				Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num), Eq(c.expComment), Eq("discard-plan"))
+		})
+	}
+}
+
+func TestRunPinPlanCommand_VCSComment(t *testing.T) {
+	cases := []struct {
+		name         string
+		cmd          *events.CommentCommand
+		author       string
+		policyOwners valid.PolicyOwners
+		pinned       bool
+		expPinned    []string
+		expComment   string
+	}{
+		{
+			name:       "pin all plans",
+			cmd:        &events.CommentCommand{Name: command.PinPlan},
+			author:     testdata.User.Username,
+			expPinned:  []string{"default.tfplan", "staging/staging-default.tfplan"},
+			expComment: "Pinned 2 plan(s), they won't be discarded by plan_ttl or new commits until they're applied or unpinned with `atlantis unpin-plan`:\n\n- 📌 dir: `.` workspace: `default`\n- 📌 project: `staging` dir: `staging` workspace: `default`",
+		},
+		{
+			name:         "pin project as policy owner",
+			cmd:          &events.CommentCommand{Name: command.PinPlan, ProjectName: "staging"},
+			author:       "someone-else",
+			policyOwners: valid.PolicyOwners{Users: []string{testdata.User.Username}},
+			expPinned:    []string{"staging/staging-default.tfplan"},
+			expComment:   "Pinned 1 plan(s), they won't be discarded by plan_ttl or new commits until they're applied or unpinned with `atlantis unpin-plan`:\n\n- 📌 project: `staging` dir: `staging` workspace: `default`",
+		},
+		{
+			name:       "pin as other user",
+			cmd:        &events.CommentCommand{Name: command.PinPlan},
+			author:     "someone-else",
+			expComment: "Not pinning plans: only the pull request's author or a policy owner can run `pin-plan`",
+		},
+		{
+			name:       "no matching plans",
+			cmd:        &events.CommentCommand{Name: command.PinPlan, ProjectName: "production"},
+			author:     testdata.User.Username,
+			expComment: "No plans found to pin",
+		},
+		{
+			name:       "unpin",
+			cmd:        &events.CommentCommand{Name: command.UnpinPlan, RepoRelDir: "staging"},
+			author:     testdata.User.Username,
+			pinned:     true,
+			expPinned:  []string{"default.tfplan"},
+			expComment: "Unpinned 1 plan(s), they'll be discarded by plan_ttl and new commits again:\n\n- project: `staging` dir: `staging` workspace: `default`",
+		},
+		{
+			name:       "unpin without pinned plans",
+			cmd:        &events.CommentCommand{Name: command.UnpinPlan},
+			author:     testdata.User.Username,
+			expComment: "No pinned plans found to unpin",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			vcsClient := setup(t, func(tc *TestConfig) {
+				tc.policyOwners = c.policyOwners
+			})
+			pull := &github.PullRequest{
+				State: github.String("open"),
+			}
+			modelPull := models.PullRequest{BaseRepo: testdata.GithubRepo, State: models.OpenPullState, Num: testdata.Pull.Num, Author: c.author}
+			When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo),
+				Eq(testdata.Pull.Num))).ThenReturn(pull, nil)
+			When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo,
+				testdata.GithubRepo, nil)
+			tmp := t.TempDir()
+			Ok(t, os.Mkdir(filepath.Join(tmp, ".git"), 0700))
+			planFiles := []string{"default.tfplan", "staging/staging-default.tfplan"}
+			for _, planFile := range planFiles {
+				Ok(t, os.MkdirAll(filepath.Dir(filepath.Join(tmp, planFile)), 0700))
+				Ok(t, os.WriteFile(filepath.Join(tmp, planFile), nil, 0600))
+				if c.pinned {
+					Ok(t, runtime.PinPlan(filepath.Join(tmp, planFile), testdata.User.Username))
+				}
+			}
+			When(workingDir.GetPullDir(Any[models.Repo](), Any[models.PullRequest]())).ThenReturn(tmp, nil)
+			When(pendingPlanFinder.Find(tmp)).ThenReturn([]events.PendingPlan{
+				{RepoDir: tmp, RepoRelDir: ".", Workspace: "default", Pinned: c.pinned},
+				{RepoDir: tmp, RepoRelDir: "staging", Workspace: "default", ProjectName: "staging", Pinned: c.pinned},
+			}, nil)
+
+			ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, c.cmd, "")
+
+			for _, planFile := range planFiles {
+				_, pinned := runtime.PlanPinnedBy(filepath.Join(tmp, planFile))
+				Equals(t, slices.Contains(c.expPinned, planFile), pinned)
+			}
+			vcsClient.VerifyWasCalledOnce().CreateComment(
+				Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num), Eq(c.expComment), Eq(c.cmd.Name.String()))
+		})
+	}
+}
+
+func TestRunLockStatusCommand_VCSComment(t *testing.T) {
+	lockTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	locks := map[string]models.ProjectLock{
+		"runatlantis/atlantis/staging/default": {
+			Project:   models.Project{RepoFullName: testdata.GithubRepo.FullName, Path: "staging", ProjectName: "staging"},
+			Workspace: "default",
+			Pull:      models.PullRequest{Num: testdata.Pull.Num, URL: "https://github.com/runatlantis/atlantis/pull/1"},
+			User:      models.User{Username: "lkysow"},
+			Time:      lockTime,
+		},
+		"runatlantis/atlantis/./default": {
+			Project:   models.Project{RepoFullName: testdata.GithubRepo.FullName, Path: "."},
+			Workspace: "default",
+			Pull:      models.PullRequest{Num: 7, URL: "https://github.com/runatlantis/atlantis/pull/7"},
+			User:      models.User{Username: "acme"},
+			Time:      lockTime,
+		},
+		"other/repo/./default": {
+			Project:   models.Project{RepoFullName: "other/repo", Path: "."},
+			Workspace: "default",
+			Pull:      models.PullRequest{Num: 3},
+			User:      models.User{Username: "someone"},
+			Time:      lockTime,
+		},
+	}
+	footer := "\n\nLocks are released when their pull request is merged or closed, by running `atlantis unlock` on it or with the Atlantis UI."
+	header := "| Project | Dir | Workspace | Pull Request | Locked By | Locked At |\n| --- | --- | --- | --- | --- | --- |\n"
+	rootRow := "|  | `.` | `default` | [#7](https://github.com/runatlantis/atlantis/pull/7) | @acme | 2024-05-01 12:00:00 UTC |"
+	stagingRow := fmt.Sprintf("| staging | `staging` | `default` | [#%d](https://github.com/runatlantis/atlantis/pull/1) (this pull request) | @lkysow | 2024-05-01 12:00:00 UTC |", testdata.Pull.Num)
+	cases := []struct {
+		name       string
+		cmd        *events.CommentCommand
+		expComment string
+	}{
+		{
+			name:       "all locks in repo",
+			cmd:        &events.CommentCommand{Name: command.LockStatus},
+			expComment: "2 project(s) locked:\n\n" + header + rootRow + "\n" + stagingRow + footer,
+		},
+		{
+			name:       "dir",
+			cmd:        &events.CommentCommand{Name: command.LockStatus, RepoRelDir: "."},
+			expComment: "1 project(s) locked:\n\n" + header + rootRow + footer,
+		},
+		{
+			name:       "project",
+			cmd:        &events.CommentCommand{Name: command.LockStatus, ProjectName: "staging"},
+			expComment: "1 project(s) locked:\n\n" + header + stagingRow + footer,
+		},
+		{
+			name:       "no matching locks",
+			cmd:        &events.CommentCommand{Name: command.LockStatus, Workspace: "production"},
+			expComment: "No matching projects are locked.",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			vcsClient := setup(t)
+			pull := &github.PullRequest{
+				State: github.String("open"),
+			}
+			modelPull := models.PullRequest{BaseRepo: testdata.GithubRepo, State: models.OpenPullState, Num: testdata.Pull.Num}
+			When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo),
+				Eq(testdata.Pull.Num))).ThenReturn(pull, nil)
+			When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo,
+				testdata.GithubRepo, nil)
+			When(lockingLocker.List()).ThenReturn(locks, nil)
+
+			ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, c.cmd, "")
+
+			vcsClient.VerifyWasCalledOnce().CreateComment(
+				Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num), Eq(c.expComment), Eq("lock-status"))
+		})
+	}
+}
+
+func TestRunLockStatusCommand_NoLocks(t *testing.T) {
+	vcsClient := setup(t)
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{BaseRepo: testdata.GithubRepo, State: models.OpenPullState, Num: testdata.Pull.Num}
+	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo),
+		Eq(testdata.Pull.Num))).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo,
+		testdata.GithubRepo, nil)
+	When(lockingLocker.List()).ThenReturn(map[string]models.ProjectLock{}, nil)
+
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.LockStatus}, "")
+
+	vcsClient.VerifyWasCalledOnce().CreateComment(
+		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num), Eq("No projects in this repo are locked."), Eq("lock-status"))
+}
+
+func TestRunApproveStepCommand(t *testing.T) {
+	vcsClient := setup(t)
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{BaseRepo: testdata.GithubRepo, State: models.OpenPullState, Num: testdata.Pull.Num}
+	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo),
+		Eq(testdata.Pull.Num))).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo,
+		testdata.GithubRepo, nil)
+
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.ApproveStep}, "")
+	vcsClient.VerifyWasCalledOnce().CreateComment(
+		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num),
+		Eq("No matching steps are waiting for approval. Steps stop waiting once they time out, their pull request is closed or Atlantis restarts, after which the command has to be run again to request a new approval."),
+		Eq("approve-step"))
+
+	ctx := command.ProjectContext{
+		Log:        logging.NewNoopLogger(t),
+		BaseRepo:   testdata.GithubRepo,
+		Pull:       modelPull,
+		User:       models.User{Username: "alice"},
+		RepoRelDir: "dir",
+		Workspace:  "default",
+	}
+	waitErr := make(chan error, 1)
+	go func() {
+		waitErr <- stepApprovals.Wait(ctx, valid.Step{StepName: "run", RunCommand: "./wipe.sh", RequireApproval: true})
+	}()
+	// Wait until the step is waiting for approval.
+	for i := 0; ; i++ {
+		waiting := false
+		stepApprovals.Approve(testdata.GithubRepo.FullName, testdata.Pull.Num, "", func(runtime.PendingStepApproval) bool {
+			waiting = true
+			return false
+		})
+		if waiting {
+			break
+		}
+		Assert(t, i < 500, "timed out waiting for the step to wait for approval")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Steps of other projects aren't approved.
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, models.User{Username: "bob"}, testdata.Pull.Num, &events.CommentCommand{Name: command.ApproveStep, ProjectName: "other"}, "")
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, models.User{Username: "alice"}, testdata.Pull.Num, &events.CommentCommand{Name: command.ApproveStep}, "")
+	vcsClient.VerifyWasCalledOnce().CreateComment(
+		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num),
+		Eq("@alice can't approve these steps since they ran the command the steps are part of, someone else has to approve them:\n\n* `./wipe.sh` for dir: `dir` workspace: `default`"),
+		Eq("approve-step"))
+
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, models.User{Username: "bob"}, testdata.Pull.Num, &events.CommentCommand{Name: command.ApproveStep, RepoRelDir: "dir"}, "")
+	vcsClient.VerifyWasCalledOnce().CreateComment(
+		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num),
+		Eq("Approved 1 step(s), they're running now:\n\n* `./wipe.sh` for dir: `dir` workspace: `default`"),
+		Eq("approve-step"))
+	Ok(t, <-waitErr)
+
+	// approve-step doesn't run workflow hooks since the run it unblocks holds
+	// the working directory.
+	preWorkflowHooksCommandRunner.(*mocks.MockPreWorkflowHooksCommandRunner).VerifyWasCalled(Never()).RunPreHooks(Any[*command.Context](), Any[*events.CommentCommand]())
+}
+
+func TestRunListProjectsCommand_VCSComment(t *testing.T) {
+	vcsClient := setup(t)
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{BaseRepo: testdata.GithubRepo, State: models.OpenPullState, Num: testdata.Pull.Num, HeadCommit: "abc123"}
+	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo),
+		Eq(testdata.Pull.Num))).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo,
+		testdata.GithubRepo, nil)
+	repoDir := t.TempDir()
+	Ok(t, os.Mkdir(filepath.Join(repoDir, ".git"), 0700))
+	When(workingDir.GetWorkingDir(Any[models.Repo](), Any[models.PullRequest](), Eq(events.DefaultWorkspace))).ThenReturn(repoDir, nil)
+	When(projectCommandBuilder.BuildPlanCommands(Any[*command.Context](), Any[*events.CommentCommand]())).ThenReturn([]command.ProjectContext{
+		{RepoRelDir: "network", Workspace: "default", ProjectName: "network"},
+		{RepoRelDir: ".", Workspace: "staging"},
+	}, nil)
+
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.ListProjects}, "")
+
+	expComment := "2 project(s) in this pull request:\n\n" +
+		"| Index | Project | Dir | Workspace |\n| --- | --- | --- | --- |\n" +
+		"| 1 | network | `network` | `default` |\n" +
+		"| 2 |  | `.` | `staging` |\n" +
+		"\nRun `atlantis plan -i INDEX` or `atlantis apply -i INDEX` to target a project. The indexes are for commit abc123, run `atlantis list-projects` again after pushing."
+	vcsClient.VerifyWasCalledOnce().CreateComment(
+		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num), Eq(expComment), Eq("list-projects"))
+
+	index, err := events.ReadProjectIndex(workingDir, &command.Context{Pull: modelPull})
+	Ok(t, err)
+	Equals(t, events.ProjectIndex{
+		HeadCommit: "abc123",
+		Projects: []events.ProjectIndexEntry{
+			{ProjectName: "network", RepoRelDir: "network", Workspace: "default"},
+			{RepoRelDir: ".", Workspace: "staging"},
+		},
+	}, index)
+}
+
+func TestRunExplainWorkflowCommand_VCSComment(t *testing.T) {
+	vcsClient := setup(t)
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{BaseRepo: testdata.GithubRepo, State: models.OpenPullState, Num: testdata.Pull.Num}
+	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo),
+		Eq(testdata.Pull.Num))).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo,
+		testdata.GithubRepo, nil)
+	When(projectCommandBuilder.BuildPlanCommands(Any[*command.Context](), Any[*events.CommentCommand]())).ThenReturn([]command.ProjectContext{
+		{
+			RepoRelDir:   "app",
+			Workspace:    "default",
+			ProjectName:  "app",
+			WorkflowName: "custom",
+			WorkflowTrace: []string{
+				`default server config sets workflow "default"`,
+				`repos[1], id: /.*/ in the server-side repo config sets workflow "shared"`,
+				`project "app" in the repo config sets workflow "custom", defined in the repo config`,
+			},
+		},
+	}, nil)
+
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.ExplainWorkflow, ProjectName: "app"}, "")
+
+	expComment := "Workflows of 1 project(s), with the configs that set them in the order they're applied. The last one is used.\n" +
+		"\n#### project: `app` dir: `app` workspace: `default`\n" +
+		"Workflow: **`custom`**\n\n" +
+		"1. default server config sets workflow \"default\"\n" +
+		"2. repos[1], id: /.*/ in the server-side repo config sets workflow \"shared\"\n" +
+		"3. project \"app\" in the repo config sets workflow \"custom\", defined in the repo config **(used)**\n"
+	vcsClient.VerifyWasCalledOnce().CreateComment(
+		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num), Eq(expComment), Eq("explain-workflow"))
+	_, cmd := projectCommandBuilder.VerifyWasCalledOnce().BuildPlanCommands(Any[*command.Context](), Any[*events.CommentCommand]()).GetCapturedArguments()
+	Equals(t, command.Plan, cmd.Name)
+	Equals(t, "app", cmd.ProjectName)
+}
+
+func TestRunShowConfigCommand_VCSComment(t *testing.T) {
+	vcsClient := setup(t)
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{BaseRepo: testdata.GithubRepo, State: models.OpenPullState, Num: testdata.Pull.Num}
+	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo),
+		Eq(testdata.Pull.Num))).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo,
+		testdata.GithubRepo, nil)
+	rateLimit, err := valid.ParseRateLimit("cloud-api:5/s")
+	Ok(t, err)
+	condition, err := valid.ParseStepCondition("workspace == 'prod'")
+	Ok(t, err)
+	jq, err := valid.ParseToolRequirement("jq>=1.6")
+	Ok(t, err)
+	When(projectCommandBuilder.BuildPlanCommands(Any[*command.Context](), Any[*events.CommentCommand]())).ThenReturn([]command.ProjectContext{
+		{
+			RepoRelDir:        "app",
+			Workspace:         "default",
+			ProjectName:       "app",
+			RepoConfigFile:    "atlantis.yaml",
+			AutoplanEnabled:   true,
+			TFVars:            map[string]string{"db_password": "hunter2"},
+			PlanRequirements:  []string{},
+			ApplyRequirements: []string{"approved", "mergeable"},
+			RepoLocksMode:     valid.RepoLocksOnPlanMode,
+			PlanTTL:           time.Hour,
+			WorkflowName:      "custom",
+			Workflow: valid.Workflow{
+				Name: "custom",
+				Plan: valid.Stage{Steps: []valid.Step{
+					{StepName: "env", EnvVarName: "TOKEN", EnvVarValue: "s3cr3t", MaskInComment: true, MaskInLog: true},
+					{StepName: "env", EnvVarName: "REGION", EnvVarValue: "us-east-1", MaskInLog: true},
+					{StepName: "init"},
+					{StepName: "plan", ExtraArgs: []string{"-var=token=t0k3n"}, Parallelism: 20},
+					{
+						StepName:         "run",
+						RunCommand:       "./check.sh",
+						Output:           valid.PostProcessRunOutputShow,
+						Stream:           true,
+						Always:           "rm -rf tmp",
+						Stdin:            "password=$DB_PASSWORD",
+						RequireTools:     []valid.ToolRequirement{jq},
+						RateLimit:        &rateLimit,
+						If:               condition,
+						Cache:            &valid.StepCache{Key: "deps", Paths: []string{".deps"}},
+						MemoryLimit:      512 << 20,
+						Verify:           &valid.StepVerify{File: "tool", Algorithm: valid.ChecksumSHA256, Checksum: "9f86d0"},
+						AllowedExitCodes: []int{2},
+						RequireApproval:  true,
+						ApprovalTimeout:  30 * time.Minute,
+						OutputFilter:     "grep -v DEBUG",
+					},
+				}},
+				Apply: valid.Stage{Steps: []valid.Step{
+					{StepName: "run", RunCommand: "./notify.sh", Output: valid.PostProcessRunOutputShow},
+					{StepName: "apply"},
+				}},
+			},
+			RepoCfgKeys: []string{valid.ApplyRequirementsKey, valid.WorkflowKey},
+		},
+	}, nil)
+
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.ShowConfig, ProjectName: "app"}, "")
+
+	expComment := "Effective config of 1 project(s). Server-side settings are set by the server-side repo config unless the repo config overrode them. Secret values are masked.\n" +
+		"\n#### project: `app` dir: `app` workspace: `default`\n" +
+		"Repo config: `atlantis.yaml`\n" +
+		"\n| Server-side setting | Value | Set by |\n" +
+		"| --- | --- | --- |\n" +
+		"| workflow | `custom` | **repo config** |\n" +
+		"| plan_requirements | none | server-side repo config |\n" +
+		"| apply_requirements | `approved, mergeable` | **repo config** |\n" +
+		"| import_requirements | none | server-side repo config |\n" +
+		"| delete_source_branch_on_merge | `false` | server-side repo config |\n" +
+		"| repo_locks | `on_plan` | server-side repo config |\n" +
+		"| custom_policy_check | `false` | server-side repo config |\n" +
+		"| plan_ttl | `1h0m0s` | server-side repo config |\n" +
+		"| plugin_cache_dir | none | server-side repo config |\n" +
+		"| provider_mirror | none | server-side repo config |\n" +
+		"| run_command_policy | none | server-side repo config |\n" +
+		"\n```yaml\n" +
+		"autoplan: true\n" +
+		"tf_vars:\n" +
+		"    db_password: '***'\n" +
+		"workflow:\n" +
+		"    name: custom\n" +
+		"    plan:\n" +
+		"        - env:\n" +
+		"            name: TOKEN\n" +
+		"            value: '***'\n" +
+		"        - env:\n" +
+		"            mask_in:\n" +
+		"                - log\n" +
+		"            name: REGION\n" +
+		"            value: '***'\n" +
+		"        - init\n" +
+		"        - plan:\n" +
+		"            extra_args:\n" +
+		"                - '***'\n" +
+		"            parallelism: 20\n" +
+		"        - run:\n" +
+		"            allowed_exit_codes:\n" +
+		"                - 2\n" +
+		"            always: rm -rf tmp\n" +
+		"            approval_timeout: 30m0s\n" +
+		"            cache:\n" +
+		"                key: deps\n" +
+		"                paths:\n" +
+		"                    - .deps\n" +
+		"            command: ./check.sh\n" +
+		"            if: workspace == 'prod'\n" +
+		"            input: '***'\n" +
+		"            memory_limit: 536870912\n" +
+		"            output_filter: grep -v DEBUG\n" +
+		"            rate_limit: cloud-api:5/s\n" +
+		"            require_approval: true\n" +
+		"            require_tool:\n" +
+		"                - jq>=1.6\n" +
+		"            stream: true\n" +
+		"            verify:\n" +
+		"                file: tool\n" +
+		"                sha256: 9f86d0\n" +
+		"    apply:\n" +
+		"        - run: ./notify.sh\n" +
+		"        - apply\n" +
+		"```\n"
+	_, _, _, comment, _ := vcsClient.VerifyWasCalledOnce().CreateComment(
+		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num), Any[string](), Eq("show-config")).GetCapturedArguments()
+	Equals(t, expComment, comment)
+	Assert(t, !strings.Contains(comment, "hunter2") && !strings.Contains(comment, "s3cr3t") && !strings.Contains(comment, "t0k3n"), "exp secrets to be masked, got %q", comment)
+	_, cmd := projectCommandBuilder.VerifyWasCalledOnce().BuildPlanCommands(Any[*command.Context](), Any[*events.CommentCommand]()).GetCapturedArguments()
+	Equals(t, command.Plan, cmd.Name)
+	Equals(t, "app", cmd.ProjectName)
+}
+
+func TestRunComparePlanCommand_VCSComment(t *testing.T) {
+	// writePlan writes the plan JSON of the project in dir with changes, a
+	// list of addresses, actions and values after the change.
+	writePlan := func(t *testing.T, dir string, showFile string, changes ...[3]string) {
+		var resourceChanges []string
+		for _, c := range changes {
+			resourceChanges = append(resourceChanges, fmt.Sprintf(`{"address": %q, "change": {"actions": [%s], "after": %s}}`, c[0], c[1], c[2]))
+		}
+		Ok(t, os.MkdirAll(dir, 0700))
+		Ok(t, os.WriteFile(filepath.Join(dir, showFile), []byte(fmt.Sprintf(`{"resource_changes": [%s]}`, strings.Join(resourceChanges, ","))), 0600))
+	}
+	otherPull := models.PullRequest{Num: 7, BaseRepo: testdata.GithubRepo}
+
+	cases := []struct {
+		name     string
+		cmd      *events.CommentCommand
+		ours     []events.PendingPlan
+		theirs   []events.PendingPlan
+		noTheirs bool
+		// lockTheirs is the workspace and dir a command of #7 is running in.
+		lockTheirs []string
+		expComment string
+	}{
+		{
+			name: "overlapping projects",
+			cmd:  &events.CommentCommand{Name: command.ComparePlan, AgainstPull: 7},
+			ours: []events.PendingPlan{
+				{RepoRelDir: ".", Workspace: "default"},
+				{RepoRelDir: "network", Workspace: "default", ProjectName: "network"},
+			},
+			theirs: []events.PendingPlan{
+				{RepoRelDir: ".", Workspace: "default"},
+				{RepoRelDir: "network", Workspace: "default", ProjectName: "network"},
+				{RepoRelDir: "staging", Workspace: "default"},
+			},
+			expComment: "Compared the plans of this pull request with #7:\n" +
+				"\n#### dir: `.` workspace: `default`\n" +
+				"| Resource | This pull request | #7 | Same change |\n| --- | --- | --- | --- |\n" +
+				"| `aws_instance.web` | update | update | no, different values |\n" +
+				"| `aws_s3_bucket.logs` | create | create | yes |\n" +
+				"| `aws_security_group.web` | update | replace | no, different actions |\n" +
+				"\n1 other resource(s) only changed by this pull request, 2 only by #7.\n" +
+				"\n#### project: `network` dir: `network` workspace: `default`\n" +
+				"Failed to compare plans: reading plan of #7: plan JSON \"THEIRS/network/network-default.json\" doesn't exist\n" +
+				"\nNot compared, only planned in #7:\n- dir: `staging` workspace: `default`",
+		},
+		{
+			name: "other pull request busy",
+			cmd:  &events.CommentCommand{Name: command.ComparePlan, AgainstPull: 7},
+			ours: []events.PendingPlan{
+				{RepoRelDir: ".", Workspace: "default"},
+			},
+			theirs: []events.PendingPlan{
+				{RepoRelDir: ".", Workspace: "default"},
+			},
+			lockTheirs: []string{"default", "."},
+			expComment: "Compared the plans of this pull request with #7:\n" +
+				"\n#### dir: `.` workspace: `default`\n" +
+				"Failed to compare plans: reading plan of #7: the default workspace at path . is currently locked by another command that is running for this pull request.\n" +
+				"Wait until the previous command is complete and try again",
+		},
+		{
+			name: "dir",
+			cmd:  &events.CommentCommand{Name: command.ComparePlan, AgainstPull: 7, RepoRelDir: "staging"},
+			ours: []events.PendingPlan{
+				{RepoRelDir: ".", Workspace: "default"},
+			},
+			expComment: "No matching plans in this pull request, run `atlantis plan` first.",
+		},
+		{
+			name: "no overlapping projects",
+			cmd:  &events.CommentCommand{Name: command.ComparePlan, AgainstPull: 7},
+			ours: []events.PendingPlan{
+				{RepoRelDir: "network", Workspace: "default", ProjectName: "network"},
+			},
+			theirs: []events.PendingPlan{
+				{RepoRelDir: "staging", Workspace: "default"},
+			},
+			expComment: "None of the projects planned in this pull request are planned in #7, so there's nothing to compare.\n\n" +
+				"Planned in this pull request:\n- project: `network` dir: `network` workspace: `default`\n\n" +
+				"Planned in #7:\n- dir: `staging` workspace: `default`",
+		},
+		{
+			name: "other pull request never planned",
+			cmd:  &events.CommentCommand{Name: command.ComparePlan, AgainstPull: 7},
+			ours: []events.PendingPlan{
+				{RepoRelDir: ".", Workspace: "default"},
+			},
+			noTheirs:   true,
+			expComment: "#7 has no plans to compare with. Plans are deleted once they're applied or their pull request is closed, run `atlantis plan` on it first.",
+		},
+		{
+			name:       "same pull request",
+			cmd:        &events.CommentCommand{Name: command.ComparePlan, AgainstPull: testdata.Pull.Num},
+			expComment: "Can't compare the plans of a pull request with themselves, use `--against` with the number of another pull request.",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			vcsClient := setup(t)
+			pull := &github.PullRequest{
+				State: github.String("open"),
+			}
+			modelPull := models.PullRequest{BaseRepo: testdata.GithubRepo, State: models.OpenPullState, Num: testdata.Pull.Num}
+			When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo),
+				Eq(testdata.Pull.Num))).ThenReturn(pull, nil)
+			When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo,
+				testdata.GithubRepo, nil)
+
+			oursDir, theirsDir := t.TempDir(), t.TempDir()
+			writePlan(t, oursDir, "default.json",
+				[3]string{"aws_instance.web", `"update"`, `{"ami": "ami-1"}`},
+				[3]string{"aws_s3_bucket.logs", `"create"`, `{"bucket": "logs"}`},
+				[3]string{"aws_security_group.web", `"update"`, `{}`},
+				[3]string{"null_resource.ours", `"create"`, `{}`},
+				[3]string{"data.aws_ami.latest", `"read"`, `{}`},
+			)
+			writePlan(t, filepath.Join(oursDir, "network"), "network-default.json")
+			writePlan(t, theirsDir, "default.json",
+				[3]string{"aws_instance.web", `"update"`, `{"ami":"ami-2"}`},
+				[3]string{"aws_s3_bucket.logs", `"create"`, `{"bucket":"logs"}`},
+				[3]string{"aws_security_group.web", `"delete", "create"`, `{}`},
+				[3]string{"null_resource.theirs", `"delete"`, `null`},
+				[3]string{"null_resource.unchanged", `"no-op"`, `{}`},
+				[3]string{"null_resource.gone", `"delete"`, `null`},
+			)
+			for i := range c.ours {
+				c.ours[i].RepoDir = oursDir
+			}
+			for i := range c.theirs {
+				c.theirs[i].RepoDir = theirsDir
+			}
+			When(workingDir.GetPullDir(Any[models.Repo](), Eq(modelPull))).ThenReturn(oursDir, nil)
+			When(pendingPlanFinder.Find(oursDir)).ThenReturn(c.ours, nil)
+			if c.noTheirs {
+				When(workingDir.GetPullDir(Any[models.Repo](), Eq(otherPull))).ThenReturn("", os.ErrNotExist)
+			} else {
+				When(workingDir.GetPullDir(Any[models.Repo](), Eq(otherPull))).ThenReturn(theirsDir, nil)
+				When(pendingPlanFinder.Find(theirsDir)).ThenReturn(c.theirs, nil)
+			}
+			if c.lockTheirs != nil {
+				unlockFn, err := workingDirLocker.TryLock(otherPull.BaseRepo.FullName, otherPull.Num, c.lockTheirs[0], c.lockTheirs[1])
+				Ok(t, err)
+				defer unlockFn()
+			}
+
+			ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, c.cmd, "")
+
+			vcsClient.VerifyWasCalledOnce().CreateComment(
+				Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num), Eq(strings.ReplaceAll(c.expComment, "THEIRS", theirsDir)), Eq("compare-plan"))
+		})
+	}
+}
+
 func TestRunUnlockCommandFail_DisableUnlockLabel(t *testing.T) {
 	t.Log("if PR has label equal to disable-unlock-label unlock should fail")
 
@@ -721,12 +1679,12 @@ func TestRunUnlockCommandFail_DisableUnlockLabel(t *testing.T) {
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo,
 		testdata.GithubRepo, nil)
 	When(deleteLockCommand.DeleteLocksByPull(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo.FullName),
-		Eq(testdata.Pull.Num))).ThenReturn(0, errors.New("err"))
+		Eq(testdata.Pull.Num))).ThenReturn(nil, errors.New("err"))
 	When(ch.VCSClient.GetPullLabels(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo),
 		Eq(modelPull))).ThenReturn([]string{doNotUnlock, "need-help"}, nil)
 
 	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num,
-		&events.CommentCommand{Name: command.Unlock})
+		&events.CommentCommand{Name: command.Unlock}, "")
 
 	vcsClient.VerifyWasCalledOnce().CreateComment(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo),
 		Eq(testdata.Pull.Num), Eq("Not allowed to unlock PR with "+doNotUnlock+" label"), Eq("unlock"))
@@ -745,12 +1703,12 @@ func TestRunUnlockCommandFail_GetLabelsFail(t *testing.T) {
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo,
 		testdata.GithubRepo, nil)
 	When(deleteLockCommand.DeleteLocksByPull(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo.FullName),
-		Eq(testdata.Pull.Num))).ThenReturn(0, errors.New("err"))
+		Eq(testdata.Pull.Num))).ThenReturn(nil, errors.New("err"))
 	When(ch.VCSClient.GetPullLabels(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo),
 		Eq(modelPull))).ThenReturn(nil, errors.New("err"))
 
 	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num,
-		&events.CommentCommand{Name: command.Unlock})
+		&events.CommentCommand{Name: command.Unlock}, "")
 
 	vcsClient.VerifyWasCalledOnce().CreateComment(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num),
 		Eq("Failed to retrieve PR labels... Not unlocking"), Eq("unlock"))
@@ -771,13 +1729,13 @@ func TestRunUnlockCommandDoesntRetrieveLabelsIfDisableUnlockLabelNotSet(t *testi
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo,
 		testdata.GithubRepo, nil)
 	When(deleteLockCommand.DeleteLocksByPull(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo.FullName),
-		Eq(testdata.Pull.Num))).ThenReturn(0, errors.New("err"))
+		Eq(testdata.Pull.Num))).ThenReturn(nil, errors.New("err"))
 	When(ch.VCSClient.GetPullLabels(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo),
 		Eq(modelPull))).ThenReturn([]string{doNotUnlock, "need-help"}, nil)
 	unlockCommandRunner.DisableUnlockLabel = ""
 
 	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num,
-		&events.CommentCommand{Name: command.Unlock})
+		&events.CommentCommand{Name: command.Unlock}, "")
 
 	vcsClient.VerifyWasCalled(Never()).GetPullLabels(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull))
 }
@@ -804,11 +1762,112 @@ func TestRunAutoplanCommand_DeletePlans(t *testing.T) {
 	When(projectCommandRunner.Plan(Any[command.ProjectContext]())).ThenReturn(command.ProjectResult{PlanSuccess: &models.PlanSuccess{}})
 	When(workingDir.GetPullDir(Any[models.Repo](), Any[models.PullRequest]())).ThenReturn(tmp, nil)
 	testdata.Pull.BaseRepo = testdata.GithubRepo
-	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, testdata.Pull, testdata.User)
+	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, testdata.Pull, testdata.User, "")
 	pendingPlanFinder.VerifyWasCalledOnce().DeletePlans(tmp)
 	lockingLocker.VerifyWasCalledOnce().UnlockByPull(testdata.Pull.BaseRepo.FullName, testdata.Pull.Num)
 }
 
+// Test that autoplan doesn't plan projects with pinned plans again.
+func TestRunAutoplanCommand_SkipsPinnedPlans(t *testing.T) {
+	vcsClient := setup(t)
+	tmp := t.TempDir()
+	boltDB, err := db.New(tmp)
+	Ok(t, err)
+	dbUpdater.Backend = boltDB
+	applyCommandRunner.Backend = boltDB
+
+	repoDir := t.TempDir()
+	Ok(t, os.MkdirAll(filepath.Join(repoDir, "staging"), 0700))
+	Ok(t, os.Mkdir(filepath.Join(repoDir, ".git"), 0700))
+	pinnedPlan := filepath.Join(repoDir, "staging", "default.tfplan")
+	Ok(t, os.WriteFile(pinnedPlan, nil, 0600))
+	Ok(t, runtime.PinPlan(pinnedPlan, "lkysow"))
+	testdata.Pull.BaseRepo = testdata.GithubRepo
+	When(projectCommandBuilder.BuildAutoplanCommands(Any[*command.Context]())).
+		ThenReturn([]command.ProjectContext{
+			{CommandName: command.Plan, Pull: testdata.Pull, RepoRelDir: ".", Workspace: "default"},
+			{CommandName: command.Plan, Pull: testdata.Pull, RepoRelDir: "staging", Workspace: "default"},
+		}, nil)
+	When(projectCommandRunner.Plan(Any[command.ProjectContext]())).ThenReturn(command.ProjectResult{PlanSuccess: &models.PlanSuccess{}})
+	When(workingDir.GetWorkingDir(Any[models.Repo](), Any[models.PullRequest](), Any[string]())).ThenReturn(repoDir, nil)
+	When(workingDir.GetPullDir(Any[models.Repo](), Any[models.PullRequest]())).ThenReturn(tmp, nil)
+
+	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, testdata.Pull, testdata.User, "")
+
+	projectCommandRunner.VerifyWasCalledOnce().Plan(Any[command.ProjectContext]())
+	vcsClient.VerifyWasCalledOnce().CreateComment(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num),
+		Eq("📌 Kept 1 pinned plan(s) instead of planning them again:\n\n- dir: `staging` workspace: `default` pinned by @lkysow\n\nRun `atlantis unpin-plan` so they're planned again, or `atlantis plan` to replace them now."),
+		Eq("plan"))
+}
+
+// Test that a push while an autoplan runs stops it from planning further
+// projects or posting its results, and the latest commit is planned instead.
+func TestRunAutoplanCommand_Debounced(t *testing.T) {
+	vcsClient := setup(t)
+	tmp := t.TempDir()
+	boltDB, err := db.New(tmp)
+	Ok(t, err)
+	dbUpdater.Backend = boltDB
+	applyCommandRunner.Backend = boltDB
+	ch.AutoplanDebouncer = events.NewAutoplanDebouncer(10 * time.Millisecond)
+
+	firstPull := testdata.Pull
+	firstPull.BaseRepo = testdata.GithubRepo
+	firstPull.HeadCommit = "first"
+	secondPull := firstPull
+	secondPull.HeadCommit = "second"
+
+	When(projectCommandBuilder.BuildAutoplanCommands(Any[*command.Context]())).
+		ThenReturn([]command.ProjectContext{
+			{
+				CommandName: command.Plan,
+			},
+			{
+				CommandName: command.Plan,
+			},
+		}, nil)
+	var plans int32
+	When(projectCommandRunner.Plan(Any[command.ProjectContext]())).Then(func(_ []Param) ReturnValues {
+		// Push a new commit while the first project of the first autoplan is
+		// planning.
+		if atomic.AddInt32(&plans, 1) == 1 {
+			ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, secondPull, testdata.User, "")
+		}
+		return ReturnValues{command.ProjectResult{PlanSuccess: &models.PlanSuccess{}}}
+	})
+	When(workingDir.GetPullDir(Any[models.Repo](), Any[models.PullRequest]())).ThenReturn(tmp, nil)
+
+	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, firstPull, testdata.User, "")
+
+	vcsClient.VerifyWasCalledEventually(Once(), 5*time.Second).CreateComment(
+		Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]())
+	// The first autoplan skips its second project and the second autoplan
+	// plans both.
+	Equals(t, int32(3), atomic.LoadInt32(&plans))
+	ctxs := projectCommandBuilder.VerifyWasCalled(Times(2)).BuildAutoplanCommands(Any[*command.Context]()).GetAllCapturedArguments()
+	Equals(t, "first", ctxs[0].Pull.HeadCommit)
+	Equals(t, "second", ctxs[1].Pull.HeadCommit)
+}
+
+func TestRunAutoplanCommand_FetchesPullStatusForPlanRequirements(t *testing.T) {
+	setup(t)
+	tmp := t.TempDir()
+	boltDB, err := db.New(tmp)
+	Ok(t, err)
+	dbUpdater.Backend = boltDB
+	applyCommandRunner.Backend = boltDB
+
+	pullStatus := models.PullReqStatus{Mergeable: true}
+	When(pullReqStatusFetcher.FetchPullStatus(Any[logging.SimpleLogging](), Any[models.PullRequest]())).ThenReturn(pullStatus, nil)
+	When(projectCommandBuilder.BuildAutoplanCommands(Any[*command.Context]())).ThenReturn([]command.ProjectContext{}, nil)
+	When(workingDir.GetPullDir(Any[models.Repo](), Any[models.PullRequest]())).ThenReturn(tmp, nil)
+	testdata.Pull.BaseRepo = testdata.GithubRepo
+	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, testdata.Pull, testdata.User, "")
+
+	ctx := projectCommandBuilder.VerifyWasCalledOnce().BuildAutoplanCommands(Any[*command.Context]()).GetCapturedArguments()
+	Equals(t, pullStatus, ctx.PullRequestStatus)
+}
+
 func TestRunAutoplanCommand_FailedPreWorkflowHook_FailOnPreWorkflowHookError_False(t *testing.T) {
 	setup(t)
 	tmp := t.TempDir()
@@ -828,7 +1887,7 @@ func TestRunAutoplanCommand_FailedPreWorkflowHook_FailOnPreWorkflowHookError_Fal
 	When(preWorkflowHooksCommandRunner.RunPreHooks(Any[*command.Context](), Any[*events.CommentCommand]())).ThenReturn(errors.New("err"))
 	testdata.Pull.BaseRepo = testdata.GithubRepo
 	ch.FailOnPreWorkflowHookError = false
-	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, testdata.Pull, testdata.User)
+	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, testdata.Pull, testdata.User, "")
 	pendingPlanFinder.VerifyWasCalledOnce().DeletePlans(tmp)
 	lockingLocker.VerifyWasCalledOnce().UnlockByPull(testdata.Pull.BaseRepo.FullName, testdata.Pull.Num)
 }
@@ -850,7 +1909,7 @@ func TestRunAutoplanCommand_FailedPreWorkflowHook_FailOnPreWorkflowHookError_Tru
 	When(preWorkflowHooksCommandRunner.RunPreHooks(Any[*command.Context](), Any[*events.CommentCommand]())).ThenReturn(errors.New("err"))
 	testdata.Pull.BaseRepo = testdata.GithubRepo
 	ch.FailOnPreWorkflowHookError = true
-	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, testdata.Pull, testdata.User)
+	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, testdata.Pull, testdata.User, "")
 	pendingPlanFinder.VerifyWasCalled(Never()).DeletePlans(Any[string]())
 	lockingLocker.VerifyWasCalled(Never()).UnlockByPull(Any[string](), Any[int]())
 }
@@ -872,7 +1931,7 @@ func TestRunCommentCommand_FailedPreWorkflowHook_FailOnPreWorkflowHookError_Fals
 	When(preWorkflowHooksCommandRunner.RunPreHooks(Any[*command.Context](), Any[*events.CommentCommand]())).ThenReturn(errors.New("err"))
 	testdata.Pull.BaseRepo = testdata.GithubRepo
 	ch.FailOnPreWorkflowHookError = false
-	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan})
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan}, "")
 	pendingPlanFinder.VerifyWasCalledOnce().DeletePlans(tmp)
 	lockingLocker.VerifyWasCalledOnce().UnlockByPull(testdata.Pull.BaseRepo.FullName, testdata.Pull.Num)
 }
@@ -890,7 +1949,7 @@ func TestRunCommentCommand_FailedPreWorkflowHook_FailOnPreWorkflowHookError_True
 	When(preWorkflowHooksCommandRunner.RunPreHooks(Any[*command.Context](), Any[*events.CommentCommand]())).ThenReturn(errors.New("err"))
 	testdata.Pull.BaseRepo = testdata.GithubRepo
 	ch.FailOnPreWorkflowHookError = true
-	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan})
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan}, "")
 	pendingPlanFinder.VerifyWasCalled(Never()).DeletePlans(Any[string]())
 	lockingLocker.VerifyWasCalled(Never()).UnlockByPull(Any[string](), Any[int]())
 }
@@ -912,7 +1971,7 @@ func TestRunGenericPlanCommand_DeletePlans(t *testing.T) {
 	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(pull, nil)
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo, testdata.GithubRepo, nil)
 	testdata.Pull.BaseRepo = testdata.GithubRepo
-	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan})
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan}, "")
 	pendingPlanFinder.VerifyWasCalledOnce().DeletePlans(tmp)
 	lockingLocker.VerifyWasCalledOnce().UnlockByPull(testdata.Pull.BaseRepo.FullName, testdata.Pull.Num)
 }
@@ -930,7 +1989,7 @@ func TestRunSpecificPlanCommandDoesnt_DeletePlans(t *testing.T) {
 	When(projectCommandRunner.Plan(Any[command.ProjectContext]())).ThenReturn(command.ProjectResult{PlanSuccess: &models.PlanSuccess{}})
 	When(workingDir.GetPullDir(Any[models.Repo](), Any[models.PullRequest]())).ThenReturn(tmp, nil)
 	testdata.Pull.BaseRepo = testdata.GithubRepo
-	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan, ProjectName: "default"})
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan, ProjectName: "default"}, "")
 	pendingPlanFinder.VerifyWasCalled(Never()).DeletePlans(tmp)
 }
 
@@ -979,13 +2038,64 @@ func TestRunAutoplanCommandWithError_DeletePlans(t *testing.T) {
 	When(workingDir.GetPullDir(Any[models.Repo](), Any[models.PullRequest]())).
 		ThenReturn(tmp, nil)
 	testdata.Pull.BaseRepo = testdata.GithubRepo
-	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, testdata.Pull, testdata.User)
+	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, testdata.Pull, testdata.User, "")
 	// gets called twice: the first time before the plan starts, the second time after the plan errors
 	pendingPlanFinder.VerifyWasCalled(Times(2)).DeletePlans(tmp)
 
 	vcsClient.VerifyWasCalled(Times(0)).DiscardReviews(Any[models.Repo](), Any[models.PullRequest]())
 }
 
+// Test that quiet repos only get a comment if a plan fails.
+func TestRunAutoplanCommand_Quiet(t *testing.T) {
+	cases := []struct {
+		description string
+		result      command.ProjectResult
+		expComment  bool
+	}{
+		{
+			description: "successful plan isn't commented on",
+			result:      command.ProjectResult{PlanSuccess: &models.PlanSuccess{}},
+			expComment:  false,
+		},
+		{
+			description: "failed plan is commented on",
+			result:      command.ProjectResult{Error: errors.New("err")},
+			expComment:  true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			vcsClient := setup(t)
+			tmp := t.TempDir()
+			boltDB, err := db.New(tmp)
+			Ok(t, err)
+			dbUpdater.Backend = boltDB
+			applyCommandRunner.Backend = boltDB
+			quiet := true
+			pullUpdater.GlobalCfg = valid.GlobalCfg{
+				Repos: []valid.Repo{{IDRegex: regexp.MustCompile(".*"), Quiet: &quiet}},
+			}
+
+			When(projectCommandBuilder.BuildAutoplanCommands(Any[*command.Context]())).
+				ThenReturn([]command.ProjectContext{{CommandName: command.Plan}}, nil)
+			When(projectCommandRunner.Plan(Any[command.ProjectContext]())).ThenReturn(c.result)
+			When(workingDir.GetPullDir(Any[models.Repo](), Any[models.PullRequest]())).ThenReturn(tmp, nil)
+			testdata.Pull.BaseRepo = testdata.GithubRepo
+			ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, testdata.Pull, testdata.User, "")
+
+			times := 0
+			if c.expComment {
+				times = 1
+			}
+			vcsClient.VerifyWasCalled(Times(times)).CreateComment(
+				Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]())
+			// The commit status is updated either way.
+			commitUpdater.VerifyWasCalled(AtLeast(1)).UpdateCombinedCount(
+				Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](), Any[models.CommitStatus](), Eq(command.Plan), Any[int](), Any[int]())
+		})
+	}
+}
+
 func TestRunGenericPlanCommand_DiscardApprovals(t *testing.T) {
 	vcsClient := setup(t, func(testConfig *TestConfig) {
 		testConfig.discardApprovalOnPlan = true
@@ -1006,7 +2116,7 @@ func TestRunGenericPlanCommand_DiscardApprovals(t *testing.T) {
 	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(pull, nil)
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo, testdata.GithubRepo, nil)
 	testdata.Pull.BaseRepo = testdata.GithubRepo
-	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan})
+	ch.RunCommentCommand(testdata.GithubRepo, nil, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan}, "")
 	pendingPlanFinder.VerifyWasCalledOnce().DeletePlans(tmp)
 	lockingLocker.VerifyWasCalledOnce().UnlockByPull(testdata.Pull.BaseRepo.FullName, testdata.Pull.Num)
 
@@ -1047,7 +2157,7 @@ func TestFailedApprovalCreatesFailedStatusUpdate(t *testing.T) {
 
 	When(workingDir.GetPullDir(testdata.GithubRepo, testdata.Pull)).ThenReturn(tmp, nil)
 
-	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, &testdata.Pull, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.ApprovePolicies})
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, &testdata.Pull, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.ApprovePolicies}, "")
 	commitUpdater.VerifyWasCalledOnce().UpdateCombinedCount(
 		Any[logging.SimpleLogging](),
 		Any[models.Repo](),
@@ -1103,7 +2213,56 @@ func TestApprovedPoliciesUpdateFailedPolicyStatus(t *testing.T) {
 		}
 	})
 
-	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, &testdata.Pull, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.ApprovePolicies})
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, &testdata.Pull, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.ApprovePolicies}, "")
+	commitUpdater.VerifyWasCalledOnce().UpdateCombinedCount(
+		Any[logging.SimpleLogging](),
+		Any[models.Repo](),
+		Any[models.PullRequest](),
+		Eq[models.CommitStatus](models.SuccessCommitStatus),
+		Eq[command.Name](command.PolicyCheck),
+		Eq(1),
+		Eq(1),
+	)
+}
+
+func TestRunCommentCommandPolicyCheck(t *testing.T) {
+	t.Log("if \"atlantis policy_check\" is run policies are checked against the existing plans")
+	vcsClient := setup(t)
+	tmp := t.TempDir()
+	boltDB, err := db.New(tmp)
+	Ok(t, err)
+	dbUpdater.Backend = boltDB
+
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{
+		BaseRepo: testdata.GithubRepo,
+		State:    models.OpenPullState,
+		Num:      testdata.Pull.Num,
+	}
+	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo, testdata.GithubRepo, nil)
+
+	When(projectCommandBuilder.BuildPolicyCheckCommands(Any[*command.Context](), Any[*events.CommentCommand]())).ThenReturn([]command.ProjectContext{
+		{
+			CommandName: command.PolicyCheck,
+			RepoRelDir:  ".",
+			Workspace:   "default",
+		},
+	}, nil)
+	When(projectCommandRunner.PolicyCheck(Any[command.ProjectContext]())).ThenReturn(command.ProjectResult{
+		Command:            command.PolicyCheck,
+		RepoRelDir:         ".",
+		Workspace:          "default",
+		PolicyCheckResults: &models.PolicyCheckResults{},
+	})
+
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, &testdata.Pull, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.PolicyCheck}, "")
+	projectCommandBuilder.VerifyWasCalled(Never()).BuildPlanCommands(Any[*command.Context](), Any[*events.CommentCommand]())
+	projectCommandRunner.VerifyWasCalledOnce().PolicyCheck(Any[command.ProjectContext]())
+	vcsClient.VerifyWasCalledOnce().CreateComment(
+		Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]())
 	commitUpdater.VerifyWasCalledOnce().UpdateCombinedCount(
 		Any[logging.SimpleLogging](),
 		Any[models.Repo](),
@@ -1115,6 +2274,35 @@ func TestApprovedPoliciesUpdateFailedPolicyStatus(t *testing.T) {
 	)
 }
 
+func TestRunCommentCommandPolicyCheck_NoPlan(t *testing.T) {
+	t.Log("if \"atlantis policy_check\" is run without a plan it comments that plan must run first")
+	vcsClient := setup(t)
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{
+		BaseRepo: testdata.GithubRepo,
+		State:    models.OpenPullState,
+		Num:      testdata.Pull.Num,
+	}
+	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(pull))).ThenReturn(modelPull, modelPull.BaseRepo, testdata.GithubRepo, nil)
+	When(projectCommandBuilder.BuildPolicyCheckCommands(Any[*command.Context](), Any[*events.CommentCommand]())).ThenReturn(nil, errors.New("no plan found for dir \".\" workspace \"default\", run plan first"))
+
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, &testdata.Pull, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.PolicyCheck}, "")
+	projectCommandRunner.VerifyWasCalled(Never()).PolicyCheck(Any[command.ProjectContext]())
+	_, _, _, comment, _ := vcsClient.VerifyWasCalledOnce().CreateComment(
+		Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]()).GetCapturedArguments()
+	Assert(t, strings.Contains(comment, "run plan first"), "exp comment to say to run plan first but was %q", comment)
+	commitUpdater.VerifyWasCalledOnce().UpdateCombined(
+		Any[logging.SimpleLogging](),
+		Any[models.Repo](),
+		Any[models.PullRequest](),
+		Eq[models.CommitStatus](models.FailedCommitStatus),
+		Eq[command.Name](command.PolicyCheck),
+	)
+}
+
 func TestApplyMergeablityWhenPolicyCheckFails(t *testing.T) {
 	t.Log("if \"atlantis apply\" is run with failing policy check then apply is not performed")
 	setup(t)
@@ -1148,7 +2336,7 @@ func TestApplyMergeablityWhenPolicyCheckFails(t *testing.T) {
 		},
 	})
 
-	When(ch.VCSClient.PullIsMergeable(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull), Eq("atlantis-test"))).ThenReturn(true, nil)
+	When(ch.VCSClient.PullIsMergeable(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(modelPull), Eq("atlantis-test/apply"))).ThenReturn(true, nil)
 
 	When(projectCommandBuilder.BuildApplyCommands(Any[*command.Context](), Any[*events.CommentCommand]())).Then(func(args []Param) ReturnValues {
 		return ReturnValues{
@@ -1166,7 +2354,7 @@ func TestApplyMergeablityWhenPolicyCheckFails(t *testing.T) {
 	})
 
 	When(workingDir.GetPullDir(testdata.GithubRepo, modelPull)).ThenReturn(tmp, nil)
-	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, &modelPull, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Apply})
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, &modelPull, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Apply}, "")
 }
 
 func TestApplyWithAutoMerge_VSCMerge(t *testing.T) {
@@ -1186,7 +2374,7 @@ func TestApplyWithAutoMerge_VSCMerge(t *testing.T) {
 		DeleteSourceBranchOnMerge: false,
 	}
 
-	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Apply})
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Apply}, "")
 	vcsClient.VerifyWasCalledOnce().MergePull(Any[logging.SimpleLogging](), Eq(modelPull), Eq(pullOptions))
 }
 
@@ -1223,7 +2411,7 @@ func TestRunApply_DiscardedProjects(t *testing.T) {
 	When(eventParsing.ParseGithubPull(Any[logging.SimpleLogging](), Eq(ghPull))).ThenReturn(pull, pull.BaseRepo, testdata.GithubRepo, nil)
 	When(workingDir.GetPullDir(Any[models.Repo](), Any[models.PullRequest]())).
 		ThenReturn(tmp, nil)
-	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, &pull, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Apply})
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, &pull, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Apply}, "")
 
 	vcsClient.VerifyWasCalled(Never()).MergePull(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.PullRequestOptions]())
 }
@@ -1232,7 +2420,7 @@ func TestRunCommentCommand_DrainOngoing(t *testing.T) {
 	t.Log("if drain is ongoing then a message should be displayed")
 	vcsClient := setup(t)
 	drainer.ShutdownBlocking()
-	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, nil)
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, nil, "")
 	vcsClient.VerifyWasCalledOnce().CreateComment(
 		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num), Eq("Atlantis server is shutting down, please try again later."), Eq(""))
 }
@@ -1242,7 +2430,7 @@ func TestRunCommentCommand_DrainNotOngoing(t *testing.T) {
 	setup(t)
 	When(githubGetter.GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))).ThenPanic(
 		"panic test - if you're seeing this in a test failure this isn't the failing test")
-	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan})
+	ch.RunCommentCommand(testdata.GithubRepo, &testdata.GithubRepo, nil, testdata.User, testdata.Pull.Num, &events.CommentCommand{Name: command.Plan}, "")
 	githubGetter.VerifyWasCalledOnce().GetPullRequest(Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num))
 	Equals(t, 0, drainer.GetStatus().InProgressOps)
 }
@@ -1251,7 +2439,7 @@ func TestRunAutoplanCommand_DrainOngoing(t *testing.T) {
 	t.Log("if drain is ongoing then a message should be displayed")
 	vcsClient := setup(t)
 	drainer.ShutdownBlocking()
-	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, testdata.Pull, testdata.User)
+	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, testdata.Pull, testdata.User, "")
 	vcsClient.VerifyWasCalledOnce().CreateComment(
 		Any[logging.SimpleLogging](), Eq(testdata.GithubRepo), Eq(testdata.Pull.Num), Eq("Atlantis server is shutting down, please try again later."), Eq("plan"))
 }
@@ -1261,7 +2449,7 @@ func TestRunAutoplanCommand_DrainNotOngoing(t *testing.T) {
 	setup(t)
 	testdata.Pull.BaseRepo = testdata.GithubRepo
 	When(projectCommandBuilder.BuildAutoplanCommands(Any[*command.Context]())).ThenPanic("panic test - if you're seeing this in a test failure this isn't the failing test")
-	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, testdata.Pull, testdata.User)
+	ch.RunAutoplanCommand(testdata.GithubRepo, testdata.GithubRepo, testdata.Pull, testdata.User, "")
 	projectCommandBuilder.VerifyWasCalledOnce().BuildAutoplanCommands(Any[*command.Context]())
 	Equals(t, 0, drainer.GetStatus().InProgressOps)
 }
@@ -45,6 +45,18 @@ const (
 	verboseFlagShort             = ""
 	clearPolicyApprovalFlagLong  = "clear-policy-approval"
 	clearPolicyApprovalFlagShort = ""
+	refFlagLong                  = "ref"
+	refFlagShort                 = ""
+	indexFlagLong                = "index"
+	indexFlagShort               = "i"
+	reasonFlagLong               = "reason"
+	reasonFlagShort              = ""
+	againstFlagLong              = "against"
+	againstFlagShort             = ""
+	allFlagLong                  = "all"
+	queueFlagLong                = "queue"
+	targetFlagLong               = "target"
+	summaryFlagLong              = "summary"
 )
 
 // multiLineRegex is used to ignore multi-line comments since those aren't valid
@@ -54,6 +66,18 @@ const (
 // and pasting GitHub comments.
 var multiLineRegex = regexp.MustCompile(`.*\r?\n[^\r\n]+`)
 
+// refRegex matches the git refs that can be planned with --ref, ex.
+// origin/hotfix, v1.2.0 or a commit SHA.
+var refRegex = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._/-]*$`)
+
+// targetRegex matches the resource and module addresses that can be applied
+// with --target, ex. module.db or aws_instance.web["a"].
+var targetRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\[\]"-]*$`)
+
+// maxApplyReasonLength is the longest reason an apply can be given with
+// --reason.
+const maxApplyReasonLength = 200
+
 //go:generate pegomock generate --package mocks -o mocks/mock_comment_parsing.go CommentParsing
 
 // CommentParsing handles parsing pull request comments.
@@ -138,8 +162,17 @@ type CommentParseResult struct {
 // - atlantis plan -w staging -d dir --verbose
 // - atlantis plan --verbose -- -key=value -key2 value2
 // - atlantis unlock
+// - atlantis discard-plan -d dir
+// - atlantis lock-status -p project
+// - atlantis compare-plan --against 123
+// - atlantis list-projects
+// - atlantis explain-workflow -p project
+// - atlantis approve-step -p project
+// - atlantis show-config -p project
+// - atlantis plan -i 3
 // - atlantis version
 // - atlantis approve_policies
+// - atlantis policy_check -d dir
 // - atlantis import ADDRESS ID
 func (e *CommentParser) Parse(rawComment string, vcsHost models.VCSHostType) CommentParseResult {
 	comment := strings.TrimSpace(rawComment)
@@ -222,10 +255,19 @@ func (e *CommentParser) Parse(rawComment string, vcsHost models.VCSHostType) Com
 	}
 
 	var workspace string
+	var workspaces []string
 	var dir string
 	var project string
 	var policySet string
 	var clearPolicyApproval bool
+	var ref string
+	var reason string
+	var index int
+	var against int
+	var unlockAll bool
+	var queue bool
+	var targets []string
+	var summary bool
 	var verbose, autoMergeDisabled bool
 	var flagSet *pflag.FlagSet
 	var name command.Name
@@ -236,9 +278,12 @@ func (e *CommentParser) Parse(rawComment string, vcsHost models.VCSHostType) Com
 		name = command.Plan
 		flagSet = pflag.NewFlagSet(command.Plan.String(), pflag.ContinueOnError)
 		flagSet.SetOutput(io.Discard)
-		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Switch to this Terraform workspace before planning.")
+		flagSet.StringArrayVarP(&workspaces, workspaceFlagLong, workspaceFlagShort, nil, "Switch to this Terraform workspace before planning. Repeat to plan each workspace.")
 		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Which directory to run plan in relative to root of repo, ex. 'child/dir'.")
 		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", "Which project to run plan for. Refers to the name of the project configured in a repo config file. Cannot be used at same time as workspace or dir flags.")
+		flagSet.StringVarP(&ref, refFlagLong, refFlagShort, "", "Plan this git ref instead of the pull request's head, ex. 'origin/hotfix'. Must be allowed by the server-side repo config.")
+		flagSet.IntVarP(&index, indexFlagLong, indexFlagShort, 0, "Which project to run plan for by its index in the last list-projects comment. Cannot be used at same time as workspace, dir or project flags.")
+		flagSet.BoolVar(&summary, summaryFlagLong, false, "Add a table of the resources the plan changes and their actions to the comment.")
 		flagSet.BoolVarP(&verbose, verboseFlagLong, verboseFlagShort, false, "Append Atlantis log to comment.")
 	case command.Apply.String():
 		name = command.Apply
@@ -247,7 +292,11 @@ func (e *CommentParser) Parse(rawComment string, vcsHost models.VCSHostType) Com
 		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Apply the plan for this Terraform workspace.")
 		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Apply the plan for this directory, relative to root of repo, ex. 'child/dir'.")
 		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", "Apply the plan for this project. Refers to the name of the project configured in a repo config file. Cannot be used at same time as workspace or dir flags.")
+		flagSet.IntVarP(&index, indexFlagLong, indexFlagShort, 0, "Apply the plan for the project with this index in the last list-projects comment. Cannot be used at same time as workspace, dir or project flags.")
 		flagSet.BoolVarP(&autoMergeDisabled, autoMergeDisabledFlagLong, autoMergeDisabledFlagShort, false, "Disable automerge after apply.")
+		flagSet.StringVarP(&reason, reasonFlagLong, reasonFlagShort, "", "Why you're applying, ex. a ticket. Recorded in the comment and exported results. Required if the server-side repo config sets require_apply_reason.")
+		flagSet.BoolVar(&queue, queueFlagLong, false, "If a project is locked by another pull request, queue the apply and run it when the lock is released. Only projects with repo_locks mode on_apply can be queued.")
+		flagSet.StringArrayVar(&targets, targetFlagLong, nil, "Apply only the changes of this resource or module in the plan, ex. 'module.db'. Repeat to apply several.")
 		flagSet.BoolVarP(&verbose, verboseFlagLong, verboseFlagShort, false, "Append Atlantis log to comment.")
 	case command.ApprovePolicies.String():
 		name = command.ApprovePolicies
@@ -259,10 +308,80 @@ func (e *CommentParser) Parse(rawComment string, vcsHost models.VCSHostType) Com
 		flagSet.StringVarP(&policySet, policySetFlagLong, policySetFlagShort, "", "Approve policies for this project. Refers to the name of the project configured in a repo config file. Cannot be used at same time as workspace or dir flags.")
 		flagSet.BoolVarP(&clearPolicyApproval, clearPolicyApprovalFlagLong, clearPolicyApprovalFlagShort, false, "Clear any existing policy approvals.")
 		flagSet.BoolVarP(&verbose, verboseFlagLong, verboseFlagShort, false, "Append Atlantis log to comment.")
+	case command.PolicyCheck.String():
+		name = command.PolicyCheck
+		flagSet = pflag.NewFlagSet(command.PolicyCheck.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Check policies against the plan for this Terraform workspace.")
+		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Check policies against the plan for this directory, relative to root of repo, ex. 'child/dir'.")
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", "Check policies against the plan for this project. Refers to the name of the project configured in a repo config file. Cannot be used at same time as workspace or dir flags.")
+		flagSet.BoolVarP(&verbose, verboseFlagLong, verboseFlagShort, false, "Append Atlantis log to comment.")
 	case command.Unlock.String():
 		name = command.Unlock
 		flagSet = pflag.NewFlagSet(command.Unlock.String(), pflag.ContinueOnError)
 		flagSet.SetOutput(io.Discard)
+		flagSet.BoolVar(&unlockAll, allFlagLong, false, "List every lock that's unlocked. Only the pull request's author or a policy owner can use it.")
+	case command.DiscardPlan.String():
+		name = command.DiscardPlan
+		flagSet = pflag.NewFlagSet(command.DiscardPlan.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Discard the plan for this Terraform workspace.")
+		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Discard the plan for this directory, relative to root of repo, ex. 'child/dir'.")
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", "Discard the plan for this project. Refers to the name of the project configured in a repo config file. Cannot be used at same time as dir flag. With the workspace flag only the project's plan for that workspace is selected.")
+	case command.LockStatus.String():
+		name = command.LockStatus
+		flagSet = pflag.NewFlagSet(command.LockStatus.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Show the lock for this Terraform workspace.")
+		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Show the lock for this directory, relative to root of repo, ex. 'child/dir'.")
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", "Show the lock for this project. Refers to the name of the project configured in a repo config file. Cannot be used at same time as workspace or dir flags.")
+	case command.ListProjects.String():
+		name = command.ListProjects
+		flagSet = pflag.NewFlagSet(command.ListProjects.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+	case command.ComparePlan.String():
+		name = command.ComparePlan
+		flagSet = pflag.NewFlagSet(command.ComparePlan.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+		flagSet.IntVarP(&against, againstFlagLong, againstFlagShort, 0, "Number of the pull request whose plans to compare with, ex. 123.")
+		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Compare the plans for this Terraform workspace.")
+		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Compare the plans for this directory, relative to root of repo, ex. 'child/dir'.")
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", "Compare the plans for this project. Refers to the name of the project configured in a repo config file. Cannot be used at same time as dir flag. With the workspace flag only the project's plan for that workspace is selected.")
+	case command.ExplainWorkflow.String():
+		name = command.ExplainWorkflow
+		flagSet = pflag.NewFlagSet(command.ExplainWorkflow.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Explain the workflow for this Terraform workspace.")
+		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Explain the workflow for this directory, relative to root of repo, ex. 'child/dir'.")
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", "Explain the workflow for this project. Refers to the name of the project configured in a repo config file. Cannot be used at same time as workspace or dir flags.")
+	case command.ApproveStep.String():
+		name = command.ApproveStep
+		flagSet = pflag.NewFlagSet(command.ApproveStep.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Approve the steps waiting in this Terraform workspace.")
+		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Approve the steps waiting in this directory, relative to root of repo, ex. 'child/dir'.")
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", "Approve the steps waiting in this project. Refers to the name of the project configured in a repo config file. Cannot be used at same time as workspace or dir flags.")
+	case command.ShowConfig.String():
+		name = command.ShowConfig
+		flagSet = pflag.NewFlagSet(command.ShowConfig.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Show the config for this Terraform workspace.")
+		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Show the config for this directory, relative to root of repo, ex. 'child/dir'.")
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", "Show the config for this project. Refers to the name of the project configured in a repo config file. Cannot be used at same time as workspace or dir flags.")
+	case command.PinPlan.String():
+		name = command.PinPlan
+		flagSet = pflag.NewFlagSet(command.PinPlan.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Pin the plan for this Terraform workspace.")
+		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Pin the plan for this directory, relative to root of repo, ex. 'child/dir'.")
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", "Pin the plan for this project. Refers to the name of the project configured in a repo config file. Cannot be used at same time as dir flag. With the workspace flag only the project's plan for that workspace is selected.")
+	case command.UnpinPlan.String():
+		name = command.UnpinPlan
+		flagSet = pflag.NewFlagSet(command.UnpinPlan.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Unpin the plan for this Terraform workspace.")
+		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Unpin the plan for this directory, relative to root of repo, ex. 'child/dir'.")
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", "Unpin the plan for this project. Refers to the name of the project configured in a repo config file. Cannot be used at same time as dir flag. With the workspace flag only the project's plan for that workspace is selected.")
 	case command.Version.String():
 		name = command.Version
 		flagSet = pflag.NewFlagSet(command.Version.String(), pflag.ContinueOnError)
@@ -300,11 +419,24 @@ func (e *CommentParser) Parse(rawComment string, vcsHost models.VCSHostType) Com
 		return CommentParseResult{CommentResponse: e.errMarkdown(err.Error(), cmd, flagSet)}
 	}
 
+	// Plan can be given -w more than once to plan each workspace.
+	var uniqueWorkspaces []string
+	for _, w := range workspaces {
+		if !utils.SlicesContains(uniqueWorkspaces, w) {
+			uniqueWorkspaces = append(uniqueWorkspaces, w)
+		}
+	}
+	if len(uniqueWorkspaces) == 1 {
+		workspace = uniqueWorkspaces[0]
+	}
+
 	// Use the same validation that Terraform uses: https://git.io/vxGhU. Plus
 	// we also don't allow '..'. We don't want the workspace to contain a path
 	// since we create files based on the name.
-	if workspace != url.PathEscape(workspace) || strings.Contains(workspace, "..") {
-		return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("invalid workspace: %q", workspace), cmd, flagSet)}
+	for _, w := range append([]string{workspace}, uniqueWorkspaces...) {
+		if w != url.PathEscape(w) || strings.Contains(w, "..") {
+			return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("invalid workspace: %q", w), cmd, flagSet)}
+		}
 	}
 
 	// If project is specified, dir or workspace should not be set. Since we
@@ -312,13 +444,70 @@ func (e *CommentParser) Parse(rawComment string, vcsHost models.VCSHostType) Com
 	// to the default or didn't set the flag so there is an edge case here we
 	// don't detect, ex. atlantis plan -p project -d . -w default won't cause
 	// an error.
-	if project != "" && (workspace != "" || dir != "") {
+	// The commands selecting stored plans match -p and -w against the plans
+	// so -w narrows down which of the project's plans they act on.
+	selectsPlans := name == command.DiscardPlan || name == command.ComparePlan || name == command.PinPlan || name == command.UnpinPlan
+	if project != "" && selectsPlans && dir != "" {
+		err := fmt.Sprintf("cannot use -%s/--%s at same time as -%s/--%s", projectFlagShort, projectFlagLong, dirFlagShort, dirFlagLong)
+		return CommentParseResult{CommentResponse: e.errMarkdown(err, cmd, flagSet)}
+	}
+	if project != "" && !selectsPlans && (workspace != "" || len(uniqueWorkspaces) > 0 || dir != "") {
 		err := fmt.Sprintf("cannot use -%s/--%s at same time as -%s/--%s or -%s/--%s", projectFlagShort, projectFlagLong, dirFlagShort, dirFlagLong, workspaceFlagShort, workspaceFlagLong)
 		return CommentParseResult{CommentResponse: e.errMarkdown(err, cmd, flagSet)}
 	}
 
+	if flagSet.Changed(indexFlagLong) {
+		if index < 1 {
+			return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("invalid index: %d, must be 1 or more", index), cmd, flagSet)}
+		}
+		if project != "" || workspace != "" || len(uniqueWorkspaces) > 0 || dir != "" {
+			err := fmt.Sprintf("cannot use -%s/--%s at same time as -%s/--%s, -%s/--%s or -%s/--%s", indexFlagShort, indexFlagLong, projectFlagShort, projectFlagLong, dirFlagShort, dirFlagLong, workspaceFlagShort, workspaceFlagLong)
+			return CommentParseResult{CommentResponse: e.errMarkdown(err, cmd, flagSet)}
+		}
+	}
+
+	if ref != "" && (!refRegex.MatchString(ref) || strings.Contains(ref, "..") || strings.HasSuffix(ref, "/")) {
+		return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("invalid ref: %q", ref), cmd, flagSet)}
+	}
+
+	if flagSet.Changed(reasonFlagLong) {
+		reason = strings.TrimSpace(reason)
+		if reason == "" {
+			return CommentParseResult{CommentResponse: e.errMarkdown("reason can't be empty", cmd, flagSet)}
+		}
+		if len(reason) > maxApplyReasonLength {
+			return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("reason is %d characters, it can be at most %d", len(reason), maxApplyReasonLength), cmd, flagSet)}
+		}
+		if strings.Contains(reason, "`") {
+			return CommentParseResult{CommentResponse: e.errMarkdown("reason can't contain backticks", cmd, flagSet)}
+		}
+	}
+
+	for i, target := range targets {
+		targets[i] = strings.TrimSpace(target)
+		if !targetRegex.MatchString(targets[i]) {
+			return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("invalid target: %q, must be a resource or module address, ex. module.db", target), cmd, flagSet)}
+		}
+	}
+
+	if name == command.ComparePlan && against < 1 {
+		return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("--%s must be the number of a pull request, ex. --%s 123", againstFlagLong, againstFlagLong), cmd, flagSet)}
+	}
+
+	commentCmd := NewCommentCommand(dir, extraArgs, name, subName, verbose, autoMergeDisabled, workspace, project, policySet, clearPolicyApproval)
+	commentCmd.Ref = ref
+	commentCmd.Reason = reason
+	commentCmd.ProjectIndex = index
+	commentCmd.AgainstPull = against
+	commentCmd.UnlockAll = unlockAll
+	commentCmd.Queue = queue
+	commentCmd.Targets = targets
+	commentCmd.Summary = summary
+	if len(uniqueWorkspaces) > 1 {
+		commentCmd.Workspaces = uniqueWorkspaces
+	}
 	return CommentParseResult{
-		Command: NewCommentCommand(dir, extraArgs, name, subName, verbose, autoMergeDisabled, workspace, project, policySet, clearPolicyApproval),
+		Command: commentCmd,
 	}
 }
 
@@ -413,6 +602,13 @@ func (e *CommentParser) BuildApprovePoliciesComment(repoRelDir string, workspace
 	return fmt.Sprintf("%s %s%s", e.ExecutableName, command.ApprovePolicies.String(), flags)
 }
 
+// BuildApproveStepComment builds an approve-step comment for the specified
+// args.
+func (e *CommentParser) BuildApproveStepComment(repoRelDir string, workspace string, project string) string {
+	flags := e.buildFlags(repoRelDir, workspace, project, false)
+	return fmt.Sprintf("%s %s%s", e.ExecutableName, command.ApproveStep.String(), flags)
+}
+
 func (e *CommentParser) buildFlags(repoRelDir string, workspace string, project string, autoMergeDisabled bool) string {
 	// Add quotes if dir has spaces.
 	if strings.Contains(repoRelDir, " ") {
@@ -494,8 +690,18 @@ func (e *CommentParser) HelpComment() string {
 		AllowApply           bool
 		AllowUnlock          bool
 		AllowApprovePolicies bool
+		AllowPolicyCheck     bool
 		AllowImport          bool
 		AllowState           bool
+		AllowDiscardPlan     bool
+		AllowLockStatus      bool
+		AllowListProjects    bool
+		AllowComparePlan     bool
+		AllowExplainWorkflow bool
+		AllowApproveStep     bool
+		AllowShowConfig      bool
+		AllowPinPlan         bool
+		AllowUnpinPlan       bool
 	}{
 		ExecutableName:       e.ExecutableName,
 		AllowVersion:         e.isAllowedCommand(command.Version.String()),
@@ -503,8 +709,18 @@ func (e *CommentParser) HelpComment() string {
 		AllowApply:           e.isAllowedCommand(command.Apply.String()),
 		AllowUnlock:          e.isAllowedCommand(command.Unlock.String()),
 		AllowApprovePolicies: e.isAllowedCommand(command.ApprovePolicies.String()),
+		AllowPolicyCheck:     e.isAllowedCommand(command.PolicyCheck.String()),
 		AllowImport:          e.isAllowedCommand(command.Import.String()),
 		AllowState:           e.isAllowedCommand(command.State.String()),
+		AllowDiscardPlan:     e.isAllowedCommand(command.DiscardPlan.String()),
+		AllowLockStatus:      e.isAllowedCommand(command.LockStatus.String()),
+		AllowListProjects:    e.isAllowedCommand(command.ListProjects.String()),
+		AllowComparePlan:     e.isAllowedCommand(command.ComparePlan.String()),
+		AllowExplainWorkflow: e.isAllowedCommand(command.ExplainWorkflow.String()),
+		AllowApproveStep:     e.isAllowedCommand(command.ApproveStep.String()),
+		AllowShowConfig:      e.isAllowedCommand(command.ShowConfig.String()),
+		AllowPinPlan:         e.isAllowedCommand(command.PinPlan.String()),
+		AllowUnpinPlan:       e.isAllowedCommand(command.UnpinPlan.String()),
 	}); err != nil {
 		return fmt.Sprintf("Failed to render template, this is a bug: %v", err)
 	}
@@ -548,10 +764,60 @@ Commands:
   unlock   Removes all atlantis locks and discards all plans for this PR.
            To unlock a specific plan you can use the Atlantis UI.
 {{- end }}
+{{- if .AllowDiscardPlan }}
+  discard-plan
+           Discards the plans for this PR without removing the locks.
+           To discard a specific plan, use the -d, -w and -p flags.
+{{- end }}
+{{- if .AllowLockStatus }}
+  lock-status
+           Shows who holds the locks of the projects in this repo.
+           To show the lock of a specific project, use the -d, -w and -p flags.
+{{- end }}
+{{- if .AllowListProjects }}
+  list-projects
+           Lists the projects of this PR by index.
+           To plan or apply a listed project, use the -i flag.
+{{- end }}
+{{- if .AllowComparePlan }}
+  compare-plan --against PULL
+           Compares the plans of this PR with the plans of another PR.
+           To compare a specific plan, use the -d, -w and -p flags.
+{{- end }}
+{{- if .AllowExplainWorkflow }}
+  explain-workflow
+           Shows which workflow the projects of this PR use and why.
+           To explain a specific project, use the -d, -w and -p flags.
+{{- end }}
+{{- if .AllowApproveStep }}
+  approve-step
+           Approves the steps of this PR waiting for approval to run.
+           To approve the steps of a specific project, use the -d, -w and -p flags.
+{{- end }}
+{{- if .AllowShowConfig }}
+  show-config
+           Shows the effective config of the projects of this PR.
+           To show the config of a specific project, use the -d, -w and -p flags.
+{{- end }}
+{{- if .AllowPinPlan }}
+  pin-plan
+           Pins the plans of this PR so they aren't discarded by plan_ttl or new commits.
+           To pin a specific plan, use the -d, -w and -p flags.
+{{- end }}
+{{- if .AllowUnpinPlan }}
+  unpin-plan
+           Unpins the plans of this PR pinned by pin-plan.
+           To unpin a specific plan, use the -d, -w and -p flags.
+{{- end }}
 {{- if .AllowApprovePolicies }}
   approve_policies
            Approves all current policy checking failures for the PR.
 {{- end }}
+{{- if .AllowPolicyCheck }}
+  policy_check
+           Checks policies again against the plans of this PR without re-planning.
+           To check a specific plan, use the -d, -w and -p flags.
+{{- end }}
 {{- if .AllowVersion }}
   version  Print the output of 'terraform version'
 {{- end }}
@@ -581,9 +847,11 @@ var DidYouMeanAtlantisComment = "Did you mean to use `%s` instead of `%s`?"
 // `atlantis unlock` with flags.
 
 var UnlockUsage = "`Usage of unlock:`\n\n ```cmake\n" +
-	`%s unlock
+	`%s unlock [--all]
 
   Unlocks the entire PR and discards all plans in this PR.
-  Arguments or flags are not supported at the moment.
+  --all also lists every lock that was unlocked. Only the PR's author
+  or a policy owner can use it.
+  Other arguments or flags are not supported at the moment.
   If you need to unlock a specific project please use the atlantis UI.` +
 	"\n```"
@@ -60,11 +60,11 @@ func TestNewCommentParser(t *testing.T) {
 		{
 			name: "comment un-available commands filtered",
 			args: args{
-				// PolicyCheck and Autoplan cannot be used on comment command, so filtered
+				// Autoplan cannot be used on comment command, so filtered
 				allowCommands: []command.Name{command.Plan, command.Apply, command.Unlock, command.PolicyCheck, command.ApprovePolicies, command.Autoplan, command.Version, command.Import},
 			},
 			want: &events.CommentParser{
-				AllowCommands: []command.Name{command.Version, command.Plan, command.Apply, command.Unlock, command.ApprovePolicies, command.Import},
+				AllowCommands: []command.Name{command.Version, command.Plan, command.Apply, command.Unlock, command.ApprovePolicies, command.PolicyCheck, command.Import},
 			},
 		},
 	}
@@ -300,6 +300,8 @@ func TestParse_SubcommandUsage(t *testing.T) {
 		{"atlantis apply --help", "apply"},
 		{"atlantis approve_policies -h", "approve_policies"},
 		{"atlantis approve_policies --help", "approve_policies"},
+		{"atlantis policy_check -h", "policy_check"},
+		{"atlantis policy_check --help", "policy_check"},
 		{"atlantis import -h", "import ADDRESS ID"},
 		{"atlantis import --help", "import ADDRESS ID"},
 		{"atlantis state -h", "state [rm ADDRESS...]"},
@@ -435,6 +437,7 @@ func TestParse_InvalidWorkspace(t *testing.T) {
 		"atlantis apply -w ../../../etc/passwd",
 		"atlantis import -w ../../../etc/passwd address id",
 		"atlantis state -w ../../../etc/passwd rm address",
+		"atlantis plan -w staging -w ..",
 	}
 	for _, c := range comments {
 		r := commentParser.Parse(c, models.Github)
@@ -449,6 +452,7 @@ func TestParse_UsingProjectAtSameTimeAsWorkspaceOrDir(t *testing.T) {
 		"atlantis plan -w workspace -p project",
 		"atlantis plan -d dir -p project",
 		"atlantis plan -d dir -w workspace -p project",
+		"atlantis plan -w staging -w prod -p project",
 	}
 	for _, c := range cases {
 		t.Run(c, func(t *testing.T) {
@@ -680,7 +684,7 @@ func TestParse_Parsing(t *testing.T) {
 	}
 
 	for _, test := range cases {
-		for _, cmdName := range []string{"plan", "apply", "import 'some[\"addr\"]' id", "state rm 'some[\"addr\"]'"} {
+		for _, cmdName := range []string{"plan", "apply", "policy_check", "import 'some[\"addr\"]' id", "state rm 'some[\"addr\"]'"} {
 			comment := fmt.Sprintf("atlantis %s %s", cmdName, test.flags)
 			t.Run(comment, func(t *testing.T) {
 				r := commentParser.Parse(comment, models.Github)
@@ -701,6 +705,10 @@ func TestParse_Parsing(t *testing.T) {
 					Assert(t, r.Command.Name == command.ApprovePolicies, "did not parse comment %q as approve_policies command", comment)
 					Assert(t, test.expExtraArgs == actExtraArgs, "exp extra args to equal %v but got %v for comment %q", test.expExtraArgs, actExtraArgs, comment)
 				}
+				if cmdName == "policy_check" {
+					Assert(t, r.Command.Name == command.PolicyCheck, "did not parse comment %q as policy_check command", comment)
+					Assert(t, test.expExtraArgs == actExtraArgs, "exp extra args to equal %v but got %v for comment %q", test.expExtraArgs, actExtraArgs, comment)
+				}
 				if strings.HasPrefix(cmdName, "import") {
 					expExtraArgs := "some[\"addr\"] id" // import use default args with `some["addr"] id`
 					if test.expExtraArgs != "" {
@@ -723,6 +731,333 @@ func TestParse_Parsing(t *testing.T) {
 	}
 }
 
+func TestParse_DiscardPlan(t *testing.T) {
+	cases := []struct {
+		flags        string
+		expWorkspace string
+		expDir       string
+		expProject   string
+	}{
+		{"", "", "", ""},
+		{"-w workspace", "workspace", "", ""},
+		{"-d dir", "", "dir", ""},
+		{"-d dir -w workspace", "workspace", "dir", ""},
+		{"-p project", "", "", "project"},
+		{"-p project -w workspace", "workspace", "", "project"},
+	}
+	for _, c := range cases {
+		comment := fmt.Sprintf("atlantis discard-plan %s", c.flags)
+		t.Run(comment, func(t *testing.T) {
+			r := commentParser.Parse(comment, models.Github)
+			Equals(t, "", r.CommentResponse)
+			Equals(t, command.DiscardPlan, r.Command.Name)
+			Equals(t, c.expWorkspace, r.Command.Workspace)
+			Equals(t, c.expDir, r.Command.RepoRelDir)
+			Equals(t, c.expProject, r.Command.ProjectName)
+		})
+	}
+
+	r := commentParser.Parse("atlantis discard-plan --verbose", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "unknown flag: --verbose"), "exp unknown flag error but got %q", r.CommentResponse)
+
+	r = commentParser.Parse("atlantis discard-plan -p project -d dir", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "cannot use -p/--project at same time as -d/--dir"), "exp project flag conflict but got %q", r.CommentResponse)
+}
+
+func TestParse_PlanRef(t *testing.T) {
+	cases := []struct {
+		ref    string
+		expErr string
+	}{
+		{"origin/hotfix", ""},
+		{"v1.2.0", ""},
+		{"3f2a9c1", ""},
+		{"-upload-pack=touch", "invalid ref: \"-upload-pack=touch\""},
+		{"../main", "invalid ref: \"../main\""},
+		{"hotfix/", "invalid ref: \"hotfix/\""},
+	}
+	for _, c := range cases {
+		comment := fmt.Sprintf("atlantis plan -d dir --ref=%s", c.ref)
+		t.Run(comment, func(t *testing.T) {
+			r := commentParser.Parse(comment, models.Github)
+			if c.expErr != "" {
+				Assert(t, strings.Contains(r.CommentResponse, c.expErr), "exp %q in %q", c.expErr, r.CommentResponse)
+				return
+			}
+			Equals(t, "", r.CommentResponse)
+			Equals(t, command.Plan, r.Command.Name)
+			Equals(t, "dir", r.Command.RepoRelDir)
+			Equals(t, c.ref, r.Command.Ref)
+		})
+	}
+
+	r := commentParser.Parse("atlantis apply --ref origin/hotfix", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "unknown flag: --ref"), "exp unknown flag error but got %q", r.CommentResponse)
+}
+
+func TestParse_ApplyReason(t *testing.T) {
+	cases := []struct {
+		comment   string
+		expReason string
+		expErr    string
+	}{
+		{`atlantis apply --reason "JIRA-123 emergency fix"`, "JIRA-123 emergency fix", ""},
+		{`atlantis apply -p project --reason=JIRA-123`, "JIRA-123", ""},
+		{`atlantis apply`, "", ""},
+		{`atlantis apply --reason " "`, "", "reason can't be empty"},
+		{`atlantis apply --reason "` + strings.Repeat("a", 201) + `"`, "", "reason is 201 characters, it can be at most 200"},
+		{"atlantis apply --reason '`rm -rf`'", "", "reason can't contain backticks"},
+		{`atlantis plan --reason JIRA-123`, "", "unknown flag: --reason"},
+	}
+	for _, c := range cases {
+		t.Run(c.comment, func(t *testing.T) {
+			r := commentParser.Parse(c.comment, models.Github)
+			if c.expErr != "" {
+				Assert(t, strings.Contains(r.CommentResponse, c.expErr), "exp %q in %q", c.expErr, r.CommentResponse)
+				return
+			}
+			Equals(t, "", r.CommentResponse)
+			Equals(t, c.expReason, r.Command.Reason)
+		})
+	}
+}
+
+func TestParse_ApplyQueue(t *testing.T) {
+	r := commentParser.Parse("atlantis apply -p project", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Assert(t, !r.Command.Queue, "exp apply to not be queued")
+
+	r = commentParser.Parse("atlantis apply -p project --queue", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, command.Apply, r.Command.Name)
+	Equals(t, "project", r.Command.ProjectName)
+	Assert(t, r.Command.Queue, "exp apply --queue to be queued")
+
+	r = commentParser.Parse("atlantis plan --queue", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "unknown flag: --queue"), "exp unknown flag in %q", r.CommentResponse)
+}
+
+func TestParse_ApplyTarget(t *testing.T) {
+	r := commentParser.Parse("atlantis apply -p project", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, 0, len(r.Command.Targets))
+
+	r = commentParser.Parse(`atlantis apply -p project --target module.db --target='aws_instance.web["a"]'`, models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, []string{"module.db", `aws_instance.web["a"]`}, r.Command.Targets)
+
+	for _, target := range []string{"", "module.db;rm", "`module.db`", "$(id)"} {
+		r = commentParser.Parse(fmt.Sprintf("atlantis apply --target=%q", target), models.Github)
+		Assert(t, strings.Contains(r.CommentResponse, "invalid target"), "exp invalid target for %q in %q", target, r.CommentResponse)
+	}
+
+	r = commentParser.Parse("atlantis plan --target module.db", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "unknown flag: --target"), "exp unknown flag in %q", r.CommentResponse)
+}
+
+func TestParse_PlanSummary(t *testing.T) {
+	r := commentParser.Parse("atlantis plan -d dir", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, false, r.Command.Summary)
+
+	r = commentParser.Parse("atlantis plan -d dir --summary -- -var foo=bar", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, true, r.Command.Summary)
+	Equals(t, []string{"-var", "foo=bar"}, r.Command.Flags)
+
+	r = commentParser.Parse("atlantis apply --summary", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "unknown flag: --summary"), "exp unknown flag in %q", r.CommentResponse)
+}
+
+func TestParse_PlanMultipleWorkspaces(t *testing.T) {
+	r := commentParser.Parse("atlantis plan -d dir -w staging -w prod --workspace staging", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, command.Plan, r.Command.Name)
+	Equals(t, "dir", r.Command.RepoRelDir)
+	Equals(t, "", r.Command.Workspace)
+	Equals(t, []string{"staging", "prod"}, r.Command.Workspaces)
+	Assert(t, r.Command.IsForSpecificProject(), "exp command to be for a specific project")
+
+	// A single workspace, even if repeated, is the same as before.
+	r = commentParser.Parse("atlantis plan -w staging -w staging", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "staging", r.Command.Workspace)
+	Equals(t, []string(nil), r.Command.Workspaces)
+}
+
+func TestParse_LockStatus(t *testing.T) {
+	cases := []struct {
+		flags        string
+		expWorkspace string
+		expDir       string
+		expProject   string
+	}{
+		{"", "", "", ""},
+		{"-w workspace", "workspace", "", ""},
+		{"-d dir", "", "dir", ""},
+		{"-p project", "", "", "project"},
+	}
+	for _, c := range cases {
+		comment := fmt.Sprintf("atlantis lock-status %s", c.flags)
+		t.Run(comment, func(t *testing.T) {
+			r := commentParser.Parse(comment, models.Github)
+			Equals(t, "", r.CommentResponse)
+			Equals(t, command.LockStatus, r.Command.Name)
+			Equals(t, c.expWorkspace, r.Command.Workspace)
+			Equals(t, c.expDir, r.Command.RepoRelDir)
+			Equals(t, c.expProject, r.Command.ProjectName)
+		})
+	}
+
+	r := commentParser.Parse("atlantis lock-status -p project -d dir", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "cannot use -p/--project at same time as -d/--dir or -w/--workspace"), "exp project flag conflict but got %q", r.CommentResponse)
+}
+
+func TestParse_Index(t *testing.T) {
+	for _, comment := range []string{"atlantis plan -i 3", "atlantis apply --index 3", "atlantis apply --index=3 --auto-merge-disabled"} {
+		t.Run(comment, func(t *testing.T) {
+			r := commentParser.Parse(comment, models.Github)
+			Equals(t, "", r.CommentResponse)
+			Equals(t, 3, r.Command.ProjectIndex)
+			Assert(t, r.Command.IsForSpecificProject(), "exp command to be for a specific project")
+		})
+	}
+
+	cases := []struct {
+		comment string
+		expErr  string
+	}{
+		{"atlantis plan -i 0", "invalid index: 0, must be 1 or more"},
+		{"atlantis apply -i -1", "invalid index: -1, must be 1 or more"},
+		{"atlantis plan -i three", "invalid argument \"three\" for \"-i, --index\" flag"},
+		{"atlantis plan -i 3 -p project", "cannot use -i/--index at same time as -p/--project, -d/--dir or -w/--workspace"},
+		{"atlantis apply -i 3 -d dir", "cannot use -i/--index at same time as -p/--project, -d/--dir or -w/--workspace"},
+		{"atlantis plan -i 3 -w staging -w prod", "cannot use -i/--index at same time as -p/--project, -d/--dir or -w/--workspace"},
+		{"atlantis discard-plan -i 3", "unknown shorthand flag: 'i' in -i"},
+	}
+	for _, c := range cases {
+		t.Run(c.comment, func(t *testing.T) {
+			r := commentParser.Parse(c.comment, models.Github)
+			Assert(t, strings.Contains(r.CommentResponse, c.expErr), "exp %q in comment but got %q", c.expErr, r.CommentResponse)
+		})
+	}
+}
+
+func TestParse_ListProjects(t *testing.T) {
+	r := commentParser.Parse("atlantis list-projects", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, command.ListProjects, r.Command.Name)
+	Assert(t, !r.Command.IsForSpecificProject(), "exp command to not be for a specific project")
+
+	r = commentParser.Parse("atlantis list-projects -d dir", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "unknown shorthand flag: 'd' in -d"), "exp unknown flag error but got %q", r.CommentResponse)
+}
+
+func TestParse_ComparePlan(t *testing.T) {
+	r := commentParser.Parse("atlantis compare-plan --against 123", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, command.ComparePlan, r.Command.Name)
+	Equals(t, 123, r.Command.AgainstPull)
+	Assert(t, !r.Command.IsForSpecificProject(), "exp command to not be for a specific project")
+
+	r = commentParser.Parse("atlantis compare-plan --against=123 -p network", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "network", r.Command.ProjectName)
+
+	for _, comment := range []string{"atlantis compare-plan", "atlantis compare-plan --against 0"} {
+		r = commentParser.Parse(comment, models.Github)
+		Assert(t, strings.Contains(r.CommentResponse, "--against must be the number of a pull request, ex. --against 123"), "exp --against error but got %q", r.CommentResponse)
+	}
+	r = commentParser.Parse("atlantis compare-plan --against abc", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "invalid argument \"abc\" for \"--against\" flag"), "exp invalid argument error but got %q", r.CommentResponse)
+}
+
+func TestParse_ExplainWorkflow(t *testing.T) {
+	r := commentParser.Parse("atlantis explain-workflow", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, command.ExplainWorkflow, r.Command.Name)
+	Assert(t, !r.Command.IsForSpecificProject(), "exp command to not be for a specific project")
+
+	r = commentParser.Parse("atlantis explain-workflow -p app", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "app", r.Command.ProjectName)
+
+	r = commentParser.Parse("atlantis explain-workflow -d dir -w staging", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "dir", r.Command.RepoRelDir)
+	Equals(t, "staging", r.Command.Workspace)
+}
+
+func TestParse_ApproveStep(t *testing.T) {
+	r := commentParser.Parse("atlantis approve-step", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, command.ApproveStep, r.Command.Name)
+	Assert(t, !r.Command.IsForSpecificProject(), "exp command to not be for a specific project")
+
+	r = commentParser.Parse("atlantis approve-step -p app", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "app", r.Command.ProjectName)
+
+	r = commentParser.Parse("atlantis approve-step -d dir -w staging", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "dir", r.Command.RepoRelDir)
+	Equals(t, "staging", r.Command.Workspace)
+}
+
+func TestBuildApproveStepComment(t *testing.T) {
+	Equals(t, "atlantis approve-step -p app", commentParser.BuildApproveStepComment("dir", "default", "app"))
+	Equals(t, "atlantis approve-step -d .", commentParser.BuildApproveStepComment(".", "default", ""))
+	Equals(t, "atlantis approve-step -d dir -w staging", commentParser.BuildApproveStepComment("dir", "staging", ""))
+}
+
+func TestParse_ShowConfig(t *testing.T) {
+	r := commentParser.Parse("atlantis show-config", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, command.ShowConfig, r.Command.Name)
+	Assert(t, !r.Command.IsForSpecificProject(), "exp command to not be for a specific project")
+
+	r = commentParser.Parse("atlantis show-config -p app", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "app", r.Command.ProjectName)
+
+	r = commentParser.Parse("atlantis show-config -d dir -w staging", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "dir", r.Command.RepoRelDir)
+	Equals(t, "staging", r.Command.Workspace)
+}
+
+func TestParse_PinPlan(t *testing.T) {
+	for _, name := range []command.Name{command.PinPlan, command.UnpinPlan} {
+		t.Run(name.String(), func(t *testing.T) {
+			r := commentParser.Parse("atlantis "+name.String(), models.Github)
+			Equals(t, "", r.CommentResponse)
+			Equals(t, name, r.Command.Name)
+			Assert(t, !r.Command.IsForSpecificProject(), "exp command to not be for a specific project")
+
+			r = commentParser.Parse("atlantis "+name.String()+" -p app", models.Github)
+			Equals(t, "", r.CommentResponse)
+			Equals(t, "app", r.Command.ProjectName)
+
+			r = commentParser.Parse("atlantis "+name.String()+" -d dir -w staging", models.Github)
+			Equals(t, "", r.CommentResponse)
+			Equals(t, "dir", r.Command.RepoRelDir)
+			Equals(t, "staging", r.Command.Workspace)
+		})
+	}
+}
+
+func TestParse_UnlockAll(t *testing.T) {
+	r := commentParser.Parse("atlantis unlock", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, command.Unlock, r.Command.Name)
+	Assert(t, !r.Command.UnlockAll, "exp unlock to not be unlock --all")
+
+	r = commentParser.Parse("atlantis unlock --all", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, command.Unlock, r.Command.Name)
+	Assert(t, r.Command.UnlockAll, "exp unlock --all")
+}
+
 func TestBuildPlanApplyVersionComment(t *testing.T) {
 	cases := []struct {
 		repoRelDir        string
@@ -878,8 +1213,38 @@ Commands:
            To only apply a specific plan, use the -d, -w and -p flags.
   unlock   Removes all atlantis locks and discards all plans for this PR.
            To unlock a specific plan you can use the Atlantis UI.
+  discard-plan
+           Discards the plans for this PR without removing the locks.
+           To discard a specific plan, use the -d, -w and -p flags.
+  lock-status
+           Shows who holds the locks of the projects in this repo.
+           To show the lock of a specific project, use the -d, -w and -p flags.
+  list-projects
+           Lists the projects of this PR by index.
+           To plan or apply a listed project, use the -i flag.
+  compare-plan --against PULL
+           Compares the plans of this PR with the plans of another PR.
+           To compare a specific plan, use the -d, -w and -p flags.
+  explain-workflow
+           Shows which workflow the projects of this PR use and why.
+           To explain a specific project, use the -d, -w and -p flags.
+  approve-step
+           Approves the steps of this PR waiting for approval to run.
+           To approve the steps of a specific project, use the -d, -w and -p flags.
+  show-config
+           Shows the effective config of the projects of this PR.
+           To show the config of a specific project, use the -d, -w and -p flags.
+  pin-plan
+           Pins the plans of this PR so they aren't discarded by plan_ttl or new commits.
+           To pin a specific plan, use the -d, -w and -p flags.
+  unpin-plan
+           Unpins the plans of this PR pinned by pin-plan.
+           To unpin a specific plan, use the -d, -w and -p flags.
   approve_policies
            Approves all current policy checking failures for the PR.
+  policy_check
+           Checks policies again against the plans of this PR without re-planning.
+           To check a specific plan, use the -d, -w and -p flags.
   version  Print the output of 'terraform version'
   import ADDRESS ID
            Runs 'terraform import' for the passed address resource.
@@ -1010,22 +1375,42 @@ func TestParse_VCSUsername(t *testing.T) {
 }
 
 var PlanUsage = `Usage of plan:
-  -d, --dir string         Which directory to run plan in relative to root of repo,
-                           ex. 'child/dir'.
-  -p, --project string     Which project to run plan for. Refers to the name of the
-                           project configured in a repo config file. Cannot be used
-                           at same time as workspace or dir flags.
-      --verbose            Append Atlantis log to comment.
-  -w, --workspace string   Switch to this Terraform workspace before planning.
+  -d, --dir string              Which directory to run plan in relative to root of
+                                repo, ex. 'child/dir'.
+  -i, --index int               Which project to run plan for by its index in the
+                                last list-projects comment. Cannot be used at same
+                                time as workspace, dir or project flags.
+  -p, --project string          Which project to run plan for. Refers to the name of
+                                the project configured in a repo config file. Cannot
+                                be used at same time as workspace or dir flags.
+      --ref string              Plan this git ref instead of the pull request's
+                                head, ex. 'origin/hotfix'. Must be allowed by the
+                                server-side repo config.
+      --summary                 Add a table of the resources the plan changes and
+                                their actions to the comment.
+      --verbose                 Append Atlantis log to comment.
+  -w, --workspace stringArray   Switch to this Terraform workspace before planning.
+                                Repeat to plan each workspace.
 `
 
 var ApplyUsage = `Usage of apply:
       --auto-merge-disabled   Disable automerge after apply.
   -d, --dir string            Apply the plan for this directory, relative to root of
                               repo, ex. 'child/dir'.
+  -i, --index int             Apply the plan for the project with this index in the
+                              last list-projects comment. Cannot be used at same
+                              time as workspace, dir or project flags.
   -p, --project string        Apply the plan for this project. Refers to the name of
                               the project configured in a repo config file. Cannot
                               be used at same time as workspace or dir flags.
+      --queue                 If a project is locked by another pull request, queue
+                              the apply and run it when the lock is released. Only
+                              projects with repo_locks mode on_apply can be queued.
+      --reason string         Why you're applying, ex. a ticket. Recorded in the
+                              comment and exported results. Required if the
+                              server-side repo config sets require_apply_reason.
+      --target stringArray    Apply only the changes of this resource or module in
+                              the plan, ex. 'module.db'. Repeat to apply several.
       --verbose               Append Atlantis log to comment.
   -w, --workspace string      Apply the plan for this Terraform workspace.
 `
@@ -1047,10 +1432,12 @@ var ApprovePolicyUsage = `Usage of approve_policies:
 `
 
 var UnlockUsage = "`Usage of unlock:`\n\n ```cmake\n" +
-	`atlantis unlock
+	`atlantis unlock [--all]
 
   Unlocks the entire PR and discards all plans in this PR.
-  Arguments or flags are not supported at the moment.
+  --all also lists every lock that was unlocked. Only the PR's author
+  or a policy owner can use it.
+  Other arguments or flags are not supported at the moment.
   If you need to unlock a specific project please use the atlantis UI.` +
 	"\n```"
 
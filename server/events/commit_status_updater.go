@@ -15,7 +15,11 @@ package events
 
 import (
 	"fmt"
+	"strings"
+	"text/template"
+	"unicode/utf8"
 
+	"github.com/runatlantis/atlantis/server/core/config/valid"
 	"github.com/runatlantis/atlantis/server/core/runtime"
 	"github.com/runatlantis/atlantis/server/events/command"
 	"github.com/runatlantis/atlantis/server/events/models"
@@ -36,16 +40,86 @@ type CommitStatusUpdater interface {
 	// UpdateCombinedCount updates the combined status to reflect the
 	// numSuccess out of numTotal.
 	UpdateCombinedCount(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, status models.CommitStatus, cmdName command.Name, numSuccess int, numTotal int) error
+	// UpdateCombinedPlanSummary updates the combined plan status to reflect
+	// the numSuccess out of numTotal and the changes summed across projects.
+	UpdateCombinedPlanSummary(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, status models.CommitStatus, numSuccess int, numTotal int, stats models.PlanSuccessStats) error
 
 	UpdatePreWorkflowHook(logger logging.SimpleLogging, pull models.PullRequest, status models.CommitStatus, hookDescription string, runtimeDescription string, url string) error
 	UpdatePostWorkflowHook(logger logging.SimpleLogging, pull models.PullRequest, status models.CommitStatus, hookDescription string, runtimeDescription string, url string) error
 }
 
+// DefaultStatusContextTemplate renders the default status contexts, ex.
+// atlantis/plan for combined statuses and atlantis/plan: myproject for
+// project statuses.
+const DefaultStatusContextTemplate = "{{ .StatusName }}/{{ .Command }}{{ if .Project }}: {{ .Project }}{{ end }}"
+
+// maxStatusContextLength is the longest status context we send. GitHub and
+// GitLab reject longer contexts.
+const maxStatusContextLength = 255
+
+// StatusContextData is the data available to status context templates.
+type StatusContextData struct {
+	// StatusName is the --vcs-status-name, ex. atlantis.
+	StatusName string
+	// Command is the command, ex. plan, or the workflow hook type, ex.
+	// pre_workflow_hook.
+	Command string
+	// Project identifies the project: its name if set, otherwise dir/workspace.
+	// For workflow hooks it's the hook description. It's empty for combined
+	// statuses.
+	Project string
+	// ProjectName is the name of the project. It's empty for combined statuses
+	// and projects without a name.
+	ProjectName string
+	// Dir is the project's directory relative to the repo root. It's empty
+	// for combined statuses.
+	Dir string
+	// Workspace is the project's workspace. It's empty for combined statuses.
+	Workspace string
+}
+
+// NewStatusContextTemplate parses text as a status context template and
+// checks that it renders a non-empty context for combined and project
+// statuses.
+func NewStatusContextTemplate(text string) (*template.Template, error) {
+	// Statuses are public, so only the step template functions are
+	// available, not the ones that read the server's environment.
+	tmpl, err := template.New("status-context").Funcs(valid.StepTemplateFuncs()).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing status context template: %w", err)
+	}
+	for _, data := range []StatusContextData{
+		{StatusName: "atlantis", Command: command.Plan.String()},
+		{StatusName: "atlantis", Command: command.Plan.String(), Project: "project", ProjectName: "project", Dir: "dir", Workspace: "default"},
+	} {
+		ctx, err := renderStatusContext(tmpl, data)
+		if err != nil {
+			return nil, fmt.Errorf("rendering status context template: %w", err)
+		}
+		if ctx == "" {
+			return nil, fmt.Errorf("status context template %q renders an empty context", text)
+		}
+	}
+	return tmpl, nil
+}
+
+var defaultStatusContextTemplate = template.Must(NewStatusContextTemplate(DefaultStatusContextTemplate))
+
 // DefaultCommitStatusUpdater implements CommitStatusUpdater.
 type DefaultCommitStatusUpdater struct {
 	Client vcs.Client
 	// StatusName is the name used to identify Atlantis when creating PR statuses.
 	StatusName string
+	// ContextTemplate renders the context of each status. If nil,
+	// DefaultStatusContextTemplate is used.
+	ContextTemplate *template.Template
+	// StatusStrategy is the server's status strategy,
+	// valid.StatusStrategyAggregate or valid.StatusStrategyPerProject. If
+	// empty both combined and project statuses are set.
+	StatusStrategy string
+	// GlobalCfg is the server-side repo config. A repo's status_strategy
+	// overrides StatusStrategy.
+	GlobalCfg valid.GlobalCfg
 }
 
 // ensure DefaultCommitStatusUpdater implements runtime.StatusUpdater interface
@@ -53,7 +127,10 @@ type DefaultCommitStatusUpdater struct {
 var _ runtime.StatusUpdater = (*DefaultCommitStatusUpdater)(nil)
 
 func (d *DefaultCommitStatusUpdater) UpdateCombined(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, status models.CommitStatus, cmdName command.Name) error {
-	src := fmt.Sprintf("%s/%s", d.StatusName, cmdName.String())
+	if d.statusStrategy(repo) == valid.StatusStrategyPerProject {
+		return nil
+	}
+	src := d.statusContext(logger, StatusContextData{Command: cmdName.String()})
 	var descripWords string
 	switch status {
 	case models.PendingCommitStatus:
@@ -67,7 +144,10 @@ func (d *DefaultCommitStatusUpdater) UpdateCombined(logger logging.SimpleLogging
 }
 
 func (d *DefaultCommitStatusUpdater) UpdateCombinedCount(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, status models.CommitStatus, cmdName command.Name, numSuccess int, numTotal int) error {
-	src := fmt.Sprintf("%s/%s", d.StatusName, cmdName.String())
+	if d.statusStrategy(repo) == valid.StatusStrategyPerProject {
+		return nil
+	}
+	src := d.statusContext(logger, StatusContextData{Command: cmdName.String()})
 	cmdVerb := "unknown"
 
 	switch cmdName {
@@ -82,12 +162,34 @@ func (d *DefaultCommitStatusUpdater) UpdateCombinedCount(logger logging.SimpleLo
 	return d.Client.UpdateStatus(logger, repo, pull, status, src, fmt.Sprintf("%d/%d projects %s successfully.", numSuccess, numTotal, cmdVerb), "")
 }
 
+func (d *DefaultCommitStatusUpdater) UpdateCombinedPlanSummary(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, status models.CommitStatus, numSuccess int, numTotal int, stats models.PlanSuccessStats) error {
+	if d.statusStrategy(repo) == valid.StatusStrategyPerProject {
+		return nil
+	}
+	src := d.statusContext(logger, StatusContextData{Command: command.Plan.String()})
+	changes := fmt.Sprintf("%d to add, %d to change, %d to destroy", stats.Add, stats.Change, stats.Destroy)
+	if stats.Import > 0 {
+		changes = fmt.Sprintf("%d to import, %s", stats.Import, changes)
+	}
+	descrip := fmt.Sprintf("%d/%d projects planned: %s.", numSuccess, numTotal, changes)
+	return d.Client.UpdateStatus(logger, repo, pull, status, src, descrip, "")
+}
+
 func (d *DefaultCommitStatusUpdater) UpdateProject(ctx command.ProjectContext, cmdName command.Name, status models.CommitStatus, url string, result *command.ProjectResult) error {
+	if d.statusStrategy(ctx.BaseRepo) == valid.StatusStrategyAggregate {
+		return nil
+	}
 	projectID := ctx.ProjectName
 	if projectID == "" {
 		projectID = fmt.Sprintf("%s/%s", ctx.RepoRelDir, ctx.Workspace)
 	}
-	src := fmt.Sprintf("%s/%s: %s", d.StatusName, cmdName.String(), projectID)
+	src := d.statusContext(ctx.Log, StatusContextData{
+		Command:     cmdName.String(),
+		Project:     projectID,
+		ProjectName: ctx.ProjectName,
+		Dir:         ctx.RepoRelDir,
+		Workspace:   ctx.Workspace,
+	})
 	var descripWords string
 	switch status {
 	case models.PendingCommitStatus:
@@ -117,7 +219,7 @@ func (d *DefaultCommitStatusUpdater) UpdatePostWorkflowHook(log logging.SimpleLo
 }
 
 func (d *DefaultCommitStatusUpdater) updateWorkflowHook(log logging.SimpleLogging, pull models.PullRequest, status models.CommitStatus, hookDescription string, runtimeDescription string, workflowType string, url string) error {
-	src := fmt.Sprintf("%s/%s: %s", d.StatusName, workflowType, hookDescription)
+	src := d.statusContext(log, StatusContextData{Command: workflowType, Project: hookDescription})
 
 	var descripWords string
 	if runtimeDescription != "" {
@@ -135,3 +237,59 @@ func (d *DefaultCommitStatusUpdater) updateWorkflowHook(log logging.SimpleLoggin
 
 	return d.Client.UpdateStatus(log, pull.BaseRepo, pull, status, src, descripWords, url)
 }
+
+// statusStrategy returns the status strategy of repo: its status_strategy if
+// it's set, otherwise the server's.
+func (d *DefaultCommitStatusUpdater) statusStrategy(repo models.Repo) string {
+	if strategy := d.GlobalCfg.RepoStatusStrategy(repo.ID()); strategy != "" {
+		return strategy
+	}
+	return d.StatusStrategy
+}
+
+// ApplyStatusContext returns the context of the combined apply status. The
+// mergeable requirement ignores statuses starting with it, so Atlantis's own
+// apply statuses don't block apply.
+func (d *DefaultCommitStatusUpdater) ApplyStatusContext(logger logging.SimpleLogging) string {
+	applyCtx := d.statusContext(logger, StatusContextData{Command: command.Apply.String()})
+	projectCtx := d.statusContext(logger, StatusContextData{
+		Command:     command.Apply.String(),
+		Project:     "project",
+		ProjectName: "project",
+		Dir:         "dir",
+		Workspace:   "default",
+	})
+	if !strings.HasPrefix(projectCtx, applyCtx) {
+		logger.Warn("project apply status %q doesn't start with the apply status %q, so the mergeable requirement won't ignore it", projectCtx, applyCtx)
+	}
+	return applyCtx
+}
+
+// statusContext renders the context of a status from data. If the template
+// fails, the default context is used so the status is still updated.
+func (d *DefaultCommitStatusUpdater) statusContext(logger logging.SimpleLogging, data StatusContextData) string {
+	data.StatusName = d.StatusName
+	if d.ContextTemplate != nil {
+		ctx, err := renderStatusContext(d.ContextTemplate, data)
+		if err == nil && ctx != "" {
+			return ctx
+		}
+		logger.Warn("unable to render status context template, using the default context: %v", err)
+	}
+	ctx, _ := renderStatusContext(defaultStatusContextTemplate, data)
+	return ctx
+}
+
+// renderStatusContext executes tmpl with data and truncates the result to
+// maxStatusContextLength characters.
+func renderStatusContext(tmpl *template.Template, data StatusContextData) (string, error) {
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	ctx := strings.TrimSpace(buf.String())
+	if utf8.RuneCountInString(ctx) > maxStatusContextLength {
+		ctx = string([]rune(ctx)[:maxStatusContextLength-3]) + "..."
+	}
+	return ctx, nil
+}
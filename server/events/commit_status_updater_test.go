@@ -15,9 +15,11 @@ package events_test
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	. "github.com/petergtz/pegomock/v4"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
 	"github.com/runatlantis/atlantis/server/events"
 	"github.com/runatlantis/atlantis/server/events/command"
 	"github.com/runatlantis/atlantis/server/events/models"
@@ -146,6 +148,44 @@ func TestUpdateCombinedCount(t *testing.T) {
 	}
 }
 
+func TestUpdateCombinedPlanSummary(t *testing.T) {
+	logger := logging.NewNoopLogger(t)
+	cases := []struct {
+		status     models.CommitStatus
+		numSuccess int
+		numTotal   int
+		stats      models.PlanSuccessStats
+		expDescrip string
+	}{
+		{
+			status:     models.SuccessCommitStatus,
+			numSuccess: 2,
+			numTotal:   2,
+			stats:      models.PlanSuccessStats{Add: 3, Change: 1},
+			expDescrip: "2/2 projects planned: 3 to add, 1 to change, 0 to destroy.",
+		},
+		{
+			status:     models.FailedCommitStatus,
+			numSuccess: 1,
+			numTotal:   2,
+			stats:      models.PlanSuccessStats{Import: 2, Destroy: 1},
+			expDescrip: "1/2 projects planned: 2 to import, 0 to add, 0 to change, 1 to destroy.",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expDescrip, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			client := mocks.NewMockClient()
+			s := events.DefaultCommitStatusUpdater{Client: client, StatusName: "atlantis-test"}
+			err := s.UpdateCombinedPlanSummary(logger, models.Repo{}, models.PullRequest{}, c.status, c.numSuccess, c.numTotal, c.stats)
+			Ok(t, err)
+
+			client.VerifyWasCalledOnce().UpdateStatus(logger, models.Repo{}, models.PullRequest{}, c.status, "atlantis-test/plan", c.expDescrip, "")
+		})
+	}
+}
+
 // Test that it sets the "source" properly depending on if the project is
 // named or not.
 func TestDefaultCommitStatusUpdater_UpdateProjectSrc(t *testing.T) {
@@ -264,3 +304,159 @@ func TestDefaultCommitStatusUpdater_UpdateProjectCustomStatusName(t *testing.T)
 	client.VerifyWasCalledOnce().UpdateStatus(Any[logging.SimpleLogging](), Eq(models.Repo{}), Eq(models.PullRequest{}),
 		Eq(models.SuccessCommitStatus), Eq("custom/apply: ./default"), Eq("Apply succeeded."), Eq("url"))
 }
+
+func TestDefaultCommitStatusUpdater_ContextTemplate(t *testing.T) {
+	RegisterMockTestingT(t)
+	logger := logging.NewNoopLogger(t)
+	tmpl, err := events.NewStatusContextTemplate("ci/{{ .StatusName }}/{{ .Command }}{{ if .Project }}/{{ .Dir }}/{{ .Workspace | upper }}{{ end }}")
+	Ok(t, err)
+	client := mocks.NewMockClient()
+	s := events.DefaultCommitStatusUpdater{Client: client, StatusName: "atlantis", ContextTemplate: tmpl}
+
+	Ok(t, s.UpdateCombined(logger, models.Repo{}, models.PullRequest{}, models.PendingCommitStatus, command.Plan))
+	client.VerifyWasCalledOnce().UpdateStatus(Any[logging.SimpleLogging](), Eq(models.Repo{}), Eq(models.PullRequest{}),
+		Eq(models.PendingCommitStatus), Eq("ci/atlantis/plan"), Eq("Plan in progress..."), Eq(""))
+
+	Ok(t, s.UpdateProject(command.ProjectContext{
+		Log:        logger,
+		RepoRelDir: "dir1",
+		Workspace:  "staging",
+	}, command.Apply, models.SuccessCommitStatus, "url", nil))
+	client.VerifyWasCalledOnce().UpdateStatus(Any[logging.SimpleLogging](), Eq(models.Repo{}), Eq(models.PullRequest{}),
+		Eq(models.SuccessCommitStatus), Eq("ci/atlantis/apply/dir1/STAGING"), Eq("Apply succeeded."), Eq("url"))
+}
+
+func TestDefaultCommitStatusUpdater_ApplyStatusContext(t *testing.T) {
+	logger := logging.NewNoopLogger(t)
+	s := events.DefaultCommitStatusUpdater{StatusName: "atlantis"}
+	Equals(t, "atlantis/apply", s.ApplyStatusContext(logger))
+
+	tmpl, err := events.NewStatusContextTemplate("ci/{{ .StatusName }}/{{ .Command }}{{ if .Project }}/{{ .Project }}{{ end }}")
+	Ok(t, err)
+	s.ContextTemplate = tmpl
+	Equals(t, "ci/atlantis/apply", s.ApplyStatusContext(logger))
+}
+
+// Test that contexts are truncated to the length VCS hosts accept.
+func TestDefaultCommitStatusUpdater_ContextTemplateTruncated(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmpl, err := events.NewStatusContextTemplate("{{ .StatusName }}/{{ .Command }}{{ if .Project }}/{{ .Project }}{{ end }}")
+	Ok(t, err)
+	client := mocks.NewMockClient()
+	s := events.DefaultCommitStatusUpdater{Client: client, StatusName: "atlantis", ContextTemplate: tmpl}
+	projectName := strings.Repeat("a", 300)
+	Ok(t, s.UpdateProject(command.ProjectContext{
+		ProjectName: projectName,
+	}, command.Plan, models.PendingCommitStatus, "url", nil))
+	client.VerifyWasCalledOnce().UpdateStatus(Any[logging.SimpleLogging](), Eq(models.Repo{}), Eq(models.PullRequest{}),
+		Eq(models.PendingCommitStatus), Eq("atlantis/plan/"+projectName[:238]+"..."), Eq("Plan in progress..."), Eq("url"))
+}
+
+// Test that only the statuses of the status strategy are set when one project
+// succeeds and another fails, and that a repo's strategy overrides the
+// server's.
+func TestDefaultCommitStatusUpdater_StatusStrategy(t *testing.T) {
+	logger := logging.NewNoopLogger(t)
+	repo := models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Hostname: "github.com"}}
+	cases := []struct {
+		description     string
+		serverStrategy  string
+		repoStrategy    string
+		expCombined     bool
+		expProjectCalls bool
+	}{
+		{
+			description:     "default",
+			expCombined:     true,
+			expProjectCalls: true,
+		},
+		{
+			description:    "aggregate",
+			serverStrategy: valid.StatusStrategyAggregate,
+			expCombined:    true,
+		},
+		{
+			description:     "per-project",
+			serverStrategy:  valid.StatusStrategyPerProject,
+			expProjectCalls: true,
+		},
+		{
+			description:     "repo overrides server",
+			serverStrategy:  valid.StatusStrategyAggregate,
+			repoStrategy:    valid.StatusStrategyPerProject,
+			expProjectCalls: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			client := mocks.NewMockClient()
+			globalCfg := valid.NewGlobalCfgFromArgs(valid.GlobalCfgArgs{})
+			globalCfg.Repos = append(globalCfg.Repos, valid.Repo{ID: repo.ID(), StatusStrategy: c.repoStrategy})
+			s := events.DefaultCommitStatusUpdater{Client: client, StatusName: "atlantis", StatusStrategy: c.serverStrategy, GlobalCfg: globalCfg}
+
+			Ok(t, s.UpdateProject(command.ProjectContext{Log: logger, BaseRepo: repo, RepoRelDir: "dir1", Workspace: "default"},
+				command.Plan, models.SuccessCommitStatus, "url1", nil))
+			Ok(t, s.UpdateProject(command.ProjectContext{Log: logger, BaseRepo: repo, RepoRelDir: "dir2", Workspace: "default"},
+				command.Plan, models.FailedCommitStatus, "url2", nil))
+			Ok(t, s.UpdateCombinedCount(logger, repo, models.PullRequest{}, models.FailedCommitStatus, command.Plan, 1, 2))
+			Ok(t, s.UpdateCombined(logger, repo, models.PullRequest{}, models.FailedCommitStatus, command.Plan))
+
+			projectTimes := Never()
+			if c.expProjectCalls {
+				projectTimes = Once()
+			}
+			client.VerifyWasCalled(projectTimes).UpdateStatus(Any[logging.SimpleLogging](), Eq(repo), Eq(models.PullRequest{}),
+				Eq(models.SuccessCommitStatus), Eq("atlantis/plan: dir1/default"), Eq("Plan succeeded."), Eq("url1"))
+			client.VerifyWasCalled(projectTimes).UpdateStatus(Any[logging.SimpleLogging](), Eq(repo), Eq(models.PullRequest{}),
+				Eq(models.FailedCommitStatus), Eq("atlantis/plan: dir2/default"), Eq("Plan failed."), Eq("url2"))
+
+			combinedTimes := Never()
+			if c.expCombined {
+				combinedTimes = Once()
+			}
+			client.VerifyWasCalled(combinedTimes).UpdateStatus(Any[logging.SimpleLogging](), Eq(repo), Eq(models.PullRequest{}),
+				Eq(models.FailedCommitStatus), Eq("atlantis/plan"), Eq("1/2 projects planned successfully."), Eq(""))
+			client.VerifyWasCalled(combinedTimes).UpdateStatus(Any[logging.SimpleLogging](), Eq(repo), Eq(models.PullRequest{}),
+				Eq(models.FailedCommitStatus), Eq("atlantis/plan"), Eq("Plan failed."), Eq(""))
+		})
+	}
+}
+
+func TestNewStatusContextTemplate(t *testing.T) {
+	cases := []struct {
+		text   string
+		expErr string
+	}{
+		{
+			text: events.DefaultStatusContextTemplate,
+		},
+		{
+			text:   "{{ .Command",
+			expErr: "parsing status context template: template: status-context:1: unclosed action",
+		},
+		{
+			text:   "{{ .Unknown }}",
+			expErr: "rendering status context template: template: status-context:1:3: executing \"status-context\" at <.Unknown>: can't evaluate field Unknown in type events.StatusContextData",
+		},
+		{
+			text:   "{{ .ProjectName }}",
+			expErr: "status context template \"{{ .ProjectName }}\" renders an empty context",
+		},
+		{
+			text:   "{{ env \"HOME\" }}",
+			expErr: "parsing status context template: template: status-context:1: function \"env\" not defined",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.text, func(t *testing.T) {
+			_, err := events.NewStatusContextTemplate(c.text)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+		})
+	}
+}
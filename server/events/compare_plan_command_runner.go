@@ -0,0 +1,270 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	"github.com/runatlantis/atlantis/server/core/terraform"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+func NewComparePlanCommandRunner(
+	vcsClient vcs.Client,
+	pendingPlanFinder PendingPlanFinder,
+	workingDir WorkingDir,
+	workingDirLocker WorkingDirLocker,
+	showStepRunner StepRunner,
+	terraformClient terraform.Client,
+	planEncryptor *runtime.PlanEncryptor,
+) *ComparePlanCommandRunner {
+	return &ComparePlanCommandRunner{
+		vcsClient:         vcsClient,
+		pendingPlanFinder: pendingPlanFinder,
+		workingDir:        workingDir,
+		workingDirLocker:  workingDirLocker,
+		showStepRunner:    showStepRunner,
+		terraformClient:   terraformClient,
+		planEncryptor:     planEncryptor,
+	}
+}
+
+// ComparePlanCommandRunner comments how the stored plans of a pull request
+// differ from those of the pull request given with compare-plan --against,
+// so reviewers of competing changes can see which resources both change and
+// whether they change them the same way. Plans are compared for the projects
+// planned in both pull requests.
+type ComparePlanCommandRunner struct {
+	vcsClient         vcs.Client
+	pendingPlanFinder PendingPlanFinder
+	workingDir        WorkingDir
+	// workingDirLocker keeps plans from being read while a command of their
+	// pull request replaces them.
+	workingDirLocker WorkingDirLocker
+	// showStepRunner writes the JSON of plans that don't have it yet.
+	showStepRunner StepRunner
+	// terraformClient detects the Terraform version plans are shown with.
+	terraformClient terraform.Client
+	// planEncryptor decrypts plans encrypted at rest. If nil plans aren't
+	// encrypted.
+	planEncryptor *runtime.PlanEncryptor
+}
+
+func (c *ComparePlanCommandRunner) Run(ctx *command.Context, cmd *CommentCommand) {
+	baseRepo := ctx.Pull.BaseRepo
+	pullNum := ctx.Pull.Num
+
+	var vcsMessage string
+	if cmd.AgainstPull == pullNum {
+		vcsMessage = "Can't compare the plans of a pull request with themselves, use `--against` with the number of another pull request."
+	} else {
+		var err error
+		vcsMessage, err = c.comparePlans(ctx, cmd)
+		if err != nil {
+			ctx.Log.Err("failed to compare plans: %s", err)
+			vcsMessage = fmt.Sprintf("Failed to compare plans: %s", err)
+		}
+	}
+
+	if commentErr := c.vcsClient.CreateComment(ctx.Log, baseRepo, pullNum, vcsMessage, command.ComparePlan.String()); commentErr != nil {
+		ctx.Log.Err("unable to comment: %s", commentErr)
+	}
+}
+
+// comparePlans returns the comment comparing the plans of ctx's pull request
+// matching cmd with those of cmd.AgainstPull.
+func (c *ComparePlanCommandRunner) comparePlans(ctx *command.Context, cmd *CommentCommand) (string, error) {
+	ours, err := c.findPlans(ctx.Pull, cmd)
+	if err != nil {
+		return "", err
+	}
+	if len(ours) == 0 {
+		if cmd.IsForSpecificProject() {
+			return "No matching plans in this pull request, run `atlantis plan` first.", nil
+		}
+		return "This pull request has no plans to compare, run `atlantis plan` first.", nil
+	}
+	other := models.PullRequest{Num: cmd.AgainstPull, BaseRepo: ctx.Pull.BaseRepo}
+	theirs, err := c.findPlans(other, cmd)
+	if err != nil {
+		return "", errors.Wrapf(err, "finding plans of #%d", other.Num)
+	}
+	if len(theirs) == 0 {
+		return fmt.Sprintf("#%d has no plans to compare with. Plans are deleted once they're applied or their pull request is closed, run `atlantis plan` on it first.", other.Num), nil
+	}
+
+	theirsByProject := make(map[string]PendingPlan)
+	for _, plan := range theirs {
+		theirsByProject[pendingPlanDescription(plan)] = plan
+	}
+	var b strings.Builder
+	var onlyOurs []string
+	compared := make(map[string]bool)
+	for _, plan := range ours {
+		description := pendingPlanDescription(plan)
+		theirPlan, ok := theirsByProject[description]
+		if !ok {
+			onlyOurs = append(onlyOurs, "- "+description)
+			continue
+		}
+		compared[description] = true
+		fmt.Fprintf(&b, "\n#### %s\n", description)
+		comparison, err := c.compareProject(ctx.Log, ctx.Pull, other, plan, theirPlan)
+		if err != nil {
+			ctx.Log.Warn("failed to compare plans for %s: %s", description, err)
+			fmt.Fprintf(&b, "Failed to compare plans: %s\n", err)
+			continue
+		}
+		b.WriteString(comparison)
+	}
+	var onlyTheirs []string
+	for _, plan := range theirs {
+		if description := pendingPlanDescription(plan); !compared[description] {
+			onlyTheirs = append(onlyTheirs, "- "+description)
+		}
+	}
+
+	if len(compared) == 0 {
+		return fmt.Sprintf("None of the projects planned in this pull request are planned in #%d, so there's nothing to compare.\n\nPlanned in this pull request:\n%s\n\nPlanned in #%d:\n%s",
+			other.Num, strings.Join(onlyOurs, "\n"), other.Num, strings.Join(onlyTheirs, "\n")), nil
+	}
+	comment := fmt.Sprintf("Compared the plans of this pull request with #%d:\n%s", other.Num, b.String())
+	if len(onlyOurs) > 0 {
+		comment += fmt.Sprintf("\nNot compared, only planned in this pull request:\n%s\n", strings.Join(onlyOurs, "\n"))
+	}
+	if len(onlyTheirs) > 0 {
+		comment += fmt.Sprintf("\nNot compared, only planned in #%d:\n%s\n", other.Num, strings.Join(onlyTheirs, "\n"))
+	}
+	return strings.TrimSuffix(comment, "\n"), nil
+}
+
+// findPlans returns the stored plans of pull matching cmd. It returns no
+// plans if pull has never been planned on this server.
+func (c *ComparePlanCommandRunner) findPlans(pull models.PullRequest, cmd *CommentCommand) ([]PendingPlan, error) {
+	pullDir, err := c.workingDir.GetPullDir(pull.BaseRepo, pull)
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	all, err := c.pendingPlanFinder.Find(pullDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding plans")
+	}
+	var plans []PendingPlan
+	for _, plan := range all {
+		if pendingPlanMatches(cmd, plan) {
+			plans = append(plans, plan)
+		}
+	}
+	return plans, nil
+}
+
+// compareProject returns the table of the resources changed by both ours and
+// theirs, plans for the same project in pull and other.
+func (c *ComparePlanCommandRunner) compareProject(log logging.SimpleLogging, pull models.PullRequest, other models.PullRequest, ours PendingPlan, theirs PendingPlan) (string, error) {
+	ourPlan, err := c.readPlan(log, pull, ours)
+	if err != nil {
+		return "", err
+	}
+	theirPlan, err := c.readPlan(log, other, theirs)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading plan of #%d", other.Num)
+	}
+	ourChanges := planChanges(ourPlan)
+	theirChanges := planChanges(theirPlan)
+
+	var addresses []string
+	for address := range ourChanges {
+		if _, ok := theirChanges[address]; ok {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+
+	var b strings.Builder
+	if len(addresses) == 0 {
+		b.WriteString("No resources are changed by both pull requests.\n")
+	} else {
+		fmt.Fprintf(&b, "| Resource | This pull request | #%d | Same change |\n", other.Num)
+		b.WriteString("| --- | --- | --- | --- |\n")
+		for _, address := range addresses {
+			ourChange, theirChange := ourChanges[address], theirChanges[address]
+			same := "yes"
+			switch {
+			case planActions(ourChange) != planActions(theirChange):
+				same = "no, different actions"
+			case !jsonEqual(ourChange.Change.After, theirChange.Change.After):
+				same = "no, different values"
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", address, planActions(ourChange), planActions(theirChange), same)
+		}
+	}
+	fmt.Fprintf(&b, "\n%d other resource(s) only changed by this pull request, %d only by #%d.\n",
+		len(ourChanges)-len(addresses), len(theirChanges)-len(addresses), other.Num)
+	return b.String(), nil
+}
+
+// readPlan returns the JSON of plan, a plan of pull. The plan is shown with
+// the Terraform version its project requires, or the default version, if it
+// doesn't have its JSON yet. It errors if a command of pull is running in
+// the plan's workspace, since the plan may be replaced or deleted under it.
+func (c *ComparePlanCommandRunner) readPlan(log logging.SimpleLogging, pull models.PullRequest, plan PendingPlan) (planJSON, error) {
+	unlockFn, err := c.workingDirLocker.TryLock(pull.BaseRepo.FullName, pull.Num, plan.Workspace, plan.RepoRelDir)
+	if err != nil {
+		return planJSON{}, err
+	}
+	defer unlockFn()
+
+	projectDir := filepath.Join(plan.RepoDir, plan.RepoRelDir)
+	ctx := command.ProjectContext{
+		Log:         log,
+		Workspace:   plan.Workspace,
+		RepoRelDir:  plan.RepoRelDir,
+		ProjectName: plan.ProjectName,
+	}
+	if c.terraformClient != nil {
+		ctx.TerraformVersion = c.terraformClient.DetectVersion(log, projectDir)
+	}
+	return readPlanJSON(c.showStepRunner, c.planEncryptor, projectDir, ctx)
+}
+
+// planChanges returns the resources plan changes by their address. Resources
+// that are only read or aren't changed are left out.
+func planChanges(plan planJSON) map[string]planResourceChange {
+	changes := make(map[string]planResourceChange)
+	for _, rc := range plan.ResourceChanges {
+		if actions := planActions(rc); actions != "no-op" && actions != "read" {
+			changes[rc.Address] = rc
+		}
+	}
+	return changes
+}
+
+// planActions returns what a plan does to a resource, ex. update or replace.
+func planActions(rc planResourceChange) string {
+	if len(rc.Change.Actions) == 2 {
+		// A delete and a create, in either order.
+		return "replace"
+	}
+	return strings.Join(rc.Change.Actions, ", ")
+}
+
+// jsonEqual returns true if a and b are the same JSON, ignoring whitespace.
+func jsonEqual(a json.RawMessage, b json.RawMessage) bool {
+	var compactA, compactB bytes.Buffer
+	if json.Compact(&compactA, a) != nil || json.Compact(&compactB, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(compactA.Bytes(), compactB.Bytes())
+}
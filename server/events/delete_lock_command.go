@@ -11,7 +11,7 @@ import (
 // DeleteLockCommand is the first step after a command request has been parsed.
 type DeleteLockCommand interface {
 	DeleteLock(logger logging.SimpleLogging, id string) (*models.ProjectLock, error)
-	DeleteLocksByPull(logger logging.SimpleLogging, repoFullName string, pullNum int) (int, error)
+	DeleteLocksByPull(logger logging.SimpleLogging, repoFullName string, pullNum int) ([]models.ProjectLock, error)
 }
 
 // DefaultDeleteLockCommand deletes a specific lock after a request from the LocksController.
@@ -41,27 +41,25 @@ func (l *DefaultDeleteLockCommand) DeleteLock(logger logging.SimpleLogging, id s
 	return lock, nil
 }
 
-// DeleteLocksByPull handles deleting all locks for the pull request
-func (l *DefaultDeleteLockCommand) DeleteLocksByPull(logger logging.SimpleLogging, repoFullName string, pullNum int) (int, error) {
+// DeleteLocksByPull handles deleting all locks for the pull request and
+// returns the locks that were deleted
+func (l *DefaultDeleteLockCommand) DeleteLocksByPull(logger logging.SimpleLogging, repoFullName string, pullNum int) ([]models.ProjectLock, error) {
 	locks, err := l.Locker.UnlockByPull(repoFullName, pullNum)
-	numLocks := len(locks)
 	if err != nil {
-		return numLocks, err
+		return locks, err
 	}
-	if numLocks == 0 {
+	if len(locks) == 0 {
 		logger.Debug("No locks found for repo '%v', pull request: %v", repoFullName, pullNum)
-		return numLocks, nil
+		return locks, nil
 	}
 
-	for i := 0; i < numLocks; i++ {
-		lock := locks[i]
-
+	for _, lock := range locks {
 		err := l.WorkingDir.DeletePlan(logger, lock.Pull.BaseRepo, lock.Pull, lock.Workspace, lock.Project.Path, lock.Project.ProjectName)
 		if err != nil {
 			logger.Warn("Failed to delete plan: %s", err)
-			return numLocks, err
+			return locks, err
 		}
 	}
 
-	return numLocks, nil
+	return locks, nil
 }
@@ -0,0 +1,158 @@
+package events
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/core/locking"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+func NewDiscardPlanCommandRunner(
+	vcsClient vcs.Client,
+	pendingPlanFinder PendingPlanFinder,
+	workingDir WorkingDir,
+	workingDirLocker WorkingDirLocker,
+	backend locking.Backend,
+	policyOwners valid.PolicyOwners,
+	SilenceNoProjects bool,
+) *DiscardPlanCommandRunner {
+	return &DiscardPlanCommandRunner{
+		vcsClient:         vcsClient,
+		pendingPlanFinder: pendingPlanFinder,
+		workingDir:        workingDir,
+		workingDirLocker:  workingDirLocker,
+		backend:           backend,
+		policyOwners:      policyOwners,
+		SilenceNoProjects: SilenceNoProjects,
+	}
+}
+
+// DiscardPlanCommandRunner deletes the stored plans of the projects targeted
+// by a discard-plan comment so they can't be applied until they're planned
+// again. Unlike unlock it doesn't release any locks.
+type DiscardPlanCommandRunner struct {
+	vcsClient         vcs.Client
+	pendingPlanFinder PendingPlanFinder
+	workingDir        WorkingDir
+	workingDirLocker  WorkingDirLocker
+	backend           locking.Backend
+	// policyOwners are the owners of the server's policies. Along with the
+	// pull request's author they can discard plans.
+	policyOwners valid.PolicyOwners
+	// SilenceNoProjects is whether Atlantis should respond to PRs if no plans
+	// are found
+	SilenceNoProjects bool
+}
+
+func (d *DiscardPlanCommandRunner) Run(ctx *command.Context, cmd *CommentCommand) {
+	baseRepo := ctx.Pull.BaseRepo
+	pullNum := ctx.Pull.Num
+
+	var vcsMessage string
+	if err := checkAuthorOrPolicyOwner(ctx, d.vcsClient, d.policyOwners, cmd.Name.String()); err != nil {
+		ctx.Log.Warn("denied %s of pull request %s#%d for user %q: %s", cmd.Name.String(), baseRepo.FullName, pullNum, ctx.User.Username, err)
+		vcsMessage = fmt.Sprintf("Not discarding plans: %s", err)
+	} else {
+		discarded, err := d.discardPlans(ctx, cmd)
+		switch {
+		case err != nil:
+			ctx.Log.Err("failed to discard plans: %s", err)
+			vcsMessage = fmt.Sprintf("Failed to discard plans: %s", err)
+		case len(discarded) == 0:
+			ctx.Log.Info("No plans to discard")
+			if d.SilenceNoProjects {
+				return
+			}
+			vcsMessage = "No plans found to discard"
+		default:
+			vcsMessage = fmt.Sprintf("Discarded %d plan(s), run `atlantis plan` to plan again:\n\n%s", len(discarded), strings.Join(discarded, "\n"))
+		}
+	}
+
+	if commentErr := d.vcsClient.CreateComment(ctx.Log, baseRepo, pullNum, vcsMessage, command.DiscardPlan.String()); commentErr != nil {
+		ctx.Log.Err("unable to comment: %s", commentErr)
+	}
+}
+
+// discardPlans deletes the pending plans matching cmd and marks them as
+// discarded in the backend. It returns a description of each discarded plan.
+// It errors if a command of the pull request is running in the workspace of a
+// plan, since the plan may be in use.
+func (d *DiscardPlanCommandRunner) discardPlans(ctx *command.Context, cmd *CommentCommand) ([]string, error) {
+	pullDir, err := d.workingDir.GetPullDir(ctx.Pull.BaseRepo, ctx.Pull)
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	plans, err := d.pendingPlanFinder.Find(pullDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding plans")
+	}
+
+	var discarded []string
+	for _, plan := range plans {
+		if !pendingPlanMatches(cmd, plan) {
+			continue
+		}
+		if err := d.discardPlan(ctx, plan); err != nil {
+			return discarded, err
+		}
+		if err := d.backend.UpdateProjectStatus(ctx.Pull, plan.Workspace, plan.RepoRelDir, models.DiscardedPlanStatus); err != nil {
+			ctx.Log.Warn("unable to update project status: %s", err)
+		}
+		discarded = append(discarded, "- "+pendingPlanDescription(plan))
+	}
+	return discarded, nil
+}
+
+// discardPlan deletes plan while holding the lock of its workspace.
+func (d *DiscardPlanCommandRunner) discardPlan(ctx *command.Context, plan PendingPlan) error {
+	unlockFn, err := d.workingDirLocker.TryLock(ctx.Pull.BaseRepo.FullName, ctx.Pull.Num, plan.Workspace, plan.RepoRelDir)
+	if err != nil {
+		return err
+	}
+	defer unlockFn()
+	if err := d.workingDir.DeletePlan(ctx.Log, ctx.Pull.BaseRepo, ctx.Pull, plan.Workspace, plan.RepoRelDir, plan.ProjectName); err != nil {
+		return errors.Wrapf(err, "deleting plan for dir %q workspace %q", plan.RepoRelDir, plan.Workspace)
+	}
+	return nil
+}
+
+// pendingPlanMatches returns true if plan is targeted by cmd. Without flags
+// every plan is targeted. Otherwise -p selects plans by project name, and
+// workspace if -w is also set, and -d/-w by directory and workspace,
+// defaulting like apply does.
+func pendingPlanMatches(cmd *CommentCommand, plan PendingPlan) bool {
+	if !cmd.IsForSpecificProject() {
+		return true
+	}
+	if cmd.ProjectName != "" {
+		return plan.ProjectName == cmd.ProjectName && (cmd.Workspace == "" || plan.Workspace == cmd.Workspace)
+	}
+	repoRelDir := DefaultRepoRelDir
+	if cmd.RepoRelDir != "" {
+		repoRelDir = cmd.RepoRelDir
+	}
+	workspace := DefaultWorkspace
+	if cmd.Workspace != "" {
+		workspace = cmd.Workspace
+	}
+	return plan.RepoRelDir == repoRelDir && plan.Workspace == workspace
+}
+
+// pendingPlanDescription returns the project of plan as it's described in
+// comments, ex. dir: `staging` workspace: `default`.
+func pendingPlanDescription(plan PendingPlan) string {
+	if plan.ProjectName != "" {
+		return fmt.Sprintf("project: `%s` dir: `%s` workspace: `%s`", plan.ProjectName, plan.RepoRelDir, plan.Workspace)
+	}
+	return fmt.Sprintf("dir: `%s` workspace: `%s`", plan.RepoRelDir, plan.Workspace)
+}
@@ -133,6 +133,10 @@ type CommentCommand struct {
 	// Workspace is the name of the Terraform workspace to run the command in.
 	// If empty then the comment specified no workspace.
 	Workspace string
+	// Workspaces are the Terraform workspaces to plan when the comment
+	// specified more than one, ex. atlantis plan -w staging -w prod. The
+	// command runs in each of them and Workspace is empty.
+	Workspaces []string
 	// ProjectName is the name of a project to run the command on. It refers to a
 	// project specified in an atlantis.yaml file.
 	// If empty then the comment specified no project.
@@ -141,13 +145,39 @@ type CommentCommand struct {
 	PolicySet string
 	// ClearPolicyApproval is true if approvals should be cleared out for specified policies.
 	ClearPolicyApproval bool
+	// Ref is the git ref to plan instead of the pull request's head, ex.
+	// origin/hotfix. If empty then the comment specified no ref.
+	Ref string
+	// Reason is why the command is run, given with apply --reason, ex. a
+	// ticket. If empty then the comment specified no reason.
+	Reason string
+	// ProjectIndex is the index of the project to run the command on in the
+	// last list-projects comment, starting at 1. If 0 then the comment
+	// specified no index.
+	ProjectIndex int
+	// AgainstPull is the number of the pull request whose plans compare-plan
+	// compares with, given with --against.
+	AgainstPull int
+	// UnlockAll is true for unlock --all, which lists every lock it unlocks
+	// and can only be run by the pull request's author or a policy owner.
+	UnlockAll bool
+	// Queue is true for apply --queue, which queues the apply of a project
+	// locked by another pull request until the lock is released. Only projects
+	// with the on_apply repo_locks mode can be queued.
+	Queue bool
+	// Targets are the resource or module addresses given with apply
+	// --target, ex. module.db. Only their changes in the plan are applied.
+	Targets []string
+	// Summary is true for plan --summary, which adds a table of the
+	// resources the plans change to the comment.
+	Summary bool
 }
 
 // IsForSpecificProject returns true if the command is for a specific dir, workspace
 // or project name. Otherwise it's a command like "atlantis plan" or "atlantis
 // apply".
 func (c CommentCommand) IsForSpecificProject() bool {
-	return c.RepoRelDir != "" || c.Workspace != "" || c.ProjectName != ""
+	return c.RepoRelDir != "" || c.Workspace != "" || len(c.Workspaces) > 0 || c.ProjectName != "" || c.ProjectIndex != 0
 }
 
 // Dir returns the dir of this command.
@@ -0,0 +1,109 @@
+package events
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/core/locking"
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// ExpiredPlanDiscarder discards plans that are older than their project's
+// plan_ttl so they don't count as pending anymore: the planfile is deleted,
+// the project's status is set to discarded, its lock is released and the
+// pull request's combined plan status is set back to pending.
+type ExpiredPlanDiscarder struct {
+	PendingPlanFinder   *DefaultPendingPlanFinder
+	WorkingDir          WorkingDir
+	Locker              locking.Locker
+	Backend             locking.Backend
+	CommitStatusUpdater CommitStatusUpdater
+}
+
+// DiscardPull discards the expired plans of pull.
+func (d *ExpiredPlanDiscarder) DiscardPull(log logging.SimpleLogging, pull models.PullRequest) {
+	pullDir, err := d.WorkingDir.GetPullDir(pull.BaseRepo, pull)
+	if os.IsNotExist(errors.Cause(err)) {
+		return
+	}
+	if err != nil {
+		log.Warn("unable to find expired plans: %s", err)
+		return
+	}
+	plans, err := d.PendingPlanFinder.FindExpired(pullDir)
+	if err != nil {
+		log.Warn("unable to find expired plans: %s", err)
+		return
+	}
+	var discarded int
+	for _, plan := range plans {
+		project := models.NewProject(pull.BaseRepo.FullName, plan.RepoRelDir, plan.ProjectName)
+		if err := d.discard(log, pull, project, plan.Workspace); err != nil {
+			log.Warn("unable to discard expired plan for %s: %s", pendingPlanDescription(plan), err)
+			continue
+		}
+		discarded++
+	}
+	if discarded > 0 {
+		d.updatePlanStatus(log, pull)
+	}
+}
+
+// DiscardLocked discards the plan of the project locked by lock if it's
+// expired, which releases the lock. It returns true if it was discarded.
+func (d *ExpiredPlanDiscarder) DiscardLocked(log logging.SimpleLogging, lock models.ProjectLock) bool {
+	repoDir, err := d.WorkingDir.GetWorkingDir(lock.Pull.BaseRepo, lock.Pull, lock.Workspace)
+	if err != nil {
+		return false
+	}
+	planFile := filepath.Join(repoDir, lock.Project.Path, runtime.GetPlanFilename(lock.Workspace, lock.Project.ProjectName))
+	if !runtime.PlanExpired(planFile) {
+		return false
+	}
+	if err := d.discard(log, lock.Pull, lock.Project, lock.Workspace); err != nil {
+		log.Warn("unable to discard expired plan locking %s: %s", locking.Key(lock.Project, lock.Workspace), err)
+		return false
+	}
+	d.updatePlanStatus(log, lock.Pull)
+	return true
+}
+
+// discard deletes the plan of project in workspace, marks it as discarded
+// and releases its lock if pull holds it.
+func (d *ExpiredPlanDiscarder) discard(log logging.SimpleLogging, pull models.PullRequest, project models.Project, workspace string) error {
+	if err := d.WorkingDir.DeletePlan(log, pull.BaseRepo, pull, workspace, project.Path, project.ProjectName); err != nil {
+		return errors.Wrap(err, "deleting plan")
+	}
+	if err := d.Backend.UpdateProjectStatus(pull, workspace, project.Path, models.DiscardedPlanStatus); err != nil {
+		log.Warn("unable to update project status: %s", err)
+	}
+	key := locking.Key(project, workspace)
+	lock, err := d.Locker.GetLock(key)
+	if err != nil {
+		return errors.Wrap(err, "getting lock")
+	}
+	if lock != nil && lock.Pull.Num == pull.Num {
+		if _, err := d.Locker.Unlock(key); err != nil {
+			return errors.Wrap(err, "releasing lock")
+		}
+	}
+	log.Info("discarded the plan for %s of pull %s#%d because it's older than its plan_ttl", key, pull.BaseRepo.FullName, pull.Num)
+	return nil
+}
+
+// updatePlanStatus sets pull's combined plan status to pending since some of
+// its projects need to be planned again.
+func (d *ExpiredPlanDiscarder) updatePlanStatus(log logging.SimpleLogging, pull models.PullRequest) {
+	pullStatus, err := d.Backend.GetPullStatus(pull)
+	if err != nil || pullStatus == nil {
+		return
+	}
+	numPlanned := len(pullStatus.Projects) - pullStatus.StatusCount(models.ErroredPlanStatus) - pullStatus.StatusCount(models.DiscardedPlanStatus)
+	if err := d.CommitStatusUpdater.UpdateCombinedCount(log, pull.BaseRepo, pull, models.PendingCommitStatus, command.Plan, numPlanned, len(pullStatus.Projects)); err != nil {
+		log.Warn("unable to update commit status: %s", err)
+	}
+}
@@ -0,0 +1,108 @@
+package events_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/petergtz/pegomock/v4"
+	lockmocks "github.com/runatlantis/atlantis/server/core/locking/mocks"
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// Test that expired plans are discarded along with their lock and the combined
+// plan status is set back to pending, while other plans are kept.
+func TestExpiredPlanDiscarder_DiscardPull(t *testing.T) {
+	RegisterMockTestingT(t)
+	logger := logging.NewNoopLogger(t)
+	tmp := DirStructure(t, map[string]interface{}{
+		"default": map[string]interface{}{
+			"dir1": map[string]interface{}{
+				"default.tfplan": nil,
+			},
+			"dir2": map[string]interface{}{
+				"default.tfplan": nil,
+			},
+		},
+	})
+	runCmd(t, filepath.Join(tmp, "default"), "git", "init")
+	expiredPlan := filepath.Join(tmp, "default", "dir2", "default.tfplan")
+	Ok(t, runtime.RecordPlanTTL(expiredPlan, time.Hour))
+	old := time.Now().Add(-2 * time.Hour)
+	Ok(t, os.Chtimes(expiredPlan, old, old))
+
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{BaseRepo: repo, Num: 1}
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.GetPullDir(repo, pull)).ThenReturn(tmp, nil)
+	locker := lockmocks.NewMockLocker()
+	key := "owner/repo/dir2/default"
+	When(locker.GetLock(key)).ThenReturn(&models.ProjectLock{Pull: pull}, nil)
+	backend := lockmocks.NewMockBackend()
+	When(backend.GetPullStatus(pull)).ThenReturn(&models.PullStatus{
+		Projects: []models.ProjectStatus{
+			{RepoRelDir: "dir1", Workspace: "default", Status: models.PlannedPlanStatus},
+			{RepoRelDir: "dir2", Workspace: "default", Status: models.DiscardedPlanStatus},
+		},
+	}, nil)
+	commitStatusUpdater := mocks.NewMockCommitStatusUpdater()
+	d := &events.ExpiredPlanDiscarder{
+		PendingPlanFinder:   &events.DefaultPendingPlanFinder{},
+		WorkingDir:          workingDir,
+		Locker:              locker,
+		Backend:             backend,
+		CommitStatusUpdater: commitStatusUpdater,
+	}
+
+	d.DiscardPull(logger, pull)
+
+	workingDir.VerifyWasCalledOnce().DeletePlan(Any[logging.SimpleLogging](), Eq(repo), Eq(pull), Eq("default"), Eq("dir2"), Eq(""))
+	backend.VerifyWasCalledOnce().UpdateProjectStatus(pull, "default", "dir2", models.DiscardedPlanStatus)
+	locker.VerifyWasCalledOnce().Unlock(key)
+	commitStatusUpdater.VerifyWasCalledOnce().UpdateCombinedCount(Any[logging.SimpleLogging](), Eq(repo), Eq(pull),
+		Eq(models.PendingCommitStatus), Eq(command.Plan), Eq(1), Eq(2))
+}
+
+// Test that a lock held by another pull request's expired plan is released,
+// and that a lock held by a plan that isn't expired is kept.
+func TestExpiredPlanDiscarder_DiscardLocked(t *testing.T) {
+	RegisterMockTestingT(t)
+	logger := logging.NewNoopLogger(t)
+	repoDir := t.TempDir()
+	Ok(t, os.MkdirAll(filepath.Join(repoDir, "dir1"), 0700))
+	planFile := filepath.Join(repoDir, "dir1", "default.tfplan")
+	Ok(t, os.WriteFile(planFile, nil, 0600))
+	Ok(t, runtime.RecordPlanTTL(planFile, time.Hour))
+
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{BaseRepo: repo, Num: 2}
+	lock := models.ProjectLock{Project: models.NewProject(repo.FullName, "dir1", ""), Workspace: "default", Pull: pull}
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.GetWorkingDir(repo, pull, "default")).ThenReturn(repoDir, nil)
+	locker := lockmocks.NewMockLocker()
+	When(locker.GetLock("owner/repo/dir1/default")).ThenReturn(&lock, nil)
+	backend := lockmocks.NewMockBackend()
+	d := &events.ExpiredPlanDiscarder{
+		PendingPlanFinder:   &events.DefaultPendingPlanFinder{},
+		WorkingDir:          workingDir,
+		Locker:              locker,
+		Backend:             backend,
+		CommitStatusUpdater: mocks.NewMockCommitStatusUpdater(),
+	}
+
+	Assert(t, !d.DiscardLocked(logger, lock), "exp plan that isn't expired to be kept")
+	locker.VerifyWasCalled(Never()).Unlock(Any[string]())
+
+	old := time.Now().Add(-2 * time.Hour)
+	Ok(t, os.Chtimes(planFile, old, old))
+	Assert(t, d.DiscardLocked(logger, lock), "exp expired plan to be discarded")
+	locker.VerifyWasCalledOnce().Unlock("owner/repo/dir1/default")
+	backend.VerifyWasCalledOnce().UpdateProjectStatus(pull, "default", "dir1", models.DiscardedPlanStatus)
+}
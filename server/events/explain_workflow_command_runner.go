@@ -0,0 +1,86 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+func NewExplainWorkflowCommandRunner(
+	vcsClient vcs.Client,
+	prjCmdBuilder ProjectPlanCommandBuilder,
+) *ExplainWorkflowCommandRunner {
+	return &ExplainWorkflowCommandRunner{
+		vcsClient:     vcsClient,
+		prjCmdBuilder: prjCmdBuilder,
+	}
+}
+
+// ExplainWorkflowCommandRunner comments which workflow the projects atlantis
+// plan would run on use, and each config that set it in the order they were
+// applied, so users can see why a project doesn't run the workflow they
+// expected, ex. because a server-side repo config overrides it.
+type ExplainWorkflowCommandRunner struct {
+	vcsClient     vcs.Client
+	prjCmdBuilder ProjectPlanCommandBuilder
+}
+
+func (e *ExplainWorkflowCommandRunner) Run(ctx *command.Context, cmd *CommentCommand) {
+	baseRepo := ctx.Pull.BaseRepo
+	pullNum := ctx.Pull.Num
+
+	var vcsMessage string
+	projectCmds, err := e.buildProjectCmds(ctx, cmd)
+	switch {
+	case err != nil:
+		ctx.Log.Err("failed to explain workflows: %s", err)
+		vcsMessage = fmt.Sprintf("Failed to explain workflows: %s", err)
+	case len(projectCmds) == 0:
+		vcsMessage = "No projects are modified in this pull request."
+	default:
+		vcsMessage = explainWorkflowComment(projectCmds)
+	}
+
+	if commentErr := e.vcsClient.CreateComment(ctx.Log, baseRepo, pullNum, vcsMessage, command.ExplainWorkflow.String()); commentErr != nil {
+		ctx.Log.Err("unable to comment: %s", commentErr)
+	}
+}
+
+// buildProjectCmds returns the projects plan would run on for cmd, with the
+// same -d, -w and -p flags.
+func (e *ExplainWorkflowCommandRunner) buildProjectCmds(ctx *command.Context, cmd *CommentCommand) ([]command.ProjectContext, error) {
+	planCmd := *cmd
+	planCmd.Name = command.Plan
+	projectCmds, err := e.prjCmdBuilder.BuildPlanCommands(ctx, &planCmd)
+	var tooManyErr *TooManyProjectsError
+	if errors.As(err, &tooManyErr) {
+		return tooManyErr.Projects, nil
+	}
+	return projectCmds, err
+}
+
+// explainWorkflowComment returns the comment explaining the workflows of
+// projectCmds.
+func explainWorkflowComment(projectCmds []command.ProjectContext) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Workflows of %d project(s), with the configs that set them in the order they're applied. The last one is used.\n", len(projectCmds))
+	for _, projectCmd := range projectCmds {
+		b.WriteString("\n#### ")
+		if projectCmd.ProjectName != "" {
+			fmt.Fprintf(&b, "project: `%s` ", projectCmd.ProjectName)
+		}
+		fmt.Fprintf(&b, "dir: `%s` workspace: `%s`\n", projectCmd.RepoRelDir, projectCmd.Workspace)
+		fmt.Fprintf(&b, "Workflow: **`%s`**\n\n", projectCmd.WorkflowName)
+		for i, step := range projectCmd.WorkflowTrace {
+			fmt.Fprintf(&b, "%d. %s", i+1, step)
+			if i == len(projectCmd.WorkflowTrace)-1 {
+				b.WriteString(" **(used)**")
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
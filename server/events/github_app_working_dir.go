@@ -21,6 +21,23 @@ type GithubAppWorkingDir struct {
 
 // Clone writes a fresh token for Github App authentication
 func (g *GithubAppWorkingDir) Clone(logger logging.SimpleLogging, headRepo models.Repo, p models.PullRequest, workspace string) (string, bool, error) {
+	headRepo, p = g.withoutCloneCredentials(headRepo, p)
+	return g.WorkingDir.Clone(logger, headRepo, p, workspace)
+}
+
+// CloneRef writes a fresh token for Github App authentication
+func (g *GithubAppWorkingDir) CloneRef(logger logging.SimpleLogging, headRepo models.Repo, p models.PullRequest, workspace string, ref string) (string, error) {
+	headRepo, p = g.withoutCloneCredentials(headRepo, p)
+	return g.WorkingDir.CloneRef(logger, headRepo, p, workspace, ref)
+}
+
+// CloneBase writes a fresh token for Github App authentication
+func (g *GithubAppWorkingDir) CloneBase(logger logging.SimpleLogging, p models.PullRequest) (string, error) {
+	_, p = g.withoutCloneCredentials(p.BaseRepo, p)
+	return g.WorkingDir.CloneBase(logger, p)
+}
+
+func (g *GithubAppWorkingDir) withoutCloneCredentials(headRepo models.Repo, p models.PullRequest) (models.Repo, models.PullRequest) {
 	baseRepo := &p.BaseRepo
 
 	// Realistically, this is a super brittle way of supporting clones using gh app installation tokens
@@ -36,5 +53,5 @@ func (g *GithubAppWorkingDir) Clone(logger logging.SimpleLogging, headRepo model
 	headRepo.CloneURL = strings.Replace(headRepo.CloneURL, "://:@", replacement, 1)
 	headRepo.SanitizedCloneURL = strings.Replace(baseRepo.SanitizedCloneURL, redactedReplacement, replacement, 1)
 
-	return g.WorkingDir.Clone(logger, headRepo, p, workspace)
+	return headRepo, p
 }
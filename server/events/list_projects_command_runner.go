@@ -0,0 +1,85 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+func NewListProjectsCommandRunner(
+	vcsClient vcs.Client,
+	prjCmdBuilder ProjectPlanCommandBuilder,
+	workingDir WorkingDir,
+) *ListProjectsCommandRunner {
+	return &ListProjectsCommandRunner{
+		vcsClient:     vcsClient,
+		prjCmdBuilder: prjCmdBuilder,
+		workingDir:    workingDir,
+	}
+}
+
+// ListProjectsCommandRunner comments the projects atlantis plan would run on
+// for a pull request, numbered so they can be planned and applied by index,
+// ex. atlantis plan -i 3. The list is saved so the indexes keep referring to
+// the same projects until the pull request changes.
+type ListProjectsCommandRunner struct {
+	vcsClient     vcs.Client
+	prjCmdBuilder ProjectPlanCommandBuilder
+	workingDir    WorkingDir
+}
+
+func (l *ListProjectsCommandRunner) Run(ctx *command.Context, _ *CommentCommand) {
+	baseRepo := ctx.Pull.BaseRepo
+	pullNum := ctx.Pull.Num
+
+	var vcsMessage string
+	index, err := l.listProjects(ctx)
+	switch {
+	case err != nil:
+		ctx.Log.Err("failed to list projects: %s", err)
+		vcsMessage = fmt.Sprintf("Failed to list projects: %s", err)
+	case len(index.Projects) == 0:
+		vcsMessage = "No projects are modified in this pull request."
+	default:
+		vcsMessage = listProjectsComment(index)
+	}
+
+	if commentErr := l.vcsClient.CreateComment(ctx.Log, baseRepo, pullNum, vcsMessage, command.ListProjects.String()); commentErr != nil {
+		ctx.Log.Err("unable to comment: %s", commentErr)
+	}
+}
+
+// listProjects returns and saves the index of the projects plan would run on.
+func (l *ListProjectsCommandRunner) listProjects(ctx *command.Context) (ProjectIndex, error) {
+	projectCmds, err := l.prjCmdBuilder.BuildPlanCommands(ctx, &CommentCommand{Name: command.Plan})
+	// Pull requests with more projects than max_projects_per_pr are listed
+	// so they can be targeted by index.
+	var tooManyErr *TooManyProjectsError
+	if errors.As(err, &tooManyErr) {
+		projectCmds, err = tooManyErr.Projects, nil
+	}
+	if err != nil {
+		return ProjectIndex{}, err
+	}
+	index := NewProjectIndex(ctx, projectCmds)
+	if len(index.Projects) == 0 {
+		return index, nil
+	}
+	return index, WriteProjectIndex(l.workingDir, ctx, index)
+}
+
+// listProjectsComment returns the comment listing the projects in index.
+func listProjectsComment(index ProjectIndex) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d project(s) in this pull request:\n\n", len(index.Projects))
+	b.WriteString("| Index | Project | Dir | Workspace |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for i, project := range index.Projects {
+		fmt.Fprintf(&b, "| %d | %s | `%s` | `%s` |\n", i+1, project.ProjectName, project.RepoRelDir, project.Workspace)
+	}
+	fmt.Fprintf(&b, "\nRun `atlantis plan -i INDEX` or `atlantis apply -i INDEX` to target a project. The indexes are for commit %s, run `atlantis list-projects` again after pushing.", index.HeadCommit)
+	return b.String()
+}
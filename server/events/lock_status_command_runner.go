@@ -0,0 +1,114 @@
+package events
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/core/locking"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+func NewLockStatusCommandRunner(
+	vcsClient vcs.Client,
+	locker locking.Locker,
+) *LockStatusCommandRunner {
+	return &LockStatusCommandRunner{
+		vcsClient: vcsClient,
+		locker:    locker,
+	}
+}
+
+// LockStatusCommandRunner comments who holds the locks of the projects
+// targeted by a lock-status comment so users can find out what's blocking
+// them without the UI. Without flags every lock in the repo is shown.
+type LockStatusCommandRunner struct {
+	vcsClient vcs.Client
+	locker    locking.Locker
+}
+
+func (l *LockStatusCommandRunner) Run(ctx *command.Context, cmd *CommentCommand) {
+	baseRepo := ctx.Pull.BaseRepo
+	pullNum := ctx.Pull.Num
+
+	var vcsMessage string
+	locks, err := l.repoLocks(baseRepo.FullName, cmd)
+	switch {
+	case err != nil:
+		ctx.Log.Err("failed to list locks: %s", err)
+		vcsMessage = fmt.Sprintf("Failed to list locks: %s", err)
+	case len(locks) == 0 && cmd.IsForSpecificProject():
+		vcsMessage = "No matching projects are locked."
+	case len(locks) == 0:
+		vcsMessage = "No projects in this repo are locked."
+	default:
+		vcsMessage = lockStatusComment(ctx.Pull, locks)
+	}
+
+	if commentErr := l.vcsClient.CreateComment(ctx.Log, baseRepo, pullNum, vcsMessage, command.LockStatus.String()); commentErr != nil {
+		ctx.Log.Err("unable to comment: %s", commentErr)
+	}
+}
+
+// repoLocks returns the locks of the repo repoFullName matching cmd, sorted by
+// directory and workspace.
+func (l *LockStatusCommandRunner) repoLocks(repoFullName string, cmd *CommentCommand) ([]models.ProjectLock, error) {
+	all, err := l.locker.List()
+	if err != nil {
+		return nil, err
+	}
+	var locks []models.ProjectLock
+	for _, lock := range all {
+		if lock.Project.RepoFullName == repoFullName && lockStatusMatches(cmd, lock) {
+			locks = append(locks, lock)
+		}
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		if locks[i].Project.Path != locks[j].Project.Path {
+			return locks[i].Project.Path < locks[j].Project.Path
+		}
+		return locks[i].Workspace < locks[j].Workspace
+	})
+	return locks, nil
+}
+
+// lockStatusMatches returns true if lock is targeted by cmd. Without flags
+// every lock is targeted. Otherwise -p selects locks by project name and
+// -d/-w by directory and workspace, defaulting like apply does.
+func lockStatusMatches(cmd *CommentCommand, lock models.ProjectLock) bool {
+	if !cmd.IsForSpecificProject() {
+		return true
+	}
+	if cmd.ProjectName != "" {
+		return lock.Project.ProjectName == cmd.ProjectName
+	}
+	repoRelDir := DefaultRepoRelDir
+	if cmd.RepoRelDir != "" {
+		repoRelDir = cmd.RepoRelDir
+	}
+	workspace := DefaultWorkspace
+	if cmd.Workspace != "" {
+		workspace = cmd.Workspace
+	}
+	return lock.Project.Path == repoRelDir && lock.Workspace == workspace
+}
+
+// lockStatusComment returns the comment listing locks for pull.
+func lockStatusComment(pull models.PullRequest, locks []models.ProjectLock) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d project(s) locked:\n\n", len(locks))
+	b.WriteString("| Project | Dir | Workspace | Pull Request | Locked By | Locked At |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, lock := range locks {
+		lockPull := fmt.Sprintf("[#%d](%s)", lock.Pull.Num, lock.Pull.URL)
+		if lock.Pull.Num == pull.Num {
+			lockPull += " (this pull request)"
+		}
+		fmt.Fprintf(&b, "| %s | `%s` | `%s` | %s | @%s | %s |\n",
+			lock.Project.ProjectName, lock.Project.Path, lock.Workspace, lockPull, lock.User.Username, lock.Time.UTC().Format("2006-01-02 15:04:05 MST"))
+	}
+	b.WriteString("\nLocks are released when their pull request is merged or closed, by running `atlantis unlock` on it or with the Atlantis UI.")
+	return b.String()
+}
@@ -73,6 +73,12 @@ type commonData struct {
 	ExecutableName            string
 	HideUnchangedPlanComments bool
 	VcsRequestType            string
+	// RunID is the ID of the run shown in the comment's footer so it can be
+	// correlated with logs.
+	RunID string
+	// ApplyReason is the reason an apply was given with --reason, shown in
+	// the comment's footer.
+	ApplyReason string
 }
 
 // errData is data about an error response.
@@ -110,6 +116,14 @@ type applyResultData struct {
 	NumApplyErrors    int
 }
 
+// applySuccessData is the data of a project's successful apply.
+type applySuccessData struct {
+	Output string
+	// State is the version of the state after the apply, or nil if it isn't
+	// known.
+	State *models.StateVersion
+}
+
 type planSuccessData struct {
 	models.PlanSuccess
 	PlanSummary              string
@@ -193,6 +207,8 @@ func (m *MarkdownRenderer) Render(ctx *command.Context, res command.Result, cmd
 		ExecutableName:            m.executableName,
 		HideUnchangedPlanComments: m.hideUnchangedPlanComments,
 		VcsRequestType:            vcsRequestType,
+		RunID:                     ctx.RunID,
+		ApplyReason:               ctx.ApplyReason,
 	}
 
 	templates := m.markdownTemplates
@@ -233,8 +249,8 @@ func (m *MarkdownRenderer) renderProjectResults(ctx *command.Context, results []
 			data := planSuccessData{
 				PlanSuccess:              *result.PlanSuccess,
 				PlanWasDeleted:           common.PlansDeleted,
-				DisableApply:             common.DisableApply,
-				DisableRepoLocking:       common.DisableRepoLocking,
+				DisableApply:             common.DisableApply || result.PlanOnly,
+				DisableRepoLocking:       common.DisableRepoLocking || result.PlanOnly,
 				EnableDiffMarkdownFormat: common.EnableDiffMarkdownFormat,
 				PlanStats:                result.PlanSuccess.Stats(),
 			}
@@ -287,10 +303,11 @@ func (m *MarkdownRenderer) renderProjectResults(ctx *command.Context, results []
 			}
 		} else if result.ApplySuccess != "" {
 			output := strings.TrimSpace(result.ApplySuccess)
+			applySuccess := applySuccessData{Output: output, State: result.ApplyState}
 			if m.shouldUseWrappedTmpl(vcsHost, result.ApplySuccess) {
-				resultData.Rendered = m.renderTemplateTrimSpace(templates.Lookup("applyWrappedSuccess"), struct{ Output string }{output})
+				resultData.Rendered = m.renderTemplateTrimSpace(templates.Lookup("applyWrappedSuccess"), applySuccess)
 			} else {
-				resultData.Rendered = m.renderTemplateTrimSpace(templates.Lookup("applyUnwrappedSuccess"), struct{ Output string }{output})
+				resultData.Rendered = m.renderTemplateTrimSpace(templates.Lookup("applyUnwrappedSuccess"), applySuccess)
 			}
 			numApplySuccesses++
 		} else if result.VersionSuccess != "" {
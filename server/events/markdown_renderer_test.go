@@ -97,6 +97,54 @@ func TestRenderErr(t *testing.T) {
 	}
 }
 
+func TestRenderRunID(t *testing.T) {
+	r := events.NewMarkdownRenderer(false, false, false, false, false, false, "", "atlantis", false)
+	logger := logging.NewNoopLogger(t).WithHistory()
+	logger.Info("log")
+	ctx := &command.Context{
+		Log: logger,
+		Pull: models.PullRequest{
+			BaseRepo: models.Repo{
+				VCSHost: models.VCSHost{
+					Type: models.Github,
+				},
+			},
+		},
+		RunID: "8c1b2d9e",
+	}
+	res := command.Result{
+		Error: errors.New("err"),
+	}
+
+	s := r.Render(ctx, res, &events.CommentCommand{Name: command.Plan})
+	Equals(t, normalize("**Plan Error**\n```\nerr\n```\n\n<sub>Run ID: $8c1b2d9e$</sub>"), normalize(s))
+
+	s = r.Render(ctx, res, &events.CommentCommand{Name: command.Plan, Verbose: true})
+	Equals(t, normalize("**Plan Error**\n```\nerr\n```\n<details><summary>Log</summary>\n<p>\n\n```\n[INFO] log\n```\n</p></details>\n\n<sub>Run ID: $8c1b2d9e$</sub>"), normalize(s))
+}
+
+func TestRenderApplyReason(t *testing.T) {
+	r := events.NewMarkdownRenderer(false, false, false, false, false, false, "", "atlantis", false)
+	ctx := &command.Context{
+		Log: logging.NewNoopLogger(t).WithHistory(),
+		Pull: models.PullRequest{
+			BaseRepo: models.Repo{
+				VCSHost: models.VCSHost{
+					Type: models.Github,
+				},
+			},
+		},
+		RunID:       "8c1b2d9e",
+		ApplyReason: "JIRA-123 emergency fix",
+	}
+	res := command.Result{
+		Error: errors.New("err"),
+	}
+
+	s := r.Render(ctx, res, &events.CommentCommand{Name: command.Apply})
+	Equals(t, normalize("**Apply Error**\n```\nerr\n```\n\n<sub>Reason: $JIRA-123 emergency fix$</sub>\n\n<sub>Run ID: $8c1b2d9e$</sub>"), normalize(s))
+}
+
 func TestRenderFailure(t *testing.T) {
 	cases := []struct {
 		Description string
@@ -286,6 +334,49 @@ $$$
   $$$
 :twisted_rightwards_arrows: Upstream was modified, a new merge was performed.
 
+---
+* :fast_forward: To **apply** all unapplied plans from this Pull Request, comment:
+  $$$shell
+  atlantis apply
+  $$$
+* :put_litter_in_its_place: To **delete** all plans and locks from this Pull Request, comment:
+  $$$shell
+  atlantis unlock
+  $$$
+`,
+		},
+		{
+			"single successful plan of a ref",
+			command.Plan,
+			"",
+			[]command.ProjectResult{
+				{
+					PlanSuccess: &models.PlanSuccess{
+						TerraformOutput: "terraform-output",
+						LockURL:         "lock-url",
+						RePlanCmd:       "atlantis plan -d path -w workspace",
+						ApplyCmd:        "atlantis apply -d path -w workspace",
+						PlanRef:         "origin/hotfix",
+					},
+					Workspace:  "workspace",
+					RepoRelDir: "path",
+				},
+			},
+			models.Github,
+			`
+Ran Plan for dir: $path$ workspace: $workspace$
+
+$$$diff
+terraform-output
+$$$
+
+* :warning: This plan was made from ref $origin/hotfix$ instead of the pull request's head, it can't be applied.
+* :put_litter_in_its_place: To **delete** this plan and lock, click [here](lock-url)
+* :repeat: To **plan** this project again, comment:
+  $$$shell
+  atlantis plan -d path -w workspace
+  $$$
+
 ---
 * :fast_forward: To **apply** all unapplied plans from this Pull Request, comment:
   $$$shell
@@ -394,6 +485,92 @@ $$$diff
 $$$
 
 
+#### Policy Approval Status:
+$$$
+policy set: policy1: requires: 1 approval(s), have: 0.
+policy set: policy2: passed.
+$$$
+* :heavy_check_mark: To **approve** this project, comment:
+  $$$shell
+  
+  $$$
+* :put_litter_in_its_place: To **delete** this plan and lock, click [here](lock-url)
+* :repeat: To re-run policies **plan** this project again by commenting:
+  $$$shell
+  atlantis plan -d path -w workspace
+  $$$
+
+---
+* :fast_forward: To **apply** all unapplied plans from this Pull Request, comment:
+  $$$shell
+  atlantis apply
+  $$$
+* :put_litter_in_its_place: To **delete** all plans and locks from this Pull Request, comment:
+  $$$shell
+  atlantis unlock
+  $$$
+`,
+		},
+		{
+			"single policy check with exemptions",
+			command.PolicyCheck,
+			"",
+			[]command.ProjectResult{
+				{
+					PolicyCheckResults: &models.PolicyCheckResults{
+						PolicySetResults: []models.PolicySetResult{
+							{
+								PolicySetName: "policy1",
+								// strings.Repeat require to get wrapped result
+								PolicyOutput: `FAIL - <redacted plan file> - main - WARNING: Null Resource creation is prohibited.
+
+2 tests, 1 passed, 0 warnings, 1 failure, 0 exceptions`,
+								Passed:       false,
+								ReqApprovals: 1,
+								Exemptions:   []string{"exception: null_resource.one", "exception: null_resource.two"},
+							},
+							{
+								PolicySetName: "policy2",
+								// strings.Repeat require to get wrapped result
+								PolicyOutput: "2 tests, 2 passed, 0 warnings, 0 failure, 0 exceptions",
+								Passed:       true,
+								ReqApprovals: 1,
+							},
+						},
+						LockURL:   "lock-url",
+						RePlanCmd: "atlantis plan -d path -w workspace",
+						ApplyCmd:  "atlantis apply -d path -w workspace",
+					},
+					Workspace:   "workspace",
+					RepoRelDir:  "path",
+					ProjectName: "projectname",
+				},
+			},
+			models.Github,
+			`
+Ran Policy Check for project: $projectname$ dir: $path$ workspace: $workspace$
+
+#### Policy Set: $policy1$
+$$$diff
+FAIL - <redacted plan file> - main - WARNING: Null Resource creation is prohibited.
+
+2 tests, 1 passed, 0 warnings, 1 failure, 0 exceptions
+$$$
+
+To exempt the failing resources from this policy set's policies, add:
+$$$
+exception: null_resource.one
+$$$
+$$$
+exception: null_resource.two
+$$$
+
+#### Policy Set: $policy2$
+$$$diff
+2 tests, 2 passed, 0 warnings, 0 failure, 0 exceptions
+$$$
+
+
 #### Policy Approval Status:
 $$$
 policy set: policy1: requires: 1 approval(s), have: 0.
@@ -583,6 +760,28 @@ Ran Apply for dir: $path$ workspace: $workspace$
 $$$diff
 success
 $$$
+`,
+		},
+		{
+			"single successful apply with state version",
+			command.Apply,
+			"",
+			[]command.ProjectResult{
+				{
+					ApplySuccess: "success",
+					ApplyState:   &models.StateVersion{Serial: 12, Lineage: "3d2a4b5c"},
+					Workspace:    "workspace",
+					RepoRelDir:   "path",
+				},
+			},
+			models.Github,
+			`
+Ran Apply for dir: $path$ workspace: $workspace$
+
+$$$diff
+success
+$$$
+State serial: $12$ lineage: $3d2a4b5c$
 `,
 		},
 		{
@@ -3832,3 +4031,64 @@ Ran Plan for 3 projects:
 		})
 	}
 }
+
+// Test that plan --summary renders a table of the plan's resource changes,
+// collapsed and truncated for large plans.
+func TestRenderProjectResults_PlanResourceSummary(t *testing.T) {
+	mr := events.NewMarkdownRenderer(false, false, false, false, false, false, "", "atlantis", false)
+	ctx := &command.Context{
+		Log: logging.NewNoopLogger(t).WithHistory(),
+		Pull: models.PullRequest{
+			BaseRepo: models.Repo{
+				VCSHost: models.VCSHost{
+					Type: models.Github,
+				},
+			},
+		},
+	}
+	render := func(summary *models.PlanResourceSummary) string {
+		res := command.Result{
+			ProjectResults: []command.ProjectResult{
+				{
+					RepoRelDir: ".",
+					Workspace:  "default",
+					PlanSuccess: &models.PlanSuccess{
+						TerraformOutput: "terraform-output",
+						LockURL:         "lock-url",
+						RePlanCmd:       "atlantis plan -d .",
+						ApplyCmd:        "atlantis apply -d .",
+						ResourceSummary: summary,
+					},
+				},
+			},
+		}
+		return mr.Render(ctx, res, &events.CommentCommand{Name: command.Plan})
+	}
+
+	rendered := render(&models.PlanResourceSummary{
+		Changes: []models.PlanResourceChange{
+			{Address: "aws_instance.web", Action: "create"},
+			{Address: `aws_route53_record.www["a|b"]`, Action: "replace"},
+			{Address: "aws_instance.old", Action: "delete"},
+		},
+	})
+	Assert(t, strings.Contains(rendered, "dir: `.` workspace: `default`\n\n**Resource changes:** 1 to create, 1 to replace, 1 to delete\n\n| Action | Resource |\n|--------|----------|\n| create | `aws_instance.web` |\n| replace | `aws_route53_record.www[\"a\\|b\"]` |\n| delete | `aws_instance.old` |\n\n```diff\nterraform-output\n```"), "unexpected table in %q", rendered)
+
+	rendered = render(&models.PlanResourceSummary{})
+	Assert(t, strings.Contains(rendered, "\n**Resource changes:** none\n\n```diff\n"), "exp no changes in %q", rendered)
+
+	rendered = render(&models.PlanResourceSummary{Unavailable: "the plan wasn't saved, ex. because it's a remote plan."})
+	Assert(t, strings.Contains(rendered, "\nThe table of resource changes isn't available: the plan wasn't saved, ex. because it's a remote plan.\n\n```diff\n"), "exp unavailable in %q", rendered)
+
+	var changes []models.PlanResourceChange
+	for i := 0; i < models.MaxPlanSummaryRows+5; i++ {
+		changes = append(changes, models.PlanResourceChange{Address: fmt.Sprintf("null_resource.r[%d]", i), Action: "update"})
+	}
+	rendered = render(&models.PlanResourceSummary{Changes: changes})
+	Assert(t, strings.Contains(rendered, "\n<details><summary>Resource changes: 105 to update</summary>\n\n| Action | Resource |\n"), "exp collapsed table in %q", rendered)
+	Assert(t, strings.Contains(rendered, "| update | `null_resource.r[99]` |\n\n...and 5 more, see the plan's output for all of them.\n</details>\n\n```diff\n"), "exp truncated table in %q", rendered)
+	Assert(t, !strings.Contains(rendered, "null_resource.r[100]"), "exp null_resource.r[100] to be truncated")
+
+	rendered = render(nil)
+	Assert(t, !strings.Contains(rendered, "Resource changes"), "exp no table without --summary in %q", rendered)
+}
@@ -57,6 +57,21 @@ func (mock *MockCommitStatusUpdater) UpdateCombinedCount(logger logging.SimpleLo
 	return ret0
 }
 
+func (mock *MockCommitStatusUpdater) UpdateCombinedPlanSummary(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, status models.CommitStatus, numSuccess int, numTotal int, stats models.PlanSuccessStats) error {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockCommitStatusUpdater().")
+	}
+	params := []pegomock.Param{logger, repo, pull, status, numSuccess, numTotal, stats}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("UpdateCombinedPlanSummary", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
 func (mock *MockCommitStatusUpdater) UpdatePostWorkflowHook(logger logging.SimpleLogging, pull models.PullRequest, status models.CommitStatus, hookDescription string, runtimeDescription string, url string) error {
 	if mock == nil {
 		panic("mock must not be nil. Use myMock := NewMockCommitStatusUpdater().")
@@ -218,6 +233,57 @@ func (c *MockCommitStatusUpdater_UpdateCombinedCount_OngoingVerification) GetAll
 	return
 }
 
+func (verifier *VerifierMockCommitStatusUpdater) UpdateCombinedPlanSummary(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, status models.CommitStatus, numSuccess int, numTotal int, stats models.PlanSuccessStats) *MockCommitStatusUpdater_UpdateCombinedPlanSummary_OngoingVerification {
+	params := []pegomock.Param{logger, repo, pull, status, numSuccess, numTotal, stats}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UpdateCombinedPlanSummary", params, verifier.timeout)
+	return &MockCommitStatusUpdater_UpdateCombinedPlanSummary_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockCommitStatusUpdater_UpdateCombinedPlanSummary_OngoingVerification struct {
+	mock              *MockCommitStatusUpdater
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockCommitStatusUpdater_UpdateCombinedPlanSummary_OngoingVerification) GetCapturedArguments() (logging.SimpleLogging, models.Repo, models.PullRequest, models.CommitStatus, int, int, models.PlanSuccessStats) {
+	logger, repo, pull, status, numSuccess, numTotal, stats := c.GetAllCapturedArguments()
+	return logger[len(logger)-1], repo[len(repo)-1], pull[len(pull)-1], status[len(status)-1], numSuccess[len(numSuccess)-1], numTotal[len(numTotal)-1], stats[len(stats)-1]
+}
+
+func (c *MockCommitStatusUpdater_UpdateCombinedPlanSummary_OngoingVerification) GetAllCapturedArguments() (_param0 []logging.SimpleLogging, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []models.CommitStatus, _param4 []int, _param5 []int, _param6 []models.PlanSuccessStats) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]logging.SimpleLogging, len(c.methodInvocations))
+		for u, param := range params[0] {
+			_param0[u] = param.(logging.SimpleLogging)
+		}
+		_param1 = make([]models.Repo, len(c.methodInvocations))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]models.PullRequest, len(c.methodInvocations))
+		for u, param := range params[2] {
+			_param2[u] = param.(models.PullRequest)
+		}
+		_param3 = make([]models.CommitStatus, len(c.methodInvocations))
+		for u, param := range params[3] {
+			_param3[u] = param.(models.CommitStatus)
+		}
+		_param4 = make([]int, len(c.methodInvocations))
+		for u, param := range params[4] {
+			_param4[u] = param.(int)
+		}
+		_param5 = make([]int, len(c.methodInvocations))
+		for u, param := range params[5] {
+			_param5[u] = param.(int)
+		}
+		_param6 = make([]models.PlanSuccessStats, len(c.methodInvocations))
+		for u, param := range params[6] {
+			_param6[u] = param.(models.PlanSuccessStats)
+		}
+	}
+	return
+}
+
 func (verifier *VerifierMockCommitStatusUpdater) UpdatePostWorkflowHook(logger logging.SimpleLogging, pull models.PullRequest, status models.CommitStatus, hookDescription string, runtimeDescription string, url string) *MockCommitStatusUpdater_UpdatePostWorkflowHook_OngoingVerification {
 	params := []pegomock.Param{logger, pull, status, hookDescription, runtimeDescription, url}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UpdatePostWorkflowHook", params, verifier.timeout)
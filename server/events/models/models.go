@@ -374,6 +374,12 @@ type PlanSuccess struct {
 	// branch we're merging into had been updated, and we had to merge again
 	// before planning
 	MergedAgain bool
+	// PlanRef is the git ref that was planned with plan --ref instead of the
+	// pull request's head. Such plans can't be applied.
+	PlanRef string
+	// ResourceSummary is the table of the resources the plan changes, set
+	// for plan --summary.
+	ResourceSummary *PlanResourceSummary
 }
 
 type PolicySetResult struct {
@@ -382,6 +388,9 @@ type PolicySetResult struct {
 	Passed        bool
 	ReqApprovals  int
 	CurApprovals  int
+	// Exemptions are snippets rendered from the policy set's exemption
+	// template that would exempt the failing resources from its policies.
+	Exemptions []string `json:",omitempty"`
 }
 
 // PolicySetApproval tracks the number of approvals a given policy set has.
@@ -469,6 +478,15 @@ type ImportSuccess struct {
 	RePlanCmd string
 }
 
+// StateVersion identifies the version of a Terraform state.
+type StateVersion struct {
+	// Serial is incremented every time the state changes.
+	Serial int64 `json:"serial"`
+	// Lineage is the unique ID the state was given when it was created. It's
+	// the same for every version of the state.
+	Lineage string `json:"lineage"`
+}
+
 // StateRmSuccess is the result of a successful state rm run.
 type StateRmSuccess struct {
 	// Output is the output from terraform state rm
@@ -549,6 +567,36 @@ func (p PullStatus) StatusCount(status ProjectPlanStatus) int {
 	return c
 }
 
+// ApplicableProjects returns the pull status without the plan-only projects
+// that can never be applied, for counting applies.
+func (p PullStatus) ApplicableProjects() PullStatus {
+	applicable := PullStatus{Pull: p.Pull}
+	for _, pr := range p.Projects {
+		if !pr.PlanOnly {
+			applicable.Projects = append(applicable.Projects, pr)
+		}
+	}
+	return applicable
+}
+
+// PlanStats returns the changes summed across the last successful plans of
+// every project.
+func (p PullStatus) PlanStats() PlanSuccessStats {
+	var total PlanSuccessStats
+	for _, project := range p.Projects {
+		if project.PlanStats == nil {
+			continue
+		}
+		total.Import += project.PlanStats.Import
+		total.Add += project.PlanStats.Add
+		total.Change += project.PlanStats.Change
+		total.Destroy += project.PlanStats.Destroy
+		total.Changes = total.Changes || project.PlanStats.Changes
+		total.ChangesOutside = total.ChangesOutside || project.PlanStats.ChangesOutside
+	}
+	return total
+}
+
 // ProjectStatus is the status of a specific project.
 type ProjectStatus struct {
 	Workspace   string
@@ -558,6 +606,12 @@ type ProjectStatus struct {
 	PolicyStatus []PolicySetStatus
 	// Status is the status of where this project is at in the planning cycle.
 	Status ProjectPlanStatus
+	// PlanStats are the changes in the project's last plan. It's nil if that
+	// plan failed.
+	PlanStats *PlanSuccessStats
+	// PlanOnly is true if the project sets lock: false so it can't be
+	// applied.
+	PlanOnly bool
 }
 
 // ProjectPlanStatus is the status of where this project is at in the planning
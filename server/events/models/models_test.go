@@ -637,6 +637,47 @@ func TestPullStatus_StatusCount(t *testing.T) {
 	Equals(t, 1, ps.StatusCount(models.PassedPolicyCheckStatus))
 }
 
+func TestPullStatus_ApplicableProjects(t *testing.T) {
+	ps := models.PullStatus{
+		Projects: []models.ProjectStatus{
+			{
+				ProjectName: "applied",
+				Status:      models.AppliedPlanStatus,
+			},
+			{
+				ProjectName: "plan-only",
+				Status:      models.PlannedPlanStatus,
+				PlanOnly:    true,
+			},
+		},
+	}
+
+	applicable := ps.ApplicableProjects()
+	Equals(t, 1, len(applicable.Projects))
+	Equals(t, "applied", applicable.Projects[0].ProjectName)
+	Equals(t, 0, applicable.StatusCount(models.PlannedPlanStatus))
+}
+
+func TestPullStatus_PlanStats(t *testing.T) {
+	ps := models.PullStatus{
+		Projects: []models.ProjectStatus{
+			{
+				Status:    models.PlannedPlanStatus,
+				PlanStats: &models.PlanSuccessStats{Add: 3, Change: 1, Changes: true},
+			},
+			{
+				Status:    models.PlannedPlanStatus,
+				PlanStats: &models.PlanSuccessStats{Import: 1, Add: 1, Destroy: 2, Changes: true},
+			},
+			{
+				Status: models.ErroredPlanStatus,
+			},
+		},
+	}
+
+	Equals(t, models.PlanSuccessStats{Import: 1, Add: 4, Change: 1, Destroy: 2, Changes: true}, ps.PlanStats())
+}
+
 func TestPlanSuccessStats(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// MaxPlanSummaryRows is the most resources the table of plan --summary
+	// lists. The others are only counted.
+	MaxPlanSummaryRows = 100
+	// planSummaryCollapseRows is how many resources the table of plan
+	// --summary lists before it's collapsed.
+	planSummaryCollapseRows = 10
+)
+
+// planSummaryActions are the actions of plan --summary in the order they're
+// counted.
+var planSummaryActions = []string{"create", "update", "replace", "delete", "import", "forget"}
+
+// PlanResourceChange is a resource a plan changes.
+type PlanResourceChange struct {
+	// Address is the resource's address, ex. aws_instance.web[0].
+	Address string
+	// Action is what the plan does to the resource, ex. create or replace.
+	Action string
+}
+
+// MarkdownAddress returns the address with the pipes that would end its cell
+// in a markdown table escaped.
+func (c PlanResourceChange) MarkdownAddress() string {
+	return strings.ReplaceAll(c.Address, "|", `\|`)
+}
+
+// PlanResourceSummary is the table of the resources a plan changes, for plan
+// --summary.
+type PlanResourceSummary struct {
+	// Changes are the resources the plan changes, in the plan's order.
+	Changes []PlanResourceChange
+	// Unavailable is why the plan's changes couldn't be read, ex. for remote
+	// plans. If set Changes is empty.
+	Unavailable string
+}
+
+// NewPlanResourceSummary returns the summary of the plan whose JSON, from
+// terraform show -json, is planJSON.
+func NewPlanResourceSummary(planJSON []byte) (*PlanResourceSummary, error) {
+	var plan struct {
+		ResourceChanges []struct {
+			Address string `json:"address"`
+			Change  struct {
+				Actions   []string        `json:"actions"`
+				Importing json.RawMessage `json:"importing"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}
+	if err := json.Unmarshal(planJSON, &plan); err != nil {
+		return nil, fmt.Errorf("parsing plan JSON: %s", err)
+	}
+	summary := &PlanResourceSummary{}
+	for _, c := range plan.ResourceChanges {
+		if action := planSummaryAction(c.Change.Actions, len(c.Change.Importing) > 0 && string(c.Change.Importing) != "null"); action != "" {
+			summary.Changes = append(summary.Changes, PlanResourceChange{Address: c.Address, Action: action})
+		}
+	}
+	return summary, nil
+}
+
+// planSummaryAction returns the action of a resource change with actions in
+// the plan JSON, or an empty string if it doesn't change the resource.
+func planSummaryAction(actions []string, importing bool) string {
+	switch strings.Join(actions, ",") {
+	case "no-op", "read":
+		if importing {
+			return "import"
+		}
+		return ""
+	case "delete,create", "create,delete":
+		return "replace"
+	default:
+		return strings.Join(actions, ", ")
+	}
+}
+
+// Counts returns how many resources the plan changes by action, ex.
+// "2 to create, 1 to delete".
+func (s PlanResourceSummary) Counts() string {
+	counts := make(map[string]int)
+	var others []string
+	for _, c := range s.Changes {
+		if counts[c.Action] == 0 && !slices.Contains(planSummaryActions, c.Action) {
+			others = append(others, c.Action)
+		}
+		counts[c.Action]++
+	}
+	var parts []string
+	for _, action := range append(append([]string(nil), planSummaryActions...), others...) {
+		if counts[action] > 0 {
+			parts = append(parts, fmt.Sprintf("%d to %s", counts[action], action))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Rows returns the changes listed in the table, at most MaxPlanSummaryRows.
+func (s PlanResourceSummary) Rows() []PlanResourceChange {
+	if len(s.Changes) > MaxPlanSummaryRows {
+		return s.Changes[:MaxPlanSummaryRows]
+	}
+	return s.Changes
+}
+
+// Hidden returns how many changes aren't listed in the table.
+func (s PlanResourceSummary) Hidden() int {
+	return len(s.Changes) - len(s.Rows())
+}
+
+// Collapsed returns true if the table lists so many resources that it's
+// collapsed.
+func (s PlanResourceSummary) Collapsed() bool {
+	return len(s.Changes) > planSummaryCollapseRows
+}
@@ -0,0 +1,46 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestNewPlanResourceSummary(t *testing.T) {
+	planJSON := `{
+  "resource_changes": [
+    {"address": "aws_instance.web", "change": {"actions": ["create"]}},
+    {"address": "aws_instance.db", "change": {"actions": ["update"]}},
+    {"address": "aws_instance.app[0]", "change": {"actions": ["delete", "create"]}},
+    {"address": "aws_instance.app[1]", "change": {"actions": ["create", "delete"]}},
+    {"address": "aws_instance.old", "change": {"actions": ["delete"]}},
+    {"address": "aws_s3_bucket.logs", "change": {"actions": ["no-op"], "importing": {"id": "logs"}}},
+    {"address": "aws_s3_bucket.data", "change": {"actions": ["no-op"]}},
+    {"address": "data.aws_ami.ubuntu", "change": {"actions": ["read"]}},
+    {"address": "aws_iam_role.legacy", "change": {"actions": ["forget"]}}
+  ]
+}`
+	summary, err := models.NewPlanResourceSummary([]byte(planJSON))
+	Ok(t, err)
+	Equals(t, []models.PlanResourceChange{
+		{Address: "aws_instance.web", Action: "create"},
+		{Address: "aws_instance.db", Action: "update"},
+		{Address: "aws_instance.app[0]", Action: "replace"},
+		{Address: "aws_instance.app[1]", Action: "replace"},
+		{Address: "aws_instance.old", Action: "delete"},
+		{Address: "aws_s3_bucket.logs", Action: "import"},
+		{Address: "aws_iam_role.legacy", Action: "forget"},
+	}, summary.Changes)
+	Equals(t, "1 to create, 1 to update, 2 to replace, 1 to delete, 1 to import, 1 to forget", summary.Counts())
+	Equals(t, 0, summary.Hidden())
+	Equals(t, false, summary.Collapsed())
+
+	summary, err = models.NewPlanResourceSummary([]byte(`{"resource_changes": [{"address": "aws_instance.web", "change": {"actions": ["no-op"]}}]}`))
+	Ok(t, err)
+	Equals(t, 0, len(summary.Changes))
+	Equals(t, "", summary.Counts())
+
+	_, err = models.NewPlanResourceSummary([]byte("not json"))
+	ErrContains(t, "parsing plan JSON", err)
+}
@@ -32,14 +32,39 @@ type PendingPlan struct {
 	// Workspace is the workspace this plan should execute in.
 	Workspace   string
 	ProjectName string
+	// Pinned is true if the plan is pinned by pin-plan so it isn't deleted
+	// by DeletePlans.
+	Pinned bool
+	// Expired is true if the plan is older than its project's plan_ttl.
+	Expired bool
 }
 
 // Find finds all pending plans in pullDir. pullDir should be the working
 // directory where Atlantis will operate on this pull request. It's one level
-// up from where Atlantis clones the repo for each workspace.
+// up from where Atlantis clones the repo for each workspace. Plans older
+// than their project's plan_ttl can't be applied so they aren't pending.
 func (p *DefaultPendingPlanFinder) Find(pullDir string) ([]PendingPlan, error) {
+	return p.find(pullDir, false)
+}
+
+// FindExpired finds the plans in pullDir that are older than their
+// project's plan_ttl.
+func (p *DefaultPendingPlanFinder) FindExpired(pullDir string) ([]PendingPlan, error) {
+	return p.find(pullDir, true)
+}
+
+func (p *DefaultPendingPlanFinder) find(pullDir string, expired bool) ([]PendingPlan, error) {
 	plans, _, err := p.findWithAbsPaths(pullDir)
-	return plans, err
+	if err != nil {
+		return nil, err
+	}
+	var found []PendingPlan
+	for _, plan := range plans {
+		if plan.Expired == expired {
+			found = append(found, plan)
+		}
+	}
+	return found, nil
 }
 
 func (p *DefaultPendingPlanFinder) findWithAbsPaths(pullDir string) ([]PendingPlan, []string, error) {
@@ -73,29 +98,39 @@ func (p *DefaultPendingPlanFinder) findWithAbsPaths(pullDir string) ([]PendingPl
 				if err != nil {
 					return nil, nil, err
 				}
+				absPath := filepath.Join(repoDir, file)
+				_, pinned := runtime.PlanPinnedBy(absPath)
 				plans = append(plans, PendingPlan{
 					RepoDir:     repoDir,
 					RepoRelDir:  filepath.Dir(file),
 					Workspace:   workspace,
 					ProjectName: projectName,
+					Pinned:      pinned,
+					Expired:     runtime.PlanExpired(absPath),
 				})
-				absPaths = append(absPaths, filepath.Join(repoDir, file))
+				absPaths = append(absPaths, absPath)
 			}
 		}
 	}
 	return plans, absPaths, nil
 }
 
-// deletePlans deletes all plans in pullDir.
+// deletePlans deletes all plans in pullDir except the pinned ones.
 func (p *DefaultPendingPlanFinder) DeletePlans(pullDir string) error {
-	_, absPaths, err := p.findWithAbsPaths(pullDir)
+	plans, absPaths, err := p.findWithAbsPaths(pullDir)
 	if err != nil {
 		return err
 	}
-	for _, path := range absPaths {
+	for i, path := range absPaths {
+		if plans[i].Pinned {
+			continue
+		}
 		if err := utils.RemoveIgnoreNonExistent(path); err != nil {
 			return errors.Wrapf(err, "delete plan at %s", path)
 		}
+		if err := runtime.RemovePlanTTL(path); err != nil {
+			return errors.Wrapf(err, "delete plan_ttl of plan at %s", path)
+		}
 	}
 	return nil
 }
@@ -7,7 +7,9 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/runatlantis/atlantis/server/core/runtime"
 	"github.com/runatlantis/atlantis/server/events"
 	. "github.com/runatlantis/atlantis/testing"
 )
@@ -313,6 +315,80 @@ func TestPendingPlanFinder_DeletePlans(t *testing.T) {
 	Equals(t, 0, len(foundPlans))
 }
 
+// Test that it finds pinned plans and doesn't delete them.
+func TestPendingPlanFinder_DeletePlansKeepsPinned(t *testing.T) {
+	tmp := DirStructure(t, map[string]interface{}{
+		"default": map[string]interface{}{
+			"dir1": map[string]interface{}{
+				"default.tfplan": nil,
+			},
+			"dir2": map[string]interface{}{
+				"default.tfplan": nil,
+			},
+		},
+	})
+	runCmd(t, filepath.Join(tmp, "default"), "git", "init")
+	pinnedPlan := filepath.Join(tmp, "default", "dir2", "default.tfplan")
+	Ok(t, runtime.PinPlan(pinnedPlan, "lkysow"))
+
+	pf := &events.DefaultPendingPlanFinder{}
+	Ok(t, pf.DeletePlans(tmp))
+
+	_, err := os.Stat(filepath.Join(tmp, "default", "dir1", "default.tfplan"))
+	ErrContains(t, "no such file or directory", err)
+	foundPlans, err := pf.Find(tmp)
+	Ok(t, err)
+	Equals(t, []events.PendingPlan{
+		{
+			RepoDir:    filepath.Join(tmp, "default"),
+			RepoRelDir: "dir2",
+			Workspace:  "default",
+			Pinned:     true,
+		},
+	}, foundPlans)
+}
+
+// Test that plans older than their plan_ttl aren't pending and are found by
+// FindExpired instead.
+func TestPendingPlanFinder_FindExpired(t *testing.T) {
+	tmp := DirStructure(t, map[string]interface{}{
+		"default": map[string]interface{}{
+			"dir1": map[string]interface{}{
+				"default.tfplan": nil,
+			},
+			"dir2": map[string]interface{}{
+				"default.tfplan": nil,
+			},
+		},
+	})
+	runCmd(t, filepath.Join(tmp, "default"), "git", "init")
+	expiredPlan := filepath.Join(tmp, "default", "dir2", "default.tfplan")
+	Ok(t, runtime.RecordPlanTTL(expiredPlan, time.Hour))
+	old := time.Now().Add(-2 * time.Hour)
+	Ok(t, os.Chtimes(expiredPlan, old, old))
+
+	pf := &events.DefaultPendingPlanFinder{}
+	pending, err := pf.Find(tmp)
+	Ok(t, err)
+	Equals(t, []events.PendingPlan{
+		{
+			RepoDir:    filepath.Join(tmp, "default"),
+			RepoRelDir: "dir1",
+			Workspace:  "default",
+		},
+	}, pending)
+	expired, err := pf.FindExpired(tmp)
+	Ok(t, err)
+	Equals(t, []events.PendingPlan{
+		{
+			RepoDir:    filepath.Join(tmp, "default"),
+			RepoRelDir: "dir2",
+			Workspace:  "default",
+			Expired:    true,
+		},
+	}, expired)
+}
+
 func runCmd(t *testing.T, dir string, name string, args ...string) string {
 	t.Helper()
 	cpCmd := exec.Command(name, args...)
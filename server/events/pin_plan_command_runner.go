@@ -0,0 +1,143 @@
+package events
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+// pinnedPlanIndicator marks pinned plans in comments.
+const pinnedPlanIndicator = "📌"
+
+func NewPinPlanCommandRunner(
+	vcsClient vcs.Client,
+	pendingPlanFinder PendingPlanFinder,
+	workingDir WorkingDir,
+	policyOwners valid.PolicyOwners,
+) *PinPlanCommandRunner {
+	return &PinPlanCommandRunner{
+		vcsClient:         vcsClient,
+		pendingPlanFinder: pendingPlanFinder,
+		workingDir:        workingDir,
+		policyOwners:      policyOwners,
+	}
+}
+
+// PinPlanCommandRunner runs pin-plan and unpin-plan comments. A pinned plan
+// isn't discarded by plan_ttl or when new commits are pushed until it's
+// applied, unpinned or replaced by running plan.
+type PinPlanCommandRunner struct {
+	vcsClient         vcs.Client
+	pendingPlanFinder PendingPlanFinder
+	workingDir        WorkingDir
+	// policyOwners are the owners of the server's policies. Along with the
+	// pull request's author they can pin and unpin plans.
+	policyOwners valid.PolicyOwners
+}
+
+func (r *PinPlanCommandRunner) Run(ctx *command.Context, cmd *CommentCommand) {
+	pin := cmd.Name == command.PinPlan
+
+	var vcsMessage string
+	if err := checkAuthorOrPolicyOwner(ctx, r.vcsClient, r.policyOwners, cmd.Name.String()); err != nil {
+		ctx.Log.Warn("denied %s of pull request %s#%d for user %q: %s", cmd.Name.String(), ctx.Pull.BaseRepo.FullName, ctx.Pull.Num, ctx.User.Username, err)
+		if pin {
+			vcsMessage = fmt.Sprintf("Not pinning plans: %s", err)
+		} else {
+			vcsMessage = fmt.Sprintf("Not unpinning plans: %s", err)
+		}
+	} else if pin {
+		vcsMessage = r.pinPlans(ctx, cmd)
+	} else {
+		vcsMessage = r.unpinPlans(ctx, cmd)
+	}
+
+	if commentErr := r.vcsClient.CreateComment(ctx.Log, ctx.Pull.BaseRepo, ctx.Pull.Num, vcsMessage, cmd.Name.String()); commentErr != nil {
+		ctx.Log.Err("unable to comment: %s", commentErr)
+	}
+}
+
+// pinPlans pins the pending plans matching cmd and returns the comment
+// listing them.
+func (r *PinPlanCommandRunner) pinPlans(ctx *command.Context, cmd *CommentCommand) string {
+	plans, err := r.findPlans(ctx, cmd)
+	if err != nil {
+		ctx.Log.Err("failed to pin plans: %s", err)
+		return fmt.Sprintf("Failed to pin plans: %s", err)
+	}
+	var pinned []string
+	for _, plan := range plans {
+		if err := runtime.PinPlan(pendingPlanFile(plan), ctx.User.Username); err != nil {
+			ctx.Log.Err("failed to pin plans: %s", err)
+			return fmt.Sprintf("Failed to pin the plan for %s: %s", pendingPlanDescription(plan), err)
+		}
+		ctx.Log.Info("user %q pinned the plan for %s", ctx.User.Username, pendingPlanDescription(plan))
+		pinned = append(pinned, fmt.Sprintf("- %s %s", pinnedPlanIndicator, pendingPlanDescription(plan)))
+	}
+	if len(pinned) == 0 {
+		return "No plans found to pin"
+	}
+	return fmt.Sprintf("Pinned %d plan(s), they won't be discarded by plan_ttl or new commits until they're applied or unpinned with `atlantis unpin-plan`:\n\n%s",
+		len(pinned), strings.Join(pinned, "\n"))
+}
+
+// unpinPlans unpins the pinned plans matching cmd and returns the comment
+// listing them.
+func (r *PinPlanCommandRunner) unpinPlans(ctx *command.Context, cmd *CommentCommand) string {
+	plans, err := r.findPlans(ctx, cmd)
+	if err != nil {
+		ctx.Log.Err("failed to unpin plans: %s", err)
+		return fmt.Sprintf("Failed to unpin plans: %s", err)
+	}
+	var unpinned []string
+	for _, plan := range plans {
+		if !plan.Pinned {
+			continue
+		}
+		if err := runtime.UnpinPlan(pendingPlanFile(plan)); err != nil {
+			ctx.Log.Err("failed to unpin plans: %s", err)
+			return fmt.Sprintf("Failed to unpin the plan for %s: %s", pendingPlanDescription(plan), err)
+		}
+		ctx.Log.Info("user %q unpinned the plan for %s", ctx.User.Username, pendingPlanDescription(plan))
+		unpinned = append(unpinned, "- "+pendingPlanDescription(plan))
+	}
+	if len(unpinned) == 0 {
+		return "No pinned plans found to unpin"
+	}
+	return fmt.Sprintf("Unpinned %d plan(s), they'll be discarded by plan_ttl and new commits again:\n\n%s",
+		len(unpinned), strings.Join(unpinned, "\n"))
+}
+
+// findPlans returns the pending plans matching cmd.
+func (r *PinPlanCommandRunner) findPlans(ctx *command.Context, cmd *CommentCommand) ([]PendingPlan, error) {
+	pullDir, err := r.workingDir.GetPullDir(ctx.Pull.BaseRepo, ctx.Pull)
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	plans, err := r.pendingPlanFinder.Find(pullDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding plans")
+	}
+	var matching []PendingPlan
+	for _, plan := range plans {
+		if pendingPlanMatches(cmd, plan) {
+			matching = append(matching, plan)
+		}
+	}
+	return matching, nil
+}
+
+// pendingPlanFile returns the path to the planfile of plan.
+func pendingPlanFile(plan PendingPlan) string {
+	return filepath.Join(plan.RepoDir, plan.RepoRelDir, runtime.GetPlanFilename(plan.Workspace, plan.ProjectName))
+}
@@ -1,7 +1,13 @@
 package events
 
 import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/core/locking"
+	"github.com/runatlantis/atlantis/server/core/runtime"
 	"github.com/runatlantis/atlantis/server/events/command"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs"
@@ -26,6 +32,7 @@ func NewPlanCommandRunner(
 	lockingLocker locking.Locker,
 	discardApprovalOnPlan bool,
 	pullReqStatusFetcher vcs.PullReqStatusFetcher,
+	vcsStatusPlanSummary bool,
 ) *PlanCommandRunner {
 	return &PlanCommandRunner{
 		silenceVCSStatusNoPlans:    silenceVCSStatusNoPlans,
@@ -46,6 +53,7 @@ func NewPlanCommandRunner(
 		lockingLocker:              lockingLocker,
 		DiscardApprovalOnPlan:      discardApprovalOnPlan,
 		pullReqStatusFetcher:       pullReqStatusFetcher,
+		vcsStatusPlanSummary:       vcsStatusPlanSummary,
 	}
 }
 
@@ -76,12 +84,24 @@ type PlanCommandRunner struct {
 	// a plan.
 	DiscardApprovalOnPlan bool
 	pullReqStatusFetcher  vcs.PullReqStatusFetcher
+	// vcsStatusPlanSummary is whether the combined plan commit status should
+	// include the resource changes summed across projects.
+	vcsStatusPlanSummary bool
 }
 
 func (p *PlanCommandRunner) runAutoplan(ctx *command.Context) {
 	baseRepo := ctx.Pull.BaseRepo
 	pull := ctx.Pull
 
+	var err error
+	ctx.PullRequestStatus, err = p.pullReqStatusFetcher.FetchPullStatus(ctx.Log, pull)
+	if err != nil {
+		// On error we continue the request with mergeable assumed false.
+		// We want to continue because not all plans will need this status,
+		// only if they rely on the plan_requirements.
+		ctx.Log.Warn("unable to get pull request status: %s. Continuing with mergeable and approved assumed false", err)
+	}
+
 	projectCmds, err := p.prjCmdBuilder.BuildAutoplanCommands(ctx)
 	if err != nil {
 		if statusErr := p.commitStatusUpdater.UpdateCombined(ctx.Log, baseRepo, pull, models.FailedCommitStatus, command.Plan); statusErr != nil {
@@ -91,6 +111,7 @@ func (p *PlanCommandRunner) runAutoplan(ctx *command.Context) {
 		return
 	}
 
+	projectCmds = p.skipPinnedPlans(ctx, projectCmds)
 	projectCmds, policyCheckCmds := p.partitionProjectCmds(ctx, projectCmds)
 
 	if len(projectCmds) == 0 {
@@ -126,13 +147,24 @@ func (p *PlanCommandRunner) runAutoplan(ctx *command.Context) {
 		ctx.Log.Err("deleting locks: %s", err)
 	}
 
+	planFunc := p.prjCmdRunner.Plan
+	if ctx.Superseded != nil {
+		planFunc = supersedablePlan(ctx, planFunc)
+	}
+
 	// Only run commands in parallel if enabled
 	var result command.Result
 	if p.isParallelEnabled(projectCmds) {
 		ctx.Log.Info("Running plans in parallel")
-		result = runProjectCmdsParallelGroups(ctx, projectCmds, p.prjCmdRunner.Plan, p.parallelPoolSize)
+		result = runProjectCmdsParallelGroups(ctx, projectCmds, planFunc, p.parallelPoolSize)
 	} else {
-		result = runProjectCmds(projectCmds, p.prjCmdRunner.Plan)
+		result = runProjectCmds(projectCmds, planFunc)
+	}
+
+	// A newer autoplan replaces this one's plans, comment and statuses.
+	if ctx.Superseded != nil && ctx.Superseded() {
+		ctx.Log.Info("autoplan was superseded by a newer commit, discarding its results")
+		return
 	}
 
 	if p.autoMerger.automergeEnabled(projectCmds) && result.HasErrors() {
@@ -323,6 +355,7 @@ func (p *PlanCommandRunner) updateCommitStatus(ctx *command.Context, pullStatus
 			status = models.FailedCommitStatus
 		}
 	} else if commandName == command.Apply {
+		pullStatus = pullStatus.ApplicableProjects()
 		numSuccess = pullStatus.StatusCount(models.AppliedPlanStatus) + pullStatus.StatusCount(models.PlannedNoChangesPlanStatus)
 		numErrored = pullStatus.StatusCount(models.ErroredApplyStatus)
 
@@ -334,6 +367,21 @@ func (p *PlanCommandRunner) updateCommitStatus(ctx *command.Context, pullStatus
 		}
 	}
 
+	if commandName == command.Plan && p.vcsStatusPlanSummary {
+		if err := p.commitStatusUpdater.UpdateCombinedPlanSummary(
+			ctx.Log,
+			ctx.Pull.BaseRepo,
+			ctx.Pull,
+			status,
+			numSuccess,
+			len(pullStatus.Projects),
+			pullStatus.PlanStats(),
+		); err != nil {
+			ctx.Log.Warn("unable to update commit status: %s", err)
+		}
+		return
+	}
+
 	if err := p.commitStatusUpdater.UpdateCombinedCount(
 		ctx.Log,
 		ctx.Pull.BaseRepo,
@@ -358,6 +406,37 @@ func (p *PlanCommandRunner) deletePlans(ctx *command.Context) {
 	}
 }
 
+// skipPinnedPlans returns cmds without the projects whose plans are pinned so
+// autoplan doesn't replace them, and comments which pinned plans were kept.
+func (p *PlanCommandRunner) skipPinnedPlans(ctx *command.Context, cmds []command.ProjectContext) []command.ProjectContext {
+	var unpinnedCmds []command.ProjectContext
+	var kept []string
+	for _, cmd := range cmds {
+		repoDir, err := p.workingDir.GetWorkingDir(cmd.Pull.BaseRepo, cmd.Pull, cmd.Workspace)
+		if err != nil {
+			unpinnedCmds = append(unpinnedCmds, cmd)
+			continue
+		}
+		planFile := filepath.Join(repoDir, cmd.RepoRelDir, runtime.GetPlanFilename(cmd.Workspace, cmd.ProjectName))
+		user, pinned := runtime.PlanPinnedBy(planFile)
+		if !pinned {
+			unpinnedCmds = append(unpinnedCmds, cmd)
+			continue
+		}
+		description := pendingPlanDescription(PendingPlan{RepoRelDir: cmd.RepoRelDir, Workspace: cmd.Workspace, ProjectName: cmd.ProjectName})
+		ctx.Log.Info("not planning %s again, its plan is pinned by %s", description, user)
+		kept = append(kept, fmt.Sprintf("- %s pinned by @%s", description, user))
+	}
+	if len(kept) > 0 {
+		comment := fmt.Sprintf("%s Kept %d pinned plan(s) instead of planning them again:\n\n%s\n\nRun `atlantis unpin-plan` so they're planned again, or `atlantis plan` to replace them now.",
+			pinnedPlanIndicator, len(kept), strings.Join(kept, "\n"))
+		if err := p.vcsClient.CreateComment(ctx.Log, ctx.Pull.BaseRepo, ctx.Pull.Num, comment, command.Plan.String()); err != nil {
+			ctx.Log.Err("unable to comment: %s", err)
+		}
+	}
+	return unpinnedCmds
+}
+
 func (p *PlanCommandRunner) partitionProjectCmds(
 	ctx *command.Context,
 	cmds []command.ProjectContext,
@@ -381,3 +460,20 @@ func (p *PlanCommandRunner) partitionProjectCmds(
 func (p *PlanCommandRunner) isParallelEnabled(projectCmds []command.ProjectContext) bool {
 	return len(projectCmds) > 0 && projectCmds[0].ParallelPlanEnabled
 }
+
+// supersedablePlan wraps plan so that projects that haven't started planning
+// are skipped once ctx's autoplan is superseded by a newer one.
+func supersedablePlan(ctx *command.Context, plan prjCmdRunnerFunc) prjCmdRunnerFunc {
+	return func(prjCtx command.ProjectContext) command.ProjectResult {
+		if ctx.Superseded() {
+			return command.ProjectResult{
+				Command:     command.Plan,
+				RepoRelDir:  prjCtx.RepoRelDir,
+				Workspace:   prjCtx.Workspace,
+				ProjectName: prjCtx.ProjectName,
+				Error:       errors.New("autoplan was superseded by a newer commit"),
+			}
+		}
+		return plan(prjCtx)
+	}
+}
@@ -0,0 +1,83 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/core/runtime"
+	"github.com/runatlantis/atlantis/server/events/command"
+)
+
+// planJSON is the part of a plan's JSON, as written by terraform show -json,
+// that Atlantis reads.
+type planJSON struct {
+	ResourceChanges []planResourceChange `json:"resource_changes"`
+}
+
+// planResourceChange is a resource a plan changes.
+type planResourceChange struct {
+	Address string `json:"address"`
+	Change  struct {
+		Actions []string        `json:"actions"`
+		After   json.RawMessage `json:"after"`
+	} `json:"change"`
+}
+
+// readPlanJSON returns the JSON of the project's plan in projectDir, as
+// written by terraform show -json to the project's show file. If it doesn't
+// exist or is older than the plan, showStepRunner is run to write it. If
+// plans are encrypted at rest, planEncryptor decrypts them, so the caller
+// must hold the project's working dir lock.
+func readPlanJSON(showStepRunner StepRunner, planEncryptor *runtime.PlanEncryptor, projectDir string, ctx command.ProjectContext) (planJSON, error) {
+	showFile := filepath.Join(projectDir, ctx.GetShowResultFileName())
+	planFile := filepath.Join(projectDir, runtime.GetPlanFilename(ctx.Workspace, ctx.ProjectName))
+	if showFileStale(showFile, planFile) {
+		if showStepRunner == nil {
+			return planJSON{}, fmt.Errorf("plan JSON %q doesn't exist", showFile)
+		}
+		ctx.Log.Debug("writing plan JSON to %q", showFile)
+		if err := showPlan(showStepRunner, planEncryptor, planFile, showFile, projectDir, ctx); err != nil {
+			return planJSON{}, err
+		}
+	}
+	content, err := planEncryptor.ReadFile(showFile)
+	if err != nil {
+		return planJSON{}, errors.Wrap(err, "reading plan JSON")
+	}
+	var plan planJSON
+	if err := json.Unmarshal(content, &plan); err != nil {
+		return planJSON{}, errors.Wrapf(err, "parsing plan JSON %q", showFile)
+	}
+	return plan, nil
+}
+
+// showPlan runs showStepRunner to write the JSON of planFile to showFile. If
+// plans are encrypted at rest, the plan is decrypted while it runs and both
+// are encrypted afterwards.
+func showPlan(showStepRunner StepRunner, planEncryptor *runtime.PlanEncryptor, planFile string, showFile string, projectDir string, ctx command.ProjectContext) error {
+	if err := planEncryptor.Decrypt(planFile); err != nil {
+		return errors.Wrap(err, "decrypting plan")
+	}
+	_, err := showStepRunner.Run(ctx, nil, projectDir, map[string]string{})
+	if encryptErr := planEncryptor.Encrypt(planFile, showFile); encryptErr != nil && err == nil {
+		return errors.Wrap(encryptErr, "encrypting plan")
+	}
+	return err
+}
+
+// showFileStale returns true if showFile doesn't exist or was written before
+// planFile, ex. by a policy check of an earlier plan.
+func showFileStale(showFile string, planFile string) bool {
+	showInfo, err := os.Stat(showFile)
+	if err != nil {
+		return true
+	}
+	planInfo, err := os.Stat(planFile)
+	if err != nil {
+		return false
+	}
+	return showInfo.ModTime().Before(planInfo.ModTime())
+}
@@ -0,0 +1,54 @@
+package events
+
+import (
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+func NewPolicyCheckCommentCommandRunner(
+	commitStatusUpdater CommitStatusUpdater,
+	prjCommandBuilder ProjectPolicyCheckCommandBuilder,
+	policyCheckCommandRunner *PolicyCheckCommandRunner,
+	pullUpdater *PullUpdater,
+	policyChecksEnabled bool,
+) *PolicyCheckCommentCommandRunner {
+	return &PolicyCheckCommentCommandRunner{
+		commitStatusUpdater:      commitStatusUpdater,
+		prjCmdBuilder:            prjCommandBuilder,
+		policyCheckCommandRunner: policyCheckCommandRunner,
+		pullUpdater:              pullUpdater,
+		policyChecksEnabled:      policyChecksEnabled,
+	}
+}
+
+// PolicyCheckCommentCommandRunner checks policies again when policy_check is
+// commented, ex. after the policies changed. Policies are checked against the
+// plans already in the working dir so nothing is planned again.
+type PolicyCheckCommentCommandRunner struct {
+	commitStatusUpdater      CommitStatusUpdater
+	prjCmdBuilder            ProjectPolicyCheckCommandBuilder
+	policyCheckCommandRunner *PolicyCheckCommandRunner
+	pullUpdater              *PullUpdater
+	// policyChecksEnabled is whether policy checks are enabled on this server.
+	policyChecksEnabled bool
+}
+
+func (p *PolicyCheckCommentCommandRunner) Run(ctx *command.Context, cmd *CommentCommand) {
+	if !p.policyChecksEnabled {
+		p.pullUpdater.updatePull(ctx, cmd, command.Result{
+			Failure: "Policy checks aren't enabled on this Atlantis server, they're enabled with --enable-policy-checks.",
+		})
+		return
+	}
+
+	projectCmds, err := p.prjCmdBuilder.BuildPolicyCheckCommands(ctx, cmd)
+	if err != nil {
+		if statusErr := p.commitStatusUpdater.UpdateCombined(ctx.Log, ctx.Pull.BaseRepo, ctx.Pull, models.FailedCommitStatus, command.PolicyCheck); statusErr != nil {
+			ctx.Log.Warn("unable to update commit status: %s", statusErr)
+		}
+		p.pullUpdater.updatePull(ctx, cmd, command.Result{Error: err})
+		return
+	}
+
+	p.policyCheckCommandRunner.Run(ctx, projectCmds)
+}
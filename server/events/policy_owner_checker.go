@@ -0,0 +1,32 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+// checkAuthorOrPolicyOwner returns an error if the user commenting isn't the
+// pull request's author or one of policyOwners. cmd is the command shown in
+// the error, ex. unlock.
+func checkAuthorOrPolicyOwner(ctx *command.Context, vcsClient vcs.Client, policyOwners valid.PolicyOwners, cmd string) error {
+	if strings.EqualFold(ctx.User.Username, ctx.Pull.Author) {
+		return nil
+	}
+	var teams []string
+	// Only query the user's team membership if any teams are owners.
+	if len(policyOwners.Teams) > 0 {
+		var err error
+		teams, err = vcsClient.GetTeamNamesForUser(ctx.Pull.BaseRepo, ctx.User)
+		if err != nil {
+			return fmt.Errorf("unable to get team membership of %s: %s", ctx.User.Username, err)
+		}
+	}
+	if policyOwners.IsOwner(ctx.User.Username, teams) {
+		return nil
+	}
+	return fmt.Errorf("only the pull request's author or a policy owner can run `%s`", cmd)
+}
@@ -10,6 +10,7 @@ import (
 	tally "github.com/uber-go/tally/v4"
 
 	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/core/runtime"
 	"github.com/runatlantis/atlantis/server/core/terraform"
 	"github.com/runatlantis/atlantis/server/logging"
 	"github.com/runatlantis/atlantis/server/metrics"
@@ -170,6 +171,13 @@ type ProjectApprovePoliciesCommandBuilder interface {
 	BuildApprovePoliciesCommands(ctx *command.Context, comment *CommentCommand) ([]command.ProjectContext, error)
 }
 
+type ProjectPolicyCheckCommandBuilder interface {
+	// BuildPolicyCheckCommands builds project PolicyCheck commands for this ctx
+	// and comment that check policies against the plans already in the
+	// working dir.
+	BuildPolicyCheckCommands(ctx *command.Context, comment *CommentCommand) ([]command.ProjectContext, error)
+}
+
 type ProjectVersionCommandBuilder interface {
 	// BuildVersionCommands builds project Version commands for this ctx and comment. If
 	// comment doesn't specify one project then there may be multiple commands
@@ -198,6 +206,7 @@ type ProjectCommandBuilder interface {
 	ProjectPlanCommandBuilder
 	ProjectApplyCommandBuilder
 	ProjectApprovePoliciesCommandBuilder
+	ProjectPolicyCheckCommandBuilder
 	ProjectVersionCommandBuilder
 	ProjectImportCommandBuilder
 	ProjectStateCommandBuilder
@@ -265,25 +274,62 @@ func (p *DefaultProjectCommandBuilder) BuildAutoplanCommands(ctx *command.Contex
 		}
 		autoplanEnabled = append(autoplanEnabled, projCtx)
 	}
+	if err := p.checkMaxProjects(ctx, command.Plan, autoplanEnabled); err != nil {
+		return nil, err
+	}
 	return autoplanEnabled, nil
 }
 
 // See ProjectCommandBuilder.BuildPlanCommands.
 func (p *DefaultProjectCommandBuilder) BuildPlanCommands(ctx *command.Context, cmd *CommentCommand) ([]command.ProjectContext, error) {
+	if cmd.Ref != "" && !p.GlobalCfg.RepoAllowsPlanRef(ctx.Pull.BaseRepo.ID(), cmd.Ref) {
+		return nil, fmt.Errorf("planning ref %q is not allowed, it must match allowed_plan_refs in the server-side repo config", cmd.Ref)
+	}
+	// The projects the pull request modifies aren't the ones the ref changes,
+	// so the projects to plan must be given.
+	if cmd.Ref != "" && !cmd.IsForSpecificProject() {
+		return nil, fmt.Errorf("plan --ref must be run for a specific project with -d or -p")
+	}
+	if err := p.resolveProjectIndex(ctx, cmd); err != nil {
+		return nil, err
+	}
+
+	var pcc []command.ProjectContext
+	var err error
 	if !cmd.IsForSpecificProject() {
 		ctx.Log.Debug("Building plan command for all affected projects")
-		return p.buildAllCommandsByCfg(ctx, cmd.CommandName(), cmd.SubName, cmd.Flags, cmd.Verbose)
+		pcc, err = p.buildAllCommandsByCfg(ctx, cmd.CommandName(), cmd.SubName, cmd.Flags, cmd.Verbose)
+	} else if len(cmd.Workspaces) == 0 {
+		ctx.Log.Debug("Building plan command for specific project with directory: '%v', workspace: '%v', project: '%v'",
+			cmd.RepoRelDir, cmd.Workspace, cmd.ProjectName)
+		pcc, err = p.buildProjectPlanCommand(ctx, cmd)
+	} else {
+		pcc, err = p.buildMultiWorkspacePlanCommands(ctx, cmd)
+	}
+	for i := range pcc {
+		pcc[i].PlanRef = cmd.Ref
+	}
+	if err == nil && !cmd.IsForSpecificProject() {
+		err = p.checkMaxProjects(ctx, command.Plan, pcc)
 	}
-	ctx.Log.Debug("Building plan command for specific project with directory: '%v', workspace: '%v', project: '%v'",
-		cmd.RepoRelDir, cmd.Workspace, cmd.ProjectName)
-	pcc, err := p.buildProjectPlanCommand(ctx, cmd)
 	return pcc, err
 }
 
 // See ProjectCommandBuilder.BuildApplyCommands.
 func (p *DefaultProjectCommandBuilder) BuildApplyCommands(ctx *command.Context, cmd *CommentCommand) ([]command.ProjectContext, error) {
+	// Applies through the API aren't commented so they don't need a reason.
+	if cmd.Reason == "" && !ctx.API && p.GlobalCfg.RepoRequireApplyReason(ctx.Pull.BaseRepo.ID()) {
+		return nil, errors.New("a reason is required to apply in this repo, set by require_apply_reason. Run apply again with --reason, ex. apply --reason \"JIRA-123 emergency fix\"")
+	}
+	if err := p.resolveProjectIndex(ctx, cmd); err != nil {
+		return nil, err
+	}
 	if !cmd.IsForSpecificProject() {
-		return p.buildAllProjectCommandsByPlan(ctx, cmd)
+		pac, err := p.buildAllProjectCommandsByPlan(ctx, cmd)
+		if err != nil {
+			return nil, err
+		}
+		return pac, p.checkMaxProjects(ctx, command.Apply, pac)
 	}
 	pac, err := p.buildProjectCommand(ctx, cmd)
 	return pac, err
@@ -297,6 +343,48 @@ func (p *DefaultProjectCommandBuilder) BuildApprovePoliciesCommands(ctx *command
 	return pac, err
 }
 
+// See ProjectPolicyCheckCommandBuilder.BuildPolicyCheckCommands.
+func (p *DefaultProjectCommandBuilder) BuildPolicyCheckCommands(ctx *command.Context, cmd *CommentCommand) ([]command.ProjectContext, error) {
+	var pac []command.ProjectContext
+	var err error
+	if !cmd.IsForSpecificProject() {
+		pac, err = p.buildAllProjectCommandsByPlan(ctx, cmd)
+	} else {
+		pac, err = p.buildProjectCommand(ctx, cmd)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(pac) == 0 && !cmd.IsForSpecificProject() {
+		return nil, errors.New("no plans of projects with policy checks enabled found, run plan first")
+	}
+	for _, prjCtx := range pac {
+		if err := p.checkPlanExists(ctx, prjCtx); err != nil {
+			return nil, err
+		}
+	}
+	return pac, nil
+}
+
+// checkPlanExists returns an error if prjCtx's project hasn't been planned, so
+// policies would be checked without a plan.
+func (p *DefaultProjectCommandBuilder) checkPlanExists(ctx *command.Context, prjCtx command.ProjectContext) error {
+	noPlanErr := fmt.Errorf("no plan found for dir %q workspace %q, run plan first", prjCtx.RepoRelDir, prjCtx.Workspace)
+	repoDir, err := p.WorkingDir.GetWorkingDir(ctx.Pull.BaseRepo, ctx.Pull, prjCtx.Workspace)
+	if os.IsNotExist(errors.Cause(err)) {
+		return noPlanErr
+	} else if err != nil {
+		return err
+	}
+	planFile := filepath.Join(repoDir, prjCtx.RepoRelDir, runtime.GetPlanFilename(prjCtx.Workspace, prjCtx.ProjectName))
+	if _, err := os.Stat(planFile); os.IsNotExist(err) {
+		return noPlanErr
+	} else if err != nil {
+		return errors.Wrapf(err, "checking for plan file %q", planFile)
+	}
+	return nil
+}
+
 func (p *DefaultProjectCommandBuilder) BuildVersionCommands(ctx *command.Context, cmd *CommentCommand) ([]command.ProjectContext, error) {
 	if !cmd.IsForSpecificProject() {
 		return p.buildAllProjectCommandsByPlan(ctx, cmd)
@@ -321,6 +409,44 @@ func (p *DefaultProjectCommandBuilder) BuildStateRmCommands(ctx *command.Context
 	return p.buildProjectCommand(ctx, cmd)
 }
 
+// resolveProjectIndex sets cmd to run on the project listed by list-projects
+// at cmd.ProjectIndex, if it has one.
+// TooManyProjectsError is returned when a command that doesn't target specific
+// projects would run on more projects than the repo's max_projects_per_pr.
+type TooManyProjectsError struct {
+	Command command.Name
+	// Projects are the projects the command would have run on.
+	Projects []command.ProjectContext
+	Max      int
+}
+
+func (e *TooManyProjectsError) Error() string {
+	return fmt.Sprintf("this pull request would %s %d projects, more than the max of %d per pull request set by max_projects_per_pr. "+
+		"Run %s for specific projects instead with -p, -d and -w, or with -i after listing them with list-projects",
+		e.Command.String(), len(e.Projects), e.Max, e.Command.String())
+}
+
+// checkMaxProjects returns a TooManyProjectsError if running cmdName on
+// projectCmds exceeds the repo's max_projects_per_pr.
+func (p *DefaultProjectCommandBuilder) checkMaxProjects(ctx *command.Context, cmdName command.Name, projectCmds []command.ProjectContext) error {
+	max := p.GlobalCfg.RepoMaxProjectsPerPR(ctx.Pull.BaseRepo.ID())
+	if max > 0 && len(projectCmds) > max {
+		return &TooManyProjectsError{Command: cmdName, Projects: projectCmds, Max: max}
+	}
+	return nil
+}
+
+func (p *DefaultProjectCommandBuilder) resolveProjectIndex(ctx *command.Context, cmd *CommentCommand) error {
+	if cmd.ProjectIndex == 0 {
+		return nil
+	}
+	index, err := ReadProjectIndex(p.WorkingDir, ctx)
+	if err != nil {
+		return err
+	}
+	return index.Resolve(ctx.Pull.HeadCommit, cmd)
+}
+
 // buildAllCommandsByCfg builds init contexts for all projects we determine were
 // modified in this ctx.
 func (p *DefaultProjectCommandBuilder) buildAllCommandsByCfg(ctx *command.Context, cmdName command.Name, subCmdName string, commentFlags []string, verbose bool) ([]command.ProjectContext, error) {
@@ -343,9 +469,11 @@ func (p *DefaultProjectCommandBuilder) buildAllCommandsByCfg(ctx *command.Contex
 
 	// Get default AutoDiscoverMode from userConfig/globalConfig
 	defaultAutoDiscoverMode := valid.AutoDiscoverMode(p.AutoDiscoverMode)
+	var autoDiscoverIgnorePaths []string
 	globalAutoDiscover := p.GlobalCfg.RepoAutoDiscoverCfg(ctx.Pull.BaseRepo.ID())
 	if globalAutoDiscover != nil {
 		defaultAutoDiscoverMode = globalAutoDiscover.Mode
+		autoDiscoverIgnorePaths = globalAutoDiscover.IgnorePaths
 	}
 
 	if p.SkipCloneNoChanges && p.VCSClient.SupportsSingleFileDownload(ctx.Pull.BaseRepo) {
@@ -431,6 +559,7 @@ func (p *DefaultProjectCommandBuilder) buildAllCommandsByCfg(ctx *command.Contex
 		// config.
 		if repoCfg.AutoDiscover != nil {
 			defaultAutoDiscoverMode = repoCfg.AutoDiscover.Mode
+			autoDiscoverIgnorePaths = repoCfg.AutoDiscover.IgnorePaths
 		}
 	}
 
@@ -467,6 +596,9 @@ func (p *DefaultProjectCommandBuilder) buildAllCommandsByCfg(ctx *command.Contex
 		for _, mp := range matchingProjects {
 			ctx.Log.Debug("determining config for project at dir: '%s' workspace: '%s'", mp.Dir, mp.Workspace)
 			mergedCfg := p.GlobalCfg.MergeProjectCfg(ctx.Log, ctx.Pull.BaseRepo.ID(), mp, repoCfg)
+			if _, err := resolveWorkspace(ctx, &mergedCfg, repoDir); err != nil {
+				return nil, err
+			}
 
 			projCtxs = append(projCtxs,
 				p.ProjectCommandContextBuilder.BuildProjectContext(
@@ -499,8 +631,18 @@ func (p *DefaultProjectCommandBuilder) buildAllCommandsByCfg(ctx *command.Contex
 			ctx.Log.Info("found no %s file", repoCfgFile)
 		}
 		// build a module index for projects that are explicitly included
-		allModifiedProjects := p.ProjectFinder.DetermineProjects(
-			ctx.Log, modifiedFiles, ctx.Pull.BaseRepo.FullName, repoDir, p.AutoplanFileList, moduleInfo)
+		var allModifiedProjects []models.Project
+		if defaultAutoDiscoverMode == valid.AutoDiscoverBackendMode {
+			allModifiedProjects, err = p.ProjectFinder.DetermineBackendProjects(
+				ctx.Log, modifiedFiles, ctx.Pull.BaseRepo.FullName, repoDir, p.AutoplanFileList, moduleInfo)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			allModifiedProjects = p.ProjectFinder.DetermineProjects(
+				ctx.Log, modifiedFiles, ctx.Pull.BaseRepo.FullName, repoDir, p.AutoplanFileList, moduleInfo)
+		}
+		autoDiscover := valid.AutoDiscover{IgnorePaths: autoDiscoverIgnorePaths}
 		// If a project is already manually configured with the same dir as a discovered project, the manually configured
 		// project should take precedence
 		modifiedProjects := make([]models.Project, 0)
@@ -514,9 +656,14 @@ func (p *DefaultProjectCommandBuilder) buildAllCommandsByCfg(ctx *command.Contex
 		}
 		for _, mp := range allModifiedProjects {
 			_, dirExists := configuredProjDirs[filepath.Clean(mp.Path)]
-			if !dirExists {
-				modifiedProjects = append(modifiedProjects, mp)
+			if dirExists {
+				continue
 			}
+			if autoDiscover.IsPathIgnored(mp.Path) {
+				ctx.Log.Debug("not discovering project at dir %q since it matches the autodiscover ignore_paths", mp.Path)
+				continue
+			}
+			modifiedProjects = append(modifiedProjects, mp)
 		}
 		ctx.Log.Info("automatically determined that there were %d additional projects modified in this pull request: %s",
 			len(modifiedProjects), modifiedProjects)
@@ -529,6 +676,9 @@ func (p *DefaultProjectCommandBuilder) buildAllCommandsByCfg(ctx *command.Contex
 			}
 
 			pCfg := p.GlobalCfg.DefaultProjCfg(ctx.Log, ctx.Pull.BaseRepo.ID(), mp.Path, pWorkspace)
+			if _, err := resolveWorkspace(ctx, &pCfg, repoDir); err != nil {
+				return nil, err
+			}
 
 			projCtxs = append(projCtxs,
 				p.ProjectCommandContextBuilder.BuildProjectContext(
@@ -548,13 +698,31 @@ func (p *DefaultProjectCommandBuilder) buildAllCommandsByCfg(ctx *command.Contex
 		}
 	}
 
-	sort.Slice(projCtxs, func(i, j int) bool {
-		return projCtxs[i].ExecutionOrderGroup < projCtxs[j].ExecutionOrderGroup
-	})
+	sortProjectCommands(projCtxs, repoCfg.ProjectOrder)
 
 	return projCtxs, nil
 }
 
+// buildMultiWorkspacePlanCommands builds plan contexts for the project in each
+// of cmd's workspaces, ex. for atlantis plan -w staging -w prod. Each
+// workspace is cloned and locked separately so it's planned as if it had been
+// commented on its own.
+func (p *DefaultProjectCommandBuilder) buildMultiWorkspacePlanCommands(ctx *command.Context, cmd *CommentCommand) ([]command.ProjectContext, error) {
+	var pcc []command.ProjectContext
+	for _, workspace := range cmd.Workspaces {
+		ctx.Log.Debug("Building plan command for directory: '%v', workspace: '%v'", cmd.RepoRelDir, workspace)
+		workspaceCmd := *cmd
+		workspaceCmd.Workspace = workspace
+		workspaceCmd.Workspaces = nil
+		workspacePcc, err := p.buildProjectPlanCommand(ctx, &workspaceCmd)
+		if err != nil {
+			return nil, errors.Wrapf(err, "building plan for workspace %q", workspace)
+		}
+		pcc = append(pcc, workspacePcc...)
+	}
+	return pcc, nil
+}
+
 // buildProjectPlanCommand builds a plan context for a single project.
 // cmd must be for only one project.
 func (p *DefaultProjectCommandBuilder) buildProjectPlanCommand(ctx *command.Context, cmd *CommentCommand) ([]command.ProjectContext, error) {
@@ -721,6 +889,9 @@ func (p *DefaultProjectCommandBuilder) getCfg(ctx *command.Context, projectName
 	}
 
 	projCfgs := repoCfg.FindProjectsByDirWorkspace(dir, workspace)
+	if len(projCfgs) == 0 {
+		projCfgs = p.findProjectsByResolvedWorkspace(ctx, repoConfig, repoDir, dir, workspace)
+	}
 	if len(projCfgs) == 0 {
 		return
 	}
@@ -766,16 +937,76 @@ func (p *DefaultProjectCommandBuilder) buildAllProjectCommandsByPlan(ctx *comman
 		if err != nil {
 			return nil, errors.Wrapf(err, "building command for dir '%s'", plan.RepoRelDir)
 		}
-		cmds = append(cmds, commentCmds...)
+		for _, cmd := range commentCmds {
+			// Plan-only projects can't be applied so don't try when applying
+			// everything.
+			if cmd.PlanOnly && commentCmd.CommandName() == command.Apply {
+				ctx.Log.Debug("skipping plan-only project %q in dir %q", cmd.ProjectName, cmd.RepoRelDir)
+				continue
+			}
+			cmds = append(cmds, cmd)
+		}
 	}
 
-	sort.Slice(cmds, func(i, j int) bool {
-		return cmds[i].ExecutionOrderGroup < cmds[j].ExecutionOrderGroup
-	})
+	projectOrder, err := p.projectOrder(ctx, defaultRepoDir)
+	if err != nil {
+		return nil, err
+	}
+	sortProjectCommands(cmds, projectOrder)
 
 	return cmds, nil
 }
 
+// projectOrder returns the project_order of the repo config in repoDir, or
+// nil if there's no repo config or it doesn't set one.
+func (p *DefaultProjectCommandBuilder) projectOrder(ctx *command.Context, repoDir string) (*valid.ProjectOrder, error) {
+	repoCfgFile := p.GlobalCfg.RepoConfigFile(ctx.Pull.BaseRepo.ID())
+	hasRepoCfg, err := p.ParserValidator.HasRepoCfg(repoDir, repoCfgFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "looking for '%s' file in '%s'", repoCfgFile, repoDir)
+	}
+	if !hasRepoCfg {
+		return nil, nil
+	}
+	repoCfg, err := p.ParserValidator.ParseRepoCfg(repoDir, p.GlobalCfg, ctx.Pull.BaseRepo.ID(), ctx.Pull.BaseBranch)
+	if err != nil {
+		return nil, err
+	}
+	return repoCfg.ProjectOrder, nil
+}
+
+// sortProjectCommands sorts cmds by execution order group so groups run in
+// order. Within a group they're sorted by order, the repo's project_order,
+// with projects it doesn't list sorted by name, dir and workspace after the
+// listed ones. If order is nil they keep the order they were built in.
+func sortProjectCommands(cmds []command.ProjectContext, order *valid.ProjectOrder) {
+	sort.SliceStable(cmds, func(i, j int) bool {
+		a, b := cmds[i], cmds[j]
+		if a.ExecutionOrderGroup != b.ExecutionOrderGroup {
+			return a.ExecutionOrderGroup < b.ExecutionOrderGroup
+		}
+		if order == nil {
+			return false
+		}
+		aRank, bRank := order.Rank(a.ProjectName, a.RepoRelDir), order.Rank(b.ProjectName, b.RepoRelDir)
+		if aRank != bRank {
+			return aRank < bRank
+		}
+		// Keep the build order of projects matching the same entry, ex. the
+		// workspaces of a dir.
+		if aRank < len(order.Names) {
+			return false
+		}
+		if a.ProjectName != b.ProjectName {
+			return a.ProjectName < b.ProjectName
+		}
+		if a.RepoRelDir != b.RepoRelDir {
+			return a.RepoRelDir < b.RepoRelDir
+		}
+		return a.Workspace < b.Workspace
+	})
+}
+
 // buildProjectCommand builds an command for the single project
 // identified by cmd except plan.
 func (p *DefaultProjectCommandBuilder) buildProjectCommand(ctx *command.Context, cmd *CommentCommand) ([]command.ProjectContext, error) {
@@ -836,6 +1067,9 @@ func (p *DefaultProjectCommandBuilder) buildProjectCommandCtx(ctx *command.Conte
 	}
 	var projCtxs []command.ProjectContext
 	var projCfg valid.MergedProjectCfg
+	// workspaceResolved is true if a project's workspace was read from its
+	// workspace_from_file so it isn't one of the configured workspaces.
+	var workspaceResolved bool
 	automerge := p.EnableAutoMerge
 	parallelApply := p.EnableParallelApply
 	parallelPlan := p.EnableParallelPlan
@@ -862,6 +1096,11 @@ func (p *DefaultProjectCommandBuilder) buildProjectCommandCtx(ctx *command.Conte
 		for _, mp := range matchingProjects {
 			ctx.Log.Debug("Merging config for project at dir: '%s' workspace: '%s'", mp.Dir, mp.Workspace)
 			projCfg = p.GlobalCfg.MergeProjectCfg(ctx.Log, ctx.Pull.BaseRepo.ID(), mp, *repoCfgPtr)
+			resolved, err := resolveWorkspace(ctx, &projCfg, repoDir)
+			if err != nil {
+				return []command.ProjectContext{}, err
+			}
+			workspaceResolved = workspaceResolved || resolved
 
 			projCtxs = append(projCtxs,
 				p.ProjectCommandContextBuilder.BuildProjectContext(
@@ -887,6 +1126,9 @@ func (p *DefaultProjectCommandBuilder) buildProjectCommandCtx(ctx *command.Conte
 		}
 
 		projCfg = p.GlobalCfg.DefaultProjCfg(ctx.Log, ctx.Pull.BaseRepo.ID(), repoRelDir, workspace)
+		if _, err := resolveWorkspace(ctx, &projCfg, repoDir); err != nil {
+			return []command.ProjectContext{}, err
+		}
 		projCtxs = append(projCtxs,
 			p.ProjectCommandContextBuilder.BuildProjectContext(
 				ctx,
@@ -904,6 +1146,9 @@ func (p *DefaultProjectCommandBuilder) buildProjectCommandCtx(ctx *command.Conte
 			)...)
 	}
 
+	if workspaceResolved {
+		return projCtxs, nil
+	}
 	if err := p.validateWorkspaceAllowed(repoCfgPtr, repoRelDir, workspace); err != nil {
 		return []command.ProjectContext{}, err
 	}
@@ -923,3 +1168,47 @@ func (p *DefaultProjectCommandBuilder) validateWorkspaceAllowed(repoCfg *valid.R
 
 	return repoCfg.ValidateWorkspaceAllowed(repoRelDir, workspace)
 }
+
+// workspaceFromFile returns the workspace_from_file of the plan step of cfg's
+// workflow, or "" if it doesn't have one.
+func workspaceFromFile(cfg valid.MergedProjectCfg) string {
+	for _, step := range cfg.Workflow.Plan.Steps {
+		if step.StepName == "plan" && step.WorkspaceFromFile != "" {
+			return step.WorkspaceFromFile
+		}
+	}
+	return ""
+}
+
+// resolveWorkspace sets the workspace of cfg to the workspace read from the
+// workspace_from_file of its plan step, if it has one. It's resolved before
+// building the project's context so every command, and its locks, use the
+// same workspace. It returns true if the workspace was resolved.
+func resolveWorkspace(ctx *command.Context, cfg *valid.MergedProjectCfg, repoDir string) (bool, error) {
+	file := workspaceFromFile(*cfg)
+	if file == "" {
+		return false, nil
+	}
+	workspace, err := runtime.ReadWorkspaceFromFile(repoDir, filepath.Join(repoDir, cfg.RepoRelDir), file)
+	if err != nil {
+		return false, errors.Wrapf(err, "resolving workspace of project in dir %q", cfg.RepoRelDir)
+	}
+	ctx.Log.Debug("using workspace %q from workspace_from_file %q instead of %q for dir %q", workspace, file, cfg.Workspace, cfg.RepoRelDir)
+	cfg.Workspace = workspace
+	return true, nil
+}
+
+// findProjectsByResolvedWorkspace returns the projects in repoCfg in dir whose
+// workspace is read from a workspace_from_file that contains workspace. It's
+// used to find the config of projects from their plans since the workspace of
+// the plan isn't the workspace the project is configured with.
+func (p *DefaultProjectCommandBuilder) findProjectsByResolvedWorkspace(ctx *command.Context, repoCfg valid.RepoCfg, repoDir string, dir string, workspace string) []valid.Project {
+	var projects []valid.Project
+	for _, proj := range repoCfg.FindProjectsByDir(dir) {
+		cfg := p.GlobalCfg.MergeProjectCfg(ctx.Log, ctx.Pull.BaseRepo.ID(), proj, repoCfg)
+		if resolved, err := resolveWorkspace(ctx, &cfg, repoDir); err == nil && resolved && cfg.Workspace == workspace {
+			projects = append(projects, proj)
+		}
+	}
+	return projects
+}
@@ -1,9 +1,11 @@
 package events
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	version "github.com/hashicorp/go-version"
 	. "github.com/petergtz/pegomock/v4"
@@ -85,6 +87,11 @@ workflows:
 				Workspace:          "myworkspace",
 				PolicySets:         emptyPolicySets,
 				RepoLocksMode:      valid.DefaultRepoLocksMode,
+				WorkflowName:       "default",
+				WorkflowTrace: []string{
+					`default server config sets workflow "default"`,
+					`repos[1], id: /.*/ in the server-side repo config sets workflow "default"`,
+				},
 			},
 			expPlanSteps:  []string{"init", "plan"},
 			expApplySteps: []string{"apply"},
@@ -144,6 +151,11 @@ projects:
 				Workspace:          "myworkspace",
 				PolicySets:         emptyPolicySets,
 				RepoLocksMode:      valid.DefaultRepoLocksMode,
+				WorkflowName:       "default",
+				WorkflowTrace: []string{
+					`default server config sets workflow "default"`,
+					`repos[1], id: /.*/ in the server-side repo config sets workflow "default"`,
+				},
 			},
 			expPlanSteps:  []string{"init", "plan"},
 			expApplySteps: []string{"apply"},
@@ -205,6 +217,11 @@ projects:
 				Workspace:          "myworkspace",
 				PolicySets:         emptyPolicySets,
 				RepoLocksMode:      valid.DefaultRepoLocksMode,
+				WorkflowName:       "default",
+				WorkflowTrace: []string{
+					`default server config sets workflow "default"`,
+					`repos[1], id: /.*/ in the server-side repo config sets workflow "default"`,
+				},
 			},
 			expPlanSteps:  []string{"init", "plan"},
 			expApplySteps: []string{"apply"},
@@ -274,6 +291,12 @@ projects:
 				Workspace:          "myworkspace",
 				PolicySets:         emptyPolicySets,
 				RepoLocksMode:      valid.DefaultRepoLocksMode,
+				WorkflowName:       "specific",
+				WorkflowTrace: []string{
+					`default server config sets workflow "default"`,
+					`repos[1], id: /.*/ in the server-side repo config sets workflow "default"`,
+					`repos[2], id: github.com/owner/repo in the server-side repo config sets workflow "specific"`,
+				},
 			},
 			expPlanSteps:  []string{"plan"},
 			expApplySteps: []string{},
@@ -430,6 +453,13 @@ workflows:
 				Workspace:          "myworkspace",
 				PolicySets:         emptyPolicySets,
 				RepoLocksMode:      valid.DefaultRepoLocksMode,
+				WorkflowName:       "custom",
+				WorkflowTrace: []string{
+					`default server config sets workflow "default"`,
+					`repos[1], id: /.*/ in the server-side repo config sets workflow "default"`,
+					`project in dir "project1" workspace "myworkspace" in the repo config sets workflow "custom", defined in the repo config`,
+				},
+				RepoCfgKeys: []string{"apply_requirements", "import_requirements", "workflow"},
 			},
 			expPlanSteps:  []string{"plan"},
 			expApplySteps: []string{"apply"},
@@ -493,6 +523,13 @@ projects:
 				Workspace:          "myworkspace",
 				PolicySets:         emptyPolicySets,
 				RepoLocksMode:      valid.DefaultRepoLocksMode,
+				WorkflowName:       "custom",
+				WorkflowTrace: []string{
+					`default server config sets workflow "default"`,
+					`repos[1], id: /.*/ in the server-side repo config sets workflow "default"`,
+					`project in dir "project1" workspace "myworkspace" in the repo config sets workflow "custom", defined in the server-side repo config`,
+				},
+				RepoCfgKeys: []string{"workflow"},
 			},
 			expPlanSteps:  []string{"plan"},
 			expApplySteps: []string{"apply"},
@@ -559,6 +596,13 @@ workflows:
 				Workspace:          "myworkspace",
 				PolicySets:         emptyPolicySets,
 				RepoLocksMode:      valid.DefaultRepoLocksMode,
+				WorkflowName:       "custom",
+				WorkflowTrace: []string{
+					`default server config sets workflow "default"`,
+					`repos[1], id: /.*/ in the server-side repo config sets workflow "custom"`,
+					`project in dir "project1" workspace "myworkspace" in the repo config sets workflow "custom", defined in the repo config`,
+				},
+				RepoCfgKeys: []string{"workflow"},
 			},
 			expPlanSteps:  []string{},
 			expApplySteps: []string{},
@@ -610,6 +654,11 @@ projects:
 				Workspace:          "myworkspace",
 				PolicySets:         emptyPolicySets,
 				RepoLocksMode:      valid.DefaultRepoLocksMode,
+				WorkflowName:       "custom",
+				WorkflowTrace: []string{
+					`default server config sets workflow "default"`,
+					`repos[2], id: github.com/owner/repo in the server-side repo config sets workflow "custom"`,
+				},
 			},
 			expPlanSteps:  []string{"plan"},
 			expApplySteps: []string{"apply"},
@@ -719,6 +768,9 @@ projects:
 
 					// Job ID cannot be compared since its generated at random
 					ctx.JobID = ""
+					// The workflow's steps are compared with Steps.
+					Equals(t, c.expCtx.WorkflowName, ctx.Workflow.Name)
+					ctx.Workflow = valid.Workflow{}
 
 					Equals(t, c.expCtx, ctx)
 					// Equals() doesn't compare TF version properly so have to
@@ -825,6 +877,11 @@ projects:
 				Workspace:          "myworkspace",
 				PolicySets:         emptyPolicySets,
 				RepoLocksMode:      valid.DefaultRepoLocksMode,
+				WorkflowName:       "default",
+				WorkflowTrace: []string{
+					`default server config sets workflow "default"`,
+					`repos[1], id: /.*/ in the server-side repo config sets workflow "default"`,
+				},
 			},
 			expPlanSteps:  []string{"init", "plan"},
 			expApplySteps: []string{"apply"},
@@ -937,6 +994,9 @@ projects:
 
 					// Job ID cannot be compared since its generated at random
 					ctx.JobID = ""
+					// The workflow's steps are compared with Steps.
+					Equals(t, c.expCtx.WorkflowName, ctx.Workflow.Name)
+					ctx.Workflow = valid.Workflow{}
 
 					Equals(t, c.expCtx, ctx)
 					// Equals() doesn't compare TF version properly so have to
@@ -1006,6 +1066,10 @@ repos:
 				Workspace:          "myworkspace",
 				PolicySets:         emptyPolicySets,
 				RepoLocksMode:      valid.DefaultRepoLocksMode,
+				WorkflowName:       "default",
+				WorkflowTrace: []string{
+					`default server config sets workflow "default"`,
+				},
 			},
 			expPolicyCheckSteps: []string{"show", "policy_check"},
 		},
@@ -1071,6 +1135,13 @@ workflows:
 				PolicySets:         emptyPolicySets,
 				RepoLocksMode:      valid.DefaultRepoLocksMode,
 				PolicySetTarget:    "",
+				WorkflowName:       "custom",
+				WorkflowTrace: []string{
+					`default server config sets workflow "default"`,
+					`repos[1], id: /.*/ in the server-side repo config sets workflow "default"`,
+					`project in dir "project1" workspace "myworkspace" in the repo config sets workflow "custom", defined in the repo config`,
+				},
+				RepoCfgKeys: []string{"apply_requirements", "workflow"},
 			},
 			expPolicyCheckSteps: []string{"policy_check"},
 		},
@@ -1178,6 +1249,9 @@ workflows:
 
 				// Job ID cannot be compared since its generated at random
 				ctx.JobID = ""
+				// The workflow's steps are compared with Steps.
+				Equals(t, c.expCtx.WorkflowName, ctx.Workflow.Name)
+				ctx.Workflow = valid.Workflow{}
 
 				Equals(t, c.expCtx, ctx)
 				// Equals() doesn't compare TF version properly so have to
@@ -1459,3 +1533,74 @@ func mustVersion(v string) *version.Version {
 	}
 	return vers
 }
+
+func TestSortProjectCommands(t *testing.T) {
+	ctx := func(name string, dir string, workspace string, group int) command.ProjectContext {
+		return command.ProjectContext{ProjectName: name, RepoRelDir: dir, Workspace: workspace, ExecutionOrderGroup: group}
+	}
+	key := func(cmds []command.ProjectContext) []string {
+		var keys []string
+		for _, c := range cmds {
+			keys = append(keys, c.ProjectName+":"+c.RepoRelDir+":"+c.Workspace)
+		}
+		return keys
+	}
+	cases := []struct {
+		description string
+		order       *valid.ProjectOrder
+		exp         []string
+	}{
+		{
+			description: "no order keeps build order within groups",
+			exp:         []string{"edge:edge:default", "app:app:default", "network:network:default", ":unnamed:staging", ":unnamed:default", "db:db:default"},
+		},
+		{
+			description: "alphabetical",
+			order:       &valid.ProjectOrder{},
+			exp:         []string{":unnamed:default", ":unnamed:staging", "app:app:default", "edge:edge:default", "network:network:default", "db:db:default"},
+		},
+		{
+			description: "explicit order with unlisted projects appended sorted",
+			order:       &valid.ProjectOrder{Names: []string{"network", "unnamed", "app"}},
+			exp:         []string{"network:network:default", ":unnamed:staging", ":unnamed:default", "app:app:default", "edge:edge:default", "db:db:default"},
+		},
+		{
+			description: "execution order groups come first",
+			order:       &valid.ProjectOrder{Names: []string{"db", "network"}},
+			exp:         []string{"network:network:default", ":unnamed:default", ":unnamed:staging", "app:app:default", "edge:edge:default", "db:db:default"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			cmds := []command.ProjectContext{
+				ctx("db", "db", "default", 1),
+				ctx("edge", "edge", "default", 0),
+				ctx("app", "app", "default", 0),
+				ctx("network", "network", "default", 0),
+				ctx("", "unnamed", "staging", 0),
+				ctx("", "unnamed", "default", 0),
+			}
+			sortProjectCommands(cmds, c.order)
+			Equals(t, c.exp, key(cmds))
+		})
+	}
+}
+
+func TestRunProjectCmdsParallel_KeepsOrder(t *testing.T) {
+	var cmds []command.ProjectContext
+	delays := make(map[string]time.Duration)
+	for i := 0; i < 20; i++ {
+		dir := fmt.Sprintf("dir%d", i)
+		cmds = append(cmds, command.ProjectContext{RepoRelDir: dir})
+		// Later projects finish first.
+		delays[dir] = time.Duration(20-i) * time.Millisecond
+	}
+	res := runProjectCmdsParallel(cmds, func(ctx command.ProjectContext) command.ProjectResult {
+		time.Sleep(delays[ctx.RepoRelDir])
+		return command.ProjectResult{RepoRelDir: ctx.RepoRelDir}
+	}, 20)
+	for i, r := range res.ProjectResults {
+		Equals(t, cmds[i].RepoRelDir, r.RepoRelDir)
+	}
+	Equals(t, len(cmds), len(res.ProjectResults))
+}
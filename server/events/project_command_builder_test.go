@@ -1,8 +1,10 @@
 package events_test
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"testing"
@@ -157,6 +159,35 @@ projects:
 				},
 			},
 		},
+		{
+			Description: "autoplan limited to some workspaces",
+			AtlantisYAML: `
+version: 3
+projects:
+- dir: .
+  autoplan: &autoplan
+    workspaces: [default, staging]
+- dir: .
+  workspace: staging
+  autoplan: *autoplan
+- dir: .
+  workspace: production
+  autoplan: *autoplan
+`,
+			TestDirStructure: defaultTestDirStructure,
+			exp: []expCtxFields{
+				{
+					ProjectName: "",
+					RepoRelDir:  ".",
+					Workspace:   "default",
+				},
+				{
+					ProjectName: "",
+					RepoRelDir:  ".",
+					Workspace:   "staging",
+				},
+			},
+		},
 		{
 			Description: "no projects modified",
 			AtlantisYAML: `
@@ -227,6 +258,46 @@ terraform {
 				},
 			},
 		},
+		{
+			Description: "autodiscover backend mode",
+			AtlantisYAML: `
+version: 3
+autodiscover:
+  mode: backend
+  ignore_paths: [sandbox]
+`,
+			TestDirStructure: map[string]interface{}{
+				"prod": map[string]interface{}{
+					"main.tf": `
+terraform {
+  backend "s3" {
+    bucket = "state"
+  }
+}`,
+				},
+				"sandbox": map[string]interface{}{
+					"main.tf": `
+terraform {
+  backend "local" {}
+}`,
+				},
+				"scratch": map[string]interface{}{
+					"main.tf": nil,
+				},
+				"modules": map[string]interface{}{
+					"vpc": map[string]interface{}{
+						"main.tf": nil,
+					},
+				},
+			},
+			exp: []expCtxFields{
+				{
+					ProjectName: "",
+					RepoRelDir:  "prod",
+					Workspace:   "default",
+				},
+			},
+		},
 	}
 
 	logger := logging.NewNoopLogger(t)
@@ -1288,6 +1359,215 @@ func TestDefaultProjectCommandBuilder_BuildMultiApply(t *testing.T) {
 	Equals(t, "workspace2", ctxs[3].Workspace)
 }
 
+// Test that commands that don't target specific projects fail when there are
+// more projects than max_projects_per_pr, but targeted ones still run.
+func TestDefaultProjectCommandBuilder_MaxProjectsPerPR(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmpDir := DirStructure(t, map[string]interface{}{
+		"workspace1": map[string]interface{}{
+			"project1": map[string]interface{}{
+				"main.tf":          nil,
+				"workspace.tfplan": nil,
+			},
+			"project2": map[string]interface{}{
+				"main.tf":          nil,
+				"workspace.tfplan": nil,
+			},
+		},
+	})
+	runCmd(t, filepath.Join(tmpDir, "workspace1"), "git", "init")
+
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.GetPullDir(
+		Any[models.Repo](),
+		Any[models.PullRequest]())).
+		ThenReturn(tmpDir, nil)
+	When(workingDir.GetWorkingDir(
+		Any[models.Repo](),
+		Any[models.PullRequest](),
+		Any[string]())).
+		ThenReturn(filepath.Join(tmpDir, "workspace1"), nil)
+
+	logger := logging.NewNoopLogger(t)
+	userConfig := defaultUserConfig
+	scope, _, _ := metrics.NewLoggingScope(logger, "atlantis")
+	globalCfg := valid.NewGlobalCfgFromArgs(valid.GlobalCfgArgs{})
+	maxProjects := 1
+	globalCfg.Repos[0].MaxProjectsPerPR = &maxProjects
+
+	terraformClient := terraform_mocks.NewMockClient()
+	When(terraformClient.ListAvailableVersions(Any[logging.SimpleLogging]())).ThenReturn([]string{}, nil)
+
+	builder := events.NewProjectCommandBuilder(
+		false,
+		&config.ParserValidator{},
+		&events.DefaultProjectFinder{},
+		nil,
+		workingDir,
+		events.NewDefaultWorkingDirLocker(),
+		globalCfg,
+		&events.DefaultPendingPlanFinder{},
+		&events.CommentParser{ExecutableName: "atlantis"},
+		userConfig.SkipCloneNoChanges,
+		userConfig.EnableRegExpCmd,
+		userConfig.EnableAutoMerge,
+		userConfig.EnableParallelPlan,
+		userConfig.EnableParallelApply,
+		userConfig.AutoDetectModuleFiles,
+		userConfig.AutoplanFileList,
+		userConfig.RestrictFileList,
+		userConfig.SilenceNoProjects,
+		userConfig.IncludeGitUntrackedFiles,
+		userConfig.AutoDiscoverMode,
+		scope,
+		terraformClient,
+	)
+	ctx := &command.Context{
+		Log:   logger,
+		Scope: scope,
+	}
+
+	_, err := builder.BuildApplyCommands(ctx, &events.CommentCommand{Name: command.Apply})
+	ErrEquals(t, "this pull request would apply 2 projects, more than the max of 1 per pull request set by max_projects_per_pr. "+
+		"Run apply for specific projects instead with -p, -d and -w, or with -i after listing them with list-projects", err)
+	var tooManyErr *events.TooManyProjectsError
+	Assert(t, errors.As(err, &tooManyErr), "exp TooManyProjectsError")
+	Equals(t, 2, len(tooManyErr.Projects))
+
+	ctxs, err := builder.BuildApplyCommands(ctx, &events.CommentCommand{
+		Name:       command.Apply,
+		RepoRelDir: "project1",
+		Workspace:  "workspace1",
+	})
+	Ok(t, err)
+	Equals(t, 1, len(ctxs))
+}
+
+// Test that plan --ref must target specific projects since the pull
+// request's modified files don't say which projects the ref changes.
+func TestDefaultProjectCommandBuilder_PlanRefRequiresProject(t *testing.T) {
+	RegisterMockTestingT(t)
+	logger := logging.NewNoopLogger(t)
+	userConfig := defaultUserConfig
+	scope, _, _ := metrics.NewLoggingScope(logger, "atlantis")
+	globalCfg := valid.NewGlobalCfgFromArgs(valid.GlobalCfgArgs{})
+	globalCfg.Repos[0].AllowedPlanRefsRegex = regexp.MustCompile("^origin/hotfix$")
+
+	builder := events.NewProjectCommandBuilder(
+		false,
+		&config.ParserValidator{},
+		&events.DefaultProjectFinder{},
+		nil,
+		mocks.NewMockWorkingDir(),
+		events.NewDefaultWorkingDirLocker(),
+		globalCfg,
+		&events.DefaultPendingPlanFinder{},
+		&events.CommentParser{ExecutableName: "atlantis"},
+		userConfig.SkipCloneNoChanges,
+		userConfig.EnableRegExpCmd,
+		userConfig.EnableAutoMerge,
+		userConfig.EnableParallelPlan,
+		userConfig.EnableParallelApply,
+		userConfig.AutoDetectModuleFiles,
+		userConfig.AutoplanFileList,
+		userConfig.RestrictFileList,
+		userConfig.SilenceNoProjects,
+		userConfig.IncludeGitUntrackedFiles,
+		userConfig.AutoDiscoverMode,
+		scope,
+		terraform_mocks.NewMockClient(),
+	)
+	ctx := &command.Context{
+		Log:   logger,
+		Scope: scope,
+	}
+
+	_, err := builder.BuildPlanCommands(ctx, &events.CommentCommand{Name: command.Plan, Ref: "origin/hotfix"})
+	ErrEquals(t, "plan --ref must be run for a specific project with -d or -p", err)
+	_, err = builder.BuildPlanCommands(ctx, &events.CommentCommand{Name: command.Plan, Ref: "origin/main", RepoRelDir: "."})
+	ErrEquals(t, `planning ref "origin/main" is not allowed, it must match allowed_plan_refs in the server-side repo config`, err)
+}
+
+func TestDefaultProjectCommandBuilder_RequireApplyReason(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmpDir := DirStructure(t, map[string]interface{}{
+		"workspace1": map[string]interface{}{
+			"project1": map[string]interface{}{
+				"main.tf":          nil,
+				"workspace.tfplan": nil,
+			},
+			"project2": map[string]interface{}{
+				"main.tf":          nil,
+				"workspace.tfplan": nil,
+			},
+		},
+	})
+	runCmd(t, filepath.Join(tmpDir, "workspace1"), "git", "init")
+
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.GetPullDir(
+		Any[models.Repo](),
+		Any[models.PullRequest]())).
+		ThenReturn(tmpDir, nil)
+	When(workingDir.GetWorkingDir(
+		Any[models.Repo](),
+		Any[models.PullRequest](),
+		Any[string]())).
+		ThenReturn(filepath.Join(tmpDir, "workspace1"), nil)
+
+	logger := logging.NewNoopLogger(t)
+	userConfig := defaultUserConfig
+	scope, _, _ := metrics.NewLoggingScope(logger, "atlantis")
+	globalCfg := valid.NewGlobalCfgFromArgs(valid.GlobalCfgArgs{})
+	requireReason := true
+	globalCfg.Repos[0].RequireApplyReason = &requireReason
+
+	terraformClient := terraform_mocks.NewMockClient()
+	When(terraformClient.ListAvailableVersions(Any[logging.SimpleLogging]())).ThenReturn([]string{}, nil)
+
+	builder := events.NewProjectCommandBuilder(
+		false,
+		&config.ParserValidator{},
+		&events.DefaultProjectFinder{},
+		nil,
+		workingDir,
+		events.NewDefaultWorkingDirLocker(),
+		globalCfg,
+		&events.DefaultPendingPlanFinder{},
+		&events.CommentParser{ExecutableName: "atlantis"},
+		userConfig.SkipCloneNoChanges,
+		userConfig.EnableRegExpCmd,
+		userConfig.EnableAutoMerge,
+		userConfig.EnableParallelPlan,
+		userConfig.EnableParallelApply,
+		userConfig.AutoDetectModuleFiles,
+		userConfig.AutoplanFileList,
+		userConfig.RestrictFileList,
+		userConfig.SilenceNoProjects,
+		userConfig.IncludeGitUntrackedFiles,
+		userConfig.AutoDiscoverMode,
+		scope,
+		terraformClient,
+	)
+	ctx := &command.Context{
+		Log:   logger,
+		Scope: scope,
+	}
+
+	_, err := builder.BuildApplyCommands(ctx, &events.CommentCommand{Name: command.Apply})
+	ErrEquals(t, "a reason is required to apply in this repo, set by require_apply_reason. Run apply again with --reason, ex. apply --reason \"JIRA-123 emergency fix\"", err)
+
+	ctxs, err := builder.BuildApplyCommands(ctx, &events.CommentCommand{Name: command.Apply, Reason: "JIRA-123"})
+	Ok(t, err)
+	Equals(t, 2, len(ctxs))
+
+	// Applies through the API don't need a reason.
+	ctx.API = true
+	ctxs, err = builder.BuildApplyCommands(ctx, &events.CommentCommand{Name: command.Apply})
+	Ok(t, err)
+	Equals(t, 2, len(ctxs))
+}
+
 // Test that if a directory has a list of workspaces configured then we don't
 // allow plans for other workspace names.
 func TestDefaultProjectCommandBuilder_WrongWorkspaceName(t *testing.T) {
@@ -1368,6 +1648,191 @@ projects:
 	ErrEquals(t, "running commands in workspace \"notconfigured\" is not allowed because this directory is only configured for the following workspaces: default, staging", err)
 }
 
+// Test that the workspace of projects whose plan step sets
+// workspace_from_file is read from the file, both when planning and when
+// applying their plans.
+func TestDefaultProjectCommandBuilder_WorkspaceFromFile(t *testing.T) {
+	RegisterMockTestingT(t)
+	workingDir := mocks.NewMockWorkingDir()
+
+	tmpDir := DirStructure(t, map[string]interface{}{
+		"pulldir": map[string]interface{}{
+			"default": map[string]interface{}{
+				"project1": map[string]interface{}{
+					"main.tf":             nil,
+					".atlantis-workspace": "staging\n",
+				},
+				"project2": map[string]interface{}{
+					"main.tf":             nil,
+					".atlantis-workspace": "",
+				},
+			},
+			"staging": map[string]interface{}{
+				"project1": map[string]interface{}{
+					"main.tf":        nil,
+					"staging.tfplan": nil,
+				},
+			},
+		},
+	})
+	pullDir := filepath.Join(tmpDir, "pulldir")
+	repoDir := filepath.Join(pullDir, "default")
+	runCmd(t, repoDir, "git", "init")
+	runCmd(t, filepath.Join(pullDir, "staging"), "git", "init")
+
+	yamlCfg := `version: 3
+projects:
+- dir: project1
+  workflow: custom
+- dir: project2
+  workflow: custom
+workflows:
+  custom:
+    plan:
+      steps:
+      - init
+      - plan:
+          workspace_from_file: .atlantis-workspace
+    apply:
+      steps:
+      - run: echo applying
+`
+	Ok(t, os.WriteFile(filepath.Join(repoDir, valid.DefaultAtlantisFile), []byte(yamlCfg), 0600))
+
+	When(workingDir.Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+		Any[string]())).ThenReturn(repoDir, false, nil)
+	When(workingDir.GetWorkingDir(Any[models.Repo](), Any[models.PullRequest](), Any[string]())).ThenReturn(repoDir, nil)
+	When(workingDir.GetPullDir(Any[models.Repo](), Any[models.PullRequest]())).ThenReturn(pullDir, nil)
+
+	globalCfgArgs := valid.GlobalCfgArgs{
+		AllowAllRepoSettings: true,
+	}
+	logger := logging.NewNoopLogger(t)
+	scope, _, _ := metrics.NewLoggingScope(logger, "atlantis")
+	userConfig := defaultUserConfig
+
+	terraformClient := terraform_mocks.NewMockClient()
+	When(terraformClient.ListAvailableVersions(Any[logging.SimpleLogging]())).ThenReturn([]string{}, nil)
+
+	builder := events.NewProjectCommandBuilder(
+		false,
+		&config.ParserValidator{},
+		&events.DefaultProjectFinder{},
+		nil,
+		workingDir,
+		events.NewDefaultWorkingDirLocker(),
+		valid.NewGlobalCfgFromArgs(globalCfgArgs),
+		&events.DefaultPendingPlanFinder{},
+		&events.CommentParser{ExecutableName: "atlantis"},
+		userConfig.SkipCloneNoChanges,
+		userConfig.EnableRegExpCmd,
+		userConfig.EnableAutoMerge,
+		userConfig.EnableParallelPlan,
+		userConfig.EnableParallelApply,
+		userConfig.AutoDetectModuleFiles,
+		userConfig.AutoplanFileList,
+		userConfig.RestrictFileList,
+		userConfig.SilenceNoProjects,
+		userConfig.IncludeGitUntrackedFiles,
+		userConfig.AutoDiscoverMode,
+		scope,
+		terraformClient,
+	)
+
+	ctx := &command.Context{
+		HeadRepo: models.Repo{},
+		Pull:     models.PullRequest{},
+		User:     models.User{},
+		Log:      logger,
+		Scope:    scope,
+	}
+	ctxs, err := builder.BuildPlanCommands(ctx, &events.CommentCommand{
+		RepoRelDir: "project1",
+		Name:       command.Plan,
+	})
+	Ok(t, err)
+	Equals(t, 1, len(ctxs))
+	Equals(t, "staging", ctxs[0].Workspace)
+
+	_, err = builder.BuildPlanCommands(ctx, &events.CommentCommand{
+		RepoRelDir: "project2",
+		Name:       command.Plan,
+	})
+	ErrEquals(t, "resolving workspace of project in dir \"project2\": workspace_from_file \".atlantis-workspace\" is empty", err)
+
+	ctxs, err = builder.BuildApplyCommands(ctx, &events.CommentCommand{
+		Name: command.Apply,
+	})
+	Ok(t, err)
+	Equals(t, 1, len(ctxs))
+	Equals(t, "project1", ctxs[0].RepoRelDir)
+	Equals(t, "staging", ctxs[0].Workspace)
+	Equals(t, "echo applying", ctxs[0].Steps[0].RunCommand)
+}
+
+// Test that a plan comment with several workspaces plans the project in each
+// of them.
+func TestDefaultProjectCommandBuilder_PlanMultipleWorkspaces(t *testing.T) {
+	RegisterMockTestingT(t)
+	workingDir := mocks.NewMockWorkingDir()
+	repoDir := DirStructure(t, map[string]interface{}{
+		"project1": map[string]interface{}{
+			"main.tf": nil,
+		},
+	})
+	When(workingDir.Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+		Any[string]())).ThenReturn(repoDir, false, nil)
+	When(workingDir.GetWorkingDir(Any[models.Repo](), Any[models.PullRequest](), Any[string]())).ThenReturn(repoDir, nil)
+
+	logger := logging.NewNoopLogger(t)
+	scope, _, _ := metrics.NewLoggingScope(logger, "atlantis")
+	userConfig := defaultUserConfig
+	terraformClient := terraform_mocks.NewMockClient()
+	When(terraformClient.ListAvailableVersions(Any[logging.SimpleLogging]())).ThenReturn([]string{}, nil)
+
+	builder := events.NewProjectCommandBuilder(
+		false,
+		&config.ParserValidator{},
+		&events.DefaultProjectFinder{},
+		nil,
+		workingDir,
+		events.NewDefaultWorkingDirLocker(),
+		valid.NewGlobalCfgFromArgs(valid.GlobalCfgArgs{}),
+		&events.DefaultPendingPlanFinder{},
+		&events.CommentParser{ExecutableName: "atlantis"},
+		userConfig.SkipCloneNoChanges,
+		userConfig.EnableRegExpCmd,
+		userConfig.EnableAutoMerge,
+		userConfig.EnableParallelPlan,
+		userConfig.EnableParallelApply,
+		userConfig.AutoDetectModuleFiles,
+		userConfig.AutoplanFileList,
+		userConfig.RestrictFileList,
+		userConfig.SilenceNoProjects,
+		userConfig.IncludeGitUntrackedFiles,
+		userConfig.AutoDiscoverMode,
+		scope,
+		terraformClient,
+	)
+
+	ctxs, err := builder.BuildPlanCommands(&command.Context{
+		Log:   logger,
+		Scope: scope,
+	}, &events.CommentCommand{
+		RepoRelDir: "project1",
+		Name:       command.Plan,
+		Workspaces: []string{"staging", "prod"},
+	})
+	Ok(t, err)
+	Equals(t, 2, len(ctxs))
+	Equals(t, "staging", ctxs[0].Workspace)
+	Equals(t, "atlantis plan -d project1 -w staging", ctxs[0].RePlanCmd)
+	Equals(t, "prod", ctxs[1].Workspace)
+	Equals(t, "atlantis plan -d project1 -w prod", ctxs[1].RePlanCmd)
+	workingDir.VerifyWasCalledOnce().Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](), Eq("staging"))
+	workingDir.VerifyWasCalledOnce().Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](), Eq("prod"))
+}
+
 // Test that extra comment args are escaped.
 func TestDefaultProjectCommandBuilder_EscapeArgs(t *testing.T) {
 	cases := []struct {
@@ -1800,6 +2265,90 @@ func TestDefaultProjectCommandBuilder_WithPolicyCheckEnabled_BuildAutoplanComman
 	Equals(t, globalCfg.Workflows["default"].PolicyCheck.Steps, policyCheckCtx.Steps)
 }
 
+// Test that policy_check commands are built for planned projects and error
+// for projects that haven't been planned.
+func TestDefaultProjectCommandBuilder_BuildPolicyCheckCommands(t *testing.T) {
+	cases := map[string]struct {
+		files  map[string]interface{}
+		expErr string
+	}{
+		"planned": {
+			files: map[string]interface{}{
+				"main.tf":        nil,
+				"default.tfplan": nil,
+			},
+		},
+		"not planned": {
+			files: map[string]interface{}{
+				"main.tf": nil,
+			},
+			expErr: "no plan found for dir \".\" workspace \"default\", run plan first",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			tmpDir := DirStructure(t, c.files)
+
+			logger := logging.NewNoopLogger(t)
+			scope, _, _ := metrics.NewLoggingScope(logger, "atlantis")
+			userConfig := defaultUserConfig
+
+			workingDir := mocks.NewMockWorkingDir()
+			When(workingDir.GetWorkingDir(Any[models.Repo](), Any[models.PullRequest](), Any[string]())).ThenReturn(tmpDir, nil)
+
+			globalCfg := valid.NewGlobalCfgFromArgs(valid.GlobalCfgArgs{
+				PolicyCheckEnabled: true,
+			})
+			terraformClient := terraform_mocks.NewMockClient()
+			When(terraformClient.ListAvailableVersions(Any[logging.SimpleLogging]())).ThenReturn([]string{}, nil)
+
+			builder := events.NewProjectCommandBuilder(
+				true,
+				&config.ParserValidator{},
+				&events.DefaultProjectFinder{},
+				nil,
+				workingDir,
+				events.NewDefaultWorkingDirLocker(),
+				globalCfg,
+				&events.DefaultPendingPlanFinder{},
+				&events.CommentParser{ExecutableName: "atlantis"},
+				userConfig.SkipCloneNoChanges,
+				userConfig.EnableRegExpCmd,
+				userConfig.EnableAutoMerge,
+				userConfig.EnableParallelPlan,
+				userConfig.EnableParallelApply,
+				userConfig.AutoDetectModuleFiles,
+				userConfig.AutoplanFileList,
+				userConfig.RestrictFileList,
+				userConfig.SilenceNoProjects,
+				userConfig.IncludeGitUntrackedFiles,
+				userConfig.AutoDiscoverMode,
+				scope,
+				terraformClient,
+			)
+
+			ctxs, err := builder.BuildPolicyCheckCommands(
+				&command.Context{
+					Log:   logger,
+					Scope: scope,
+				},
+				&events.CommentCommand{
+					RepoRelDir: ".",
+					Name:       command.PolicyCheck,
+				})
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, 1, len(ctxs))
+			Equals(t, command.PolicyCheck, ctxs[0].CommandName)
+			Equals(t, globalCfg.Workflows["default"].PolicyCheck.Steps, ctxs[0].Steps)
+		})
+	}
+}
+
 // Test building version command for multiple projects
 func TestDefaultProjectCommandBuilder_BuildVersionCommand(t *testing.T) {
 	RegisterMockTestingT(t)
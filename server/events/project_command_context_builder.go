@@ -103,6 +103,8 @@ func (cb *DefaultProjectCommandContextBuilder) BuildProjectContext(
 		steps = prjCfg.Workflow.Plan.Steps
 	case command.Apply:
 		steps = prjCfg.Workflow.Apply.Steps
+	case command.PolicyCheck:
+		steps = prjCfg.Workflow.PolicyCheck.Steps
 	case command.Version:
 		// Setting statically since there will only be one step
 		steps = []valid.Step{{
@@ -172,6 +174,10 @@ func (cb *PolicyCheckProjectCommandContextBuilder) BuildProjectContext(
 	} else {
 		// PolicyCheck is disabled at repository level
 		ctx.Log.Debug("PolicyChecks are disabled on this repository")
+		// There's nothing to check when checking policies again.
+		if cmdName == command.PolicyCheck {
+			return nil
+		}
 	}
 
 	// If TerraformVersion not defined in config file look for a
@@ -281,6 +287,8 @@ func newProjectCommandContext(ctx *command.Context,
 		DependsOn:                  projCfg.DependsOn,
 		AutoplanEnabled:            projCfg.AutoplanEnabled,
 		Steps:                      steps,
+		Engine:                     projCfg.Workflow.Engine,
+		EnvPassthrough:             projCfg.Workflow.EnvPassthrough,
 		HeadRepo:                   ctx.HeadRepo,
 		Log:                        ctx.Log,
 		Scope:                      scope,
@@ -306,6 +314,29 @@ func newProjectCommandContext(ctx *command.Context,
 		JobID:                      uuid.New().String(),
 		ExecutionOrderGroup:        projCfg.ExecutionOrderGroup,
 		AbortOnExcecutionOrderFail: abortOnExcecutionOrderFail,
+		PlanOnly:                   projCfg.PlanOnly,
+		PlanTTL:                    projCfg.PlanTTL,
+		Chdir:                      projCfg.Chdir,
+		CLIConfig:                  projCfg.CLIConfig,
+		TFVars:                     projCfg.TFVars,
+		BackendConfigs:             projCfg.BackendConfigs,
+		PluginCacheDir:             projCfg.PluginCacheDir,
+		ProviderMirror:             projCfg.ProviderMirror,
+		RunCommandPolicy:           projCfg.RunCommandPolicy,
+		RunID:                      ctx.RunID,
+		QueueApply:                 ctx.QueueApply,
+		Cancelled:                  ctx.Cancelled,
+		ProjectOutcomes:            ctx.ProjectOutcomes,
+		OnCancelSteps:              projCfg.Workflow.OnCancel.Steps,
+		ApplyReason:                ctx.ApplyReason,
+		ApplyTargets:               ctx.ApplyTargets,
+		PlanSummary:                ctx.PlanSummary,
+		Span:                       ctx.Span,
+		WorkflowTimeout:            projCfg.Workflow.Timeout,
+		WorkflowName:               projCfg.Workflow.Name,
+		WorkflowTrace:              projCfg.WorkflowTrace,
+		Workflow:                   projCfg.Workflow,
+		RepoCfgKeys:                projCfg.RepoCfgKeys,
 	}
 }
 
@@ -1,8 +1,8 @@
 package events
 
 import (
+	"errors"
 	"sort"
-	"sync"
 
 	"github.com/remeh/sizedwaitgroup"
 	"github.com/runatlantis/atlantis/server/events/command"
@@ -15,21 +15,19 @@ func runProjectCmdsParallel(
 	runnerFunc prjCmdRunnerFunc,
 	poolSize int,
 ) command.Result {
-	var results []command.ProjectResult
-	mux := &sync.Mutex{}
+	// Results are kept in the order of cmds, not the order they finish in,
+	// so comments list projects in the same order every time.
+	results := make([]command.ProjectResult, len(cmds))
 
 	wg := sizedwaitgroup.New(poolSize)
-	for _, pCmd := range cmds {
-		pCmd := pCmd
+	for i, pCmd := range cmds {
+		i, pCmd := i, pCmd
 		var execute func()
 		wg.Add()
 
 		execute = func() {
 			defer wg.Done()
-			res := runnerFunc(pCmd)
-			mux.Lock()
-			results = append(results, res)
-			mux.Unlock()
+			results[i] = runProjectCmd(pCmd, runnerFunc)
 		}
 
 		go execute()
@@ -45,13 +43,63 @@ func runProjectCmds(
 ) command.Result {
 	var results []command.ProjectResult
 	for _, pCmd := range cmds {
-		res := runnerFunc(pCmd)
+		res := runProjectCmd(pCmd, runnerFunc)
 
 		results = append(results, res)
 	}
 	return command.Result{ProjectResults: results}
 }
 
+// runProjectCmd runs pCmd with runnerFunc and records whether it succeeded
+// for the run's other projects.
+func runProjectCmd(pCmd command.ProjectContext, runnerFunc prjCmdRunnerFunc) (res command.ProjectResult) {
+	span := pCmd.Span.StartChild("project " + pCmd.CommandName.String())
+	span.SetAttribute("atlantis.project", pCmd.ProjectName)
+	span.SetAttribute("atlantis.dir", pCmd.RepoRelDir)
+	span.SetAttribute("atlantis.workspace", pCmd.Workspace)
+	pCmd.Span = span
+	// The span is ended even if the command panics so it's not lost.
+	outcome := "error"
+	var err error = errors.New("panicked")
+	defer func() {
+		span.SetAttribute("atlantis.outcome", outcome)
+		span.End(err)
+	}()
+
+	if abortedBy, ok := projectAborted(pCmd); ok {
+		res = command.ProjectResult{
+			Command:     pCmd.CommandName,
+			RepoRelDir:  pCmd.RepoRelDir,
+			Workspace:   pCmd.Workspace,
+			ProjectName: pCmd.ProjectName,
+			Error:       abortedErr(abortedBy),
+		}
+	} else {
+		res = runnerFunc(pCmd)
+	}
+	switch {
+	case res.Error != nil:
+		err = res.Error
+	case res.Failure != "":
+		outcome, err = "failure", errors.New(res.Failure)
+	default:
+		outcome, err = "success", nil
+	}
+	if pCmd.ProjectOutcomes != nil {
+		pCmd.ProjectOutcomes.Record(res)
+	}
+	return res
+}
+
+// projectAborted returns the description of the project whose fatal step
+// aborted pCmd's run and true if the run was aborted, so pCmd isn't started.
+func projectAborted(pCmd command.ProjectContext) (string, bool) {
+	if pCmd.ProjectOutcomes == nil {
+		return "", false
+	}
+	return pCmd.ProjectOutcomes.Aborted()
+}
+
 func splitByExecutionOrderGroup(cmds []command.ProjectContext) [][]command.ProjectContext {
 	groups := make(map[int][]command.ProjectContext)
 	for _, cmd := range cmds {
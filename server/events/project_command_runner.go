@@ -18,7 +18,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
@@ -29,6 +31,7 @@ import (
 	"github.com/runatlantis/atlantis/server/events/vcs"
 	"github.com/runatlantis/atlantis/server/events/webhooks"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/utils"
 )
 
 const OperationComplete = true
@@ -64,8 +67,8 @@ type StepRunner interface {
 
 // CustomStepRunner runs custom run steps.
 type CustomStepRunner interface {
-	// Run cmd in path.
-	Run(ctx command.ProjectContext, cmd string, path string, envs map[string]string, streamOutput bool, postProcessOutput valid.PostProcessRunOutputOption) (string, error)
+	// Run the step's command in path.
+	Run(ctx command.ProjectContext, step valid.Step, path string, envs map[string]string, streamOutput bool) (string, error)
 }
 
 //go:generate pegomock generate --package mocks -o mocks/mock_env_step_runner.go EnvStepRunner
@@ -75,10 +78,17 @@ type EnvStepRunner interface {
 	Run(ctx command.ProjectContext, cmd string, value string, path string, envs map[string]string) (string, error)
 }
 
+// SSMEnvStepRunner runs env steps that load AWS SSM parameters.
+type SSMEnvStepRunner interface {
+	// Run sets envs from the parameters under ssmPath and returns the values
+	// that must be masked in output.
+	Run(ctx command.ProjectContext, ssmPath string, envs map[string]string) ([]string, error)
+}
+
 // MultiEnvStepRunner runs multienv steps.
 type MultiEnvStepRunner interface {
-	// Run cmd in path.
-	Run(ctx command.ProjectContext, cmd string, path string, envs map[string]string) (string, error)
+	// Run step's command in path and set envs from its output.
+	Run(ctx command.ProjectContext, step valid.Step, path string, envs map[string]string) (string, error)
 }
 
 //go:generate pegomock generate --package mocks -o mocks/mock_webhooks_sender.go WebhooksSender
@@ -200,25 +210,50 @@ func (p *ProjectOutputWrapper) updateProjectPRStatus(commandName command.Name, c
 
 // DefaultProjectCommandRunner implements ProjectCommandRunner.
 type DefaultProjectCommandRunner struct {
-	VcsClient                 vcs.Client
-	Locker                    ProjectLocker
-	LockURLGenerator          LockURLGenerator
-	InitStepRunner            StepRunner
-	PlanStepRunner            StepRunner
-	ShowStepRunner            StepRunner
-	ApplyStepRunner           StepRunner
-	PolicyCheckStepRunner     StepRunner
-	VersionStepRunner         StepRunner
-	ImportStepRunner          StepRunner
-	StateRmStepRunner         StepRunner
-	RunStepRunner             CustomStepRunner
-	EnvStepRunner             EnvStepRunner
+	VcsClient             vcs.Client
+	Locker                ProjectLocker
+	LockURLGenerator      LockURLGenerator
+	InitStepRunner        StepRunner
+	PlanStepRunner        StepRunner
+	ShowStepRunner        StepRunner
+	ApplyStepRunner       StepRunner
+	PolicyCheckStepRunner StepRunner
+	VersionStepRunner     StepRunner
+	ImportStepRunner      StepRunner
+	StateRmStepRunner     StepRunner
+	RunStepRunner         CustomStepRunner
+	EnvStepRunner         EnvStepRunner
+	SSMEnvStepRunner      SSMEnvStepRunner
+	// SecretStore resolves the ${{ secrets.NAME }} expressions in the values
+	// of env steps. If nil they're an error.
+	SecretStore               runtime.SecretStore
 	MultiEnvStepRunner        MultiEnvStepRunner
 	PullApprovedChecker       runtime.PullApprovedChecker
 	WorkingDir                WorkingDir
 	Webhooks                  WebhooksSender
 	WorkingDirLocker          WorkingDirLocker
 	CommandRequirementHandler CommandRequirementHandler
+	// SeparateStepComments posts the output of run steps with comment_mode
+	// separate. If nil their output is added to the command's comment.
+	SeparateStepComments *SeparateStepComments
+	// ReviewStepComments posts the annotations in the output of run steps
+	// with thread review as review comments. If nil the output is commented
+	// as usual.
+	ReviewStepComments *ReviewStepComments
+	// PlanEncryptor encrypts plans and their JSON at rest. They're decrypted
+	// while steps run. If nil plans aren't encrypted.
+	PlanEncryptor *runtime.PlanEncryptor
+	// ApplyQueue queues applies run with apply --queue on projects locked by
+	// other pull requests. Only projects with the on_apply repo_locks mode can
+	// be queued. If nil they fail like other applies.
+	ApplyQueue *ApplyQueue
+	// CancelGracePeriod is how long the on_cancel steps of a cancelled run
+	// are given to finish. If 0 it's DefaultCancelGracePeriod.
+	CancelGracePeriod time.Duration
+	// RunStepFixtures, if set, are the fixtures the RunStepRunner records or
+	// replays. When replaying, workflows with steps that can't be replayed
+	// fail instead of running them.
+	RunStepFixtures *runtime.RunStepFixtures
 }
 
 // Plan runs terraform plan for the project described by ctx.
@@ -232,6 +267,7 @@ func (p *DefaultProjectCommandRunner) Plan(ctx command.ProjectContext) command.P
 		RepoRelDir:  ctx.RepoRelDir,
 		Workspace:   ctx.Workspace,
 		ProjectName: ctx.ProjectName,
+		PlanOnly:    ctx.PlanOnly,
 	}
 }
 
@@ -251,12 +287,13 @@ func (p *DefaultProjectCommandRunner) PolicyCheck(ctx command.ProjectContext) co
 
 // Apply runs terraform apply for the project described by ctx.
 func (p *DefaultProjectCommandRunner) Apply(ctx command.ProjectContext) command.ProjectResult {
-	applyOut, failure, err := p.doApply(ctx)
+	applyOut, applyState, failure, err := p.doApply(ctx)
 	return command.ProjectResult{
 		Command:      command.Apply,
 		Failure:      failure,
 		Error:        err,
 		ApplySuccess: applyOut,
+		ApplyState:   applyState,
 		RepoRelDir:   ctx.RepoRelDir,
 		Workspace:    ctx.Workspace,
 		ProjectName:  ctx.ProjectName,
@@ -552,9 +589,16 @@ func (p *DefaultProjectCommandRunner) doPlan(ctx command.ProjectContext) (*model
 	}
 	defer unlockFn()
 
-	p.WorkingDir.SetCheckForUpstreamChanges()
-	// Clone is idempotent so okay to run even if the repo was already cloned.
-	repoDir, mergedAgain, cloneErr := p.WorkingDir.Clone(ctx.Log, ctx.HeadRepo, ctx.Pull, ctx.Workspace)
+	var repoDir string
+	var mergedAgain bool
+	var cloneErr error
+	if ctx.PlanRef != "" {
+		repoDir, cloneErr = p.WorkingDir.CloneRef(ctx.Log, ctx.HeadRepo, ctx.Pull, ctx.Workspace, ctx.PlanRef)
+	} else {
+		p.WorkingDir.SetCheckForUpstreamChanges()
+		// Clone is idempotent so okay to run even if the repo was already cloned.
+		repoDir, mergedAgain, cloneErr = p.WorkingDir.Clone(ctx.Log, ctx.HeadRepo, ctx.Pull, ctx.Workspace)
+	}
 	if cloneErr != nil {
 		if unlockErr := lockAttempt.UnlockFn(); unlockErr != nil {
 			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
@@ -571,7 +615,18 @@ func (p *DefaultProjectCommandRunner) doPlan(ctx command.ProjectContext) (*model
 		return nil, failure, err
 	}
 
-	outputs, err := p.runSteps(ctx.Steps, ctx, projAbsPath)
+	// The new plan replaces a pinned one so it isn't pinned anymore.
+	if err := runtime.UnpinPlan(filepath.Join(projAbsPath, runtime.GetPlanFilename(ctx.Workspace, ctx.ProjectName))); err != nil {
+		ctx.Log.Warn("failed to unpin the plan being replaced: %s", err)
+	}
+
+	steps := ctx.Steps
+	if ctx.PlanSummary && (len(steps) == 0 || steps[len(steps)-1].StepName != "show") {
+		// The summary is read from the plan's JSON, which the workflow
+		// might not write.
+		steps = append(append([]valid.Step(nil), steps...), valid.Step{StepName: "show"})
+	}
+	outputs, err := p.runSteps(steps, ctx, projAbsPath)
 
 	if err != nil {
 		if unlockErr := lockAttempt.UnlockFn(); unlockErr != nil {
@@ -580,56 +635,123 @@ func (p *DefaultProjectCommandRunner) doPlan(ctx command.ProjectContext) (*model
 		return nil, "", fmt.Errorf("%s\n%s", err, strings.Join(outputs, "\n"))
 	}
 
-	return &models.PlanSuccess{
+	// Record the plan_ttl so the plan stops being pending once it expires,
+	// even if the config changes.
+	if err := runtime.RecordPlanTTL(filepath.Join(projAbsPath, runtime.GetPlanFilename(ctx.Workspace, ctx.ProjectName)), ctx.PlanTTL); err != nil {
+		ctx.Log.Warn("failed to record the plan's plan_ttl: %s", err)
+	}
+	// Record the ref so the plan can't be applied as the pull request's.
+	if err := runtime.RecordPlanRef(filepath.Join(projAbsPath, runtime.GetPlanFilename(ctx.Workspace, ctx.ProjectName)), ctx.PlanRef); err != nil {
+		if unlockErr := lockAttempt.UnlockFn(); unlockErr != nil {
+			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
+		}
+		return nil, "", errors.Wrap(err, "recording the planned ref")
+	}
+
+	planSuccess := &models.PlanSuccess{
 		LockURL:         p.LockURLGenerator.GenerateLockURL(lockAttempt.LockKey),
 		TerraformOutput: strings.Join(outputs, "\n"),
 		RePlanCmd:       ctx.RePlanCmd,
 		ApplyCmd:        ctx.ApplyCmd,
 		MergedAgain:     mergedAgain,
-	}, "", nil
+		PlanRef:         ctx.PlanRef,
+	}
+	if ctx.PlanSummary {
+		planSuccess.ResourceSummary = p.planResourceSummary(ctx, projAbsPath)
+	}
+	return planSuccess, "", nil
 }
 
-func (p *DefaultProjectCommandRunner) doApply(ctx command.ProjectContext) (applyOut string, failure string, err error) {
+// planResourceSummary returns the table of the resources the project's plan
+// changes for plan --summary, from the plan's JSON. If it can't be read the
+// summary says why instead of failing the plan.
+func (p *DefaultProjectCommandRunner) planResourceSummary(ctx command.ProjectContext, absPath string) *models.PlanResourceSummary {
+	planInfo, err := os.Stat(filepath.Join(absPath, runtime.GetPlanFilename(ctx.Workspace, ctx.ProjectName)))
+	if err != nil {
+		return &models.PlanResourceSummary{Unavailable: "the plan wasn't saved, ex. because it's a remote plan."}
+	}
+	showFile := filepath.Join(absPath, ctx.GetShowResultFileName())
+	if showInfo, err := os.Stat(showFile); err != nil || showInfo.ModTime().Before(planInfo.ModTime()) {
+		return &models.PlanResourceSummary{Unavailable: "the plan's JSON wasn't written, ex. because the Terraform version doesn't support terraform show -json."}
+	}
+	planJSON, err := p.PlanEncryptor.ReadFile(showFile)
+	if err == nil {
+		var summary *models.PlanResourceSummary
+		if summary, err = models.NewPlanResourceSummary(planJSON); err == nil {
+			return summary
+		}
+	}
+	ctx.Log.Warn("reading plan summary: %s", err)
+	return &models.PlanResourceSummary{Unavailable: "the plan's JSON couldn't be read, see the Atlantis logs."}
+}
+
+func (p *DefaultProjectCommandRunner) doApply(ctx command.ProjectContext) (applyOut string, applyState *models.StateVersion, failure string, err error) {
+	if ctx.PlanOnly {
+		return "", nil, "This project sets lock: false so it's plan-only and can't be applied.", nil
+	}
+	// With the other modes the project is locked when it's planned, so it
+	// can't have a plan to apply while another pull request locks it.
+	if ctx.QueueApply && ctx.RepoLocksMode != valid.RepoLocksOnApplyMode {
+		return "", nil, fmt.Sprintf("`apply --queue` can only be used on projects with `repo_locks` mode `%s`, this project's mode is `%s`.", valid.RepoLocksOnApplyMode, ctx.RepoLocksMode), nil
+	}
 	repoDir, err := p.WorkingDir.GetWorkingDir(ctx.Pull.BaseRepo, ctx.Pull, ctx.Workspace)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return "", "", errors.New("project has not been cloned–did you run plan?")
+			return "", nil, "", errors.New("project has not been cloned–did you run plan?")
 		}
-		return "", "", err
+		return "", nil, "", err
 	}
 	absPath := filepath.Join(repoDir, ctx.RepoRelDir)
 	if _, err = os.Stat(absPath); os.IsNotExist(err) {
-		return "", "", DirNotExistErr{RepoRelDir: ctx.RepoRelDir}
+		return "", nil, "", DirNotExistErr{RepoRelDir: ctx.RepoRelDir}
+	}
+
+	failure, err = discardExpiredPlan(ctx, absPath)
+	if failure != "" || err != nil {
+		return "", nil, failure, err
+	}
+	if ref := runtime.PlanRef(filepath.Join(absPath, runtime.GetPlanFilename(ctx.Workspace, ctx.ProjectName))); ref != "" {
+		return "", nil, fmt.Sprintf("This plan was made from ref `%s` with `plan --ref` instead of the pull request's head, so it can't be applied. Run `atlantis plan` to plan the pull request.", ref), nil
 	}
 
 	failure, err = p.CommandRequirementHandler.ValidateApplyProject(repoDir, ctx)
 	if failure != "" || err != nil {
-		return "", failure, err
+		return "", nil, failure, err
 	}
 
 	failure, err = p.CommandRequirementHandler.ValidateProjectDependencies(ctx)
 	if failure != "" || err != nil {
-		return "", failure, err
+		return "", nil, failure, err
 	}
 
 	// Acquire Atlantis lock for this repo/dir/workspace.
 	lockAttempt, err := p.Locker.TryLock(ctx.Log, ctx.Pull, ctx.User, ctx.Workspace, models.NewProject(ctx.Pull.BaseRepo.FullName, ctx.RepoRelDir, ctx.ProjectName), ctx.RepoLocksMode == valid.RepoLocksOnApplyMode)
 	if err != nil {
-		return "", "", errors.Wrap(err, "acquiring lock")
+		return "", nil, "", errors.Wrap(err, "acquiring lock")
 	}
 	if !lockAttempt.LockAcquired {
-		return "", lockAttempt.LockFailureReason, nil
+		if ctx.QueueApply && p.ApplyQueue != nil && lockAttempt.LockKey != "" {
+			return "", nil, p.queueApply(ctx, lockAttempt.LockKey), nil
+		}
+		return "", nil, lockAttempt.LockFailureReason, nil
 	}
 	ctx.Log.Debug("acquired lock for project")
 
 	// Acquire internal lock for the directory we're going to operate in.
 	unlockFn, err := p.WorkingDirLocker.TryLock(ctx.Pull.BaseRepo.FullName, ctx.Pull.Num, ctx.Workspace, ctx.RepoRelDir)
 	if err != nil {
-		return "", "", err
+		return "", nil, "", err
 	}
 	defer unlockFn()
 
+	// Remove the state version of an earlier apply so it isn't shown if it
+	// can't be read after this one.
+	stateVersionFile := filepath.Join(absPath, ctx.GetStateVersionFileName())
+	if err := utils.RemoveIgnoreNonExistent(stateVersionFile); err != nil {
+		ctx.Log.Warn("failed to delete the state version of an earlier apply: %s", err)
+	}
 	outputs, err := p.runSteps(ctx.Steps, ctx, absPath)
+	unlockIfTimedOut(ctx, lockAttempt.UnlockFn, err)
 
 	p.Webhooks.Send(ctx.Log, webhooks.ApplyResult{ // nolint: errcheck
 		Workspace: ctx.Workspace,
@@ -641,10 +763,92 @@ func (p *DefaultProjectCommandRunner) doApply(ctx command.ProjectContext) (apply
 	})
 
 	if err != nil {
-		return "", "", fmt.Errorf("%s\n%s", err, strings.Join(outputs, "\n"))
+		return "", nil, "", fmt.Errorf("%s\n%s", err, strings.Join(outputs, "\n"))
 	}
 
-	return strings.Join(outputs, "\n"), "", nil
+	return strings.Join(outputs, "\n"), readStateVersion(ctx, stateVersionFile), "", nil
+}
+
+// queueApply queues the apply of the project, locked by another pull request
+// with the lock key lockKey, and returns the failure commented on the pull
+// request with its position in the queue.
+func (p *DefaultProjectCommandRunner) queueApply(ctx command.ProjectContext, lockKey string) string {
+	cmd := &CommentCommand{
+		Name:        command.Apply,
+		Verbose:     ctx.Verbose,
+		ProjectName: ctx.ProjectName,
+		Reason:      ctx.ApplyReason,
+		Queue:       true,
+	}
+	if ctx.ProjectName == "" {
+		cmd.RepoRelDir = ctx.RepoRelDir
+		cmd.Workspace = ctx.Workspace
+	}
+	position, length, err := p.ApplyQueue.Enqueue(lockKey, QueuedApply{
+		Pull: ctx.Pull,
+		User: ctx.User,
+		Cmd:  cmd,
+	})
+	if err != nil {
+		return fmt.Sprintf("This project is locked by another pull request and the apply couldn't be queued: %s.", err)
+	}
+	ctx.Log.Info("queued apply at position %d of %d", position, length)
+	return fmt.Sprintf("This project is locked by another pull request. The apply was queued at position %d of %d and will run when the lock is released.", position, length)
+}
+
+// readStateVersion returns the version of the state written to
+// stateVersionFile by the apply step, or nil if it wasn't written.
+func readStateVersion(ctx command.ProjectContext, stateVersionFile string) *models.StateVersion {
+	content, err := os.ReadFile(stateVersionFile) // nolint: gosec
+	if err != nil {
+		if !os.IsNotExist(err) {
+			ctx.Log.Warn("failed to read the state version: %s", err)
+		}
+		return nil
+	}
+	var state models.StateVersion
+	if err := json.Unmarshal(content, &state); err != nil {
+		ctx.Log.Warn("failed to parse the state version %q: %s", stateVersionFile, err)
+		return nil
+	}
+	return &state
+}
+
+// discardExpiredPlan deletes the project's planfile if it's older than the
+// project's plan TTL so it can't be applied, and returns a failure asking to
+// plan again. Pinned plans are never discarded.
+func discardExpiredPlan(ctx command.ProjectContext, absPath string) (failure string, err error) {
+	if ctx.PlanTTL == 0 {
+		return "", nil
+	}
+	planFile := filepath.Join(absPath, runtime.GetPlanFilename(ctx.Workspace, ctx.ProjectName))
+	info, err := os.Stat(planFile)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "checking plan age")
+	}
+	if user, pinned := runtime.PlanPinnedBy(planFile); pinned {
+		ctx.Log.Debug("not discarding plan pinned by %s", user)
+		return "", nil
+	}
+	age := time.Since(info.ModTime())
+	if age <= ctx.PlanTTL {
+		return "", nil
+	}
+	if err := os.Remove(planFile); err != nil {
+		return "", errors.Wrap(err, "discarding expired plan")
+	}
+	if err := runtime.RemovePlanTTL(planFile); err != nil {
+		return "", errors.Wrap(err, "discarding expired plan")
+	}
+	ctx.Log.Info("discarded plan that was %s old, older than plan_ttl %s", age.Round(time.Second), ctx.PlanTTL)
+	planCmd := ctx.RePlanCmd
+	if planCmd == "" {
+		planCmd = "atlantis plan"
+	}
+	return fmt.Sprintf("This plan was discarded because it's older than the project's plan_ttl of %s. Run `%s` to plan again.", ctx.PlanTTL, planCmd), nil
 }
 
 func (p *DefaultProjectCommandRunner) doVersion(ctx command.ProjectContext) (versionOut string, failure string, err error) {
@@ -710,6 +914,7 @@ func (p *DefaultProjectCommandRunner) doImport(ctx command.ProjectContext) (out
 
 	outputs, err := p.runSteps(ctx.Steps, ctx, projAbsPath)
 	if err != nil {
+		unlockIfTimedOut(ctx, lockAttempt.UnlockFn, err)
 		return nil, "", fmt.Errorf("%s\n%s", err, strings.Join(outputs, "\n"))
 	}
 
@@ -751,6 +956,7 @@ func (p *DefaultProjectCommandRunner) doStateRm(ctx command.ProjectContext) (out
 
 	outputs, err := p.runSteps(ctx.Steps, ctx, projAbsPath)
 	if err != nil {
+		unlockIfTimedOut(ctx, lockAttempt.UnlockFn, err)
 		return nil, "", fmt.Errorf("%s\n%s", err, strings.Join(outputs, "\n"))
 	}
 
@@ -762,48 +968,368 @@ func (p *DefaultProjectCommandRunner) doStateRm(ctx command.ProjectContext) (out
 	}, "", nil
 }
 
+// runSteps runs steps with the project's plan and its JSON decrypted if plans
+// are encrypted at rest, and encrypts them again, including any the steps
+// wrote, once the steps finished. If the project's workflow has a timeout the
+// steps are cancelled once it's exceeded.
 func (p *DefaultProjectCommandRunner) runSteps(steps []valid.Step, ctx command.ProjectContext, absPath string) ([]string, error) {
+	if ctx.WorkflowTimeout > 0 {
+		ctx.Deadline = time.Now().Add(ctx.WorkflowTimeout)
+	}
+	planFiles := []string{
+		filepath.Join(absPath, runtime.GetPlanFilename(ctx.Workspace, ctx.ProjectName)),
+		filepath.Join(absPath, ctx.GetShowResultFileName()),
+	}
+	if err := p.PlanEncryptor.Decrypt(planFiles...); err != nil {
+		return nil, errors.Wrap(err, "decrypting plan")
+	}
+	outputs, err := p.doRunSteps(steps, ctx, absPath)
+	if encryptErr := p.PlanEncryptor.Encrypt(planFiles...); encryptErr != nil {
+		if err != nil {
+			ctx.Log.Err("encrypting plan: %s", encryptErr)
+			return outputs, err
+		}
+		return outputs, errors.Wrap(encryptErr, "encrypting plan")
+	}
+	return outputs, err
+}
+
+func (p *DefaultProjectCommandRunner) doRunSteps(steps []valid.Step, ctx command.ProjectContext, absPath string) ([]string, error) {
 	var outputs []string
 
 	envs := make(map[string]string)
-	for _, step := range steps {
+	// commentSecrets are masked in the output of every step and logSecrets in
+	// the logs of every step. Both are masked in the job output shown in the
+	// UI, which can be as widely visible as comments. They're values loaded
+	// from SSM SecureString parameters and secrets referenced by env steps,
+	// which are masked in both, and values set by env steps, masked as set by
+	// their mask_in option.
+	var commentSecrets, logSecrets []string
+	log := ctx.Log
+	if ctx.CLIConfig != "" {
+		repoDir := strings.TrimSuffix(absPath, ctx.RepoRelDir)
+		cliConfigFile, err := runtime.ResolveCLIConfigFile(repoDir, absPath, ctx.CLIConfig)
+		if err != nil {
+			return outputs, err
+		}
+		ctx.CLIConfigFile = cliConfigFile
+	}
+	// The project's tf_vars are set first so env steps and -var flags
+	// override them.
+	tfVarNames := make([]string, 0, len(ctx.TFVars))
+	for name := range ctx.TFVars {
+		tfVarNames = append(tfVarNames, name)
+	}
+	sort.Strings(tfVarNames)
+	for _, name := range tfVarNames {
+		value, secrets, err := runtime.ResolveEnvSecrets(ctx.TFVars[name], p.SecretStore)
+		commentSecrets = append(commentSecrets, secrets...)
+		logSecrets = append(logSecrets, secrets...)
+		if err != nil {
+			return outputs, fmt.Errorf("setting tf_vars variable %q: %w", name, err)
+		}
+		envs["TF_VAR_"+name] = value
+	}
+	if err := p.RunStepFixtures.CheckReplayable(steps); err != nil {
+		return outputs, err
+	}
+	for i, step := range steps {
+		ctx.StepIndex = i
+		if ctx.ProjectOutcomes != nil {
+			if abortedBy, ok := ctx.ProjectOutcomes.Aborted(); ok {
+				return outputs, abortedErr(abortedBy)
+			}
+		}
+		if cancelled(ctx) {
+			p.runOnCancel(ctx, absPath)
+			return outputs, cancelledErr(nil)
+		}
+		if timedOut(ctx) {
+			p.runOnCancel(ctx, absPath)
+			return outputs, timeoutErr(ctx, nil)
+		}
+		extraArgs, err := p.stepExtraArgs(step, ctx, absPath)
+		if err != nil {
+			return outputs, err
+		}
+		ctx.OutputSecrets = unionSecrets(commentSecrets, logSecrets)
+		stepCtx := ctx
+		if step.CommentArgsPosition == valid.CommentArgsPrepend {
+			extraArgs, stepCtx = prependCommentArgs(extraArgs, ctx)
+		}
+
+		stepSpan := ctx.Span.StartChild("step " + step.StepName)
+		stepSpan.SetAttribute("atlantis.step", step.StepName)
+		if stepSpan != nil {
+			// Lets commands that support it, like terraform, continue the
+			// trace.
+			envs["TRACEPARENT"] = stepSpan.Traceparent()
+		}
+
 		var out string
-		var err error
 		switch step.StepName {
 		case "init":
-			out, err = p.InitStepRunner.Run(ctx, step.ExtraArgs, absPath, envs)
+			out, err = p.InitStepRunner.Run(ctx, extraArgs, absPath, envs)
 		case "plan":
-			out, err = p.PlanStepRunner.Run(ctx, step.ExtraArgs, absPath, envs)
+			out, err = p.PlanStepRunner.Run(stepCtx, extraArgs, absPath, envs)
 		case "show":
-			_, err = p.ShowStepRunner.Run(ctx, step.ExtraArgs, absPath, envs)
+			_, err = p.ShowStepRunner.Run(ctx, extraArgs, absPath, envs)
 		case "policy_check":
-			out, err = p.PolicyCheckStepRunner.Run(ctx, step.ExtraArgs, absPath, envs)
+			out, err = p.PolicyCheckStepRunner.Run(ctx, extraArgs, absPath, envs)
 		case "apply":
-			out, err = p.ApplyStepRunner.Run(ctx, step.ExtraArgs, absPath, envs)
+			out, err = p.ApplyStepRunner.Run(stepCtx, extraArgs, absPath, envs)
 		case "version":
-			out, err = p.VersionStepRunner.Run(ctx, step.ExtraArgs, absPath, envs)
+			out, err = p.VersionStepRunner.Run(ctx, extraArgs, absPath, envs)
 		case "import":
-			out, err = p.ImportStepRunner.Run(ctx, step.ExtraArgs, absPath, envs)
+			out, err = p.ImportStepRunner.Run(stepCtx, extraArgs, absPath, envs)
 		case "state_rm":
-			out, err = p.StateRmStepRunner.Run(ctx, step.ExtraArgs, absPath, envs)
+			out, err = p.StateRmStepRunner.Run(stepCtx, extraArgs, absPath, envs)
 		case "run":
-			out, err = p.RunStepRunner.Run(ctx, step.RunCommand, absPath, envs, true, step.Output)
+			out, err = p.RunStepRunner.Run(ctx, step, absPath, envs, true)
 		case "env":
-			out, err = p.EnvStepRunner.Run(ctx, step.RunCommand, step.EnvVarValue, absPath, envs)
+			if step.SSMPath != "" {
+				var stepSecrets []string
+				stepSecrets, err = p.SSMEnvStepRunner.Run(ctx, step.SSMPath, envs)
+				commentSecrets = append(commentSecrets, stepSecrets...)
+				logSecrets = append(logSecrets, stepSecrets...)
+				break
+			}
+			value, stepSecrets, secretErr := runtime.ResolveEnvSecrets(step.EnvVarValue, p.SecretStore)
+			commentSecrets = append(commentSecrets, stepSecrets...)
+			logSecrets = append(logSecrets, stepSecrets...)
+			if secretErr != nil {
+				err = fmt.Errorf("setting env %q: %w", step.EnvVarName, secretErr)
+				break
+			}
+			out, err = p.EnvStepRunner.Run(ctx, step.RunCommand, value, absPath, envs)
 			envs[step.EnvVarName] = out
+			if err == nil && runtime.MaskableValue(out) {
+				if step.MaskInComment {
+					commentSecrets = append(commentSecrets, out)
+				}
+				if step.MaskInLog {
+					logSecrets = append(logSecrets, out)
+				}
+			}
 			// We reset out to the empty string because we don't want it to
 			// be printed to the PR, it's solely to set the environment variable.
 			out = ""
 		case "multienv":
-			out, err = p.MultiEnvStepRunner.Run(ctx, step.RunCommand, absPath, envs)
+			out, err = p.MultiEnvStepRunner.Run(ctx, step, absPath, envs)
 		}
 
+		// The error is commented on the pull request too.
+		if len(commentSecrets) > 0 {
+			secrets := commentSecrets
+			if step.LinePrefix != "" {
+				secrets = runtime.LinePrefixedSecrets(commentSecrets, step.LinePrefix)
+			}
+			out = utils.MaskSecrets(out, secrets)
+			if err != nil {
+				err = errors.New(utils.MaskSecrets(err.Error(), secrets))
+			}
+		}
+		stepSpan.End(err)
+		ctx.Log = runtime.NewMaskingLogger(log, logSecrets)
+		if err == nil && out != "" && step.CommentMode == valid.CommentModeSeparate && p.SeparateStepComments != nil {
+			out = p.SeparateStepComments.Post(ctx, step, out)
+		}
+		if err == nil && out != "" && step.Thread == valid.ThreadReview && p.ReviewStepComments != nil {
+			out = p.ReviewStepComments.Post(ctx, step, out)
+		}
 		if out != "" {
 			outputs = append(outputs, out)
 		}
 		if err != nil {
+			if step.Fatal {
+				if ctx.ProjectOutcomes != nil {
+					ctx.ProjectOutcomes.Abort(fatalProjectDescription(ctx))
+				}
+				return outputs, fmt.Errorf("fatal step %q failed, aborting the run without cleanup: %w", step.RunCommand, err)
+			}
+			// The step's commands were killed at the deadline.
+			if timedOut(ctx) {
+				p.runOnCancel(ctx, absPath)
+				return outputs, timeoutErr(ctx, err)
+			}
+			// The step's commands were interrupted because the run was
+			// cancelled.
+			if cancelled(ctx) {
+				p.runOnCancel(ctx, absPath)
+				return outputs, cancelledErr(err)
+			}
 			return outputs, err
 		}
 	}
 	return outputs, nil
 }
+
+// unionSecrets returns the secrets in either a or b, without duplicates.
+func unionSecrets(a []string, b []string) []string {
+	var union []string
+	seen := make(map[string]bool, len(a)+len(b))
+	for _, secret := range append(append([]string(nil), a...), b...) {
+		if !seen[secret] {
+			seen[secret] = true
+			union = append(union, secret)
+		}
+	}
+	return union
+}
+
+// fatalProjectDescription describes the project of ctx in the errors of the
+// run's projects aborted by its fatal step.
+func fatalProjectDescription(ctx command.ProjectContext) string {
+	if ctx.ProjectName != "" {
+		return fmt.Sprintf("project %q", ctx.ProjectName)
+	}
+	return fmt.Sprintf("dir %q workspace %q", ctx.RepoRelDir, ctx.Workspace)
+}
+
+// abortedErr returns the error of a project whose steps were skipped because
+// a fatal step of the project described by abortedBy failed.
+func abortedErr(abortedBy string) error {
+	return fmt.Errorf("aborted because a fatal step failed in %s", abortedBy)
+}
+
+// cancelled returns true if ctx's run was cancelled because its pull request
+// was closed.
+func cancelled(ctx command.ProjectContext) bool {
+	select {
+	case <-ctx.Cancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// cancelledErr returns the error of a run of a project's steps that was
+// cancelled, with the error of the step that was interrupted if any.
+func cancelledErr(stepErr error) error {
+	if stepErr == nil {
+		return errors.New("cancelled because the pull request was closed")
+	}
+	return fmt.Errorf("cancelled because the pull request was closed, commands still running were interrupted: %s", stepErr)
+}
+
+// timedOut returns true if ctx's deadline, from its workflow's timeout, has
+// passed.
+func timedOut(ctx command.ProjectContext) bool {
+	return !ctx.Deadline.IsZero() && !time.Now().Before(ctx.Deadline)
+}
+
+// WorkflowTimeoutErr is the error of a run of a project's steps that exceeded
+// its workflow's timeout.
+type WorkflowTimeoutErr struct {
+	WorkflowName string
+	Timeout      time.Duration
+	// StepErr is the error of the step that was cancelled, if any.
+	StepErr error
+}
+
+func (w WorkflowTimeoutErr) Error() string {
+	msg := fmt.Sprintf("cancelled because the %q workflow didn't finish within its timeout of %s, commands still running were killed", w.WorkflowName, w.Timeout)
+	if w.StepErr == nil {
+		return msg
+	}
+	return fmt.Sprintf("%s: %s", msg, w.StepErr)
+}
+
+// timeoutErr returns the error of a run of ctx's steps that exceeded its
+// workflow's timeout, with the error of the step that was cancelled if any.
+func timeoutErr(ctx command.ProjectContext, stepErr error) error {
+	return WorkflowTimeoutErr{WorkflowName: ctx.WorkflowName, Timeout: ctx.WorkflowTimeout, StepErr: stepErr}
+}
+
+// unlockIfTimedOut releases the project lock with unlock if err is from the
+// run exceeding its workflow's timeout, so a stuck run doesn't keep the
+// project locked. Other failures keep the lock so the command can be retried.
+func unlockIfTimedOut(ctx command.ProjectContext, unlock func() error, err error) {
+	var wtErr WorkflowTimeoutErr
+	if !errors.As(err, &wtErr) {
+		return
+	}
+	if unlockErr := unlock(); unlockErr != nil {
+		ctx.Log.Err("error unlocking state after workflow timeout: %v", unlockErr)
+	}
+}
+
+// runOnCancel runs the on_cancel steps of the project's workflow after its run
+// is cancelled. They're best effort: they're given p.CancelGracePeriod to
+// finish, after which their commands are interrupted and then killed like at
+// a workflow's timeout, and their failures are only logged. It returns once
+// they're done so they don't outlive the working dir lock.
+func (p *DefaultProjectCommandRunner) runOnCancel(ctx command.ProjectContext, absPath string) {
+	if len(ctx.OnCancelSteps) == 0 {
+		return
+	}
+	gracePeriod := p.CancelGracePeriod
+	if gracePeriod == 0 {
+		gracePeriod = DefaultCancelGracePeriod
+	}
+	onCancelCtx := ctx
+	onCancelCtx.Cancelled = nil
+	onCancelCtx.Deadline = time.Now().Add(gracePeriod)
+	onCancelCtx.OnCancelSteps = nil
+
+	ctx.Log.Info("run was cancelled, running on_cancel steps")
+	if _, err := p.doRunSteps(ctx.OnCancelSteps, onCancelCtx, absPath); err != nil {
+		ctx.Log.Warn("on_cancel steps failed: %s", err)
+	}
+}
+
+// stepExtraArgs returns the extra args for a built-in step. Args read from
+// the step's extra_args_file are appended after the inline extra_args so the
+// resulting order is deterministic. For init, the -backend-config of the
+// project's workspace in its backend_configs comes first so the step's own
+// -backend-config args override its values. The step's parallelism is
+// appended last unless the comment sets -parallelism itself, in which case
+// the comment's flag wins.
+func (p *DefaultProjectCommandRunner) stepExtraArgs(step valid.Step, ctx command.ProjectContext, absPath string) ([]string, error) {
+	extraArgs := step.ExtraArgs
+	repoDir := strings.TrimSuffix(absPath, ctx.RepoRelDir)
+	if step.ExtraArgsFile != "" {
+		fileArgs, err := runtime.ReadExtraArgsFile(repoDir, absPath, step.ExtraArgsFile)
+		if err != nil {
+			return nil, err
+		}
+		extraArgs = make([]string, 0, len(step.ExtraArgs)+len(fileArgs))
+		extraArgs = append(extraArgs, step.ExtraArgs...)
+		extraArgs = append(extraArgs, fileArgs...)
+	}
+	if step.StepName == "init" && len(ctx.BackendConfigs) > 0 {
+		backendConfigFile, err := runtime.ResolveBackendConfigFile(repoDir, absPath, ctx.BackendConfigs, ctx.Workspace)
+		if err != nil {
+			return nil, err
+		}
+		extraArgs = append([]string{"-backend-config=" + backendConfigFile}, extraArgs...)
+	}
+	if step.Parallelism > 0 && !valid.HasParallelismFlag(extraArgs) && !valid.HasParallelismFlag(unescapeArgs(ctx.EscapedCommentArgs)) {
+		extraArgs = append(extraArgs[:len(extraArgs):len(extraArgs)], fmt.Sprintf("-parallelism=%d", step.Parallelism))
+	}
+	return extraArgs, nil
+}
+
+// unescapeArgs reverses escapeArgs.
+func unescapeArgs(args []string) []string {
+	unescaped := make([]string, 0, len(args))
+	for _, arg := range args {
+		var b strings.Builder
+		for i := 1; i < len(arg); i += 2 {
+			b.WriteByte(arg[i])
+		}
+		unescaped = append(unescaped, b.String())
+	}
+	return unescaped
+}
+
+// prependCommentArgs returns extraArgs with the args from the comment before
+// them, and a copy of ctx without the comment's args so the step runner
+// doesn't append them again.
+func prependCommentArgs(extraArgs []string, ctx command.ProjectContext) ([]string, command.ProjectContext) {
+	args := make([]string, 0, len(ctx.EscapedCommentArgs)+len(extraArgs))
+	args = append(args, ctx.EscapedCommentArgs...)
+	args = append(args, extraArgs...)
+	ctx.EscapedCommentArgs = nil
+	return args, ctx
+}
@@ -14,14 +14,19 @@
 package events_test
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-version"
 	. "github.com/petergtz/pegomock/v4"
 	"github.com/runatlantis/atlantis/server/core/config/valid"
+	lockmocks "github.com/runatlantis/atlantis/server/core/locking/mocks"
 	"github.com/runatlantis/atlantis/server/core/runtime"
 	tmocks "github.com/runatlantis/atlantis/server/core/terraform/mocks"
 	"github.com/runatlantis/atlantis/server/events"
@@ -95,11 +100,17 @@ func TestDefaultProjectCommandRunner_Plan(t *testing.T) {
 		Workspace:  "default",
 		RepoRelDir: ".",
 	}
+	// Steps are run with their index in the stage.
+	stepCtx := func(i int) command.ProjectContext {
+		c := ctx
+		c.StepIndex = i
+		return c
+	}
 	// Each step will output its step name.
-	When(mockInit.Run(ctx, nil, repoDir, expEnvs)).ThenReturn("init", nil)
-	When(mockPlan.Run(ctx, nil, repoDir, expEnvs)).ThenReturn("plan", nil)
-	When(mockApply.Run(ctx, nil, repoDir, expEnvs)).ThenReturn("apply", nil)
-	When(mockRun.Run(ctx, "", repoDir, expEnvs, true, "")).ThenReturn("run", nil)
+	When(mockInit.Run(stepCtx(4), nil, repoDir, expEnvs)).ThenReturn("init", nil)
+	When(mockPlan.Run(stepCtx(3), nil, repoDir, expEnvs)).ThenReturn("plan", nil)
+	When(mockApply.Run(stepCtx(2), nil, repoDir, expEnvs)).ThenReturn("apply", nil)
+	When(mockRun.Run(stepCtx(1), valid.Step{StepName: "run"}, repoDir, expEnvs, true)).ThenReturn("run", nil)
 	res := runner.Plan(ctx)
 
 	Assert(t, res.PlanSuccess != nil, "exp plan success")
@@ -109,17 +120,229 @@ func TestDefaultProjectCommandRunner_Plan(t *testing.T) {
 	for _, step := range expSteps {
 		switch step {
 		case "init":
-			mockInit.VerifyWasCalledOnce().Run(ctx, nil, repoDir, expEnvs)
+			mockInit.VerifyWasCalledOnce().Run(stepCtx(4), nil, repoDir, expEnvs)
 		case "plan":
-			mockPlan.VerifyWasCalledOnce().Run(ctx, nil, repoDir, expEnvs)
+			mockPlan.VerifyWasCalledOnce().Run(stepCtx(3), nil, repoDir, expEnvs)
 		case "apply":
-			mockApply.VerifyWasCalledOnce().Run(ctx, nil, repoDir, expEnvs)
+			mockApply.VerifyWasCalledOnce().Run(stepCtx(2), nil, repoDir, expEnvs)
 		case "run":
-			mockRun.VerifyWasCalledOnce().Run(ctx, "", repoDir, expEnvs, true, "")
+			mockRun.VerifyWasCalledOnce().Run(stepCtx(1), valid.Step{StepName: "run"}, repoDir, expEnvs, true)
 		}
 	}
 }
 
+// Test that plans of a ref check out the ref rather than the pull request's
+// head.
+func TestDefaultProjectCommandRunner_PlanRef(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockPlan := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	mockCommandRequirementHandler := mocks.NewMockCommandRequirementHandler()
+
+	runner := events.DefaultProjectCommandRunner{
+		Locker:                    mockLocker,
+		LockURLGenerator:          mockURLGenerator{},
+		PlanStepRunner:            mockPlan,
+		WorkingDir:                mockWorkingDir,
+		WorkingDirLocker:          events.NewDefaultWorkingDirLocker(),
+		CommandRequirementHandler: mockCommandRequirementHandler,
+	}
+
+	repoDir := t.TempDir()
+	When(mockWorkingDir.CloneRef(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+		Any[string](), Eq("origin/hotfix"))).ThenReturn(repoDir, nil)
+	When(mockLocker.TryLock(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.User](), Any[string](),
+		Any[models.Project](), AnyBool())).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key"}, nil)
+
+	ctx := command.ProjectContext{
+		Log: logging.NewNoopLogger(t),
+		Steps: []valid.Step{
+			{
+				StepName: "plan",
+			},
+		},
+		Workspace:  "default",
+		RepoRelDir: ".",
+		PlanRef:    "origin/hotfix",
+	}
+	When(mockPlan.Run(ctx, nil, repoDir, map[string]string{})).ThenReturn("plan", nil)
+	res := runner.Plan(ctx)
+
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, "plan", res.PlanSuccess.TerraformOutput)
+	Equals(t, "origin/hotfix", res.PlanSuccess.PlanRef)
+	mockWorkingDir.VerifyWasCalled(Never()).Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](), Any[string]())
+
+	// The plan of the ref can't be applied, even without --ref.
+	Ok(t, os.WriteFile(filepath.Join(repoDir, "default.tfplan"), nil, 0600))
+	When(mockWorkingDir.GetWorkingDir(Any[models.Repo](), Any[models.PullRequest](), Any[string]())).ThenReturn(repoDir, nil)
+	ctx.PlanRef = ""
+	res = runner.Apply(ctx)
+	Equals(t, "This plan was made from ref `origin/hotfix` with `plan --ref` instead of the pull request's head, so it can't be applied. Run `atlantis plan` to plan the pull request.", res.Failure)
+}
+
+// Test that comment_args_position controls whether the comment's args go
+// before or after a built-in step's extra args.
+func TestDefaultProjectCommandRunner_PlanCommentArgsPosition(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockPlan := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+
+	runner := events.DefaultProjectCommandRunner{
+		Locker:                    mockLocker,
+		LockURLGenerator:          mockURLGenerator{},
+		PlanStepRunner:            mockPlan,
+		WorkingDir:                mockWorkingDir,
+		WorkingDirLocker:          events.NewDefaultWorkingDirLocker(),
+		CommandRequirementHandler: mocks.NewMockCommandRequirementHandler(),
+	}
+
+	repoDir := t.TempDir()
+	When(mockWorkingDir.Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+		Any[string]())).ThenReturn(repoDir, false, nil)
+	When(mockLocker.TryLock(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.User](), Any[string](),
+		Any[models.Project](), AnyBool())).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key"}, nil)
+
+	ctx := command.ProjectContext{
+		Log: logging.NewNoopLogger(t),
+		Steps: []valid.Step{
+			{
+				StepName:  "plan",
+				ExtraArgs: []string{"-var-file=default.tfvars"},
+			},
+			{
+				StepName:            "plan",
+				ExtraArgs:           []string{"-var-file=default.tfvars"},
+				CommentArgsPosition: valid.CommentArgsPrepend,
+			},
+		},
+		EscapedCommentArgs: []string{"-var-file=override.tfvars"},
+		Workspace:          "default",
+		RepoRelDir:         ".",
+	}
+	prependCtx := ctx
+	prependCtx.EscapedCommentArgs = nil
+	prependCtx.StepIndex = 1
+	When(mockPlan.Run(ctx, []string{"-var-file=default.tfvars"}, repoDir, map[string]string{})).ThenReturn("append", nil)
+	When(mockPlan.Run(prependCtx, []string{"-var-file=override.tfvars", "-var-file=default.tfvars"}, repoDir, map[string]string{})).ThenReturn("prepend", nil)
+	res := runner.Plan(ctx)
+
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, "append\nprepend", res.PlanSuccess.TerraformOutput)
+}
+
+// Test that a built-in step's parallelism is added to its extra args unless
+// the comment sets -parallelism.
+func TestDefaultProjectCommandRunner_PlanParallelism(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockPlan := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+
+	runner := events.DefaultProjectCommandRunner{
+		Locker:                    mockLocker,
+		LockURLGenerator:          mockURLGenerator{},
+		PlanStepRunner:            mockPlan,
+		WorkingDir:                mockWorkingDir,
+		WorkingDirLocker:          events.NewDefaultWorkingDirLocker(),
+		CommandRequirementHandler: mocks.NewMockCommandRequirementHandler(),
+	}
+
+	repoDir := t.TempDir()
+	When(mockWorkingDir.Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+		Any[string]())).ThenReturn(repoDir, false, nil)
+	When(mockLocker.TryLock(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.User](), Any[string](),
+		Any[models.Project](), AnyBool())).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key"}, nil)
+
+	ctx := command.ProjectContext{
+		Log: logging.NewNoopLogger(t),
+		Steps: []valid.Step{
+			{
+				StepName:    "plan",
+				ExtraArgs:   []string{"-var-file=default.tfvars"},
+				Parallelism: 20,
+			},
+		},
+		Workspace:  "default",
+		RepoRelDir: ".",
+	}
+	When(mockPlan.Run(ctx, []string{"-var-file=default.tfvars", "-parallelism=20"}, repoDir, map[string]string{})).ThenReturn("step", nil)
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, "step", res.PlanSuccess.TerraformOutput)
+
+	// The comment's -parallelism wins, so the step's isn't added.
+	commentCtx := ctx
+	commentCtx.EscapedCommentArgs = []string{`\-\p\a\r\a\l\l\e\l\i\s\m\=\5`}
+	When(mockPlan.Run(commentCtx, []string{"-var-file=default.tfvars"}, repoDir, map[string]string{})).ThenReturn("comment", nil)
+	res = runner.Plan(commentCtx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, "comment", res.PlanSuccess.TerraformOutput)
+}
+
+func TestDefaultProjectCommandRunner_InitBackendConfigs(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockInit := mocks.NewMockStepRunner()
+	mockPlan := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+
+	runner := events.DefaultProjectCommandRunner{
+		Locker:                    mockLocker,
+		LockURLGenerator:          mockURLGenerator{},
+		InitStepRunner:            mockInit,
+		PlanStepRunner:            mockPlan,
+		WorkingDir:                mockWorkingDir,
+		WorkingDirLocker:          events.NewDefaultWorkingDirLocker(),
+		CommandRequirementHandler: mocks.NewMockCommandRequirementHandler(),
+	}
+
+	repoDir := t.TempDir()
+	Ok(t, os.MkdirAll(filepath.Join(repoDir, "backends"), 0700))
+	Ok(t, os.WriteFile(filepath.Join(repoDir, "backends", "staging.hcl"), nil, 0600))
+	backendConfigFile, err := filepath.EvalSymlinks(filepath.Join(repoDir, "backends", "staging.hcl"))
+	Ok(t, err)
+	When(mockWorkingDir.Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+		Any[string]())).ThenReturn(repoDir, false, nil)
+	When(mockLocker.TryLock(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.User](), Any[string](),
+		Any[models.Project](), AnyBool())).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key", UnlockFn: func() error { return nil }}, nil)
+
+	ctx := command.ProjectContext{
+		Log: logging.NewNoopLogger(t),
+		Steps: []valid.Step{
+			{
+				StepName:  "init",
+				ExtraArgs: []string{"-backend-config=key=staging.tfstate"},
+			},
+			{
+				StepName: "plan",
+			},
+		},
+		Workspace:  "staging",
+		RepoRelDir: ".",
+		BackendConfigs: map[string]string{
+			"staging":    "backends/staging.hcl",
+			"production": "backends/production.hcl",
+		},
+	}
+	When(mockInit.Run(ctx, []string{"-backend-config=" + backendConfigFile, "-backend-config=key=staging.tfstate"}, repoDir, map[string]string{})).ThenReturn("", nil)
+	planCtx := ctx
+	planCtx.StepIndex = 1
+	When(mockPlan.Run(planCtx, nil, repoDir, map[string]string{})).ThenReturn("plan", nil)
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success, got error %v", res.Error)
+	Equals(t, "plan", res.PlanSuccess.TerraformOutput)
+
+	// A workspace without a backend config isn't initialized with another's.
+	defaultCtx := ctx
+	defaultCtx.Workspace = "default"
+	res = runner.Plan(defaultCtx)
+	ErrContains(t, "backend_configs has no backend config for workspace \"default\"", res.Error)
+	mockInit.VerifyWasCalled(Never()).Run(defaultCtx, nil, repoDir, map[string]string{})
+}
+
 func TestProjectOutputWrapper(t *testing.T) {
 	RegisterMockTestingT(t)
 	ctx := command.ProjectContext{
@@ -242,6 +465,81 @@ func TestDefaultProjectCommandRunner_ApplyNotCloned(t *testing.T) {
 	ErrEquals(t, "project has not been cloned–did you run plan?", res.Error)
 }
 
+// Test that plan-only projects can't be applied.
+func TestDefaultProjectCommandRunner_ApplyPlanOnly(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	runner := &events.DefaultProjectCommandRunner{
+		WorkingDir: mockWorkingDir,
+	}
+	ctx := command.ProjectContext{PlanOnly: true}
+
+	res := runner.Apply(ctx)
+	Ok(t, res.Error)
+	Equals(t, "This project sets lock: false so it's plan-only and can't be applied.", res.Failure)
+	mockWorkingDir.VerifyWasCalled(Never()).GetWorkingDir(Any[models.Repo](), Any[models.PullRequest](), Any[string]())
+}
+
+// Test that plans older than the plan TTL are discarded instead of applied.
+func TestDefaultProjectCommandRunner_ApplyExpiredPlan(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	runner := &events.DefaultProjectCommandRunner{
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+	ctx := command.ProjectContext{
+		Log:       logging.NewNoopLogger(t),
+		Workspace: "default",
+		PlanTTL:   time.Hour,
+		RePlanCmd: "atlantis plan -d .",
+	}
+	tmp := t.TempDir()
+	planFile := filepath.Join(tmp, "default.tfplan")
+	Ok(t, os.WriteFile(planFile, nil, 0600))
+	old := time.Now().Add(-2 * time.Hour)
+	Ok(t, os.Chtimes(planFile, old, old))
+	When(mockWorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ctx.Workspace)).ThenReturn(tmp, nil)
+
+	res := runner.Apply(ctx)
+	Ok(t, res.Error)
+	Equals(t, "This plan was discarded because it's older than the project's plan_ttl of 1h0m0s. Run `atlantis plan -d .` to plan again.", res.Failure)
+	_, err := os.Stat(planFile)
+	Assert(t, os.IsNotExist(err), "exp expired plan to be deleted")
+}
+
+// Test that pinned plans aren't discarded when they're older than the plan TTL.
+func TestDefaultProjectCommandRunner_ApplyExpiredPinnedPlan(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	runner := &events.DefaultProjectCommandRunner{
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+		CommandRequirementHandler: &events.DefaultCommandRequirementHandler{
+			WorkingDir: mockWorkingDir,
+		},
+	}
+	ctx := command.ProjectContext{
+		Log:               logging.NewNoopLogger(t),
+		Workspace:         "default",
+		PlanTTL:           time.Hour,
+		ApplyRequirements: []string{"approved"},
+	}
+	tmp := t.TempDir()
+	Ok(t, os.Mkdir(filepath.Join(tmp, ".git"), 0700))
+	planFile := filepath.Join(tmp, "default.tfplan")
+	Ok(t, os.WriteFile(planFile, nil, 0600))
+	Ok(t, runtime.PinPlan(planFile, "lkysow"))
+	old := time.Now().Add(-2 * time.Hour)
+	Ok(t, os.Chtimes(planFile, old, old))
+	When(mockWorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ctx.Workspace)).ThenReturn(tmp, nil)
+
+	res := runner.Apply(ctx)
+	Equals(t, "Pull request must be approved according to the project's approval rules before running apply.", res.Failure)
+	_, err := os.Stat(planFile)
+	Ok(t, err)
+}
+
 // Test that if approval is required and the PR isn't approved we give an error.
 func TestDefaultProjectCommandRunner_ApplyNotApproved(t *testing.T) {
 	RegisterMockTestingT(t)
@@ -452,11 +750,21 @@ func TestDefaultProjectCommandRunner_Apply(t *testing.T) {
 			expEnvs := map[string]string{
 				"key": "value",
 			}
-			When(mockInit.Run(ctx, nil, repoDir, expEnvs)).ThenReturn("init", nil)
-			When(mockPlan.Run(ctx, nil, repoDir, expEnvs)).ThenReturn("plan", nil)
-			When(mockApply.Run(ctx, nil, repoDir, expEnvs)).ThenReturn("apply", nil)
-			When(mockRun.Run(ctx, "", repoDir, expEnvs, true, "")).ThenReturn("run", nil)
-			When(mockEnv.Run(ctx, "", "value", repoDir, make(map[string]string))).ThenReturn("value", nil)
+			// Steps are run with their index in the stage.
+			stepCtx := func(name string) command.ProjectContext {
+				stepCtx := ctx
+				for i, step := range c.steps {
+					if step.StepName == name {
+						stepCtx.StepIndex = i
+					}
+				}
+				return stepCtx
+			}
+			When(mockInit.Run(stepCtx("init"), nil, repoDir, expEnvs)).ThenReturn("init", nil)
+			When(mockPlan.Run(stepCtx("plan"), nil, repoDir, expEnvs)).ThenReturn("plan", nil)
+			When(mockApply.Run(stepCtx("apply"), nil, repoDir, expEnvs)).ThenReturn("apply", nil)
+			When(mockRun.Run(stepCtx("run"), valid.Step{StepName: "run"}, repoDir, expEnvs, true)).ThenReturn("run", nil)
+			When(mockEnv.Run(stepCtx("env"), "", "value", repoDir, make(map[string]string))).ThenReturn("value", nil)
 
 			res := runner.Apply(ctx)
 			Equals(t, c.expOut, res.ApplySuccess)
@@ -465,15 +773,15 @@ func TestDefaultProjectCommandRunner_Apply(t *testing.T) {
 			for _, step := range c.expSteps {
 				switch step {
 				case "init":
-					mockInit.VerifyWasCalledOnce().Run(ctx, nil, repoDir, expEnvs)
+					mockInit.VerifyWasCalledOnce().Run(stepCtx("init"), nil, repoDir, expEnvs)
 				case "plan":
-					mockPlan.VerifyWasCalledOnce().Run(ctx, nil, repoDir, expEnvs)
+					mockPlan.VerifyWasCalledOnce().Run(stepCtx("plan"), nil, repoDir, expEnvs)
 				case "apply":
-					mockApply.VerifyWasCalledOnce().Run(ctx, nil, repoDir, expEnvs)
+					mockApply.VerifyWasCalledOnce().Run(stepCtx("apply"), nil, repoDir, expEnvs)
 				case "run":
-					mockRun.VerifyWasCalledOnce().Run(ctx, "", repoDir, expEnvs, true, "")
+					mockRun.VerifyWasCalledOnce().Run(stepCtx("run"), valid.Step{StepName: "run"}, repoDir, expEnvs, true)
 				case "env":
-					mockEnv.VerifyWasCalledOnce().Run(ctx, "", "value", repoDir, expEnvs)
+					mockEnv.VerifyWasCalledOnce().Run(stepCtx("env"), "", "value", repoDir, expEnvs)
 				}
 			}
 		})
@@ -538,6 +846,126 @@ func TestDefaultProjectCommandRunner_ApplyRunStepFailure(t *testing.T) {
 	mockApply.VerifyWasCalledOnce().Run(ctx, nil, repoDir, expEnvs)
 }
 
+// Test that applies run with --queue on a project locked by another pull
+// request are queued if the project is locked on apply.
+func TestDefaultProjectCommandRunner_ApplyQueued(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	applyQueue := events.NewApplyQueue(lockmocks.NewMockLocker(), 10)
+	runner := events.DefaultProjectCommandRunner{
+		Locker:     mockLocker,
+		WorkingDir: mockWorkingDir,
+		CommandRequirementHandler: &events.DefaultCommandRequirementHandler{
+			WorkingDir: mockWorkingDir,
+		},
+		ApplyQueue: applyQueue,
+	}
+	When(mockWorkingDir.GetWorkingDir(
+		Any[models.Repo](),
+		Any[models.PullRequest](),
+		Any[string](),
+	)).ThenReturn(t.TempDir(), nil)
+	When(mockLocker.TryLock(
+		Any[logging.SimpleLogging](),
+		Any[models.PullRequest](),
+		Any[models.User](),
+		Any[string](),
+		Any[models.Project](),
+		AnyBool(),
+	)).ThenReturn(&events.TryLockResponse{
+		LockAcquired:      false,
+		LockFailureReason: "locked by pull #1",
+		LockKey:           "owner/repo/./default",
+	}, nil)
+
+	ctx := command.ProjectContext{
+		Log:               logging.NewNoopLogger(t),
+		Pull:              models.PullRequest{Num: 2, BaseRepo: models.Repo{FullName: "owner/repo"}},
+		Workspace:         "default",
+		ApplyRequirements: []string{},
+		RepoRelDir:        ".",
+		ApplyReason:       "JIRA-123",
+		RepoLocksMode:     valid.RepoLocksOnApplyMode,
+	}
+	res := runner.Apply(ctx)
+	Equals(t, "locked by pull #1", res.Failure)
+	Equals(t, 0, len(applyQueue.Queued("owner/repo/./default")))
+
+	// Projects locked when they're planned can't be queued.
+	ctx.QueueApply = true
+	ctx.RepoLocksMode = valid.RepoLocksOnPlanMode
+	res = runner.Apply(ctx)
+	Equals(t, "`apply --queue` can only be used on projects with `repo_locks` mode `on_apply`, this project's mode is `on_plan`.", res.Failure)
+	Equals(t, 0, len(applyQueue.Queued("owner/repo/./default")))
+
+	ctx.RepoLocksMode = valid.RepoLocksOnApplyMode
+	res = runner.Apply(ctx)
+	Equals(t, "This project is locked by another pull request. The apply was queued at position 1 of 1 and will run when the lock is released.", res.Failure)
+	Equals(t, []events.QueuedApply{
+		{
+			Pull: ctx.Pull,
+			Cmd: &events.CommentCommand{
+				Name:       command.Apply,
+				RepoRelDir: ".",
+				Workspace:  "default",
+				Reason:     "JIRA-123",
+				Queue:      true,
+			},
+		},
+	}, applyQueue.Queued("owner/repo/./default"))
+}
+
+// Test that the state version written by the apply step is in the result, and
+// that the state version of an earlier apply isn't.
+func TestDefaultProjectCommandRunner_ApplyStateVersion(t *testing.T) {
+	for _, written := range []bool{true, false} {
+		t.Run(fmt.Sprintf("written %t", written), func(t *testing.T) {
+			RegisterMockTestingT(t)
+			mockApply := mocks.NewMockStepRunner()
+			mockWorkingDir := mocks.NewMockWorkingDir()
+			mockLocker := mocks.NewMockProjectLocker()
+			runner := events.DefaultProjectCommandRunner{
+				Locker:                    mockLocker,
+				LockURLGenerator:          mockURLGenerator{},
+				ApplyStepRunner:           mockApply,
+				WorkingDir:                mockWorkingDir,
+				WorkingDirLocker:          events.NewDefaultWorkingDirLocker(),
+				CommandRequirementHandler: &events.DefaultCommandRequirementHandler{WorkingDir: mockWorkingDir},
+				Webhooks:                  mocks.NewMockWebhooksSender(),
+			}
+			repoDir := t.TempDir()
+			When(mockWorkingDir.GetWorkingDir(Any[models.Repo](), Any[models.PullRequest](), Any[string]())).ThenReturn(repoDir, nil)
+			When(mockLocker.TryLock(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.User](), Any[string](),
+				Any[models.Project](), AnyBool())).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key"}, nil)
+
+			ctx := command.ProjectContext{
+				Log:               logging.NewNoopLogger(t),
+				Steps:             []valid.Step{{StepName: "apply"}},
+				Workspace:         "default",
+				ApplyRequirements: []string{},
+				RepoRelDir:        ".",
+			}
+			stateVersionFile := filepath.Join(repoDir, "default-stateversion.json")
+			Ok(t, os.WriteFile(stateVersionFile, []byte(`{"serial":11,"lineage":"3d2a4b5c"}`), 0600))
+			When(mockApply.Run(ctx, nil, repoDir, map[string]string{})).Then(func(_ []Param) ReturnValues {
+				if written {
+					Ok(t, os.WriteFile(stateVersionFile, []byte(`{"serial":12,"lineage":"3d2a4b5c"}`), 0600))
+				}
+				return ReturnValues{"apply", nil}
+			})
+
+			res := runner.Apply(ctx)
+			Equals(t, "apply", res.ApplySuccess)
+			if written {
+				Equals(t, &models.StateVersion{Serial: 12, Lineage: "3d2a4b5c"}, res.ApplyState)
+			} else {
+				Assert(t, res.ApplyState == nil, "exp no state version, got %v", res.ApplyState)
+			}
+		})
+	}
+}
+
 // Test run and env steps. We don't use mocks for this test since we're
 // not running any Terraform.
 func TestDefaultProjectCommandRunner_RunEnvSteps(t *testing.T) {
@@ -620,6 +1048,711 @@ func TestDefaultProjectCommandRunner_RunEnvSteps(t *testing.T) {
 	Equals(t, "var=\n\nvar=value\n\ndynamic_var=dynamic_value\n\ndynamic_var=overridden\n", res.PlanSuccess.TerraformOutput)
 }
 
+// Test that values set by env steps are masked in the comment and the logs as
+// set by their mask_in option, and in the job output if either masks them.
+func TestDefaultProjectCommandRunner_RunEnvStepsMaskIn(t *testing.T) {
+	RegisterMockTestingT(t)
+	tfClient := tmocks.NewMockClient()
+	tfVersion, err := version.NewVersion("0.12.0")
+	Ok(t, err)
+	outputHandler := jobmocks.NewMockProjectCommandOutputHandler()
+	run := runtime.RunStepRunner{
+		TerraformExecutor:       tfClient,
+		DefaultTFVersion:        tfVersion,
+		ProjectCmdOutputHandler: outputHandler,
+	}
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+
+	runner := events.DefaultProjectCommandRunner{
+		Locker:                    mockLocker,
+		LockURLGenerator:          mockURLGenerator{},
+		RunStepRunner:             &run,
+		EnvStepRunner:             &runtime.EnvStepRunner{RunStepRunner: &run},
+		WorkingDir:                mockWorkingDir,
+		WorkingDirLocker:          events.NewDefaultWorkingDirLocker(),
+		CommandRequirementHandler: mocks.NewMockCommandRequirementHandler(),
+	}
+
+	repoDir := t.TempDir()
+	When(mockWorkingDir.Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+		Any[string]())).ThenReturn(repoDir, false, nil)
+	When(mockLocker.TryLock(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.User](), Any[string](),
+		Any[models.Project](), AnyBool())).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key", UnlockFn: func() error { return nil }}, nil)
+
+	logger := logging.NewNoopLogger(t).WithHistory()
+	ctx := command.ProjectContext{
+		Log: logger,
+		Steps: []valid.Step{
+			{
+				StepName:      "env",
+				EnvVarName:    "EVERYWHERE",
+				EnvVarValue:   "everywhere-secret",
+				MaskInComment: true,
+				MaskInLog:     true,
+			},
+			{
+				StepName:      "env",
+				EnvVarName:    "COMMENT",
+				EnvVarValue:   "comment-secret",
+				MaskInComment: true,
+			},
+			{
+				StepName:    "env",
+				EnvVarName:  "LOG",
+				EnvVarValue: "log-secret",
+				MaskInLog:   true,
+			},
+			{
+				StepName:   "run",
+				RunCommand: "echo $EVERYWHERE $COMMENT $LOG && exit 1",
+			},
+		},
+		Workspace:  "default",
+		RepoRelDir: ".",
+	}
+	res := runner.Plan(ctx)
+	ErrContains(t, "\n*** *** log-secret\n", res.Error)
+	history := logger.GetHistory()
+	Assert(t, strings.Contains(history, "*** comment-secret ***"), "exp log to only show the comment-secret, got %q", history)
+	Assert(t, !strings.Contains(history, "everywhere-secret"), "exp log to mask everywhere-secret, got %q", history)
+	jobCtx, _, _ := outputHandler.VerifyWasCalledOnce().Send(Any[command.ProjectContext](), Any[string](), AnyBool()).GetCapturedArguments()
+	Equals(t, []string{"everywhere-secret", "comment-secret", "log-secret"}, jobCtx.OutputSecrets)
+}
+
+// Test that secrets spanning multiple lines are still masked in the output of
+// run steps with line_prefix.
+func TestDefaultProjectCommandRunner_RunLinePrefixMasksSecrets(t *testing.T) {
+	RegisterMockTestingT(t)
+	tfClient := tmocks.NewMockClient()
+	tfVersion, err := version.NewVersion("0.12.0")
+	Ok(t, err)
+	run := runtime.RunStepRunner{
+		TerraformExecutor:       tfClient,
+		DefaultTFVersion:        tfVersion,
+		ProjectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+	}
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+
+	runner := events.DefaultProjectCommandRunner{
+		Locker:                    mockLocker,
+		LockURLGenerator:          mockURLGenerator{},
+		RunStepRunner:             &run,
+		EnvStepRunner:             &runtime.EnvStepRunner{RunStepRunner: &run},
+		WorkingDir:                mockWorkingDir,
+		WorkingDirLocker:          events.NewDefaultWorkingDirLocker(),
+		CommandRequirementHandler: mocks.NewMockCommandRequirementHandler(),
+	}
+
+	repoDir := t.TempDir()
+	When(mockWorkingDir.Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+		Any[string]())).ThenReturn(repoDir, false, nil)
+	When(mockLocker.TryLock(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.User](), Any[string](),
+		Any[models.Project](), AnyBool())).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key", UnlockFn: func() error { return nil }}, nil)
+
+	ctx := command.ProjectContext{
+		Log: logging.NewNoopLogger(t),
+		Steps: []valid.Step{
+			{
+				StepName:      "env",
+				EnvVarName:    "KEY",
+				EnvVarValue:   "first-secret-line\nsecond-secret-line",
+				MaskInComment: true,
+			},
+			{
+				StepName:   "run",
+				RunCommand: "echo start && echo \"$KEY\" && exit 1",
+				LinePrefix: "[key] ",
+			},
+		},
+		Workspace:  "default",
+		RepoRelDir: ".",
+	}
+	res := runner.Plan(ctx)
+	ErrContains(t, "\n[key] start\n[key] ***\n", res.Error)
+}
+
+// Test that plans are encrypted at rest and that an apply after the server
+// restarted, with a new encryptor, reads the encrypted plan.
+func TestDefaultProjectCommandRunner_PlanEncryption(t *testing.T) {
+	RegisterMockTestingT(t)
+	tfClient := tmocks.NewMockClient()
+	tfVersion, err := version.NewVersion("0.12.0")
+	Ok(t, err)
+	run := runtime.RunStepRunner{
+		TerraformExecutor:       tfClient,
+		DefaultTFVersion:        tfVersion,
+		ProjectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+	}
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	repoDir := t.TempDir()
+	When(mockWorkingDir.Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+		Any[string]())).ThenReturn(repoDir, false, nil)
+	When(mockWorkingDir.GetWorkingDir(Any[models.Repo](), Any[models.PullRequest](), Any[string]())).ThenReturn(repoDir, nil)
+	When(mockLocker.TryLock(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.User](), Any[string](),
+		Any[models.Project](), AnyBool())).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key", UnlockFn: func() error { return nil }}, nil)
+
+	keyFile := filepath.Join(t.TempDir(), "plan-keys")
+	Ok(t, os.WriteFile(keyFile, []byte("a:"+base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", 32)))+"\n"), 0600))
+	newRunner := func() events.DefaultProjectCommandRunner {
+		keys, err := runtime.NewLocalPlanKeys(keyFile)
+		Ok(t, err)
+		return events.DefaultProjectCommandRunner{
+			Locker:                    mockLocker,
+			LockURLGenerator:          mockURLGenerator{},
+			RunStepRunner:             &run,
+			WorkingDir:                mockWorkingDir,
+			WorkingDirLocker:          events.NewDefaultWorkingDirLocker(),
+			CommandRequirementHandler: mocks.NewMockCommandRequirementHandler(),
+			Webhooks:                  mocks.NewMockWebhooksSender(),
+			PlanEncryptor:             &runtime.PlanEncryptor{Keys: keys},
+		}
+	}
+	ctx := command.ProjectContext{
+		Log:        logging.NewNoopLogger(t),
+		Workspace:  "default",
+		RepoRelDir: ".",
+	}
+
+	ctx.Steps = []valid.Step{{StepName: "run", RunCommand: "echo my-plan > $PLANFILE"}}
+	runner := newRunner()
+	res := runner.Plan(ctx)
+	Ok(t, res.Error)
+	content, err := os.ReadFile(filepath.Join(repoDir, "default.tfplan"))
+	Ok(t, err)
+	Assert(t, !strings.Contains(string(content), "my-plan"), "exp plan to be encrypted, got %q", content)
+
+	ctx.Steps = []valid.Step{{StepName: "run", RunCommand: "cat $PLANFILE"}}
+	runner = newRunner()
+	res = runner.Apply(ctx)
+	Ok(t, res.Error)
+	Equals(t, "my-plan", strings.TrimSpace(res.ApplySuccess))
+}
+
+type fakeSSMParameterGetter struct {
+	params []runtime.SSMParameter
+}
+
+func (f fakeSSMParameterGetter) GetParametersByPath(_ command.ProjectContext, _ string) ([]runtime.SSMParameter, error) {
+	return f.params, nil
+}
+
+// Test that env steps with an SSM path set env vars and that SecureString
+// values are masked in the output and the job's output.
+func TestDefaultProjectCommandRunner_RunSSMEnvSteps(t *testing.T) {
+	RegisterMockTestingT(t)
+	tfClient := tmocks.NewMockClient()
+	tfVersion, err := version.NewVersion("0.12.0")
+	Ok(t, err)
+	outputHandler := jobmocks.NewMockProjectCommandOutputHandler()
+	run := runtime.RunStepRunner{
+		TerraformExecutor:       tfClient,
+		DefaultTFVersion:        tfVersion,
+		ProjectCmdOutputHandler: outputHandler,
+	}
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+
+	runner := events.DefaultProjectCommandRunner{
+		Locker:           mockLocker,
+		LockURLGenerator: mockURLGenerator{},
+		RunStepRunner:    &run,
+		SSMEnvStepRunner: &runtime.SSMEnvStepRunner{
+			ParameterGetter: fakeSSMParameterGetter{
+				params: []runtime.SSMParameter{
+					{Name: "/app/prod/region", Type: "String", Value: "us-east-1"},
+					{Name: "/app/prod/db_password", Type: "SecureString", Value: "hunter2"},
+				},
+			},
+		},
+		WorkingDir:                mockWorkingDir,
+		WorkingDirLocker:          events.NewDefaultWorkingDirLocker(),
+		CommandRequirementHandler: mocks.NewMockCommandRequirementHandler(),
+	}
+
+	repoDir := t.TempDir()
+	When(mockWorkingDir.Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+		Any[string]())).ThenReturn(repoDir, false, nil)
+	When(mockLocker.TryLock(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.User](), Any[string](),
+		Any[models.Project](), AnyBool())).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key"}, nil)
+
+	ctx := command.ProjectContext{
+		Log: logging.NewNoopLogger(t),
+		Steps: []valid.Step{
+			{
+				StepName: "env",
+				SSMPath:  "/app/prod/",
+			},
+			{
+				StepName:   "run",
+				RunCommand: "echo region=$REGION password=$DB_PASSWORD",
+			},
+		},
+		Workspace:  "default",
+		RepoRelDir: ".",
+	}
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, "region=us-east-1 password=***\n", res.PlanSuccess.TerraformOutput)
+	jobCtx, _, _ := outputHandler.VerifyWasCalledOnce().Send(Any[command.ProjectContext](), Any[string](), AnyBool()).GetCapturedArguments()
+	Equals(t, []string{"hunter2"}, jobCtx.OutputSecrets)
+}
+
+// Test that env steps resolve secrets from the secret store, that they're
+// masked even if the env var isn't and that unknown secrets are an error.
+func TestDefaultProjectCommandRunner_RunEnvStepsSecrets(t *testing.T) {
+	RegisterMockTestingT(t)
+	tfClient := tmocks.NewMockClient()
+	tfVersion, err := version.NewVersion("0.12.0")
+	Ok(t, err)
+	run := runtime.RunStepRunner{
+		TerraformExecutor:       tfClient,
+		DefaultTFVersion:        tfVersion,
+		ProjectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+	}
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	secretsDir := t.TempDir()
+	Ok(t, os.WriteFile(filepath.Join(secretsDir, "DB_PASSWORD"), []byte("hunter22\n"), 0600))
+
+	runner := events.DefaultProjectCommandRunner{
+		Locker:                    mockLocker,
+		LockURLGenerator:          mockURLGenerator{},
+		RunStepRunner:             &run,
+		EnvStepRunner:             &runtime.EnvStepRunner{RunStepRunner: &run},
+		SecretStore:               &runtime.DirSecretStore{Dir: secretsDir},
+		WorkingDir:                mockWorkingDir,
+		WorkingDirLocker:          events.NewDefaultWorkingDirLocker(),
+		CommandRequirementHandler: mocks.NewMockCommandRequirementHandler(),
+	}
+
+	repoDir := t.TempDir()
+	When(mockWorkingDir.Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+		Any[string]())).ThenReturn(repoDir, false, nil)
+	When(mockLocker.TryLock(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.User](), Any[string](),
+		Any[models.Project](), AnyBool())).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key", UnlockFn: func() error { return nil }}, nil)
+
+	ctx := command.ProjectContext{
+		Log: logging.NewNoopLogger(t),
+		Steps: []valid.Step{
+			{
+				StepName:    "env",
+				EnvVarName:  "DB_URL",
+				EnvVarValue: "postgres://app:${{ secrets.DB_PASSWORD }}@db",
+			},
+			{
+				StepName:   "run",
+				RunCommand: "echo $DB_URL",
+			},
+		},
+		Workspace:  "default",
+		RepoRelDir: ".",
+	}
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success, got %q", res.Error)
+	Equals(t, "postgres://app:***@db\n", res.PlanSuccess.TerraformOutput)
+
+	ctx.Steps[0].EnvVarValue = "${{ secrets.API_TOKEN }}"
+	res = runner.Plan(ctx)
+	ErrContains(t, "setting env \"DB_URL\": unknown secret \"API_TOKEN\", it isn't in the server's secrets", res.Error)
+}
+
+// Test that plan --summary runs show after the workflow's steps and adds the
+// table of the plan's resource changes from its JSON.
+func TestDefaultProjectCommandRunner_PlanSummary(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockPlan := mocks.NewMockStepRunner()
+	mockShow := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	runner := events.DefaultProjectCommandRunner{
+		Locker:                    mockLocker,
+		LockURLGenerator:          mockURLGenerator{},
+		PlanStepRunner:            mockPlan,
+		ShowStepRunner:            mockShow,
+		WorkingDir:                mockWorkingDir,
+		WorkingDirLocker:          events.NewDefaultWorkingDirLocker(),
+		CommandRequirementHandler: mocks.NewMockCommandRequirementHandler(),
+	}
+
+	repoDir := t.TempDir()
+	When(mockWorkingDir.Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+		Any[string]())).ThenReturn(repoDir, false, nil)
+	When(mockLocker.TryLock(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.User](), Any[string](),
+		Any[models.Project](), AnyBool())).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key"}, nil)
+
+	ctx := command.ProjectContext{
+		Log:         logging.NewNoopLogger(t),
+		Steps:       []valid.Step{{StepName: "plan"}},
+		Workspace:   "default",
+		RepoRelDir:  ".",
+		PlanSummary: true,
+	}
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success, got %q", res.Error)
+	Equals(t, &models.PlanResourceSummary{Unavailable: "the plan wasn't saved, ex. because it's a remote plan."}, res.PlanSuccess.ResourceSummary)
+	mockShow.VerifyWasCalledOnce().Run(Any[command.ProjectContext](), Any[[]string](), Eq(repoDir), Any[map[string]string]())
+
+	Ok(t, os.WriteFile(filepath.Join(repoDir, "default.tfplan"), []byte("plan"), 0600))
+	res = runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success, got %q", res.Error)
+	Equals(t, &models.PlanResourceSummary{Unavailable: "the plan's JSON wasn't written, ex. because the Terraform version doesn't support terraform show -json."}, res.PlanSuccess.ResourceSummary)
+
+	Ok(t, os.WriteFile(filepath.Join(repoDir, ctx.GetShowResultFileName()), []byte(`{"resource_changes": [{"address": "aws_instance.web", "change": {"actions": ["create"]}}]}`), 0600))
+	res = runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success, got %q", res.Error)
+	Equals(t, &models.PlanResourceSummary{Changes: []models.PlanResourceChange{{Address: "aws_instance.web", Action: "create"}}}, res.PlanSuccess.ResourceSummary)
+
+	// Without --summary there's no table.
+	ctx.PlanSummary = false
+	res = runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success, got %q", res.Error)
+	Assert(t, res.PlanSuccess.ResourceSummary == nil, "exp no summary, got %v", res.PlanSuccess.ResourceSummary)
+	mockShow.VerifyWasCalled(Times(3)).Run(Any[command.ProjectContext](), Any[[]string](), Eq(repoDir), Any[map[string]string]())
+}
+
+// Test that tf_vars are exported as TF_VAR_ env vars, with their secrets
+// masked, and that env steps override them.
+func TestDefaultProjectCommandRunner_RunTFVars(t *testing.T) {
+	RegisterMockTestingT(t)
+	tfClient := tmocks.NewMockClient()
+	tfVersion, err := version.NewVersion("0.12.0")
+	Ok(t, err)
+	run := runtime.RunStepRunner{
+		TerraformExecutor:       tfClient,
+		DefaultTFVersion:        tfVersion,
+		ProjectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+	}
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	secretsDir := t.TempDir()
+	Ok(t, os.WriteFile(filepath.Join(secretsDir, "DB_PASSWORD"), []byte("hunter22\n"), 0600))
+
+	runner := events.DefaultProjectCommandRunner{
+		Locker:                    mockLocker,
+		LockURLGenerator:          mockURLGenerator{},
+		RunStepRunner:             &run,
+		EnvStepRunner:             &runtime.EnvStepRunner{RunStepRunner: &run},
+		SecretStore:               &runtime.DirSecretStore{Dir: secretsDir},
+		WorkingDir:                mockWorkingDir,
+		WorkingDirLocker:          events.NewDefaultWorkingDirLocker(),
+		CommandRequirementHandler: mocks.NewMockCommandRequirementHandler(),
+	}
+
+	repoDir := t.TempDir()
+	When(mockWorkingDir.Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+		Any[string]())).ThenReturn(repoDir, false, nil)
+	When(mockLocker.TryLock(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.User](), Any[string](),
+		Any[models.Project](), AnyBool())).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key", UnlockFn: func() error { return nil }}, nil)
+
+	ctx := command.ProjectContext{
+		Log: logging.NewNoopLogger(t),
+		Steps: []valid.Step{
+			{
+				StepName:    "env",
+				EnvVarName:  "TF_VAR_instance_type",
+				EnvVarValue: "t3.large",
+			},
+			{
+				StepName:   "run",
+				RunCommand: "echo $TF_VAR_region $TF_VAR_instance_type $TF_VAR_db_password",
+			},
+		},
+		TFVars: map[string]string{
+			"region":        "us-east-1",
+			"instance_type": "t3.micro",
+			"db_password":   "${{ secrets.DB_PASSWORD }}",
+		},
+		Workspace:  "default",
+		RepoRelDir: ".",
+	}
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success, got %q", res.Error)
+	Equals(t, "us-east-1 t3.large ***\n", res.PlanSuccess.TerraformOutput)
+
+	ctx.TFVars["db_password"] = "${{ secrets.API_TOKEN }}"
+	res = runner.Plan(ctx)
+	ErrContains(t, "setting tf_vars variable \"db_password\": unknown secret \"API_TOKEN\", it isn't in the server's secrets", res.Error)
+}
+
+// Test that a cancelled run has its running commands interrupted, stops before
+// its next step and runs its on_cancel steps, which are interrupted once the
+// grace period passes.
+func TestDefaultProjectCommandRunner_RunCancelled(t *testing.T) {
+	RegisterMockTestingT(t)
+	tfClient := tmocks.NewMockClient()
+	tfVersion, err := version.NewVersion("0.12.0")
+	Ok(t, err)
+	run := runtime.RunStepRunner{
+		TerraformExecutor:       tfClient,
+		DefaultTFVersion:        tfVersion,
+		ProjectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+	}
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	runner := events.DefaultProjectCommandRunner{
+		Locker:                    mockLocker,
+		LockURLGenerator:          mockURLGenerator{},
+		RunStepRunner:             &run,
+		WorkingDir:                mockWorkingDir,
+		WorkingDirLocker:          events.NewDefaultWorkingDirLocker(),
+		CommandRequirementHandler: mocks.NewMockCommandRequirementHandler(),
+		CancelGracePeriod:         100 * time.Millisecond,
+	}
+	When(mockLocker.TryLock(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.User](), Any[string](),
+		Any[models.Project](), AnyBool())).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key", UnlockFn: func() error { return nil }}, nil)
+
+	cases := []struct {
+		description string
+		onCancel    string
+		expCleanup  bool
+	}{
+		{
+			description: "on_cancel steps run",
+			onCancel:    "touch cleanup",
+			expCleanup:  true,
+		},
+		{
+			description: "failing on_cancel steps",
+			onCancel:    "exit 1",
+		},
+		{
+			description: "on_cancel steps exceeding the grace period",
+			onCancel:    "sleep 30 && touch cleanup",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			repoDir := t.TempDir()
+			When(mockWorkingDir.Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+				Any[string]())).ThenReturn(repoDir, false, nil)
+
+			// The run is cancelled once its first step is running.
+			cancel := make(chan struct{})
+			go func() {
+				for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+					if _, err := os.Stat(filepath.Join(repoDir, "first")); err == nil {
+						break
+					}
+				}
+				close(cancel)
+			}()
+
+			ctx := command.ProjectContext{
+				Log: logging.NewNoopLogger(t),
+				Steps: []valid.Step{
+					{StepName: "run", RunCommand: "touch first && sleep 30"},
+					{StepName: "run", RunCommand: "touch second"},
+				},
+				OnCancelSteps: []valid.Step{
+					{StepName: "run", RunCommand: c.onCancel},
+				},
+				Cancelled:  cancel,
+				Workspace:  "default",
+				RepoRelDir: ".",
+			}
+			start := time.Now()
+			res := runner.Plan(ctx)
+			ErrContains(t, "cancelled because the pull request was closed", res.Error)
+			Assert(t, time.Since(start) < 10*time.Second, "exp the steps to be interrupted, took %s", time.Since(start))
+
+			_, err := os.Stat(filepath.Join(repoDir, "second"))
+			Assert(t, os.IsNotExist(err), "exp the step after the cancellation not to run")
+			_, err = os.Stat(filepath.Join(repoDir, "cleanup"))
+			Equals(t, c.expCleanup, err == nil)
+		})
+	}
+}
+
+// Test that a run exceeding its workflow's timeout has its commands killed,
+// runs its on_cancel steps and releases its lock.
+func TestDefaultProjectCommandRunner_RunTimeout(t *testing.T) {
+	RegisterMockTestingT(t)
+	tfClient := tmocks.NewMockClient()
+	tfVersion, err := version.NewVersion("0.12.0")
+	Ok(t, err)
+	run := runtime.RunStepRunner{
+		TerraformExecutor:       tfClient,
+		DefaultTFVersion:        tfVersion,
+		ProjectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+	}
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	runner := events.DefaultProjectCommandRunner{
+		Locker:                    mockLocker,
+		LockURLGenerator:          mockURLGenerator{},
+		RunStepRunner:             &run,
+		WorkingDir:                mockWorkingDir,
+		WorkingDirLocker:          events.NewDefaultWorkingDirLocker(),
+		CommandRequirementHandler: mocks.NewMockCommandRequirementHandler(),
+	}
+	unlocked := false
+	When(mockLocker.TryLock(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.User](), Any[string](),
+		Any[models.Project](), AnyBool())).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key", UnlockFn: func() error {
+		unlocked = true
+		return nil
+	}}, nil)
+	repoDir := t.TempDir()
+	When(mockWorkingDir.Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+		Any[string]())).ThenReturn(repoDir, false, nil)
+
+	ctx := command.ProjectContext{
+		Log: logging.NewNoopLogger(t),
+		Steps: []valid.Step{
+			{StepName: "run", RunCommand: "touch first && sleep 30"},
+			{StepName: "run", RunCommand: "touch second"},
+		},
+		OnCancelSteps: []valid.Step{
+			{StepName: "run", RunCommand: "touch cleanup"},
+		},
+		WorkflowName:    "slow",
+		WorkflowTimeout: 200 * time.Millisecond,
+		Workspace:       "default",
+		RepoRelDir:      ".",
+	}
+	start := time.Now()
+	res := runner.Plan(ctx)
+	ErrContains(t, "cancelled because the \"slow\" workflow didn't finish within its timeout of 200ms, commands still running were killed", res.Error)
+	Assert(t, time.Since(start) < 10*time.Second, "exp the step to be killed, took %s", time.Since(start))
+	Assert(t, unlocked, "exp the lock to be released")
+
+	_, err = os.Stat(filepath.Join(repoDir, "second"))
+	Assert(t, os.IsNotExist(err), "exp the step after the timeout not to run")
+	_, err = os.Stat(filepath.Join(repoDir, "cleanup"))
+	Ok(t, err)
+}
+
+// Test that an apply that exceeds its workflow's timeout releases its lock,
+// while one that fails otherwise keeps it so it can be retried.
+func TestDefaultProjectCommandRunner_ApplyTimeout(t *testing.T) {
+	cases := []struct {
+		description string
+		command     string
+		expErr      string
+		expUnlocked bool
+	}{
+		{
+			description: "timed out",
+			command:     "sleep 30",
+			expErr:      "cancelled because the \"slow\" workflow didn't finish within its timeout of 200ms",
+			expUnlocked: true,
+		},
+		{
+			description: "failed",
+			command:     "exit 1",
+			expErr:      "exit status 1",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			tfVersion, err := version.NewVersion("0.12.0")
+			Ok(t, err)
+			run := runtime.RunStepRunner{
+				TerraformExecutor:       tmocks.NewMockClient(),
+				DefaultTFVersion:        tfVersion,
+				ProjectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+			}
+			mockWorkingDir := mocks.NewMockWorkingDir()
+			mockLocker := mocks.NewMockProjectLocker()
+			runner := events.DefaultProjectCommandRunner{
+				Locker:                    mockLocker,
+				LockURLGenerator:          mockURLGenerator{},
+				RunStepRunner:             &run,
+				WorkingDir:                mockWorkingDir,
+				WorkingDirLocker:          events.NewDefaultWorkingDirLocker(),
+				CommandRequirementHandler: mocks.NewMockCommandRequirementHandler(),
+				Webhooks:                  mocks.NewMockWebhooksSender(),
+			}
+			unlocked := false
+			When(mockLocker.TryLock(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.User](), Any[string](),
+				Any[models.Project](), AnyBool())).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key", UnlockFn: func() error {
+				unlocked = true
+				return nil
+			}}, nil)
+			repoDir := t.TempDir()
+			When(mockWorkingDir.GetWorkingDir(Any[models.Repo](), Any[models.PullRequest](), Any[string]())).ThenReturn(repoDir, nil)
+
+			ctx := command.ProjectContext{
+				Log:             logging.NewNoopLogger(t),
+				Steps:           []valid.Step{{StepName: "run", RunCommand: c.command}},
+				WorkflowName:    "slow",
+				WorkflowTimeout: 200 * time.Millisecond,
+				Workspace:       "default",
+				RepoRelDir:      ".",
+			}
+			res := runner.Apply(ctx)
+			ErrContains(t, c.expErr, res.Error)
+			Equals(t, c.expUnlocked, unlocked)
+		})
+	}
+}
+
+// Test that a fatal step killed at the workflow's timeout skips the on_cancel
+// steps and aborts the run's other projects.
+func TestDefaultProjectCommandRunner_RunFatal(t *testing.T) {
+	RegisterMockTestingT(t)
+	tfClient := tmocks.NewMockClient()
+	tfVersion, err := version.NewVersion("0.12.0")
+	Ok(t, err)
+	run := runtime.RunStepRunner{
+		TerraformExecutor:       tfClient,
+		DefaultTFVersion:        tfVersion,
+		ProjectCmdOutputHandler: jobmocks.NewMockProjectCommandOutputHandler(),
+	}
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	runner := events.DefaultProjectCommandRunner{
+		Locker:                    mockLocker,
+		LockURLGenerator:          mockURLGenerator{},
+		RunStepRunner:             &run,
+		WorkingDir:                mockWorkingDir,
+		WorkingDirLocker:          events.NewDefaultWorkingDirLocker(),
+		CommandRequirementHandler: mocks.NewMockCommandRequirementHandler(),
+	}
+	When(mockLocker.TryLock(Any[logging.SimpleLogging](), Any[models.PullRequest](), Any[models.User](), Any[string](),
+		Any[models.Project](), AnyBool())).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key", UnlockFn: func() error { return nil }}, nil)
+	repoDir := t.TempDir()
+	When(mockWorkingDir.Clone(Any[logging.SimpleLogging](), Any[models.Repo](), Any[models.PullRequest](),
+		Any[string]())).ThenReturn(repoDir, false, nil)
+
+	outcomes := command.NewProjectOutcomes()
+	ctx := command.ProjectContext{
+		Log:         logging.NewNoopLogger(t),
+		ProjectName: "db",
+		Steps: []valid.Step{
+			{StepName: "run", RunCommand: "sleep 30", Fatal: true},
+			{StepName: "run", RunCommand: "touch second"},
+		},
+		OnCancelSteps: []valid.Step{
+			{StepName: "run", RunCommand: "touch cleanup"},
+		},
+		WorkflowName:    "slow",
+		WorkflowTimeout: 200 * time.Millisecond,
+		ProjectOutcomes: outcomes,
+		Workspace:       "default",
+		RepoRelDir:      ".",
+	}
+	res := runner.Plan(ctx)
+	ErrContains(t, "fatal step \"sleep 30\" failed, aborting the run without cleanup", res.Error)
+	_, err = os.Stat(filepath.Join(repoDir, "second"))
+	Assert(t, os.IsNotExist(err), "exp the step after the fatal step not to run")
+	_, err = os.Stat(filepath.Join(repoDir, "cleanup"))
+	Assert(t, os.IsNotExist(err), "exp the on_cancel steps not to run")
+
+	// The run's other projects stop before their next step.
+	otherCtx := ctx
+	otherCtx.ProjectName = "app"
+	otherCtx.Steps = []valid.Step{{StepName: "run", RunCommand: "touch other"}}
+	otherCtx.WorkflowTimeout = 0
+	res = runner.Plan(otherCtx)
+	ErrContains(t, "aborted because a fatal step failed in project \"db\"", res.Error)
+	_, err = os.Stat(filepath.Join(repoDir, "other"))
+	Assert(t, os.IsNotExist(err), "exp the other project's steps not to run")
+}
+
 // Test that it runs the expected import steps.
 func TestDefaultProjectCommandRunner_Import(t *testing.T) {
 	expEnvs := map[string]string{}
@@ -657,7 +1790,9 @@ func TestDefaultProjectCommandRunner_Import(t *testing.T) {
 				}, nil)
 
 				When(mockInit.Run(ctx, nil, repoDir, expEnvs)).ThenReturn("init", nil)
-				When(mockImport.Run(ctx, nil, repoDir, expEnvs)).ThenReturn("import", nil)
+				importCtx := ctx
+				importCtx.StepIndex = 1
+				When(mockImport.Run(importCtx, nil, repoDir, expEnvs)).ThenReturn("import", nil)
 			},
 			expSteps: []string{"import"},
 			expOut: &models.ImportSuccess{
@@ -727,7 +1862,9 @@ func TestDefaultProjectCommandRunner_Import(t *testing.T) {
 				case "init":
 					mockInit.VerifyWasCalledOnce().Run(ctx, nil, repoDir, expEnvs)
 				case "import":
-					mockImport.VerifyWasCalledOnce().Run(ctx, nil, repoDir, expEnvs)
+					importCtx := ctx
+					importCtx.StepIndex = 1
+					mockImport.VerifyWasCalledOnce().Run(importCtx, nil, repoDir, expEnvs)
 				}
 			}
 		})
@@ -46,6 +46,11 @@ type ProjectFinder interface {
 	// based on modifiedFiles and the repo's config.
 	// absRepoDir is the path to the cloned repo on disk.
 	DetermineProjectsViaConfig(log logging.SimpleLogging, modifiedFiles []string, config valid.RepoCfg, absRepoDir string, moduleInfo ModuleProjects) ([]valid.Project, error)
+	// DetermineBackendProjects returns the list of projects that were modified
+	// based on modifiedFiles where only directories with a backend or cloud
+	// block are projects. The list will be de-duplicated.
+	// absRepoDir is the path to the cloned repo on disk.
+	DetermineBackendProjects(log logging.SimpleLogging, modifiedFiles []string, repoFullName string, absRepoDir string, autoplanFileList string, moduleInfo ModuleProjects) ([]models.Project, error)
 
 	DetermineWorkspaceFromHCL(log logging.SimpleLogging, absRepoDir string) (string, error)
 }
@@ -67,6 +72,18 @@ var terraformBlockSchema = &hcl.BodySchema{
 	},
 }
 
+var backendBlockSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{
+			Type:       "backend",
+			LabelNames: []string{"type"},
+		},
+		{
+			Type: "cloud",
+		},
+	},
+}
+
 var cloudBlockSchema = &hcl.BodySchema{
 	Blocks: []hcl.BlockHeaderSchema{
 		{
@@ -178,6 +195,120 @@ func (p *DefaultProjectFinder) DetermineProjects(log logging.SimpleLogging, modi
 	return projects
 }
 
+// See ProjectFinder.DetermineBackendProjects.
+func (p *DefaultProjectFinder) DetermineBackendProjects(log logging.SimpleLogging, modifiedFiles []string, repoFullName string, absRepoDir string, autoplanFileList string, moduleInfo ModuleProjects) ([]models.Project, error) {
+	var projects []models.Project
+
+	modifiedTerraformFiles := p.filterToFileList(log, modifiedFiles, autoplanFileList)
+	if len(modifiedTerraformFiles) == 0 {
+		return projects, nil
+	}
+
+	backendDirs, err := findBackendDirs(absRepoDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "looking for directories with a backend")
+	}
+	log.Debug("found %d directories with a backend: %v", len(backendDirs), backendDirs)
+
+	var dirs []string
+	for _, modifiedFile := range modifiedTerraformFiles {
+		dir := path.Dir(modifiedFile)
+		if backendDirs[dir] {
+			dirs = append(dirs, dir)
+			continue
+		}
+		// The file isn't in a root module so it's planned in the closest parent
+		// directory with a backend, ex. for modules/ or env/ directories inside a
+		// project, and in every project using its directory as a module.
+		if parent := closestBackendDir(dir, backendDirs); parent != "" {
+			dirs = append(dirs, parent)
+		}
+		if moduleInfo != nil {
+			downstreamProjects := moduleInfo.DependentProjects(dir)
+			log.Debug("found downstream projects for %q: %v", modifiedFile, downstreamProjects)
+			for _, downstream := range downstreamProjects {
+				if backendDirs[downstream] {
+					dirs = append(dirs, downstream)
+				}
+			}
+		}
+	}
+	uniqueDirs := p.unique(dirs)
+
+	for _, dir := range uniqueDirs {
+		projects = append(projects, models.NewProject(repoFullName, dir, ""))
+	}
+	log.Info("there are %d modified project(s) with a backend at path(s): %v",
+		len(projects), strings.Join(uniqueDirs, ", "))
+	return projects, nil
+}
+
+// findBackendDirs returns the directories of absRepoDir, relative to it, with a
+// .tf file configuring a backend or Terraform Cloud. Hidden directories, like
+// .git and .terraform, are skipped.
+func findBackendDirs(absRepoDir string) (map[string]bool, error) {
+	dirs := make(map[string]bool)
+	parser := hclparse.NewParser()
+	err := filepath.WalkDir(absRepoDir, func(absPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if absPath != absRepoDir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(d.Name(), ".tf") {
+			return nil
+		}
+		relDir, err := filepath.Rel(absRepoDir, filepath.Dir(absPath))
+		if err != nil {
+			return err
+		}
+		relDir = filepath.ToSlash(relDir)
+		if dirs[relDir] {
+			return nil
+		}
+		// Files that don't parse can't be planned either so they're treated as
+		// not having a backend rather than failing discovery for the repo.
+		file, diags := parser.ParseHCLFile(absPath)
+		if diags.HasErrors() {
+			return nil
+		}
+		if hasBackendBlock(file) {
+			dirs[relDir] = true
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// hasBackendBlock returns true if file has a terraform block with a backend or
+// cloud block.
+func hasBackendBlock(file *hcl.File) bool {
+	content, _, _ := file.Body.PartialContent(rootBlockSchema)
+	for _, block := range content.Blocks {
+		tfContent, _, _ := block.Body.PartialContent(backendBlockSchema)
+		if len(tfContent.Blocks) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// closestBackendDir returns the closest parent of the repo relative directory
+// dir in backendDirs, or "" if none of its parents have a backend.
+func closestBackendDir(dir string, backendDirs map[string]bool) string {
+	for dir != "." {
+		dir = path.Dir(dir)
+		if backendDirs[dir] {
+			return dir
+		}
+	}
+	return ""
+}
+
 // See ProjectFinder.DetermineProjectsViaConfig.
 func (p *DefaultProjectFinder) DetermineProjectsViaConfig(log logging.SimpleLogging, modifiedFiles []string, config valid.RepoCfg, absRepoDir string, moduleInfo ModuleProjects) ([]valid.Project, error) {
 
@@ -564,3 +564,141 @@ func TestDefaultProjectFinder_DetermineProjectsViaConfig(t *testing.T) {
 		})
 	}
 }
+
+// fakeModuleProjects maps module directories to the projects using them.
+type fakeModuleProjects map[string][]string
+
+func (f fakeModuleProjects) DependentProjects(moduleDir string) []string {
+	return f[moduleDir]
+}
+
+func TestDefaultProjectFinder_DetermineBackendProjects(t *testing.T) {
+	backend := `
+terraform {
+  backend "s3" {
+    bucket = "state"
+  }
+}
+`
+	cloud := `
+terraform {
+  required_version = ">= 1.1"
+  cloud {
+    organization = "org"
+  }
+}
+`
+	noBackend := `
+terraform {
+  required_version = ">= 1.1"
+}
+`
+	// Create dir structure:
+	// stacks/
+	//   prod/
+	//     backend.tf (s3 backend)
+	//     main.tf
+	//     env/
+	//       prod.tfvars
+	//     modules/
+	//       db/
+	//         main.tf
+	//   cloud/
+	//     main.tf (cloud block)
+	//   scratch/
+	//     main.tf (no backend)
+	// modules/
+	//   vpc/
+	//     main.tf (no backend)
+	// .terraform/
+	//   backend.tf
+	tmpDir := DirStructure(t, map[string]interface{}{
+		"stacks": map[string]interface{}{
+			"prod": map[string]interface{}{
+				"backend.tf": backend,
+				"main.tf":    noBackend,
+				"env": map[string]interface{}{
+					"prod.tfvars": nil,
+				},
+				"modules": map[string]interface{}{
+					"db": map[string]interface{}{
+						"main.tf": noBackend,
+					},
+				},
+			},
+			"cloud": map[string]interface{}{
+				"main.tf": cloud,
+			},
+			"scratch": map[string]interface{}{
+				"main.tf": noBackend,
+			},
+		},
+		"modules": map[string]interface{}{
+			"vpc": map[string]interface{}{
+				"main.tf": noBackend,
+			},
+		},
+		".terraform": map[string]interface{}{
+			"backend.tf": backend,
+		},
+	})
+	moduleInfo := fakeModuleProjects{
+		"modules/vpc": {"stacks/prod", "stacks/scratch"},
+	}
+
+	cases := []struct {
+		description  string
+		modified     []string
+		moduleInfo   events.ModuleProjects
+		expProjPaths []string
+	}{
+		{
+			description:  "root module changed",
+			modified:     []string{"stacks/prod/main.tf", "stacks/cloud/main.tf"},
+			expProjPaths: []string{"stacks/prod", "stacks/cloud"},
+		},
+		{
+			description:  "dirs inside a root module changed",
+			modified:     []string{"stacks/prod/env/prod.tfvars", "stacks/prod/modules/db/main.tf"},
+			expProjPaths: []string{"stacks/prod"},
+		},
+		{
+			description:  "dir without a backend changed",
+			modified:     []string{"stacks/scratch/main.tf"},
+			expProjPaths: nil,
+		},
+		{
+			description:  "shared module changed without module info",
+			modified:     []string{"modules/vpc/main.tf"},
+			expProjPaths: nil,
+		},
+		{
+			description:  "shared module changed with module info",
+			modified:     []string{"modules/vpc/main.tf"},
+			moduleInfo:   moduleInfo,
+			expProjPaths: []string{"stacks/prod"},
+		},
+		{
+			description:  "hidden dir changed",
+			modified:     []string{".terraform/backend.tf"},
+			expProjPaths: nil,
+		},
+		{
+			description:  "non terraform file changed",
+			modified:     []string{"stacks/prod/README.md"},
+			expProjPaths: nil,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			pf := events.DefaultProjectFinder{}
+			projects, err := pf.DetermineBackendProjects(logging.NewNoopLogger(t), c.modified, modifiedRepo, tmpDir, "**/*.tf,**/*.tfvars", c.moduleInfo)
+			Ok(t, err)
+			var projPaths []string
+			for _, proj := range projects {
+				projPaths = append(projPaths, proj.Path)
+			}
+			Equals(t, c.expProjPaths, projPaths)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/command"
+)
+
+// projectIndexFile is the file list-projects saves the projects it listed to.
+// It's kept in the .git dir of the pull request's default workspace clone so
+// it isn't seen as a change to the repo and is removed with the clone.
+const projectIndexFile = "atlantis-list-projects.json"
+
+// ProjectIndex is the list of projects commented by list-projects so they can
+// be planned and applied by index, ex. atlantis plan -i 3.
+type ProjectIndex struct {
+	// HeadCommit is the pull request's head commit when the projects were
+	// listed. The indexes are only used while it's the same so they can't
+	// refer to a different project than the one the user saw.
+	HeadCommit string `json:"head_commit"`
+	// Projects are the listed projects in order, the first has index 1.
+	Projects []ProjectIndexEntry `json:"projects"`
+}
+
+// ProjectIndexEntry is a project listed by list-projects.
+type ProjectIndexEntry struct {
+	ProjectName string `json:"project_name"`
+	RepoRelDir  string `json:"repo_rel_dir"`
+	Workspace   string `json:"workspace"`
+}
+
+// NewProjectIndex returns the index of projectCmds for ctx's pull request.
+func NewProjectIndex(ctx *command.Context, projectCmds []command.ProjectContext) ProjectIndex {
+	index := ProjectIndex{HeadCommit: ctx.Pull.HeadCommit}
+	for _, cmd := range projectCmds {
+		index.Projects = append(index.Projects, ProjectIndexEntry{
+			ProjectName: cmd.ProjectName,
+			RepoRelDir:  cmd.RepoRelDir,
+			Workspace:   cmd.Workspace,
+		})
+	}
+	return index
+}
+
+// Resolve sets cmd to run on the project at cmd.ProjectIndex. It errors if
+// the index is out of range or the pull request changed since the projects
+// were listed.
+func (i ProjectIndex) Resolve(headCommit string, cmd *CommentCommand) error {
+	if i.HeadCommit != headCommit {
+		return fmt.Errorf("the pull request changed since the projects were listed, run list-projects again before using an index")
+	}
+	if cmd.ProjectIndex < 1 || cmd.ProjectIndex > len(i.Projects) {
+		return fmt.Errorf("no project with index %d, the last list-projects comment listed %d project(s)", cmd.ProjectIndex, len(i.Projects))
+	}
+	project := i.Projects[cmd.ProjectIndex-1]
+	// Named projects are targeted like the -p flag would, the rest by dir
+	// and workspace.
+	if project.ProjectName != "" {
+		cmd.ProjectName = project.ProjectName
+	} else {
+		cmd.RepoRelDir = project.RepoRelDir
+		cmd.Workspace = project.Workspace
+	}
+	return nil
+}
+
+// WriteProjectIndex saves index for ctx's pull request, replacing the index
+// from a previous list-projects.
+func WriteProjectIndex(workingDir WorkingDir, ctx *command.Context, index ProjectIndex) error {
+	path, err := projectIndexPath(workingDir, ctx)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// ReadProjectIndex returns the index saved for ctx's pull request. It errors
+// if list-projects wasn't run for it.
+func ReadProjectIndex(workingDir WorkingDir, ctx *command.Context) (ProjectIndex, error) {
+	var index ProjectIndex
+	var data []byte
+	path, err := projectIndexPath(workingDir, ctx)
+	if err == nil {
+		data, err = os.ReadFile(path)
+	}
+	if os.IsNotExist(errors.Cause(err)) {
+		return index, errors.New("no projects have been listed for this pull request, run list-projects before using an index")
+	} else if err != nil {
+		return index, err
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return index, errors.Wrapf(err, "reading %s", path)
+	}
+	return index, nil
+}
+
+func projectIndexPath(workingDir WorkingDir, ctx *command.Context) (string, error) {
+	repoDir, err := workingDir.GetWorkingDir(ctx.Pull.BaseRepo, ctx.Pull, DefaultWorkspace)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(repoDir, ".git", projectIndexFile), nil
+}
@@ -0,0 +1,86 @@
+package events_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/petergtz/pegomock/v4"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/models"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestProjectIndex_Resolve(t *testing.T) {
+	index := events.ProjectIndex{
+		HeadCommit: "abc123",
+		Projects: []events.ProjectIndexEntry{
+			{ProjectName: "network", RepoRelDir: "network", Workspace: "default"},
+			{RepoRelDir: "app", Workspace: "staging"},
+		},
+	}
+	cases := []struct {
+		description string
+		headCommit  string
+		index       int
+		exp         events.CommentCommand
+		expErr      string
+	}{
+		{
+			description: "named project",
+			headCommit:  "abc123",
+			index:       1,
+			exp:         events.CommentCommand{ProjectIndex: 1, ProjectName: "network"},
+		},
+		{
+			description: "unnamed project",
+			headCommit:  "abc123",
+			index:       2,
+			exp:         events.CommentCommand{ProjectIndex: 2, RepoRelDir: "app", Workspace: "staging"},
+		},
+		{
+			description: "out of range",
+			headCommit:  "abc123",
+			index:       3,
+			expErr:      "no project with index 3, the last list-projects comment listed 2 project(s)",
+		},
+		{
+			description: "pull request changed",
+			headCommit:  "def456",
+			index:       1,
+			expErr:      "the pull request changed since the projects were listed, run list-projects again before using an index",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			cmd := events.CommentCommand{ProjectIndex: c.index}
+			err := index.Resolve(c.headCommit, &cmd)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.exp, cmd)
+		})
+	}
+}
+
+func TestReadProjectIndex(t *testing.T) {
+	RegisterMockTestingT(t)
+	workingDir := mocks.NewMockWorkingDir()
+	repoDir := t.TempDir()
+	Ok(t, os.Mkdir(filepath.Join(repoDir, ".git"), 0700))
+	When(workingDir.GetWorkingDir(Any[models.Repo](), Any[models.PullRequest](), Eq(events.DefaultWorkspace))).ThenReturn(repoDir, nil)
+	ctx := &command.Context{Pull: models.PullRequest{Num: 1, HeadCommit: "abc123"}}
+
+	_, err := events.ReadProjectIndex(workingDir, ctx)
+	ErrEquals(t, "no projects have been listed for this pull request, run list-projects before using an index", err)
+
+	index := events.NewProjectIndex(ctx, []command.ProjectContext{{RepoRelDir: ".", Workspace: "default"}})
+	Ok(t, events.WriteProjectIndex(workingDir, ctx, index))
+	act, err := events.ReadProjectIndex(workingDir, ctx)
+	Ok(t, err)
+	Equals(t, index, act)
+}
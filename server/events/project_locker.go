@@ -41,6 +41,9 @@ type DefaultProjectLocker struct {
 	Locker     locking.Locker
 	NoOpLocker locking.Locker
 	VCSClient  vcs.Client
+	// ExpiredPlanDiscarder, if set, releases locks held by other pull
+	// requests' plans that are older than their plan_ttl.
+	ExpiredPlanDiscarder *ExpiredPlanDiscarder
 }
 
 // TryLockResponse is the result of trying to lock a project.
@@ -54,7 +57,8 @@ type TryLockResponse struct {
 	// if there is an error later and the caller doesn't want to continue to
 	// hold the lock.
 	UnlockFn func() error
-	// LockKey is the key for the lock if the lock was acquired.
+	// LockKey is the key for the lock. It's set if the lock was acquired or
+	// is held by another pull request.
 	LockKey string
 }
 
@@ -69,6 +73,13 @@ func (p *DefaultProjectLocker) TryLock(log logging.SimpleLogging, pull models.Pu
 	if err != nil {
 		return nil, err
 	}
+	if !lockAttempt.LockAcquired && lockAttempt.CurrLock.Pull.Num != pull.Num && repoLocking && p.ExpiredPlanDiscarder != nil &&
+		p.ExpiredPlanDiscarder.DiscardLocked(log, lockAttempt.CurrLock) {
+		lockAttempt, err = locker.TryLock(project, workspace, pull, user)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if !lockAttempt.LockAcquired && lockAttempt.CurrLock.Pull.Num != pull.Num {
 		link, err := p.VCSClient.MarkdownPullLink(lockAttempt.CurrLock.Pull)
 		if err != nil {
@@ -81,6 +92,7 @@ func (p *DefaultProjectLocker) TryLock(log logging.SimpleLogging, pull models.Pu
 		return &TryLockResponse{
 			LockAcquired:      false,
 			LockFailureReason: failureMsg,
+			LockKey:           lockAttempt.LockKey,
 		}, nil
 	}
 	log.Info("acquired lock with id %q", lockAttempt.LockKey)
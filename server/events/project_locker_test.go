@@ -15,12 +15,17 @@ package events_test
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/petergtz/pegomock/v4"
 	"github.com/runatlantis/atlantis/server/core/locking"
 	"github.com/runatlantis/atlantis/server/core/locking/mocks"
+	"github.com/runatlantis/atlantis/server/core/runtime"
 	"github.com/runatlantis/atlantis/server/events"
+	eventMocks "github.com/runatlantis/atlantis/server/events/mocks"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 	"github.com/runatlantis/atlantis/server/logging"
@@ -49,7 +54,7 @@ func TestDefaultProjectLocker_TryLockWhenLocked(t *testing.T) {
 			CurrLock: models.ProjectLock{
 				Pull: lockingPull,
 			},
-			LockKey: "",
+			LockKey: "owner/repo/./default",
 		},
 		nil,
 	)
@@ -59,6 +64,7 @@ func TestDefaultProjectLocker_TryLockWhenLocked(t *testing.T) {
 	Equals(t, &events.TryLockResponse{
 		LockAcquired:      false,
 		LockFailureReason: fmt.Sprintf("This project is currently locked by an unapplied plan from pull %s. To continue, delete the lock from %s or apply that plan and merge the pull request.\n\nOnce the lock is released, comment `atlantis plan` here to re-plan.", link, link),
+		LockKey:           "owner/repo/./default",
 	}, res)
 }
 
@@ -140,6 +146,51 @@ func TestDefaultProjectLocker_TryLockUnlocked(t *testing.T) {
 	mockLocker.VerifyWasCalledOnce().Unlock(lockKey)
 }
 
+// Test that a lock held by another pull request's plan that's older than its
+// plan_ttl is released and acquired.
+func TestDefaultProjectLocker_TryLockWhenLockedByExpiredPlan(t *testing.T) {
+	RegisterMockTestingT(t)
+	repoDir := t.TempDir()
+	planFile := filepath.Join(repoDir, "default.tfplan")
+	Ok(t, os.WriteFile(planFile, nil, 0600))
+	Ok(t, runtime.RecordPlanTTL(planFile, time.Hour))
+	old := time.Now().Add(-2 * time.Hour)
+	Ok(t, os.Chtimes(planFile, old, old))
+
+	var githubClient *vcs.GithubClient
+	mockClient := vcs.NewClientProxy(githubClient, nil, nil, nil, nil, nil)
+	mockLocker := mocks.NewMockLocker()
+	workingDir := eventMocks.NewMockWorkingDir()
+	locker := events.DefaultProjectLocker{
+		Locker:    mockLocker,
+		VCSClient: mockClient,
+		ExpiredPlanDiscarder: &events.ExpiredPlanDiscarder{
+			WorkingDir:          workingDir,
+			Locker:              mockLocker,
+			Backend:             mocks.NewMockBackend(),
+			CommitStatusUpdater: eventMocks.NewMockCommitStatusUpdater(),
+		},
+	}
+	expProject := models.NewProject("owner/repo", ".", "")
+	expWorkspace := "default"
+	expPull := models.PullRequest{Num: 2}
+	expUser := models.User{}
+
+	lockingPull := models.PullRequest{Num: 1}
+	currLock := models.ProjectLock{Project: expProject, Workspace: expWorkspace, Pull: lockingPull}
+	lockKey := "owner/repo/./default"
+	When(workingDir.GetWorkingDir(lockingPull.BaseRepo, lockingPull, expWorkspace)).ThenReturn(repoDir, nil)
+	When(mockLocker.GetLock(lockKey)).ThenReturn(&currLock, nil)
+	When(mockLocker.TryLock(expProject, expWorkspace, expPull, expUser)).
+		ThenReturn(locking.TryLockResponse{LockAcquired: false, CurrLock: currLock, LockKey: lockKey}, nil).
+		ThenReturn(locking.TryLockResponse{LockAcquired: true, CurrLock: models.ProjectLock{Pull: expPull}, LockKey: lockKey}, nil)
+
+	res, err := locker.TryLock(logging.NewNoopLogger(t), expPull, expUser, expWorkspace, expProject, true)
+	Ok(t, err)
+	Equals(t, true, res.LockAcquired)
+	mockLocker.VerifyWasCalledOnce().Unlock(lockKey)
+}
+
 func TestDefaultProjectLocker_RepoLocking(t *testing.T) {
 	var githubClient *vcs.GithubClient
 	mockClient := vcs.NewClientProxy(githubClient, nil, nil, nil, nil, nil)
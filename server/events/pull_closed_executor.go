@@ -54,6 +54,9 @@ type PullClosedExecutor struct {
 	Backend                  locking.Backend
 	PullClosedTemplate       PullCleanupTemplate
 	LogStreamResourceCleaner ResourceCleaner
+	// RunCanceller cancels the commands running for the pull request before
+	// it's cleaned up. If nil they aren't cancelled.
+	RunCanceller *RunCanceller
 }
 
 type templatedProject struct {
@@ -78,6 +81,14 @@ func (t *PullClosedEventTemplate) Execute(wr io.Writer, data interface{}) error
 
 // CleanUpPull cleans up after a closed pull request.
 func (p *PullClosedExecutor) CleanUpPull(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest) error {
+	if p.RunCanceller != nil {
+		// Wait long enough for a running step to be interrupted and the
+		// on_cancel steps to run before deleting the working directory.
+		if n := p.RunCanceller.Cancel(repo, pull.Num, cancelWait(DefaultCancelGracePeriod)); n > 0 {
+			logger.Info("cancelled %d running command(s)", n)
+		}
+	}
+
 	pullStatus, err := p.Backend.GetPullStatus(pull)
 	if err != nil {
 		// Log and continue to clean up other resources.
@@ -1,6 +1,7 @@
 package events
 
 import (
+	"github.com/runatlantis/atlantis/server/core/config/valid"
 	"github.com/runatlantis/atlantis/server/events/command"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 )
@@ -9,6 +10,12 @@ type PullUpdater struct {
 	HidePrevPlanComments bool
 	VCSClient            vcs.Client
 	MarkdownRenderer     *MarkdownRenderer
+	// GlobalCfg is used to check if repos are quiet, in which case successful
+	// plans aren't commented on.
+	GlobalCfg valid.GlobalCfg
+	// ResultExporter exports plan and apply results to the issue or url the
+	// repo's result_export is set to. If nil results aren't exported.
+	ResultExporter *ResultExporter
 }
 
 func (c *PullUpdater) updatePull(ctx *command.Context, cmd PullCommand, res command.Result) {
@@ -19,6 +26,12 @@ func (c *PullUpdater) updatePull(ctx *command.Context, cmd PullCommand, res comm
 		ctx.Log.Warn(res.Failure)
 	}
 
+	// Export the result once it's commented on so exporting doesn't delay
+	// the comment.
+	if c.ResultExporter != nil {
+		defer c.ResultExporter.Export(ctx, cmd, res)
+	}
+
 	// HidePrevCommandComments will hide old comments left from previous runs to reduce
 	// clutter in a pull/merge request. This will not delete the comment, since the
 	// comment trail may be useful in auditing or backtracing problems.
@@ -29,6 +42,13 @@ func (c *PullUpdater) updatePull(ctx *command.Context, cmd PullCommand, res comm
 		}
 	}
 
+	// Quiet repos only get commit statuses for successful plans so failures
+	// stand out.
+	if cmd.CommandName() == command.Plan && !res.HasErrors() && c.GlobalCfg.RepoQuiet(ctx.Pull.BaseRepo.ID()) {
+		ctx.Log.Debug("not commenting on successful plan since repo is quiet")
+		return
+	}
+
 	comment := c.MarkdownRenderer.Render(ctx, res, cmd)
 	if err := c.VCSClient.CreateComment(ctx.Log, ctx.Pull.BaseRepo, ctx.Pull.Num, comment, cmd.CommandName().String()); err != nil {
 		ctx.Log.Err("unable to comment: %s", err)
@@ -0,0 +1,159 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+// resultExportTimeout is how long posting a result to a result_export url can
+// take.
+const resultExportTimeout = 10 * time.Second
+
+// ResultExporter exports plan and apply results to the issue or url set by
+// the result_export of a repo's server-side config, to keep a record of them
+// beyond pull request comments. Failing to export a result is logged but
+// doesn't fail the command.
+type ResultExporter struct {
+	VCSClient vcs.Client
+	GlobalCfg valid.GlobalCfg
+	// HTTPClient posts results to urls. If nil a client with
+	// resultExportTimeout is used.
+	HTTPClient *http.Client
+}
+
+// ResultExportEntry is the record of a plan or apply exported for a repo. It's
+// posted as JSON to result_export's url.
+type ResultExportEntry struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Repo    string    `json:"repo"`
+	Pull    int       `json:"pull"`
+	PullURL string    `json:"pull_url"`
+	// Author is the author of the pull request.
+	Author string `json:"author"`
+	// User is who ran the command.
+	User string `json:"user"`
+	// Reason is the reason an apply was given with --reason.
+	Reason   string                `json:"reason,omitempty"`
+	Success  bool                  `json:"success"`
+	Error    string                `json:"error,omitempty"`
+	Projects []ResultExportProject `json:"projects"`
+}
+
+// ResultExportProject is the outcome of a plan or apply for a single project.
+type ResultExportProject struct {
+	Project   string `json:"project,omitempty"`
+	Dir       string `json:"dir"`
+	Workspace string `json:"workspace"`
+	// Outcome is the project's status after the command, ex. "applied" or
+	// "apply_errored".
+	Outcome string `json:"outcome"`
+}
+
+// Export exports the result res of cmd if the repo sets result_export and cmd
+// is a plan or apply.
+func (e *ResultExporter) Export(ctx *command.Context, cmd PullCommand, res command.Result) {
+	if cmd.CommandName() != command.Plan && cmd.CommandName() != command.Apply {
+		return
+	}
+	export := e.GlobalCfg.RepoResultExport(ctx.Pull.BaseRepo.ID())
+	if export == nil {
+		return
+	}
+	entry := e.entry(ctx, cmd, res)
+	if export.Issue > 0 {
+		if err := e.VCSClient.CreateComment(ctx.Log, ctx.Pull.BaseRepo, export.Issue, entry.Markdown(), ""); err != nil {
+			ctx.Log.Err("unable to export %s result to issue %d: %s", entry.Command, export.Issue, err)
+		}
+	}
+	if export.URL != "" {
+		if err := e.post(export.URL, entry); err != nil {
+			ctx.Log.Err("unable to export %s result to %s: %s", entry.Command, export.URL, err)
+		}
+	}
+}
+
+func (e *ResultExporter) entry(ctx *command.Context, cmd PullCommand, res command.Result) ResultExportEntry {
+	entry := ResultExportEntry{
+		Time:     time.Now().UTC(),
+		Command:  cmd.CommandName().String(),
+		Repo:     ctx.Pull.BaseRepo.FullName,
+		Pull:     ctx.Pull.Num,
+		PullURL:  ctx.Pull.URL,
+		Author:   ctx.Pull.Author,
+		User:     ctx.User.Username,
+		Reason:   ctx.ApplyReason,
+		Success:  !res.HasErrors(),
+		Projects: []ResultExportProject{},
+	}
+	if res.Error != nil {
+		entry.Error = res.Error.Error()
+	} else if res.Failure != "" {
+		entry.Error = res.Failure
+	}
+	for _, p := range res.ProjectResults {
+		if p.Command != command.Plan && p.Command != command.Apply {
+			continue
+		}
+		entry.Projects = append(entry.Projects, ResultExportProject{
+			Project:   p.ProjectName,
+			Dir:       p.RepoRelDir,
+			Workspace: p.Workspace,
+			Outcome:   p.PlanStatus().String(),
+		})
+	}
+	return entry
+}
+
+// Markdown returns the entry as it's commented on result_export's issue.
+func (r ResultExportEntry) Markdown() string {
+	var b strings.Builder
+	outcome := "succeeded"
+	if !r.Success {
+		outcome = "failed"
+	}
+	fmt.Fprintf(&b, "**%s %s** on [%s#%d](%s) by @%s (pull request author @%s) at %s\n",
+		strings.ToUpper(r.Command[:1])+r.Command[1:], outcome, r.Repo, r.Pull, r.PullURL, r.User, r.Author, r.Time.Format("2006-01-02 15:04:05 MST"))
+	if r.Reason != "" {
+		fmt.Fprintf(&b, "\nReason: `%s`\n", r.Reason)
+	}
+	if r.Error != "" {
+		fmt.Fprintf(&b, "\n```\n%s\n```\n", r.Error)
+	}
+	if len(r.Projects) > 0 {
+		b.WriteString("\n| Project | Dir | Workspace | Outcome |\n| --- | --- | --- | --- |\n")
+		for _, p := range r.Projects {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", p.Project, p.Dir, p.Workspace, p.Outcome)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// post posts entry to url as JSON.
+func (e *ResultExporter) post(url string, entry ResultExportEntry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	client := e.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: resultExportTimeout}
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("got status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package events_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/petergtz/pegomock/v4"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/models"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func resultExportCtx(t *testing.T) *command.Context {
+	return &command.Context{
+		Log:  logging.NewNoopLogger(t),
+		User: models.User{Username: "lkysow"},
+		Pull: models.PullRequest{
+			Num:      12,
+			URL:      "https://github.com/owner/repo/pull/12",
+			Author:   "author",
+			BaseRepo: models.Repo{FullName: "owner/repo", Owner: "owner", Name: "repo", VCSHost: models.VCSHost{Hostname: "github.com"}},
+		},
+	}
+}
+
+func resultExportCfg(export valid.ResultExport) valid.GlobalCfg {
+	return valid.GlobalCfg{
+		Repos: []valid.Repo{
+			{IDRegex: regexp.MustCompile(".*"), ResultExport: &export},
+		},
+	}
+}
+
+var applyResult = command.Result{
+	ProjectResults: []command.ProjectResult{
+		{
+			Command:      command.Apply,
+			ProjectName:  "prod",
+			RepoRelDir:   "prod",
+			Workspace:    "default",
+			ApplySuccess: "success",
+		},
+		{
+			Command:    command.Apply,
+			RepoRelDir: "staging",
+			Workspace:  "staging",
+			Error:      errors.New("apply failed"),
+		},
+	},
+}
+
+// Test that apply results are commented on the tracking issue and posted to
+// the url.
+func TestResultExporter_Export(t *testing.T) {
+	RegisterMockTestingT(t)
+	var posted events.ResultExportEntry
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "application/json", r.Header.Get("Content-Type"))
+		Ok(t, json.NewDecoder(r.Body).Decode(&posted))
+	}))
+	defer server.Close()
+
+	vcsClient := vcsmocks.NewMockClient()
+	exporter := &events.ResultExporter{
+		VCSClient: vcsClient,
+		GlobalCfg: resultExportCfg(valid.ResultExport{Issue: 42, URL: server.URL}),
+	}
+	exporter.Export(resultExportCtx(t), &events.CommentCommand{Name: command.Apply}, applyResult)
+
+	_, repo, _, comment, _ := vcsClient.VerifyWasCalledOnce().CreateComment(
+		Any[logging.SimpleLogging](), Any[models.Repo](), Eq(42), Any[string](), Eq("")).GetCapturedArguments()
+	Equals(t, "owner/repo", repo.FullName)
+	Assert(t, strings.HasPrefix(comment, "**Apply failed** on [owner/repo#12](https://github.com/owner/repo/pull/12) by @lkysow (pull request author @author) at "), "unexpected comment %q", comment)
+	Assert(t, strings.HasSuffix(comment, `
+| Project | Dir | Workspace | Outcome |
+| --- | --- | --- | --- |
+| prod | prod | default | applied |
+|  | staging | staging | apply_errored |`), "unexpected comment %q", comment)
+
+	Equals(t, "apply", posted.Command)
+	Equals(t, "owner/repo", posted.Repo)
+	Equals(t, 12, posted.Pull)
+	Equals(t, "author", posted.Author)
+	Equals(t, "lkysow", posted.User)
+	Equals(t, false, posted.Success)
+	Equals(t, []events.ResultExportProject{
+		{Project: "prod", Dir: "prod", Workspace: "default", Outcome: "applied"},
+		{Dir: "staging", Workspace: "staging", Outcome: "apply_errored"},
+	}, posted.Projects)
+	Assert(t, !posted.Time.IsZero(), "exp entry to be dated")
+}
+
+// Test that the reason an apply was given is exported with it.
+func TestResultExporter_ExportApplyReason(t *testing.T) {
+	RegisterMockTestingT(t)
+	var posted events.ResultExportEntry
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Ok(t, json.NewDecoder(r.Body).Decode(&posted))
+	}))
+	defer server.Close()
+
+	vcsClient := vcsmocks.NewMockClient()
+	exporter := &events.ResultExporter{
+		VCSClient: vcsClient,
+		GlobalCfg: resultExportCfg(valid.ResultExport{Issue: 42, URL: server.URL}),
+	}
+	ctx := resultExportCtx(t)
+	ctx.ApplyReason = "JIRA-123 emergency fix"
+	exporter.Export(ctx, &events.CommentCommand{Name: command.Apply}, applyResult)
+
+	_, _, _, comment, _ := vcsClient.VerifyWasCalledOnce().CreateComment(
+		Any[logging.SimpleLogging](), Any[models.Repo](), Eq(42), Any[string](), Eq("")).GetCapturedArguments()
+	Assert(t, strings.Contains(comment, "\n\nReason: `JIRA-123 emergency fix`\n\n| Project |"), "unexpected comment %q", comment)
+	Equals(t, "JIRA-123 emergency fix", posted.Reason)
+}
+
+// Test that failing to export doesn't stop the other target from being
+// exported to.
+func TestResultExporter_ExportFailures(t *testing.T) {
+	RegisterMockTestingT(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	vcsClient := vcsmocks.NewMockClient()
+	When(vcsClient.CreateComment(Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]())).
+		ThenReturn(errors.New("issue not found"))
+	exporter := &events.ResultExporter{
+		VCSClient: vcsClient,
+		GlobalCfg: resultExportCfg(valid.ResultExport{Issue: 42, URL: server.URL}),
+	}
+	exporter.Export(resultExportCtx(t), &events.CommentCommand{Name: command.Apply}, applyResult)
+	vcsClient.VerifyWasCalledOnce().CreateComment(
+		Any[logging.SimpleLogging](), Any[models.Repo](), Eq(42), Any[string](), Any[string]())
+}
+
+// Test that only plans and applies of repos with result_export are exported.
+func TestResultExporter_ExportSkipped(t *testing.T) {
+	RegisterMockTestingT(t)
+	vcsClient := vcsmocks.NewMockClient()
+	exporter := &events.ResultExporter{
+		VCSClient: vcsClient,
+		GlobalCfg: resultExportCfg(valid.ResultExport{Issue: 42}),
+	}
+	exporter.Export(resultExportCtx(t), &events.CommentCommand{Name: command.Unlock}, command.Result{})
+
+	exporter.GlobalCfg = valid.GlobalCfg{}
+	exporter.Export(resultExportCtx(t), &events.CommentCommand{Name: command.Apply}, applyResult)
+	vcsClient.VerifyWasCalled(Never()).CreateComment(
+		Any[logging.SimpleLogging](), Any[models.Repo](), Any[int](), Any[string](), Any[string]())
+}
@@ -0,0 +1,96 @@
+package events
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+// maxReviewComments is the most review comments a step's review has. Further
+// annotations are added to the review's body instead so large outputs don't
+// flood the pull request.
+const maxReviewComments = 50
+
+// annotationRegex matches an annotation in the output of a linter, ex.
+// "main.tf:12: message" or "modules/vpc/main.tf:3:5: message". Paths are
+// relative to the project's dir, where the command runs.
+var annotationRegex = regexp.MustCompile(`^([^\s:][^:]*):(\d+)(?::\d+)?:\s*(\S.*)$`)
+
+// ReviewStepComments posts the annotations in the output of run steps with
+// thread review as review comments on the lines they refer to, rather than
+// in the command's comment.
+type ReviewStepComments struct {
+	VCSClient vcs.Client
+}
+
+// Post creates a review of ctx's pull request with the annotations in output
+// of step and returns the line that replaces output in the command's comment.
+// If output has no annotations, or the VCS host doesn't support review
+// comments, output is returned so it's commented as usual.
+func (r *ReviewStepComments) Post(ctx command.ProjectContext, step valid.Step, output string) string {
+	comments, rest := reviewComments(ctx.RepoRelDir, output)
+	if len(comments) == 0 {
+		return output
+	}
+	commenter, ok := r.VCSClient.(vcs.ReviewCommenter)
+	if !ok {
+		return output
+	}
+
+	body := fmt.Sprintf("**Output of `%s`** from %s in dir: `%s` workspace: `%s`", step.RunCommand, ctx.CommandName.String(), ctx.RepoRelDir, ctx.Workspace)
+	if len(rest) > 0 {
+		body += fmt.Sprintf("\n```\n%s\n```", strings.Join(rest, "\n"))
+	}
+	if len(body) > separateCommentMaxLength {
+		ctx.Log.Warn("output of %q is too long to post as a review, adding it to the %s comment", step.RunCommand, ctx.CommandName.String())
+		return output
+	}
+	if err := commenter.CreateReview(ctx.Log, ctx.Pull.BaseRepo, ctx.Pull, body, comments); err != nil {
+		if err != vcs.ErrReviewsNotSupported {
+			ctx.Log.Warn("unable to post output of %q as a review, adding it to the %s comment: %s", step.RunCommand, ctx.CommandName.String(), err)
+		}
+		return output
+	}
+	return fmt.Sprintf("Output of `%s` posted as a review with %d comments.", step.RunCommand, len(comments))
+}
+
+// reviewComments returns the review comments for the annotations in output of
+// a step run in repoRelDir, and the lines of output that aren't posted as
+// review comments. Annotations of the same line are combined into one
+// comment.
+func reviewComments(repoRelDir string, output string) ([]vcs.ReviewComment, []string) {
+	var comments []vcs.ReviewComment
+	var rest []string
+	index := make(map[string]int)
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		match := annotationRegex.FindStringSubmatch(line)
+		if match == nil || path.IsAbs(match[1]) {
+			rest = append(rest, line)
+			continue
+		}
+		file := path.Join(repoRelDir, match[1])
+		lineNum, err := strconv.Atoi(match[2])
+		if err != nil || lineNum == 0 || file == ".." || strings.HasPrefix(file, "../") {
+			rest = append(rest, line)
+			continue
+		}
+		key := fmt.Sprintf("%s:%d", file, lineNum)
+		if i, ok := index[key]; ok {
+			comments[i].Body += "\n" + match[3]
+			continue
+		}
+		if len(comments) == maxReviewComments {
+			rest = append(rest, line)
+			continue
+		}
+		index[key] = len(comments)
+		comments = append(comments, vcs.ReviewComment{Path: file, Line: lineNum, Body: match[3]})
+	}
+	return comments, rest
+}
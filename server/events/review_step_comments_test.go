@@ -0,0 +1,76 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/petergtz/pegomock/v4"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// reviewClient is a vcs.Client that supports review comments.
+type reviewClient struct {
+	*vcsmocks.MockClient
+	err      error
+	body     string
+	comments []vcs.ReviewComment
+}
+
+func (c *reviewClient) CreateReview(_ logging.SimpleLogging, _ models.Repo, _ models.PullRequest, body string, comments []vcs.ReviewComment) error {
+	c.body = body
+	c.comments = comments
+	return c.err
+}
+
+func TestReviewStepComments_Post(t *testing.T) {
+	RegisterMockTestingT(t)
+	ctx := command.ProjectContext{
+		Log:         logging.NewNoopLogger(t),
+		CommandName: command.Plan,
+		Pull:        models.PullRequest{Num: 1},
+		RepoRelDir:  "mydir",
+		Workspace:   "default",
+	}
+	step := valid.Step{StepName: "run", RunCommand: "./lint.sh", Thread: valid.ThreadReview}
+	output := "main.tf:3: missing description\n./modules/vpc/main.tf:12:5: unused variable\nmain.tf:3: missing type\n/tmp/other.tf:1: outside repo\n2 issues found\n"
+
+	t.Run("posts annotations as review comments", func(t *testing.T) {
+		client := &reviewClient{MockClient: vcsmocks.NewMockClient()}
+		out := (&events.ReviewStepComments{VCSClient: client}).Post(ctx, step, output)
+		Equals(t, "Output of `./lint.sh` posted as a review with 2 comments.", out)
+		Equals(t, []vcs.ReviewComment{
+			{Path: "mydir/main.tf", Line: 3, Body: "missing description\nmissing type"},
+			{Path: "mydir/modules/vpc/main.tf", Line: 12, Body: "unused variable"},
+		}, client.comments)
+		Equals(t, "**Output of `./lint.sh`** from plan in dir: `mydir` workspace: `default`\n```\n/tmp/other.tf:1: outside repo\n2 issues found\n```", client.body)
+	})
+
+	t.Run("output without annotations", func(t *testing.T) {
+		client := &reviewClient{MockClient: vcsmocks.NewMockClient()}
+		out := (&events.ReviewStepComments{VCSClient: client}).Post(ctx, step, "no issues\n")
+		Equals(t, "no issues\n", out)
+		Equals(t, 0, len(client.comments))
+	})
+
+	t.Run("falls back to the command's comment if reviews aren't supported", func(t *testing.T) {
+		out := (&events.ReviewStepComments{VCSClient: vcsmocks.NewMockClient()}).Post(ctx, step, output)
+		Equals(t, output, out)
+
+		client := &reviewClient{MockClient: vcsmocks.NewMockClient(), err: vcs.ErrReviewsNotSupported}
+		out = (&events.ReviewStepComments{VCSClient: client}).Post(ctx, step, output)
+		Equals(t, output, out)
+	})
+
+	t.Run("falls back to the command's comment if the review fails", func(t *testing.T) {
+		client := &reviewClient{MockClient: vcsmocks.NewMockClient(), err: errors.New("line must be part of the diff")}
+		out := (&events.ReviewStepComments{VCSClient: client}).Post(ctx, step, output)
+		Equals(t, output, out)
+	})
+}
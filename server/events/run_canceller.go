@@ -0,0 +1,102 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	runtimemodels "github.com/runatlantis/atlantis/server/core/runtime/models"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// DefaultCancelGracePeriod is how long the on_cancel steps of a cancelled run
+// are given to finish before they're interrupted.
+const DefaultCancelGracePeriod = 30 * time.Second
+
+// RunCanceller cancels the commands running for a pull request when it's
+// closed. The commands a cancelled run is running are interrupted, it stops
+// before its next step and runs its workflow's on_cancel steps.
+type RunCanceller struct {
+	mu   sync.Mutex
+	runs map[string][]*cancellableRun
+}
+
+// cancellableRun is a command running for a pull request.
+type cancellableRun struct {
+	cancelled chan struct{}
+	cancel    sync.Once
+	done      chan struct{}
+}
+
+// NewRunCanceller returns a RunCanceller with no runs.
+func NewRunCanceller() *RunCanceller {
+	return &RunCanceller{runs: make(map[string][]*cancellableRun)}
+}
+
+// Start registers a run of a command for the pull request of repo. It returns
+// a channel that's closed once the run is cancelled, and a function that must
+// be called when the run is done.
+func (c *RunCanceller) Start(repo models.Repo, pullNum int) (<-chan struct{}, func()) {
+	run := &cancellableRun{cancelled: make(chan struct{}), done: make(chan struct{})}
+	key := runCancellerKey(repo, pullNum)
+
+	c.mu.Lock()
+	c.runs[key] = append(c.runs[key], run)
+	c.mu.Unlock()
+
+	var once sync.Once
+	done := func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			var kept []*cancellableRun
+			for _, r := range c.runs[key] {
+				if r != run {
+					kept = append(kept, r)
+				}
+			}
+			if len(kept) == 0 {
+				delete(c.runs, key)
+			} else {
+				c.runs[key] = kept
+			}
+			close(run.done)
+		})
+	}
+	return run.cancelled, done
+}
+
+// Cancel cancels the runs of the commands of repo's pull request and waits up
+// to wait for them to finish. It returns the number of runs cancelled.
+func (c *RunCanceller) Cancel(repo models.Repo, pullNum int, wait time.Duration) int {
+	c.mu.Lock()
+	runs := append([]*cancellableRun(nil), c.runs[runCancellerKey(repo, pullNum)]...)
+	c.mu.Unlock()
+
+	for _, run := range runs {
+		run.cancel.Do(func() { close(run.cancelled) })
+	}
+	timeout := time.After(wait)
+	for _, run := range runs {
+		select {
+		case <-run.done:
+		case <-timeout:
+			return len(runs)
+		}
+	}
+	return len(runs)
+}
+
+// cancelWait returns how long cancelled runs can take to finish when their
+// on_cancel steps are given gracePeriod: the interrupted command and then the
+// on_cancel steps each get runtimemodels.DeadlineKillGracePeriod to exit
+// before they're killed.
+func cancelWait(gracePeriod time.Duration) time.Duration {
+	return gracePeriod + 2*runtimemodels.DeadlineKillGracePeriod
+}
+
+// runCancellerKey identifies the pull request of repo, including its VCS host
+// since repos with the same name can be hosted on different ones.
+func runCancellerKey(repo models.Repo, pullNum int) string {
+	return fmt.Sprintf("%s#%d", repo.ID(), pullNum)
+}
@@ -0,0 +1,60 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestRunCanceller_Cancel(t *testing.T) {
+	c := events.NewRunCanceller()
+	repo := models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Hostname: "github.com"}}
+	// A repo with the same name on another host.
+	otherHostRepo := models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Hostname: "gitlab.com"}}
+	cancelled, done := c.Start(repo, 1)
+	otherCancelled, otherDone := c.Start(repo, 2)
+	defer otherDone()
+	otherHostCancelled, otherHostDone := c.Start(otherHostRepo, 1)
+	defer otherHostDone()
+	Assert(t, !isClosed(cancelled), "exp run not to be cancelled")
+
+	// Cancel waits for the run to be done.
+	go func() {
+		<-cancelled
+		done()
+	}()
+	Equals(t, 1, c.Cancel(repo, 1, 5*time.Second))
+	Assert(t, isClosed(cancelled), "exp run to be cancelled")
+	Assert(t, !isClosed(otherCancelled), "exp the other pull request's run not to be cancelled")
+	Assert(t, !isClosed(otherHostCancelled), "exp the run of the repo on the other host not to be cancelled")
+
+	// Done runs are forgotten.
+	Equals(t, 0, c.Cancel(repo, 1, time.Second))
+}
+
+func TestRunCanceller_CancelWait(t *testing.T) {
+	c := events.NewRunCanceller()
+	repo := models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Hostname: "github.com"}}
+	cancelled, done := c.Start(repo, 1)
+	defer done()
+
+	start := time.Now()
+	Equals(t, 1, c.Cancel(repo, 1, 10*time.Millisecond))
+	Assert(t, isClosed(cancelled), "exp run to be cancelled")
+	Assert(t, time.Since(start) < time.Second, "exp not to wait for the run longer than the wait")
+
+	// Cancelling again doesn't panic on the closed channel.
+	Equals(t, 1, c.Cancel(repo, 1, 10*time.Millisecond))
+}
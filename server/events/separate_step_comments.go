@@ -0,0 +1,113 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+// separateCommentMaxLength is the longest comment that's created so it can be
+// updated in place. It's under GitHub's limit, the lowest of the VCS hosts
+// that support editing comments. Longer comments are split by
+// vcs.Client.CreateComment instead.
+const separateCommentMaxLength = 60000
+
+// SeparateStepComments posts the output of run steps with comment_mode
+// separate as their own comments rather than in the command's comment. The
+// comment of a step is updated in place when it runs again for the same
+// project, if the VCS host supports editing comments.
+type SeparateStepComments struct {
+	VCSClient vcs.Client
+
+	mu sync.Mutex
+	// commentIDs are the IDs of the comments of each step, keyed by
+	// separateCommentKey. They're kept in memory so after a restart the next
+	// run creates a new comment.
+	commentIDs map[string]int64
+}
+
+// NewSeparateStepComments returns a SeparateStepComments commenting with
+// vcsClient.
+func NewSeparateStepComments(vcsClient vcs.Client) *SeparateStepComments {
+	return &SeparateStepComments{
+		VCSClient:  vcsClient,
+		commentIDs: make(map[string]int64),
+	}
+}
+
+// Post comments output of step on ctx's pull request and returns the line
+// that replaces it in the command's comment.
+func (s *SeparateStepComments) Post(ctx command.ProjectContext, step valid.Step, output string) string {
+	comment := fmt.Sprintf("**Output of `%s`** from %s in dir: `%s` workspace: `%s`\n", step.RunCommand, ctx.CommandName.String(), ctx.RepoRelDir, ctx.Workspace)
+	if step.Render == "" || step.Render == valid.RenderRaw {
+		comment += fmt.Sprintf("```\n%s\n```", strings.TrimRight(output, "\n"))
+	} else {
+		comment += output
+	}
+
+	if err := s.comment(ctx, step, comment); err != nil {
+		ctx.Log.Warn("unable to post output of %q as a separate comment, adding it to the %s comment: %s", step.RunCommand, ctx.CommandName.String(), err)
+		return output
+	}
+	return fmt.Sprintf("Output of `%s` posted as a separate comment.", step.RunCommand)
+}
+
+// comment updates the step's comment from a previous run or creates it. If
+// the VCS host doesn't support editing comments, or comment is too long to
+// post as a single comment, it's created with CreateComment which splits it
+// as needed.
+func (s *SeparateStepComments) comment(ctx command.ProjectContext, step valid.Step, comment string) error {
+	repo := ctx.Pull.BaseRepo
+	pullNum := ctx.Pull.Num
+	key := separateCommentKey(ctx, step)
+
+	updater, ok := s.VCSClient.(vcs.CommentUpdater)
+	if !ok || len(comment) > separateCommentMaxLength {
+		s.forget(key)
+		return s.VCSClient.CreateComment(ctx.Log, repo, pullNum, comment, ctx.CommandName.String())
+	}
+
+	if commentID, ok := s.commentID(key); ok {
+		err := updater.UpdateComment(ctx.Log, repo, pullNum, commentID, comment)
+		if err == nil {
+			return nil
+		}
+		// The comment may have been deleted, create a new one.
+		ctx.Log.Debug("unable to update comment %d, creating a new one: %s", commentID, err)
+	}
+	commentID, err := updater.CreateUpdatableComment(ctx.Log, repo, pullNum, comment)
+	if err == vcs.ErrCommentUpdatesNotSupported {
+		s.forget(key)
+		return s.VCSClient.CreateComment(ctx.Log, repo, pullNum, comment, ctx.CommandName.String())
+	}
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commentIDs[key] = commentID
+	return nil
+}
+
+func (s *SeparateStepComments) commentID(key string) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	commentID, ok := s.commentIDs[key]
+	return commentID, ok
+}
+
+func (s *SeparateStepComments) forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.commentIDs, key)
+}
+
+// separateCommentKey identifies the comment of step for ctx's project and
+// command.
+func separateCommentKey(ctx command.ProjectContext, step valid.Step) string {
+	return fmt.Sprintf("%s#%d/%s/%s/%s/%s/%s", ctx.Pull.BaseRepo.FullName, ctx.Pull.Num, ctx.CommandName.String(), ctx.ProjectName, ctx.RepoRelDir, ctx.Workspace, step.RunCommand)
+}
@@ -0,0 +1,85 @@
+package events_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/petergtz/pegomock/v4"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/models"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// updatableClient is a vcs.Client that supports editing comments.
+type updatableClient struct {
+	*vcsmocks.MockClient
+	created map[int64]string
+	updated map[int64]string
+}
+
+func (c *updatableClient) CreateUpdatableComment(_ logging.SimpleLogging, _ models.Repo, _ int, comment string) (int64, error) {
+	id := int64(len(c.created) + 1)
+	c.created[id] = comment
+	return id, nil
+}
+
+func (c *updatableClient) UpdateComment(_ logging.SimpleLogging, _ models.Repo, _ int, commentID int64, comment string) error {
+	c.updated[commentID] = comment
+	return nil
+}
+
+func TestSeparateStepComments_Post(t *testing.T) {
+	RegisterMockTestingT(t)
+	client := &updatableClient{
+		MockClient: vcsmocks.NewMockClient(),
+		created:    make(map[int64]string),
+		updated:    make(map[int64]string),
+	}
+	comments := events.NewSeparateStepComments(client)
+	ctx := command.ProjectContext{
+		Log:         logging.NewNoopLogger(t),
+		CommandName: command.Plan,
+		Pull:        models.PullRequest{Num: 1},
+		RepoRelDir:  "mydir",
+		Workspace:   "default",
+	}
+	step := valid.Step{StepName: "run", RunCommand: "./report.sh", CommentMode: valid.CommentModeSeparate}
+
+	out := comments.Post(ctx, step, "first\n")
+	Equals(t, "Output of `./report.sh` posted as a separate comment.", out)
+	Equals(t, map[int64]string{1: "**Output of `./report.sh`** from plan in dir: `mydir` workspace: `default`\n```\nfirst\n```"}, client.created)
+
+	// Running the step again updates its comment.
+	comments.Post(ctx, step, "second\n")
+	Equals(t, 1, len(client.created))
+	Equals(t, map[int64]string{1: "**Output of `./report.sh`** from plan in dir: `mydir` workspace: `default`\n```\nsecond\n```"}, client.updated)
+
+	// Output too long for a single comment is split by CreateComment.
+	long := strings.Repeat("a", 70000)
+	comments.Post(ctx, step, long)
+	Equals(t, 1, len(client.created))
+	client.MockClient.VerifyWasCalledOnce().CreateComment(Any[logging.SimpleLogging](), Any[models.Repo](), Eq(1), Any[string](), Eq("plan"))
+}
+
+func TestSeparateStepComments_PostNotUpdatable(t *testing.T) {
+	RegisterMockTestingT(t)
+	client := vcsmocks.NewMockClient()
+	comments := events.NewSeparateStepComments(client)
+	ctx := command.ProjectContext{
+		Log:         logging.NewNoopLogger(t),
+		CommandName: command.Apply,
+		Pull:        models.PullRequest{Num: 1},
+		RepoRelDir:  ".",
+		Workspace:   "default",
+	}
+	step := valid.Step{StepName: "run", RunCommand: "./report.sh", CommentMode: valid.CommentModeSeparate, Render: valid.RenderTable}
+
+	out := comments.Post(ctx, step, "| a |\n| --- |\n| 1 |")
+	Equals(t, "Output of `./report.sh` posted as a separate comment.", out)
+	client.VerifyWasCalledOnce().CreateComment(Any[logging.SimpleLogging](), Any[models.Repo](), Eq(1),
+		Eq("**Output of `./report.sh`** from apply in dir: `.` workspace: `default`\n| a |\n| --- |\n| 1 |"), Eq("apply"))
+}
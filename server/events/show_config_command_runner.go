@@ -0,0 +1,416 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/core/config/raw"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"gopkg.in/yaml.v3"
+)
+
+// maskedConfigValue replaces the values of secret-bearing settings in
+// show-config's comment.
+const maskedConfigValue = "***"
+
+func NewShowConfigCommandRunner(
+	vcsClient vcs.Client,
+	prjCmdBuilder ProjectPlanCommandBuilder,
+) *ShowConfigCommandRunner {
+	return &ShowConfigCommandRunner{
+		vcsClient:     vcsClient,
+		prjCmdBuilder: prjCmdBuilder,
+	}
+}
+
+// ShowConfigCommandRunner comments the effective config of the projects
+// atlantis plan would run on: the repo config merged with the server-side
+// repo config, and which of the server-side settings the repo config
+// overrode, so users can audit the config that applies to a pull request.
+type ShowConfigCommandRunner struct {
+	vcsClient     vcs.Client
+	prjCmdBuilder ProjectPlanCommandBuilder
+}
+
+func (s *ShowConfigCommandRunner) Run(ctx *command.Context, cmd *CommentCommand) {
+	baseRepo := ctx.Pull.BaseRepo
+	pullNum := ctx.Pull.Num
+
+	var vcsMessage string
+	projectCmds, err := s.buildProjectCmds(ctx, cmd)
+	if err == nil && len(projectCmds) > 0 {
+		vcsMessage, err = showConfigComment(projectCmds)
+	}
+	switch {
+	case err != nil:
+		ctx.Log.Err("failed to show config: %s", err)
+		vcsMessage = fmt.Sprintf("Failed to show config: %s", err)
+	case len(projectCmds) == 0:
+		vcsMessage = "No projects are modified in this pull request."
+	}
+
+	if commentErr := s.vcsClient.CreateComment(ctx.Log, baseRepo, pullNum, vcsMessage, command.ShowConfig.String()); commentErr != nil {
+		ctx.Log.Err("unable to comment: %s", commentErr)
+	}
+}
+
+// buildProjectCmds returns the projects plan would run on for cmd, with the
+// same -d, -w and -p flags.
+func (s *ShowConfigCommandRunner) buildProjectCmds(ctx *command.Context, cmd *CommentCommand) ([]command.ProjectContext, error) {
+	planCmd := *cmd
+	planCmd.Name = command.Plan
+	projectCmds, err := s.prjCmdBuilder.BuildPlanCommands(ctx, &planCmd)
+	var tooManyErr *TooManyProjectsError
+	if errors.As(err, &tooManyErr) {
+		return tooManyErr.Projects, nil
+	}
+	return projectCmds, err
+}
+
+// showConfigComment returns the comment showing the effective config of
+// projectCmds.
+func showConfigComment(projectCmds []command.ProjectContext) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Effective config of %d project(s). Server-side settings are set by the server-side repo config unless the repo config overrode them. Secret values are masked.\n", len(projectCmds))
+	for _, projectCmd := range projectCmds {
+		b.WriteString("\n#### ")
+		if projectCmd.ProjectName != "" {
+			fmt.Fprintf(&b, "project: `%s` ", projectCmd.ProjectName)
+		}
+		fmt.Fprintf(&b, "dir: `%s` workspace: `%s`\n", projectCmd.RepoRelDir, projectCmd.Workspace)
+		if projectCmd.RepoConfigFile != "" {
+			fmt.Fprintf(&b, "Repo config: `%s`\n", projectCmd.RepoConfigFile)
+		}
+
+		b.WriteString("\n| Server-side setting | Value | Set by |\n")
+		b.WriteString("| --- | --- | --- |\n")
+		for _, setting := range serverSideSettings(projectCmd) {
+			setBy := "server-side repo config"
+			if slices.Contains(projectCmd.RepoCfgKeys, setting.key) {
+				setBy = "**repo config**"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", setting.key, setting.value, setBy)
+		}
+
+		projectYAML, err := yaml.Marshal(newProjectConfigView(projectCmd))
+		if err != nil {
+			return "", fmt.Errorf("rendering the config of dir %q workspace %q: %w", projectCmd.RepoRelDir, projectCmd.Workspace, err)
+		}
+		fmt.Fprintf(&b, "\n```yaml\n%s```\n", projectYAML)
+	}
+	return b.String(), nil
+}
+
+// configSetting is a server-side setting shown by show-config, with its
+// value formatted for a markdown table.
+type configSetting struct {
+	key   string
+	value string
+}
+
+// serverSideSettings returns the settings of projectCmd the server-side repo
+// config controls.
+func serverSideSettings(projectCmd command.ProjectContext) []configSetting {
+	list := func(values []string) string {
+		if len(values) == 0 {
+			return "none"
+		}
+		return "`" + strings.Join(values, ", ") + "`"
+	}
+	code := func(value string) string {
+		if value == "" {
+			return "none"
+		}
+		return "`" + value + "`"
+	}
+
+	planTTL := "none"
+	if projectCmd.PlanTTL > 0 {
+		planTTL = code(projectCmd.PlanTTL.String())
+	}
+	providerMirror := "none"
+	if mirror := projectCmd.ProviderMirror; mirror != nil {
+		providerMirror = code(mirror.URL + mirror.Path)
+	}
+	runCommandPolicy := "none"
+	if policy := projectCmd.RunCommandPolicy; !policy.IsEmpty() {
+		runCommandPolicy = fmt.Sprintf("allowed: %s, denied: %s", list(policy.Allowed), list(policy.Denied))
+	}
+
+	return []configSetting{
+		{valid.WorkflowKey, code(projectCmd.WorkflowName)},
+		{valid.PlanRequirementsKey, list(projectCmd.PlanRequirements)},
+		{valid.ApplyRequirementsKey, list(projectCmd.ApplyRequirements)},
+		{valid.ImportRequirementsKey, list(projectCmd.ImportRequirements)},
+		{valid.DeleteSourceBranchOnMergeKey, code(fmt.Sprint(projectCmd.DeleteSourceBranchOnMerge))},
+		{valid.RepoLocksKey, code(string(projectCmd.RepoLocksMode))},
+		{valid.CustomPolicyCheckKey, code(fmt.Sprint(projectCmd.CustomPolicyCheck))},
+		{valid.PlanTTLKey, planTTL},
+		{"plugin_cache_dir", code(projectCmd.PluginCacheDir)},
+		{"provider_mirror", providerMirror},
+		{"run_command_policy", runCommandPolicy},
+	}
+}
+
+// projectConfigView is the YAML show-config renders of a project's settings
+// and workflow.
+type projectConfigView struct {
+	TerraformVersion    string            `yaml:"terraform_version,omitempty"`
+	Autoplan            bool              `yaml:"autoplan"`
+	DependsOn           []string          `yaml:"depends_on,omitempty"`
+	ExecutionOrderGroup int               `yaml:"execution_order_group,omitempty"`
+	Chdir               bool              `yaml:"chdir,omitempty"`
+	CLIConfig           string            `yaml:"cli_config,omitempty"`
+	TFVars              map[string]string `yaml:"tf_vars,omitempty"`
+	BackendConfigs      map[string]string `yaml:"backend_configs,omitempty"`
+	Workflow            workflowView      `yaml:"workflow"`
+}
+
+// workflowView is the YAML show-config renders of a workflow.
+type workflowView struct {
+	Name           string        `yaml:"name"`
+	Engine         valid.Engine  `yaml:"engine,omitempty"`
+	Timeout        string        `yaml:"timeout,omitempty"`
+	EnvPassthrough []string      `yaml:"env_passthrough,omitempty"`
+	Plan           []interface{} `yaml:"plan,omitempty"`
+	Apply          []interface{} `yaml:"apply,omitempty"`
+	PolicyCheck    []interface{} `yaml:"policy_check,omitempty"`
+	Import         []interface{} `yaml:"import,omitempty"`
+	StateRm        []interface{} `yaml:"state_rm,omitempty"`
+	OnCancel       []interface{} `yaml:"on_cancel,omitempty"`
+}
+
+func newProjectConfigView(projectCmd command.ProjectContext) projectConfigView {
+	view := projectConfigView{
+		Autoplan:            projectCmd.AutoplanEnabled,
+		DependsOn:           projectCmd.DependsOn,
+		ExecutionOrderGroup: projectCmd.ExecutionOrderGroup,
+		Chdir:               projectCmd.Chdir,
+		CLIConfig:           projectCmd.CLIConfig,
+		BackendConfigs:      projectCmd.BackendConfigs,
+	}
+	if projectCmd.TerraformVersion != nil {
+		view.TerraformVersion = projectCmd.TerraformVersion.String()
+	}
+	// tf_vars are passed as TF_VAR_ environment variables, which often hold
+	// credentials.
+	if len(projectCmd.TFVars) > 0 {
+		view.TFVars = make(map[string]string, len(projectCmd.TFVars))
+		for name := range projectCmd.TFVars {
+			view.TFVars[name] = maskedConfigValue
+		}
+	}
+
+	workflow := projectCmd.Workflow
+	view.Workflow = workflowView{
+		Name:           workflow.Name,
+		Engine:         workflow.Engine,
+		EnvPassthrough: workflow.EnvPassthrough,
+		Plan:           stepViews(workflow.Plan.Steps),
+		Apply:          stepViews(workflow.Apply.Steps),
+		PolicyCheck:    stepViews(workflow.PolicyCheck.Steps),
+		Import:         stepViews(workflow.Import.Steps),
+		StateRm:        stepViews(workflow.StateRm.Steps),
+		OnCancel:       stepViews(workflow.OnCancel.Steps),
+	}
+	if workflow.Timeout > 0 {
+		view.Workflow.Timeout = workflow.Timeout.String()
+	}
+	return view
+}
+
+func stepViews(steps []valid.Step) []interface{} {
+	var views []interface{}
+	for _, step := range steps {
+		views = append(views, stepView(step))
+	}
+	return views
+}
+
+// stepOption is an option of a workflow step, keyed the way it's written in
+// the repo config.
+type stepOption struct {
+	key string
+	// value returns the option's value, or nil if the step doesn't set it.
+	value func(step valid.Step) interface{}
+	// secret is set for options that can hold secrets, whose values are
+	// masked.
+	secret bool
+}
+
+// stepOptions are the options of every step type. Steps only set the options
+// of their type, so each step is shown with the options it sets.
+var stepOptions = []stepOption{
+	{key: raw.NameArgKey, value: func(s valid.Step) interface{} { return nonZero(s.EnvVarName) }},
+	{key: raw.CommandArgKey, value: func(s valid.Step) interface{} { return nonZero(s.RunCommand) }},
+	{key: raw.ValueArgKey, value: func(s valid.Step) interface{} { return nonZero(s.EnvVarValue) }, secret: true},
+	{key: raw.FromSSMPathArgKey, value: func(s valid.Step) interface{} { return nonZero(s.SSMPath) }},
+	{key: raw.MaskInArgKey, value: envMaskIn},
+	{key: raw.ModeArgKey, value: func(s valid.Step) interface{} { return nonZero(string(s.MultiEnvMode)) }},
+	{key: raw.SeparatorArgKey, value: func(s valid.Step) interface{} { return nonZero(s.MultiEnvSeparator) }},
+	{key: raw.ExtraArgsKey, value: func(s valid.Step) interface{} { return nonEmpty(s.ExtraArgs) }, secret: true},
+	{key: raw.ExtraArgsFileKey, value: func(s valid.Step) interface{} { return nonZero(s.ExtraArgsFile) }},
+	{key: raw.WorkspaceFromFileKey, value: func(s valid.Step) interface{} { return nonZero(s.WorkspaceFromFile) }},
+	{key: raw.CommentArgsPositionKey, value: func(s valid.Step) interface{} { return nonZero(string(s.CommentArgsPosition)) }},
+	{key: raw.ParallelismKey, value: func(s valid.Step) interface{} { return nonZero(s.Parallelism) }},
+	{key: raw.OutputArgKey, value: func(s valid.Step) interface{} {
+		if s.Output == valid.PostProcessRunOutputShow {
+			return nil
+		}
+		return nonZero(string(s.Output))
+	}},
+	{key: raw.StreamArgKey, value: func(s valid.Step) interface{} { return nonZero(s.Stream) }},
+	{key: raw.AlwaysArgKey, value: func(s valid.Step) interface{} { return nonZero(s.Always) }},
+	{key: raw.OnSuccessArgKey, value: func(s valid.Step) interface{} { return nonZero(s.OnSuccess) }},
+	{key: raw.CommentModeArgKey, value: func(s valid.Step) interface{} { return nonZero(string(s.CommentMode)) }},
+	{key: raw.ThreadArgKey, value: func(s valid.Step) interface{} { return nonZero(string(s.Thread)) }},
+	{key: raw.LinePrefixArgKey, value: func(s valid.Step) interface{} { return nonZero(s.LinePrefix) }},
+	{key: raw.OutputFilterArgKey, value: func(s valid.Step) interface{} { return nonZero(s.OutputFilter) }},
+	{key: raw.RequireToolArgKey, value: func(s valid.Step) interface{} {
+		var tools []string
+		for _, tool := range s.RequireTools {
+			if tool.Constraints != nil {
+				tools = append(tools, tool.Name+tool.Constraints.String())
+			} else {
+				tools = append(tools, tool.Name)
+			}
+		}
+		return nonEmpty(tools)
+	}},
+	{key: raw.ForEachArgKey, value: func(s valid.Step) interface{} { return nonZero(s.ForEach) }},
+	{key: raw.ParallelArgKey, value: func(s valid.Step) interface{} { return nonZero(s.Parallel) }},
+	{key: raw.GoldenArgKey, value: func(s valid.Step) interface{} { return nonZero(s.Golden) }},
+	{key: raw.AssertFormatArgKey, value: func(s valid.Step) interface{} { return nonZero(string(s.AssertFormat)) }},
+	{key: raw.MetricArgKey, value: func(s valid.Step) interface{} { return nonZero(s.Metric) }},
+	{key: raw.InputArgKey, value: func(s valid.Step) interface{} { return nonZero(s.Stdin) }, secret: true},
+	{key: raw.NoNetworkArgKey, value: func(s valid.Step) interface{} { return nonZero(s.NoNetwork) }},
+	{key: raw.RestoreDirArgKey, value: func(s valid.Step) interface{} { return nonZero(s.RestoreDir) }},
+	{key: raw.RenderArgKey, value: func(s valid.Step) interface{} { return nonZero(string(s.Render)) }},
+	{key: raw.MetricLabelArgKey, value: func(s valid.Step) interface{} { return nonZero(s.MetricLabel) }},
+	{key: raw.RateLimitArgKey, value: func(s valid.Step) interface{} {
+		if s.RateLimit == nil {
+			return nil
+		}
+		return s.RateLimit.String()
+	}},
+	{key: raw.IfArgKey, value: func(s valid.Step) interface{} {
+		if s.If == nil {
+			return nil
+		}
+		return s.If.Expr
+	}},
+	{key: raw.RequireCleanAfterArgKey, value: func(s valid.Step) interface{} { return nonZero(s.RequireCleanAfter) }},
+	{key: raw.CacheArgKey, value: func(s valid.Step) interface{} {
+		if s.Cache == nil {
+			return nil
+		}
+		return map[string]interface{}{raw.CacheKeyArgKey: s.Cache.Key, raw.CachePathsArgKey: s.Cache.Paths}
+	}},
+	{key: raw.MemoryLimitArgKey, value: func(s valid.Step) interface{} { return nonZero(s.MemoryLimit) }},
+	{key: raw.CPULimitArgKey, value: func(s valid.Step) interface{} { return nonZero(s.CPULimit) }},
+	{key: raw.VerifyArgKey, value: func(s valid.Step) interface{} {
+		if s.Verify == nil {
+			return nil
+		}
+		verify := map[string]string{raw.VerifyFileArgKey: s.Verify.File}
+		if s.Verify.Checksum != "" {
+			verify[s.Verify.Algorithm] = s.Verify.Checksum
+		} else {
+			verify[s.Verify.Algorithm+"_file"] = s.Verify.ChecksumFile
+		}
+		return verify
+	}},
+	{key: raw.RequiresFilesArgKey, value: func(s valid.Step) interface{} { return nonEmpty(s.RequiresFiles) }},
+	{key: raw.RequiresProjectsSuccessArgKey, value: func(s valid.Step) interface{} { return nonEmpty(s.RequiresProjectsSuccess) }},
+	{key: raw.RequiresPlanArgKey, value: func(s valid.Step) interface{} { return nonZero(s.RequiresPlan) }},
+	{key: raw.NixShellArgKey, value: func(s valid.Step) interface{} { return nonZero(s.NixShell) }},
+	{key: raw.JUnitArgKey, value: func(s valid.Step) interface{} { return nonZero(s.JUnit) }},
+	{key: raw.ExitCodeVarArgKey, value: func(s valid.Step) interface{} { return nonZero(s.ExitCodeVar) }},
+	{key: raw.AllowedExitCodesArgKey, value: func(s valid.Step) interface{} { return nonEmpty(s.AllowedExitCodes) }},
+	{key: raw.OnDriftArgKey, value: func(s valid.Step) interface{} { return nonZero(s.OnDrift) }},
+	{key: raw.DebugEnvDiffArgKey, value: func(s valid.Step) interface{} { return nonZero(s.DebugEnvDiff) }},
+	{key: raw.RequireApprovalArgKey, value: func(s valid.Step) interface{} { return nonZero(s.RequireApproval) }},
+	{key: raw.ApprovalTimeoutArgKey, value: func(s valid.Step) interface{} {
+		if s.ApprovalTimeout <= 0 {
+			return nil
+		}
+		return s.ApprovalTimeout.String()
+	}},
+	{key: raw.DiffAgainstBaseArgKey, value: func(s valid.Step) interface{} { return nonZero(s.DiffAgainstBase) }},
+	{key: raw.FatalArgKey, value: func(s valid.Step) interface{} { return nonZero(s.Fatal) }},
+}
+
+// stepView returns step the way it's written in a workflow: the step's name
+// for built-in steps without options, the command of run steps without
+// options, otherwise a map of the step's name to its options. The options
+// that can hold secrets are masked.
+func stepView(step valid.Step) interface{} {
+	opts := map[string]interface{}{}
+	for _, opt := range stepOptions {
+		value := opt.value(step)
+		if value == nil {
+			continue
+		}
+		if opt.secret {
+			value = maskConfigValue(value)
+		}
+		opts[opt.key] = value
+	}
+	switch {
+	case step.StepName == raw.RunStepName && len(opts) == 1:
+		return map[string]string{step.StepName: step.RunCommand}
+	case len(opts) == 0:
+		return step.StepName
+	}
+	return map[string]interface{}{step.StepName: opts}
+}
+
+// envMaskIn returns where an env step's value is masked if it isn't masked
+// everywhere, the default.
+func envMaskIn(step valid.Step) interface{} {
+	if step.StepName != raw.EnvStepName || step.SSMPath != "" || (step.MaskInComment && step.MaskInLog) {
+		return nil
+	}
+	maskIn := []string{}
+	if step.MaskInComment {
+		maskIn = append(maskIn, valid.MaskInComment)
+	}
+	if step.MaskInLog {
+		maskIn = append(maskIn, valid.MaskInLog)
+	}
+	return maskIn
+}
+
+// maskConfigValue masks value, masking each element of lists so the number
+// of values set is still shown.
+func maskConfigValue(value interface{}) interface{} {
+	if list, ok := value.([]string); ok {
+		masked := make([]string, len(list))
+		for i := range list {
+			masked[i] = maskedConfigValue
+		}
+		return masked
+	}
+	return maskedConfigValue
+}
+
+// nonZero returns value, or nil if it's the zero value.
+func nonZero[T comparable](value T) interface{} {
+	var zero T
+	if value == zero {
+		return nil
+	}
+	return value
+}
+
+// nonEmpty returns list, or nil if it's empty.
+func nonEmpty[T any](list []T) interface{} {
+	if len(list) == 0 {
+		return nil
+	}
+	return list
+}
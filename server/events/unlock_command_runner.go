@@ -1,9 +1,13 @@
 package events
 
 import (
+	"fmt"
 	"slices"
+	"strings"
 
+	"github.com/runatlantis/atlantis/server/core/config/valid"
 	"github.com/runatlantis/atlantis/server/events/command"
+	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 )
 
@@ -12,12 +16,14 @@ func NewUnlockCommandRunner(
 	vcsClient vcs.Client,
 	SilenceNoProjects bool,
 	DisableUnlockLabel string,
+	policyOwners valid.PolicyOwners,
 ) *UnlockCommandRunner {
 	return &UnlockCommandRunner{
 		deleteLockCommand:  deleteLockCommand,
 		vcsClient:          vcsClient,
 		SilenceNoProjects:  SilenceNoProjects,
 		DisableUnlockLabel: DisableUnlockLabel,
+		policyOwners:       policyOwners,
 	}
 }
 
@@ -28,19 +34,30 @@ type UnlockCommandRunner struct {
 	// are found
 	SilenceNoProjects  bool
 	DisableUnlockLabel string
+	// policyOwners are the owners of the server's policies. Along with the
+	// pull request's author they can run unlock --all.
+	policyOwners valid.PolicyOwners
 }
 
-func (u *UnlockCommandRunner) Run(ctx *command.Context, _ *CommentCommand) {
+func (u *UnlockCommandRunner) Run(ctx *command.Context, cmd *CommentCommand) {
 	baseRepo := ctx.Pull.BaseRepo
 	pullNum := ctx.Pull.Num
 	disableUnlockLabel := u.DisableUnlockLabel
+	all := cmd != nil && cmd.UnlockAll
 
 	ctx.Log.Info("Unlocking all locks")
 	vcsMessage := "All Atlantis locks for this PR have been unlocked and plans discarded"
 
-	var hasLabel bool
 	var err error
-	if disableUnlockLabel != "" {
+	if all {
+		if err = checkAuthorOrPolicyOwner(ctx, u.vcsClient, u.policyOwners, "unlock --all"); err != nil {
+			vcsMessage = fmt.Sprintf("Not unlocking: %s", err)
+			ctx.Log.Warn("denied unlock --all of pull request %s#%d for user %q: %s", baseRepo.FullName, pullNum, ctx.User.Username, err)
+		}
+	}
+
+	var hasLabel bool
+	if err == nil && disableUnlockLabel != "" {
 		var labels []string
 		labels, err = u.vcsClient.GetPullLabels(ctx.Log, baseRepo, ctx.Pull)
 		if err != nil {
@@ -54,17 +71,27 @@ func (u *UnlockCommandRunner) Run(ctx *command.Context, _ *CommentCommand) {
 		}
 	}
 
-	var numLocks int
+	var locks []models.ProjectLock
 	if err == nil && !hasLabel {
-		numLocks, err = u.deleteLockCommand.DeleteLocksByPull(ctx.Log, baseRepo.FullName, pullNum)
+		locks, err = u.deleteLockCommand.DeleteLocksByPull(ctx.Log, baseRepo.FullName, pullNum)
 		if err != nil {
 			vcsMessage = "Failed to delete PR locks"
 			ctx.Log.Err("failed to delete locks by pull %s", err.Error())
 		}
+		if all {
+			// Log each lock so forced unlocks can be audited, including the
+			// ones deleted before an error.
+			for _, lock := range locks {
+				ctx.Log.Info("user %q unlocked %s with unlock --all", ctx.User.Username, lockDescription(lock))
+			}
+			if err == nil {
+				vcsMessage = unlockAllComment(locks)
+			}
+		}
 	}
 
 	// if there are no locks to delete, no errors, and SilenceNoProjects is enabled, don't comment
-	if err == nil && numLocks == 0 {
+	if err == nil && len(locks) == 0 {
 		ctx.Log.Info("No locks to delete")
 		if u.SilenceNoProjects {
 			return
@@ -75,3 +102,24 @@ func (u *UnlockCommandRunner) Run(ctx *command.Context, _ *CommentCommand) {
 		ctx.Log.Err("unable to comment: %s", commentErr)
 	}
 }
+
+// unlockAllComment is the comment listing the locks unlock --all unlocked.
+func unlockAllComment(locks []models.ProjectLock) string {
+	if len(locks) == 0 {
+		return "No Atlantis locks found for this PR"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Unlocked %d lock(s) and discarded their plans:\n", len(locks))
+	for _, lock := range locks {
+		fmt.Fprintf(&sb, "\n- %s", lockDescription(lock))
+	}
+	return sb.String()
+}
+
+// lockDescription describes the project and workspace of lock.
+func lockDescription(lock models.ProjectLock) string {
+	if lock.Project.ProjectName != "" {
+		return fmt.Sprintf("project: `%s` dir: `%s` workspace: `%s`", lock.Project.ProjectName, lock.Project.Path, lock.Workspace)
+	}
+	return fmt.Sprintf("dir: `%s` workspace: `%s`", lock.Project.Path, lock.Workspace)
+}
@@ -23,11 +23,14 @@ type AzureDevopsClient struct {
 	UserName string
 }
 
-// NewAzureDevopsClient returns a valid Azure DevOps client.
-func NewAzureDevopsClient(hostname string, userName string, token string) (*AzureDevopsClient, error) {
+// NewAzureDevopsClient returns a valid Azure DevOps client. Requests are sent
+// with transport, ex. from NewRetryTransport, or http.DefaultTransport if it's
+// nil.
+func NewAzureDevopsClient(hostname string, userName string, token string, transport http.RoundTripper) (*AzureDevopsClient, error) {
 	tp := azuredevops.BasicAuthTransport{
-		Username: "",
-		Password: strings.TrimSpace(token),
+		Username:  "",
+		Password:  strings.TrimSpace(token),
+		Transport: transport,
 	}
 	httpClient := tp.Client()
 	httpClient.Timeout = time.Second * 10
@@ -177,7 +180,7 @@ func (g *AzureDevopsClient) DiscardReviews(repo models.Repo, pull models.PullReq
 }
 
 // PullIsMergeable returns true if the merge request can be merged.
-func (g *AzureDevopsClient) PullIsMergeable(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, vcsstatusname string) (bool, error) { //nolint: revive
+func (g *AzureDevopsClient) PullIsMergeable(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, applyStatusContext string) (bool, error) { //nolint: revive
 	owner, project, repoName := SplitAzureDevopsRepoFullName(repo.FullName)
 
 	opts := azuredevops.PullRequestGetOptions{IncludeWorkItemRefs: true}
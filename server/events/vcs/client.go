@@ -14,6 +14,8 @@
 package vcs
 
 import (
+	"errors"
+
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/logging"
 )
@@ -30,7 +32,10 @@ type Client interface {
 	ReactToComment(logger logging.SimpleLogging, repo models.Repo, pullNum int, commentID int64, reaction string) error
 	HidePrevCommandComments(logger logging.SimpleLogging, repo models.Repo, pullNum int, command string, dir string) error
 	PullIsApproved(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest) (models.ApprovalStatus, error)
-	PullIsMergeable(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, vcsstatusname string) (bool, error)
+	// PullIsMergeable returns true if pull can be merged. Statuses whose
+	// context starts with applyStatusContext, ex. atlantis/apply, are
+	// Atlantis's own apply statuses and are ignored.
+	PullIsMergeable(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, applyStatusContext string) (bool, error)
 	// UpdateStatus updates the commit status to state for pull. src is the
 	// source of this status. This should be relatively static across runs,
 	// ex. atlantis/plan or atlantis/apply.
@@ -54,3 +59,38 @@ type Client interface {
 	// GetPullLabels returns the labels of a pull request
 	GetPullLabels(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest) ([]string, error)
 }
+
+// CommentUpdater is implemented by clients for VCS hosts that support editing
+// comments after they've been created.
+type CommentUpdater interface {
+	// CreateUpdatableComment creates a single comment on the pull request and
+	// returns its ID so it can later be passed to UpdateComment. Unlike
+	// CreateComment, the comment isn't split if it's too long.
+	CreateUpdatableComment(logger logging.SimpleLogging, repo models.Repo, pullNum int, comment string) (int64, error)
+	// UpdateComment replaces the body of the comment with commentID.
+	UpdateComment(logger logging.SimpleLogging, repo models.Repo, pullNum int, commentID int64, comment string) error
+}
+
+// ReviewCommenter is implemented by clients for VCS hosts that support review
+// comments on specific lines of a pull request.
+type ReviewCommenter interface {
+	// CreateReview creates a review of the pull request's head commit with
+	// body and comments on the lines they refer to.
+	CreateReview(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, body string, comments []ReviewComment) error
+}
+
+// ReviewComment is a comment on a line of a file changed by a pull request.
+type ReviewComment struct {
+	// Path is the path of the file relative to the repo root.
+	Path string
+	Line int
+	Body string
+}
+
+// ErrReviewsNotSupported is returned when review comments aren't supported
+// for the VCS host.
+var ErrReviewsNotSupported = errors.New("review comments are not supported for this VCS host")
+
+// ErrCommentUpdatesNotSupported is returned when editing comments isn't
+// supported for the VCS host.
+var ErrCommentUpdatesNotSupported = errors.New("editing comments is not supported for this VCS host")
@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -59,14 +60,19 @@ type GiteaPullGetter interface {
 // NewClient builds a client that makes API calls to Gitea. httpClient is the
 // client to use to make the requests, username and password are used as basic
 // auth in the requests, baseURL is the API's baseURL, ex. https://corp.com:7990.
-// Don't include the API version, ex. '/1.0'.
-func NewClient(baseURL string, username string, token string, pagesize int, logger logging.SimpleLogging) (*GiteaClient, error) {
+// Don't include the API version, ex. '/1.0'. If httpClient is nil the Gitea
+// library's default client is used.
+func NewClient(httpClient *http.Client, baseURL string, username string, token string, pagesize int, logger logging.SimpleLogging) (*GiteaClient, error) {
 	logger.Debug("Creating new Gitea client for: %s", baseURL)
 
-	giteaClient, err := gitea.NewClient(baseURL,
+	opts := []gitea.ClientOption{
 		gitea.SetToken(token),
 		gitea.SetUserAgent("atlantis"),
-	)
+	}
+	if httpClient != nil {
+		opts = append(opts, gitea.SetHTTPClient(httpClient))
+	}
+	giteaClient, err := gitea.NewClient(baseURL, opts...)
 
 	if err != nil {
 		return nil, errors.Wrap(err, "creating gitea client")
@@ -283,7 +289,7 @@ func (c *GiteaClient) PullIsApproved(logger logging.SimpleLogging, repo models.R
 }
 
 // PullIsMergeable returns true if the pull request is mergeable
-func (c *GiteaClient) PullIsMergeable(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, vcsstatusname string) (bool, error) {
+func (c *GiteaClient) PullIsMergeable(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, applyStatusContext string) (bool, error) {
 	logger.Debug("Checking if Gitea pull request %d is mergeable", pull.Num)
 
 	pullRequest, _, err := c.giteaClient.GetPullRequest(repo.Owner, repo.Name, int64(pull.Num))
@@ -23,7 +23,6 @@ import (
 
 	"github.com/google/go-github/v59/github"
 	"github.com/pkg/errors"
-	"github.com/runatlantis/atlantis/server/events/command"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs/common"
 	"github.com/runatlantis/atlantis/server/logging"
@@ -82,6 +81,7 @@ func NewGithubClient(hostname string, credentials GithubCredentials, config Gith
 	if err != nil {
 		return nil, errors.Wrap(err, "error initializing github authentication transport")
 	}
+	transport.Transport = NewRetryTransport(transport.Transport, config.Retry, logger)
 
 	var graphqlURL string
 	var client *github.Client
@@ -204,6 +204,55 @@ func (g *GithubClient) CreateComment(logger logging.SimpleLogging, repo models.R
 	return nil
 }
 
+// CreateUpdatableComment creates a single comment on the pull request and
+// returns its ID.
+func (g *GithubClient) CreateUpdatableComment(logger logging.SimpleLogging, repo models.Repo, pullNum int, comment string) (int64, error) {
+	logger.Debug("Creating updatable comment on GitHub pull request %d", pullNum)
+	c, resp, err := g.client.Issues.CreateComment(g.ctx, repo.Owner, repo.Name, pullNum, &github.IssueComment{Body: &comment})
+	if resp != nil {
+		logger.Debug("POST /repos/%v/%v/issues/%d/comments returned: %v", repo.Owner, repo.Name, pullNum, resp.StatusCode)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return c.GetID(), nil
+}
+
+// UpdateComment replaces the body of the comment with commentID.
+func (g *GithubClient) UpdateComment(logger logging.SimpleLogging, repo models.Repo, _ int, commentID int64, comment string) error {
+	logger.Debug("Updating GitHub pull request comment %d", commentID)
+	_, resp, err := g.client.Issues.EditComment(g.ctx, repo.Owner, repo.Name, commentID, &github.IssueComment{Body: &comment})
+	if resp != nil {
+		logger.Debug("PATCH /repos/%v/%v/issues/comments/%d returned: %v", repo.Owner, repo.Name, commentID, resp.StatusCode)
+	}
+	return err
+}
+
+// CreateReview creates a review of the pull request's head commit with a
+// comment on the new version of each line in comments. GitHub rejects the
+// whole review if a line isn't part of the pull request's diff.
+func (g *GithubClient) CreateReview(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, body string, comments []ReviewComment) error {
+	logger.Debug("Creating review with %d comments on GitHub pull request %d", len(comments), pull.Num)
+	review := &github.PullRequestReviewRequest{
+		CommitID: github.String(pull.HeadCommit),
+		Body:     github.String(body),
+		Event:    github.String("COMMENT"),
+	}
+	for _, c := range comments {
+		review.Comments = append(review.Comments, &github.DraftReviewComment{
+			Path: github.String(c.Path),
+			Line: github.Int(c.Line),
+			Side: github.String("RIGHT"),
+			Body: github.String(c.Body),
+		})
+	}
+	_, resp, err := g.client.PullRequests.CreateReview(g.ctx, repo.Owner, repo.Name, pull.Num, review)
+	if resp != nil {
+		logger.Debug("POST /repos/%v/%v/pulls/%d/reviews returned: %v", repo.Owner, repo.Name, pull.Num, resp.StatusCode)
+	}
+	return err
+}
+
 // ReactToComment adds a reaction to a comment.
 func (g *GithubClient) ReactToComment(logger logging.SimpleLogging, repo models.Repo, _ int, commentID int64, reaction string) error {
 	logger.Debug("Adding reaction to GitHub pull request comment %d", commentID)
@@ -416,8 +465,9 @@ func isRequiredCheck(check string, required []string) bool {
 	return false
 }
 
-// GetCombinedStatusMinusApply checks Statuses for PR, excluding atlantis apply. Returns true if all other statuses are not in failure.
-func (g *GithubClient) GetCombinedStatusMinusApply(logger logging.SimpleLogging, repo models.Repo, pull *github.PullRequest, vcstatusname string) (bool, error) {
+// GetCombinedStatusMinusApply checks Statuses for PR, excluding atlantis apply, i.e. the statuses starting with
+// applyStatusContext. Returns true if all other statuses are not in failure.
+func (g *GithubClient) GetCombinedStatusMinusApply(logger logging.SimpleLogging, repo models.Repo, pull *github.PullRequest, applyStatusContext string) (bool, error) {
 	logger.Debug("Checking if GitHub pull request %d has successful status checks", pull.GetNumber())
 	//check combined status api
 	status, resp, err := g.client.Repositories.GetCombinedStatus(g.ctx, *pull.Head.Repo.Owner.Login, repo.Name, *pull.Head.Ref, nil)
@@ -430,7 +480,7 @@ func (g *GithubClient) GetCombinedStatusMinusApply(logger logging.SimpleLogging,
 
 	//iterate over statuses - return false if we find one that isn't "apply" and doesn't have state = "success"
 	for _, r := range status.Statuses {
-		if strings.HasPrefix(*r.Context, fmt.Sprintf("%s/%s", vcstatusname, command.Apply.String())) {
+		if strings.HasPrefix(*r.Context, applyStatusContext) {
 			continue
 		}
 		if *r.State != "success" {
@@ -518,7 +568,7 @@ func (g *GithubClient) GetPullReviewDecision(repo models.Repo, pull models.PullR
 }
 
 // PullIsMergeable returns true if the pull request is mergeable.
-func (g *GithubClient) PullIsMergeable(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, vcsstatusname string) (bool, error) {
+func (g *GithubClient) PullIsMergeable(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, applyStatusContext string) (bool, error) {
 	logger.Debug("Checking if GitHub pull request %d is mergeable", pull.Num)
 	githubPR, err := g.GetPullRequest(logger, repo, pull.Num)
 	if err != nil {
@@ -541,7 +591,7 @@ func (g *GithubClient) PullIsMergeable(logger logging.SimpleLogging, repo models
 			logger.Debug("AllowMergeableBypassApply feature flag is enabled - attempting to bypass apply from mergeable requirements")
 			if state == "blocked" {
 				//check status excluding atlantis apply
-				status, err := g.GetCombinedStatusMinusApply(logger, repo, githubPR, vcsstatusname)
+				status, err := g.GetCombinedStatusMinusApply(logger, repo, githubPR, applyStatusContext)
 				if err != nil {
 					return false, errors.Wrap(err, "getting pull request status")
 				}
@@ -632,7 +632,7 @@ func TestGithubClient_PullIsMergeable(t *testing.T) {
 					},
 				}, models.PullRequest{
 					Num: 1,
-				}, vcsStatusName)
+				}, vcsStatusName+"/apply")
 			Ok(t, err)
 			Equals(t, c.expMergeable, actMergeable)
 		})
@@ -786,7 +786,7 @@ func TestGithubClient_PullIsMergeableWithAllowMergeableBypassApply(t *testing.T)
 					},
 				}, models.PullRequest{
 					Num: 1,
-				}, vcsStatusName)
+				}, vcsStatusName+"/apply")
 			Ok(t, err)
 			Equals(t, c.expMergeable, actMergeable)
 		})
@@ -897,7 +897,7 @@ func TestGithubClient_PullIsMergeableWithAllowMergeableBypassApplyButWithNoBranc
 					},
 				}, models.PullRequest{
 					Num: 1,
-				}, vcsStatusName)
+				}, vcsStatusName+"/apply")
 			Ok(t, err)
 			Equals(t, c.expMergeable, actMergeable)
 		})
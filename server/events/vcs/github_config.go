@@ -3,4 +3,7 @@ package vcs
 // GithubConfig allows for custom github-specific functionality and behavior
 type GithubConfig struct {
 	AllowMergeableBypassApply bool
+	// Retry configures retrying requests to GitHub that failed with a
+	// transient error.
+	Retry RetryConfig
 }
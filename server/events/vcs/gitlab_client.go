@@ -25,7 +25,6 @@ import (
 	version "github.com/hashicorp/go-version"
 	"github.com/jpillora/backoff"
 	"github.com/pkg/errors"
-	"github.com/runatlantis/atlantis/server/events/command"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs/common"
 	"github.com/runatlantis/atlantis/server/logging"
@@ -55,17 +54,23 @@ var commonMarkSupported = MustConstraint(">=11.1")
 // gitlabClientUnderTest is true if we're running under go test.
 var gitlabClientUnderTest = false
 
-// NewGitlabClient returns a valid GitLab client.
-func NewGitlabClient(hostname string, token string, logger logging.SimpleLogging) (*GitlabClient, error) {
+// NewGitlabClient returns a valid GitLab client. If transport is set, ex. by
+// NewRetryTransport, requests are sent with it instead of being retried by the
+// GitLab library.
+func NewGitlabClient(hostname string, token string, transport http.RoundTripper, logger logging.SimpleLogging) (*GitlabClient, error) {
 	logger.Debug("Creating new GitLab client for %s", hostname)
 	client := &GitlabClient{
 		PollingInterval: time.Second,
 		PollingTimeout:  time.Second * 30,
 	}
+	var opts []gitlab.ClientOptionFunc
+	if transport != nil {
+		opts = append(opts, gitlab.WithHTTPClient(&http.Client{Transport: transport}), gitlab.WithoutRetries())
+	}
 
 	// Create the client differently depending on the base URL.
 	if hostname == "gitlab.com" {
-		glClient, err := gitlab.NewClient(token)
+		glClient, err := gitlab.NewClient(token, opts...)
 		if err != nil {
 			return nil, err
 		}
@@ -94,7 +99,7 @@ func NewGitlabClient(hostname string, token string, logger logging.SimpleLogging
 		// Now we're ready to construct the client.
 		absoluteURL = strings.TrimSuffix(absoluteURL, "/")
 		apiURL := fmt.Sprintf("%s/api/v4/", absoluteURL)
-		glClient, err := gitlab.NewClient(token, gitlab.WithBaseURL(apiURL))
+		glClient, err := gitlab.NewClient(token, append(opts, gitlab.WithBaseURL(apiURL))...)
 		if err != nil {
 			return nil, err
 		}
@@ -190,6 +195,30 @@ func (g *GitlabClient) CreateComment(logger logging.SimpleLogging, repo models.R
 	return nil
 }
 
+// CreateUpdatableComment creates a single note on the merge request and returns
+// its ID.
+func (g *GitlabClient) CreateUpdatableComment(logger logging.SimpleLogging, repo models.Repo, pullNum int, comment string) (int64, error) {
+	logger.Debug("Creating updatable comment on GitLab merge request %d", pullNum)
+	note, resp, err := g.Client.Notes.CreateMergeRequestNote(repo.FullName, pullNum, &gitlab.CreateMergeRequestNoteOptions{Body: gitlab.Ptr(comment)})
+	if resp != nil {
+		logger.Debug("POST /projects/%s/merge_requests/%d/notes returned: %d", repo.FullName, pullNum, resp.StatusCode)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int64(note.ID), nil
+}
+
+// UpdateComment replaces the body of the note with commentID.
+func (g *GitlabClient) UpdateComment(logger logging.SimpleLogging, repo models.Repo, pullNum int, commentID int64, comment string) error {
+	logger.Debug("Updating comment %d on GitLab merge request %d", commentID, pullNum)
+	_, resp, err := g.Client.Notes.UpdateMergeRequestNote(repo.FullName, pullNum, int(commentID), &gitlab.UpdateMergeRequestNoteOptions{Body: gitlab.Ptr(comment)})
+	if resp != nil {
+		logger.Debug("PUT /projects/%s/merge_requests/%d/notes/%d returned: %d", repo.FullName, pullNum, commentID, resp.StatusCode)
+	}
+	return err
+}
+
 // ReactToComment adds a reaction to a comment.
 func (g *GitlabClient) ReactToComment(logger logging.SimpleLogging, repo models.Repo, pullNum int, commentID int64, reaction string) error {
 	logger.Debug("Adding reaction '%s' to comment %d on GitLab merge request %d", reaction, commentID, pullNum)
@@ -271,7 +300,8 @@ func (g *GitlabClient) HidePrevCommandComments(logger logging.SimpleLogging, rep
 	return nil
 }
 
-// PullIsApproved returns true if the merge request was approved.
+// PullIsApproved returns true if the merge request was approved by at least
+// one user, has no approvals left and all of its approval rules are approved.
 func (g *GitlabClient) PullIsApproved(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest) (approvalStatus models.ApprovalStatus, err error) {
 	logger.Debug("Checking if GitLab merge request %d is approved", pull.Num)
 	approvals, resp, err := g.Client.MergeRequests.GetMergeRequestApprovals(repo.FullName, pull.Num)
@@ -282,11 +312,48 @@ func (g *GitlabClient) PullIsApproved(logger logging.SimpleLogging, repo models.
 		return approvalStatus, err
 	}
 	if approvals.ApprovalsLeft > 0 {
+		logger.Debug("GitLab merge request %d needs %d more approvals", pull.Num, approvals.ApprovalsLeft)
+		return approvalStatus, nil
+	}
+	// Merge requests without any approval rules have no approvals left even
+	// if no one approved them, so they need at least one approval.
+	if len(approvals.ApprovedBy) == 0 {
+		logger.Debug("GitLab merge request %d has no approvals", pull.Num)
 		return approvalStatus, nil
 	}
-	return models.ApprovalStatus{
-		IsApproved: true,
-	}, nil
+	// On GitLab Premium the approval rules, ex. of code owners, can require
+	// approvals from specific users or groups so they must all be approved,
+	// not only have enough approvals in total.
+	if approvals.HasApprovalRules {
+		approved, err := g.approvalRulesApproved(logger, repo, pull)
+		if err != nil || !approved {
+			return approvalStatus, err
+		}
+	}
+	approvalStatus.IsApproved = true
+	if approvedBy := approvals.ApprovedBy[0].User; approvedBy != nil {
+		approvalStatus.ApprovedBy = approvedBy.Username
+	}
+	return approvalStatus, nil
+}
+
+// approvalRulesApproved returns true if every approval rule of the merge
+// request that requires approvals is approved.
+func (g *GitlabClient) approvalRulesApproved(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest) (bool, error) {
+	state, resp, err := g.Client.MergeRequestApprovals.GetApprovalState(repo.FullName, pull.Num)
+	if resp != nil {
+		logger.Debug("GET /projects/%s/merge_requests/%d/approval_state returned: %d", repo.FullName, pull.Num, resp.StatusCode)
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, rule := range state.Rules {
+		if rule.ApprovalsRequired > 0 && !rule.Approved {
+			logger.Debug("GitLab merge request %d approval rule %q has %d of %d required approvals", pull.Num, rule.Name, len(rule.ApprovedBy), rule.ApprovalsRequired)
+			return false, nil
+		}
+	}
+	return true, nil
 }
 
 // PullIsMergeable returns true if the merge request can be merged.
@@ -300,7 +367,7 @@ func (g *GitlabClient) PullIsApproved(logger logging.SimpleLogging, repo models.
 // See:
 // - https://gitlab.com/gitlab-org/gitlab-ee/issues/3169
 // - https://gitlab.com/gitlab-org/gitlab-ce/issues/42344
-func (g *GitlabClient) PullIsMergeable(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, vcsstatusname string) (bool, error) {
+func (g *GitlabClient) PullIsMergeable(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, applyStatusContext string) (bool, error) {
 	logger.Debug("Checking if GitLab merge request %d is mergeable", pull.Num)
 	mr, resp, err := g.Client.MergeRequests.GetMergeRequest(repo.FullName, pull.Num, nil)
 	if resp != nil {
@@ -338,8 +405,8 @@ func (g *GitlabClient) PullIsMergeable(logger logging.SimpleLogging, repo models
 	}
 
 	for _, status := range statuses {
-		// Ignore any commit statuses with 'atlantis/apply' as prefix
-		if strings.HasPrefix(status.Name, fmt.Sprintf("%s/%s", vcsstatusname, command.Apply.String())) {
+		// Ignore Atlantis's own apply statuses, ex. atlantis/apply: project
+		if strings.HasPrefix(status.Name, applyStatusContext) {
 			continue
 		}
 		if !status.AllowFailure && project.OnlyAllowMergeIfPipelineSucceeds && status.Status != "success" {
@@ -64,7 +64,7 @@ func TestNewGitlabClient_BaseURL(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.Hostname, func(t *testing.T) {
 			log := logging.NewNoopLogger(t)
-			client, err := NewGitlabClient(c.Hostname, "token", log)
+			client, err := NewGitlabClient(c.Hostname, "token", nil, log)
 			Ok(t, err)
 			Equals(t, c.ExpBaseURL, client.Client.BaseURL().String())
 		})
@@ -657,7 +657,7 @@ func TestGitlabClient_PullIsMergeable(t *testing.T) {
 						Num:        c.mrID,
 						BaseRepo:   repo,
 						HeadCommit: "67cb91d3f6198189f433c045154a885784ba6977",
-					}, vcsStatusName)
+					}, vcsStatusName+"/apply")
 
 				Ok(t, err)
 				Equals(t, c.expState, mergeable)
@@ -666,11 +666,90 @@ func TestGitlabClient_PullIsMergeable(t *testing.T) {
 	}
 }
 
+func TestGitlabClient_PullIsApproved(t *testing.T) {
+	cases := []struct {
+		description   string
+		approvals     string
+		approvalState string
+		expApproved   bool
+		expApprovedBy string
+	}{
+		{
+			description: "approvals left",
+			approvals:   `{"approvals_left":1,"approved_by":[{"user":{"username":"alice"}}]}`,
+			expApproved: false,
+		},
+		{
+			description: "no approval rules and no approvals",
+			approvals:   `{"approvals_left":0,"approved_by":[]}`,
+			expApproved: false,
+		},
+		{
+			description:   "no approval rules and approved",
+			approvals:     `{"approvals_left":0,"approved_by":[{"user":{"username":"alice"}}]}`,
+			expApproved:   true,
+			expApprovedBy: "alice",
+		},
+		{
+			description:   "approval rule not approved",
+			approvals:     `{"approvals_left":0,"approved_by":[{"user":{"username":"alice"}}],"has_approval_rules":true}`,
+			approvalState: `{"rules":[{"name":"All members","approvals_required":1,"approved_by":[{"username":"alice"}],"approved":true},{"name":"Code owners","approvals_required":2,"approved_by":[{"username":"alice"}],"approved":false}]}`,
+			expApproved:   false,
+		},
+		{
+			description:   "approval rules approved",
+			approvals:     `{"approvals_left":0,"approved_by":[{"user":{"username":"alice"}},{"user":{"username":"bob"}}],"has_approval_rules":true}`,
+			approvalState: `{"rules":[{"name":"Optional","approvals_required":0,"approved":false},{"name":"Code owners","approvals_required":2,"approved_by":[{"username":"alice"},{"username":"bob"}],"approved":true}]}`,
+			expApproved:   true,
+			expApprovedBy: "alice",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			testServer := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					switch r.RequestURI {
+					case "/api/v4/projects/runatlantis%2Fatlantis/merge_requests/1/approvals":
+						w.Write([]byte(c.approvals)) // nolint: errcheck
+					case "/api/v4/projects/runatlantis%2Fatlantis/merge_requests/1/approval_state":
+						Assert(t, c.approvalState != "", "exp approval state not to be requested")
+						w.Write([]byte(c.approvalState)) // nolint: errcheck
+					default:
+						t.Errorf("got unexpected request at %q", r.RequestURI)
+						http.Error(w, "not found", http.StatusNotFound)
+					}
+				}))
+			defer testServer.Close()
+
+			internalClient, err := gitlab.NewClient("token", gitlab.WithBaseURL(testServer.URL))
+			Ok(t, err)
+			client := &GitlabClient{
+				Client:  internalClient,
+				Version: nil,
+			}
+			repo := models.Repo{
+				FullName: "runatlantis/atlantis",
+				Owner:    "runatlantis",
+				Name:     "atlantis",
+				VCSHost: models.VCSHost{
+					Type:     models.Gitlab,
+					Hostname: "gitlab.com",
+				},
+			}
+
+			status, err := client.PullIsApproved(logging.NewNoopLogger(t), repo, models.PullRequest{Num: 1, BaseRepo: repo})
+			Ok(t, err)
+			Equals(t, c.expApproved, status.IsApproved)
+			Equals(t, c.expApprovedBy, status.ApprovedBy)
+		})
+	}
+}
+
 func TestGitlabClient_MarkdownPullLink(t *testing.T) {
 	logger := logging.NewNoopLogger(t)
 	gitlabClientUnderTest = true
 	defer func() { gitlabClientUnderTest = false }()
-	client, err := NewGitlabClient("gitlab.com", "token", logger)
+	client, err := NewGitlabClient("gitlab.com", "token", nil, logger)
 	Ok(t, err)
 	pull := models.PullRequest{Num: 1}
 	s, _ := client.MarkdownPullLink(pull)
@@ -121,6 +121,85 @@ func (c *InstrumentedClient) CreateComment(logger logging.SimpleLogging, repo mo
 	return nil
 }
 
+// CreateUpdatableComment passes through to the underlying client if it
+// supports editing comments.
+func (c *InstrumentedClient) CreateUpdatableComment(logger logging.SimpleLogging, repo models.Repo, pullNum int, comment string) (int64, error) {
+	updater, ok := c.Client.(CommentUpdater)
+	if !ok {
+		return 0, ErrCommentUpdatesNotSupported
+	}
+	scope := c.StatsScope.SubScope("create_updatable_comment")
+	scope = SetGitScopeTags(scope, repo.FullName, pullNum)
+
+	executionTime := scope.Timer(metrics.ExecutionTimeMetric).Start()
+	defer executionTime.Stop()
+
+	executionSuccess := scope.Counter(metrics.ExecutionSuccessMetric)
+	executionError := scope.Counter(metrics.ExecutionErrorMetric)
+
+	commentID, err := updater.CreateUpdatableComment(logger, repo, pullNum, comment)
+	if err != nil {
+		executionError.Inc(1)
+		logger.Err("Unable to create updatable comment, error: %s", err.Error())
+		return 0, err
+	}
+
+	executionSuccess.Inc(1)
+	return commentID, nil
+}
+
+// UpdateComment passes through to the underlying client if it supports
+// editing comments.
+func (c *InstrumentedClient) UpdateComment(logger logging.SimpleLogging, repo models.Repo, pullNum int, commentID int64, comment string) error {
+	updater, ok := c.Client.(CommentUpdater)
+	if !ok {
+		return ErrCommentUpdatesNotSupported
+	}
+	scope := c.StatsScope.SubScope("update_comment")
+	scope = SetGitScopeTags(scope, repo.FullName, pullNum)
+
+	executionTime := scope.Timer(metrics.ExecutionTimeMetric).Start()
+	defer executionTime.Stop()
+
+	executionSuccess := scope.Counter(metrics.ExecutionSuccessMetric)
+	executionError := scope.Counter(metrics.ExecutionErrorMetric)
+
+	if err := updater.UpdateComment(logger, repo, pullNum, commentID, comment); err != nil {
+		executionError.Inc(1)
+		logger.Err("Unable to update comment %d, error: %s", commentID, err.Error())
+		return err
+	}
+
+	executionSuccess.Inc(1)
+	return nil
+}
+
+// CreateReview passes through to the underlying client if it supports review
+// comments.
+func (c *InstrumentedClient) CreateReview(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, body string, comments []ReviewComment) error {
+	commenter, ok := c.Client.(ReviewCommenter)
+	if !ok {
+		return ErrReviewsNotSupported
+	}
+	scope := c.StatsScope.SubScope("create_review")
+	scope = SetGitScopeTags(scope, repo.FullName, pull.Num)
+
+	executionTime := scope.Timer(metrics.ExecutionTimeMetric).Start()
+	defer executionTime.Stop()
+
+	executionSuccess := scope.Counter(metrics.ExecutionSuccessMetric)
+	executionError := scope.Counter(metrics.ExecutionErrorMetric)
+
+	if err := commenter.CreateReview(logger, repo, pull, body, comments); err != nil {
+		executionError.Inc(1)
+		logger.Err("Unable to create review, error: %s", err.Error())
+		return err
+	}
+
+	executionSuccess.Inc(1)
+	return nil
+}
+
 func (c *InstrumentedClient) ReactToComment(logger logging.SimpleLogging, repo models.Repo, pullNum int, commentID int64, reaction string) error {
 	scope := c.StatsScope.SubScope("react_to_comment")
 
@@ -183,7 +262,7 @@ func (c *InstrumentedClient) PullIsApproved(logger logging.SimpleLogging, repo m
 	return approved, err
 }
 
-func (c *InstrumentedClient) PullIsMergeable(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, vcsstatusname string) (bool, error) {
+func (c *InstrumentedClient) PullIsMergeable(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, applyStatusContext string) (bool, error) {
 	scope := c.StatsScope.SubScope("pull_is_mergeable")
 	scope = SetGitScopeTags(scope, repo.FullName, pull.Num)
 
@@ -193,7 +272,7 @@ func (c *InstrumentedClient) PullIsMergeable(logger logging.SimpleLogging, repo
 	executionSuccess := scope.Counter(metrics.ExecutionSuccessMetric)
 	executionError := scope.Counter(metrics.ExecutionErrorMetric)
 
-	mergeable, err := c.Client.PullIsMergeable(logger, repo, pull, vcsstatusname)
+	mergeable, err := c.Client.PullIsMergeable(logger, repo, pull, applyStatusContext)
 
 	if err != nil {
 		executionError.Inc(1)
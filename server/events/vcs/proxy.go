@@ -65,6 +65,27 @@ func (d *ClientProxy) CreateComment(logger logging.SimpleLogging, repo models.Re
 	return d.clients[repo.VCSHost.Type].CreateComment(logger, repo, pullNum, comment, command)
 }
 
+func (d *ClientProxy) CreateUpdatableComment(logger logging.SimpleLogging, repo models.Repo, pullNum int, comment string) (int64, error) {
+	if updater, ok := d.clients[repo.VCSHost.Type].(CommentUpdater); ok {
+		return updater.CreateUpdatableComment(logger, repo, pullNum, comment)
+	}
+	return 0, ErrCommentUpdatesNotSupported
+}
+
+func (d *ClientProxy) UpdateComment(logger logging.SimpleLogging, repo models.Repo, pullNum int, commentID int64, comment string) error {
+	if updater, ok := d.clients[repo.VCSHost.Type].(CommentUpdater); ok {
+		return updater.UpdateComment(logger, repo, pullNum, commentID, comment)
+	}
+	return ErrCommentUpdatesNotSupported
+}
+
+func (d *ClientProxy) CreateReview(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, body string, comments []ReviewComment) error {
+	if commenter, ok := d.clients[repo.VCSHost.Type].(ReviewCommenter); ok {
+		return commenter.CreateReview(logger, repo, pull, body, comments)
+	}
+	return ErrReviewsNotSupported
+}
+
 func (d *ClientProxy) HidePrevCommandComments(logger logging.SimpleLogging, repo models.Repo, pullNum int, command string, dir string) error {
 	return d.clients[repo.VCSHost.Type].HidePrevCommandComments(logger, repo, pullNum, command, dir)
 }
@@ -81,8 +102,8 @@ func (d *ClientProxy) DiscardReviews(repo models.Repo, pull models.PullRequest)
 	return d.clients[repo.VCSHost.Type].DiscardReviews(repo, pull)
 }
 
-func (d *ClientProxy) PullIsMergeable(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, vcsstatusname string) (bool, error) {
-	return d.clients[repo.VCSHost.Type].PullIsMergeable(logger, repo, pull, vcsstatusname)
+func (d *ClientProxy) PullIsMergeable(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, applyStatusContext string) (bool, error) {
+	return d.clients[repo.VCSHost.Type].PullIsMergeable(logger, repo, pull, applyStatusContext)
 }
 
 func (d *ClientProxy) UpdateStatus(logger logging.SimpleLogging, repo models.Repo, pull models.PullRequest, state models.CommitStatus, src string, description string, url string) error {
@@ -13,14 +13,16 @@ type PullReqStatusFetcher interface {
 }
 
 type pullReqStatusFetcher struct {
-	client        Client
-	vcsStatusName string
+	client Client
+	// applyStatusContext is the context of Atlantis's combined apply status,
+	// ex. atlantis/apply. Statuses starting with it don't block mergeable.
+	applyStatusContext string
 }
 
-func NewPullReqStatusFetcher(client Client, vcsStatusName string) PullReqStatusFetcher {
+func NewPullReqStatusFetcher(client Client, applyStatusContext string) PullReqStatusFetcher {
 	return &pullReqStatusFetcher{
-		client:        client,
-		vcsStatusName: vcsStatusName,
+		client:             client,
+		applyStatusContext: applyStatusContext,
 	}
 }
 
@@ -30,7 +32,7 @@ func (f *pullReqStatusFetcher) FetchPullStatus(logger logging.SimpleLogging, pul
 		return pullStatus, errors.Wrapf(err, "fetching pull approval status for repo: %s, and pull number: %d", pull.BaseRepo.FullName, pull.Num)
 	}
 
-	mergeable, err := f.client.PullIsMergeable(logger, pull.BaseRepo, pull, f.vcsStatusName)
+	mergeable, err := f.client.PullIsMergeable(logger, pull.BaseRepo, pull, f.applyStatusContext)
 	if err != nil {
 		return pullStatus, errors.Wrapf(err, "fetching mergeability status for repo: %s, and pull number: %d", pull.BaseRepo.FullName, pull.Num)
 	}
@@ -0,0 +1,160 @@
+package vcs
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jpillora/backoff"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// retryMaxWait is the longest that's waited before retrying a request. If the
+// VCS host asks to wait longer, ex. until its rate limit resets in an hour,
+// the request isn't retried.
+const retryMaxWait = time.Minute
+
+// RetryConfig configures how requests to a VCS host that failed with a
+// transient error are retried.
+type RetryConfig struct {
+	// MaxRetries is how many times a request is retried. 0 disables retries.
+	MaxRetries int
+	// Backoff is the wait before the first retry. It doubles with each retry.
+	Backoff time.Duration
+}
+
+// retryTransport retries requests that failed because the VCS host was rate
+// limiting or had a server error.
+type retryTransport struct {
+	base   http.RoundTripper
+	config RetryConfig
+	logger logging.SimpleLogging
+}
+
+// NewRetryTransport returns a transport sending requests with base and
+// retrying them as set by config when the response is a 429, or a 403 because
+// a rate limit was hit, or a 5xx to an idempotent request. Retry-After and
+// X-RateLimit-Reset headers are respected, otherwise the wait backs off
+// exponentially. If retries are disabled base is returned.
+func NewRetryTransport(base http.RoundTripper, config RetryConfig, logger logging.SimpleLogging) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if config.MaxRetries <= 0 {
+		return base
+	}
+	return &retryTransport{base: base, config: config, logger: logger}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The body is sent again with each retry.
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close() // nolint: errcheck
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	b := &backoff.Backoff{
+		Min:    t.config.Backoff,
+		Max:    retryMaxWait,
+		Factor: 2,
+		Jitter: true,
+	}
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || !retryableResponse(req, resp) {
+			return resp, err
+		}
+		if attempt == t.config.MaxRetries {
+			t.logger.Warn("giving up on %s %s after %d retries, got %s", req.Method, req.URL.Redacted(), attempt, resp.Status)
+			return resp, nil
+		}
+
+		wait := b.Duration()
+		if after, ok := retryAfter(resp, time.Now()); ok {
+			if after > retryMaxWait {
+				t.logger.Warn("not retrying %s %s, got %s and the VCS host asked to wait %s", req.Method, req.URL.Redacted(), resp.Status, after.Round(time.Second))
+				return resp, nil
+			}
+			wait = after
+		}
+		t.logger.Debug("retrying %s %s in %s, got %s", req.Method, req.URL.Redacted(), wait, resp.Status)
+		// Drain the body so the connection can be reused.
+		io.Copy(io.Discard, resp.Body) // nolint: errcheck
+		resp.Body.Close()              // nolint: errcheck
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryableResponse returns true if resp to req is a transient error that's
+// safe to retry. Rate limited requests weren't processed so they're always
+// retried, but server errors are only retried for idempotent methods since
+// the VCS host may have processed the request, ex. posted a comment, before
+// failing.
+func retryableResponse(req *http.Request, resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotentMethod(req.Method)
+	case http.StatusForbidden:
+		// GitHub responds with a 403 when a rate limit is hit.
+		return resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0"
+	}
+	return false
+}
+
+// idempotentMethod returns true if requests with method can be sent again
+// without changing the outcome, see RFC 9110 section 9.2.2.
+func idempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns how long resp asks to wait before retrying, from its
+// Retry-After header, in seconds or as a date, or from X-RateLimit-Reset if
+// the rate limit was used up.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second, true
+		}
+		if at, err := http.ParseTime(v); err == nil {
+			return max(at.Sub(now), 0), true
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return max(time.Unix(reset, 0).Sub(now), 0), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,196 @@
+package vcs_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestRetryTransport(t *testing.T) {
+	cases := []struct {
+		description string
+		// method defaults to POST.
+		method      string
+		responses   []int
+		header      http.Header
+		maxRetries  int
+		expStatus   int
+		expRequests int
+	}{
+		{
+			description: "rate limited then ok",
+			responses:   []int{http.StatusTooManyRequests, http.StatusOK},
+			header:      http.Header{"Retry-After": {"0"}},
+			maxRetries:  3,
+			expStatus:   http.StatusOK,
+			expRequests: 2,
+		},
+		{
+			description: "server errors until retries run out",
+			method:      http.MethodGet,
+			responses:   []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusInternalServerError},
+			maxRetries:  2,
+			expStatus:   http.StatusInternalServerError,
+			expRequests: 3,
+		},
+		{
+			description: "server error to a post isn't retried",
+			responses:   []int{http.StatusBadGateway, http.StatusOK},
+			maxRetries:  3,
+			expStatus:   http.StatusBadGateway,
+			expRequests: 1,
+		},
+		{
+			description: "server error to a delete is retried",
+			method:      http.MethodDelete,
+			responses:   []int{http.StatusServiceUnavailable, http.StatusOK},
+			maxRetries:  3,
+			expStatus:   http.StatusOK,
+			expRequests: 2,
+		},
+		{
+			description: "secondary rate limit",
+			responses:   []int{http.StatusForbidden, http.StatusOK},
+			header:      http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"0"}},
+			maxRetries:  1,
+			expStatus:   http.StatusOK,
+			expRequests: 2,
+		},
+		{
+			description: "forbidden isn't retried",
+			responses:   []int{http.StatusForbidden, http.StatusOK},
+			maxRetries:  3,
+			expStatus:   http.StatusForbidden,
+			expRequests: 1,
+		},
+		{
+			description: "retry after is too long",
+			responses:   []int{http.StatusTooManyRequests, http.StatusOK},
+			header:      http.Header{"Retry-After": {"3600"}},
+			maxRetries:  3,
+			expStatus:   http.StatusTooManyRequests,
+			expRequests: 1,
+		},
+		{
+			description: "retries disabled",
+			responses:   []int{http.StatusTooManyRequests, http.StatusOK},
+			header:      http.Header{"Retry-After": {"0"}},
+			maxRetries:  0,
+			expStatus:   http.StatusTooManyRequests,
+			expRequests: 1,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				Ok(t, err)
+				Equals(t, "body", string(body))
+				status := c.responses[requests]
+				requests++
+				if status != http.StatusOK {
+					for k, v := range c.header {
+						w.Header()[k] = v
+					}
+				}
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			transport := vcs.NewRetryTransport(nil, vcs.RetryConfig{MaxRetries: c.maxRetries, Backoff: time.Millisecond}, logging.NewNoopLogger(t))
+			client := &http.Client{Transport: transport}
+			// The body is read from a reader without GetBody, so the
+			// transport has to buffer it.
+			method := c.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req, err := http.NewRequest(method, server.URL, io.NopCloser(strings.NewReader("body")))
+			Ok(t, err)
+			resp, err := client.Do(req)
+			Ok(t, err)
+			resp.Body.Close() // nolint: errcheck
+			Equals(t, c.expStatus, resp.StatusCode)
+			Equals(t, c.expRequests, requests)
+		})
+	}
+}
+
+// A comment that's rate limited is posted once GitHub accepts it.
+func TestGithubClient_RetriesRateLimitedComment(t *testing.T) {
+	var requests int
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.RequestURI {
+			case "/api/v3/repos/owner/repo/issues/1/comments":
+				body, err := io.ReadAll(r.Body)
+				Ok(t, err)
+				Equals(t, "{\"body\":\"comment\"}\n", string(body))
+				requests++
+				if requests == 1 {
+					w.Header().Set("Retry-After", "0")
+					http.Error(w, "rate limited", http.StatusTooManyRequests)
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("{}")) // nolint: errcheck
+			default:
+				t.Errorf("got unexpected request at %q", r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+	defer testServer.Close()
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	config := vcs.GithubConfig{Retry: vcs.RetryConfig{MaxRetries: 3, Backoff: time.Millisecond}}
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, config, logging.NewNoopLogger(t))
+	Ok(t, err)
+	defer disableSSLVerification()()
+
+	repo := models.Repo{FullName: "owner/repo", Owner: "owner", Name: "repo"}
+	Ok(t, client.CreateComment(logging.NewNoopLogger(t), repo, 1, "comment", ""))
+	Equals(t, 2, requests)
+}
+
+// GitLab requests are retried by the retry transport instead of the GitLab
+// library, so a comment that failed with a server error isn't posted twice.
+func TestGitlabClient_RetryTransport(t *testing.T) {
+	var comments int
+	testServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/api/v4/version":
+				w.Write([]byte(`{"version": "16.0.0"}`)) // nolint: errcheck
+			case r.URL.Path == "/api/v4/":
+				// Rate limiter requests.
+				w.WriteHeader(http.StatusOK)
+			case r.Method == http.MethodPost && r.URL.RawPath == "/api/v4/projects/owner%2Frepo/merge_requests/1/notes":
+				comments++
+				http.Error(w, "bad gateway", http.StatusBadGateway)
+			default:
+				t.Errorf("got unexpected request %s %q", r.Method, r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+	defer testServer.Close()
+
+	logger := logging.NewNoopLogger(t)
+	transport := vcs.NewRetryTransport(nil, vcs.RetryConfig{MaxRetries: 3, Backoff: time.Millisecond}, logger)
+	client, err := vcs.NewGitlabClient(testServer.URL, "token", transport, logger)
+	Ok(t, err)
+
+	repo := models.Repo{FullName: "owner/repo", Owner: "owner", Name: "repo"}
+	ErrContains(t, "502", client.CreateComment(logger, repo, 1, "comment", ""))
+	Equals(t, 1, comments)
+}
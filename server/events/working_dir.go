@@ -23,6 +23,7 @@ import (
 	"sync"
 
 	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/core/config/valid"
 	"github.com/runatlantis/atlantis/server/core/runtime"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/logging"
@@ -31,6 +32,10 @@ import (
 
 const workingDirPrefix = "repos"
 
+// baseClonesDir is the directory, in the data dir, base branches are cloned
+// to by CloneBase.
+const baseClonesDir = "base-clones"
+
 var cloneLocks sync.Map
 
 //go:generate pegomock generate github.com/runatlantis/atlantis/server/events --package mocks -o mocks/mock_working_dir.go WorkingDir
@@ -43,6 +48,14 @@ type WorkingDir interface {
 	// a boolean indicating if we should warn users that the branch we're
 	// merging into has been updated since we cloned it.
 	Clone(logger logging.SimpleLogging, headRepo models.Repo, p models.PullRequest, workspace string) (string, bool, error)
+	// CloneRef is like Clone but checks out the git ref instead of the pull
+	// request's head, ex. for atlantis plan --ref. The next call to Clone will
+	// check out the pull request's head again.
+	CloneRef(logger logging.SimpleLogging, headRepo models.Repo, p models.PullRequest, workspace string, ref string) (string, error)
+	// CloneBase clones the base branch of p into a new directory, separate
+	// from the pull request's workspaces, ex. for run steps with
+	// diff_against_base, and returns its path. The caller must delete it.
+	CloneBase(logger logging.SimpleLogging, p models.PullRequest) (string, error)
 	// GetWorkingDir returns the path to the workspace for this repo and pull.
 	// If workspace does not exist on disk, error will be of type os.IsNotExist.
 	GetWorkingDir(r models.Repo, p models.PullRequest, workspace string) (string, error)
@@ -87,6 +100,16 @@ type FileWorkspace struct {
 	GpgNoSigningEnabled bool
 	// flag indicating if we have to merge with potential new changes upstream (directly after grabbing project lock)
 	CheckForUpstreamChanges bool
+	// GlobalCfg is the server-side repo config. A repo's clone_depth overrides
+	// CheckoutDepth and the depth used by the branch strategy.
+	GlobalCfg valid.GlobalCfg
+	// CloneURLRewrites rewrite the URLs repos are cloned and fetched from,
+	// ex. to clone from an internal mirror.
+	CloneURLRewrites []CloneURLRewrite
+	// GitCredentialsFile is the git credentials file written with
+	// --write-git-creds, if any. Its credentials for a VCS host are used
+	// for the host clone URLs without credentials are rewritten to.
+	GitCredentialsFile string
 }
 
 // Clone git clones headRepo, checks out the branch and then returns the absolute
@@ -99,6 +122,7 @@ func (w *FileWorkspace) Clone(logger logging.SimpleLogging, headRepo models.Repo
 	cloneDir := w.cloneDir(p.BaseRepo, p, workspace)
 	defer func() { w.CheckForUpstreamChanges = false }()
 
+	headRepo, p = w.rewriteCloneURLs(headRepo, p)
 	c := wrappedGitContext{cloneDir, headRepo, p}
 	// If the directory already exists, check if it's at the right commit.
 	// If so, then we do nothing.
@@ -142,6 +166,94 @@ func (w *FileWorkspace) Clone(logger logging.SimpleLogging, headRepo models.Repo
 	return cloneDir, false, w.forceClone(logger, c)
 }
 
+// CloneRef checks out ref in the clone for p and workspace, cloning the repo
+// first if it doesn't exist yet. ref is fetched from the base repo and a
+// leading "origin/" is dropped so remote branches can be given the way git
+// shows them, ex. origin/hotfix. If the clone is already at ref it does
+// nothing so planning multiple projects doesn't delete their plans.
+func (w *FileWorkspace) CloneRef(logger logging.SimpleLogging, headRepo models.Repo, p models.PullRequest, workspace string, ref string) (string, error) {
+	cloneDir := w.cloneDir(p.BaseRepo, p, workspace)
+	headRepo, p = w.rewriteCloneURLs(headRepo, p)
+	c := wrappedGitContext{cloneDir, headRepo, p}
+	if _, err := os.Stat(cloneDir); err != nil {
+		if err := w.forceClone(logger, c); err != nil {
+			return cloneDir, err
+		}
+	}
+
+	baseCloneURL := p.BaseRepo.CloneURL
+	if w.TestingOverrideBaseCloneURL != "" {
+		baseCloneURL = w.TestingOverrideBaseCloneURL
+	}
+	ref = strings.TrimPrefix(ref, "origin/")
+	fetchArgs := []string{"fetch"}
+	if depth := w.cloneDepth(p); depth > 0 {
+		fetchArgs = append(fetchArgs, "--depth", fmt.Sprint(depth))
+	}
+	fetchArgs = append(fetchArgs, baseCloneURL, ref)
+	if err := w.wrappedGit(logger, c, fetchArgs...); err != nil {
+		// Only branches, tags and full commit hashes can be fetched, so ref
+		// may be an abbreviated or older commit. Fall back to fetching the
+		// full history of the base repo and resolving ref in it.
+		logger.Debug("could not fetch ref %q, fetching the full history instead: %s", ref, err)
+		if err := w.fetchFullHistory(logger, c, baseCloneURL); err != nil {
+			return cloneDir, err
+		}
+		if err := w.wrappedGit(logger, c, "update-ref", "FETCH_HEAD", ref+"^{commit}"); err != nil {
+			return cloneDir, err
+		}
+	}
+
+	revParseCmd := exec.Command("git", "rev-parse", "HEAD", "FETCH_HEAD") // #nosec
+	revParseCmd.Dir = cloneDir
+	out, err := revParseCmd.Output()
+	if err == nil {
+		if commits := strings.Fields(string(out)); len(commits) == 2 && commits[0] == commits[1] {
+			logger.Debug("repo is already at ref %q so will not check it out again", ref)
+			return cloneDir, nil
+		}
+	}
+	logger.Info("checking out ref %q instead of the pull request's head", ref)
+	return cloneDir, w.wrappedGit(logger, c, "checkout", "--detach", "FETCH_HEAD")
+}
+
+// fetchFullHistory fetches every branch and tag of the repo at url with
+// their full history, unshallowing the clone in c.dir if it's shallow.
+func (w *FileWorkspace) fetchFullHistory(logger logging.SimpleLogging, c wrappedGitContext, url string) error {
+	fetchArgs := []string{"fetch", "--tags"}
+	if _, err := os.Stat(filepath.Join(c.dir, ".git", "shallow")); err == nil {
+		fetchArgs = append(fetchArgs, "--unshallow")
+	}
+	fetchArgs = append(fetchArgs, url, "+refs/heads/*:refs/remotes/origin/*")
+	return w.wrappedGit(logger, c, fetchArgs...)
+}
+
+// CloneBase clones the head of p's base branch into a new directory under
+// baseClonesDir. Only the last commit is cloned since the clone is only read.
+func (w *FileWorkspace) CloneBase(logger logging.SimpleLogging, p models.PullRequest) (string, error) {
+	_, p = w.rewriteCloneURLs(p.BaseRepo, p)
+	parent := filepath.Join(w.DataDir, baseClonesDir)
+	if err := os.MkdirAll(parent, 0700); err != nil {
+		return "", errors.Wrap(err, "creating base clones dir")
+	}
+	dir, err := os.MkdirTemp(parent, "")
+	if err != nil {
+		return "", errors.Wrap(err, "creating base clone dir")
+	}
+
+	baseCloneURL := p.BaseRepo.CloneURL
+	if w.TestingOverrideBaseCloneURL != "" {
+		baseCloneURL = w.TestingOverrideBaseCloneURL
+	}
+	c := wrappedGitContext{dir, p.BaseRepo, p}
+	logger.Info("cloning base branch %q into %q", p.BaseBranch, dir)
+	if err := w.wrappedGit(logger, c, "clone", "--depth", "1", "--branch", p.BaseBranch, "--single-branch", baseCloneURL, dir); err != nil {
+		os.RemoveAll(dir) // nolint: errcheck
+		return "", err
+	}
+	return dir, nil
+}
+
 // recheckDiverged returns true if the branch we're merging into has diverged
 // from what we currently have checked out.
 // This matters in the case of the merge checkout strategy because after
@@ -226,7 +338,13 @@ func (w *FileWorkspace) forceClone(logger logging.SimpleLogging, c wrappedGitCon
 		return nil
 	}
 
-	err := os.RemoveAll(c.dir)
+	// Pinned plans are kept across clones so new commits don't discard them.
+	pinnedPlans, err := runtime.ReadPinnedPlans(c.dir)
+	if err != nil {
+		return errors.Wrapf(err, "reading pinned plans in '%s' before cloning", c.dir)
+	}
+
+	err = os.RemoveAll(c.dir)
 	if err != nil {
 		return errors.Wrapf(err, "deleting dir '%s' before cloning", c.dir)
 	}
@@ -247,22 +365,34 @@ func (w *FileWorkspace) forceClone(logger logging.SimpleLogging, c wrappedGitCon
 		baseCloneURL = w.TestingOverrideBaseCloneURL
 	}
 
-	// if branch strategy, use depth=1
+	// if no depth, omit depth arg
+	cloneArgs := []string{"clone"}
+	if depth := w.cloneDepth(c.pr); depth > 0 {
+		cloneArgs = append(cloneArgs, "--depth", fmt.Sprint(depth))
+	}
+
+	if err := w.cloneInto(logger, c, cloneArgs, headCloneURL, baseCloneURL); err != nil {
+		return err
+	}
+	if len(pinnedPlans) > 0 {
+		logger.Info("restoring %d pinned plan(s) in '%s'", len(pinnedPlans), c.dir)
+	}
+	return runtime.RestorePinnedPlans(c.dir, pinnedPlans)
+}
+
+// cloneInto runs git clone with cloneArgs into the empty dir c.dir, merging
+// the head branch into the base branch with the merge strategy.
+func (w *FileWorkspace) cloneInto(logger logging.SimpleLogging, c wrappedGitContext, cloneArgs []string, headCloneURL string, baseCloneURL string) error {
+	// if branch strategy, clone the head branch
 	if !w.CheckoutMerge {
-		return w.wrappedGit(logger, c, "clone", "--depth=1", "--branch", c.pr.HeadBranch, "--single-branch", headCloneURL, c.dir)
+		cloneArgs = append(cloneArgs, "--branch", c.pr.HeadBranch, "--single-branch", headCloneURL, c.dir)
+		return w.wrappedGit(logger, c, cloneArgs...)
 	}
 
 	// if merge strategy...
-
-	// if no checkout depth, omit depth arg
-	if w.CheckoutDepth == 0 {
-		if err := w.wrappedGit(logger, c, "clone", "--branch", c.pr.BaseBranch, "--single-branch", baseCloneURL, c.dir); err != nil {
-			return err
-		}
-	} else {
-		if err := w.wrappedGit(logger, c, "clone", "--depth", fmt.Sprint(w.CheckoutDepth), "--branch", c.pr.BaseBranch, "--single-branch", baseCloneURL, c.dir); err != nil {
-			return err
-		}
+	cloneArgs = append(cloneArgs, "--branch", c.pr.BaseBranch, "--single-branch", baseCloneURL, c.dir)
+	if err := w.wrappedGit(logger, c, cloneArgs...); err != nil {
+		return err
 	}
 
 	if err := w.wrappedGit(logger, c, "remote", "add", "head", headCloneURL); err != nil {
@@ -297,6 +427,22 @@ func (w *FileWorkspace) mergeAgain(logger logging.SimpleLogging, c wrappedGitCon
 	return w.mergeToBaseBranch(logger, c)
 }
 
+// cloneDepth returns how many commits to clone and fetch for p, or 0 to
+// retrieve the full history. The branch strategy only ever needs the head
+// commit so it defaults to 1, and the merge strategy defaults to CheckoutDepth.
+// Atlantis fetches more history itself only when the merge base or a ref
+// planned with --ref isn't within the depth; run steps that need more history
+// must fetch it themselves.
+func (w *FileWorkspace) cloneDepth(p models.PullRequest) int {
+	if depth := w.GlobalCfg.RepoCloneDepth(p.BaseRepo.ID()); depth != nil {
+		return *depth
+	}
+	if !w.CheckoutMerge {
+		return 1
+	}
+	return w.CheckoutDepth
+}
+
 // wrappedGitContext is the configuration for wrappedGit that is typically unchanged
 // for a series of calls to wrappedGit
 type wrappedGitContext struct {
@@ -337,12 +483,13 @@ func (w *FileWorkspace) mergeToBaseBranch(logger logging.SimpleLogging, c wrappe
 	}
 
 	// if no checkout depth, omit depth arg
-	if w.CheckoutDepth == 0 {
+	depth := w.cloneDepth(c.pr)
+	if depth == 0 {
 		if err := w.wrappedGit(logger, c, "fetch", fetchRemote, fetchRef); err != nil {
 			return err
 		}
 	} else {
-		if err := w.wrappedGit(logger, c, "fetch", "--depth", fmt.Sprint(w.CheckoutDepth), fetchRemote, fetchRef); err != nil {
+		if err := w.wrappedGit(logger, c, "fetch", "--depth", fmt.Sprint(depth), fetchRemote, fetchRef); err != nil {
 			return err
 		}
 	}
@@ -405,6 +552,16 @@ func (w *FileWorkspace) cloneDir(r models.Repo, p models.PullRequest, workspace
 	return filepath.Join(w.repoPullDir(r, p), workspace)
 }
 
+// rewriteCloneURLs returns headRepo and the pull request's base repo with
+// their clone URLs rewritten by w.CloneURLRewrites.
+func (w *FileWorkspace) rewriteCloneURLs(headRepo models.Repo, p models.PullRequest) (models.Repo, models.PullRequest) {
+	if len(w.CloneURLRewrites) == 0 {
+		return headRepo, p
+	}
+	p.BaseRepo = rewriteRepoCloneURLs(w.CloneURLRewrites, w.GitCredentialsFile, p.BaseRepo)
+	return rewriteRepoCloneURLs(w.CloneURLRewrites, w.GitCredentialsFile, headRepo), p
+}
+
 // sanitizeGitCredentials replaces any git clone urls that contain credentials
 // in s with the sanitized versions.
 func (w *FileWorkspace) sanitizeGitCredentials(s string, base models.Repo, head models.Repo) string {
@@ -420,6 +577,12 @@ func (w *FileWorkspace) SetCheckForUpstreamChanges() {
 func (w *FileWorkspace) DeletePlan(logger logging.SimpleLogging, r models.Repo, p models.PullRequest, workspace string, projectPath string, projectName string) error {
 	planPath := filepath.Join(w.cloneDir(r, p, workspace), projectPath, runtime.GetPlanFilename(workspace, projectName))
 	logger.Info("Deleting plan: " + planPath)
+	if err := runtime.UnpinPlan(planPath); err != nil {
+		return err
+	}
+	if err := runtime.RemovePlanTTL(planPath); err != nil {
+		return err
+	}
 	return utils.RemoveIgnoreNonExistent(planPath)
 }
 
@@ -0,0 +1,48 @@
+package events
+
+import (
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// WorkingDirCleaner deletes the working dirs of pull requests as set by their
+// repo's clean_workspace so they're cloned again, ex. to avoid runs failing
+// because of state left in the working dir by earlier runs.
+type WorkingDirCleaner struct {
+	WorkingDir       WorkingDir
+	WorkingDirLocker WorkingDirLocker
+	GlobalCfg        valid.GlobalCfg
+}
+
+// BeforePlan deletes the working dirs of pull if its repo's clean_workspace
+// is always, so the plan runs in a fresh clone. It's only called before plans
+// since applies need the plans in the working dirs.
+func (w *WorkingDirCleaner) BeforePlan(logger logging.SimpleLogging, pull models.PullRequest) {
+	if w.GlobalCfg.RepoCleanWorkspace(pull.BaseRepo.ID()) == valid.CleanWorkspaceAlways {
+		w.clean(logger, pull)
+	}
+}
+
+// AfterRun deletes the working dirs of pull if its repo's clean_workspace is
+// on-error and a project errored in the run, so the next run clones the repo
+// again.
+func (w *WorkingDirCleaner) AfterRun(logger logging.SimpleLogging, pull models.PullRequest, errored bool) {
+	if errored && w.GlobalCfg.RepoCleanWorkspace(pull.BaseRepo.ID()) == valid.CleanWorkspaceOnError {
+		w.clean(logger, pull)
+	}
+}
+
+func (w *WorkingDirCleaner) clean(logger logging.SimpleLogging, pull models.PullRequest) {
+	// Other commands for the pull request may be using its working dirs, in
+	// which case they're left for the next run to clean.
+	unlock, err := w.WorkingDirLocker.TryLockPull(pull.BaseRepo.FullName, pull.Num)
+	if err != nil {
+		logger.Warn("not cleaning working dir as set by clean_workspace: %s", err)
+		return
+	}
+	defer unlock()
+	if err := w.WorkingDir.Delete(logger, pull.BaseRepo, pull); err != nil {
+		logger.Err("cleaning working dir as set by clean_workspace: %s", err)
+	}
+}
@@ -0,0 +1,91 @@
+package events_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/core/config/valid"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestWorkingDirCleaner(t *testing.T) {
+	cases := []struct {
+		policy        string
+		errored       bool
+		expBeforePlan bool
+		expAfterRun   bool
+	}{
+		{policy: "", expBeforePlan: false, expAfterRun: false},
+		{policy: valid.CleanWorkspaceNever, errored: true, expBeforePlan: false, expAfterRun: false},
+		{policy: valid.CleanWorkspaceAlways, errored: true, expBeforePlan: true, expAfterRun: false},
+		{policy: valid.CleanWorkspaceOnError, expBeforePlan: false, expAfterRun: false},
+		{policy: valid.CleanWorkspaceOnError, errored: true, expBeforePlan: false, expAfterRun: true},
+	}
+	for _, c := range cases {
+		t.Run(c.policy, func(t *testing.T) {
+			logger := logging.NewNoopLogger(t)
+			dataDir := t.TempDir()
+			pull := models.PullRequest{
+				Num:      1,
+				BaseRepo: models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Hostname: "github.com"}},
+			}
+			cleaner := &events.WorkingDirCleaner{
+				WorkingDir:       &events.FileWorkspace{DataDir: dataDir},
+				WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+				GlobalCfg: valid.GlobalCfg{
+					Repos: []valid.Repo{{IDRegex: regexp.MustCompile(".*"), CleanWorkspace: c.policy}},
+				},
+			}
+			planFile := filepath.Join(dataDir, "repos", "owner/repo", "1", "default", "default.tfplan")
+
+			Ok(t, os.MkdirAll(filepath.Dir(planFile), 0700))
+			Ok(t, os.WriteFile(planFile, nil, 0600))
+			cleaner.BeforePlan(logger, pull)
+			_, err := os.Stat(planFile)
+			Equals(t, c.expBeforePlan, os.IsNotExist(err))
+
+			Ok(t, os.MkdirAll(filepath.Dir(planFile), 0700))
+			Ok(t, os.WriteFile(planFile, nil, 0600))
+			cleaner.AfterRun(logger, pull, c.errored)
+			_, err = os.Stat(planFile)
+			Equals(t, c.expAfterRun, os.IsNotExist(err))
+		})
+	}
+}
+
+// Test that the working dir isn't deleted while another command for the pull
+// request is using it.
+func TestWorkingDirCleaner_Locked(t *testing.T) {
+	logger := logging.NewNoopLogger(t)
+	dataDir := t.TempDir()
+	pull := models.PullRequest{
+		Num:      1,
+		BaseRepo: models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Hostname: "github.com"}},
+	}
+	locker := events.NewDefaultWorkingDirLocker()
+	cleaner := &events.WorkingDirCleaner{
+		WorkingDir:       &events.FileWorkspace{DataDir: dataDir},
+		WorkingDirLocker: locker,
+		GlobalCfg: valid.GlobalCfg{
+			Repos: []valid.Repo{{IDRegex: regexp.MustCompile(".*"), CleanWorkspace: valid.CleanWorkspaceAlways}},
+		},
+	}
+	cloneDir := filepath.Join(dataDir, "repos", "owner/repo", "1", "default")
+	Ok(t, os.MkdirAll(cloneDir, 0700))
+
+	unlock, err := locker.TryLock("owner/repo", 1, "default", ".")
+	Ok(t, err)
+	cleaner.BeforePlan(logger, pull)
+	_, err = os.Stat(cloneDir)
+	Ok(t, err)
+
+	unlock()
+	cleaner.BeforePlan(logger, pull)
+	_, err = os.Stat(cloneDir)
+	Assert(t, os.IsNotExist(err), "exp clone dir to be deleted once unlocked")
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/runatlantis/atlantis/server/events/command"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/utils"
 )
 
 type OutputBuffer struct {
@@ -156,7 +157,7 @@ func (p *AsyncProjectCommandOutputHandler) Send(ctx command.ProjectContext, msg
 			},
 			JobStep: ctx.CommandName.String(),
 		},
-		Line:              msg,
+		Line:              utils.MaskSecrets(msg, ctx.OutputSecrets),
 		OperationComplete: operationComplete,
 	}
 }
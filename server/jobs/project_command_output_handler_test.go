@@ -93,6 +93,31 @@ func TestProjectCommandOutputHandler(t *testing.T) {
 		Equals(t, expectedMsg, Msg)
 	})
 
+	t.Run("masks output secrets", func(t *testing.T) {
+		var wg sync.WaitGroup
+		var expectedMsg string
+		projectOutputHandler := createProjectCommandOutputHandler(t)
+
+		ch := make(chan string, 1)
+		projectOutputHandler.Register(ctx.JobID, ch)
+
+		wg.Add(1)
+		go func() {
+			for msg := range ch {
+				expectedMsg = msg
+				wg.Done()
+			}
+		}()
+
+		secretCtx := ctx
+		secretCtx.OutputSecrets = []string{"hunt", "hunter2"}
+		projectOutputHandler.Send(secretCtx, "password is hunter2", false)
+		wg.Wait()
+		close(ch)
+
+		Equals(t, "password is ***", expectedMsg)
+	})
+
 	t.Run("copies buffer to new channels", func(t *testing.T) {
 		var wg sync.WaitGroup
 
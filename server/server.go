@@ -28,6 +28,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"syscall"
@@ -45,6 +46,7 @@ import (
 	"github.com/runatlantis/atlantis/server/jobs"
 	"github.com/runatlantis/atlantis/server/metrics"
 	"github.com/runatlantis/atlantis/server/scheduled"
+	"github.com/runatlantis/atlantis/server/tracing"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -84,6 +86,12 @@ const (
 	// terraformPluginCacheDir is the name of the dir inside our data dir
 	// where we tell terraform to cache plugins and modules.
 	TerraformPluginCacheDirName = "plugin-cache"
+	// StepCacheDirName is the name of the dir inside our data dir where the
+	// cache of run steps with cache set is stored.
+	StepCacheDirName = "step-cache"
+	// RunStepFixturesDirName is the name of the dir inside our data dir where
+	// run step fixtures are recorded by default.
+	RunStepFixturesDirName = "run-step-fixtures"
 )
 
 // Server runs the Atlantis web server.
@@ -99,6 +107,8 @@ type Server struct {
 	StatsScope                     tally.Scope
 	StatsReporter                  tally.BaseStatsReporter
 	StatsCloser                    io.Closer
+	Tracer                         *tracing.Tracer
+	StepApprovals                  *runtime.StepApprovals
 	Locker                         locking.Locker
 	ApplyLocker                    locking.ApplyLocker
 	VCSEventsController            *events_controllers.VCSEventsController
@@ -127,12 +137,13 @@ type Server struct {
 
 // Config holds config for server that isn't passed in by the user.
 type Config struct {
-	AllowForkPRsFlag        string
-	AtlantisURLFlag         string
-	AtlantisVersion         string
-	DefaultTFVersionFlag    string
-	RepoConfigJSONFlag      string
-	SilenceForkPRErrorsFlag string
+	AllowForkPRsFlag             string
+	AtlantisURLFlag              string
+	AtlantisVersion              string
+	DefaultTFVersionFlag         string
+	RepoConfigJSONFlag           string
+	SilenceForkPRErrorsFlag      string
+	VCSStatusContextTemplateFlag string
 }
 
 // WebhookConfig is nested within UserConfig. It's used to configure webhooks.
@@ -197,7 +208,7 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		}
 	}
 
-	validator := &cfg.ParserValidator{}
+	validator := &cfg.ParserValidator{RunIDEnvVar: userConfig.RunIDEnvVar}
 
 	globalCfg := valid.NewGlobalCfgFromArgs(
 		valid.GlobalCfgArgs{
@@ -221,11 +232,25 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		return nil, errors.Wrapf(err, "instantiating metrics scope")
 	}
 
+	var tracer *tracing.Tracer
+	if userConfig.OTelEndpoint != "" {
+		tracer, err = tracing.NewTracer(userConfig.OTelEndpoint, logger)
+		if err != nil {
+			return nil, errors.Wrapf(err, "instantiating tracer")
+		}
+	}
+
+	vcsRetryConfig := vcs.RetryConfig{
+		MaxRetries: userConfig.VCSMaxRetries,
+		Backoff:    time.Duration(userConfig.VCSRetryBackoffSeconds) * time.Second,
+	}
+	// vcsTransport retries the requests of the VCS clients other than GitHub's,
+	// which sets up its own from vcsRetryConfig.
+	vcsTransport := vcs.NewRetryTransport(http.DefaultTransport, vcsRetryConfig, logger)
 	if userConfig.GithubUser != "" || userConfig.GithubAppID != 0 {
-		if userConfig.GithubAllowMergeableBypassApply {
-			githubConfig = vcs.GithubConfig{
-				AllowMergeableBypassApply: true,
-			}
+		githubConfig = vcs.GithubConfig{
+			AllowMergeableBypassApply: userConfig.GithubAllowMergeableBypassApply,
+			Retry:                     vcsRetryConfig,
 		}
 		supportedVCSHosts = append(supportedVCSHosts, models.Github)
 		if userConfig.GithubUser != "" {
@@ -266,16 +291,17 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 	if userConfig.GitlabUser != "" {
 		supportedVCSHosts = append(supportedVCSHosts, models.Gitlab)
 		var err error
-		gitlabClient, err = vcs.NewGitlabClient(userConfig.GitlabHostname, userConfig.GitlabToken, logger)
+		gitlabClient, err = vcs.NewGitlabClient(userConfig.GitlabHostname, userConfig.GitlabToken, vcsTransport, logger)
 		if err != nil {
 			return nil, err
 		}
 	}
 	if userConfig.BitbucketUser != "" {
+		bitbucketHTTPClient := &http.Client{Transport: vcsTransport}
 		if userConfig.BitbucketBaseURL == bitbucketcloud.BaseURL {
 			supportedVCSHosts = append(supportedVCSHosts, models.BitbucketCloud)
 			bitbucketCloudClient = bitbucketcloud.NewClient(
-				http.DefaultClient,
+				bitbucketHTTPClient,
 				userConfig.BitbucketUser,
 				userConfig.BitbucketToken,
 				userConfig.AtlantisURL)
@@ -283,7 +309,7 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 			supportedVCSHosts = append(supportedVCSHosts, models.BitbucketServer)
 			var err error
 			bitbucketServerClient, err = bitbucketserver.NewClient(
-				http.DefaultClient,
+				bitbucketHTTPClient,
 				userConfig.BitbucketUser,
 				userConfig.BitbucketToken,
 				userConfig.BitbucketBaseURL,
@@ -297,7 +323,7 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		supportedVCSHosts = append(supportedVCSHosts, models.AzureDevops)
 
 		var err error
-		azuredevopsClient, err = vcs.NewAzureDevopsClient(userConfig.AzureDevOpsHostname, userConfig.AzureDevopsUser, userConfig.AzureDevopsToken)
+		azuredevopsClient, err = vcs.NewAzureDevopsClient(userConfig.AzureDevOpsHostname, userConfig.AzureDevopsUser, userConfig.AzureDevopsToken, vcsTransport)
 		if err != nil {
 			return nil, err
 		}
@@ -305,7 +331,7 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 	if userConfig.GiteaToken != "" {
 		supportedVCSHosts = append(supportedVCSHosts, models.Gitea)
 
-		giteaClient, err = gitea.NewClient(userConfig.GiteaBaseURL, userConfig.GiteaUser, userConfig.GiteaToken, userConfig.GiteaPageSize, logger)
+		giteaClient, err = gitea.NewClient(&http.Client{Transport: vcsTransport}, userConfig.GiteaBaseURL, userConfig.GiteaUser, userConfig.GiteaToken, userConfig.GiteaPageSize, logger)
 		if err != nil {
 			fmt.Println("error setting up gitea client", "error", err)
 			return nil, errors.Wrapf(err, "setting up Gitea client")
@@ -377,7 +403,18 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		return nil, errors.Wrap(err, "initializing webhooks")
 	}
 	vcsClient := vcs.NewClientProxy(githubClient, gitlabClient, bitbucketCloudClient, bitbucketServerClient, azuredevopsClient, giteaClient)
-	commitStatusUpdater := &events.DefaultCommitStatusUpdater{Client: vcsClient, StatusName: userConfig.VCSStatusName}
+	commitStatusUpdater := &events.DefaultCommitStatusUpdater{
+		Client:         vcsClient,
+		StatusName:     userConfig.VCSStatusName,
+		StatusStrategy: userConfig.VCSStatusStrategy,
+		GlobalCfg:      globalCfg,
+	}
+	if userConfig.VCSStatusContextTemplate != "" {
+		commitStatusUpdater.ContextTemplate, err = events.NewStatusContextTemplate(userConfig.VCSStatusContextTemplate)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing --%s", config.VCSStatusContextTemplateFlag)
+		}
+	}
 
 	binDir, err := mkSubDir(userConfig.DataDir, BinDirName)
 
@@ -407,10 +444,15 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 	}
 
 	var projectCmdOutputHandler jobs.ProjectCommandOutputHandler
+	var tfcRunClient *runtime.TFCRunClient
 
 	if userConfig.TFEToken != "" && !userConfig.TFELocalExecutionMode {
 		// When TFE is enabled and using remote execution mode log streaming is not necessary.
 		projectCmdOutputHandler = &jobs.NoopProjectOutputHandler{}
+		tfcRunClient = &runtime.TFCRunClient{
+			Address: "https://" + userConfig.TFEHostname,
+			Token:   userConfig.TFEToken,
+		}
 	} else {
 		projectCmdOutput := make(chan *jobs.ProjectCmdOutputLine)
 		projectCmdOutputHandler = jobs.NewAsyncProjectCommandOutputHandler(
@@ -470,11 +512,13 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 	}
 
 	noOpLocker := locking.NewNoOpLocker()
+	var applyQueue *events.ApplyQueue
 	if userConfig.DisableRepoLocking {
 		logger.Info("Repo Locking is disabled")
 		lockingClient = noOpLocker
 	} else {
-		lockingClient = locking.NewClient(backend)
+		applyQueue = events.NewApplyQueue(locking.NewClient(backend), userConfig.ApplyQueueMaxSize)
+		lockingClient = applyQueue
 	}
 	disableGlobalApplyLock := false
 	if userConfig.DisableGlobalApplyLock {
@@ -484,11 +528,22 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 	applyLockingClient = locking.NewApplyClient(backend, disableApply, disableGlobalApplyLock)
 	workingDirLocker := events.NewDefaultWorkingDirLocker()
 
+	cloneURLRewrites, err := userConfig.ToCloneURLRewrites()
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing clone URL rewrites")
+	}
+	var gitCredentialsFile string
+	if userConfig.WriteGitCreds {
+		gitCredentialsFile = filepath.Join(home, ".git-credentials")
+	}
 	var workingDir events.WorkingDir = &events.FileWorkspace{
-		DataDir:          userConfig.DataDir,
-		CheckoutMerge:    userConfig.CheckoutStrategy == "merge",
-		CheckoutDepth:    userConfig.CheckoutDepth,
-		GithubAppEnabled: githubAppEnabled,
+		DataDir:            userConfig.DataDir,
+		CheckoutMerge:      userConfig.CheckoutStrategy == "merge",
+		CheckoutDepth:      userConfig.CheckoutDepth,
+		GithubAppEnabled:   githubAppEnabled,
+		GlobalCfg:          globalCfg,
+		CloneURLRewrites:   cloneURLRewrites,
+		GitCredentialsFile: gitCredentialsFile,
 	}
 
 	scheduledExecutorService := scheduled.NewExecutorService(
@@ -527,6 +582,7 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		Backend:          backend,
 	}
 
+	runCanceller := events.NewRunCanceller()
 	pullClosedExecutor := events.NewInstrumentedPullClosedExecutor(
 		statsScope,
 		logger,
@@ -537,6 +593,7 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 			PullClosedTemplate:       &events.PullClosedEventTemplate{},
 			LogStreamResourceCleaner: projectCmdOutputHandler,
 			VCSClient:                vcsClient,
+			RunCanceller:             runCanceller,
 		},
 	)
 
@@ -565,11 +622,44 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 	)
 	defaultTfVersion := terraformClient.DefaultVersion()
 	pendingPlanFinder := &events.DefaultPendingPlanFinder{}
+	expiredPlanDiscarder := &events.ExpiredPlanDiscarder{
+		PendingPlanFinder:   pendingPlanFinder,
+		WorkingDir:          workingDir,
+		Locker:              lockingClient,
+		Backend:             backend,
+		CommitStatusUpdater: commitStatusUpdater,
+	}
+	projectLocker.ExpiredPlanDiscarder = expiredPlanDiscarder
+	var runStepFixtures *runtime.RunStepFixtures
+	if userConfig.RunStepFixtures != "" {
+		fixturesDir := userConfig.RunStepFixturesDir
+		if fixturesDir == "" {
+			fixturesDir = filepath.Join(userConfig.DataDir, RunStepFixturesDirName)
+		}
+		runStepFixtures, err = runtime.NewRunStepFixtures(fixturesDir, runtime.RunStepFixturesMode(userConfig.RunStepFixtures))
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing run step fixtures")
+		}
+		logger.Warn("run step fixtures are in %s mode with fixtures in %q, this is only meant for developing workflows", userConfig.RunStepFixtures, fixturesDir)
+	}
+	// Steps with require_approval set fail without waiting if they can't be
+	// approved.
+	var stepApprovals *runtime.StepApprovals
+	if slices.Contains(allowCommands, command.ApproveStep) {
+		stepApprovals = runtime.NewStepApprovals(vcsClient, commentParser)
+	}
 	runStepRunner := &runtime.RunStepRunner{
 		TerraformExecutor:       terraformClient,
 		DefaultTFVersion:        defaultTfVersion,
 		TerraformBinDir:         terraformClient.TerraformBinDir(),
 		ProjectCmdOutputHandler: projectCmdOutputHandler,
+		PullCommentUpdater:      vcsClient,
+		RateLimiters:            runtime.NewRateLimiters(),
+		StepCache:               runtime.NewStepCache(filepath.Join(userConfig.DataDir, StepCacheDirName), int64(userConfig.StepCacheMaxSizeMB)*1024*1024),
+		RunIDEnvVar:             userConfig.RunIDEnvVar,
+		Fixtures:                runStepFixtures,
+		StepApprovals:           stepApprovals,
+		BaseCloner:              workingDir,
 	}
 	drainer := &events.Drainer{}
 	statusController := &controllers.StatusController{
@@ -640,8 +730,30 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		return nil, errors.Wrap(err, "initializing policy check step runner")
 	}
 
+	var planEncryptor *runtime.PlanEncryptor
+	if userConfig.PlanEncryptionKeyFile != "" || userConfig.PlanEncryptionKMSKeyID != "" {
+		var planKeys runtime.PlanKeyProvider
+		if userConfig.PlanEncryptionKeyFile != "" {
+			planKeys, err = runtime.NewLocalPlanKeys(userConfig.PlanEncryptionKeyFile)
+		} else {
+			planKeys, err = runtime.NewKMSPlanKeys(userConfig.PlanEncryptionKMSKeyID)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing plan encryption")
+		}
+		planEncryptor = &runtime.PlanEncryptor{Keys: planKeys}
+	}
+
 	applyRequirementHandler := &events.DefaultCommandRequirementHandler{
-		WorkingDir: workingDir,
+		WorkingDir:       workingDir,
+		ShowStepRunner:   showStepRunner,
+		PlanEncryptor:    planEncryptor,
+		WorkingDirLocker: workingDirLocker,
+	}
+
+	var secretStore runtime.SecretStore
+	if userConfig.SecretsDir != "" {
+		secretStore = &runtime.DirSecretStore{Dir: userConfig.SecretsDir}
 	}
 
 	projectCommandRunner := &events.DefaultProjectCommandRunner{
@@ -652,7 +764,7 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 			TerraformExecutor: terraformClient,
 			DefaultTFVersion:  defaultTfVersion,
 		},
-		PlanStepRunner:        runtime.NewPlanStepRunner(terraformClient, defaultTfVersion, commitStatusUpdater, terraformClient),
+		PlanStepRunner:        runtime.NewPlanStepRunner(terraformClient, defaultTfVersion, commitStatusUpdater, terraformClient, tfcRunClient),
 		ShowStepRunner:        showStepRunner,
 		PolicyCheckStepRunner: policyCheckStepRunner,
 		ApplyStepRunner: &runtime.ApplyStepRunner{
@@ -660,11 +772,17 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 			DefaultTFVersion:    defaultTfVersion,
 			CommitStatusUpdater: commitStatusUpdater,
 			AsyncTFExec:         terraformClient,
+			TFCRunClient:        tfcRunClient,
+			PullCommentUpdater:  vcsClient,
 		},
 		RunStepRunner: runStepRunner,
 		EnvStepRunner: &runtime.EnvStepRunner{
 			RunStepRunner: runStepRunner,
 		},
+		SSMEnvStepRunner: &runtime.SSMEnvStepRunner{
+			ParameterGetter: &runtime.AWSSSMParameterGetter{},
+		},
+		SecretStore: secretStore,
 		MultiEnvStepRunner: &runtime.MultiEnvStepRunner{
 			RunStepRunner: runStepRunner,
 		},
@@ -678,6 +796,11 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		Webhooks:                  webhooksManager,
 		WorkingDirLocker:          workingDirLocker,
 		CommandRequirementHandler: applyRequirementHandler,
+		SeparateStepComments:      events.NewSeparateStepComments(vcsClient),
+		ReviewStepComments:        &events.ReviewStepComments{VCSClient: vcsClient},
+		PlanEncryptor:             planEncryptor,
+		ApplyQueue:                applyQueue,
+		RunStepFixtures:           runStepFixtures,
 	}
 
 	dbUpdater := &events.DBUpdater{
@@ -688,6 +811,11 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		HidePrevPlanComments: userConfig.HidePrevPlanComments,
 		VCSClient:            vcsClient,
 		MarkdownRenderer:     markdownRenderer,
+		GlobalCfg:            globalCfg,
+		ResultExporter: &events.ResultExporter{
+			VCSClient: vcsClient,
+			GlobalCfg: globalCfg,
+		},
 	}
 
 	autoMerger := &events.AutoMerger{
@@ -715,7 +843,7 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		userConfig.QuietPolicyChecks,
 	)
 
-	pullReqStatusFetcher := vcs.NewPullReqStatusFetcher(vcsClient, userConfig.VCSStatusName)
+	pullReqStatusFetcher := vcs.NewPullReqStatusFetcher(vcsClient, commitStatusUpdater.ApplyStatusContext(logger))
 	planCommandRunner := events.NewPlanCommandRunner(
 		userConfig.SilenceVCSStatusNoPlans,
 		userConfig.SilenceVCSStatusNoProjects,
@@ -735,6 +863,7 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		lockingClient,
 		userConfig.DiscardApprovalOnPlanFlag,
 		pullReqStatusFetcher,
+		userConfig.VCSStatusPlanSummary,
 	)
 
 	applyCommandRunner := events.NewApplyCommandRunner(
@@ -765,11 +894,68 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		vcsClient,
 	)
 
+	policyCheckCommentCommandRunner := events.NewPolicyCheckCommentCommandRunner(
+		commitStatusUpdater,
+		projectCommandBuilder,
+		policyCheckCommandRunner,
+		pullUpdater,
+		policyChecksEnabled,
+	)
+
 	unlockCommandRunner := events.NewUnlockCommandRunner(
 		deleteLockCommand,
 		vcsClient,
 		userConfig.SilenceNoProjects,
 		userConfig.DisableUnlockLabel,
+		globalCfg.PolicySets.Owners,
+	)
+
+	discardPlanCommandRunner := events.NewDiscardPlanCommandRunner(
+		vcsClient,
+		pendingPlanFinder,
+		workingDir,
+		workingDirLocker,
+		backend,
+		globalCfg.PolicySets.Owners,
+		userConfig.SilenceNoProjects,
+	)
+
+	pinPlanCommandRunner := events.NewPinPlanCommandRunner(
+		vcsClient,
+		pendingPlanFinder,
+		workingDir,
+		globalCfg.PolicySets.Owners,
+	)
+
+	lockStatusCommandRunner := events.NewLockStatusCommandRunner(
+		vcsClient,
+		lockingClient,
+	)
+
+	comparePlanCommandRunner := events.NewComparePlanCommandRunner(
+		vcsClient,
+		pendingPlanFinder,
+		workingDir,
+		workingDirLocker,
+		showStepRunner,
+		terraformClient,
+		planEncryptor,
+	)
+
+	explainWorkflowCommandRunner := events.NewExplainWorkflowCommandRunner(
+		vcsClient,
+		projectCommandBuilder,
+	)
+
+	showConfigCommandRunner := events.NewShowConfigCommandRunner(
+		vcsClient,
+		projectCommandBuilder,
+	)
+
+	listProjectsCommandRunner := events.NewListProjectsCommandRunner(
+		vcsClient,
+		projectCommandBuilder,
+		workingDir,
 	)
 
 	versionCommandRunner := events.NewVersionCommandRunner(
@@ -794,14 +980,29 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		instrumentedProjectCmdRunner,
 	)
 
+	approveStepCommandRunner := events.NewApproveStepCommandRunner(
+		vcsClient,
+		stepApprovals,
+	)
+
 	commentCommandRunnerByCmd := map[command.Name]events.CommentCommandRunner{
 		command.Plan:            planCommandRunner,
 		command.Apply:           applyCommandRunner,
 		command.ApprovePolicies: approvePoliciesCommandRunner,
+		command.PolicyCheck:     policyCheckCommentCommandRunner,
 		command.Unlock:          unlockCommandRunner,
 		command.Version:         versionCommandRunner,
 		command.Import:          importCommandRunner,
 		command.State:           stateCommandRunner,
+		command.DiscardPlan:     discardPlanCommandRunner,
+		command.LockStatus:      lockStatusCommandRunner,
+		command.ListProjects:    listProjectsCommandRunner,
+		command.ComparePlan:     comparePlanCommandRunner,
+		command.ExplainWorkflow: explainWorkflowCommandRunner,
+		command.ApproveStep:     approveStepCommandRunner,
+		command.ShowConfig:      showConfigCommandRunner,
+		command.PinPlan:         pinPlanCommandRunner,
+		command.UnpinPlan:       pinPlanCommandRunner,
 	}
 
 	githubTeamAllowlistChecker, err := events.NewTeamAllowlistChecker(userConfig.GithubTeamAllowlist)
@@ -838,6 +1039,28 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		TeamAllowlistChecker:           githubTeamAllowlistChecker,
 		VarFileAllowlistChecker:        varFileAllowlistChecker,
 		CommitStatusUpdater:            commitStatusUpdater,
+		RunCanceller:                   runCanceller,
+		Tracer:                         tracer,
+		ExpiredPlanDiscarder:           expiredPlanDiscarder,
+		WorkingDirCleaner: &events.WorkingDirCleaner{
+			WorkingDir:       workingDir,
+			WorkingDirLocker: workingDirLocker,
+			GlobalCfg:        globalCfg,
+		},
+	}
+	if userConfig.AutoplanDebounceSeconds > 0 {
+		commandRunner.AutoplanDebouncer = events.NewAutoplanDebouncer(time.Duration(userConfig.AutoplanDebounceSeconds) * time.Second)
+	} else if azuredevopsClient != nil {
+		// Autoplans always go through the debouncer for Azure DevOps so a
+		// new iteration supersedes the autoplan of the previous one.
+		commandRunner.AutoplanDebouncer = events.NewAutoplanDebouncer(0)
+	}
+	if applyQueue != nil {
+		applyQueue.Run = func(apply events.QueuedApply) {
+			commandRunner.RunCommentCommand(apply.Pull.BaseRepo, nil, nil, apply.User, apply.Pull.Num, apply.Cmd, "")
+		}
+		applyQueue.Comments = vcsClient
+		applyQueue.Logger = logger
 	}
 	repoAllowlist, err := events.NewRepoAllowlistChecker(userConfig.RepoAllowlist)
 	if err != nil {
@@ -889,6 +1112,8 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		RepoAllowlistChecker:           repoAllowlist,
 		Scope:                          statsScope.SubScope("api"),
 		VCSClient:                      vcsClient,
+		Renderer:                       markdownRenderer,
+		GlobalCfg:                      globalCfg,
 	}
 
 	eventsController := &events_controllers.VCSEventsController{
@@ -913,7 +1138,10 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		AzureDevopsWebhookBasicUser:     []byte(userConfig.AzureDevopsWebhookUser),
 		AzureDevopsWebhookBasicPassword: []byte(userConfig.AzureDevopsWebhookPassword),
 		AzureDevopsRequestValidator:     &events_controllers.DefaultAzureDevopsRequestValidator{},
+		AzureDevopsIterations:           events_controllers.NewAzureDevopsIterations(),
 		GiteaWebhookSecret:              []byte(userConfig.GiteaWebhookSecret),
+		RunIDHeader:                     userConfig.RunIDHeader,
+		Tracer:                          tracer,
 	}
 	githubAppController := &controllers.GithubAppController{
 		AtlantisURL:         parsedURL,
@@ -935,6 +1163,8 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		StatsScope:                     statsScope,
 		StatsReporter:                  statsReporter,
 		StatsCloser:                    closer,
+		Tracer:                         tracer,
+		StepApprovals:                  stepApprovals,
 		Locker:                         lockingClient,
 		ApplyLocker:                    applyLockingClient,
 		VCSEventsController:            eventsController,
@@ -970,6 +1200,7 @@ func (s *Server) Start() error {
 	s.Router.HandleFunc("/events", s.VCSEventsController.Post).Methods("POST")
 	s.Router.HandleFunc("/api/plan", s.APIController.Plan).Methods("POST")
 	s.Router.HandleFunc("/api/apply", s.APIController.Apply).Methods("POST")
+	s.Router.HandleFunc("/api/drift", s.APIController.Drift).Methods("POST")
 	s.Router.HandleFunc("/github-app/exchange-code", s.GithubAppController.ExchangeCode).Methods("GET")
 	s.Router.HandleFunc("/github-app/setup", s.GithubAppController.New).Methods("GET")
 	s.Router.HandleFunc("/locks", s.LocksController.DeleteLock).Methods("DELETE").Queries("id", "{id:.*}")
@@ -1028,12 +1259,18 @@ func (s *Server) Start() error {
 	<-stop
 
 	s.Logger.Warn("Received interrupt. Waiting for in-progress operations to complete")
+	// Steps waiting for approval could hold up the shutdown for as long as
+	// their approval timeout, and they can't be approved once it's shut down.
+	s.StepApprovals.Close()
 	s.waitForDrain()
 
-	// flush stats before shutdown
+	// flush stats and traces before shutdown
 	if err := s.StatsCloser.Close(); err != nil {
 		s.Logger.Err(err.Error())
 	}
+	if err := s.Tracer.Close(); err != nil {
+		s.Logger.Err(err.Error())
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// The OpenTelemetry env vars the exporter checks itself, see
+// https://opentelemetry.io/docs/specs/otel/protocol/exporter/. The others,
+// ex. OTEL_EXPORTER_OTLP_HEADERS, OTEL_TRACES_SAMPLER and
+// OTEL_RESOURCE_ATTRIBUTES, are read by the OpenTelemetry SDK. The traces
+// specific ones take precedence.
+const (
+	protocolEnvVar       = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	tracesProtocolEnvVar = "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"
+
+	// protobufProtocol is the only OTLP protocol the exporter speaks.
+	protobufProtocol = "http/protobuf"
+)
+
+// defaultServiceName is the service.name of Atlantis' spans unless it's set
+// with OTEL_SERVICE_NAME or OTEL_RESOURCE_ATTRIBUTES.
+const defaultServiceName = "atlantis"
+
+// checkProtocol returns an error if the OTLP protocol set with the
+// OTEL_EXPORTER_OTLP_*PROTOCOL env vars isn't the one the exporter speaks.
+func checkProtocol() error {
+	for _, name := range []string{tracesProtocolEnvVar, protocolEnvVar} {
+		if protocol := os.Getenv(name); protocol != "" {
+			if protocol != protobufProtocol {
+				return fmt.Errorf("%s is %q but only %q is supported", name, protocol, protobufProtocol)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// newResource returns the resource of Atlantis' spans, from the
+// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES env vars.
+func newResource() (*resource.Resource, error) {
+	res, err := resource.New(context.Background(),
+		resource.WithAttributes(attribute.String("service.name", defaultServiceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("parsing OTEL_RESOURCE_ATTRIBUTES: %s", err)
+	}
+	return res, nil
+}
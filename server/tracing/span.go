@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxStatusMessageLength is the longest status message of a span, ex. from a
+// step's error with the output of the command it ran.
+const maxStatusMessageLength = 1024
+
+// Span is an operation of a run, ex. a project's plan or one of its steps. A
+// nil *Span does nothing so callers don't need to check if tracing is on.
+type Span struct {
+	tracer trace.Tracer
+	span   trace.Span
+	parent *Span
+
+	mu             sync.Mutex
+	ended          bool
+	failedChildren int
+}
+
+// StartChild starts a span for an operation of s.
+func (s *Span) StartChild(name string) *Span {
+	if s == nil {
+		return nil
+	}
+	ctx := trace.ContextWithSpan(context.Background(), s.span)
+	_, span := s.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindInternal))
+	return &Span{tracer: s.tracer, span: span, parent: s}
+}
+
+// SetAttribute sets the attribute key of s to value, which must be a string,
+// int or bool. Other values are formatted as strings.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(newAttribute(key, value))
+}
+
+// End ends s and queues it to be exported. Its status is an error if err is
+// set or if any of its children failed. Only the first call to End counts so
+// it can be deferred as well as called once the outcome is known.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	failed := true
+	switch {
+	case err != nil:
+		msg := err.Error()
+		if len(msg) > maxStatusMessageLength {
+			msg = msg[:maxStatusMessageLength] + "..."
+		}
+		s.span.SetStatus(codes.Error, msg)
+	case s.failedChildren > 0:
+		s.span.SetStatus(codes.Error, fmt.Sprintf("%d of its operations failed", s.failedChildren))
+	default:
+		failed = false
+	}
+	s.mu.Unlock()
+
+	if failed && s.parent != nil {
+		s.parent.mu.Lock()
+		s.parent.failedChildren++
+		s.parent.mu.Unlock()
+	}
+	s.span.End()
+}
+
+// Traceparent returns the W3C traceparent header of s, to continue its trace
+// in other systems.
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(trace.ContextWithSpan(context.Background(), s.span), carrier)
+	return carrier.Get("traceparent")
+}
+
+// newAttribute returns the attribute key with value, which is formatted as a
+// string unless it's a string, int or bool.
+func newAttribute(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,152 @@
+// Package tracing traces runs with OpenTelemetry spans exported to a
+// collector with OTLP over HTTP. The exporter is configured with the standard
+// OTEL_* env vars read by the OpenTelemetry SDK.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// tracesPath is the path collectors receive OTLP traces on.
+	tracesPath = "/v1/traces"
+	// scopeName is the name of the instrumentation scope of Atlantis' spans.
+	scopeName = "atlantis"
+	// remoteParentTTL is how long the trace context of a webhook is kept for
+	// the run it starts.
+	remoteParentTTL = 10 * time.Minute
+	// closeTimeout is how long Close waits for the spans that ended to be
+	// exported.
+	closeTimeout = 10 * time.Second
+)
+
+// Tracer creates the spans of runs and exports them once they end. A nil
+// *Tracer doesn't trace, its spans are nil and do nothing.
+type Tracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+
+	mu            sync.Mutex
+	remoteParents map[string]remoteParent
+}
+
+// remoteParent is the trace context of a webhook, kept for the run it starts.
+type remoteParent struct {
+	spanContext trace.SpanContext
+	received    time.Time
+}
+
+// NewTracer returns a tracer that exports spans to the collector at endpoint,
+// ex. http://localhost:4318. Spans are sent to its /v1/traces path unless
+// endpoint already has it. The headers sent with them, the sampler and the
+// resource attributes are configured with the standard OTEL_* env vars.
+func NewTracer(endpoint string, logger logging.SimpleLogging) (*Tracer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%q isn't an OTLP endpoint, it must be an http or https URL, ex. http://localhost:4318", endpoint)
+	}
+	if err := checkProtocol(); err != nil {
+		return nil, err
+	}
+	res, err := newResource()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(u.Path, tracesPath) {
+		u.Path = strings.TrimSuffix(u.Path, "/") + tracesPath
+	}
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(u.String()))
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter for %q: %s", u, err)
+	}
+	// The SDK reports failed exports, ex. when the collector can't be
+	// reached, to the global error handler.
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warn("exporting spans to %q: %s", u, err)
+	}))
+	// Without a sampler option the SDK's is configured with
+	// OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG.
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	return &Tracer{
+		provider:      provider,
+		tracer:        provider.Tracer(scopeName),
+		remoteParents: make(map[string]remoteParent),
+	}, nil
+}
+
+// SetRemoteParent keeps the W3C trace context of the webhook that starts the
+// run with ID runID, from its traceparent header, so the run's span is its
+// child. It returns false if traceparent isn't valid.
+func (t *Tracer) SetRemoteParent(runID string, traceparent string) bool {
+	if t == nil {
+		return false
+	}
+	ctx := propagation.TraceContext{}.Extract(context.Background(), propagation.MapCarrier{"traceparent": traceparent})
+	parent := trace.SpanContextFromContext(ctx)
+	if !parent.IsValid() {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	// Webhooks that don't start a run, ex. ignored events, leave their
+	// trace context behind.
+	for id, p := range t.remoteParents {
+		if now.Sub(p.received) > remoteParentTTL {
+			delete(t.remoteParents, id)
+		}
+	}
+	t.remoteParents[runID] = remoteParent{spanContext: parent, received: now}
+	return true
+}
+
+// StartRun starts the span of the run with ID runID. It's the child of the
+// trace context of the webhook that started the run if it had one, otherwise
+// it starts a new trace. Spans of runs that aren't sampled aren't exported
+// but still propagate their trace context.
+func (t *Tracer) StartRun(runID string, name string) *Span {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	parent, ok := t.remoteParents[runID]
+	delete(t.remoteParents, runID)
+	t.mu.Unlock()
+
+	ctx := context.Background()
+	if ok {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, parent.spanContext)
+	}
+	_, span := t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("atlantis.run_id", runID)),
+	)
+	return &Span{tracer: t.tracer, span: span}
+}
+
+// Close exports the spans that ended and stops exporting. Spans that end
+// afterwards are dropped.
+func (t *Tracer) Close() error {
+	if t == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	return t.provider.Shutdown(ctx)
+}
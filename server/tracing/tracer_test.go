@@ -0,0 +1,288 @@
+package tracing_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/tracing"
+	. "github.com/runatlantis/atlantis/testing"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	tracev1 "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+type exportedSpan struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	Kind         int
+	Attributes   map[string]string
+	Status       struct {
+		Code    int
+		Message string
+	}
+}
+
+func (s exportedSpan) attribute(key string) string {
+	return s.Attributes[key]
+}
+
+// attributeValues returns the values of attrs by key, formatted as strings.
+func attributeValues(attrs []*commonv1.KeyValue) map[string]string {
+	values := make(map[string]string)
+	for _, a := range attrs {
+		switch v := a.GetValue().GetValue().(type) {
+		case *commonv1.AnyValue_StringValue:
+			values[a.GetKey()] = v.StringValue
+		case *commonv1.AnyValue_IntValue:
+			values[a.GetKey()] = strconv.FormatInt(v.IntValue, 10)
+		case *commonv1.AnyValue_BoolValue:
+			values[a.GetKey()] = strconv.FormatBool(v.BoolValue)
+		}
+	}
+	return values
+}
+
+func newExportedSpan(s *tracev1.Span) exportedSpan {
+	span := exportedSpan{
+		TraceID:      hex.EncodeToString(s.GetTraceId()),
+		SpanID:       hex.EncodeToString(s.GetSpanId()),
+		ParentSpanID: hex.EncodeToString(s.GetParentSpanId()),
+		Name:         s.GetName(),
+		Kind:         int(s.GetKind()),
+		Attributes:   attributeValues(s.GetAttributes()),
+	}
+	span.Status.Code = int(s.GetStatus().GetCode())
+	span.Status.Message = s.GetStatus().GetMessage()
+	return span
+}
+
+// decodeTraces decodes the OTLP traces of an export request.
+func decodeTraces(t *testing.T, r *http.Request) *collectortrace.ExportTraceServiceRequest {
+	Equals(t, "/v1/traces", r.URL.Path)
+	Equals(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+	body, err := io.ReadAll(r.Body)
+	Ok(t, err)
+	var req collectortrace.ExportTraceServiceRequest
+	Ok(t, proto.Unmarshal(body, &req))
+	return &req
+}
+
+// collector returns the URL of a fake OTLP collector and a func returning the
+// spans it received by name.
+func collector(t *testing.T) (string, func() map[string]exportedSpan) {
+	var mu sync.Mutex
+	spans := make(map[string]exportedSpan)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := decodeTraces(t, r)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range req.GetResourceSpans() {
+			for _, ss := range rs.GetScopeSpans() {
+				for _, s := range ss.GetSpans() {
+					spans[s.GetName()] = newExportedSpan(s)
+				}
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, func() map[string]exportedSpan {
+		mu.Lock()
+		defer mu.Unlock()
+		return spans
+	}
+}
+
+func TestTracer_Run(t *testing.T) {
+	url, received := collector(t)
+	tracer, err := tracing.NewTracer(url, logging.NewNoopLogger(t))
+	Ok(t, err)
+
+	Assert(t, tracer.SetRemoteParent("run-1", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"), "exp valid traceparent")
+	run := tracer.StartRun("run-1", "atlantis plan")
+	run.SetAttribute("atlantis.repo", "owner/repo")
+	run.SetAttribute("atlantis.pull", 1)
+	project := run.StartChild("project plan")
+	project.SetAttribute("atlantis.workspace", "default")
+	step := project.StartChild("step plan")
+	Assert(t, strings.HasPrefix(step.Traceparent(), "00-4bf92f3577b34da6a3ce929d0e0e4736-"), "exp step to be in the webhook's trace, got %q", step.Traceparent())
+	step.End(errors.New("exit status 1"))
+	// Only the first End counts.
+	step.End(nil)
+	project.End(nil)
+	run.End(nil)
+	Ok(t, tracer.Close())
+
+	spans := received()
+	Equals(t, 3, len(spans))
+	Equals(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans["atlantis plan"].TraceID)
+	Equals(t, "00f067aa0ba902b7", spans["atlantis plan"].ParentSpanID)
+	Equals(t, 2, spans["atlantis plan"].Kind)
+	Equals(t, "run-1", spans["atlantis plan"].attribute("atlantis.run_id"))
+	Equals(t, "owner/repo", spans["atlantis plan"].attribute("atlantis.repo"))
+	Equals(t, "1", spans["atlantis plan"].attribute("atlantis.pull"))
+	Equals(t, spans["atlantis plan"].SpanID, spans["project plan"].ParentSpanID)
+	Equals(t, spans["project plan"].SpanID, spans["step plan"].ParentSpanID)
+	Equals(t, "default", spans["project plan"].attribute("atlantis.workspace"))
+
+	// The failed step fails its project, which fails the run.
+	Equals(t, 2, spans["step plan"].Status.Code)
+	Equals(t, "exit status 1", spans["step plan"].Status.Message)
+	Equals(t, 2, spans["project plan"].Status.Code)
+	Equals(t, "1 of its operations failed", spans["project plan"].Status.Message)
+	Equals(t, 2, spans["atlantis plan"].Status.Code)
+}
+
+// Test that runs without a webhook trace context start a new trace.
+func TestTracer_NewTrace(t *testing.T) {
+	url, received := collector(t)
+	tracer, err := tracing.NewTracer(url+"/v1/traces", logging.NewNoopLogger(t))
+	Ok(t, err)
+
+	Assert(t, !tracer.SetRemoteParent("run-1", "not-a-traceparent"), "exp invalid traceparent")
+	Assert(t, !tracer.SetRemoteParent("run-1", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"), "exp invalid trace ID")
+	run := tracer.StartRun("run-1", "atlantis autoplan")
+	run.End(nil)
+	Ok(t, tracer.Close())
+
+	span := received()["atlantis autoplan"]
+	Equals(t, "", span.ParentSpanID)
+	Equals(t, 32, len(span.TraceID))
+	Equals(t, 0, span.Status.Code)
+
+	// Spans that end after the tracer is closed are dropped.
+	tracer.StartRun("run-2", "atlantis apply").End(nil)
+	Ok(t, tracer.Close())
+	_, ok := received()["atlantis apply"]
+	Assert(t, !ok, "exp span to be dropped")
+}
+
+func TestTracer_Nil(t *testing.T) {
+	var tracer *tracing.Tracer
+	Assert(t, !tracer.SetRemoteParent("run-1", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"), "exp nil tracer not to trace")
+	run := tracer.StartRun("run-1", "atlantis plan")
+	Assert(t, run == nil, "exp nil span")
+	step := run.StartChild("step plan")
+	step.SetAttribute("atlantis.step", "plan")
+	step.End(errors.New("err"))
+	Equals(t, "", step.Traceparent())
+	Ok(t, tracer.Close())
+}
+
+func TestNewTracer_InvalidEndpoint(t *testing.T) {
+	_, err := tracing.NewTracer("localhost:4318", logging.NewNoopLogger(t))
+	ErrEquals(t, `"localhost:4318" isn't an OTLP endpoint, it must be an http or https URL, ex. http://localhost:4318`, err)
+}
+
+// Test that the headers and resource attributes from the OTEL_* env vars are
+// sent with the spans.
+func TestTracer_EnvConfig(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "X-Team=platform")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS", "Authorization=Bearer%20token")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "service.name=ignored,deployment.environment=prod")
+	t.Setenv("OTEL_SERVICE_NAME", "atlantis-prod")
+	var mu sync.Mutex
+	var headers http.Header
+	var resource map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := decodeTraces(t, r)
+		mu.Lock()
+		defer mu.Unlock()
+		headers = r.Header
+		resource = attributeValues(req.GetResourceSpans()[0].GetResource().GetAttributes())
+	}))
+	t.Cleanup(srv.Close)
+	tracer, err := tracing.NewTracer(srv.URL, logging.NewNoopLogger(t))
+	Ok(t, err)
+
+	tracer.StartRun("run-1", "atlantis plan").End(nil)
+	Ok(t, tracer.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	// The traces specific headers replace the others.
+	Equals(t, "Bearer token", headers.Get("Authorization"))
+	Equals(t, "", headers.Get("X-Team"))
+	Equals(t, map[string]string{"service.name": "atlantis-prod", "deployment.environment": "prod"}, resource)
+}
+
+func TestTracer_Sampling(t *testing.T) {
+	sampledParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	unsampledParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+	cases := []struct {
+		sampler     string
+		arg         string
+		traceparent string
+		expSampled  bool
+	}{
+		{sampler: "", expSampled: true},
+		{sampler: "", traceparent: unsampledParent, expSampled: false},
+		{sampler: "always_on", traceparent: unsampledParent, expSampled: true},
+		{sampler: "always_off", traceparent: sampledParent, expSampled: false},
+		{sampler: "parentbased_always_off", expSampled: false},
+		{sampler: "parentbased_always_off", traceparent: sampledParent, expSampled: true},
+		{sampler: "traceidratio", arg: "0", expSampled: false},
+		{sampler: "traceidratio", arg: "1", expSampled: true},
+		{sampler: "parentbased_traceidratio", arg: "0", traceparent: sampledParent, expSampled: true},
+	}
+	for _, c := range cases {
+		t.Run(c.sampler+" "+c.arg+" "+c.traceparent, func(t *testing.T) {
+			t.Setenv("OTEL_TRACES_SAMPLER", c.sampler)
+			t.Setenv("OTEL_TRACES_SAMPLER_ARG", c.arg)
+			url, received := collector(t)
+			tracer, err := tracing.NewTracer(url, logging.NewNoopLogger(t))
+			Ok(t, err)
+
+			if c.traceparent != "" {
+				Assert(t, tracer.SetRemoteParent("run-1", c.traceparent), "exp valid traceparent")
+			}
+			run := tracer.StartRun("run-1", "atlantis plan")
+			step := run.StartChild("step plan")
+			step.End(nil)
+			run.End(nil)
+			Ok(t, tracer.Close())
+
+			_, ok := received()["atlantis plan"]
+			Equals(t, c.expSampled, ok)
+			_, ok = received()["step plan"]
+			Equals(t, c.expSampled, ok)
+			// The sampling decision is propagated.
+			Equals(t, c.expSampled, strings.HasSuffix(step.Traceparent(), "-01"))
+		})
+	}
+}
+
+func TestNewTracer_InvalidEnvConfig(t *testing.T) {
+	cases := []struct {
+		env    map[string]string
+		expErr string
+	}{
+		{
+			env:    map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"},
+			expErr: `OTEL_EXPORTER_OTLP_PROTOCOL is "grpc" but only "http/protobuf" is supported`,
+		},
+		{
+			env:    map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc", "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL": "http/json"},
+			expErr: `OTEL_EXPORTER_OTLP_TRACES_PROTOCOL is "http/json" but only "http/protobuf" is supported`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			for k, v := range c.env {
+				t.Setenv(k, v)
+			}
+			_, err := tracing.NewTracer("http://localhost:4318", logging.NewNoopLogger(t))
+			ErrEquals(t, c.expErr, err)
+		})
+	}
+}
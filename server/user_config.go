@@ -3,6 +3,7 @@ package server
 import (
 	"strings"
 
+	"github.com/runatlantis/atlantis/server/events"
 	"github.com/runatlantis/atlantis/server/events/command"
 	"github.com/runatlantis/atlantis/server/logging"
 )
@@ -13,12 +14,14 @@ import (
 type UserConfig struct {
 	AllowForkPRs                bool   `mapstructure:"allow-fork-prs"`
 	AllowCommands               string `mapstructure:"allow-commands"`
+	ApplyQueueMaxSize           int    `mapstructure:"apply-queue-max-size"`
 	AtlantisURL                 string `mapstructure:"atlantis-url"`
 	AutoDiscoverModeFlag        string `mapstructure:"autodiscover-mode"`
 	Automerge                   bool   `mapstructure:"automerge"`
 	AutoplanFileList            string `mapstructure:"autoplan-file-list"`
 	AutoplanModules             bool   `mapstructure:"autoplan-modules"`
 	AutoplanModulesFromProjects string `mapstructure:"autoplan-modules-from-projects"`
+	AutoplanDebounceSeconds     int    `mapstructure:"autoplan-debounce-seconds"`
 	AzureDevopsToken            string `mapstructure:"azuredevops-token"`
 	AzureDevopsUser             string `mapstructure:"azuredevops-user"`
 	AzureDevopsWebhookPassword  string `mapstructure:"azuredevops-webhook-password"`
@@ -30,6 +33,7 @@ type UserConfig struct {
 	BitbucketWebhookSecret      string `mapstructure:"bitbucket-webhook-secret"`
 	CheckoutDepth               int    `mapstructure:"checkout-depth"`
 	CheckoutStrategy            string `mapstructure:"checkout-strategy"`
+	CloneURLRewrites            string `mapstructure:"clone-url-rewrites"`
 	DataDir                     string `mapstructure:"data-dir"`
 	DisableApplyAll             bool   `mapstructure:"disable-apply-all"`
 	DisableAutoplan             bool   `mapstructure:"disable-autoplan"`
@@ -73,9 +77,12 @@ type UserConfig struct {
 	LockingDBType                   string `mapstructure:"locking-db-type"`
 	LogLevel                        string `mapstructure:"log-level"`
 	MarkdownTemplateOverridesDir    string `mapstructure:"markdown-template-overrides-dir"`
+	OTelEndpoint                    string `mapstructure:"otel-endpoint"`
 	ParallelPoolSize                int    `mapstructure:"parallel-pool-size"`
 	ParallelPlan                    bool   `mapstructure:"parallel-plan"`
 	ParallelApply                   bool   `mapstructure:"parallel-apply"`
+	PlanEncryptionKeyFile           string `mapstructure:"plan-encryption-key-file"`
+	PlanEncryptionKMSKeyID          string `mapstructure:"plan-encryption-kms-key-id"`
 	StatsNamespace                  string `mapstructure:"stats-namespace"`
 	PlanDrafts                      bool   `mapstructure:"allow-draft-prs"`
 	Port                            int    `mapstructure:"port"`
@@ -90,6 +97,8 @@ type UserConfig struct {
 	RepoConfigJSON                  string `mapstructure:"repo-config-json"`
 	RepoAllowlist                   string `mapstructure:"repo-allowlist"`
 
+	// SecretsDir is the directory of secrets env steps can reference.
+	SecretsDir string `mapstructure:"secrets-dir"`
 	// SilenceNoProjects is whether Atlantis should respond to a PR if no projects are found.
 	SilenceNoProjects   bool `mapstructure:"silence-no-projects"`
 	SilenceForkPRErrors bool `mapstructure:"silence-fork-pr-errors"`
@@ -104,14 +113,24 @@ type UserConfig struct {
 	SlackToken                 string          `mapstructure:"slack-token"`
 	SSLCertFile                string          `mapstructure:"ssl-cert-file"`
 	SSLKeyFile                 string          `mapstructure:"ssl-key-file"`
+	StepCacheMaxSizeMB         int             `mapstructure:"step-cache-max-size-mb"`
 	RestrictFileList           bool            `mapstructure:"restrict-file-list"`
+	RunIDEnvVar                string          `mapstructure:"run-id-env-var"`
+	RunIDHeader                string          `mapstructure:"run-id-header"`
+	RunStepFixtures            string          `mapstructure:"run-step-fixtures"`
+	RunStepFixturesDir         string          `mapstructure:"run-step-fixtures-dir"`
 	TFDownload                 bool            `mapstructure:"tf-download"`
 	TFDownloadURL              string          `mapstructure:"tf-download-url"`
 	TFEHostname                string          `mapstructure:"tfe-hostname"`
 	TFELocalExecutionMode      bool            `mapstructure:"tfe-local-execution-mode"`
 	TFEToken                   string          `mapstructure:"tfe-token"`
 	VarFileAllowlist           string          `mapstructure:"var-file-allowlist"`
+	VCSMaxRetries              int             `mapstructure:"vcs-max-retries"`
+	VCSRetryBackoffSeconds     int             `mapstructure:"vcs-retry-backoff-seconds"`
 	VCSStatusName              string          `mapstructure:"vcs-status-name"`
+	VCSStatusContextTemplate   string          `mapstructure:"vcs-status-context-template"`
+	VCSStatusPlanSummary       bool            `mapstructure:"vcs-status-plan-summary"`
+	VCSStatusStrategy          string          `mapstructure:"vcs-status-strategy"`
 	DefaultTFVersion           string          `mapstructure:"default-tf-version"`
 	Webhooks                   []WebhookConfig `mapstructure:"webhooks" flag:"false"`
 	WebBasicAuth               bool            `mapstructure:"web-basic-auth"`
@@ -146,6 +165,12 @@ func (u UserConfig) ToAllowCommandNames() ([]command.Name, error) {
 	return allowCommands, nil
 }
 
+// ToCloneURLRewrites parses CloneURLRewrites into the rules rewriting clone
+// URLs.
+func (u UserConfig) ToCloneURLRewrites() ([]events.CloneURLRewrite, error) {
+	return events.ParseCloneURLRewrites(u.CloneURLRewrites)
+}
+
 // ToLogLevel returns the LogLevel object corresponding to the user-passed
 // log level.
 func (u UserConfig) ToLogLevel() logging.LogLevel {
@@ -28,14 +28,18 @@ func TestUserConfig_ToAllowCommandNames(t *testing.T) {
 			name:          "all",
 			allowCommands: "all",
 			want: []command.Name{
-				command.Version, command.Plan, command.Apply, command.Unlock, command.ApprovePolicies, command.Import, command.State,
+				command.Version, command.Plan, command.Apply, command.Unlock, command.ApprovePolicies, command.PolicyCheck, command.Import, command.State,
+				command.DiscardPlan, command.LockStatus, command.ListProjects, command.ComparePlan, command.ExplainWorkflow, command.ApproveStep,
+				command.ShowConfig, command.PinPlan, command.UnpinPlan,
 			},
 		},
 		{
 			name:          "all with others returns same with all result",
 			allowCommands: "all,plan",
 			want: []command.Name{
-				command.Version, command.Plan, command.Apply, command.Unlock, command.ApprovePolicies, command.Import, command.State,
+				command.Version, command.Plan, command.Apply, command.Unlock, command.ApprovePolicies, command.PolicyCheck, command.Import, command.State,
+				command.DiscardPlan, command.LockStatus, command.ListProjects, command.ComparePlan, command.ExplainWorkflow, command.ApproveStep,
+				command.ShowConfig, command.PinPlan, command.UnpinPlan,
 			},
 		},
 		{
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+)
+
+// MaskedValue replaces secrets masked in output.
+const MaskedValue = "***"
+
+// MaskSecrets replaces every secret in s with MaskedValue. Longer secrets are
+// replaced first so a secret containing another is masked entirely.
+func MaskSecrets(s string, secrets []string) string {
+	if len(secrets) == 0 {
+		return s
+	}
+	sorted := append([]string(nil), secrets...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+	for _, secret := range sorted {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, MaskedValue)
+		}
+	}
+	return s
+}
@@ -0,0 +1,14 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/utils"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestMaskSecrets(t *testing.T) {
+	Equals(t, "password is *** and *** again", utils.MaskSecrets("password is hunter2 and hunter2 again", []string{"hunt", "hunter2"}))
+	Equals(t, "no secrets", utils.MaskSecrets("no secrets", nil))
+	Equals(t, "empty secret", utils.MaskSecrets("empty secret", []string{""}))
+}